@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence/migrate"
+)
+
+const (
+	commandExportStore = "export-store"
+	commandImportStore = "import-store"
+)
+
+// runExportStore writes all scan jobs of the configured store backend, including their reports, to a gzipped
+// tarball at the given path, e.g. to back them up or to clone an environment.
+func runExportStore(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: scanner-tunnel %s <archive.tar.gz>", commandExportStore)
+	}
+
+	return withStoreBackend(ctx, func(config etc.Config, rdb goredis.UniversalClient, db *sql.DB) error {
+		store, err := newStoreBackend(config.Store.Backend, config, config.RedisStore, rdb, db)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.OpenFile(args[0], os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return fmt.Errorf("creating archive: %w", err)
+		}
+		exported, err := migrate.Export(ctx, store, f)
+		if closeErr := f.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("closing archive: %w", closeErr)
+		}
+		if err != nil {
+			_ = os.Remove(args[0])
+			return fmt.Errorf("exporting scan jobs: %w", err)
+		}
+
+		slog.Info("Exported scan jobs", slog.String("backend", config.Store.Backend),
+			slog.String("archive", args[0]), slog.Int("exported", exported))
+		return nil
+	})
+}
+
+// runImportStore copies the scan jobs of a tarball written by the export-store command to the configured store
+// backend, e.g. to restore a backup into a new instance or another backend.
+func runImportStore(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: scanner-tunnel %s <archive.tar.gz>", commandImportStore)
+	}
+
+	return withStoreBackend(ctx, func(config etc.Config, rdb goredis.UniversalClient, db *sql.DB) error {
+		store, err := newStoreBackend(config.Store.Backend, config, config.RedisStore, rdb, db)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("opening archive: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+
+		result, err := migrate.Import(ctx, store, f)
+		if err != nil {
+			return fmt.Errorf("importing scan jobs: %w", err)
+		}
+
+		slog.Info("Imported scan jobs", slog.String("backend", config.Store.Backend),
+			slog.String("archive", args[0]), slog.Int("copied", result.Copied), slog.Int("unchanged", result.Unchanged))
+		return nil
+	})
+}
+
+// withStoreBackend calls the given function with the configuration and, if used, a connection pool to Redis and a
+// connection pool to PostgreSQL, which are closed once the function returns.
+func withStoreBackend(ctx context.Context, fn func(config etc.Config, rdb goredis.UniversalClient, db *sql.DB) error) error {
+	config, err := etc.GetConfig()
+	if err != nil {
+		return fmt.Errorf("getting config: %w", err)
+	}
+
+	rdb, err := openRedis(&config)
+	if err != nil {
+		return err
+	}
+	if rdb != nil {
+		defer func() { _ = rdb.Close() }()
+	}
+
+	db, err := openPostgres(ctx, config)
+	if err != nil {
+		return err
+	}
+	if db != nil {
+		defer func() { _ = db.Close() }()
+	}
+
+	return fn(config, rdb, db)
+}
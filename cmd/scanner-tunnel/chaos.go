@@ -0,0 +1,25 @@
+//go:build chaos
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/chaos"
+)
+
+// newFaultInjector enables fault injection in binaries built with the `chaos` build tag.
+func newFaultInjector() (*chaos.Injector, error) {
+	config, err := chaos.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("getting chaos config: %w", err)
+	}
+	slog.Warn("Fault injection is enabled, do not use this build in production",
+		slog.Int64("seed", config.Seed),
+		slog.Any("store", config.Store),
+		slog.Any("queue", config.Queue),
+		slog.Any("scanner", config.Scanner),
+	)
+	return chaos.NewInjector(config), nil
+}
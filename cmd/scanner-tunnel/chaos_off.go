@@ -0,0 +1,12 @@
+//go:build !chaos
+
+package main
+
+import (
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/chaos"
+)
+
+// newFaultInjector disables fault injection in regular builds.
+func newFaultInjector() (*chaos.Injector, error) {
+	return nil, nil
+}
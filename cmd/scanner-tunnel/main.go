@@ -3,20 +3,58 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/allowlist"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/audit"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/chaos"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/classify"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/clock"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/connectivity"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/crd"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/enrich"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/epss"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/ext"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/feature"
+	grpcapi "github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/grpc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/http/api"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/http/api/auth"
 	v1 "github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/http/api/v1"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/httpx"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/impact"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/log"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/metrics"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/notify"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/nvd"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/opa"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence/history"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence/postgres"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence/redis"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence/spill"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/policy"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/policy/bundle"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/queue"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/redisx"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/registry"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/scan"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/shadow"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/slo"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/support"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/telemetry"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/throttle"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tracing"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/vex"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 var (
@@ -26,10 +64,17 @@ var (
 	date    = "unknown"
 )
 
+// supportLogRecords is the number of the most recent log records included in support bundles.
+const supportLogRecords = 1000
+
+// tracingExportTimeout bounds each export of spans to the OTLP receiver.
+const tracingExportTimeout = 10 * time.Second
+
 func main() {
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+	logs := support.NewLogBuffer(supportLogRecords)
+	logger := slog.New(log.NewHandler(slog.NewJSONHandler(io.MultiWriter(os.Stdout, logs), &slog.HandlerOptions{
 		Level: etc.LogLevel(),
-	}))
+	})))
 	slog.SetDefault(logger)
 
 	info := etc.BuildInfo{
@@ -39,13 +84,32 @@ func main() {
 	}
 
 	ctx := context.Background()
-	if err := run(ctx, info); err != nil {
-		slog.Error("Error: %v", err)
+
+	var command string
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+
+	var err error
+	switch command {
+	case commandMigrateStore:
+		err = runMigrateStore(ctx)
+	case commandExportStore:
+		err = runExportStore(ctx, os.Args[2:])
+	case commandImportStore:
+		err = runImportStore(ctx, os.Args[2:])
+	case commandMigrateNamespace:
+		err = runMigrateNamespace(ctx, os.Args[2:])
+	default:
+		err = run(ctx, info, logs)
+	}
+	if err != nil {
+		slog.Error("Error", slog.String("err", err.Error()))
 		os.Exit(1)
 	}
 }
 
-func run(ctx context.Context, info etc.BuildInfo) error {
+func run(ctx context.Context, info etc.BuildInfo, logs *support.LogBuffer) error {
 	slog.Info("Starting harbor-scanner-tunnel", slog.String("version", info.Version),
 		slog.String("commit", info.Commit), slog.String("built_at", info.Date),
 	)
@@ -58,22 +122,373 @@ func run(ctx context.Context, info etc.BuildInfo) error {
 		return fmt.Errorf("checking config: %w", err)
 	}
 
-	rdb, err := redisx.NewClient(config.RedisPool)
+	if config.Tracing.Enabled {
+		slog.Info("Exporting traces", slog.String("otlp_endpoint", config.Tracing.OTLPEndpoint),
+			slog.Float64("sample_ratio", config.Tracing.SampleRatio))
+		provider := tracing.NewTracerProvider(config.Tracing, info, httpx.Client("tracing", tracingExportTimeout))
+		defer func() { _ = provider.Shutdown(context.Background()) }()
+	}
+
+	rdb, err := openRedis(&config)
 	if err != nil {
-		return fmt.Errorf("constructing connection pool: %w", err)
+		return err
 	}
 
-	wrapper := tunnel.NewWrapper(config.Tunnel, ext.DefaultAmbassador)
-	store := redis.NewStore(config.RedisStore, rdb)
-	controller := scan.NewController(store, wrapper, scan.NewTransformer(&scan.SystemClock{}))
-	enqueuer := queue.NewEnqueuer(config.JobQueue, rdb, store)
-	worker := queue.NewWorker(config.JobQueue, rdb, controller)
+	idGenerator, err := job.NewIDGenerator(config.JobQueue.IDGenerator)
+	if err != nil {
+		return fmt.Errorf("constructing scan job ID generator: %w", err)
+	}
+
+	faults, err := newFaultInjector()
+	if err != nil {
+		return err
+	}
 
-	apiHandler := v1.NewAPIHandler(info, config, enqueuer, store, wrapper)
+	var ambassador ext.Ambassador = ext.DefaultAmbassador
+	if config.Tunnel.MaxPullBandwidth > 0 {
+		pullProxy := throttle.NewProxy(config.Tunnel.MaxPullBandwidth)
+		if err = pullProxy.Start("127.0.0.1:0"); err != nil {
+			return fmt.Errorf("starting pull proxy: %w", err)
+		}
+		defer func() { _ = pullProxy.Close() }()
+		slog.Info("Throttling image pulls", slog.Int64("max_bytes_per_second", config.Tunnel.MaxPullBandwidth),
+			slog.String("proxy_url", pullProxy.URL()))
+
+		ambassador = throttle.NewAmbassador(ambassador, pullProxy.URL())
+		prometheus.MustRegister(metrics.NewPullCollector(pullProxy, config.Tunnel.MaxPullBandwidth))
+	}
+
+	if config.Tunnel.Parallel == 0 {
+		config.Tunnel.Parallel = tunnel.DefaultParallel(config.Tunnel, config.JobQueue.WorkerConcurrency)
+	}
+	slog.Debug("Tunnel layer parallelism", slog.Int("parallel", config.Tunnel.Parallel))
+
+	// The policy bundle is applied before constructing the wrapper, which passes its ignore policy to Tunnel.
+	var bundles bundle.Loader
+	if config.Policy.BundleURL != "" {
+		if bundles, err = newPolicyBundleLoader(ctx, config); err != nil {
+			return err
+		}
+		config.Tunnel.IgnorePolicy = bundles.IgnorePolicyFile()
+	}
+
+	var wrapperOptions []tunnel.WrapperOption
+	if len(config.Tunnel.RegistryHeaders) > 0 {
+		gateway := registry.NewGateway(config.Tunnel)
+		defer func() { _ = gateway.Close() }()
+		wrapperOptions = append(wrapperOptions, tunnel.WithRegistryGateway(gateway))
+	}
+
+	pinnedWrapper, pin := tunnel.NewPinnedWrapper(tunnel.NewWrapper(config.Tunnel, ambassador, wrapperOptions...),
+		config.Tunnel, ambassador)
+	wrapper := chaos.NewWrapper(pinnedWrapper, faults)
+	db, err := openPostgres(ctx, config)
+	if err != nil {
+		return err
+	}
+
+	backend, err := newStore(config, rdb, db)
+	if err != nil {
+		return fmt.Errorf("constructing store: %w", err)
+	}
+
+	store := chaos.NewStore(tracing.NewStore(metrics.NewStore(backend)), faults)
+	if config.Audit.IsEnabled() {
+		sink, err := audit.NewSink(config.Audit)
+		if err != nil {
+			return fmt.Errorf("new audit sink: %w", err)
+		}
+		slog.Info("Exporting scan job events", slog.String("sink", config.Audit.Sink))
+		exporter := audit.NewExporter(config.Audit, sink)
+		defer exporter.Close()
+		store = audit.NewStore(store, exporter)
+	}
+	if config.Telemetry.Enabled {
+		slog.Info("Reporting anonymized usage telemetry", slog.String("endpoint", config.Telemetry.Endpoint),
+			slog.Duration("interval", config.Telemetry.Interval))
+		reporter := telemetry.NewReporter(config.Telemetry, info)
+		defer reporter.Close()
+		store = telemetry.NewStore(store, reporter)
+	}
+	var jobHistory history.History
+	if config.Store.HistorySize > 0 {
+		jobHistory = history.NewHistory(config.Store.HistorySize)
+		store = history.NewStore(store, jobHistory)
+	}
+	// The vulnerability index, the lifetimes and the exceptions are kept in Redis only, hence without Redis the
+	// features based on them are disabled.
+	var index persistence.VulnerabilityIndex
+	var lifetimes persistence.LifetimeStore
+	var exceptions persistence.ExceptionStore
+	if rdb != nil {
+		index = redis.NewVulnerabilityIndex(config.RedisStore, rdb)
+		lifetimes = redis.NewLifetimeStore(config.RedisStore, rdb)
+		exceptions = redis.NewExceptionStore(config.RedisStore, rdb)
+	}
+	var transformerOptions []scan.TransformerOption
+	if config.Classification.Enabled {
+		transformerOptions = append(transformerOptions,
+			scan.WithClassifier(classify.NewClassifier(config.Classification)))
+	}
+	if len(config.Allowlist.CVEs) > 0 || config.Allowlist.File != "" {
+		list, err := allowlist.Load(config.Allowlist, clock.System)
+		if err != nil {
+			return fmt.Errorf("loading allowlist: %w", err)
+		}
+		slog.Info("Applying CVE allowlist", slog.String("mode", config.Allowlist.Mode))
+		transformerOptions = append(transformerOptions, scan.WithAllowlist(list, config.Allowlist.Mode))
+	}
+	if config.VEX.IsEnabled() {
+		matcher, err := vex.Load(ctx, config.VEX, config.Tunnel)
+		if err != nil {
+			return fmt.Errorf("loading VEX documents: %w", err)
+		}
+		slog.Info("Applying VEX statements", slog.String("path", config.VEX.Path),
+			slog.String("oci_ref", config.VEX.OCIRef))
+		transformerOptions = append(transformerOptions, scan.WithVEX(matcher))
+	}
+	if config.Tunnel.SeverityThreshold != "" {
+		threshold, err := harbor.ParseSeverity(config.Tunnel.SeverityThreshold)
+		if err != nil {
+			return fmt.Errorf("parsing severity threshold: %w", err)
+		}
+		slog.Info("Dropping vulnerabilities below severity threshold", slog.String("threshold", threshold.String()))
+		transformerOptions = append(transformerOptions, scan.WithSeverityThreshold(threshold))
+	}
+	transformer := scan.NewTransformer(clock.System, transformerOptions...)
+
+	var sboms persistence.SBOMStore
+	if config.Tunnel.SBOMEnabled {
+		sboms = redis.NewSBOMStore(config.RedisStore, rdb)
+	}
+
+	flags := feature.AllEnabled()
+	if config.Feature.FlagsFile != "" {
+		flagsConfig, err := feature.Load(config.Feature.FlagsFile)
+		if err != nil {
+			return fmt.Errorf("loading feature flags: %w", err)
+		}
+		if flags, err = feature.NewFlags(flagsConfig); err != nil {
+			return fmt.Errorf("new feature flags: %w", err)
+		}
+	}
+
+	repositories, err := harbor.NewRepositoryNormalizer(config.Tunnel.RepositoryNormalization,
+		config.Tunnel.ProxyCacheUpstreams)
+	if err != nil {
+		return fmt.Errorf("new repository normalizer: %w", err)
+	}
+
+	controllerOptions := []scan.Option{scan.WithFeatureFlags(flags), scan.WithRepositoryNormalizer(repositories),
+		scan.WithLifetimes(lifetimes)}
+	if config.Tunnel.RegistrySBOMEnabled {
+		controllerOptions = append(controllerOptions, scan.WithRegistrySBOMs(registry.NewSBOMFetcher(config.Tunnel)))
+	}
+	if config.Tunnel.CredentialsRefreshHookURL != "" {
+		controllerOptions = append(controllerOptions,
+			scan.WithCredentialsRefresher(registry.NewRefreshHook(config.Tunnel.CredentialsRefreshHookURL)))
+	}
+
+	if config.Shadow.IsEnabled() {
+		slog.Info("Comparing scans with shadow tunnel", slog.Int("percentage", config.Shadow.Percentage))
+		shadowWrapper := tunnel.NewWrapper(config.Shadow.TunnelConfig(config.Tunnel), ambassador, wrapperOptions...)
+		controllerOptions = append(controllerOptions,
+			scan.WithShadowComparator(shadow.NewComparator(config.Shadow, shadowWrapper)))
+	}
+
+	if config.NVD.Enabled {
+		controllerOptions = append(controllerOptions,
+			scan.WithEnricher(nvd.NewEnricher(nvd.NewClient(config.NVD), config.NVD.Timeout)))
+	}
+	var epssLoader epss.Loader
+	if config.EPSS.Enabled {
+		// Reports are enriched as soon as a dataset is loaded, so that an outage of the EPSS URL never blocks start.
+		epssLoader = epss.NewLoader(config.EPSS)
+		if err := epssLoader.Load(ctx); err != nil {
+			slog.Warn("Error while loading EPSS dataset, scores are added once it is refreshed",
+				slog.String("err", err.Error()))
+		}
+		controllerOptions = append(controllerOptions, scan.WithEnricher(epss.NewEnricher(epssLoader)))
+	}
+	if len(config.Enrichment.Hooks) > 0 {
+		controllerOptions = append(controllerOptions, scan.WithEnricher(enrich.NewEnricher(config.Enrichment)))
+	}
+	if config.OPA.IsEnabled() {
+		controllerOptions = append(controllerOptions, scan.WithReportPolicy(opa.NewEvaluator(config.OPA)))
+	}
+
+	var enqueuerOptions []queue.EnqueuerOption
+	if config.JobQueue.DigestReuseWindow > 0 {
+		enqueuerOptions = append(enqueuerOptions,
+			queue.WithDigestIndex(redis.NewDigestIndex(config.RedisStore, rdb, config.JobQueue.DigestReuseWindow)))
+	}
+	if config.JobQueue.FailedDigestThreshold > 0 {
+		failures := redis.NewFailureIndex(config.RedisStore, rdb, config.JobQueue.FailedDigestThreshold,
+			config.JobQueue.FailedDigestCooldown)
+		controllerOptions = append(controllerOptions, scan.WithFailureIndex(failures))
+		enqueuerOptions = append(enqueuerOptions, queue.WithFailureIndex(failures))
+	}
+
+	if config.CleanScans.WebhookURL != "" {
+		controllerOptions = append(controllerOptions,
+			scan.WithCleanScanNotifier(scan.NewCleanScanWebhook(config.CleanScans.WebhookURL)))
+	}
+	if config.Notifications.IsEnabled() {
+		minSeverity, err := harbor.ParseSeverity(config.Notifications.MinSeverity)
+		if err != nil {
+			return fmt.Errorf("notifications min severity: %w", err)
+		}
+		if config.Notifications.SlackWebhookURL != "" {
+			controllerOptions = append(controllerOptions,
+				scan.WithReportNotifier(notify.NewSlackNotifier(config.Notifications.SlackWebhookURL, minSeverity)))
+		}
+		if config.Notifications.TeamsWebhookURL != "" {
+			controllerOptions = append(controllerOptions,
+				scan.WithReportNotifier(notify.NewTeamsNotifier(config.Notifications.TeamsWebhookURL, minSeverity)))
+		}
+	}
+
+	controller := scan.NewController(store, index, sboms, wrapper, transformer, controllerOptions...)
+	var enqueuer queue.Enqueuer
+	var worker queue.Worker
+	recoverScanJobs := func(ctx context.Context) (int, error) {
+		return queue.Recover(ctx, config.JobQueue, rdb, store, clock.System)
+	}
+	if config.JobQueueDriver() == "local" {
+		slog.Info("Keeping job queue in process")
+		local := queue.NewLocal()
+		enqueuer = queue.NewLocalEnqueuer(config.JobQueue, local, store, idGenerator, enqueuerOptions...)
+		worker = queue.NewLocalWorker(config.JobQueue, local, controller)
+		recoverScanJobs = func(ctx context.Context) (int, error) {
+			return queue.RecoverLocal(ctx, local, store, clock.System)
+		}
+	} else {
+		enqueuer = queue.NewEnqueuer(config.JobQueue, rdb, store, idGenerator, enqueuerOptions...)
+		worker = queue.NewWorker(config.JobQueue, rdb, store, controller)
+	}
+	enqueuer = chaos.NewEnqueuer(enqueuer, faults)
+
+	// Readiness probes and metrics scrapes share the version of the vulnerability database, so that neither execs
+	// Tunnel each time.
+	versions := tunnel.NewVersionCache(tunnel.VersionCacheTTL)
+	prometheus.MustRegister(metrics.NewVulnDBCollector(wrapper, metrics.WithVersionCache(versions)))
+	prometheus.MustRegister(redisx.CommandDuration, httpx.RequestDuration)
+	prometheus.MustRegister(tunnel.RegistryThrottles, tunnel.RegistryConcurrencyLimit)
+	prometheus.MustRegister(metrics.RegistryUnauthorized, metrics.NVDLookups, metrics.AuditEvents)
+	prometheus.MustRegister(metrics.ShadowScans, metrics.ShadowFindings, metrics.ShadowSeverityDisagreements)
+	prometheus.MustRegister(metrics.ScanJobsEnqueued, metrics.ScanJobsStarted, metrics.ScanJobsCompleted,
+		metrics.ScanJobsFailed, metrics.ScanJobsRetried, metrics.ScanDuration, metrics.ReportTransformDuration,
+		metrics.StoreOperationDuration, metrics.CleanScans)
+	prometheus.MustRegister(metrics.NewQueueCollector(enqueuer))
+
+	authProvider, err := auth.NewProvider(ctx, config.Auth)
+	if err != nil {
+		return fmt.Errorf("new auth provider: %w", err)
+	}
+
+	apiOptions := []v1.Option{
+		v1.WithAuthProvider(authProvider),
+		v1.WithVulnerabilityIndex(index),
+		v1.WithLifetimeStore(lifetimes),
+		v1.WithExceptionStore(exceptions),
+		v1.WithVersionCache(versions),
+		v1.WithSupportBundleGenerator(support.NewGenerator(info, config, wrapper, store, enqueuer, logs)),
+	}
+	grpcOptions := []grpcapi.Option{grpcapi.WithAuthProvider(authProvider)}
+
+	if config.SLO.IsEnabled() {
+		tracker := slo.NewTracker(config.SLO, store)
+		prometheus.MustRegister(metrics.NewSLOCollector(tracker))
+		apiOptions = append(apiOptions, v1.WithSLOTracker(tracker))
+	}
+
+	if pin != nil {
+		apiOptions = append(apiOptions, v1.WithTunnelPin(pin))
+	}
+	if jobHistory != nil {
+		apiOptions = append(apiOptions, v1.WithJobHistory(jobHistory))
+	}
+	if rdb != nil {
+		apiOptions = append(apiOptions, v1.WithRedisCheck(func(ctx context.Context) error {
+			return redisx.Ping(ctx, rdb)
+		}))
+	}
+
+	signatureVerifier, err := auth.NewSignatureVerifier(config.Auth)
+	if err != nil {
+		return fmt.Errorf("new signature verifier: %w", err)
+	}
+	if signatureVerifier != nil {
+		apiOptions = append(apiOptions, v1.WithSignatureVerifier(signatureVerifier))
+		grpcOptions = append(grpcOptions, grpcapi.WithSignatureVerifier(signatureVerifier))
+	}
+
+	if config.Policy.File != "" {
+		p, err := policy.Load(config.Policy.File)
+		if err != nil {
+			return fmt.Errorf("loading policy: %w", err)
+		}
+		engine, err := policy.NewEngine(p)
+		if err != nil {
+			return fmt.Errorf("new policy engine: %w", err)
+		}
+		apiOptions = append(apiOptions, v1.WithPolicyEngine(engine))
+		grpcOptions = append(grpcOptions, grpcapi.WithPolicyEngine(engine))
+	}
+	if bundles != nil {
+		apiOptions = append(apiOptions, v1.WithPolicyEngine(bundles))
+		grpcOptions = append(grpcOptions, grpcapi.WithPolicyEngine(bundles))
+	}
+
+	watchCtx, stopWatching := context.WithCancel(ctx)
+	defer stopWatching()
+
+	if sboms != nil {
+		assessor, err := newImpactAssessor(config.Impact, flags, impact.NewSBOMSource(sboms, wrapper, transformer), index)
+		if err != nil {
+			return err
+		}
+		apiOptions = append(apiOptions, v1.WithImpactAssessor(assessor))
+		if config.Impact.OnDBUpdate {
+			go impact.WatchDBUpdates(watchCtx, assessor, wrapper, config.Impact.DBPollInterval)
+		}
+	}
+	if db != nil {
+		go postgres.Reap(watchCtx, db, config.PostgresStore)
+	}
+	if config.Store.SpillDir != "" {
+		go spill.Reap(watchCtx, config.Store, clock.System)
+	}
+	if bundles != nil {
+		go bundles.Run(watchCtx)
+	}
+	if epssLoader != nil {
+		go epssLoader.Run(watchCtx)
+	}
+	if config.Connectivity.Enabled {
+		prober := connectivity.NewProber(config)
+		apiOptions = append(apiOptions, v1.WithConnectivityProber(prober))
+		go prober.Run(watchCtx)
+	}
+	if config.CRD.Enabled {
+		client, err := crd.NewInClusterClient()
+		if err != nil {
+			return fmt.Errorf("new kubernetes client: %w", err)
+		}
+		go crd.NewController(config.CRD, client, enqueuer, store).Run(watchCtx)
+	}
+
+	apiHandler := v1.NewAPIHandler(info, config, enqueuer, store, wrapper, apiOptions...)
 	apiServer, err := api.NewServer(config.API, apiHandler)
 	if err != nil {
 		return fmt.Errorf("new api server: %w", err)
 	}
+	var grpcServer *grpcapi.Server
+	if config.GRPC.IsEnabled() {
+		if grpcServer, err = grpcapi.NewServer(config, enqueuer, store, grpcOptions...); err != nil {
+			return fmt.Errorf("new grpc server: %w", err)
+		}
+	}
 
 	shutdownComplete := make(chan struct{})
 	go func() {
@@ -82,16 +497,68 @@ func run(ctx context.Context, info etc.BuildInfo) error {
 		captured := <-sigint
 		slog.Debug("Trapped os signal", slog.String("signal", captured.String()))
 
+		stopWatching()
 		apiServer.Shutdown()
+		if grpcServer != nil {
+			grpcServer.Shutdown()
+		}
 		worker.Stop()
-		_ = rdb.Close()
+		if rdb != nil {
+			_ = rdb.Close()
+		}
+		if db != nil {
+			_ = db.Close()
+		}
 
 		close(shutdownComplete)
 	}()
 
 	worker.Start(ctx)
+	if recovered, err := recoverScanJobs(ctx); err != nil {
+		slog.Error("Error while recovering scan jobs", slog.String("err", err.Error()))
+	} else if recovered > 0 {
+		slog.Info("Recovered scan jobs", slog.Int("count", recovered))
+	}
 	apiServer.ListenAndServe()
+	if grpcServer != nil {
+		grpcServer.ListenAndServe()
+	}
 
 	<-shutdownComplete
 	return nil
 }
+
+// newPolicyBundleLoader constructs the Loader of the configured policy bundle, and applies the bundle, so that the
+// adapter never serves verdicts nor scans without a verified policy.
+func newPolicyBundleLoader(ctx context.Context, config etc.Config) (bundle.Loader, error) {
+	source, err := bundle.NewSource(config.Policy, config.Tunnel)
+	if err != nil {
+		return nil, fmt.Errorf("constructing policy bundle source: %w", err)
+	}
+	verifier, err := bundle.LoadVerifier(config.Policy.BundlePublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("constructing policy bundle verifier: %w", err)
+	}
+	loader := bundle.NewLoader(config.Policy, source, verifier)
+	if err = loader.Load(ctx); err != nil {
+		return nil, fmt.Errorf("loading policy bundle: %w", err)
+	}
+	return loader, nil
+}
+
+// newImpactAssessor constructs an Assessor, which alerts the configured webhook if any about the artifacts
+// of the projects the webhooks flag is enabled for.
+func newImpactAssessor(config etc.Impact, flags feature.Flags, source impact.Source, index persistence.VulnerabilityIndex) (impact.Assessor, error) {
+	var notifier impact.Notifier
+	if config.WebhookURL != "" {
+		minSeverity, err := harbor.ParseSeverity(config.WebhookMinSeverity)
+		if err != nil {
+			return nil, fmt.Errorf("impact webhook min severity: %w", err)
+		}
+		notifier = impact.NewFilteringNotifier(impact.NewWebhookNotifier(config.WebhookURL, minSeverity),
+			func(artifact impact.Artifact) bool {
+				return flags.Enabled(feature.Webhooks, policy.ProjectOf(artifact.Repository))
+			})
+	}
+	return impact.NewAssessor(source, index, notifier), nil
+}
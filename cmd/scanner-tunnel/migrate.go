@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence/migrate"
+)
+
+const commandMigrateStore = "migrate-store"
+
+// runMigrateStore copies all scan jobs from the configured store backend to the migration target
+// and verifies the copies. It fails if any copy differs from its source.
+func runMigrateStore(ctx context.Context) error {
+	config, err := etc.GetConfig()
+	if err != nil {
+		return fmt.Errorf("getting config: %w", err)
+	}
+	if config.Store.MigrationTarget == "" {
+		return fmt.Errorf("store migration target must not be blank")
+	}
+
+	rdb, err := openRedis(&config)
+	if err != nil {
+		return err
+	}
+	if rdb != nil {
+		defer func() { _ = rdb.Close() }()
+	}
+
+	db, err := openPostgres(ctx, config)
+	if err != nil {
+		return err
+	}
+	if db != nil {
+		defer func() { _ = db.Close() }()
+	}
+
+	source, err := newStoreBackend(config.Store.Backend, config, config.RedisStore, rdb, db)
+	if err != nil {
+		return err
+	}
+	target, err := newMigrationTarget(config, rdb, db)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Migrating scan jobs", slog.String("backend", config.Store.Backend),
+		slog.String("target", config.Store.MigrationTarget))
+
+	result, err := migrate.Migrate(ctx, source, target)
+	if err != nil {
+		return fmt.Errorf("migrating scan jobs: %w", err)
+	}
+
+	slog.Info("Migrated scan jobs", slog.Int("copied", result.Copied), slog.Int("unchanged", result.Unchanged),
+		slog.Int("mismatched", len(result.Mismatched)))
+
+	if !result.Verified() {
+		return fmt.Errorf("%d migrated scan jobs differ from source: %s", len(result.Mismatched),
+			strings.Join(result.Mismatched, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log/slog"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence/redis"
+)
+
+const commandMigrateNamespace = "migrate-namespace"
+
+// runMigrateNamespace renames, or copies, the keys of an old Redis namespace to the configured store namespace,
+// or to the given namespace, so that the scan jobs saved before changing SCANNER_STORE_REDIS_NAMESPACE remain
+// reachable.
+func runMigrateNamespace(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet(commandMigrateNamespace, flag.ContinueOnError)
+	copyKeys := flags.Bool("copy", false, "copy the keys rather than renaming them")
+	batchSize := flags.Int("batch-size", 100, "number of keys migrated at once")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		return fmt.Errorf("usage: scanner-tunnel %s [-copy] [-batch-size n] <old-namespace> [<new-namespace>]",
+			commandMigrateNamespace)
+	}
+
+	return withStoreBackend(ctx, func(config etc.Config, rdb goredis.UniversalClient, _ *sql.DB) error {
+		if rdb == nil {
+			return fmt.Errorf("%s requires Redis, which is used by neither the store nor the job queue",
+				commandMigrateNamespace)
+		}
+		migration := redis.NamespaceMigration{
+			From:      flags.Arg(0),
+			To:        config.RedisStore.Namespace,
+			Copy:      *copyKeys,
+			BatchSize: *batchSize,
+			Progress: func(result redis.NamespaceResult) {
+				slog.Info("Migrating keys", slog.Int("migrated", result.Migrated), slog.Int("skipped", result.Skipped))
+			},
+		}
+		if flags.NArg() == 2 {
+			migration.To = flags.Arg(1)
+		}
+
+		slog.Info("Migrating Redis namespace", slog.String("from", migration.From), slog.String("to", migration.To),
+			slog.Bool("copy", migration.Copy))
+
+		result, err := redis.MigrateNamespace(ctx, rdb, migration)
+		if err != nil {
+			return fmt.Errorf("migrating namespace: %w", err)
+		}
+
+		slog.Info("Migrated Redis namespace", slog.String("from", migration.From), slog.String("to", migration.To),
+			slog.Int("migrated", result.Migrated), slog.Int("skipped", result.Skipped))
+		return nil
+	})
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence/memory"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence/migrate"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence/postgres"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence/redis"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence/sealed"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence/spill"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/redisx"
+)
+
+const (
+	storeBackendRedis    = "redis"
+	storeBackendPostgres = "postgres"
+	storeBackendMemory   = "memory"
+)
+
+// postgresMigrationTimeout bounds connecting to the PostgreSQL database and migrating its schema.
+const postgresMigrationTimeout = time.Minute
+
+// newStore constructs the configured store, which mirrors writes to the migration target in dual-write mode,
+// spills the vulnerabilities of large reports to disk if a spill dir is configured, and encrypts the registry
+// credentials of scan requests with the credentials key, or keeps them out of the store without one.
+func newStore(config etc.Config, rdb goredis.UniversalClient, db *sql.DB) (persistence.Store, error) {
+	store, err := newStoreBackend(config.Store.Backend, config, config.RedisStore, rdb, db)
+	if err != nil {
+		return nil, err
+	}
+	if config.Store.MigrationDualWrite {
+		target, err := newMigrationTarget(config, rdb, db)
+		if err != nil {
+			return nil, err
+		}
+		slog.Info("Mirroring scan jobs to store migration target", slog.String("backend", config.Store.Backend),
+			slog.String("target", config.Store.MigrationTarget))
+		store = migrate.NewDualWriteStore(store, target)
+	}
+	if config.Store.SpillDir != "" {
+		slog.Info("Spilling vulnerabilities of large reports to disk", slog.String("dir", config.Store.SpillDir),
+			slog.Int("threshold", config.Store.SpillThreshold))
+		store = spill.NewStore(store, config.Store)
+	}
+	key, err := config.Store.DecodeCredentialsKey()
+	if err != nil {
+		return nil, fmt.Errorf("decoding store credentials key: %w", err)
+	}
+	return sealed.NewStore(store, key)
+}
+
+// newMigrationTarget constructs the store scan jobs are migrated to.
+func newMigrationTarget(config etc.Config, rdb goredis.UniversalClient, db *sql.DB) (persistence.Store, error) {
+	redisConfig := config.RedisStore
+	redisConfig.Namespace = config.Store.MigrationRedisNamespace
+	if config.Store.MigrationTarget == storeBackendRedis && (redisConfig.Namespace == "" || redisConfig.Namespace == config.RedisStore.Namespace) {
+		return nil, fmt.Errorf("store migration to redis requires a different namespace")
+	}
+	return newStoreBackend(config.Store.MigrationTarget, config, redisConfig, rdb, db)
+}
+
+func newStoreBackend(backend string, config etc.Config, redisConfig etc.RedisStore, rdb goredis.UniversalClient, db *sql.DB) (persistence.Store, error) {
+	switch backend {
+	case storeBackendRedis:
+		return redis.NewStore(redisConfig, rdb), nil
+	case storeBackendPostgres:
+		if db == nil {
+			return nil, fmt.Errorf("postgres store backend is not connected")
+		}
+		return postgres.NewStore(config.PostgresStore, db), nil
+	case storeBackendMemory:
+		return memory.NewStore(config.MemoryStore), nil
+	}
+	return nil, fmt.Errorf("unsupported store backend: %s", backend)
+}
+
+// openRedis constructs the connection pool to Redis if scan jobs or the job queue are kept in Redis, and returns nil
+// otherwise. The namespaces of a Redis Cluster are wrapped in hash tags, so that the keys of each namespace, which
+// are read and written together, are kept on one slot.
+func openRedis(config *etc.Config) (goredis.UniversalClient, error) {
+	if !config.RequiresRedis() {
+		return nil, nil
+	}
+
+	rdb, err := redisx.NewClient(config.RedisPool)
+	if err != nil {
+		return nil, fmt.Errorf("constructing connection pool: %w", err)
+	}
+	if !redisx.IsCluster(rdb) {
+		return rdb, nil
+	}
+
+	config.RedisStore.Namespace = redisx.HashTag(config.RedisStore.Namespace)
+	config.JobQueue.Namespace = redisx.HashTag(config.JobQueue.Namespace)
+	if config.Store.MigrationRedisNamespace != "" {
+		config.Store.MigrationRedisNamespace = redisx.HashTag(config.Store.MigrationRedisNamespace)
+	}
+	slog.Info("Connected to Redis Cluster", slog.String("store_namespace", config.RedisStore.Namespace),
+		slog.String("job_queue_namespace", config.JobQueue.Namespace))
+	return rdb, nil
+}
+
+// openPostgres connects to the PostgreSQL database and migrates its schema if it is the configured store backend
+// or migration target, and returns nil otherwise.
+func openPostgres(ctx context.Context, config etc.Config) (*sql.DB, error) {
+	if config.Store.Backend != storeBackendPostgres && config.Store.MigrationTarget != storeBackendPostgres {
+		return nil, nil
+	}
+
+	db, err := sql.Open("postgres", config.PostgresStore.URL)
+	if err != nil {
+		return nil, fmt.Errorf("opening postgres database: %w", err)
+	}
+	db.SetMaxOpenConns(config.PostgresStore.MaxOpenConns)
+
+	ctx, cancel := context.WithTimeout(ctx, postgresMigrationTimeout)
+	defer cancel()
+	if err = postgres.Migrate(ctx, db); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("migrating postgres schema: %w", err)
+	}
+	return db, nil
+}
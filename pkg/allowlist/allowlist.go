@@ -0,0 +1,157 @@
+// Package allowlist loads the CVE allowlist of the adapter, whose vulnerabilities are dropped from, or marked in,
+// the reports sent to Harbor until their entries expire.
+package allowlist
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/clock"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+)
+
+// Modes of applying the allowlist to reports.
+const (
+	// ModeDrop removes allowlisted vulnerabilities from reports.
+	ModeDrop = "drop"
+	// ModeMark keeps allowlisted vulnerabilities in reports, marked with the allowlisted vendor attribute.
+	ModeMark = "mark"
+)
+
+// expiryPrefix prefixes the expiry dates of entries, as in the .tunnelignore files read by Tunnel.
+const expiryPrefix = "exp:"
+
+// Entry allowlists the vulnerability with the given ID until the start of the day it expires on, in UTC, or
+// forever if Expires is zero.
+type Entry struct {
+	ID      string
+	Expires time.Time
+}
+
+// Expired returns true if the entry does not apply at the given time anymore.
+func (e Entry) Expired(at time.Time) bool {
+	return !e.Expires.IsZero() && !at.Before(e.Expires)
+}
+
+// ParseEntry parses an entry from a vulnerability ID, optionally followed by the date it expires on, e.g.
+// CVE-2023-1234 exp:2025-06-30.
+func ParseEntry(s string) (Entry, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 2 {
+		return Entry{}, fmt.Errorf("invalid allowlist entry: %q", s)
+	}
+	entry := Entry{ID: fields[0]}
+	if len(fields) == 2 {
+		date, ok := strings.CutPrefix(fields[1], expiryPrefix)
+		if !ok {
+			return Entry{}, fmt.Errorf("invalid allowlist entry: %q", s)
+		}
+		expires, err := time.Parse(time.DateOnly, date)
+		if err != nil {
+			return Entry{}, fmt.Errorf("invalid expiry date of allowlist entry %s: %s", entry.ID, date)
+		}
+		entry.Expires = expires
+	}
+	return entry, nil
+}
+
+// Parse parses the entries of a .tunnelignore style file, which lists an entry per line. Blank lines and comments
+// starting with # are skipped.
+func Parse(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		entry, err := ParseEntry(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Allowlist wraps the Lookup method.
+// Lookup returns the entry allowlisting the vulnerability with the given ID at the given time, and false if the
+// vulnerability is not allowlisted, or its entry expired.
+type Allowlist interface {
+	Lookup(id string, at time.Time) (Entry, bool)
+}
+
+type allowlist struct {
+	// entries are indexed by upper case ID, as IDs are matched case-insensitively.
+	entries map[string]Entry
+}
+
+// New constructs an Allowlist of the given entries. If several entries allowlist the same vulnerability, the one
+// expiring last applies.
+func New(entries []Entry) Allowlist {
+	a := &allowlist{entries: make(map[string]Entry, len(entries))}
+	for _, entry := range entries {
+		key := strings.ToUpper(entry.ID)
+		if existing, ok := a.entries[key]; ok && outlasts(existing, entry) {
+			continue
+		}
+		a.entries[key] = entry
+	}
+	return a
+}
+
+// Load constructs the Allowlist of the configured CVEs and file. The entries expired at the time of the given
+// Clock are reported.
+func Load(config etc.Allowlist, c clock.Clock) (Allowlist, error) {
+	entries := make([]Entry, 0, len(config.CVEs))
+	for _, cve := range config.CVEs {
+		entry, err := ParseEntry(cve)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if config.File != "" {
+		f, err := os.Open(config.File)
+		if err != nil {
+			return nil, fmt.Errorf("reading allowlist: %w", err)
+		}
+		defer f.Close()
+		fileEntries, err := Parse(f)
+		if err != nil {
+			return nil, fmt.Errorf("parsing allowlist %s: %w", config.File, err)
+		}
+		entries = append(entries, fileEntries...)
+	}
+	for _, entry := range entries {
+		if entry.Expired(c.Now()) {
+			slog.Warn("Allowlist entry expired", slog.String("id", entry.ID),
+				slog.String("expires", entry.Expires.Format(time.DateOnly)))
+		}
+	}
+	return New(entries), nil
+}
+
+func (a *allowlist) Lookup(id string, at time.Time) (Entry, bool) {
+	entry, ok := a.entries[strings.ToUpper(id)]
+	if !ok || entry.Expired(at) {
+		return Entry{}, false
+	}
+	return entry, true
+}
+
+// outlasts returns true if the entry a expires after the entry b, or never.
+func outlasts(a, b Entry) bool {
+	if a.Expires.IsZero() {
+		return true
+	}
+	return !b.Expires.IsZero() && a.Expires.After(b.Expires)
+}
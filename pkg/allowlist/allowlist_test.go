@@ -0,0 +1,171 @@
+package allowlist
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/clock"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+)
+
+func TestParseEntry(t *testing.T) {
+	testCases := []struct {
+		name          string
+		entry         string
+		expectedEntry Entry
+		expectedError string
+	}{
+		{
+			name:          "Should parse entry without expiry date",
+			entry:         "CVE-2023-1234",
+			expectedEntry: Entry{ID: "CVE-2023-1234"},
+		},
+		{
+			name:          "Should parse entry with expiry date",
+			entry:         " GHSA-xvch-5gv4-984h   exp:2025-06-30 ",
+			expectedEntry: Entry{ID: "GHSA-xvch-5gv4-984h", Expires: time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:          "Should return error when expiry date is malformed",
+			entry:         "CVE-2023-1234 exp:30/06/2025",
+			expectedError: "invalid expiry date of allowlist entry CVE-2023-1234: 30/06/2025",
+		},
+		{
+			name:          "Should return error when entry has unknown field",
+			entry:         "CVE-2023-1234 openssl",
+			expectedError: `invalid allowlist entry: "CVE-2023-1234 openssl"`,
+		},
+		{
+			name:          "Should return error when entry is blank",
+			entry:         " ",
+			expectedError: `invalid allowlist entry: " "`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			entry, err := ParseEntry(tc.entry)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedEntry, entry)
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Run("Should skip blank lines and comments", func(t *testing.T) {
+		entries, err := Parse(strings.NewReader(`# Accepted until the base image is updated
+CVE-2023-1234 exp:2025-06-30
+
+CVE-2023-5678 # not exploitable, see SEC-42
+`))
+		require.NoError(t, err)
+		assert.Equal(t, []Entry{
+			{ID: "CVE-2023-1234", Expires: time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC)},
+			{ID: "CVE-2023-5678"},
+		}, entries)
+	})
+
+	t.Run("Should return error with line of invalid entry", func(t *testing.T) {
+		_, err := Parse(strings.NewReader("CVE-2023-1234\n\nCVE-2023-5678 exp:tomorrow\n"))
+		assert.EqualError(t, err, "line 3: invalid expiry date of allowlist entry CVE-2023-5678: tomorrow")
+	})
+}
+
+func TestAllowlist_Lookup(t *testing.T) {
+	expires := time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC)
+	list := New([]Entry{
+		{ID: "CVE-2023-1234", Expires: expires},
+		{ID: "CVE-2023-5678", Expires: time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)},
+		{ID: "CVE-2023-5678"},
+		{ID: "CVE-2023-9012", Expires: expires},
+		{ID: "CVE-2023-9012", Expires: time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)},
+	})
+
+	testCases := []struct {
+		name          string
+		id            string
+		at            time.Time
+		expectedEntry Entry
+		expectedOK    bool
+	}{
+		{
+			name:          "Should match entry before it expires",
+			id:            "CVE-2023-1234",
+			at:            expires.Add(-time.Second),
+			expectedEntry: Entry{ID: "CVE-2023-1234", Expires: expires},
+			expectedOK:    true,
+		},
+		{
+			name:          "Should match entry case-insensitively",
+			id:            "cve-2023-1234",
+			at:            expires.Add(-time.Second),
+			expectedEntry: Entry{ID: "CVE-2023-1234", Expires: expires},
+			expectedOK:    true,
+		},
+		{
+			name: "Should not match expired entry",
+			id:   "CVE-2023-1234",
+			at:   expires,
+		},
+		{
+			name:          "Should match entry which never expires over entry which expires",
+			id:            "CVE-2023-5678",
+			at:            expires,
+			expectedEntry: Entry{ID: "CVE-2023-5678"},
+			expectedOK:    true,
+		},
+		{
+			name:          "Should match entry which expires last",
+			id:            "CVE-2023-9012",
+			at:            time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+			expectedEntry: Entry{ID: "CVE-2023-9012", Expires: expires},
+			expectedOK:    true,
+		},
+		{
+			name: "Should not match vulnerability which is not allowlisted",
+			id:   "CVE-2024-0001",
+			at:   time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			entry, ok := list.Lookup(tc.id, tc.at)
+			assert.Equal(t, tc.expectedOK, ok)
+			assert.Equal(t, tc.expectedEntry, entry)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), ".tunnelignore")
+	require.NoError(t, os.WriteFile(file, []byte("CVE-2023-5678\n"), 0600))
+
+	t.Run("Should load configured CVEs and file", func(t *testing.T) {
+		list, err := Load(etc.Allowlist{CVEs: []string{"CVE-2023-1234 exp:2099-12-31"}, File: file}, clock.System)
+		require.NoError(t, err)
+
+		_, ok := list.Lookup("CVE-2023-1234", time.Now())
+		assert.True(t, ok)
+		_, ok = list.Lookup("CVE-2023-5678", time.Now())
+		assert.True(t, ok)
+	})
+
+	t.Run("Should return error when configured CVE is invalid", func(t *testing.T) {
+		_, err := Load(etc.Allowlist{CVEs: []string{"CVE-2023-1234 until:2099-12-31"}}, clock.System)
+		assert.EqualError(t, err, `invalid allowlist entry: "CVE-2023-1234 until:2099-12-31"`)
+	})
+
+	t.Run("Should return error when file does not exist", func(t *testing.T) {
+		_, err := Load(etc.Allowlist{File: "/does/not/exist/.tunnelignore"}, clock.System)
+		assert.EqualError(t, err, "reading allowlist: open /does/not/exist/.tunnelignore: no such file or directory")
+	})
+}
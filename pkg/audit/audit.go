@@ -0,0 +1,169 @@
+// Package audit exports the lifecycle events of scan jobs and the summaries of their reports to a sink, e.g.
+// Kafka or S3, for long-term analytics. Scan jobs expire from the store after their TTL, while the exported
+// events are kept as long as the sink retains them.
+//
+// Events are exported asynchronously and in batches, so that a slow or unavailable sink never slows down or
+// fails scans. Exporting is best effort: events are dropped when the buffer is full or the sink fails.
+package audit
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/metrics"
+)
+
+// Types of the events.
+const (
+	EventScanJobQueued   = "scan_job.queued"
+	EventScanJobStarted  = "scan_job.started"
+	EventScanJobFinished = "scan_job.finished"
+	EventScanJobFailed   = "scan_job.failed"
+	// EventScanReportSaved carries the summary of the report of a scan job, which precedes its finished event.
+	EventScanReportSaved = "scan_report.saved"
+)
+
+// writeTimeout bounds the export of a batch to the sink.
+const writeTimeout = 30 * time.Second
+
+// Event is a lifecycle event of a scan job. The registry credentials of the scan request are never exported.
+type Event struct {
+	Type      string    `json:"type"`
+	Time      time.Time `json:"time"`
+	ScanJobID string    `json:"scan_job_id"`
+	Error     string    `json:"error,omitempty"`
+	// Registry and Artifact are only set on queued events and report summaries.
+	Registry string           `json:"registry,omitempty"`
+	Artifact *harbor.Artifact `json:"artifact,omitempty"`
+	Report   *ReportSummary   `json:"report,omitempty"`
+}
+
+// ReportSummary is the summary of a scan report.
+type ReportSummary struct {
+	GeneratedAt time.Time       `json:"generated_at"`
+	Scanner     harbor.Scanner  `json:"scanner"`
+	Severity    harbor.Severity `json:"severity"`
+	// Counts are the numbers of reported vulnerabilities keyed by severity name.
+	Counts map[string]int `json:"counts"`
+}
+
+// Summarize returns the summary of the given report.
+func Summarize(report harbor.ScanReport) *ReportSummary {
+	summary := &ReportSummary{
+		GeneratedAt: report.GeneratedAt,
+		Scanner:     report.Scanner,
+		Severity:    report.Severity,
+		Counts:      make(map[string]int),
+	}
+	for _, v := range report.Vulnerabilities {
+		summary.Counts[v.Severity.String()]++
+	}
+	return summary
+}
+
+// Sink wraps the Write method.
+// Write exports the given batch of events.
+type Sink interface {
+	Write(ctx context.Context, events []Event) error
+}
+
+// Exporter exports events to a Sink asynchronously.
+type Exporter interface {
+	// Export queues the given event for export without blocking. The event is dropped if the buffer is full.
+	Export(event Event)
+	// Close exports the queued events and stops the Exporter.
+	Close()
+}
+
+type exporter struct {
+	sink          Sink
+	batchSize     int
+	flushInterval time.Duration
+
+	mu      sync.RWMutex
+	closed  bool
+	events  chan Event
+	stopped chan struct{}
+}
+
+// NewExporter constructs an Exporter, which exports events to the given Sink in batches of the configured size,
+// or of the events queued within the configured flush interval.
+func NewExporter(config etc.Audit, sink Sink) Exporter {
+	e := &exporter{
+		sink:          sink,
+		batchSize:     max(config.BatchSize, 1),
+		flushInterval: config.FlushInterval,
+		events:        make(chan Event, config.BufferSize),
+		stopped:       make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+func (e *exporter) Export(event Event) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.closed {
+		metrics.AuditEvents.WithLabelValues("dropped").Inc()
+		return
+	}
+	select {
+	case e.events <- event:
+	default:
+		metrics.AuditEvents.WithLabelValues("dropped").Inc()
+	}
+}
+
+func (e *exporter) Close() {
+	e.mu.Lock()
+	if !e.closed {
+		e.closed = true
+		close(e.events)
+	}
+	e.mu.Unlock()
+	<-e.stopped
+}
+
+func (e *exporter) run() {
+	defer close(e.stopped)
+	ticker := time.NewTicker(e.flushInterval)
+	defer ticker.Stop()
+
+	var batch []Event
+	for {
+		select {
+		case event, ok := <-e.events:
+			if !ok {
+				e.flush(batch)
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) >= e.batchSize {
+				e.flush(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			e.flush(batch)
+			batch = nil
+		}
+	}
+}
+
+func (e *exporter) flush(batch []Event) {
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+	if err := e.sink.Write(ctx, batch); err != nil {
+		slog.Warn("Error while exporting audit events", slog.Int("count", len(batch)),
+			slog.String("err", err.Error()))
+		metrics.AuditEvents.WithLabelValues("failed").Add(float64(len(batch)))
+		return
+	}
+	metrics.AuditEvents.WithLabelValues("exported").Add(float64(len(batch)))
+}
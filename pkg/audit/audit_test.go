@@ -0,0 +1,105 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+)
+
+// fakeSink records the batches of events written to it.
+type fakeSink struct {
+	mu      sync.Mutex
+	batches [][]Event
+	err     error
+}
+
+func (s *fakeSink) Write(_ context.Context, events []Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, events)
+	return s.err
+}
+
+func (s *fakeSink) Batches() [][]Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.batches
+}
+
+func TestExporter(t *testing.T) {
+	events := []Event{
+		{Type: EventScanJobQueued, ScanJobID: "job:1"},
+		{Type: EventScanJobQueued, ScanJobID: "job:2"},
+		{Type: EventScanJobStarted, ScanJobID: "job:1"},
+	}
+
+	t.Run("Should export events in batches and flush remaining events on close", func(t *testing.T) {
+		sink := &fakeSink{}
+		exporter := NewExporter(etc.Audit{BufferSize: 10, BatchSize: 2, FlushInterval: time.Hour}, sink)
+		for _, event := range events {
+			exporter.Export(event)
+		}
+		exporter.Close()
+
+		assert.Equal(t, [][]Event{events[:2], events[2:]}, sink.Batches())
+	})
+
+	t.Run("Should flush events periodically", func(t *testing.T) {
+		sink := &fakeSink{}
+		exporter := NewExporter(etc.Audit{BufferSize: 10, BatchSize: 100, FlushInterval: 10 * time.Millisecond}, sink)
+		defer exporter.Close()
+		exporter.Export(events[0])
+
+		assert.Eventually(t, func() bool {
+			return len(sink.Batches()) == 1
+		}, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("Should keep exporting when sink fails", func(t *testing.T) {
+		sink := &fakeSink{err: errors.New("unexpected status 503")}
+		exporter := NewExporter(etc.Audit{BufferSize: 10, BatchSize: 1, FlushInterval: time.Hour}, sink)
+		for _, event := range events {
+			exporter.Export(event)
+		}
+		exporter.Close()
+
+		assert.Len(t, sink.Batches(), 3)
+	})
+
+	t.Run("Should drop events exported after close", func(t *testing.T) {
+		sink := &fakeSink{}
+		exporter := NewExporter(etc.Audit{BufferSize: 10, BatchSize: 1, FlushInterval: time.Hour}, sink)
+		exporter.Close()
+		exporter.Export(events[0])
+
+		assert.Empty(t, sink.Batches())
+	})
+}
+
+func TestSummarize(t *testing.T) {
+	generatedAt := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	summary := Summarize(harbor.ScanReport{
+		GeneratedAt: generatedAt,
+		Scanner:     harbor.Scanner{Name: "Tunnel", Vendor: "Khulnasoft Security", Version: "0.50.1"},
+		Severity:    harbor.SevCritical,
+		Vulnerabilities: []harbor.VulnerabilityItem{
+			{ID: "CVE-2024-3094", Severity: harbor.SevCritical},
+			{ID: "CVE-2023-44487", Severity: harbor.SevHigh},
+			{ID: "CVE-2023-4911", Severity: harbor.SevHigh},
+		},
+	})
+
+	assert.Equal(t, &ReportSummary{
+		GeneratedAt: generatedAt,
+		Scanner:     harbor.Scanner{Name: "Tunnel", Vendor: "Khulnasoft Security", Version: "0.50.1"},
+		Severity:    harbor.SevCritical,
+		Counts:      map[string]int{"Critical": 1, "High": 2},
+	}, summary)
+}
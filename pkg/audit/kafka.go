@@ -0,0 +1,58 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// contentTypeKafkaJSON is the content type of JSON records produced through the v2 API of the Kafka REST Proxy.
+const contentTypeKafkaJSON = "application/vnd.kafka.json.v2+json"
+
+type kafkaRecord struct {
+	Key   string `json:"key"`
+	Value Event  `json:"value"`
+}
+
+type kafkaSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewKafkaSink constructs a Sink, which produces events to the given topic through the Kafka REST Proxy at the
+// given URL. Events are keyed by scan job, so that the events of a scan job are kept in order.
+func NewKafkaSink(restURL, topic string, client *http.Client) Sink {
+	return &kafkaSink{
+		url:    strings.TrimSuffix(restURL, "/") + "/topics/" + url.PathEscape(topic),
+		client: client,
+	}
+}
+
+func (s *kafkaSink) Write(ctx context.Context, events []Event) error {
+	records := make([]kafkaRecord, len(events))
+	for i, event := range events {
+		records[i] = kafkaRecord{Key: event.ScanJobID, Value: event}
+	}
+	body, err := json.Marshal(map[string][]kafkaRecord{"records": records})
+	if err != nil {
+		return fmt.Errorf("marshalling records: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentTypeKafkaJSON)
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("producing records: %w", err)
+	}
+	defer res.Body.Close()
+	return checkStatus(res, "producing records")
+}
@@ -0,0 +1,193 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+)
+
+const (
+	sigV4Algorithm = "AWS4-HMAC-SHA256"
+	sigV4Service   = "s3"
+	amzDateFormat  = "20060102T150405Z"
+)
+
+type s3Sink struct {
+	endpoint        string
+	region          string
+	bucket          string
+	prefix          string
+	accessKeyID     string
+	secretAccessKey string
+	client          *http.Client
+	now             func() time.Time
+
+	// hostname and sequence make the object keys written by the replicas unique.
+	hostname string
+	sequence atomic.Uint64
+}
+
+// NewS3Sink constructs a Sink, which writes each batch of events to the configured S3 bucket as JSON Lines
+// objects, one per day the events occurred on. Objects are keyed <prefix>/date=<YYYY-MM-DD>/<host>-<time>-<n>.jsonl,
+// which query engines such as Athena read as date partitions. Objects are addressed in the path style, which
+// S3 compatible object storages support too.
+func NewS3Sink(config etc.Audit, client *http.Client) Sink {
+	endpoint := config.S3Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", config.S3Region)
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "scanner"
+	}
+	return &s3Sink{
+		endpoint:        strings.TrimSuffix(endpoint, "/"),
+		region:          config.S3Region,
+		bucket:          config.S3Bucket,
+		prefix:          strings.Trim(config.S3Prefix, "/"),
+		accessKeyID:     config.S3AccessKeyID,
+		secretAccessKey: config.S3SecretAccessKey,
+		client:          client,
+		now:             time.Now,
+		hostname:        hostname,
+	}
+}
+
+func (s *s3Sink) Write(ctx context.Context, events []Event) error {
+	partitions := make(map[string]*bytes.Buffer)
+	for _, event := range events {
+		date := partition(event.Time)
+		if partitions[date] == nil {
+			partitions[date] = &bytes.Buffer{}
+		}
+		if err := json.NewEncoder(partitions[date]).Encode(event); err != nil {
+			return fmt.Errorf("marshalling event: %w", err)
+		}
+	}
+
+	dates := make([]string, 0, len(partitions))
+	for date := range partitions {
+		dates = append(dates, date)
+	}
+	slices.Sort(dates)
+
+	for _, date := range dates {
+		if err := s.put(ctx, s.key(date), partitions[date].Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *s3Sink) key(date string) string {
+	name := fmt.Sprintf("date=%s/%s-%d-%d.jsonl", date, s.hostname, s.now().UnixNano(), s.sequence.Add(1))
+	if s.prefix == "" {
+		return name
+	}
+	return s.prefix + "/" + name
+}
+
+func (s *s3Sink) put(ctx context.Context, key string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut,
+		s.endpoint+"/"+uriEncode(s.bucket)+"/"+uriEncode(key), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	s.sign(req, body, s.now())
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("putting object %s: %w", key, err)
+	}
+	defer res.Body.Close()
+	return checkStatus(res, "putting object "+key)
+}
+
+// sign signs the given request with the AWS Signature Version 4, covering the host and all the headers set.
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html.
+func (s *s3Sink) sign(req *http.Request, body []byte, t time.Time) {
+	t = t.UTC()
+	payloadHash := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Date", t.Format(amzDateFormat))
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	date := t.Format("20060102")
+	scope := date + "/" + s.region + "/" + sigV4Service + "/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		t.Format(amzDateFormat),
+		scope,
+		hex.EncodeToString(canonicalHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretAccessKey), date)
+	for _, part := range []string{s.region, sigV4Service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, s.accessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// uriEncode percent-encodes all the bytes of the given path but slashes and the unreserved characters of
+// RFC 3986, as required by the Signature Version 4.
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,41 @@
+package audit
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/httpx"
+)
+
+// Names of the sinks.
+const (
+	SinkKafka = "kafka"
+	SinkS3    = "s3"
+)
+
+// NewSink constructs the configured Sink.
+func NewSink(config etc.Audit) (Sink, error) {
+	client := httpx.Client("audit", writeTimeout)
+	switch config.Sink {
+	case SinkKafka:
+		return NewKafkaSink(config.KafkaRESTURL, config.KafkaTopic, client), nil
+	case SinkS3:
+		return NewS3Sink(config, client), nil
+	}
+	return nil, fmt.Errorf("unsupported audit sink: %s", config.Sink)
+}
+
+// checkStatus returns an error if the given response is not successful.
+func checkStatus(res *http.Response, action string) error {
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("%s: unexpected status %d", action, res.StatusCode)
+	}
+	return nil
+}
+
+// partition returns the date partition of the given time, in UTC.
+func partition(t time.Time) string {
+	return t.UTC().Format(time.DateOnly)
+}
@@ -0,0 +1,80 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+)
+
+type store struct {
+	persistence.Store
+	exporter Exporter
+	now      func() time.Time
+}
+
+// NewStore constructs a Store, which delegates to the given Store and exports an event for each successful
+// write of a scan job, i.e. its creation, the updates of its status and the update of its report.
+func NewStore(s persistence.Store, exporter Exporter) persistence.Store {
+	return &store{
+		Store:    s,
+		exporter: exporter,
+		now:      time.Now,
+	}
+}
+
+func (s *store) Create(ctx context.Context, scanJob job.ScanJob) error {
+	if err := s.Store.Create(ctx, scanJob); err != nil {
+		return err
+	}
+	event := Event{Type: EventScanJobQueued, Time: s.now(), ScanJobID: scanJob.ID}
+	if scanJob.Request != nil {
+		event.Registry = scanJob.Request.Registry.URL
+		event.Artifact = &harbor.Artifact{
+			Repository: scanJob.Request.Artifact.Repository,
+			Digest:     scanJob.Request.Artifact.Digest,
+			MimeType:   scanJob.Request.Artifact.MimeType,
+		}
+	}
+	s.exporter.Export(event)
+	return nil
+}
+
+func (s *store) UpdateStatus(ctx context.Context, scanJobID string, newStatus job.ScanJobStatus, error ...string) error {
+	if err := s.Store.UpdateStatus(ctx, scanJobID, newStatus, error...); err != nil {
+		return err
+	}
+	event := Event{Time: s.now(), ScanJobID: scanJobID}
+	switch newStatus {
+	case job.Pending:
+		event.Type = EventScanJobStarted
+	case job.Finished:
+		event.Type = EventScanJobFinished
+	case job.Failed:
+		event.Type = EventScanJobFailed
+		if len(error) > 0 {
+			event.Error = error[0]
+		}
+	default:
+		return nil
+	}
+	s.exporter.Export(event)
+	return nil
+}
+
+func (s *store) UpdateReport(ctx context.Context, scanJobID string, report harbor.ScanReport) error {
+	if err := s.Store.UpdateReport(ctx, scanJobID, report); err != nil {
+		return err
+	}
+	artifact := report.Artifact
+	s.exporter.Export(Event{
+		Type:      EventScanReportSaved,
+		Time:      s.now(),
+		ScanJobID: scanJobID,
+		Artifact:  &artifact,
+		Report:    Summarize(report),
+	})
+	return nil
+}
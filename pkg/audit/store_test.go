@@ -0,0 +1,76 @@
+package audit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/mock"
+)
+
+// recordingExporter records the exported events.
+type recordingExporter struct {
+	events []Event
+}
+
+func (e *recordingExporter) Export(event Event) {
+	e.events = append(e.events, event)
+}
+
+func (e *recordingExporter) Close() {
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	artifact := harbor.Artifact{
+		Repository: "library/mongo",
+		Digest:     "sha256:917f5b7f4bef1b35ee90f03033f33a81002511c1e0767fd44276d4bd9cd2fa8e",
+	}
+	request := harbor.ScanRequest{
+		Registry: harbor.Registry{URL: "https://core.harbor.domain", Authorization: "Bearer: SECRET"},
+		Artifact: artifact,
+	}
+	report := harbor.ScanReport{
+		Artifact:        artifact,
+		Severity:        harbor.SevHigh,
+		Vulnerabilities: []harbor.VulnerabilityItem{{ID: "CVE-2023-44487", Severity: harbor.SevHigh}},
+	}
+
+	backend := mock.NewStore()
+	backend.On("Create", ctx, job.ScanJob{ID: "job:1", Status: job.Queued, Request: &request}).Return(nil)
+	backend.On("UpdateStatus", ctx, "job:1", job.Pending, []string(nil)).Return(nil)
+	backend.On("UpdateReport", ctx, "job:1", report).Return(nil)
+	backend.On("UpdateStatus", ctx, "job:1", job.Finished, []string(nil)).Return(nil)
+	backend.On("UpdateStatus", ctx, "job:2", job.Failed, []string{"pulling image: timeout"}).Return(nil)
+	backend.On("UpdateStatus", ctx, "job:3", job.Pending, []string(nil)).Return(xerrors.New("connection refused"))
+
+	exporter := &recordingExporter{}
+	s := NewStore(backend, exporter)
+	s.(*store).now = func() time.Time { return now }
+
+	require.NoError(t, s.Create(ctx, job.ScanJob{ID: "job:1", Status: job.Queued, Request: &request}))
+	require.NoError(t, s.UpdateStatus(ctx, "job:1", job.Pending))
+	require.NoError(t, s.UpdateReport(ctx, "job:1", report))
+	require.NoError(t, s.UpdateStatus(ctx, "job:1", job.Finished))
+	require.NoError(t, s.UpdateStatus(ctx, "job:2", job.Failed, "pulling image: timeout"))
+	assert.EqualError(t, s.UpdateStatus(ctx, "job:3", job.Pending), "connection refused")
+
+	assert.Equal(t, []Event{
+		{Type: EventScanJobQueued, Time: now, ScanJobID: "job:1", Registry: "https://core.harbor.domain", Artifact: &artifact},
+		{Type: EventScanJobStarted, Time: now, ScanJobID: "job:1"},
+		{Type: EventScanReportSaved, Time: now, ScanJobID: "job:1", Artifact: &artifact, Report: &ReportSummary{
+			Severity: harbor.SevHigh,
+			Counts:   map[string]int{"High": 1},
+		}},
+		{Type: EventScanJobFinished, Time: now, ScanJobID: "job:1"},
+		{Type: EventScanJobFailed, Time: now, ScanJobID: "job:2", Error: "pulling image: timeout"},
+	}, exporter.events)
+	backend.AssertExpectations(t)
+}
@@ -0,0 +1,84 @@
+// Package chaos provides fault-injection wrappers around the store, the queue, and the scanner,
+// which are used to validate retry and recovery behaviour deterministically.
+//
+// Faults are only injected in binaries built with the `chaos` build tag, see cmd/scanner-tunnel.
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/caarlos0/env/v6"
+)
+
+// ErrInjected is the error returned by operations failed on purpose.
+var ErrInjected = errors.New("chaos: injected fault")
+
+// Fault describes the faults injected into each operation of a backend.
+type Fault struct {
+	// Latency is added before each operation.
+	Latency time.Duration `env:"LATENCY"`
+	// ErrorRate is the probability, between 0 and 1, of an operation failing with ErrInjected.
+	ErrorRate float64 `env:"ERROR_RATE"`
+}
+
+type Config struct {
+	Seed    int64 `env:"SCANNER_CHAOS_SEED" envDefault:"1"`
+	Store   Fault `envPrefix:"SCANNER_CHAOS_STORE_"`
+	Queue   Fault `envPrefix:"SCANNER_CHAOS_QUEUE_"`
+	Scanner Fault `envPrefix:"SCANNER_CHAOS_SCANNER_"`
+}
+
+// GetConfig parses the fault-injection configuration from environment variables, e.g.
+// SCANNER_CHAOS_STORE_LATENCY=200ms or SCANNER_CHAOS_SCANNER_ERROR_RATE=0.1.
+func GetConfig() (Config, error) {
+	var cfg Config
+	err := env.Parse(&cfg)
+	return cfg, err
+}
+
+// Injector decides which operations are delayed or failed. The same seed always produces the
+// same sequence of faults for the same sequence of operations.
+type Injector struct {
+	config Config
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// NewInjector constructs an Injector with the given configuration.
+func NewInjector(config Config) *Injector {
+	return &Injector{
+		config: config,
+		rand:   rand.New(rand.NewSource(config.Seed)),
+	}
+}
+
+// inject delays the named operation by the configured latency and then fails it with
+// the configured probability.
+func (i *Injector) inject(ctx context.Context, fault Fault, operation string) error {
+	if fault.Latency > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(fault.Latency):
+		}
+	}
+
+	if fault.ErrorRate <= 0 {
+		return nil
+	}
+
+	i.mu.Lock()
+	roll := i.rand.Float64()
+	i.mu.Unlock()
+
+	if roll < fault.ErrorRate {
+		return fmt.Errorf("%s: %w", operation, ErrInjected)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package chaos
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInjector_inject(t *testing.T) {
+	testCases := []struct {
+		name          string
+		fault         Fault
+		expectedError error
+	}{
+		{
+			name:  "Should not fail when error rate is zero",
+			fault: Fault{},
+		},
+		{
+			name:          "Should always fail when error rate is one",
+			fault:         Fault{ErrorRate: 1},
+			expectedError: ErrInjected,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			injector := NewInjector(Config{Seed: 1})
+			for i := 0; i < 100; i++ {
+				err := injector.inject(context.Background(), tc.fault, "test")
+				if tc.expectedError != nil {
+					assert.ErrorIs(t, err, tc.expectedError)
+				} else {
+					assert.NoError(t, err)
+				}
+			}
+		})
+	}
+
+	t.Run("Should inject the same faults for the same seed", func(t *testing.T) {
+		sequence := func(seed int64) []bool {
+			injector := NewInjector(Config{Seed: seed})
+			var failed []bool
+			for i := 0; i < 50; i++ {
+				failed = append(failed, injector.inject(context.Background(), Fault{ErrorRate: 0.5}, "test") != nil)
+			}
+			return failed
+		}
+		assert.Equal(t, sequence(42), sequence(42))
+		assert.NotEqual(t, sequence(42), sequence(43))
+	})
+
+	t.Run("Should add latency", func(t *testing.T) {
+		start := time.Now()
+		err := NewInjector(Config{}).inject(context.Background(), Fault{Latency: 20 * time.Millisecond}, "test")
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	})
+
+	t.Run("Should stop waiting when context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := NewInjector(Config{}).inject(ctx, Fault{Latency: time.Hour}, "test")
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestNewStore(t *testing.T) {
+	delegate := mock.NewStore()
+
+	t.Run("Should return delegate when injector is nil", func(t *testing.T) {
+		assert.Same(t, delegate, NewStore(delegate, nil))
+	})
+
+	t.Run("Should fail before calling delegate", func(t *testing.T) {
+		store := NewStore(delegate, NewInjector(Config{Store: Fault{ErrorRate: 1}}))
+		err := store.Create(context.Background(), job.ScanJob{ID: "123"})
+		assert.ErrorIs(t, err, ErrInjected)
+		assert.EqualError(t, err, "creating scan job: chaos: injected fault")
+		delegate.AssertNotCalled(t, "Create")
+	})
+}
+
+func TestNewEnqueuer(t *testing.T) {
+	delegate := mock.NewEnqueuer()
+	delegate.On("Enqueue", context.Background(), harbor.ScanRequest{}).Return(job.ScanJob{ID: "123"}, nil)
+
+	enqueuer := NewEnqueuer(delegate, NewInjector(Config{Store: Fault{ErrorRate: 1}}))
+	scanJob, err := enqueuer.Enqueue(context.Background(), harbor.ScanRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "123", scanJob.ID)
+	delegate.AssertExpectations(t)
+}
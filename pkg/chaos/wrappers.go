@@ -0,0 +1,159 @@
+package chaos
+
+import (
+	"context"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/queue"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+)
+
+type store struct {
+	persistence.Store
+	injector *Injector
+}
+
+// NewStore wraps the given Store with the store faults of the Injector.
+// The Store is returned as is if the Injector is nil.
+func NewStore(delegate persistence.Store, injector *Injector) persistence.Store {
+	if injector == nil {
+		return delegate
+	}
+	return &store{Store: delegate, injector: injector}
+}
+
+func (s *store) Create(ctx context.Context, scanJob job.ScanJob) error {
+	if err := s.injector.inject(ctx, s.injector.config.Store, "creating scan job"); err != nil {
+		return err
+	}
+	return s.Store.Create(ctx, scanJob)
+}
+
+func (s *store) Get(ctx context.Context, scanJobID string) (*job.ScanJob, error) {
+	if err := s.injector.inject(ctx, s.injector.config.Store, "getting scan job"); err != nil {
+		return nil, err
+	}
+	return s.Store.Get(ctx, scanJobID)
+}
+
+func (s *store) UpdateStatus(ctx context.Context, scanJobID string, newStatus job.ScanJobStatus, error ...string) error {
+	if err := s.injector.inject(ctx, s.injector.config.Store, "updating scan job status"); err != nil {
+		return err
+	}
+	return s.Store.UpdateStatus(ctx, scanJobID, newStatus, error...)
+}
+
+func (s *store) UpdateReport(ctx context.Context, scanJobID string, report harbor.ScanReport) error {
+	if err := s.injector.inject(ctx, s.injector.config.Store, "updating scan report"); err != nil {
+		return err
+	}
+	return s.Store.UpdateReport(ctx, scanJobID, report)
+}
+
+func (s *store) Replace(ctx context.Context, scanJob job.ScanJob) error {
+	if err := s.injector.inject(ctx, s.injector.config.Store, "replacing scan job"); err != nil {
+		return err
+	}
+	return s.Store.Replace(ctx, scanJob)
+}
+
+func (s *store) UpdateSBOM(ctx context.Context, scanJobID string, sbom harbor.SBOMReport) error {
+	if err := s.injector.inject(ctx, s.injector.config.Store, "updating SBOM"); err != nil {
+		return err
+	}
+	return s.Store.UpdateSBOM(ctx, scanJobID, sbom)
+}
+
+func (s *store) FindByStatus(ctx context.Context, statuses ...job.ScanJobStatus) ([]job.ScanJob, error) {
+	if err := s.injector.inject(ctx, s.injector.config.Store, "finding scan jobs"); err != nil {
+		return nil, err
+	}
+	return s.Store.FindByStatus(ctx, statuses...)
+}
+
+func (s *store) Extend(ctx context.Context, scanJobID string, ttl time.Duration) error {
+	if err := s.injector.inject(ctx, s.injector.config.Store, "extending scan job"); err != nil {
+		return err
+	}
+	return s.Store.Extend(ctx, scanJobID, ttl)
+}
+
+func (s *store) ListSummaries(ctx context.Context, limit int) ([]job.Summary, error) {
+	if err := s.injector.inject(ctx, s.injector.config.Store, "listing scan job summaries"); err != nil {
+		return nil, err
+	}
+	return s.Store.ListSummaries(ctx, limit)
+}
+
+func (s *store) List(ctx context.Context, options persistence.ListOptions) ([]job.Entry, error) {
+	if err := s.injector.inject(ctx, s.injector.config.Store, "listing scan jobs"); err != nil {
+		return nil, err
+	}
+	return s.Store.List(ctx, options)
+}
+
+type enqueuer struct {
+	queue.Enqueuer
+	injector *Injector
+}
+
+// NewEnqueuer wraps the given Enqueuer with the queue faults of the Injector.
+// The Enqueuer is returned as is if the Injector is nil.
+func NewEnqueuer(delegate queue.Enqueuer, injector *Injector) queue.Enqueuer {
+	if injector == nil {
+		return delegate
+	}
+	return &enqueuer{Enqueuer: delegate, injector: injector}
+}
+
+func (e *enqueuer) Enqueue(ctx context.Context, request harbor.ScanRequest) (job.ScanJob, error) {
+	if err := e.injector.inject(ctx, e.injector.config.Queue, "enqueuing scan job"); err != nil {
+		return job.ScanJob{}, err
+	}
+	return e.Enqueuer.Enqueue(ctx, request)
+}
+
+type wrapper struct {
+	tunnel.Wrapper
+	injector *Injector
+}
+
+// NewWrapper wraps the given Wrapper with the scanner faults of the Injector.
+// The Wrapper is returned as is if the Injector is nil.
+func NewWrapper(delegate tunnel.Wrapper, injector *Injector) tunnel.Wrapper {
+	if injector == nil {
+		return delegate
+	}
+	return &wrapper{Wrapper: delegate, injector: injector}
+}
+
+func (w *wrapper) Scan(imageRef tunnel.ImageRef) (tunnel.Report, error) {
+	if err := w.injector.inject(context.Background(), w.injector.config.Scanner, "running tunnel"); err != nil {
+		return tunnel.Report{}, err
+	}
+	return w.Wrapper.Scan(imageRef)
+}
+
+func (w *wrapper) GenerateSBOM(imageRef tunnel.ImageRef, format tunnel.SBOMFormat) ([]byte, error) {
+	if err := w.injector.inject(context.Background(), w.injector.config.Scanner, "generating SBOM"); err != nil {
+		return nil, err
+	}
+	return w.Wrapper.GenerateSBOM(imageRef, format)
+}
+
+func (w *wrapper) ScanSBOM(sbom []byte) (tunnel.Report, error) {
+	if err := w.injector.inject(context.Background(), w.injector.config.Scanner, "scanning SBOM"); err != nil {
+		return tunnel.Report{}, err
+	}
+	return w.Wrapper.ScanSBOM(sbom)
+}
+
+func (w *wrapper) GetVersion() (tunnel.VersionInfo, error) {
+	if err := w.injector.inject(context.Background(), w.injector.config.Scanner, "getting tunnel version"); err != nil {
+		return tunnel.VersionInfo{}, err
+	}
+	return w.Wrapper.GetVersion()
+}
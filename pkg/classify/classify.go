@@ -0,0 +1,76 @@
+// Package classify classifies findings by where their fix is made, so that they can be routed to the right
+// owners: vulnerable language dependencies of the application are fixed when the application is built, while
+// vulnerable OS packages, and packages shipped by the base image, are fixed by updating the runtime image.
+package classify
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+)
+
+// Stage is where the fix of a finding is made.
+type Stage string
+
+const (
+	// StageBuild findings are fixed by updating the dependencies of the application and rebuilding it.
+	StageBuild Stage = "build"
+	// StageRuntime findings are fixed by updating the base image, or the OS packages installed on top of it.
+	StageRuntime Stage = "runtime"
+)
+
+// classLanguagePackages is the class of the scan results listing language packages.
+const classLanguagePackages = "lang-pkgs"
+
+// Classifier wraps the Classify method.
+// Classify returns the stage where the fix of the given finding is made.
+type Classifier interface {
+	Classify(v tunnel.Vulnerability) Stage
+}
+
+type classifier struct {
+	buildTypes   []string
+	runtimeTypes []string
+	runtimePaths []string
+}
+
+// NewClassifier constructs a Classifier applying the configured heuristics in order:
+//  1. packages of the runtime types are runtime findings, e.g. Go binaries shipped by the base image;
+//  2. packages declared below the runtime paths are runtime findings, e.g. Python packages in /usr/lib;
+//  3. packages of the build types, or language packages if no build types are configured, are build findings;
+//  4. anything else, i.e. OS packages, are runtime findings.
+func NewClassifier(config etc.Classification) Classifier {
+	runtimePaths := make([]string, len(config.RuntimePaths))
+	for i, p := range config.RuntimePaths {
+		runtimePaths[i] = strings.TrimPrefix(p, "/")
+	}
+	return &classifier{
+		buildTypes:   config.BuildTypes,
+		runtimeTypes: config.RuntimeTypes,
+		runtimePaths: runtimePaths,
+	}
+}
+
+func (c *classifier) Classify(v tunnel.Vulnerability) Stage {
+	if slices.Contains(c.runtimeTypes, v.Type) {
+		return StageRuntime
+	}
+	pkgPath := strings.TrimPrefix(v.PkgPath, "/")
+	for _, p := range c.runtimePaths {
+		if pkgPath != "" && strings.HasPrefix(pkgPath, p) {
+			return StageRuntime
+		}
+	}
+	if len(c.buildTypes) > 0 {
+		if slices.Contains(c.buildTypes, v.Type) {
+			return StageBuild
+		}
+		return StageRuntime
+	}
+	if v.Class == classLanguagePackages {
+		return StageBuild
+	}
+	return StageRuntime
+}
@@ -0,0 +1,65 @@
+package classify
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+)
+
+func TestClassifier_Classify(t *testing.T) {
+	openssl := tunnel.Vulnerability{PkgName: "openssl", Class: "os-pkgs", Type: "alpine"}
+	lodash := tunnel.Vulnerability{PkgName: "lodash", PkgPath: "app/node_modules/lodash/package.json", Class: "lang-pkgs", Type: "npm"}
+	requests := tunnel.Vulnerability{PkgName: "requests", PkgPath: "/usr/lib/python3/dist-packages/requests", Class: "lang-pkgs", Type: "python-pkg"}
+	stdlib := tunnel.Vulnerability{PkgName: "stdlib", PkgPath: "usr/local/bin/dockerd", Class: "lang-pkgs", Type: "gobinary"}
+
+	testCases := []struct {
+		name          string
+		config        etc.Classification
+		vulnerability tunnel.Vulnerability
+		expectedStage Stage
+	}{
+		{
+			name:          "Should classify OS package as runtime",
+			vulnerability: openssl,
+			expectedStage: StageRuntime,
+		},
+		{
+			name:          "Should classify language package as build",
+			vulnerability: lodash,
+			expectedStage: StageBuild,
+		},
+		{
+			name:          "Should classify package of runtime type as runtime",
+			config:        etc.Classification{RuntimeTypes: []string{"gobinary"}},
+			vulnerability: stdlib,
+			expectedStage: StageRuntime,
+		},
+		{
+			name:          "Should classify package below runtime path as runtime",
+			config:        etc.Classification{RuntimePaths: []string{"/usr/lib/"}},
+			vulnerability: requests,
+			expectedStage: StageRuntime,
+		},
+		{
+			name:          "Should classify package of build type as build",
+			config:        etc.Classification{BuildTypes: []string{"npm"}},
+			vulnerability: lodash,
+			expectedStage: StageBuild,
+		},
+		{
+			name:          "Should classify language package of another type as runtime when build types are configured",
+			config:        etc.Classification{BuildTypes: []string{"npm"}},
+			vulnerability: requests,
+			expectedStage: StageRuntime,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedStage, NewClassifier(tc.config).Classify(tc.vulnerability))
+		})
+	}
+}
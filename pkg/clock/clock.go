@@ -0,0 +1,61 @@
+// Package clock abstracts the current time, so that the time-based decisions of the adapter, e.g. the expiry of
+// scan jobs, the takeover of stalled scan jobs or the durations recorded in metrics, can be tested
+// deterministically with a Fake clock rather than the system time.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock wraps the Now method. Introduced to allow replacing the global state with fixed clocks to facilitate testing.
+// Now returns the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+type system struct {
+}
+
+func (system) Now() time.Time {
+	return time.Now()
+}
+
+// System is the Clock of the system time, which subsystems use unless they are constructed with another Clock.
+var System Clock = system{}
+
+// Since returns the time elapsed since the given time according to the given Clock.
+func Since(c Clock, t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// Fake is a Clock whose time only changes when it is set or advanced. It is safe for concurrent use.
+type Fake struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFake constructs a Fake clock set to the given time.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Set sets the clock to the given time.
+func (f *Fake) Set(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = now
+}
+
+// Advance moves the clock forward by the given duration.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
@@ -0,0 +1,28 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFake(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	c := NewFake(now)
+
+	assert.Equal(t, now, c.Now())
+
+	c.Advance(90 * time.Second)
+	assert.Equal(t, now.Add(90*time.Second), c.Now())
+	assert.Equal(t, 90*time.Second, Since(c, now))
+
+	c.Set(now)
+	assert.Equal(t, now, c.Now())
+}
+
+func TestSystem(t *testing.T) {
+	before := time.Now()
+	now := System.Now()
+	assert.False(t, now.Before(before))
+}
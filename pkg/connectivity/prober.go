@@ -0,0 +1,265 @@
+// Package connectivity periodically probes the outbound destinations of the adapter, i.e. the registries of recent
+// scan requests, the source of vulnerability database updates and the webhook targets, so that failures of the
+// network can be told apart from failures of the adapter. Probes only open a TCP connection to each destination,
+// which neither authenticates nor triggers webhooks.
+package connectivity
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+)
+
+// Kinds of the probed destinations.
+const (
+	KindRegistry        = "registry"
+	KindVulnerabilityDB = "vulnerability_db"
+	KindTunnelServer    = "tunnel_server"
+	KindWebhook         = "webhook"
+)
+
+// Classes of the failures of probes.
+const (
+	FailureDNS     = "dns"
+	FailureRefused = "refused"
+	FailureTimeout = "timeout"
+	FailureOther   = "other"
+)
+
+// Status is the result of the last probe of a destination.
+type Status struct {
+	Kind string `json:"kind"`
+	// Address is the host and port probed.
+	Address   string `json:"address"`
+	Reachable bool   `json:"reachable"`
+	// Failure is the class of the error of an unreachable destination.
+	Failure       string    `json:"failure,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	LatencyMillis int64     `json:"latency_ms"`
+	CheckedAt     time.Time `json:"checked_at"`
+}
+
+// Prober probes the outbound destinations of the adapter.
+type Prober interface {
+	// ObserveRegistry adds the registry of a scan request to the probed destinations until it has not been seen
+	// for the configured window.
+	ObserveRegistry(registryURL string)
+	// Statuses returns the statuses of the destinations probed so far, sorted by kind and address.
+	Statuses() []Status
+	// Run probes the destinations at the configured interval until the given context is done.
+	Run(ctx context.Context)
+}
+
+type destination struct {
+	kind    string
+	address string
+}
+
+type prober struct {
+	config etc.Connectivity
+	static []destination
+	dial   func(ctx context.Context, network, address string) (net.Conn, error)
+	now    func() time.Time
+
+	mu         sync.Mutex
+	registries map[string]time.Time
+	statuses   map[destination]Status
+}
+
+// NewProber constructs a Prober of the Tunnel server, or of the source of vulnerability database updates unless
+// Tunnel skips updates, and of the webhook targets of the given configuration.
+func NewProber(config etc.Config) Prober {
+	var static []destination
+	switch {
+	case config.Tunnel.ServerURL != "":
+		static = appendDestination(static, KindTunnelServer, config.Tunnel.ServerURL)
+	case !config.Tunnel.SkipUpdate && !config.Tunnel.OfflineScan:
+		static = appendDestination(static, KindVulnerabilityDB, config.Connectivity.DBSourceURL)
+	}
+	static = appendDestination(static, KindWebhook, config.Impact.WebhookURL)
+	static = appendDestination(static, KindWebhook, config.CleanScans.WebhookURL)
+	static = appendDestination(static, KindWebhook, config.Notifications.SlackWebhookURL)
+	static = appendDestination(static, KindWebhook, config.Notifications.TeamsWebhookURL)
+	static = appendDestination(static, KindWebhook, config.Tunnel.CredentialsRefreshHookURL)
+	for _, hook := range config.Enrichment.Hooks {
+		static = appendDestination(static, KindWebhook, hook)
+	}
+
+	return &prober{
+		config:     config.Connectivity,
+		static:     static,
+		dial:       (&net.Dialer{}).DialContext,
+		now:        time.Now,
+		registries: make(map[string]time.Time),
+		statuses:   make(map[destination]Status),
+	}
+}
+
+// appendDestination appends the destination of the given URL, unless it is blank or not an HTTP(S) URL, e.g. the
+// path of an enrichment hook executable.
+func appendDestination(destinations []destination, kind, rawURL string) []destination {
+	address, ok := addressOf(rawURL)
+	if !ok {
+		return destinations
+	}
+	d := destination{kind: kind, address: address}
+	if slices.Contains(destinations, d) {
+		return destinations
+	}
+	return append(destinations, d)
+}
+
+// addressOf returns the host and port of the given HTTP(S) URL, the port defaulting to the port of its scheme.
+func addressOf(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "", false
+	}
+	var port string
+	switch strings.ToLower(u.Scheme) {
+	case "https":
+		port = "443"
+	case "http":
+		port = "80"
+	default:
+		return "", false
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	return net.JoinHostPort(u.Hostname(), port), true
+}
+
+func (p *prober) ObserveRegistry(registryURL string) {
+	address, ok := addressOf(registryURL)
+	if !ok {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.registries[address] = p.now()
+}
+
+func (p *prober) Statuses() []Status {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	statuses := make([]Status, 0, len(p.statuses))
+	for _, status := range p.statuses {
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Kind != statuses[j].Kind {
+			return statuses[i].Kind < statuses[j].Kind
+		}
+		return statuses[i].Address < statuses[j].Address
+	})
+	return statuses
+}
+
+func (p *prober) Run(ctx context.Context) {
+	p.probeAll(ctx)
+
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.probeAll(ctx)
+		}
+	}
+}
+
+// probeAll probes all destinations concurrently, and forgets the registries not seen within the window.
+func (p *prober) probeAll(ctx context.Context) {
+	destinations := p.destinations()
+
+	var wg sync.WaitGroup
+	results := make([]Status, len(destinations))
+	for i, d := range destinations {
+		wg.Add(1)
+		go func(i int, d destination) {
+			defer wg.Done()
+			results[i] = p.probe(ctx, d)
+		}(i, d)
+	}
+	wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.statuses = make(map[destination]Status, len(destinations))
+	for i, d := range destinations {
+		p.statuses[d] = results[i]
+		if !results[i].Reachable {
+			slog.Warn("Outbound destination is unreachable", slog.String("kind", d.kind),
+				slog.String("address", d.address), slog.String("failure", results[i].Failure),
+				slog.String("err", results[i].Error))
+		}
+	}
+}
+
+func (p *prober) destinations() []destination {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	destinations := append([]destination(nil), p.static...)
+	now := p.now()
+	for address, seenAt := range p.registries {
+		if now.Sub(seenAt) > p.config.RegistryWindow {
+			delete(p.registries, address)
+			continue
+		}
+		destinations = append(destinations, destination{kind: KindRegistry, address: address})
+	}
+	return destinations
+}
+
+func (p *prober) probe(ctx context.Context, d destination) Status {
+	ctx, cancel := context.WithTimeout(ctx, p.config.Timeout)
+	defer cancel()
+
+	start := p.now()
+	conn, err := p.dial(ctx, "tcp", d.address)
+	status := Status{
+		Kind:          d.kind,
+		Address:       d.address,
+		Reachable:     err == nil,
+		LatencyMillis: p.now().Sub(start).Milliseconds(),
+		CheckedAt:     start,
+	}
+	if err != nil {
+		status.Failure = ClassifyFailure(err)
+		status.Error = err.Error()
+		return status
+	}
+	_ = conn.Close()
+	return status
+}
+
+// ClassifyFailure returns the class of the given error of a probe.
+func ClassifyFailure(err error) string {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return FailureDNS
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return FailureRefused
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return FailureTimeout
+	}
+	return FailureOther
+}
@@ -0,0 +1,136 @@
+package connectivity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProber(t *testing.T) {
+	t.Run("Should probe vulnerability database source and webhook targets", func(t *testing.T) {
+		p := NewProber(etc.Config{
+			Tunnel:       etc.Tunnel{CredentialsRefreshHookURL: "http://vault.example.com:8200/refresh"},
+			Impact:       etc.Impact{WebhookURL: "https://hooks.example.com/impact"},
+			Enrichment:   etc.Enrichment{Hooks: []string{"/usr/local/bin/enrich", "https://hooks.example.com/enrich"}},
+			Connectivity: etc.Connectivity{DBSourceURL: "https://api.github.com"},
+		}).(*prober)
+
+		assert.Equal(t, []destination{
+			{kind: KindVulnerabilityDB, address: "api.github.com:443"},
+			{kind: KindWebhook, address: "hooks.example.com:443"},
+			{kind: KindWebhook, address: "vault.example.com:8200"},
+		}, p.static)
+	})
+
+	t.Run("Should not probe vulnerability database source when updates are skipped", func(t *testing.T) {
+		p := NewProber(etc.Config{
+			Tunnel:       etc.Tunnel{SkipUpdate: true},
+			Connectivity: etc.Connectivity{DBSourceURL: "https://api.github.com"},
+		}).(*prober)
+
+		assert.Empty(t, p.static)
+	})
+
+	t.Run("Should probe tunnel server instead of vulnerability database source", func(t *testing.T) {
+		p := NewProber(etc.Config{
+			Tunnel:       etc.Tunnel{ServerURL: "http://tunnel-server:4954"},
+			Connectivity: etc.Connectivity{DBSourceURL: "https://api.github.com"},
+		}).(*prober)
+
+		assert.Equal(t, []destination{{kind: KindTunnelServer, address: "tunnel-server:4954"}}, p.static)
+	})
+}
+
+func TestProber(t *testing.T) {
+	server := httptest.NewServer(nil)
+	defer server.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedAddress := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	p := NewProber(etc.Config{
+		Tunnel: etc.Tunnel{SkipUpdate: true},
+		Impact: etc.Impact{WebhookURL: fmt.Sprintf("http://%s/impact", closedAddress)},
+		Connectivity: etc.Connectivity{
+			Interval:       time.Minute,
+			Timeout:        time.Second,
+			RegistryWindow: time.Hour,
+		},
+	}).(*prober)
+	p.now = func() time.Time { return now }
+
+	p.ObserveRegistry(server.URL)
+	p.ObserveRegistry("core.harbor.domain")
+	p.probeAll(context.Background())
+
+	assert.Equal(t, []Status{
+		{
+			Kind:      KindRegistry,
+			Address:   server.Listener.Addr().String(),
+			Reachable: true,
+			CheckedAt: now,
+		},
+		{
+			Kind:      KindWebhook,
+			Address:   closedAddress,
+			Failure:   FailureRefused,
+			Error:     fmt.Sprintf("dial tcp %s: connect: connection refused", closedAddress),
+			CheckedAt: now,
+		},
+	}, p.Statuses())
+
+	now = now.Add(2 * time.Hour)
+	p.probeAll(context.Background())
+
+	statuses := p.Statuses()
+	require.Len(t, statuses, 1, "registry should no longer be probed once it has not been seen for the window")
+	assert.Equal(t, KindWebhook, statuses[0].Kind)
+}
+
+func TestClassifyFailure(t *testing.T) {
+	testCases := []struct {
+		name            string
+		err             error
+		expectedFailure string
+	}{
+		{
+			name:            "Should classify DNS error",
+			err:             &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "registry.invalid"}},
+			expectedFailure: FailureDNS,
+		},
+		{
+			name:            "Should classify refused connection",
+			err:             &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)},
+			expectedFailure: FailureRefused,
+		},
+		{
+			name:            "Should classify timeout",
+			err:             &net.OpError{Op: "dial", Err: context.DeadlineExceeded},
+			expectedFailure: FailureTimeout,
+		},
+		{
+			name:            "Should classify other errors",
+			err:             errors.New("network is unreachable"),
+			expectedFailure: FailureOther,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedFailure, ClassifyFailure(tc.err))
+		})
+	}
+}
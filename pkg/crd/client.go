@@ -0,0 +1,180 @@
+package crd
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/httpx"
+)
+
+const (
+	// serviceAccountDir is where Kubernetes mounts the token and the CA certificate of the service account of pods.
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+	clientTimeout = 30 * time.Second
+	// maxErrorSize is the maximum number of bytes of an error response of the API server quoted in errors.
+	maxErrorSize = 1024
+
+	mimeTypeMergePatch = "application/merge-patch+json"
+	mimeTypeApplyPatch = "application/apply-patch+yaml"
+)
+
+// Client wraps the requests to the Kubernetes API server made by the controller.
+type Client interface {
+	// ListScanRequests lists the ScanRequests of the given namespace, or of all namespaces if it is blank.
+	ListScanRequests(ctx context.Context, namespace string) ([]ScanRequest, error)
+	// UpdateScanRequestStatus replaces the status of the given ScanRequest.
+	UpdateScanRequestStatus(ctx context.Context, request ScanRequest) error
+	// ApplyScanReport creates or updates the given ScanReport, taking over the fields owned by other managers.
+	ApplyScanReport(ctx context.Context, report ScanReport) error
+	// GetSecretData returns the data of the given secret.
+	GetSecretData(ctx context.Context, namespace, name string) (map[string][]byte, error)
+}
+
+type client struct {
+	baseURL   string
+	tokenFile string
+	http      *http.Client
+}
+
+// NewClient constructs a Client of the API server at the given base URL, authenticated with the bearer token read
+// from the given file, if any, before each request, so that the rotated tokens of service accounts are picked up.
+func NewClient(baseURL, tokenFile string, httpClient *http.Client) Client {
+	return &client{
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		tokenFile: tokenFile,
+		http:      httpClient,
+	}
+}
+
+// NewInClusterClient constructs a Client of the API server of the cluster the adapter is running in, authenticated
+// as the service account of its pod.
+func NewInClusterClient() (Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in a Kubernetes cluster: KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT must be set")
+	}
+	ca, err := os.ReadFile(path.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("reading service account CA certificate: %w", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(ca) {
+		return nil, errors.New("parsing service account CA certificate: no certificates found")
+	}
+	httpClient := &http.Client{
+		Transport: httpx.Instrument(httpx.RequestDuration, "kubernetes",
+			httpx.NewTransport(&tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12})),
+		Timeout: clientTimeout,
+	}
+	return NewClient("https://"+net.JoinHostPort(host, port), path.Join(serviceAccountDir, "token"), httpClient), nil
+}
+
+func (c *client) ListScanRequests(ctx context.Context, namespace string) ([]ScanRequest, error) {
+	var list struct {
+		Items []ScanRequest `json:"items"`
+	}
+	if err := c.do(ctx, http.MethodGet, resourcePath(namespace, "scanrequests", ""), "", nil, &list); err != nil {
+		return nil, fmt.Errorf("listing scan requests: %w", err)
+	}
+	return list.Items, nil
+}
+
+func (c *client) UpdateScanRequestStatus(ctx context.Context, request ScanRequest) error {
+	patch := map[string]interface{}{"status": request.Status}
+	p := resourcePath(request.Metadata.Namespace, "scanrequests", request.Metadata.Name) + "/status"
+	if err := c.do(ctx, http.MethodPatch, p, mimeTypeMergePatch, patch, nil); err != nil {
+		return fmt.Errorf("updating scan request status: %w", err)
+	}
+	return nil
+}
+
+func (c *client) ApplyScanReport(ctx context.Context, report ScanReport) error {
+	query := url.Values{"fieldManager": {FieldManager}, "force": {"true"}}
+	p := resourcePath(report.Metadata.Namespace, "scanreports", report.Metadata.Name) + "?" + query.Encode()
+	// JSON is YAML, hence the report is applied as is.
+	if err := c.do(ctx, http.MethodPatch, p, mimeTypeApplyPatch, report, nil); err != nil {
+		return fmt.Errorf("applying scan report: %w", err)
+	}
+	return nil
+}
+
+func (c *client) GetSecretData(ctx context.Context, namespace, name string) (map[string][]byte, error) {
+	var secret struct {
+		Data map[string][]byte `json:"data"`
+	}
+	p := "/api/v1/namespaces/" + url.PathEscape(namespace) + "/secrets/" + url.PathEscape(name)
+	if err := c.do(ctx, http.MethodGet, p, "", nil, &secret); err != nil {
+		return nil, fmt.Errorf("getting secret: %w", err)
+	}
+	return secret.Data, nil
+}
+
+// resourcePath returns the path of the given resource of the custom resources, or of their collection if the name
+// is blank, in the given namespace, or in all namespaces if it is blank.
+func resourcePath(namespace, resource, name string) string {
+	p := "/apis/" + APIVersion
+	if namespace != "" {
+		p += "/namespaces/" + url.PathEscape(namespace)
+	}
+	p += "/" + resource
+	if name != "" {
+		p += "/" + url.PathEscape(name)
+	}
+	return p
+}
+
+// do sends a request with the given body encoded as JSON to the given path, and decodes the response into out
+// unless it is nil.
+func (c *client) do(ctx context.Context, method, p, contentType string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+p, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.tokenFile != "" {
+		token, err := os.ReadFile(c.tokenFile)
+		if err != nil {
+			return fmt.Errorf("reading service account token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorSize))
+		return fmt.Errorf("unexpected status %d: %s", res.StatusCode, bytes.TrimSpace(message))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
@@ -0,0 +1,275 @@
+package crd
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/queue"
+)
+
+// dockerConfigJSONKey is the key of the data of kubernetes.io/dockerconfigjson secrets.
+const dockerConfigJSONKey = ".dockerconfigjson"
+
+// Controller reconciles ScanRequests with the scan jobs enqueued for them.
+type Controller interface {
+	// Run polls the ScanRequests at the configured interval until the given context is done.
+	Run(ctx context.Context)
+}
+
+type controller struct {
+	config   etc.CRD
+	client   Client
+	enqueuer queue.Enqueuer
+	store    persistence.Store
+}
+
+// NewController constructs a Controller, which enqueues a scan job with the given Enqueuer for each new
+// ScanRequest, follows the scan job in the given Store, and applies a ScanReport with its result once it is
+// finished. ScanRequests are polled rather than watched, which keeps the controller to plain requests to the API
+// server, and the ones which could not be reconciled, e.g. because the backlog is full, are retried at the next poll.
+func NewController(config etc.CRD, client Client, enqueuer queue.Enqueuer, store persistence.Store) Controller {
+	return &controller{
+		config:   config,
+		client:   client,
+		enqueuer: enqueuer,
+		store:    store,
+	}
+}
+
+func (c *controller) Run(ctx context.Context) {
+	c.reconcile(ctx)
+
+	ticker := time.NewTicker(c.config.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.reconcile(ctx)
+		}
+	}
+}
+
+// reconcile reconciles all ScanRequests which are not finished or failed yet.
+func (c *controller) reconcile(ctx context.Context) {
+	requests, err := c.client.ListScanRequests(ctx, c.config.Namespace)
+	if err != nil {
+		slog.WarnContext(ctx, "Error while listing scan requests", slog.String("err", err.Error()))
+		return
+	}
+	for _, request := range requests {
+		var err error
+		switch request.Status.Phase {
+		case "":
+			err = c.enqueue(ctx, request)
+		case PhaseQueued, PhasePending:
+			err = c.follow(ctx, request)
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "Error while reconciling scan request", slog.String("namespace", request.Metadata.Namespace),
+				slog.String("name", request.Metadata.Name), slog.String("err", err.Error()))
+		}
+	}
+}
+
+// enqueue enqueues a scan job for the given new ScanRequest, or fails it if its spec is invalid.
+func (c *controller) enqueue(ctx context.Context, request ScanRequest) error {
+	scanRequest, err := c.toScanRequest(ctx, request)
+	var invalid *invalidSpecError
+	if errors.As(err, &invalid) {
+		request.Status = ScanRequestStatus{Phase: PhaseFailed, Message: invalid.message}
+		return c.client.UpdateScanRequestStatus(ctx, request)
+	}
+	if err != nil {
+		return err
+	}
+	scanJob, err := c.enqueuer.Enqueue(ctx, scanRequest)
+	if err != nil {
+		// The scan job is enqueued again at the next poll, e.g. once the backlog is drained.
+		return fmt.Errorf("enqueuing scan job: %w", err)
+	}
+	slog.InfoContext(ctx, "Enqueued scan job for scan request", slog.String("namespace", request.Metadata.Namespace),
+		slog.String("name", request.Metadata.Name), slog.String("scan_job_id", scanJob.ID))
+
+	request.Status = ScanRequestStatus{Phase: PhaseQueued, ScanJobID: scanJob.ID}
+	return c.client.UpdateScanRequestStatus(ctx, request)
+}
+
+// follow updates the phase of the given ScanRequest to the status of its scan job, and applies the ScanReport of
+// the scan job once it is finished.
+func (c *controller) follow(ctx context.Context, request ScanRequest) error {
+	scanJob, err := c.store.Get(ctx, request.Status.ScanJobID)
+	if err != nil {
+		return fmt.Errorf("getting scan job: %w", err)
+	}
+
+	status := request.Status
+	switch {
+	case scanJob == nil:
+		status.Phase = PhaseFailed
+		status.Message = "scan job not found, it may have expired"
+	case scanJob.Status == job.Queued:
+		status.Phase = PhaseQueued
+	case scanJob.Status == job.Pending:
+		status.Phase = PhasePending
+	case scanJob.Status == job.Failed:
+		status.Phase = PhaseFailed
+		status.Message = scanJob.Error
+	case scanJob.Status == job.Finished:
+		if err = c.client.ApplyScanReport(ctx, c.toScanReport(request, *scanJob)); err != nil {
+			return err
+		}
+		status.Phase = PhaseFinished
+		status.ReportName = request.Metadata.Name
+	}
+	if status == request.Status {
+		return nil
+	}
+	request.Status = status
+	return c.client.UpdateScanRequestStatus(ctx, request)
+}
+
+// invalidSpecError fails a ScanRequest for good, as opposed to the errors of the requests to the API server, which
+// are retried at the next poll.
+type invalidSpecError struct {
+	message string
+}
+
+func (e *invalidSpecError) Error() string {
+	return e.message
+}
+
+// toScanRequest converts the spec of the given ScanRequest to the scan request enqueued for it, authorized with
+// the credentials of its pull secret, if any. It returns an invalidSpecError if the spec is invalid.
+func (c *controller) toScanRequest(ctx context.Context, request ScanRequest) (harbor.ScanRequest, error) {
+	spec := request.Spec
+	registryURL, err := url.ParseRequestURI(spec.Registry.URL)
+	switch {
+	case spec.Registry.URL == "":
+		return harbor.ScanRequest{}, &invalidSpecError{message: "missing registry.url"}
+	case err != nil:
+		return harbor.ScanRequest{}, &invalidSpecError{message: "invalid registry.url"}
+	case spec.Artifact.Repository == "":
+		return harbor.ScanRequest{}, &invalidSpecError{message: "missing artifact.repository"}
+	case spec.Artifact.Digest == "":
+		return harbor.ScanRequest{}, &invalidSpecError{message: "missing artifact.digest"}
+	case spec.TimeoutSeconds < 0:
+		return harbor.ScanRequest{}, &invalidSpecError{message: "timeoutSeconds must not be negative"}
+	}
+
+	scanRequest := harbor.ScanRequest{
+		Registry: harbor.Registry{URL: spec.Registry.URL},
+		Artifact: harbor.Artifact{
+			Repository: spec.Artifact.Repository,
+			Digest:     spec.Artifact.Digest,
+			MimeType:   spec.Artifact.MimeType,
+		},
+		TimeoutSeconds: spec.TimeoutSeconds,
+	}
+	if spec.Registry.PullSecretName != "" {
+		data, err := c.client.GetSecretData(ctx, request.Metadata.Namespace, spec.Registry.PullSecretName)
+		if err != nil {
+			return harbor.ScanRequest{}, err
+		}
+		auth, err := basicAuth(data[dockerConfigJSONKey], registryURL.Host)
+		if err != nil {
+			return harbor.ScanRequest{}, &invalidSpecError{
+				message: fmt.Sprintf("pull secret %s: %s", spec.Registry.PullSecretName, err),
+			}
+		}
+		scanRequest.Registry.Authorization = "Basic " + auth
+	}
+	return scanRequest, nil
+}
+
+// basicAuth returns the base64 encoded credentials of the given registry host held by the given Docker config.
+func basicAuth(dockerConfigJSON []byte, host string) (string, error) {
+	var config struct {
+		Auths map[string]struct {
+			Auth     string `json:"auth"`
+			Username string `json:"username"`
+			Password string `json:"password"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(dockerConfigJSON, &config); err != nil {
+		return "", fmt.Errorf("parsing %s: %w", dockerConfigJSONKey, err)
+	}
+	for server, entry := range config.Auths {
+		// Servers are registry hosts, optionally with the scheme and path of the registry API.
+		if u, err := url.Parse(server); err == nil && u.Host != "" {
+			server = u.Host
+		}
+		if !strings.EqualFold(server, host) {
+			continue
+		}
+		if entry.Auth != "" {
+			return entry.Auth, nil
+		}
+		return base64.StdEncoding.EncodeToString([]byte(entry.Username + ":" + entry.Password)), nil
+	}
+	return "", fmt.Errorf("no credentials for registry %s", host)
+}
+
+// toScanReport converts the report of the given finished scan job to the ScanReport of the given ScanRequest,
+// listing up to the configured maximum number of vulnerabilities, the most severe first.
+func (c *controller) toScanReport(request ScanRequest, scanJob job.ScanJob) ScanReport {
+	vulnerabilities := make([]harbor.VulnerabilityItem, len(scanJob.Report.Vulnerabilities))
+	copy(vulnerabilities, scanJob.Report.Vulnerabilities)
+	sort.SliceStable(vulnerabilities, func(i, j int) bool {
+		return vulnerabilities[i].Severity > vulnerabilities[j].Severity
+	})
+	truncated := len(vulnerabilities) > c.config.MaxVulnerabilities
+	if truncated {
+		vulnerabilities = vulnerabilities[:c.config.MaxVulnerabilities]
+	}
+
+	status := ScanReportStatus{
+		ScanJobID:       scanJob.ID,
+		Repository:      request.Spec.Artifact.Repository,
+		Digest:          request.Spec.Artifact.Digest,
+		GeneratedAt:     scanJob.Report.GeneratedAt,
+		Severity:        scanJob.Report.Severity.String(),
+		Counts:          persistence.CountSeverities(scanJob.Report),
+		Vulnerabilities: make([]Vulnerability, 0, len(vulnerabilities)),
+		Truncated:       truncated,
+	}
+	for _, v := range vulnerabilities {
+		status.Vulnerabilities = append(status.Vulnerabilities, Vulnerability{
+			ID:         v.ID,
+			Package:    v.Pkg,
+			Version:    v.Version,
+			FixVersion: v.FixVersion,
+			Severity:   v.Severity.String(),
+		})
+	}
+
+	return ScanReport{
+		APIVersion: APIVersion,
+		Kind:       KindScanReport,
+		Metadata: ObjectMeta{
+			Name:      request.Metadata.Name,
+			Namespace: request.Metadata.Namespace,
+			OwnerReferences: []OwnerReference{{
+				APIVersion: APIVersion,
+				Kind:       KindScanRequest,
+				Name:       request.Metadata.Name,
+				UID:        request.Metadata.UID,
+				Controller: true,
+			}},
+		},
+		Status: status,
+	}
+}
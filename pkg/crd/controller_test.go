@@ -0,0 +1,240 @@
+package crd
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/mock"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence/memory"
+)
+
+type patch struct {
+	path        string
+	contentType string
+	body        map[string]interface{}
+}
+
+// apiServer fakes the Kubernetes API server, serving the given ScanRequests and pull secret, and recording the
+// patches sent to it.
+type apiServer struct {
+	requests []ScanRequest
+	secret   map[string][]byte
+
+	mu      sync.Mutex
+	patches []patch
+}
+
+func (s *apiServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/apis/scanner.khulnasoft.com/v1alpha1/namespaces/ci/scanrequests":
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"items": s.requests})
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v1/namespaces/ci/secrets/harbor-robot":
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": s.secret})
+	case r.Method == http.MethodPatch:
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]interface{}
+		_ = json.Unmarshal(data, &body)
+		s.mu.Lock()
+		s.patches = append(s.patches, patch{path: r.URL.RequestURI(), contentType: r.Header.Get("Content-Type"), body: body})
+		s.mu.Unlock()
+		_, _ = w.Write(data)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestController(t *testing.T) {
+	ctx := context.Background()
+	config := etc.CRD{Namespace: "ci", PollInterval: time.Minute, MaxVulnerabilities: 2}
+	metadata := ObjectMeta{Name: "nginx", Namespace: "ci", UID: "7f3c"}
+	spec := ScanRequestSpec{
+		Registry: RegistrySpec{URL: "https://core.harbor.domain", PullSecretName: "harbor-robot"},
+		Artifact: ArtifactSpec{Repository: "library/nginx", Digest: "sha256:6c3c"},
+	}
+	scanRequest := harbor.ScanRequest{
+		Registry: harbor.Registry{URL: "https://core.harbor.domain", Authorization: "Basic cm9ib3Q6czNjcmV0"},
+		Artifact: harbor.Artifact{Repository: "library/nginx", Digest: "sha256:6c3c"},
+	}
+	secret := map[string][]byte{
+		".dockerconfigjson": []byte(`{"auths":{"core.harbor.domain":{"auth":"cm9ib3Q6czNjcmV0"}}}`),
+	}
+	report := harbor.ScanReport{
+		GeneratedAt: time.Date(2024, 4, 2, 9, 30, 0, 0, time.UTC),
+		Severity:    harbor.SevCritical,
+		Vulnerabilities: []harbor.VulnerabilityItem{
+			{ID: "CVE-2023-5363", Pkg: "openssl", Version: "3.0.11", FixVersion: "3.0.12", Severity: harbor.SevHigh},
+			{ID: "CVE-2023-44487", Pkg: "nghttp2", Version: "1.52.0", Severity: harbor.SevMedium},
+			{ID: "CVE-2024-3094", Pkg: "xz", Version: "5.6.0", FixVersion: "5.6.2", Severity: harbor.SevCritical},
+		},
+	}
+
+	newController := func(t *testing.T, server *apiServer, enqueuer *mock.Enqueuer, scanJobs ...job.ScanJob) *controller {
+		ts := httptest.NewServer(server)
+		t.Cleanup(ts.Close)
+		store := memory.NewStore(etc.MemoryStore{ScanJobTTL: time.Hour, MaxScanJobs: 10})
+		for _, scanJob := range scanJobs {
+			require.NoError(t, store.Create(ctx, scanJob))
+		}
+		return NewController(config, NewClient(ts.URL, "", ts.Client()), enqueuer, store).(*controller)
+	}
+
+	t.Run("Should enqueue scan job for new scan request", func(t *testing.T) {
+		server := &apiServer{requests: []ScanRequest{{Metadata: metadata, Spec: spec}}, secret: secret}
+		enqueuer := mock.NewEnqueuer()
+		enqueuer.On("Enqueue", ctx, scanRequest).Return(job.ScanJob{ID: "job:123", Status: job.Queued}, nil)
+
+		newController(t, server, enqueuer).reconcile(ctx)
+
+		enqueuer.AssertExpectations(t)
+		assert.Equal(t, []patch{{
+			path:        "/apis/scanner.khulnasoft.com/v1alpha1/namespaces/ci/scanrequests/nginx/status",
+			contentType: "application/merge-patch+json",
+			body:        map[string]interface{}{"status": map[string]interface{}{"phase": "Queued", "scanJobID": "job:123"}},
+		}}, server.patches)
+	})
+
+	t.Run("Should fail scan request with invalid spec", func(t *testing.T) {
+		invalid := spec
+		invalid.Artifact.Digest = ""
+		server := &apiServer{requests: []ScanRequest{{Metadata: metadata, Spec: invalid}}}
+		enqueuer := mock.NewEnqueuer()
+
+		newController(t, server, enqueuer).reconcile(ctx)
+
+		enqueuer.AssertNotCalled(t, "Enqueue")
+		require.Len(t, server.patches, 1)
+		assert.Equal(t, map[string]interface{}{"status": map[string]interface{}{
+			"phase": "Failed", "message": "missing artifact.digest",
+		}}, server.patches[0].body)
+	})
+
+	t.Run("Should retry new scan request when pull secret cannot be got", func(t *testing.T) {
+		invalid := spec
+		invalid.Registry.PullSecretName = "missing"
+		server := &apiServer{requests: []ScanRequest{{Metadata: metadata, Spec: invalid}}}
+		enqueuer := mock.NewEnqueuer()
+
+		newController(t, server, enqueuer).reconcile(ctx)
+
+		enqueuer.AssertNotCalled(t, "Enqueue")
+		assert.Empty(t, server.patches)
+	})
+
+	t.Run("Should leave scan request of queued scan job as is", func(t *testing.T) {
+		server := &apiServer{requests: []ScanRequest{{Metadata: metadata, Spec: spec,
+			Status: ScanRequestStatus{Phase: PhaseQueued, ScanJobID: "job:123"}}}}
+
+		newController(t, server, mock.NewEnqueuer(), job.ScanJob{ID: "job:123", Status: job.Queued}).reconcile(ctx)
+
+		assert.Empty(t, server.patches)
+	})
+
+	t.Run("Should fail scan request of failed scan job", func(t *testing.T) {
+		server := &apiServer{requests: []ScanRequest{{Metadata: metadata, Spec: spec,
+			Status: ScanRequestStatus{Phase: PhasePending, ScanJobID: "job:123"}}}}
+
+		newController(t, server, mock.NewEnqueuer(),
+			job.ScanJob{ID: "job:123", Status: job.Failed, Error: "running tunnel: exit status 1"}).reconcile(ctx)
+
+		require.Len(t, server.patches, 1)
+		assert.Equal(t, map[string]interface{}{"status": map[string]interface{}{
+			"phase": "Failed", "scanJobID": "job:123", "message": "running tunnel: exit status 1",
+		}}, server.patches[0].body)
+	})
+
+	t.Run("Should apply scan report of finished scan job", func(t *testing.T) {
+		server := &apiServer{requests: []ScanRequest{{Metadata: metadata, Spec: spec,
+			Status: ScanRequestStatus{Phase: PhasePending, ScanJobID: "job:123"}}}}
+
+		newController(t, server, mock.NewEnqueuer(),
+			job.ScanJob{ID: "job:123", Status: job.Finished, Report: report}).reconcile(ctx)
+
+		require.Len(t, server.patches, 2)
+		assert.Equal(t, "/apis/scanner.khulnasoft.com/v1alpha1/namespaces/ci/scanreports/nginx?fieldManager=scanner-tunnel&force=true",
+			server.patches[0].path)
+		assert.Equal(t, "application/apply-patch+yaml", server.patches[0].contentType)
+		assert.Equal(t, map[string]interface{}{
+			"apiVersion": "scanner.khulnasoft.com/v1alpha1",
+			"kind":       "ScanReport",
+			"metadata": map[string]interface{}{
+				"name":      "nginx",
+				"namespace": "ci",
+				"ownerReferences": []interface{}{map[string]interface{}{
+					"apiVersion": "scanner.khulnasoft.com/v1alpha1",
+					"kind":       "ScanRequest",
+					"name":       "nginx",
+					"uid":        "7f3c",
+					"controller": true,
+				}},
+			},
+			"status": map[string]interface{}{
+				"scanJobID":   "job:123",
+				"repository":  "library/nginx",
+				"digest":      "sha256:6c3c",
+				"generatedAt": "2024-04-02T09:30:00Z",
+				"severity":    "Critical",
+				"counts": map[string]interface{}{
+					"Unknown": 0.0, "Low": 0.0, "Medium": 1.0, "High": 1.0, "Critical": 1.0,
+				},
+				"vulnerabilities": []interface{}{
+					map[string]interface{}{"id": "CVE-2024-3094", "package": "xz", "version": "5.6.0",
+						"fixVersion": "5.6.2", "severity": "Critical"},
+					map[string]interface{}{"id": "CVE-2023-5363", "package": "openssl", "version": "3.0.11",
+						"fixVersion": "3.0.12", "severity": "High"},
+				},
+				"truncated": true,
+			},
+		}, server.patches[0].body)
+		assert.Equal(t, map[string]interface{}{"status": map[string]interface{}{
+			"phase": "Finished", "scanJobID": "job:123", "reportName": "nginx",
+		}}, server.patches[1].body)
+	})
+}
+
+func TestBasicAuth(t *testing.T) {
+	testCases := []struct {
+		name          string
+		config        string
+		expectedAuth  string
+		expectedError string
+	}{
+		{
+			name:         "Should return auth of registry host",
+			config:       `{"auths":{"core.harbor.domain":{"auth":"cm9ib3Q6czNjcmV0"}}}`,
+			expectedAuth: "cm9ib3Q6czNjcmV0",
+		},
+		{
+			name:         "Should encode username and password of registry URL",
+			config:       `{"auths":{"https://core.harbor.domain/v2/":{"username":"robot","password":"s3cret"}}}`,
+			expectedAuth: "cm9ib3Q6czNjcmV0",
+		},
+		{
+			name:          "Should return error when registry has no credentials",
+			config:        `{"auths":{"docker.io":{"auth":"cm9ib3Q6czNjcmV0"}}}`,
+			expectedError: "no credentials for registry core.harbor.domain",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			auth, err := basicAuth([]byte(tc.config), "core.harbor.domain")
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedAuth, auth)
+		})
+	}
+}
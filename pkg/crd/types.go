@@ -0,0 +1,115 @@
+// Package crd requests scans declaratively from within a Kubernetes cluster. Its controller watches ScanRequest
+// custom resources, enqueues a scan job for each of them like the REST API does, and records the result of the
+// scan job in a ScanReport custom resource of the same name, so that GitOps pipelines and other Kubernetes-native
+// tools request scans by applying manifests rather than by calling the API of the adapter.
+package crd
+
+import "time"
+
+const (
+	// Group is the API group of the custom resources.
+	Group = "scanner.khulnasoft.com"
+	// Version is the version of the custom resources.
+	Version = "v1alpha1"
+	// APIVersion is the apiVersion of the custom resources.
+	APIVersion = Group + "/" + Version
+
+	KindScanRequest = "ScanRequest"
+	KindScanReport  = "ScanReport"
+
+	// FieldManager is the manager of the fields of the custom resources written by the controller.
+	FieldManager = "scanner-tunnel"
+)
+
+// Phases of ScanRequests. ScanRequests without a phase have not been seen by the controller yet.
+const (
+	PhaseQueued   = "Queued"
+	PhasePending  = "Pending"
+	PhaseFinished = "Finished"
+	PhaseFailed   = "Failed"
+)
+
+// ObjectMeta is the subset of the metadata of Kubernetes objects used by the controller.
+type ObjectMeta struct {
+	Name            string           `json:"name"`
+	Namespace       string           `json:"namespace,omitempty"`
+	UID             string           `json:"uid,omitempty"`
+	OwnerReferences []OwnerReference `json:"ownerReferences,omitempty"`
+}
+
+// OwnerReference makes a ScanReport owned by its ScanRequest, hence deleted along with it.
+type OwnerReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	UID        string `json:"uid"`
+	Controller bool   `json:"controller"`
+}
+
+// ScanRequest requests a scan of an artifact.
+type ScanRequest struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   ObjectMeta        `json:"metadata"`
+	Spec       ScanRequestSpec   `json:"spec"`
+	Status     ScanRequestStatus `json:"status,omitempty"`
+}
+
+type ScanRequestSpec struct {
+	Registry RegistrySpec `json:"registry"`
+	Artifact ArtifactSpec `json:"artifact"`
+	// TimeoutSeconds extends the timeout of the scan, up to the maximum timeout configured for the adapter.
+	TimeoutSeconds int64 `json:"timeoutSeconds,omitempty"`
+}
+
+type RegistrySpec struct {
+	URL string `json:"url"`
+	// PullSecretName is the name of a kubernetes.io/dockerconfigjson secret of the namespace of the ScanRequest
+	// holding the credentials of the registry, if it requires any.
+	PullSecretName string `json:"pullSecretName,omitempty"`
+}
+
+type ArtifactSpec struct {
+	Repository string `json:"repository"`
+	Digest     string `json:"digest"`
+	MimeType   string `json:"mimeType,omitempty"`
+}
+
+type ScanRequestStatus struct {
+	Phase     string `json:"phase,omitempty"`
+	ScanJobID string `json:"scanJobID,omitempty"`
+	// Message explains why the scan failed.
+	Message string `json:"message,omitempty"`
+	// ReportName is the name of the ScanReport holding the result of the scan once it is finished.
+	ReportName string `json:"reportName,omitempty"`
+}
+
+// ScanReport holds the result of the scan requested by the ScanRequest of the same name.
+type ScanReport struct {
+	APIVersion string           `json:"apiVersion"`
+	Kind       string           `json:"kind"`
+	Metadata   ObjectMeta       `json:"metadata"`
+	Status     ScanReportStatus `json:"status"`
+}
+
+type ScanReportStatus struct {
+	ScanJobID   string    `json:"scanJobID"`
+	Repository  string    `json:"repository"`
+	Digest      string    `json:"digest"`
+	GeneratedAt time.Time `json:"generatedAt"`
+	// Severity is the highest severity of the vulnerabilities.
+	Severity string `json:"severity"`
+	// Counts are the numbers of vulnerabilities keyed by severity name.
+	Counts          map[string]int  `json:"counts"`
+	Vulnerabilities []Vulnerability `json:"vulnerabilities"`
+	// Truncated is true if the vulnerabilities are only the most severe ones of the report.
+	Truncated bool `json:"truncated"`
+}
+
+type Vulnerability struct {
+	ID         string `json:"id"`
+	Package    string `json:"package"`
+	Version    string `json:"version"`
+	FixVersion string `json:"fixVersion,omitempty"`
+	Severity   string `json:"severity"`
+}
@@ -0,0 +1,191 @@
+// Package enrich runs the configured hooks on transformed scan reports, so that organizations can inject
+// proprietary enrichment, e.g. threat intelligence in the vendor attributes of vulnerabilities, without forking
+// the adapter. A hook receives the report as JSON and returns the enriched report as JSON, which is validated
+// before it replaces the original report.
+package enrich
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/httpx"
+)
+
+// maxReportSize bounds the size of the reports returned by hooks.
+const maxReportSize = 64 << 20
+
+// Enricher wraps the Enrich method.
+// Enrich returns the given scan report enriched with additional data.
+type Enricher interface {
+	Enrich(ctx context.Context, report harbor.ScanReport) (harbor.ScanReport, error)
+}
+
+// hook runs a single hook, which returns the enriched report as JSON.
+type hook interface {
+	name() string
+	run(ctx context.Context, report []byte) ([]byte, error)
+}
+
+type chain struct {
+	hooks       []hook
+	timeout     time.Duration
+	failOnError bool
+}
+
+// NewEnricher constructs an Enricher running the configured hooks in order. Unless the configuration fails on
+// errors, a failed hook is logged and skipped, i.e. the report enriched by the previous hooks is kept.
+func NewEnricher(config etc.Enrichment) Enricher {
+	hooks := make([]hook, len(config.Hooks))
+	for i, h := range config.Hooks {
+		if etc.IsHTTPHook(h) {
+			hooks[i] = &httpHook{url: h, client: httpx.Client("enrichment_hook", 0)}
+		} else {
+			hooks[i] = &execHook{path: h}
+		}
+	}
+	return &chain{
+		hooks:       hooks,
+		timeout:     config.Timeout,
+		failOnError: config.FailOnError,
+	}
+}
+
+func (c *chain) Enrich(ctx context.Context, report harbor.ScanReport) (harbor.ScanReport, error) {
+	for _, h := range c.hooks {
+		enriched, err := c.enrich(ctx, h, report)
+		if err != nil {
+			err = fmt.Errorf("enrichment hook %s: %w", h.name(), err)
+			if c.failOnError {
+				return report, err
+			}
+			slog.Warn("Error while enriching scan report", slog.String("err", err.Error()))
+			continue
+		}
+		report = enriched
+	}
+	return report, nil
+}
+
+func (c *chain) enrich(ctx context.Context, h hook, report harbor.ScanReport) (harbor.ScanReport, error) {
+	in, err := json.Marshal(report)
+	if err != nil {
+		return report, fmt.Errorf("marshalling report: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	out, err := h.run(ctx, in)
+	if err != nil {
+		return report, err
+	}
+
+	var enriched harbor.ScanReport
+	decoder := json.NewDecoder(bytes.NewReader(out))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(&enriched); err != nil {
+		return report, fmt.Errorf("decoding report: %w", err)
+	}
+	if err = Validate(report, enriched); err != nil {
+		return report, err
+	}
+	return enriched, nil
+}
+
+// Validate returns an error if the given enriched report is not a valid enrichment of the given original report,
+// i.e. if it describes another artifact or has vulnerabilities which cannot be identified.
+func Validate(original, enriched harbor.ScanReport) error {
+	if enriched.Artifact.Repository != original.Artifact.Repository || enriched.Artifact.Digest != original.Artifact.Digest {
+		return errors.New("invalid report: artifact must not be changed")
+	}
+	for i, v := range enriched.Vulnerabilities {
+		if v.ID == "" || v.Pkg == "" {
+			return fmt.Errorf("invalid report: vulnerability %d must have an id and a package", i)
+		}
+	}
+	return nil
+}
+
+// execHook runs an executable, which reads the report from its standard input and writes the enriched report to
+// its standard output.
+type execHook struct {
+	path string
+}
+
+func (h *execHook) name() string {
+	return h.path
+}
+
+func (h *execHook) run(ctx context.Context, report []byte) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, h.path)
+	cmd.Stdin = bytes.NewReader(report)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("running hook: %w", ctx.Err())
+		}
+		return nil, fmt.Errorf("running hook: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if stdout.Len() > maxReportSize {
+		return nil, fmt.Errorf("report exceeds %d bytes", maxReportSize)
+	}
+	return stdout.Bytes(), nil
+}
+
+// httpHook posts the report to an HTTP endpoint, which responds with the enriched report.
+type httpHook struct {
+	url    string
+	client *http.Client
+}
+
+// name returns the URL of the endpoint without its path and query, which may embed secrets.
+func (h *httpHook) name() string {
+	scheme, rest, _ := strings.Cut(h.url, "://")
+	host, _, _ := strings.Cut(rest, "/")
+	if _, after, ok := strings.Cut(host, "@"); ok {
+		host = after
+	}
+	return scheme + "://" + host
+}
+
+func (h *httpHook) run(ctx context.Context, report []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(report))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := h.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("posting report: %w", err)
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxReportSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+	if len(body) > maxReportSize {
+		return nil, fmt.Errorf("report exceeds %d bytes", maxReportSize)
+	}
+	return body, nil
+}
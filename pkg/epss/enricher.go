@@ -0,0 +1,48 @@
+package epss
+
+import (
+	"context"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/enrich"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+)
+
+// VendorAttributeScore is the vendor attribute holding the EPSS score of a vulnerability, i.e. the probability of
+// its exploitation in the next 30 days, and VendorAttributePercentile the percentile of the score.
+const (
+	VendorAttributeScore      = "epss_score"
+	VendorAttributePercentile = "epss_percentile"
+)
+
+type enricher struct {
+	scores Scores
+}
+
+// NewEnricher constructs an Enricher, which adds the scores looked up with the given Scores to the vulnerabilities
+// of reports. Vulnerabilities which are not scored, e.g. GHSA advisories without CVE, are left as they are.
+func NewEnricher(scores Scores) enrich.Enricher {
+	return &enricher{scores: scores}
+}
+
+func (e *enricher) Enrich(_ context.Context, report harbor.ScanReport) (harbor.ScanReport, error) {
+	vulnerabilities := make([]harbor.VulnerabilityItem, len(report.Vulnerabilities))
+	copy(vulnerabilities, report.Vulnerabilities)
+
+	for i, v := range vulnerabilities {
+		score, ok := e.scores.Lookup(v.ID)
+		if !ok {
+			continue
+		}
+		// The vendor attributes are copied, as they are shared with the original report.
+		attributes := make(map[string]interface{}, len(v.VendorAttributes)+2)
+		for key, value := range v.VendorAttributes {
+			attributes[key] = value
+		}
+		attributes[VendorAttributeScore] = score.Probability
+		attributes[VendorAttributePercentile] = score.Percentile
+		vulnerabilities[i].VendorAttributes = attributes
+	}
+
+	report.Vulnerabilities = vulnerabilities
+	return report, nil
+}
@@ -0,0 +1,44 @@
+package epss
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+)
+
+// fakeScores returns the given scores.
+type fakeScores map[string]Score
+
+func (s fakeScores) Lookup(id string) (Score, bool) {
+	score, ok := s[id]
+	return score, ok
+}
+
+func TestEnricher_Enrich(t *testing.T) {
+	enricher := NewEnricher(fakeScores{
+		"CVE-2024-3094": {Probability: 0.84372, Percentile: 0.99254},
+	})
+	report := harbor.ScanReport{
+		Artifact: harbor.Artifact{Repository: "library/alpine", Digest: "sha256:917f"},
+		Vulnerabilities: []harbor.VulnerabilityItem{
+			{ID: "CVE-2024-3094", Pkg: "xz", VendorAttributes: map[string]interface{}{harbor.VendorAttributeEcosystem: "os"}},
+			{ID: "GHSA-xvch-5gv4-984h", Pkg: "minimist"},
+		},
+	}
+
+	enriched, err := enricher.Enrich(context.Background(), report)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		harbor.VendorAttributeEcosystem: "os",
+		VendorAttributeScore:            0.84372,
+		VendorAttributePercentile:       0.99254,
+	}, enriched.Vulnerabilities[0].VendorAttributes)
+	assert.Nil(t, enriched.Vulnerabilities[1].VendorAttributes)
+	assert.Equal(t, map[string]interface{}{harbor.VendorAttributeEcosystem: "os"},
+		report.Vulnerabilities[0].VendorAttributes, "original report must not be modified")
+}
@@ -0,0 +1,100 @@
+// Package epss enriches the CVEs of reports with their EPSS scores, i.e. the probabilities of their exploitation
+// in the next 30 days as estimated by the Exploit Prediction Scoring System of FIRST, so that findings can be
+// prioritized by their likelihood of exploitation rather than by their severity only. The daily dataset of FIRST
+// is downloaded in the background and cached, and is never downloaded unless configured.
+package epss
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Score is the EPSS score of a CVE.
+type Score struct {
+	// Probability is the probability of exploitation in the next 30 days, between 0 and 1.
+	Probability float64
+	// Percentile is the proportion of CVEs scored less than or equal to the CVE, between 0 and 1.
+	Percentile float64
+}
+
+// Dataset is a daily EPSS dataset, which scores all published CVEs.
+type Dataset struct {
+	ModelVersion string
+	// ScoreDate is the date the CVEs were scored on, as stated in the dataset, e.g. 2025-03-01T00:00:00+0000.
+	ScoreDate string
+	Scores    map[string]Score
+}
+
+// Parse parses the given EPSS dataset, which is either gzipped or plain CSV. Its first line optionally holds the
+// model version and the score date, e.g. #model_version:v2023.03.01,score_date:2025-03-01T00:00:00+0000, which
+// precedes the cve,epss,percentile header.
+func Parse(r io.Reader) (*Dataset, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("decompressing EPSS dataset: %w", err)
+		}
+		defer func() { _ = zr.Close() }()
+		br = bufio.NewReader(zr)
+	}
+
+	dataset := &Dataset{Scores: make(map[string]Score)}
+	// offset is the number of lines read before the CSV records, for errors to name the lines of the dataset.
+	offset := 0
+	if first, err := br.Peek(1); err == nil && first[0] == '#' {
+		line, err := br.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("reading EPSS dataset: %w", err)
+		}
+		offset = 1
+		for _, field := range strings.Split(strings.TrimSpace(strings.TrimPrefix(line, "#")), ",") {
+			key, value, _ := strings.Cut(field, ":")
+			switch key {
+			case "model_version":
+				dataset.ModelVersion = value
+			case "score_date":
+				dataset.ScoreDate = value
+			}
+		}
+	}
+
+	cr := csv.NewReader(br)
+	cr.FieldsPerRecord = 3
+	cr.ReuseRecord = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading EPSS dataset header: %w", err)
+	}
+	if header[0] != "cve" || header[1] != "epss" || header[2] != "percentile" {
+		return nil, fmt.Errorf("unexpected EPSS dataset header: %s", strings.Join(header, ","))
+	}
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading EPSS dataset: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+		line += offset
+		probability, err := strconv.ParseFloat(record[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid EPSS score of %s: %s", line, record[0], record[1])
+		}
+		percentile, err := strconv.ParseFloat(record[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid EPSS percentile of %s: %s", line, record[0], record[2])
+		}
+		dataset.Scores[strings.ToUpper(record[0])] = Score{Probability: probability, Percentile: percentile}
+	}
+	return dataset, nil
+}
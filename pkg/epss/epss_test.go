@@ -0,0 +1,93 @@
+package epss
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testDataset = `#model_version:v2023.03.01,score_date:2025-03-01T00:00:00+0000
+cve,epss,percentile
+CVE-2024-3094,0.84372,0.99254
+CVE-2023-44487,0.81589,0.99123
+CVE-1999-0001,0.0109,0.83526
+`
+
+func gzipped(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write([]byte(s))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestParse(t *testing.T) {
+	expected := &Dataset{
+		ModelVersion: "v2023.03.01",
+		ScoreDate:    "2025-03-01T00:00:00+0000",
+		Scores: map[string]Score{
+			"CVE-2024-3094":  {Probability: 0.84372, Percentile: 0.99254},
+			"CVE-2023-44487": {Probability: 0.81589, Percentile: 0.99123},
+			"CVE-1999-0001":  {Probability: 0.0109, Percentile: 0.83526},
+		},
+	}
+
+	t.Run("Should parse gzipped dataset", func(t *testing.T) {
+		dataset, err := Parse(bytes.NewReader(gzipped(t, testDataset)))
+		require.NoError(t, err)
+		assert.Equal(t, expected, dataset)
+	})
+
+	t.Run("Should parse plain CSV dataset", func(t *testing.T) {
+		dataset, err := Parse(strings.NewReader(testDataset))
+		require.NoError(t, err)
+		assert.Equal(t, expected, dataset)
+	})
+
+	t.Run("Should parse dataset without model version and score date", func(t *testing.T) {
+		dataset, err := Parse(strings.NewReader("cve,epss,percentile\ncve-2024-3094,0.84372,0.99254\n"))
+		require.NoError(t, err)
+		assert.Equal(t, &Dataset{
+			Scores: map[string]Score{"CVE-2024-3094": {Probability: 0.84372, Percentile: 0.99254}},
+		}, dataset)
+	})
+
+	testCases := []struct {
+		name          string
+		dataset       string
+		expectedError string
+	}{
+		{
+			name:          "Should return error when header is unexpected",
+			dataset:       "cve,score,percentile\n",
+			expectedError: "unexpected EPSS dataset header: cve,score,percentile",
+		},
+		{
+			name:          "Should return error with line of invalid score",
+			dataset:       "#model_version:v2023.03.01\ncve,epss,percentile\nCVE-2024-3094,0.84372,0.99254\nCVE-2023-44487,high,0.99123\n",
+			expectedError: "line 4: invalid EPSS score of CVE-2023-44487: high",
+		},
+		{
+			name:          "Should return error with line of invalid percentile",
+			dataset:       "cve,epss,percentile\nCVE-2024-3094,0.84372,\n",
+			expectedError: "line 2: invalid EPSS percentile of CVE-2024-3094: ",
+		},
+		{
+			name:          "Should return error when dataset is empty",
+			dataset:       "",
+			expectedError: "reading EPSS dataset header: EOF",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tc.dataset))
+			assert.EqualError(t, err, tc.expectedError)
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package epss
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/httpx"
+)
+
+// cacheFile is the name of the file the dataset downloaded last is cached in, within the configured cache dir.
+const cacheFile = "epss_scores.csv.gz"
+
+// downloadTimeout bounds the download of the dataset, which is a few MB gzipped.
+const downloadTimeout = 5 * time.Minute
+
+// maxDatasetSize bounds the size of downloaded datasets.
+const maxDatasetSize = 256 << 20
+
+// Scores wraps the Lookup method.
+// Lookup returns the EPSS score of the CVE with the given ID, and false if it is not scored.
+type Scores interface {
+	Lookup(id string) (Score, bool)
+}
+
+// Loader loads the EPSS dataset, and looks up scores in the dataset loaded last.
+type Loader interface {
+	Scores
+	// Load loads the cached dataset if it was downloaded within the refresh interval, or downloads the dataset
+	// otherwise. The cached dataset is loaded regardless of its age if no dataset is loaded and the download fails.
+	Load(ctx context.Context) error
+	// Run downloads the dataset at the configured interval until the given context is done. The dataset loaded
+	// last is kept when a download fails.
+	Run(ctx context.Context)
+}
+
+type loader struct {
+	config etc.EPSS
+	client *http.Client
+	now    func() time.Time
+
+	mu      sync.RWMutex
+	dataset *Dataset
+}
+
+// NewLoader constructs a Loader of the configured dataset. No score is looked up until a dataset is loaded.
+func NewLoader(config etc.EPSS) Loader {
+	return &loader{
+		config: config,
+		client: httpx.Client("epss", downloadTimeout),
+		now:    time.Now,
+	}
+}
+
+func (l *loader) Lookup(id string) (Score, bool) {
+	l.mu.RLock()
+	dataset := l.dataset
+	l.mu.RUnlock()
+
+	if dataset == nil {
+		return Score{}, false
+	}
+	score, ok := dataset.Scores[strings.ToUpper(id)]
+	return score, ok
+}
+
+func (l *loader) loaded() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.dataset != nil
+}
+
+func (l *loader) Load(ctx context.Context) error {
+	if !l.loaded() {
+		if info, err := os.Stat(l.cacheFile()); err == nil && l.now().Sub(info.ModTime()) < l.config.RefreshInterval {
+			err = l.loadCache()
+			if err == nil {
+				return nil
+			}
+			slog.Warn("Error while loading cached EPSS dataset, downloading it", slog.String("err", err.Error()))
+		}
+	}
+
+	err := l.download(ctx)
+	if err != nil && !l.loaded() {
+		if cacheErr := l.loadCache(); cacheErr == nil {
+			slog.Warn("Error while downloading EPSS dataset, using the cached dataset", slog.String("err", err.Error()))
+			return nil
+		}
+	}
+	return err
+}
+
+func (l *loader) cacheFile() string {
+	return filepath.Join(l.config.CacheDir, cacheFile)
+}
+
+func (l *loader) loadCache() error {
+	f, err := os.Open(l.cacheFile())
+	if err != nil {
+		return fmt.Errorf("reading cached EPSS dataset: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	dataset, err := Parse(f)
+	if err != nil {
+		return err
+	}
+	l.apply(dataset)
+	return nil
+}
+
+// download downloads the dataset to a temporary file, which replaces the cached dataset with a rename once it is
+// parsed, so that the cache never holds a partially downloaded or invalid dataset.
+func (l *loader) download(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.config.URL, nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	res, err := l.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("downloading EPSS dataset: %w", err)
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("downloading EPSS dataset: unexpected status %d", res.StatusCode)
+	}
+
+	if err = os.MkdirAll(l.config.CacheDir, 0o755); err != nil {
+		return fmt.Errorf("creating EPSS cache dir: %w", err)
+	}
+	f, err := os.CreateTemp(l.config.CacheDir, cacheFile+".*")
+	if err != nil {
+		return fmt.Errorf("writing EPSS dataset: %w", err)
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	n, err := io.Copy(f, io.LimitReader(res.Body, maxDatasetSize+1))
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("downloading EPSS dataset: %w", err)
+	}
+	if n > maxDatasetSize {
+		_ = f.Close()
+		return fmt.Errorf("downloading EPSS dataset: exceeds %d bytes", maxDatasetSize)
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("writing EPSS dataset: %w", err)
+	}
+	dataset, err := Parse(f)
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("writing EPSS dataset: %w", closeErr)
+	}
+	if err != nil {
+		return err
+	}
+	if err = os.Rename(f.Name(), l.cacheFile()); err != nil {
+		return fmt.Errorf("writing EPSS dataset: %w", err)
+	}
+	l.apply(dataset)
+	return nil
+}
+
+func (l *loader) apply(dataset *Dataset) {
+	l.mu.Lock()
+	l.dataset = dataset
+	l.mu.Unlock()
+
+	slog.Info("Loaded EPSS dataset", slog.String("model_version", dataset.ModelVersion),
+		slog.String("score_date", dataset.ScoreDate), slog.Int("cves", len(dataset.Scores)))
+}
+
+func (l *loader) Run(ctx context.Context) {
+	ticker := time.NewTicker(l.config.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.download(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				slog.Error("Error while refreshing EPSS dataset, keeping the dataset loaded last",
+					slog.String("err", err.Error()))
+			}
+		}
+	}
+}
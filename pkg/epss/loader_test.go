@@ -0,0 +1,134 @@
+package epss
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+)
+
+const staleDataset = `cve,epss,percentile
+CVE-2024-3094,0.00043,0.0918
+`
+
+func TestLoader_Load(t *testing.T) {
+	dataset := gzipped(t, testDataset)
+	var downloads atomic.Int32
+	var unavailable atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unavailable.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		downloads.Add(1)
+		_, _ = w.Write(dataset)
+	}))
+	defer server.Close()
+
+	newLoader := func(t *testing.T, cacheDir string) Loader {
+		t.Helper()
+		return NewLoader(etc.EPSS{
+			Enabled:         true,
+			URL:             server.URL + "/epss_scores-current.csv.gz",
+			RefreshInterval: 24 * time.Hour,
+			CacheDir:        cacheDir,
+		})
+	}
+	writeCache := func(t *testing.T, cacheDir string, age time.Duration) {
+		t.Helper()
+		file := filepath.Join(cacheDir, cacheFile)
+		require.NoError(t, os.WriteFile(file, gzipped(t, staleDataset), 0o644))
+		modTime := time.Now().Add(-age)
+		require.NoError(t, os.Chtimes(file, modTime, modTime))
+	}
+
+	t.Run("Should download and cache dataset", func(t *testing.T) {
+		unavailable.Store(false)
+		downloads.Store(0)
+		cacheDir := filepath.Join(t.TempDir(), "epss")
+		loader := newLoader(t, cacheDir)
+
+		_, ok := loader.Lookup("CVE-2024-3094")
+		assert.False(t, ok)
+
+		require.NoError(t, loader.Load(context.Background()))
+		score, ok := loader.Lookup("cve-2024-3094")
+		assert.True(t, ok)
+		assert.Equal(t, Score{Probability: 0.84372, Percentile: 0.99254}, score)
+		assert.Equal(t, int32(1), downloads.Load())
+
+		cached, err := os.ReadFile(filepath.Join(cacheDir, cacheFile))
+		require.NoError(t, err)
+		assert.Equal(t, dataset, cached)
+	})
+
+	t.Run("Should load cached dataset downloaded within refresh interval", func(t *testing.T) {
+		unavailable.Store(false)
+		downloads.Store(0)
+		cacheDir := t.TempDir()
+		writeCache(t, cacheDir, time.Hour)
+		loader := newLoader(t, cacheDir)
+
+		require.NoError(t, loader.Load(context.Background()))
+		score, ok := loader.Lookup("CVE-2024-3094")
+		assert.True(t, ok)
+		assert.Equal(t, Score{Probability: 0.00043, Percentile: 0.0918}, score)
+		assert.Equal(t, int32(0), downloads.Load())
+	})
+
+	t.Run("Should download dataset when cached dataset is stale", func(t *testing.T) {
+		unavailable.Store(false)
+		downloads.Store(0)
+		cacheDir := t.TempDir()
+		writeCache(t, cacheDir, 25*time.Hour)
+		loader := newLoader(t, cacheDir)
+
+		require.NoError(t, loader.Load(context.Background()))
+		score, _ := loader.Lookup("CVE-2024-3094")
+		assert.Equal(t, Score{Probability: 0.84372, Percentile: 0.99254}, score)
+		assert.Equal(t, int32(1), downloads.Load())
+	})
+
+	t.Run("Should fall back to stale cached dataset when download fails", func(t *testing.T) {
+		unavailable.Store(true)
+		cacheDir := t.TempDir()
+		writeCache(t, cacheDir, 72*time.Hour)
+		loader := newLoader(t, cacheDir)
+
+		require.NoError(t, loader.Load(context.Background()))
+		score, ok := loader.Lookup("CVE-2024-3094")
+		assert.True(t, ok)
+		assert.Equal(t, Score{Probability: 0.00043, Percentile: 0.0918}, score)
+	})
+
+	t.Run("Should return error when download fails without cached dataset", func(t *testing.T) {
+		unavailable.Store(true)
+		loader := newLoader(t, t.TempDir())
+
+		err := loader.Load(context.Background())
+		assert.EqualError(t, err, "downloading EPSS dataset: unexpected status 503")
+		_, ok := loader.Lookup("CVE-2024-3094")
+		assert.False(t, ok)
+	})
+
+	t.Run("Should keep dataset loaded last when download fails", func(t *testing.T) {
+		unavailable.Store(false)
+		cacheDir := t.TempDir()
+		loader := newLoader(t, cacheDir)
+		require.NoError(t, loader.Load(context.Background()))
+
+		unavailable.Store(true)
+		assert.EqualError(t, loader.Load(context.Background()), "downloading EPSS dataset: unexpected status 503")
+		_, ok := loader.Lookup("CVE-2024-3094")
+		assert.True(t, ok)
+	})
+}
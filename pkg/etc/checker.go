@@ -1,10 +1,17 @@
 package etc
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/url"
 	"os"
+	"strings"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
 )
 
 // Check checks config values to fail fast in case of any problems
@@ -34,6 +41,467 @@ func Check(config Config) error {
 		return err
 	}
 
+	if config.Tunnel.MaxTimeout < config.Tunnel.Timeout {
+		return errors.New("tunnel max timeout must not be less than tunnel timeout")
+	}
+
+	if config.Tunnel.MaxRegistryConnections < 0 {
+		return errors.New("tunnel max registry connections must not be negative")
+	}
+
+	if config.Tunnel.RegistryAdaptiveConcurrency && config.Tunnel.MaxRegistryConnections == 0 {
+		return errors.New("tunnel max registry connections must be set with adaptive registry concurrency")
+	}
+
+	if config.Tunnel.RegistryThrottleRetries < 0 {
+		return errors.New("tunnel registry throttle retries must not be negative")
+	}
+
+	if config.Tunnel.MaxPullBandwidth < 0 {
+		return errors.New("tunnel max pull bandwidth must not be negative")
+	}
+
+	if config.Tunnel.SeverityThreshold != "" {
+		if _, err := harbor.ParseSeverity(config.Tunnel.SeverityThreshold); err != nil {
+			return fmt.Errorf("tunnel severity threshold: %w", err)
+		}
+	}
+
+	switch config.Tunnel.CacheMode {
+	case "", "shared":
+	case "isolated":
+		// Isolated cache dirs start empty, hence Tunnel must download the vulnerability database into each of them.
+		if config.Tunnel.SkipUpdate {
+			return errors.New("tunnel isolated cache mode requires vulnerability database updates")
+		}
+	default:
+		return fmt.Errorf("unsupported tunnel cache mode: %s", config.Tunnel.CacheMode)
+	}
+
+	switch config.Tunnel.ExecutionDriver {
+	case "", "process":
+	case "container", "kubernetes":
+		if config.Tunnel.SandboxImage == "" {
+			return fmt.Errorf("tunnel sandbox image must not be blank with the %s execution driver",
+				config.Tunnel.ExecutionDriver)
+		}
+		if config.Tunnel.ExecutionDriver == "kubernetes" && config.Tunnel.KubernetesVolumeClaim == "" {
+			return errors.New("tunnel kubernetes volume claim must not be blank with the kubernetes execution driver")
+		}
+	default:
+		return fmt.Errorf("unsupported tunnel execution driver: %s", config.Tunnel.ExecutionDriver)
+	}
+
+	if config.Tunnel.Parallel < 0 {
+		return errors.New("tunnel parallel must not be negative")
+	}
+
+	if config.Tunnel.DBRepairMaxFailures < 0 {
+		return errors.New("tunnel DB repair max failures must not be negative")
+	}
+
+	for _, entry := range config.Tunnel.RegistryHeaders {
+		if name, _, ok := strings.Cut(entry, "="); !ok || name == "" {
+			return fmt.Errorf("registry header must be in the name=value form: %s", entry)
+		}
+	}
+	if len(config.Tunnel.RegistryHeaders) > 0 && config.Tunnel.ExecutionDriver != "" && config.Tunnel.ExecutionDriver != "process" {
+		return fmt.Errorf("registry headers cannot be added to the pulls of the %s execution driver",
+			config.Tunnel.ExecutionDriver)
+	}
+
+	// The throttling proxy listens on the loopback interface of the adapter, which sandboxes cannot reach.
+	if config.Tunnel.MaxPullBandwidth > 0 && config.Tunnel.ExecutionDriver != "" && config.Tunnel.ExecutionDriver != "process" {
+		return fmt.Errorf("tunnel max pull bandwidth cannot be enforced with the %s execution driver",
+			config.Tunnel.ExecutionDriver)
+	}
+
+	if _, err := harbor.NewRepositoryNormalizer(config.Tunnel.RepositoryNormalization, config.Tunnel.ProxyCacheUpstreams); err != nil {
+		return err
+	}
+
+	if config.Tunnel.ExpectedSHA256 != "" {
+		if b, err := hex.DecodeString(config.Tunnel.ExpectedSHA256); err != nil || len(b) != sha256.Size {
+			return errors.New("tunnel expected SHA-256 must be a hex-encoded SHA-256 checksum")
+		}
+		if config.Tunnel.ExecutionDriver != "" && config.Tunnel.ExecutionDriver != "process" {
+			return fmt.Errorf("tunnel expected SHA-256 cannot be verified with the %s execution driver",
+				config.Tunnel.ExecutionDriver)
+		}
+	}
+
+	if config.Tunnel.ServerURL != "" {
+		if u, err := url.Parse(config.Tunnel.ServerURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid tunnel server URL: %s", config.Tunnel.ServerURL)
+		}
+		// The version reported in the client/server mode is the version of the server.
+		if config.Tunnel.ExpectedVersion != "" {
+			return errors.New("tunnel expected version cannot be verified with a tunnel server")
+		}
+	}
+
+	if config.Store.HistorySize < 0 {
+		return errors.New("store history size must not be negative")
+	}
+
+	if config.Store.SpillDir != "" {
+		if config.Store.SpillThreshold <= 0 {
+			return errors.New("store spill threshold must be positive")
+		}
+		if config.Store.SpillTTL <= 0 {
+			return errors.New("store spill TTL must be positive")
+		}
+		if err := ensureDirExists(config.Store.SpillDir, "store spill dir"); err != nil {
+			return err
+		}
+	}
+
+	key, err := config.Store.DecodeCredentialsKey()
+	if err != nil {
+		return fmt.Errorf("decoding store credentials key: %w", err)
+	}
+	if key != nil && len(key) != 32 {
+		return fmt.Errorf("store credentials key must be 32 bytes: %d", len(key))
+	}
+
+	if config.Store.MigrationDualWrite && config.Store.MigrationTarget == "" {
+		return errors.New("store migration target must not be blank in dual-write mode")
+	}
+
+	if config.Store.Backend == "postgres" || config.Store.MigrationTarget == "postgres" {
+		if config.PostgresStore.URL == "" {
+			return errors.New("postgres store URL must not be blank")
+		}
+		if config.PostgresStore.ScanJobTTL <= 0 {
+			return errors.New("postgres store scan job TTL must be positive")
+		}
+		if config.PostgresStore.ReaperInterval <= 0 {
+			return errors.New("postgres store reaper interval must be positive")
+		}
+	}
+
+	if config.Store.MigrationTarget == "memory" {
+		return errors.New("store migration target must not be memory, which is lost on restart")
+	}
+	if config.Store.Backend == "memory" && (config.MemoryStore.ScanJobTTL <= 0 || config.MemoryStore.MaxScanJobs <= 0) {
+		return errors.New("memory store scan job TTL and max scan jobs must be positive")
+	}
+
+	if config.API.MaxConnections < 0 {
+		return errors.New("API server max connections must not be negative")
+	}
+
+	switch config.Auth.Provider {
+	case "static":
+		if len(config.Auth.StaticTokens) == 0 {
+			return errors.New("auth static tokens must not be empty")
+		}
+	case "oidc":
+		if config.Auth.OIDCIssuerURL == "" {
+			return errors.New("auth OIDC issuer URL must not be blank")
+		}
+		if config.Auth.OIDCAudience == "" {
+			return errors.New("auth OIDC audience must not be blank")
+		}
+	}
+
+	switch config.Auth.SignatureScheme {
+	case "", "none":
+	case "hmac", "jwt":
+		if config.Auth.SignatureSecret == "" {
+			return errors.New("auth signature secret must not be blank")
+		}
+		if config.Auth.SignatureMaxAge <= 0 {
+			return errors.New("auth signature max age must be positive")
+		}
+	default:
+		return fmt.Errorf("unsupported auth signature scheme: %s", config.Auth.SignatureScheme)
+	}
+
+	if config.Impact.WebhookURL != "" {
+		if _, err := harbor.ParseSeverity(config.Impact.WebhookMinSeverity); err != nil {
+			return fmt.Errorf("impact webhook min severity: %w", err)
+		}
+	}
+
+	if config.CleanScans.WebhookURL != "" {
+		if u, err := url.Parse(config.CleanScans.WebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid clean scan webhook URL: %s", config.CleanScans.WebhookURL)
+		}
+	}
+
+	if config.Notifications.SlackWebhookURL != "" {
+		if u, err := url.Parse(config.Notifications.SlackWebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid Slack notifications webhook URL: %s", config.Notifications.SlackWebhookURL)
+		}
+	}
+	if config.Notifications.TeamsWebhookURL != "" {
+		if u, err := url.Parse(config.Notifications.TeamsWebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid Teams notifications webhook URL: %s", config.Notifications.TeamsWebhookURL)
+		}
+	}
+	if config.Notifications.IsEnabled() {
+		if _, err := harbor.ParseSeverity(config.Notifications.MinSeverity); err != nil {
+			return fmt.Errorf("notifications min severity: %w", err)
+		}
+	}
+
+	if config.Impact.OnDBUpdate && config.Impact.DBPollInterval <= 0 {
+		return errors.New("impact assessment DB poll interval must be positive")
+	}
+
+	if config.Impact.OnDBUpdate && !config.Tunnel.SBOMEnabled {
+		return errors.New("impact assessment on DB update requires SBOMs to be enabled")
+	}
+
+	switch config.Allowlist.Mode {
+	case "", "drop", "mark":
+	default:
+		return fmt.Errorf("unsupported allowlist mode: %s", config.Allowlist.Mode)
+	}
+	if config.Allowlist.File != "" && !fileExists(config.Allowlist.File) {
+		return fmt.Errorf("allowlist file does not exist: %s", config.Allowlist.File)
+	}
+
+	if config.VEX.Path != "" {
+		if _, err := os.Stat(config.VEX.Path); err != nil {
+			return fmt.Errorf("VEX path does not exist: %s", config.VEX.Path)
+		}
+	}
+	if config.VEX.OCIRef != "" {
+		if u, err := url.Parse(config.VEX.OCIRef); err != nil || u.Scheme != "oci" || u.Host == "" || strings.Trim(u.Path, "/") == "" {
+			return fmt.Errorf("invalid VEX OCI reference: %s", config.VEX.OCIRef)
+		}
+	}
+
+	if config.Policy.File != "" && !fileExists(config.Policy.File) {
+		return fmt.Errorf("policy file does not exist: %s", config.Policy.File)
+	}
+
+	if config.Policy.BundleURL != "" {
+		if u, err := url.Parse(config.Policy.BundleURL); err != nil || (u.Scheme != "oci" && u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid policy bundle URL: %s", config.Policy.BundleURL)
+		}
+		if config.Policy.File != "" || config.Tunnel.IgnorePolicy != "" {
+			return errors.New("policy bundle must not be combined with a policy file or a Tunnel ignore policy")
+		}
+		if !fileExists(config.Policy.BundlePublicKey) {
+			return fmt.Errorf("policy bundle public key does not exist: %s", config.Policy.BundlePublicKey)
+		}
+		if config.Policy.BundleRefreshInterval <= 0 {
+			return errors.New("policy bundle refresh interval must be positive")
+		}
+	}
+	if config.Policy.MaxExceptionDuration < 0 {
+		return errors.New("policy max exception duration must not be negative")
+	}
+
+	if config.Feature.FlagsFile != "" && !fileExists(config.Feature.FlagsFile) {
+		return fmt.Errorf("feature flags file does not exist: %s", config.Feature.FlagsFile)
+	}
+
+	for _, entry := range config.Tunnel.RegistryCABundles {
+		host, path, ok := strings.Cut(entry, "=")
+		if !ok || host == "" || path == "" {
+			return fmt.Errorf("registry CA bundle must be in the host=path form: %s", entry)
+		}
+		if !fileExists(path) {
+			return fmt.Errorf("registry CA bundle file does not exist: %s", path)
+		}
+	}
+
+	if config.Shadow.Percentage < 0 || config.Shadow.Percentage > 100 {
+		return fmt.Errorf("shadow percentage must be between 0 and 100: %d", config.Shadow.Percentage)
+	}
+
+	if config.Shadow.IsEnabled() {
+		if config.Shadow.Concurrency < 1 {
+			return errors.New("shadow concurrency must be positive")
+		}
+		if config.Shadow.Executable == "" && config.Shadow.SandboxImage == "" {
+			return errors.New("shadow tunnel executable or sandbox image must not be blank")
+		}
+		if err := ensureDirExists(config.Shadow.CacheDir, "shadow tunnel cache dir"); err != nil {
+			return err
+		}
+	}
+
+	if len(config.Enrichment.Hooks) > 0 && config.Enrichment.Timeout <= 0 {
+		return errors.New("enrichment timeout must be positive")
+	}
+
+	for _, hook := range config.Enrichment.Hooks {
+		if !IsHTTPHook(hook) && !fileExists(hook) {
+			return fmt.Errorf("enrichment hook does not exist: %s", hook)
+		}
+	}
+
+	if config.OPA.IsEnabled() {
+		if config.OPA.URL != "" && config.OPA.PolicyPath != "" {
+			return errors.New("OPA URL and policy path are mutually exclusive")
+		}
+		if config.OPA.URL != "" && !IsHTTPHook(config.OPA.URL) {
+			return fmt.Errorf("invalid OPA URL: %s", config.OPA.URL)
+		}
+		if config.OPA.PolicyPath != "" {
+			if _, err := os.Stat(config.OPA.PolicyPath); err != nil {
+				return fmt.Errorf("OPA policy path does not exist: %s", config.OPA.PolicyPath)
+			}
+		}
+		if config.OPA.Timeout <= 0 {
+			return errors.New("OPA timeout must be positive")
+		}
+	}
+
+	if config.NVD.Enabled {
+		if _, err := url.ParseRequestURI(config.NVD.URL); err != nil {
+			return fmt.Errorf("invalid NVD URL: %w", err)
+		}
+		if config.NVD.Timeout <= 0 {
+			return errors.New("NVD timeout must be positive")
+		}
+	}
+
+	if config.EPSS.Enabled {
+		if _, err := url.ParseRequestURI(config.EPSS.URL); err != nil {
+			return fmt.Errorf("invalid EPSS URL: %w", err)
+		}
+		if config.EPSS.RefreshInterval <= 0 {
+			return errors.New("EPSS refresh interval must be positive")
+		}
+	}
+
+	if config.Audit.IsEnabled() {
+		if err := checkAudit(config.Audit); err != nil {
+			return err
+		}
+	}
+
+	if config.Telemetry.Enabled {
+		if u, err := url.Parse(config.Telemetry.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid telemetry endpoint: %s", config.Telemetry.Endpoint)
+		}
+		if config.Telemetry.Interval < time.Hour {
+			return errors.New("telemetry interval must be at least 1h")
+		}
+	}
+
+	if config.AccessLog.Enabled {
+		if config.AccessLog.SampleRatio < 0 || config.AccessLog.SampleRatio > 1 {
+			return fmt.Errorf("access log sample ratio must be between 0 and 1: %g", config.AccessLog.SampleRatio)
+		}
+		if config.AccessLog.SlowThreshold < 0 {
+			return errors.New("access log slow threshold must not be negative")
+		}
+	}
+
+	if config.Tracing.Enabled {
+		if u, err := url.Parse(config.Tracing.OTLPEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid tracing OTLP endpoint: %s", config.Tracing.OTLPEndpoint)
+		}
+		if config.Tracing.SampleRatio < 0 || config.Tracing.SampleRatio > 1 {
+			return fmt.Errorf("tracing sample ratio must be between 0 and 1: %g", config.Tracing.SampleRatio)
+		}
+		for _, entry := range config.Tracing.OTLPHeaders {
+			if name, _, ok := strings.Cut(entry, "="); !ok || name == "" {
+				return fmt.Errorf("tracing OTLP header must be in the name=value form: %s", entry)
+			}
+		}
+	}
+
+	if config.Connectivity.Enabled && (config.Connectivity.Interval <= 0 || config.Connectivity.Timeout <= 0 ||
+		config.Connectivity.RegistryWindow <= 0) {
+		return errors.New("connectivity probe interval, timeout and registry window must be positive")
+	}
+
+	if config.GRPC.IsEnabled() && config.GRPC.WatchPollInterval <= 0 {
+		return errors.New("gRPC watch poll interval must be positive")
+	}
+
+	if config.CRD.Enabled {
+		if config.CRD.PollInterval <= 0 {
+			return errors.New("CRD poll interval must be positive")
+		}
+		if config.CRD.MaxVulnerabilities < 0 {
+			return errors.New("CRD max vulnerabilities must not be negative")
+		}
+	}
+
+	if config.SLO.Objective < 0 || config.SLO.Objective >= 100 {
+		return fmt.Errorf("SLO objective must be between 0 and 100: %g", config.SLO.Objective)
+	}
+
+	if config.SLO.IsEnabled() {
+		if config.SLO.Latency <= 0 {
+			return errors.New("SLO latency must be positive")
+		}
+		if config.SLO.Window <= 0 {
+			return errors.New("SLO window must be positive")
+		}
+		if config.RedisStore.ScanJobTTL > 0 && config.SLO.Window > config.RedisStore.ScanJobTTL {
+			return fmt.Errorf("SLO window must not exceed scan job TTL: %s", config.RedisStore.ScanJobTTL)
+		}
+		if config.SLO.BurnRateThreshold <= 0 {
+			return errors.New("SLO burn rate threshold must be positive")
+		}
+	}
+
+	switch config.JobQueue.Envelope {
+	case "", "json", "zstd", "id":
+	default:
+		return fmt.Errorf("unsupported job queue envelope: %s", config.JobQueue.Envelope)
+	}
+	if config.JobQueue.Envelope == "id" && config.Store.CredentialsKey == "" {
+		return errors.New("store credentials key must be set with id job queue envelope")
+	}
+	if config.JobQueue.EnvelopeVersion < 0 || config.JobQueue.EnvelopeVersion > 2 {
+		return fmt.Errorf("unsupported job queue envelope version: %d", config.JobQueue.EnvelopeVersion)
+	}
+
+	if config.JobQueue.HeartbeatInterval > 0 && config.JobQueue.StallTimeout <= config.JobQueue.HeartbeatInterval {
+		return fmt.Errorf("job queue stall timeout %s must be greater than heartbeat interval %s",
+			config.JobQueue.StallTimeout, config.JobQueue.HeartbeatInterval)
+	}
+
+	if config.JobQueue.FailedDigestThreshold < 0 {
+		return errors.New("job queue failed digest threshold must not be negative")
+	}
+	if config.JobQueue.FailedDigestThreshold > 0 && config.JobQueue.FailedDigestCooldown <= 0 {
+		return errors.New("job queue failed digest cooldown must be positive")
+	}
+
+	if config.JobQueue.MaxBacklog < 0 {
+		return errors.New("job queue max backlog must not be negative")
+	}
+
+	switch config.JobQueueDriver() {
+	case "redis", "local":
+	default:
+		return fmt.Errorf("unsupported job queue driver: %s", config.JobQueue.Driver)
+	}
+	if !config.RequiresRedis() {
+		// SBOMs and the digest indexes are kept in Redis whatever the store backend.
+		if config.Tunnel.SBOMEnabled {
+			return errors.New("tunnel SBOMs require Redis, which is used by neither the store nor the job queue")
+		}
+		if config.JobQueue.DigestReuseWindow > 0 || config.JobQueue.FailedDigestThreshold > 0 {
+			return errors.New("job queue digest reuse and failed digests require Redis, which is used by neither the store nor the job queue")
+		}
+	}
+
+	if config.HarborWebhook.IsEnabled() {
+		if config.HarborWebhook.RegistryURL != "" && !IsHTTPHook(config.HarborWebhook.RegistryURL) {
+			return fmt.Errorf("invalid Harbor webhook registry URL: %s", config.HarborWebhook.RegistryURL)
+		}
+		if (config.HarborWebhook.RegistryUsername == "") != (config.HarborWebhook.RegistryPassword == "") {
+			return errors.New("Harbor webhook registry username and password must be set together")
+		}
+		if config.HarborWebhook.RegistryUsername != "" && config.HarborWebhook.RegistryURL == "" {
+			// Otherwise the credentials would be sent to whichever host the events name.
+			return errors.New("Harbor webhook registry URL must be set with registry credentials")
+		}
+	}
+
 	if config.API.IsTLSEnabled() {
 		if !fileExists(config.API.TLSCertificate) {
 			return fmt.Errorf("TLS certificate file does not exist: %s", config.API.TLSCertificate)
@@ -50,6 +518,62 @@ func Check(config Config) error {
 		}
 	}
 
+	if err := checkRedisTLS(config.RedisPool); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func checkAudit(config Audit) error {
+	if config.BufferSize <= 0 || config.BatchSize <= 0 {
+		return errors.New("audit buffer size and batch size must be positive")
+	}
+	if config.FlushInterval <= 0 {
+		return errors.New("audit flush interval must be positive")
+	}
+
+	switch config.Sink {
+	case "kafka":
+		if _, err := url.ParseRequestURI(config.KafkaRESTURL); err != nil {
+			return fmt.Errorf("invalid audit Kafka REST URL: %w", err)
+		}
+		if config.KafkaTopic == "" {
+			return errors.New("audit Kafka topic must not be blank")
+		}
+	case "s3":
+		if config.S3Endpoint != "" {
+			if _, err := url.ParseRequestURI(config.S3Endpoint); err != nil {
+				return fmt.Errorf("invalid audit S3 endpoint: %w", err)
+			}
+		}
+		if config.S3Bucket == "" || config.S3Region == "" {
+			return errors.New("audit S3 bucket and region must not be blank")
+		}
+		if config.S3AccessKeyID == "" || config.S3SecretAccessKey == "" {
+			return errors.New("audit S3 access key must not be blank")
+		}
+	default:
+		return fmt.Errorf("unsupported audit sink: %s", config.Sink)
+	}
+	return nil
+}
+
+func checkRedisTLS(config RedisPool) error {
+	if !config.TLSEnabled {
+		if config.TLSCABundle != "" || config.TLSCertificate != "" || config.TLSKey != "" || config.TLSInsecureSkipVerify {
+			return errors.New("redis TLS options require redis TLS to be enabled")
+		}
+		return nil
+	}
+	if (config.TLSCertificate == "") != (config.TLSKey == "") {
+		return errors.New("redis TLS certificate and key must be set together")
+	}
+	for _, file := range []string{config.TLSCABundle, config.TLSCertificate, config.TLSKey} {
+		if file != "" && !fileExists(file) {
+			return fmt.Errorf("redis TLS file does not exist: %s", file)
+		}
+	}
 	return nil
 }
 
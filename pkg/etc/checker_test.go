@@ -5,6 +5,7 @@ import (
 	"os"
 	"path"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -71,6 +72,1339 @@ func TestCheck(t *testing.T) {
 		assert.NoError(t, err)
 	})
 
+	t.Run("Should return error when max registry connections is negative", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{Tunnel: Tunnel{
+			CacheDir:               path.Join(tempDir, "cache"),
+			ReportsDir:             path.Join(tempDir, "reports"),
+			MaxRegistryConnections: -1,
+		}})
+
+		assert.EqualError(t, err, "tunnel max registry connections must not be negative")
+	})
+
+	t.Run("Should return error when adaptive registry concurrency has no maximum", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{Tunnel: Tunnel{
+			CacheDir:                    path.Join(tempDir, "cache"),
+			ReportsDir:                  path.Join(tempDir, "reports"),
+			RegistryAdaptiveConcurrency: true,
+		}})
+
+		assert.EqualError(t, err, "tunnel max registry connections must be set with adaptive registry concurrency")
+	})
+
+	t.Run("Should return error when max timeout is less than timeout", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{Tunnel: Tunnel{
+			CacheDir:   path.Join(tempDir, "cache"),
+			ReportsDir: path.Join(tempDir, "reports"),
+			Timeout:    10 * time.Minute,
+			MaxTimeout: 5 * time.Minute,
+		}})
+
+		assert.EqualError(t, err, "tunnel max timeout must not be less than tunnel timeout")
+	})
+
+	t.Run("Should return error when registry throttle retries is negative", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{Tunnel: Tunnel{
+			CacheDir:                path.Join(tempDir, "cache"),
+			ReportsDir:              path.Join(tempDir, "reports"),
+			RegistryThrottleRetries: -1,
+		}})
+
+		assert.EqualError(t, err, "tunnel registry throttle retries must not be negative")
+	})
+
+	t.Run("Should return error when max pull bandwidth is negative", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{Tunnel: Tunnel{
+			CacheDir:         path.Join(tempDir, "cache"),
+			ReportsDir:       path.Join(tempDir, "reports"),
+			MaxPullBandwidth: -1,
+		}})
+
+		assert.EqualError(t, err, "tunnel max pull bandwidth must not be negative")
+	})
+
+	t.Run("Should return error when store history size is negative", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Store: Store{
+				Backend:     "redis",
+				HistorySize: -1,
+			},
+		})
+
+		assert.EqualError(t, err, "store history size must not be negative")
+	})
+
+	t.Run("Should return error when store credentials key is not 256 bits", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Store: Store{
+				Backend:        "redis",
+				CredentialsKey: "c2VjcmV0",
+			},
+		})
+
+		assert.EqualError(t, err, "store credentials key must be 32 bytes: 6")
+	})
+
+	t.Run("Should return error when id job queue envelope has no store credentials key", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Store: Store{
+				Backend: "redis",
+			},
+			JobQueue: JobQueue{
+				Envelope: "id",
+			},
+		})
+
+		assert.EqualError(t, err, "store credentials key must be set with id job queue envelope")
+	})
+
+	t.Run("Should return error when dual-write mode has no migration target", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Store: Store{
+				Backend:            "redis",
+				MigrationDualWrite: true,
+			},
+		})
+
+		assert.EqualError(t, err, "store migration target must not be blank in dual-write mode")
+	})
+
+	t.Run("Should return error when postgres store has no URL", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Store: Store{
+				Backend:         "redis",
+				MigrationTarget: "postgres",
+			},
+		})
+
+		assert.EqualError(t, err, "postgres store URL must not be blank")
+	})
+
+	t.Run("Should return error when postgres store scan job TTL is not positive", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Store: Store{
+				Backend: "postgres",
+			},
+			PostgresStore: PostgresStore{
+				URL:            "postgres://harbor@postgres:5432/scanner",
+				ReaperInterval: time.Minute,
+			},
+		})
+
+		assert.EqualError(t, err, "postgres store scan job TTL must be positive")
+	})
+
+	t.Run("Should return error when postgres store reaper interval is not positive", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Store: Store{
+				Backend: "postgres",
+			},
+			PostgresStore: PostgresStore{
+				URL:        "postgres://harbor@postgres:5432/scanner",
+				ScanJobTTL: time.Hour,
+			},
+		})
+
+		assert.EqualError(t, err, "postgres store reaper interval must be positive")
+	})
+
+	t.Run("Should return error when memory store is migration target", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Store: Store{
+				Backend:         "redis",
+				MigrationTarget: "memory",
+			},
+		})
+
+		assert.EqualError(t, err, "store migration target must not be memory, which is lost on restart")
+	})
+
+	t.Run("Should return error when memory store is unbounded", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Store: Store{
+				Backend: "memory",
+			},
+			MemoryStore: MemoryStore{
+				ScanJobTTL: time.Hour,
+			},
+		})
+
+		assert.EqualError(t, err, "memory store scan job TTL and max scan jobs must be positive")
+	})
+
+	t.Run("Should return error when API server max connections is negative", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			API: API{
+				MaxConnections: -1,
+			},
+		})
+
+		assert.EqualError(t, err, "API server max connections must not be negative")
+	})
+
+	t.Run("Should return error when static auth provider has no tokens", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Auth: Auth{
+				Provider: "static",
+			},
+		})
+
+		assert.EqualError(t, err, "auth static tokens must not be empty")
+	})
+
+	t.Run("Should return error when OIDC auth provider has no audience", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Auth: Auth{
+				Provider:      "oidc",
+				OIDCIssuerURL: "https://issuer.example.com",
+			},
+		})
+
+		assert.EqualError(t, err, "auth OIDC audience must not be blank")
+	})
+
+	t.Run("Should return error when auth signature secret is blank", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Auth: Auth{
+				SignatureScheme: "hmac",
+				SignatureMaxAge: 5 * time.Minute,
+			},
+		})
+
+		assert.EqualError(t, err, "auth signature secret must not be blank")
+	})
+
+	t.Run("Should return error when auth signature scheme is not supported", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Auth: Auth{
+				SignatureScheme: "ed25519",
+			},
+		})
+
+		assert.EqualError(t, err, "unsupported auth signature scheme: ed25519")
+	})
+
+	t.Run("Should return error when store spill threshold is not positive", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Store: Store{
+				SpillDir: path.Join(tempDir, "spill"),
+				SpillTTL: time.Hour,
+			},
+		})
+
+		assert.EqualError(t, err, "store spill threshold must be positive")
+	})
+
+	t.Run("Should return error when tunnel severity threshold is unknown", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:          path.Join(tempDir, "cache"),
+				ReportsDir:        path.Join(tempDir, "reports"),
+				SeverityThreshold: "severe",
+			},
+		})
+
+		assert.EqualError(t, err, "tunnel severity threshold: unknown severity: severe")
+	})
+
+	t.Run("Should return error when impact webhook min severity is unknown", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Impact: Impact{
+				WebhookURL:         "https://alerts.example.com",
+				WebhookMinSeverity: "severe",
+			},
+		})
+
+		assert.EqualError(t, err, "impact webhook min severity: unknown severity: severe")
+	})
+
+	t.Run("Should return error when clean scan webhook URL is invalid", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			CleanScans: CleanScans{WebhookURL: "hooks.example.com/clean-scans"},
+		})
+
+		assert.EqualError(t, err, "invalid clean scan webhook URL: hooks.example.com/clean-scans")
+	})
+
+	t.Run("Should return error when Teams notifications webhook URL is invalid", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Notifications: Notifications{TeamsWebhookURL: "outlook.office.com/webhook", MinSeverity: "High"},
+		})
+
+		assert.EqualError(t, err, "invalid Teams notifications webhook URL: outlook.office.com/webhook")
+	})
+
+	t.Run("Should return error when notifications min severity is invalid", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Notifications: Notifications{SlackWebhookURL: "https://hooks.slack.com/services/T000/B000", MinSeverity: "Severe"},
+		})
+
+		assert.EqualError(t, err, "notifications min severity: unknown severity: Severe")
+	})
+
+	t.Run("Should return error when impact assessment on DB update is enabled without SBOMs", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Impact: Impact{
+				OnDBUpdate:     true,
+				DBPollInterval: 5 * time.Minute,
+			},
+		})
+
+		assert.EqualError(t, err, "impact assessment on DB update requires SBOMs to be enabled")
+	})
+
+	t.Run("Should return error when allowlist mode is unsupported", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Allowlist: Allowlist{
+				Mode: "hide",
+			},
+		})
+
+		assert.EqualError(t, err, "unsupported allowlist mode: hide")
+	})
+
+	t.Run("Should return error when allowlist file does not exist", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Allowlist: Allowlist{
+				File: "/does/not/exist/.tunnelignore",
+			},
+		})
+
+		assert.EqualError(t, err, "allowlist file does not exist: /does/not/exist/.tunnelignore")
+	})
+
+	t.Run("Should return error when VEX path does not exist", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			VEX: VEX{
+				Path: "/does/not/exist/vex",
+			},
+		})
+
+		assert.EqualError(t, err, "VEX path does not exist: /does/not/exist/vex")
+	})
+
+	t.Run("Should return error when VEX OCI reference is invalid", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			VEX: VEX{
+				OCIRef: "https://core.harbor.domain/security/vex",
+			},
+		})
+
+		assert.EqualError(t, err, "invalid VEX OCI reference: https://core.harbor.domain/security/vex")
+	})
+
+	t.Run("Should return error when EPSS URL is invalid", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			EPSS: EPSS{
+				Enabled:         true,
+				URL:             "epss_scores-current.csv.gz",
+				RefreshInterval: 24 * time.Hour,
+			},
+		})
+
+		assert.EqualError(t, err, `invalid EPSS URL: parse "epss_scores-current.csv.gz": invalid URI for request`)
+	})
+
+	t.Run("Should return error when EPSS refresh interval is not positive", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			EPSS: EPSS{
+				Enabled: true,
+				URL:     "https://epss.cyentia.com/epss_scores-current.csv.gz",
+			},
+		})
+
+		assert.EqualError(t, err, "EPSS refresh interval must be positive")
+	})
+
+	t.Run("Should return error when policy file does not exist", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Policy: Policy{
+				File: "/does/not/exist/policy.json",
+			},
+		})
+
+		assert.EqualError(t, err, "policy file does not exist: /does/not/exist/policy.json")
+	})
+
+	t.Run("Should return error when policy bundle URL is invalid", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Policy: Policy{
+				BundleURL: "core.harbor.domain/security/policy:v3",
+			},
+		})
+
+		assert.EqualError(t, err, "invalid policy bundle URL: core.harbor.domain/security/policy:v3")
+	})
+
+	t.Run("Should return error when policy bundle is combined with Tunnel ignore policy", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:     path.Join(tempDir, "cache"),
+				ReportsDir:   path.Join(tempDir, "reports"),
+				IgnorePolicy: "/etc/scanner/ignore.rego",
+			},
+			Policy: Policy{
+				BundleURL: "oci://core.harbor.domain/security/policy:v3",
+			},
+		})
+
+		assert.EqualError(t, err, "policy bundle must not be combined with a policy file or a Tunnel ignore policy")
+	})
+
+	t.Run("Should return error when policy bundle public key does not exist", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Policy: Policy{
+				BundleURL:             "oci://core.harbor.domain/security/policy:v3",
+				BundlePublicKey:       "/does/not/exist/policy.pub",
+				BundleRefreshInterval: 5 * time.Minute,
+			},
+		})
+
+		assert.EqualError(t, err, "policy bundle public key does not exist: /does/not/exist/policy.pub")
+	})
+
+	t.Run("Should return error when policy max exception duration is negative", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Policy: Policy{MaxExceptionDuration: -time.Hour},
+		})
+
+		assert.EqualError(t, err, "policy max exception duration must not be negative")
+	})
+
+	t.Run("Should return error when feature flags file does not exist", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Feature: Feature{
+				FlagsFile: "/does/not/exist/flags.json",
+			},
+		})
+
+		assert.EqualError(t, err, "feature flags file does not exist: /does/not/exist/flags.json")
+	})
+
+	t.Run("Should return error when tunnel execution driver is not supported", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:        path.Join(tempDir, "cache"),
+				ReportsDir:      path.Join(tempDir, "reports"),
+				ExecutionDriver: "vm",
+			},
+		})
+
+		assert.EqualError(t, err, "unsupported tunnel execution driver: vm")
+	})
+
+	t.Run("Should return error when tunnel sandbox image is blank", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:        path.Join(tempDir, "cache"),
+				ReportsDir:      path.Join(tempDir, "reports"),
+				ExecutionDriver: "container",
+			},
+		})
+
+		assert.EqualError(t, err, "tunnel sandbox image must not be blank with the container execution driver")
+	})
+
+	t.Run("Should return error when tunnel kubernetes volume claim is blank", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:        path.Join(tempDir, "cache"),
+				ReportsDir:      path.Join(tempDir, "reports"),
+				ExecutionDriver: "kubernetes",
+				SandboxImage:    "khulnasoft/tunnel:0.50.1",
+			},
+		})
+
+		assert.EqualError(t, err, "tunnel kubernetes volume claim must not be blank with the kubernetes execution driver")
+	})
+
+	t.Run("Should return error when registry CA bundle is malformed", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:          path.Join(tempDir, "cache"),
+				ReportsDir:        path.Join(tempDir, "reports"),
+				RegistryCABundles: []string{"registry.internal:5000"},
+			},
+		})
+
+		assert.EqualError(t, err, "registry CA bundle must be in the host=path form: registry.internal:5000")
+	})
+
+	t.Run("Should return error when registry CA bundle file does not exist", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:          path.Join(tempDir, "cache"),
+				ReportsDir:        path.Join(tempDir, "reports"),
+				RegistryCABundles: []string{"registry.internal=/does/not/exist/ca.crt"},
+			},
+		})
+
+		assert.EqualError(t, err, "registry CA bundle file does not exist: /does/not/exist/ca.crt")
+	})
+
+	t.Run("Should return error when tunnel cache mode is not supported", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+				CacheMode:  "private",
+			},
+		})
+
+		assert.EqualError(t, err, "unsupported tunnel cache mode: private")
+	})
+
+	t.Run("Should return error when tunnel isolated cache mode skips vulnerability database updates", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+				CacheMode:  "isolated",
+				SkipUpdate: true,
+			},
+		})
+
+		assert.EqualError(t, err, "tunnel isolated cache mode requires vulnerability database updates")
+	})
+
+	t.Run("Should return error when tunnel parallel is negative", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+				Parallel:   -1,
+			},
+		})
+
+		assert.EqualError(t, err, "tunnel parallel must not be negative")
+	})
+
+	t.Run("Should return error when tunnel DB repair max failures is negative", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:            path.Join(tempDir, "cache"),
+				ReportsDir:          path.Join(tempDir, "reports"),
+				DBRepairMaxFailures: -1,
+			},
+		})
+
+		assert.EqualError(t, err, "tunnel DB repair max failures must not be negative")
+	})
+
+	t.Run("Should return error when registry header is not in the name=value form", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:        path.Join(tempDir, "cache"),
+				ReportsDir:      path.Join(tempDir, "reports"),
+				RegistryHeaders: []string{"X-Tenant"},
+			},
+		})
+
+		assert.EqualError(t, err, "registry header must be in the name=value form: X-Tenant")
+	})
+
+	t.Run("Should return error when registry headers are set with the container execution driver", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:        path.Join(tempDir, "cache"),
+				ReportsDir:      path.Join(tempDir, "reports"),
+				RegistryHeaders: []string{"X-Tenant=scanner"},
+				ExecutionDriver: "container",
+				SandboxImage:    "khulnasoft/tunnel:0.50.1",
+			},
+		})
+
+		assert.EqualError(t, err, "registry headers cannot be added to the pulls of the container execution driver")
+	})
+
+	t.Run("Should return error when max pull bandwidth is set with the kubernetes execution driver", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:              path.Join(tempDir, "cache"),
+				ReportsDir:            path.Join(tempDir, "reports"),
+				MaxPullBandwidth:      1 << 20,
+				ExecutionDriver:       "kubernetes",
+				SandboxImage:          "khulnasoft/tunnel:0.50.1",
+				KubernetesVolumeClaim: "tunnel-cache",
+			},
+		})
+
+		assert.EqualError(t, err, "tunnel max pull bandwidth cannot be enforced with the kubernetes execution driver")
+	})
+
+	t.Run("Should return error when repository normalization is unsupported", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:                path.Join(tempDir, "cache"),
+				ReportsDir:              path.Join(tempDir, "reports"),
+				RepositoryNormalization: "fancy",
+			},
+		})
+
+		assert.EqualError(t, err, "unsupported repository normalization: fancy")
+	})
+
+	t.Run("Should return error when tunnel expected SHA-256 is malformed", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:       path.Join(tempDir, "cache"),
+				ReportsDir:     path.Join(tempDir, "reports"),
+				ExpectedSHA256: "sha256:0123",
+			},
+		})
+
+		assert.EqualError(t, err, "tunnel expected SHA-256 must be a hex-encoded SHA-256 checksum")
+	})
+
+	t.Run("Should return error when tunnel expected SHA-256 cannot be verified", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:        path.Join(tempDir, "cache"),
+				ReportsDir:      path.Join(tempDir, "reports"),
+				ExecutionDriver: "container",
+				SandboxImage:    "khulnasoft/tunnel:0.46.1",
+				ExpectedSHA256:  "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			},
+		})
+
+		assert.EqualError(t, err, "tunnel expected SHA-256 cannot be verified with the container execution driver")
+	})
+
+	t.Run("Should return error when tunnel server URL is invalid", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+				ServerURL:  "tunnel-server:4954",
+			},
+		})
+
+		assert.EqualError(t, err, "invalid tunnel server URL: tunnel-server:4954")
+	})
+
+	t.Run("Should return error when tunnel expected version is set with a tunnel server", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:        path.Join(tempDir, "cache"),
+				ReportsDir:      path.Join(tempDir, "reports"),
+				ServerURL:       "http://tunnel-server:4954",
+				ExpectedVersion: "0.46.1",
+			},
+		})
+
+		assert.EqualError(t, err, "tunnel expected version cannot be verified with a tunnel server")
+	})
+
+	t.Run("Should return error when shadow percentage is out of range", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Shadow: Shadow{
+				Percentage: 150,
+			},
+		})
+
+		assert.EqualError(t, err, "shadow percentage must be between 0 and 100: 150")
+	})
+
+	t.Run("Should return error when shadow tunnel is not configured", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Shadow: Shadow{
+				Percentage:  10,
+				Concurrency: 1,
+				CacheDir:    path.Join(tempDir, "shadow"),
+			},
+		})
+
+		assert.EqualError(t, err, "shadow tunnel executable or sandbox image must not be blank")
+	})
+
+	t.Run("Should create shadow tunnel cache dir", func(t *testing.T) {
+		tempDir := t.TempDir()
+		shadowCacheDir := path.Join(tempDir, "shadow")
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Shadow: Shadow{
+				Percentage:  10,
+				Concurrency: 1,
+				Executable:  "/opt/tunnel-next/bin/tunnel",
+				CacheDir:    shadowCacheDir,
+			},
+		})
+
+		assert.NoError(t, err)
+		assert.True(t, dirExists(shadowCacheDir))
+	})
+
+	t.Run("Should return error when enrichment hook does not exist", func(t *testing.T) {
+		tempDir := t.TempDir()
+		hook := path.Join(tempDir, "enrich")
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Enrichment: Enrichment{
+				Hooks:   []string{"https://enrichment.internal/reports", hook},
+				Timeout: 10 * time.Second,
+			},
+		})
+
+		assert.EqualError(t, err, fmt.Sprintf("enrichment hook does not exist: %s", hook))
+	})
+
+	t.Run("Should return error when SLO objective is out of range", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			SLO: SLO{Objective: 100},
+		})
+
+		assert.EqualError(t, err, "SLO objective must be between 0 and 100: 100")
+	})
+
+	t.Run("Should return error when SLO window exceeds scan job TTL", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			RedisStore: RedisStore{ScanJobTTL: time.Hour},
+			SLO: SLO{
+				Objective:         95,
+				Latency:           10 * time.Minute,
+				Window:            24 * time.Hour,
+				BurnRateThreshold: 14.4,
+			},
+		})
+
+		assert.EqualError(t, err, "SLO window must not exceed scan job TTL: 1h0m0s")
+	})
+
+	t.Run("Should return error when audit sink is not supported", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Audit: Audit{Sink: "syslog", BufferSize: 10, BatchSize: 10, FlushInterval: time.Minute},
+		})
+
+		assert.EqualError(t, err, "unsupported audit sink: syslog")
+	})
+
+	t.Run("Should return error when audit S3 access key is blank", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Audit: Audit{
+				Sink:          "s3",
+				BufferSize:    10,
+				BatchSize:     10,
+				FlushInterval: time.Minute,
+				S3Region:      "us-east-1",
+				S3Bucket:      "audit",
+			},
+		})
+
+		assert.EqualError(t, err, "audit S3 access key must not be blank")
+	})
+
+	t.Run("Should return error when telemetry endpoint is invalid", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Telemetry: Telemetry{Enabled: true, Endpoint: "telemetry.example.com", Interval: 24 * time.Hour},
+		})
+
+		assert.EqualError(t, err, "invalid telemetry endpoint: telemetry.example.com")
+	})
+
+	t.Run("Should return error when telemetry interval is shorter than an hour", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Telemetry: Telemetry{Enabled: true, Endpoint: "https://telemetry.example.com", Interval: time.Minute},
+		})
+
+		assert.EqualError(t, err, "telemetry interval must be at least 1h")
+	})
+
+	t.Run("Should return error when tracing OTLP endpoint is invalid", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Tracing: Tracing{Enabled: true, OTLPEndpoint: "otel-collector:4318", SampleRatio: 1},
+		})
+
+		assert.EqualError(t, err, "invalid tracing OTLP endpoint: otel-collector:4318")
+	})
+
+	t.Run("Should return error when access log sample ratio is greater than 1", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			AccessLog: AccessLog{Enabled: true, SampleRatio: 1.5},
+		})
+
+		assert.EqualError(t, err, "access log sample ratio must be between 0 and 1: 1.5")
+	})
+
+	t.Run("Should return error when tracing sample ratio is greater than 1", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Tracing: Tracing{Enabled: true, OTLPEndpoint: "http://otel-collector:4318", SampleRatio: 10},
+		})
+
+		assert.EqualError(t, err, "tracing sample ratio must be between 0 and 1: 10")
+	})
+
+	t.Run("Should return error when tracing OTLP header has no name", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Tracing: Tracing{Enabled: true, OTLPEndpoint: "http://otel-collector:4318", OTLPHeaders: []string{"s3cret"}, SampleRatio: 1},
+		})
+
+		assert.EqualError(t, err, "tracing OTLP header must be in the name=value form: s3cret")
+	})
+
+	t.Run("Should return error when connectivity probe timeout is not positive", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Connectivity: Connectivity{Enabled: true, Interval: time.Minute, RegistryWindow: time.Hour},
+		})
+
+		assert.EqualError(t, err, "connectivity probe interval, timeout and registry window must be positive")
+	})
+
+	t.Run("Should return error when gRPC watch poll interval is not positive", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			GRPC: GRPC{Addr: ":9090"},
+		})
+
+		assert.EqualError(t, err, "gRPC watch poll interval must be positive")
+	})
+
+	t.Run("Should return error when CRD poll interval is not positive", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			CRD: CRD{Enabled: true, MaxVulnerabilities: 500},
+		})
+
+		assert.EqualError(t, err, "CRD poll interval must be positive")
+	})
+
+	t.Run("Should return error when CRD max vulnerabilities is negative", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			CRD: CRD{Enabled: true, PollInterval: 10 * time.Second, MaxVulnerabilities: -1},
+		})
+
+		assert.EqualError(t, err, "CRD max vulnerabilities must not be negative")
+	})
+
+	t.Run("Should return error when OPA URL and policy path are both set", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			OPA: OPA{URL: "http://opa:8181/v1/data/scanner/report", PolicyPath: tempDir, Timeout: 10 * time.Second},
+		})
+
+		assert.EqualError(t, err, "OPA URL and policy path are mutually exclusive")
+	})
+
+	t.Run("Should return error when OPA policy path does not exist", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			OPA: OPA{PolicyPath: "/does/not/exist.rego", Timeout: 10 * time.Second},
+		})
+
+		assert.EqualError(t, err, "OPA policy path does not exist: /does/not/exist.rego")
+	})
+
+	t.Run("Should return error when Harbor webhook registry password is not set", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			HarborWebhook: HarborWebhook{Secret: "s3cret", RegistryUsername: "robot$scanner"},
+		})
+
+		assert.EqualError(t, err, "Harbor webhook registry username and password must be set together")
+	})
+
+	t.Run("Should return error when Harbor webhook registry credentials are set without registry URL", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			HarborWebhook: HarborWebhook{Secret: "s3cret", RegistryUsername: "robot$scanner", RegistryPassword: "s3cret"},
+		})
+
+		assert.EqualError(t, err, "Harbor webhook registry URL must be set with registry credentials")
+	})
+
+	t.Run("Should return error when job queue envelope is not supported", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			JobQueue: JobQueue{
+				Envelope: "gzip",
+			},
+		})
+
+		assert.EqualError(t, err, "unsupported job queue envelope: gzip")
+	})
+
+	t.Run("Should return error when job queue envelope version is not supported", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			JobQueue: JobQueue{
+				Envelope:        "zstd",
+				EnvelopeVersion: 3,
+			},
+		})
+
+		assert.EqualError(t, err, "unsupported job queue envelope version: 3")
+	})
+
+	t.Run("Should return error when stall timeout does not exceed heartbeat interval", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			JobQueue: JobQueue{
+				HeartbeatInterval: 10 * time.Second,
+				StallTimeout:      10 * time.Second,
+			},
+		})
+
+		assert.EqualError(t, err, "job queue stall timeout 10s must be greater than heartbeat interval 10s")
+	})
+
+	t.Run("Should return error when failed digest cooldown is not positive", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			JobQueue: JobQueue{FailedDigestThreshold: 3},
+		})
+
+		assert.EqualError(t, err, "job queue failed digest cooldown must be positive")
+	})
+
+	t.Run("Should return error when max backlog is negative", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			JobQueue: JobQueue{MaxBacklog: -1},
+		})
+
+		assert.EqualError(t, err, "job queue max backlog must not be negative")
+	})
+
+	t.Run("Should return error when job queue driver is unsupported", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			JobQueue: JobQueue{Driver: "kafka"},
+		})
+
+		assert.EqualError(t, err, "unsupported job queue driver: kafka")
+	})
+
+	t.Run("Should return error when SBOMs are enabled without Redis", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:    path.Join(tempDir, "cache"),
+				ReportsDir:  path.Join(tempDir, "reports"),
+				SBOMEnabled: true,
+			},
+			Store: Store{
+				Backend: "memory",
+			},
+			MemoryStore: MemoryStore{
+				ScanJobTTL:  time.Hour,
+				MaxScanJobs: 10,
+			},
+		})
+
+		assert.EqualError(t, err, "tunnel SBOMs require Redis, which is used by neither the store nor the job queue")
+	})
+
+	t.Run("Should return error when digest reuse is enabled without Redis", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			Store: Store{
+				Backend: "postgres",
+			},
+			PostgresStore: PostgresStore{
+				URL:            "postgres://scanner@harbor-db:5432/scanner",
+				ScanJobTTL:     time.Hour,
+				ReaperInterval: time.Minute,
+			},
+			JobQueue: JobQueue{
+				Driver:            "local",
+				DigestReuseWindow: time.Hour,
+			},
+		})
+
+		assert.EqualError(t, err, "job queue digest reuse and failed digests require Redis, which is used by neither the store nor the job queue")
+	})
+
 	t.Run("Should return error when TLS certificate does not exist", func(t *testing.T) {
 		tempDir := t.TempDir()
 
@@ -162,4 +1496,46 @@ func TestCheck(t *testing.T) {
 
 		assert.EqualError(t, err, fmt.Sprintf("ClientCA file does not exist: %s", clientCA2File))
 	})
+	t.Run("Should return error when redis TLS options are set without TLS", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			RedisPool: RedisPool{TLSCABundle: path.Join(tempDir, "ca.crt")},
+		})
+
+		assert.EqualError(t, err, "redis TLS options require redis TLS to be enabled")
+	})
+
+	t.Run("Should return error when redis TLS certificate is set without key", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			RedisPool: RedisPool{TLSEnabled: true, TLSCertificate: path.Join(tempDir, "tls.crt")},
+		})
+
+		assert.EqualError(t, err, "redis TLS certificate and key must be set together")
+	})
+
+	t.Run("Should return error when redis TLS CA bundle does not exist", func(t *testing.T) {
+		tempDir := t.TempDir()
+		caBundle := path.Join(tempDir, "ca.crt")
+
+		err := Check(Config{
+			Tunnel: Tunnel{
+				CacheDir:   path.Join(tempDir, "cache"),
+				ReportsDir: path.Join(tempDir, "reports"),
+			},
+			RedisPool: RedisPool{TLSEnabled: true, TLSCABundle: caBundle},
+		})
+
+		assert.EqualError(t, err, fmt.Sprintf("redis TLS file does not exist: %s", caBundle))
+	})
 }
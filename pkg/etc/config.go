@@ -1,13 +1,15 @@
 package etc
 
 import (
+	"encoding/base64"
 	"log/slog"
+	"net"
 	"os"
 	"strings"
 	"time"
 
-	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
 	"github.com/caarlos0/env/v6"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
 )
 
 type BuildInfo struct {
@@ -17,27 +19,192 @@ type BuildInfo struct {
 }
 
 type Config struct {
-	API        API
-	Tunnel      Tunnel
-	RedisStore RedisStore
-	JobQueue   JobQueue
-	RedisPool  RedisPool
+	API            API
+	Auth           Auth
+	CORS           CORS
+	HarborWebhook  HarborWebhook
+	AccessLog      AccessLog
+	Tunnel         Tunnel
+	Store          Store
+	RedisStore     RedisStore
+	PostgresStore  PostgresStore
+	MemoryStore    MemoryStore
+	JobQueue       JobQueue
+	RedisPool      RedisPool
+	Impact         Impact
+	CleanScans     CleanScans
+	Notifications  Notifications
+	Policy         Policy
+	Feature        Feature
+	Shadow         Shadow
+	Enrichment     Enrichment
+	OPA            OPA
+	Classification Classification
+	Allowlist      Allowlist
+	VEX            VEX
+	SLO            SLO
+	NVD            NVD
+	EPSS           EPSS
+	Audit          Audit
+	Telemetry      Telemetry
+	Tracing        Tracing
+	Connectivity   Connectivity
+	CRD            CRD
+	GRPC           GRPC
 }
 
 type Tunnel struct {
-	CacheDir       string        `env:"SCANNER_TUNNEL_CACHE_DIR" envDefault:"/home/scanner/.cache/tunnel"`
-	ReportsDir     string        `env:"SCANNER_TUNNEL_REPORTS_DIR" envDefault:"/home/scanner/.cache/reports"`
-	DebugMode      bool          `env:"SCANNER_TUNNEL_DEBUG_MODE" envDefault:"false"`
-	VulnType       string        `env:"SCANNER_TUNNEL_VULN_TYPE" envDefault:"os,library"`
-	SecurityChecks string        `env:"SCANNER_TUNNEL_SECURITY_CHECKS" envDefault:"vuln"`
-	Severity       string        `env:"SCANNER_TUNNEL_SEVERITY" envDefault:"UNKNOWN,LOW,MEDIUM,HIGH,CRITICAL"`
-	IgnoreUnfixed  bool          `env:"SCANNER_TUNNEL_IGNORE_UNFIXED" envDefault:"false"`
-	IgnorePolicy   string        `env:"SCANNER_TUNNEL_IGNORE_POLICY"`
-	SkipUpdate     bool          `env:"SCANNER_TUNNEL_SKIP_UPDATE" envDefault:"false"`
-	OfflineScan    bool          `env:"SCANNER_TUNNEL_OFFLINE_SCAN" envDefault:"false"`
-	GitHubToken    string        `env:"SCANNER_TUNNEL_GITHUB_TOKEN"`
-	Insecure       bool          `env:"SCANNER_TUNNEL_INSECURE" envDefault:"false"`
-	Timeout        time.Duration `env:"SCANNER_TUNNEL_TIMEOUT" envDefault:"5m0s"`
+	// Executable is the name or path of the Tunnel executable, which is looked up in the PATH of the adapter,
+	// or of the sandbox image with the container and kubernetes execution drivers.
+	Executable     string `env:"SCANNER_TUNNEL_EXECUTABLE" envDefault:"tunnel"`
+	CacheDir       string `env:"SCANNER_TUNNEL_CACHE_DIR" envDefault:"/home/scanner/.cache/tunnel"`
+	ReportsDir     string `env:"SCANNER_TUNNEL_REPORTS_DIR" envDefault:"/home/scanner/.cache/reports"`
+	DebugMode      bool   `env:"SCANNER_TUNNEL_DEBUG_MODE" envDefault:"false"`
+	VulnType       string `env:"SCANNER_TUNNEL_VULN_TYPE" envDefault:"os,library"`
+	SecurityChecks string `env:"SCANNER_TUNNEL_SECURITY_CHECKS" envDefault:"vuln"`
+	Severity       string `env:"SCANNER_TUNNEL_SEVERITY" envDefault:"UNKNOWN,LOW,MEDIUM,HIGH,CRITICAL"`
+	// SeverityThreshold is the lowest severity of the vulnerabilities reported to Harbor, e.g. High to only report
+	// High and Critical ones. Blank reports vulnerabilities of any severity.
+	SeverityThreshold string        `env:"SCANNER_TUNNEL_SEVERITY_THRESHOLD"`
+	IgnoreUnfixed     bool          `env:"SCANNER_TUNNEL_IGNORE_UNFIXED" envDefault:"false"`
+	IgnorePolicy      string        `env:"SCANNER_TUNNEL_IGNORE_POLICY"`
+	SkipUpdate        bool          `env:"SCANNER_TUNNEL_SKIP_UPDATE" envDefault:"false"`
+	OfflineScan       bool          `env:"SCANNER_TUNNEL_OFFLINE_SCAN" envDefault:"false"`
+	GitHubToken       string        `env:"SCANNER_TUNNEL_GITHUB_TOKEN"`
+	Insecure          bool          `env:"SCANNER_TUNNEL_INSECURE" envDefault:"false"`
+	Timeout           time.Duration `env:"SCANNER_TUNNEL_TIMEOUT" envDefault:"5m0s"`
+	// MaxTimeout caps the timeouts scan requests and the policy may extend Timeout to, for the images which
+	// legitimately take longer to scan.
+	MaxTimeout         time.Duration `env:"SCANNER_TUNNEL_MAX_TIMEOUT" envDefault:"30m"`
+	VulnDBMaxStaleness time.Duration `env:"SCANNER_TUNNEL_VULNDB_MAX_STALENESS"`
+	// MaxRegistryConnections limits the number of Tunnel processes pulling images at the same time,
+	// regardless of the number of workers. Zero means no limit.
+	MaxRegistryConnections int `env:"SCANNER_TUNNEL_MAX_REGISTRY_CONNECTIONS"`
+	// RegistryAdaptiveConcurrency lowers the number of image pulls allowed at the same time, from
+	// MaxRegistryConnections down to one, while registries throttle pulls, and raises it back once they succeed.
+	RegistryAdaptiveConcurrency bool `env:"SCANNER_TUNNEL_REGISTRY_ADAPTIVE_CONCURRENCY" envDefault:"false"`
+	// RegistryThrottleRetries is the budget of retries of an image pull throttled by the registry, which are
+	// spaced by a jittered exponential backoff starting at RegistryThrottleBackoff.
+	RegistryThrottleRetries int           `env:"SCANNER_TUNNEL_REGISTRY_THROTTLE_RETRIES" envDefault:"3"`
+	RegistryThrottleBackoff time.Duration `env:"SCANNER_TUNNEL_REGISTRY_THROTTLE_BACKOFF" envDefault:"5s"`
+	// Parallel is the number of layers each Tunnel process downloads and analyzes in parallel. Zero spreads
+	// a default budget among the workers.
+	Parallel int `env:"SCANNER_TUNNEL_PARALLEL"`
+	// MaxPullBandwidth caps the aggregate bandwidth of image pulls in bytes per second. Zero means no limit.
+	MaxPullBandwidth int64 `env:"SCANNER_TUNNEL_MAX_PULL_BANDWIDTH"`
+	// SBOMEnabled stores the SBOM of each scanned artifact, so that subsequent scans of the same digest match
+	// vulnerabilities against the stored SBOM instead of analyzing the image again.
+	SBOMEnabled bool `env:"SCANNER_TUNNEL_SBOM_ENABLED" envDefault:"false"`
+	// RegistrySBOMEnabled scans the SBOM attached to an artifact in the registry, as an OCI referrer or with
+	// Cosign, instead of pulling and analyzing the image. Artifacts without an attached SBOM are analyzed.
+	RegistrySBOMEnabled bool `env:"SCANNER_TUNNEL_REGISTRY_SBOM_ENABLED" envDefault:"false"`
+	// CacheMode is either shared, i.e. all Tunnel processes use CacheDir, or isolated, i.e. concurrent Tunnel
+	// processes use distinct subdirectories of CacheDir.
+	CacheMode string `env:"SCANNER_TUNNEL_CACHE_MODE" envDefault:"shared"`
+	// DBRepairMaxFailures is the number of consecutive failed repairs of a corrupted vulnerability database,
+	// after which repairs are suspended for DBRepairCooldown. Zero disables repairs.
+	DBRepairMaxFailures int           `env:"SCANNER_TUNNEL_DB_REPAIR_MAX_FAILURES" envDefault:"3"`
+	DBRepairCooldown    time.Duration `env:"SCANNER_TUNNEL_DB_REPAIR_COOLDOWN" envDefault:"10m"`
+	// RegistryCABundles maps registry hosts to the CA bundles their TLS certificates are verified with, in
+	// the host=path form. A host without a port matches the registry on any port.
+	RegistryCABundles []string `env:"SCANNER_TUNNEL_REGISTRY_CA_BUNDLES"`
+	// InsecureRegistries are the registry hosts whose TLS certificates are not verified, as a last resort
+	// when a CA bundle cannot be provided. A host without a port matches the registry on any port.
+	InsecureRegistries []string `env:"SCANNER_TUNNEL_INSECURE_REGISTRIES"`
+	// RegistryHeaders are the headers added to all registry requests, in the name=value form, e.g. the tenant
+	// ID expected by a layer-7 gateway in front of the registries.
+	RegistryHeaders []string `env:"SCANNER_TUNNEL_REGISTRY_HEADERS"`
+	// RepositoryNormalization is the strategy of normalizing the repositories sent by Harbor before pulling
+	// them, either none, standard or proxy-cache.
+	RepositoryNormalization string `env:"SCANNER_TUNNEL_REPOSITORY_NORMALIZATION" envDefault:"standard"`
+	// ProxyCacheUpstreams are the upstream registry hosts removed from the repositories of proxy cache projects
+	// by the proxy-cache strategy. They default to the hosts of Docker Hub.
+	ProxyCacheUpstreams []string `env:"SCANNER_TUNNEL_PROXY_CACHE_UPSTREAMS"`
+	// CredentialsRefreshHookURL is posted the scan jobs failed because the registry rejected their credentials,
+	// so that the credentials of Harbor's robot account can be refreshed.
+	CredentialsRefreshHookURL string `env:"SCANNER_TUNNEL_CREDENTIALS_REFRESH_HOOK_URL"`
+	// ExecutionDriver is either process, i.e. Tunnel runs as a child process of the adapter, container, i.e.
+	// each Tunnel process runs in its own container of SandboxImage with a read-only root filesystem, as defense
+	// in depth when scanning untrusted images, or kubernetes, i.e. each Tunnel process runs in its own pod.
+	ExecutionDriver string `env:"SCANNER_TUNNEL_EXECUTION_DRIVER" envDefault:"process"`
+	// SandboxCLI is the Docker compatible CLI running sandbox containers, e.g. nerdctl for containerd.
+	SandboxCLI   string `env:"SCANNER_TUNNEL_SANDBOX_CLI" envDefault:"docker"`
+	SandboxImage string `env:"SCANNER_TUNNEL_SANDBOX_IMAGE"`
+	// SandboxRuntime is the OCI runtime of sandbox containers, e.g. runsc to run them in gVisor. Blank uses the
+	// default runtime of the container engine.
+	SandboxRuntime string `env:"SCANNER_TUNNEL_SANDBOX_RUNTIME"`
+	// SandboxNetwork is the network sandbox containers are attached to. Blank uses the default network of the
+	// container engine.
+	SandboxNetwork string `env:"SCANNER_TUNNEL_SANDBOX_NETWORK"`
+	// KubernetesNamespace is the namespace of the pods of the kubernetes execution driver. Blank uses the
+	// namespace of the current kubectl context.
+	KubernetesNamespace string `env:"SCANNER_TUNNEL_KUBERNETES_NAMESPACE"`
+	// KubernetesVolumeClaim is the persistent volume claim holding CacheDir and ReportsDir, which is mounted at
+	// KubernetesVolumePath in the adapter and in the pods of the kubernetes execution driver.
+	KubernetesVolumeClaim string `env:"SCANNER_TUNNEL_KUBERNETES_VOLUME_CLAIM"`
+	KubernetesVolumePath  string `env:"SCANNER_TUNNEL_KUBERNETES_VOLUME_PATH" envDefault:"/home/scanner/.cache"`
+	// ExpectedVersion and ExpectedSHA256 pin the Tunnel binary, so that a drifted sidecar or sandbox image cannot
+	// silently change the behavior of scans. Scans are refused, and the adapter is not ready, while the version
+	// reported by Tunnel, or the SHA-256 checksum of the executable, does not match.
+	ExpectedVersion string `env:"SCANNER_TUNNEL_EXPECTED_VERSION"`
+	// ExpectedSHA256 is only verified with the process execution driver, which runs the executable of the adapter.
+	ExpectedSHA256 string `env:"SCANNER_TUNNEL_EXPECTED_SHA256"`
+	// ServerURL is the URL of a long-running Tunnel server, which holds the vulnerability database. Tunnel
+	// processes only analyze images and SBOMs, and have the server match the packages found against the database,
+	// instead of downloading and loading the database for each scan.
+	ServerURL string `env:"SCANNER_TUNNEL_SERVER_URL"`
+	// ServerToken authenticates the requests to the Tunnel server in the ServerTokenHeader header.
+	ServerToken       string `env:"SCANNER_TUNNEL_SERVER_TOKEN"`
+	ServerTokenHeader string `env:"SCANNER_TUNNEL_SERVER_TOKEN_HEADER" envDefault:"Tunnel-Token"`
+}
+
+// RegistryCABundle returns the path of the CA bundle configured for the given registry host, which may
+// include a port, or an empty string if there is none.
+func (c *Tunnel) RegistryCABundle(host string) string {
+	hostname := hostnameOf(host)
+	var bundle string
+	for _, entry := range c.RegistryCABundles {
+		h, path, _ := strings.Cut(entry, "=")
+		if h == host {
+			return path
+		}
+		if h == hostname {
+			bundle = path
+		}
+	}
+	return bundle
+}
+
+// RegistryHeaderValues returns the headers added to all registry requests keyed by name.
+func (c *Tunnel) RegistryHeaderValues() map[string]string {
+	headers := make(map[string]string, len(c.RegistryHeaders))
+	for _, entry := range c.RegistryHeaders {
+		name, value, _ := strings.Cut(entry, "=")
+		headers[name] = value
+	}
+	return headers
+}
+
+// IsInsecureRegistry returns true if TLS certificates of the given registry host, which may include a
+// port, are not verified.
+func (c *Tunnel) IsInsecureRegistry(host string) bool {
+	if c.Insecure {
+		return true
+	}
+	hostname := hostnameOf(host)
+	for _, h := range c.InsecureRegistries {
+		if h == host || h == hostname {
+			return true
+		}
+	}
+	return false
+}
+
+func hostnameOf(host string) string {
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		return hostname
+	}
+	return host
 }
 
 type API struct {
@@ -48,20 +215,524 @@ type API struct {
 	ReadTimeout    time.Duration `env:"SCANNER_API_SERVER_READ_TIMEOUT" envDefault:"15s"`
 	WriteTimeout   time.Duration `env:"SCANNER_API_SERVER_WRITE_TIMEOUT" envDefault:"15s"`
 	IdleTimeout    time.Duration `env:"SCANNER_API_SERVER_IDLE_TIMEOUT" envDefault:"60s"`
+	// ReadHeaderTimeout bounds the time clients may take to send request headers, so that slow clients
+	// cannot hold connections open indefinitely.
+	ReadHeaderTimeout time.Duration `env:"SCANNER_API_SERVER_READ_HEADER_TIMEOUT" envDefault:"5s"`
+	MaxHeaderBytes    int           `env:"SCANNER_API_SERVER_MAX_HEADER_BYTES" envDefault:"1048576"`
+	// MaxRequestBodyBytes limits the size of request bodies. Zero means no limit.
+	MaxRequestBodyBytes int64 `env:"SCANNER_API_SERVER_MAX_REQUEST_BODY_BYTES" envDefault:"1048576"`
+	// MaxConnections limits the number of simultaneous connections accepted by the server. Zero means no limit.
+	MaxConnections int `env:"SCANNER_API_SERVER_MAX_CONNECTIONS"`
+	// MaintenanceMode rejects new scan requests, while metadata and existing scan reports are still served.
+	MaintenanceMode    bool   `env:"SCANNER_API_MAINTENANCE_MODE" envDefault:"false"`
+	MaintenanceMessage string `env:"SCANNER_API_MAINTENANCE_MESSAGE" envDefault:"scanner is under maintenance, try again later"`
+	// HarborLegacyMode serves scan reports in the structure expected by Harbor releases prior to 2.6.
+	HarborLegacyMode bool `env:"SCANNER_API_HARBOR_LEGACY_MODE" envDefault:"false"`
+	// VulnerabilityReportVersions are the schema versions of the vulnerability reports served concurrently, the
+	// first of which is served unless Harbor requests another one.
+	VulnerabilityReportVersions []harbor.VulnerabilityReportVersion `env:"SCANNER_API_VULNERABILITY_REPORT_VERSIONS" envDefault:"1.1"`
+	// IncludeEcosystems and ExcludeEcosystems filter the vulnerabilities of scan reports by the ecosystem of their
+	// packages, e.g. os, npm, pypi, golang or jar, unless the request overrides them with query parameters.
+	IncludeEcosystems []string `env:"SCANNER_API_REPORT_INCLUDE_ECOSYSTEMS"`
+	ExcludeEcosystems []string `env:"SCANNER_API_REPORT_EXCLUDE_ECOSYSTEMS"`
+	// MetadataCacheTTL is the time during which the metadata is served without retrieving the version of the
+	// vulnerability database again. Zero disables the cache.
+	MetadataCacheTTL time.Duration `env:"SCANNER_API_METADATA_CACHE_TTL" envDefault:"1m"`
 }
 
 func (c *API) IsTLSEnabled() bool {
 	return c.TLSCertificate != "" && c.TLSKey != ""
 }
 
+// HarborWebhook configures the endpoint accepting the artifact push events of Harbor webhooks, which enqueues scans
+// of the pushed artifacts. The endpoint is disabled unless the secret is set.
+type HarborWebhook struct {
+	// Secret is the value of the Authorization header of the events, i.e. the auth header of the webhook policy.
+	Secret string `env:"SCANNER_HARBOR_WEBHOOK_SECRET"`
+	// RegistryURL is the URL of the registry the pushed artifacts are pulled from. It defaults to the HTTPS URL of
+	// the host of the resource URLs of the events.
+	RegistryURL string `env:"SCANNER_HARBOR_WEBHOOK_REGISTRY_URL"`
+	// RegistryUsername and RegistryPassword are the credentials of the registry, e.g. of a robot account allowed
+	// to pull the artifacts of all projects. Artifacts are pulled anonymously unless they are set.
+	RegistryUsername string `env:"SCANNER_HARBOR_WEBHOOK_REGISTRY_USERNAME"`
+	RegistryPassword string `env:"SCANNER_HARBOR_WEBHOOK_REGISTRY_PASSWORD"`
+}
+
+// IsEnabled returns true if the endpoint accepting Harbor webhook events is served.
+func (c HarborWebhook) IsEnabled() bool {
+	return c.Secret != ""
+}
+
+// Auth configures how callers of the API are authenticated. The none provider accepts all requests,
+// the static provider accepts bearer tokens from a fixed list, and the oidc provider validates JWTs
+// issued by an OpenID Connect provider.
+type Auth struct {
+	Provider     string   `env:"SCANNER_API_AUTH_PROVIDER" envDefault:"none"`
+	StaticTokens []string `env:"SCANNER_API_AUTH_STATIC_TOKENS"`
+	// StaticAdminTokens are accepted by the admin endpoints in addition to the endpoints called by Harbor,
+	// so that a leaked Harbor registration token cannot be used to manage the adapter.
+	StaticAdminTokens []string `env:"SCANNER_API_AUTH_STATIC_ADMIN_TOKENS"`
+	// OIDCIssuerURL is matched against the iss claim and used to discover the JSON Web Key Set.
+	OIDCIssuerURL string `env:"SCANNER_API_AUTH_OIDC_ISSUER_URL"`
+	OIDCAudience  string `env:"SCANNER_API_AUTH_OIDC_AUDIENCE"`
+	// OIDCJWKSURL overrides the JSON Web Key Set URL advertised by the issuer's discovery document.
+	OIDCJWKSURL string `env:"SCANNER_API_AUTH_OIDC_JWKS_URL"`
+	// OIDCAdminSubjects are the values of the sub claim granted access to the admin endpoints.
+	OIDCAdminSubjects []string `env:"SCANNER_API_AUTH_OIDC_ADMIN_SUBJECTS"`
+	// SignatureScheme is the scheme of the signatures binding scan requests to their artifact digests, which
+	// Harbor or a gateway in front of the adapter attaches to prevent spoofed scan requests. Scan requests are
+	// not required to be signed if the scheme is none.
+	SignatureScheme string `env:"SCANNER_API_AUTH_SIGNATURE_SCHEME" envDefault:"none"`
+	SignatureSecret string `env:"SCANNER_API_AUTH_SIGNATURE_SECRET"`
+	// SignatureMaxAge is the age after which HMAC signatures are rejected to limit replays.
+	SignatureMaxAge time.Duration `env:"SCANNER_API_AUTH_SIGNATURE_MAX_AGE" envDefault:"5m"`
+}
+
+// CORS configures Cross-Origin Resource Sharing for the /api/v1 endpoints, so that browser-based consumers
+// can call the adapter directly. CORS is disabled unless at least one origin is allowed.
+type CORS struct {
+	// AllowedOrigins lists the allowed origins, or * to allow any origin.
+	AllowedOrigins []string      `env:"SCANNER_API_CORS_ALLOWED_ORIGINS"`
+	AllowedMethods []string      `env:"SCANNER_API_CORS_ALLOWED_METHODS" envDefault:"GET,POST"`
+	AllowedHeaders []string      `env:"SCANNER_API_CORS_ALLOWED_HEADERS" envDefault:"Accept,Authorization,Content-Type"`
+	MaxAge         time.Duration `env:"SCANNER_API_CORS_MAX_AGE" envDefault:"10m"`
+}
+
+// IsEnabled returns true if at least one origin is allowed.
+func (c *CORS) IsEnabled() bool {
+	return len(c.AllowedOrigins) > 0
+}
+
+// AccessLog configures the access log of the API. Requests are logged at the configured sample ratio, so that
+// bursts of scan requests, e.g. when scanning all artifacts, do not drown the logs, while failed and slow requests
+// are always logged.
+type AccessLog struct {
+	Enabled     bool    `env:"SCANNER_API_ACCESS_LOG_ENABLED" envDefault:"false"`
+	SampleRatio float64 `env:"SCANNER_API_ACCESS_LOG_SAMPLE_RATIO" envDefault:"1"`
+	// SlowThreshold is the duration from which requests are always logged. Zero disables slow request capture.
+	SlowThreshold time.Duration `env:"SCANNER_API_ACCESS_LOG_SLOW_THRESHOLD" envDefault:"5s"`
+}
+
+type Store struct {
+	Backend string `env:"SCANNER_STORE_BACKEND" envDefault:"redis"`
+	// MigrationTarget is the backend scan jobs are migrated to with the migrate-store command.
+	MigrationTarget string `env:"SCANNER_STORE_MIGRATION_TARGET"`
+	// MigrationDualWrite mirrors writes to the migration target, so that it stays in sync during the migration.
+	MigrationDualWrite      bool   `env:"SCANNER_STORE_MIGRATION_DUAL_WRITE" envDefault:"false"`
+	MigrationRedisNamespace string `env:"SCANNER_STORE_MIGRATION_REDIS_NAMESPACE"`
+	// HistorySize is the number of scan jobs completed recently by this replica, which are kept in memory after
+	// they expire from the store and exposed by the admin API. Zero disables the history.
+	HistorySize int `env:"SCANNER_STORE_HISTORY_SIZE" envDefault:"100"`
+	// SpillDir is the directory the vulnerabilities of large reports are spilled to, rather than being stored with
+	// their scan jobs, so that neither the store nor the adapter holds their encoded copies in memory. It must be
+	// shared by the replicas serving the reports. Blank disables spilling.
+	SpillDir string `env:"SCANNER_STORE_SPILL_DIR"`
+	// SpillThreshold is the number of vulnerabilities of the reports above which they are spilled.
+	SpillThreshold int `env:"SCANNER_STORE_SPILL_THRESHOLD" envDefault:"10000"`
+	// SpillTTL is how long spilled vulnerabilities are kept, which must not be less than the TTL of scan jobs.
+	SpillTTL time.Duration `env:"SCANNER_STORE_SPILL_TTL" envDefault:"2h"`
+	// CredentialsKey is the base64-encoded 256-bit AES key the registry credentials of stored scan requests are
+	// encrypted with. Blank keeps the credentials out of the store altogether.
+	CredentialsKey string `env:"SCANNER_STORE_CREDENTIALS_KEY"`
+}
+
+// DecodeCredentialsKey returns the decoded credentials key, or nil if it is blank.
+func (s Store) DecodeCredentialsKey() ([]byte, error) {
+	if s.CredentialsKey == "" {
+		return nil, nil
+	}
+	return base64.StdEncoding.DecodeString(s.CredentialsKey)
+}
+
 type RedisStore struct {
 	Namespace  string        `env:"SCANNER_STORE_REDIS_NAMESPACE" envDefault:"harbor.scanner.tunnel:data-store"`
 	ScanJobTTL time.Duration `env:"SCANNER_STORE_REDIS_SCAN_JOB_TTL" envDefault:"1h"`
+	// VulnerabilityIndexTTL is how long an artifact stays in the vulnerability index after its last scan.
+	// Zero means artifacts are never evicted.
+	VulnerabilityIndexTTL time.Duration `env:"SCANNER_STORE_REDIS_VULNERABILITY_INDEX_TTL" envDefault:"168h"`
+	// SBOMTTL is how long an SBOM is stored, after which the artifact is analyzed again on its next scan.
+	// Zero means SBOMs are never evicted.
+	SBOMTTL time.Duration `env:"SCANNER_STORE_REDIS_SBOM_TTL" envDefault:"168h"`
+	// LifetimeTTL is how long the lifetimes of the vulnerabilities of an artifact are kept after its last scan.
+	// Zero means lifetimes are never evicted.
+	LifetimeTTL time.Duration `env:"SCANNER_STORE_REDIS_LIFETIME_TTL" envDefault:"2160h"`
+}
+
+// PostgresStore configures the PostgreSQL store backend, which is selected with SCANNER_STORE_BACKEND=postgres.
+type PostgresStore struct {
+	URL        string        `env:"SCANNER_STORE_POSTGRES_URL"`
+	ScanJobTTL time.Duration `env:"SCANNER_STORE_POSTGRES_SCAN_JOB_TTL" envDefault:"1h"`
+	// ReaperInterval is how often expired scan jobs are deleted.
+	ReaperInterval time.Duration `env:"SCANNER_STORE_POSTGRES_REAPER_INTERVAL" envDefault:"1m"`
+	MaxOpenConns   int           `env:"SCANNER_STORE_POSTGRES_MAX_OPEN_CONNS" envDefault:"10"`
+}
+
+// MemoryStore configures the in-memory store backend, which is selected with SCANNER_STORE_BACKEND=memory. Scan
+// jobs kept in memory are lost on restart, hence it only suits single-replica installations.
+type MemoryStore struct {
+	ScanJobTTL time.Duration `env:"SCANNER_STORE_MEMORY_SCAN_JOB_TTL" envDefault:"1h"`
+	// MaxScanJobs bounds the number of scan jobs kept in memory, beyond which the least recently updated scan jobs
+	// are evicted.
+	MaxScanJobs int `env:"SCANNER_STORE_MEMORY_MAX_SCAN_JOBS" envDefault:"10000"`
 }
 
 type JobQueue struct {
+	// Driver is either redis, which shares the job queue among replicas, or local, which keeps it in the process.
+	// Blank is local with the memory store backend and redis otherwise.
+	Driver            string `env:"SCANNER_JOB_QUEUE_DRIVER"`
 	Namespace         string `env:"SCANNER_JOB_QUEUE_REDIS_NAMESPACE" envDefault:"harbor.scanner.tunnel:job-queue"`
 	WorkerConcurrency int    `env:"SCANNER_JOB_QUEUE_WORKER_CONCURRENCY" envDefault:"1"`
+	IDGenerator       string `env:"SCANNER_JOB_QUEUE_ID_GENERATOR" envDefault:"random"`
+	Envelope          string `env:"SCANNER_JOB_QUEUE_ENVELOPE" envDefault:"json"`
+	// EnvelopeVersion is the version of the zstd and id envelopes. Pin it to the previous version until all
+	// replicas of a rolling upgrade understand the current one.
+	EnvelopeVersion   int           `env:"SCANNER_JOB_QUEUE_ENVELOPE_VERSION" envDefault:"2"`
+	HeartbeatInterval time.Duration `env:"SCANNER_JOB_QUEUE_HEARTBEAT_INTERVAL" envDefault:"10s"`
+	StallTimeout      time.Duration `env:"SCANNER_JOB_QUEUE_STALL_TIMEOUT" envDefault:"1m"`
+	// DigestReuseWindow is how long the report of a completed scan job is served to requests to scan artifacts
+	// of the same digest, under any tag, rather than rescanning them. Zero rescans every requested artifact.
+	DigestReuseWindow time.Duration `env:"SCANNER_JOB_QUEUE_DIGEST_REUSE_WINDOW"`
+	// FailedDigestThreshold is the number of permanent failures of a digest, e.g. because its manifest is unknown,
+	// after which requests to scan it fail fast with the error of the last failure, until it did not fail for
+	// FailedDigestCooldown. Zero scans every requested artifact.
+	FailedDigestThreshold int           `env:"SCANNER_JOB_QUEUE_FAILED_DIGEST_THRESHOLD"`
+	FailedDigestCooldown  time.Duration `env:"SCANNER_JOB_QUEUE_FAILED_DIGEST_COOLDOWN" envDefault:"1h"`
+	// MaxBacklog is the number of scan jobs waiting for a worker beyond which scan requests are rejected, so that
+	// Harbor retries them later rather than piling them up while the workers are saturated. Zero means no limit.
+	MaxBacklog int `env:"SCANNER_JOB_QUEUE_MAX_BACKLOG"`
+}
+
+// JobQueueDriver returns the configured job queue driver, which defaults to local with the memory store backend,
+// whose scan jobs cannot be shared among replicas anyway, and to redis otherwise.
+func (c Config) JobQueueDriver() string {
+	if c.JobQueue.Driver != "" {
+		return c.JobQueue.Driver
+	}
+	if c.Store.Backend == "memory" {
+		return "local"
+	}
+	return "redis"
+}
+
+// RequiresRedis returns true if scan jobs, or the job queue, are kept in Redis.
+func (c Config) RequiresRedis() bool {
+	return c.Store.Backend == "redis" || c.Store.MigrationTarget == "redis" || c.JobQueueDriver() == "redis"
+}
+
+// Impact configures the assessments, which re-evaluate the cached SBOMs of scanned artifacts against
+// the vulnerability database to find the artifacts affected by newly published vulnerabilities.
+type Impact struct {
+	// OnDBUpdate starts an assessment whenever the vulnerability database is updated.
+	OnDBUpdate     bool          `env:"SCANNER_IMPACT_ASSESSMENT_ON_DB_UPDATE" envDefault:"false"`
+	DBPollInterval time.Duration `env:"SCANNER_IMPACT_ASSESSMENT_DB_POLL_INTERVAL" envDefault:"5m"`
+	// WebhookURL receives alerts listing the artifacts newly affected by vulnerabilities of at least
+	// WebhookMinSeverity.
+	WebhookURL         string `env:"SCANNER_IMPACT_WEBHOOK_URL"`
+	WebhookMinSeverity string `env:"SCANNER_IMPACT_WEBHOOK_MIN_SEVERITY" envDefault:"High"`
+}
+
+// CleanScans configures the notifications of the scans which found no vulnerability.
+type CleanScans struct {
+	// WebhookURL is posted the artifacts scanned successfully without any vulnerability found.
+	WebhookURL string `env:"SCANNER_CLEAN_SCAN_WEBHOOK_URL"`
+}
+
+// Notifications configures the chat notifications of the scan reports whose severity is at least MinSeverity.
+// Each webhook URL is posted a human-readable summary of such reports, unless it is blank.
+type Notifications struct {
+	// SlackWebhookURL is the URL of a Slack incoming webhook.
+	SlackWebhookURL string `env:"SCANNER_NOTIFICATIONS_SLACK_WEBHOOK_URL"`
+	// TeamsWebhookURL is the URL of a Microsoft Teams incoming webhook, or of a Teams workflow triggered by
+	// webhook requests.
+	TeamsWebhookURL string `env:"SCANNER_NOTIFICATIONS_TEAMS_WEBHOOK_URL"`
+	MinSeverity     string `env:"SCANNER_NOTIFICATIONS_MIN_SEVERITY" envDefault:"High"`
+}
+
+// IsEnabled returns true if a webhook URL is set.
+func (n Notifications) IsEnabled() bool {
+	return n.SlackWebhookURL != "" || n.TeamsWebhookURL != ""
+}
+
+// Policy configures the risk-based policy, which the verdicts on scan reports are based on.
+type Policy struct {
+	// File is the path of the JSON policy file. Verdicts are disabled if it is blank.
+	File string `env:"SCANNER_POLICY_FILE"`
+	// BundleURL is the URL of the signed policy bundle, which holds the policy and the Tunnel ignore policy, and
+	// supersedes File and the Tunnel IgnorePolicy. It is either an OCI artifact, i.e.
+	// oci://registry/repository:tag, or a tarball, i.e. https://host/path.tar.gz.
+	BundleURL string `env:"SCANNER_POLICY_BUNDLE_URL"`
+	// BundlePublicKey is the path of the PEM encoded public key verifying the signature of the bundle.
+	BundlePublicKey       string        `env:"SCANNER_POLICY_BUNDLE_PUBLIC_KEY"`
+	BundleUsername        string        `env:"SCANNER_POLICY_BUNDLE_USERNAME"`
+	BundlePassword        string        `env:"SCANNER_POLICY_BUNDLE_PASSWORD"`
+	BundleRefreshInterval time.Duration `env:"SCANNER_POLICY_BUNDLE_REFRESH_INTERVAL" envDefault:"5m"`
+	// BundleDir is the directory the Tunnel ignore policy of the bundle is written to.
+	BundleDir string `env:"SCANNER_POLICY_BUNDLE_DIR" envDefault:"/home/scanner/.cache/policy-bundle"`
+	// MaxExceptionDuration caps the time policy exceptions may be requested for, zero for no cap.
+	MaxExceptionDuration time.Duration `env:"SCANNER_POLICY_MAX_EXCEPTION_DURATION" envDefault:"720h"`
+}
+
+type Feature struct {
+	// FlagsFile is the path of the JSON feature flags file. All subsystems are enabled as configured if it is blank.
+	FlagsFile string `env:"SCANNER_FEATURE_FLAGS_FILE"`
+}
+
+// Shadow configures the shadow mode, which scans a sample of the scanned images again with a secondary Tunnel,
+// e.g. a release being evaluated, and records how its findings differ from the findings reported to Harbor.
+type Shadow struct {
+	// Percentage is the percentage of the scans sampled for shadow scans. Zero disables the shadow mode.
+	Percentage int `env:"SCANNER_SHADOW_PERCENTAGE" envDefault:"0"`
+	// Concurrency is the number of shadow scans running at the same time. Sampled scans are skipped while
+	// all shadow scans are running, so that shadow scans never hold back scan jobs.
+	Concurrency int `env:"SCANNER_SHADOW_CONCURRENCY" envDefault:"1"`
+	// Executable and SandboxImage replace the Tunnel executable and sandbox image of the primary Tunnel.
+	Executable   string `env:"SCANNER_SHADOW_TUNNEL_EXECUTABLE"`
+	SandboxImage string `env:"SCANNER_SHADOW_TUNNEL_SANDBOX_IMAGE"`
+	// CacheDir keeps the vulnerability database of the secondary Tunnel apart from the primary one.
+	CacheDir string `env:"SCANNER_SHADOW_TUNNEL_CACHE_DIR" envDefault:"/home/scanner/.cache/tunnel-shadow"`
+}
+
+// IsEnabled returns true if any scan is sampled for shadow scans.
+func (c *Shadow) IsEnabled() bool {
+	return c.Percentage > 0
+}
+
+// TunnelConfig returns the configuration of the secondary Tunnel, i.e. the given configuration of the primary
+// Tunnel with the executable, sandbox image and cache dir of the shadow mode.
+func (c *Shadow) TunnelConfig(primary Tunnel) Tunnel {
+	config := primary
+	if c.Executable != "" {
+		config.Executable = c.Executable
+	}
+	if c.SandboxImage != "" {
+		config.SandboxImage = c.SandboxImage
+	}
+	config.CacheDir = c.CacheDir
+	return config
+}
+
+// Enrichment configures the hooks enriching scan reports before they are stored, e.g. with proprietary threat
+// intelligence, so that organizations do not need to fork the adapter.
+type Enrichment struct {
+	// Hooks are run in order. A hook is either the URL of an HTTP endpoint, which is posted the report, or the
+	// path of an executable, which reads the report from its standard input. Both respond with the enriched report.
+	Hooks   []string      `env:"SCANNER_ENRICHMENT_HOOKS"`
+	Timeout time.Duration `env:"SCANNER_ENRICHMENT_TIMEOUT" envDefault:"10s"`
+	// FailOnError fails scan jobs when a hook fails. Otherwise, the report enriched by the previous hooks is kept.
+	FailOnError bool `env:"SCANNER_ENRICHMENT_FAIL_ON_ERROR" envDefault:"false"`
+}
+
+// IsHTTPHook returns true if the given enrichment hook is the URL of an HTTP endpoint rather than an executable.
+func IsHTTPHook(hook string) bool {
+	return strings.HasPrefix(hook, "http://") || strings.HasPrefix(hook, "https://")
+}
+
+// OPA configures the Rego policy which post-processes reports before they are saved, i.e. filters, relabels and
+// annotates their findings. The policy is either evaluated by an OPA server at URL, or by the OPA executable with
+// the policies of PolicyPath. The policy is disabled unless either is set.
+type OPA struct {
+	// URL is the URL of the document of the decision in the Data API of an OPA server, e.g.
+	// http://opa:8181/v1/data/scanner/report.
+	URL string `env:"SCANNER_OPA_URL"`
+	// PolicyPath is the path of a Rego file, or of a directory of Rego files, evaluated with Executable.
+	PolicyPath string `env:"SCANNER_OPA_POLICY_PATH"`
+	// Query is the query of the decision evaluated with the policies of PolicyPath.
+	Query      string        `env:"SCANNER_OPA_QUERY" envDefault:"data.scanner.report"`
+	Executable string        `env:"SCANNER_OPA_EXECUTABLE" envDefault:"opa"`
+	Timeout    time.Duration `env:"SCANNER_OPA_TIMEOUT" envDefault:"10s"`
+	// FailOnError fails scan jobs when the policy cannot be evaluated. Otherwise, the report is saved as is.
+	FailOnError bool `env:"SCANNER_OPA_FAIL_ON_ERROR" envDefault:"false"`
+}
+
+// IsEnabled returns true if reports are post-processed by a Rego policy.
+func (c OPA) IsEnabled() bool {
+	return c.URL != "" || c.PolicyPath != ""
+}
+
+// Classification configures the classification of findings by where their fix is made, which is exposed as the
+// fix_stage vendor attribute of vulnerabilities. Language packages are fixed at build time, and OS packages at
+// runtime, unless the heuristics are overridden.
+type Classification struct {
+	Enabled bool `env:"SCANNER_CLASSIFICATION_ENABLED" envDefault:"false"`
+	// BuildTypes are the package types, e.g. npm or gomod, fixed at build time. Defaults to all language packages.
+	BuildTypes []string `env:"SCANNER_CLASSIFICATION_BUILD_TYPES"`
+	// RuntimeTypes are the package types fixed at runtime, e.g. gobinary if Go binaries are shipped by base images.
+	RuntimeTypes []string `env:"SCANNER_CLASSIFICATION_RUNTIME_TYPES"`
+	// RuntimePaths are the path prefixes of language packages installed by base images, e.g. /usr/lib/.
+	RuntimePaths []string `env:"SCANNER_CLASSIFICATION_RUNTIME_PATHS"`
+}
+
+// Allowlist configures the CVE allowlist of the adapter, whose vulnerabilities are dropped from the reports sent to
+// Harbor, or marked with the allowlisted vendor attribute. It applies to the artifacts of all projects, on top of
+// the CVE allowlists of Harbor projects, which Harbor applies itself, as they are not sent with scan requests.
+type Allowlist struct {
+	// CVEs are the allowlisted vulnerability IDs, each optionally followed by the date it expires on, e.g.
+	// CVE-2023-1234 exp:2025-06-30, as in the lines of File.
+	CVEs []string `env:"SCANNER_ALLOWLIST_CVES"`
+	// File is the path of a .tunnelignore style file, which lists a vulnerability ID per line, optionally followed
+	// by the date it expires on. Blank lines and comments starting with # are skipped.
+	File string `env:"SCANNER_ALLOWLIST_FILE"`
+	// Mode is either drop, which removes allowlisted vulnerabilities from reports, or mark.
+	Mode string `env:"SCANNER_ALLOWLIST_MODE" envDefault:"drop"`
+}
+
+// VEX configures the VEX documents, i.e. OpenVEX or CSAF VEX documents, which are loaded on start. Vulnerabilities
+// stated as not_affected or fixed in the scanned artifact, or in its vulnerable package, are kept in reports with
+// the vex_status vendor attribute, but neither raise their severity nor violate the policy.
+type VEX struct {
+	// Path is the path of a VEX document, or of a directory of VEX documents, i.e. its .json files.
+	Path string `env:"SCANNER_VEX_PATH"`
+	// OCIRef is the reference of an OCI artifact, whose layers of the OpenVEX, CSAF or JSON media types are VEX
+	// documents, i.e. oci://registry/repository:tag. Its registry is trusted like the registries of scan requests.
+	OCIRef      string `env:"SCANNER_VEX_OCI_REF"`
+	OCIUsername string `env:"SCANNER_VEX_OCI_USERNAME"`
+	OCIPassword string `env:"SCANNER_VEX_OCI_PASSWORD"`
+}
+
+// IsEnabled returns true if VEX documents are loaded from a path or an OCI artifact.
+func (c *VEX) IsEnabled() bool {
+	return c.Path != "" || c.OCIRef != ""
+}
+
+// NVD configures the live lookups of CVEs in the NVD 2.0 API, which fill in the CVSS details and descriptions the
+// offline vulnerability database lacks. The API is never called unless enabled.
+type NVD struct {
+	Enabled bool   `env:"SCANNER_NVD_ENABLED" envDefault:"false"`
+	URL     string `env:"SCANNER_NVD_URL" envDefault:"https://services.nvd.nist.gov/rest/json/cves/2.0"`
+	APIKey  string `env:"SCANNER_NVD_API_KEY"`
+	// RequestInterval spaces the requests to stay within the NVD's rate limits, i.e. 5 requests in 30 seconds
+	// without an API key, and 50 with one.
+	RequestInterval time.Duration `env:"SCANNER_NVD_REQUEST_INTERVAL" envDefault:"6s"`
+	CacheTTL        time.Duration `env:"SCANNER_NVD_CACHE_TTL" envDefault:"24h"`
+	CacheSize       int           `env:"SCANNER_NVD_CACHE_SIZE" envDefault:"10000"`
+	// Timeout bounds the lookups of a report, after which its remaining vulnerabilities are left as they are.
+	Timeout time.Duration `env:"SCANNER_NVD_TIMEOUT" envDefault:"30s"`
+}
+
+// EPSS configures the enrichment of CVEs with their EPSS scores, i.e. the probabilities of their exploitation in the
+// next 30 days, taken from the daily dataset of FIRST. The dataset is downloaded on start and at the refresh interval,
+// and cached in CacheDir, so that restarts within the interval and outages of its URL fall back to the cached dataset.
+type EPSS struct {
+	Enabled bool `env:"SCANNER_EPSS_ENABLED" envDefault:"false"`
+	// URL is the URL of the gzipped or plain CSV dataset, e.g. of a mirror in air-gapped deployments.
+	URL             string        `env:"SCANNER_EPSS_URL" envDefault:"https://epss.cyentia.com/epss_scores-current.csv.gz"`
+	RefreshInterval time.Duration `env:"SCANNER_EPSS_REFRESH_INTERVAL" envDefault:"24h"`
+	CacheDir        string        `env:"SCANNER_EPSS_CACHE_DIR" envDefault:"/home/scanner/.cache/epss"`
+}
+
+// SLO configures the service-level objective of scan jobs, e.g. 95% of scan jobs finish within 10 minutes of being
+// queued. The SLO is only tracked if the objective is set.
+type SLO struct {
+	// Objective is the percentage of scan jobs which must finish within the latency.
+	Objective float64       `env:"SCANNER_SLO_OBJECTIVE" envDefault:"0"`
+	Latency   time.Duration `env:"SCANNER_SLO_LATENCY" envDefault:"10m"`
+	// Window is the period over which the SLO is evaluated. It must not exceed the TTL of scan jobs.
+	Window time.Duration `env:"SCANNER_SLO_WINDOW" envDefault:"1h"`
+	// BurnRateThreshold is the burn rate of the error budget above which the SLO is alerting, i.e. 14.4 alerts
+	// when 2% of a 30 days error budget is consumed within an hour.
+	BurnRateThreshold float64 `env:"SCANNER_SLO_BURN_RATE_THRESHOLD" envDefault:"14.4"`
+}
+
+// IsEnabled returns true if the objective is set.
+func (s SLO) IsEnabled() bool {
+	return s.Objective > 0
+}
+
+// Audit configures the export of scan job lifecycle events and report summaries to a sink for long-term
+// analytics, which outlives the TTL of the scan jobs in the store.
+type Audit struct {
+	// Sink is none, kafka or s3.
+	Sink string `env:"SCANNER_AUDIT_SINK" envDefault:"none"`
+	// BufferSize bounds the events waiting to be exported, beyond which events are dropped rather than slowing
+	// down scans.
+	BufferSize    int           `env:"SCANNER_AUDIT_BUFFER_SIZE" envDefault:"10000"`
+	BatchSize     int           `env:"SCANNER_AUDIT_BATCH_SIZE" envDefault:"500"`
+	FlushInterval time.Duration `env:"SCANNER_AUDIT_FLUSH_INTERVAL" envDefault:"1m"`
+	// KafkaRESTURL is the URL of the Kafka REST Proxy the events are produced through.
+	KafkaRESTURL string `env:"SCANNER_AUDIT_KAFKA_REST_URL"`
+	KafkaTopic   string `env:"SCANNER_AUDIT_KAFKA_TOPIC" envDefault:"harbor-scanner-events"`
+	// S3Endpoint is the endpoint of an S3 compatible object storage, or blank for the AWS endpoint of S3Region.
+	S3Endpoint        string `env:"SCANNER_AUDIT_S3_ENDPOINT"`
+	S3Region          string `env:"SCANNER_AUDIT_S3_REGION" envDefault:"us-east-1"`
+	S3Bucket          string `env:"SCANNER_AUDIT_S3_BUCKET"`
+	S3Prefix          string `env:"SCANNER_AUDIT_S3_PREFIX" envDefault:"events"`
+	S3AccessKeyID     string `env:"SCANNER_AUDIT_S3_ACCESS_KEY_ID"`
+	S3SecretAccessKey string `env:"SCANNER_AUDIT_S3_SECRET_ACCESS_KEY"`
+}
+
+// IsEnabled returns true if a sink is configured.
+func (a Audit) IsEnabled() bool {
+	return a.Sink != "" && a.Sink != "none"
+}
+
+// Telemetry reports aggregate, anonymized usage counters to the maintainers. It is disabled unless opted in.
+type Telemetry struct {
+	Enabled  bool          `env:"SCANNER_TELEMETRY_ENABLED" envDefault:"false"`
+	Endpoint string        `env:"SCANNER_TELEMETRY_ENDPOINT"`
+	Interval time.Duration `env:"SCANNER_TELEMETRY_INTERVAL" envDefault:"24h"`
+}
+
+// Tracing configures the export of OpenTelemetry traces following scan requests through the job queue, Tunnel,
+// the transformer and the store. Spans are exported over OTLP/HTTP, and nothing is traced unless enabled.
+type Tracing struct {
+	Enabled bool `env:"SCANNER_TRACING_ENABLED" envDefault:"false"`
+	// OTLPEndpoint is the base URL of the OTLP/HTTP receiver, to which spans are posted at /v1/traces.
+	OTLPEndpoint string `env:"SCANNER_TRACING_OTLP_ENDPOINT" envDefault:"http://localhost:4318"`
+	// OTLPHeaders are the name=value headers added to the requests to the receiver, e.g. an API key.
+	OTLPHeaders []string `env:"SCANNER_TRACING_OTLP_HEADERS"`
+	ServiceName string   `env:"SCANNER_TRACING_SERVICE_NAME" envDefault:"harbor-scanner-tunnel"`
+	// SampleRatio is the ratio of the traces started by the adapter which are sampled. Requests carrying a trace
+	// context follow the sampling decision of their caller.
+	SampleRatio float64 `env:"SCANNER_TRACING_SAMPLE_RATIO" envDefault:"1"`
+}
+
+// Headers returns the headers added to the requests to the OTLP receiver keyed by name.
+func (t Tracing) Headers() map[string]string {
+	headers := make(map[string]string, len(t.OTLPHeaders))
+	for _, entry := range t.OTLPHeaders {
+		name, value, _ := strings.Cut(entry, "=")
+		headers[name] = value
+	}
+	return headers
+}
+
+// Connectivity configures the probes of the outbound destinations of the adapter, i.e. the registries of recent
+// scan requests, the source of vulnerability database updates and the webhook targets. Probes are disabled unless
+// explicitly enabled.
+type Connectivity struct {
+	Enabled  bool          `env:"SCANNER_CONNECTIVITY_PROBES_ENABLED" envDefault:"false"`
+	Interval time.Duration `env:"SCANNER_CONNECTIVITY_PROBE_INTERVAL" envDefault:"1m"`
+	Timeout  time.Duration `env:"SCANNER_CONNECTIVITY_PROBE_TIMEOUT" envDefault:"5s"`
+	// DBSourceURL is the URL vulnerability database updates are downloaded from.
+	DBSourceURL string `env:"SCANNER_CONNECTIVITY_DB_SOURCE_URL" envDefault:"https://api.github.com"`
+	// RegistryWindow is how long a registry is probed after its last scan request.
+	RegistryWindow time.Duration `env:"SCANNER_CONNECTIVITY_REGISTRY_WINDOW" envDefault:"1h"`
+}
+
+// CRD configures the controller of ScanRequest custom resources, which requests scans declaratively from within a
+// Kubernetes cluster and records their results in ScanReport custom resources. The controller is disabled unless
+// explicitly enabled.
+type CRD struct {
+	Enabled bool `env:"SCANNER_CRD_ENABLED" envDefault:"false"`
+	// Namespace is the namespace whose ScanRequests are watched, or blank to watch all namespaces.
+	Namespace    string        `env:"SCANNER_CRD_NAMESPACE"`
+	PollInterval time.Duration `env:"SCANNER_CRD_POLL_INTERVAL" envDefault:"10s"`
+	// MaxVulnerabilities is the maximum number of vulnerabilities listed in a ScanReport, the most severe first,
+	// which keeps it within the size limit of Kubernetes objects. Zero lists none.
+	MaxVulnerabilities int `env:"SCANNER_CRD_MAX_VULNERABILITIES" envDefault:"500"`
+}
+
+// GRPC configures the gRPC API, which serves the scan submission and report retrieval operations of the v1 API to
+// internal tooling, with the same authentication and TLS configuration. It is disabled unless its address is set.
+type GRPC struct {
+	Addr string `env:"SCANNER_GRPC_ADDR"`
+	// WatchPollInterval is the interval at which the status of the scan jobs whose reports are watched is polled.
+	WatchPollInterval time.Duration `env:"SCANNER_GRPC_WATCH_POLL_INTERVAL" envDefault:"1s"`
+}
+
+// IsEnabled returns true if the gRPC API is served.
+func (c GRPC) IsEnabled() bool {
+	return c.Addr != ""
 }
 
 type RedisPool struct {
@@ -72,6 +743,15 @@ type RedisPool struct {
 	ConnectionTimeout time.Duration `env:"SCANNER_REDIS_POOL_CONNECTION_TIMEOUT" envDefault:"1s"`
 	ReadTimeout       time.Duration `env:"SCANNER_REDIS_POOL_READ_TIMEOUT" envDefault:"1s"`
 	WriteTimeout      time.Duration `env:"SCANNER_REDIS_POOL_WRITE_TIMEOUT" envDefault:"1s"`
+	// SlowCommandThreshold is the duration from which Redis commands are logged as slow, or zero to disable it.
+	SlowCommandThreshold time.Duration `env:"SCANNER_REDIS_SLOW_COMMAND_THRESHOLD" envDefault:"0"`
+	// TLSEnabled connects to Redis over TLS, whose certificates are verified with the system roots and the
+	// certificates of TLSCABundle. TLSCertificate and TLSKey authenticate the adapter with mutual TLS.
+	TLSEnabled            bool   `env:"SCANNER_REDIS_TLS_ENABLED" envDefault:"false"`
+	TLSCABundle           string `env:"SCANNER_REDIS_TLS_CA_BUNDLE"`
+	TLSCertificate        string `env:"SCANNER_REDIS_TLS_CERTIFICATE"`
+	TLSKey                string `env:"SCANNER_REDIS_TLS_KEY"`
+	TLSInsecureSkipVerify bool   `env:"SCANNER_REDIS_TLS_INSECURE_SKIP_VERIFY" envDefault:"false"`
 }
 
 func LogLevel() slog.Level {
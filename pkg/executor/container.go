@@ -0,0 +1,104 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/ext"
+)
+
+// passedEnv are the variables of the environment of the adapter passed to Tunnel processes running in
+// containers, in addition to the ones of the Spec. Other variables, e.g. PATH, would not make sense there.
+var passedEnv = []string{
+	"SSL_CERT_DIR",
+	"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY",
+	"http_proxy", "https_proxy", "no_proxy",
+}
+
+type containerDriver struct {
+	config     etc.Tunnel
+	ambassador ext.Ambassador
+	// user is the uid:gid containers run as.
+	user string
+}
+
+// NewContainerDriver constructs a Driver, which runs each Tunnel process in its own container of the configured
+// SandboxImage with a Docker compatible CLI. Containers run as the user of the adapter, so that the adapter can
+// read and remove the files they write, and the mounts of the Spec are the only directories they can write.
+func NewContainerDriver(config etc.Tunnel, ambassador ext.Ambassador) Driver {
+	return &containerDriver{
+		config:     config,
+		ambassador: ambassador,
+		user:       fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()),
+	}
+}
+
+func (d *containerDriver) Command(spec Spec) (*exec.Cmd, error) {
+	name, err := d.ambassador.LookPath(d.config.SandboxCLI)
+	if err != nil {
+		return nil, err
+	}
+	environ := d.ambassador.Environ()
+
+	runArgs := []string{
+		"run", "--rm",
+		"--read-only", "--tmpfs", "/tmp",
+		"--cap-drop", "ALL",
+		"--security-opt", "no-new-privileges",
+		"--user", d.user,
+	}
+	if d.config.SandboxRuntime != "" {
+		runArgs = append(runArgs, "--runtime", d.config.SandboxRuntime)
+	}
+	if d.config.SandboxNetwork != "" {
+		runArgs = append(runArgs, "--network", d.config.SandboxNetwork)
+	}
+
+	for _, m := range spec.Mounts {
+		volume := m.Path + ":" + m.Path
+		if m.ReadOnly {
+			volume += ":ro"
+		}
+		runArgs = append(runArgs, "--volume", volume)
+	}
+
+	names, values := containerEnv(environ, spec.Env)
+	for _, dir := range filepath.SplitList(values["SSL_CERT_DIR"]) {
+		runArgs = append(runArgs, "--volume", dir+":"+dir+":ro")
+	}
+	// Variables are passed by name, so that their values, e.g. registry passwords, don't show up in the args
+	// of the CLI process. The CLI reads them from its own environment.
+	for _, key := range names {
+		runArgs = append(runArgs, "--env", key)
+	}
+
+	runArgs = append(runArgs, d.config.SandboxImage, Executable(d.config))
+	cmd := exec.Command(name, append(runArgs, spec.Args...)...)
+	cmd.Env = append(environ, spec.Env...)
+	return cmd, nil
+}
+
+func (d *containerDriver) Release(_ *exec.Cmd) {}
+
+// containerEnv returns the names of the variables set for Tunnel processes running in containers, in the order
+// they're first set, and their last values.
+func containerEnv(environ, env []string) ([]string, map[string]string) {
+	var names []string
+	values := make(map[string]string)
+	for i, kv := range append(slices.Clip(environ), env...) {
+		key, value, _ := strings.Cut(kv, "=")
+		if i < len(environ) && !slices.Contains(passedEnv, key) {
+			continue
+		}
+		if _, ok := values[key]; !ok {
+			names = append(names, key)
+		}
+		values[key] = value
+	}
+	return names, values
+}
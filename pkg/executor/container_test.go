@@ -0,0 +1,70 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/ext"
+)
+
+func TestContainerDriver_Command(t *testing.T) {
+	ambassador := ext.NewMockAmbassador()
+	ambassador.On("Environ").Return([]string{"PATH=/usr/bin", "HTTPS_PROXY=http://someproxy:7777", "SSL_CERT_DIR=/etc/ssl/certs"})
+	ambassador.On("LookPath", "docker").Return("/usr/bin/docker", nil)
+
+	d := NewContainerDriver(etc.Tunnel{
+		SandboxCLI:     "docker",
+		SandboxImage:   "khulnasoft/tunnel:0.50.1",
+		SandboxRuntime: "runsc",
+		SandboxNetwork: "host",
+	}, ambassador).(*containerDriver)
+	d.user = "1000:1000"
+
+	cmd, err := d.Command(Spec{
+		Args: []string{"--cache-dir", "/home/scanner/.cache/tunnel", "image", "alpine:3.10.2"},
+		Env: []string{
+			"TUNNEL_TIMEOUT=5m0s",
+			"TUNNEL_PASSWORD=s3cret",
+			"SSL_CERT_DIR=/etc/registry/internal:/etc/ssl/certs",
+		},
+		Mounts: []Mount{
+			{Path: "/home/scanner/.cache/tunnel"},
+			{Path: "/home/scanner/opa/policy.rego", ReadOnly: true},
+		},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "/usr/bin/docker", cmd.Path)
+	assert.Equal(t, []string{
+		"/usr/bin/docker",
+		"run", "--rm",
+		"--read-only", "--tmpfs", "/tmp",
+		"--cap-drop", "ALL",
+		"--security-opt", "no-new-privileges",
+		"--user", "1000:1000",
+		"--runtime", "runsc",
+		"--network", "host",
+		"--volume", "/home/scanner/.cache/tunnel:/home/scanner/.cache/tunnel",
+		"--volume", "/home/scanner/opa/policy.rego:/home/scanner/opa/policy.rego:ro",
+		"--volume", "/etc/registry/internal:/etc/registry/internal:ro",
+		"--volume", "/etc/ssl/certs:/etc/ssl/certs:ro",
+		"--env", "HTTPS_PROXY",
+		"--env", "SSL_CERT_DIR",
+		"--env", "TUNNEL_TIMEOUT",
+		"--env", "TUNNEL_PASSWORD",
+		"khulnasoft/tunnel:0.50.1",
+		"tunnel",
+		"--cache-dir", "/home/scanner/.cache/tunnel", "image", "alpine:3.10.2",
+	}, cmd.Args)
+	assert.Equal(t, []string{
+		"PATH=/usr/bin",
+		"HTTPS_PROXY=http://someproxy:7777",
+		"SSL_CERT_DIR=/etc/ssl/certs",
+		"TUNNEL_TIMEOUT=5m0s",
+		"TUNNEL_PASSWORD=s3cret",
+		"SSL_CERT_DIR=/etc/registry/internal:/etc/ssl/certs",
+	}, cmd.Env)
+}
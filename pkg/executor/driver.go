@@ -0,0 +1,70 @@
+// Package executor runs Tunnel processes with the execution driver chosen by the configuration, which trades
+// the isolation of the scanned images for the cost of starting each process.
+package executor
+
+import (
+	"os/exec"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/ext"
+)
+
+// Names of the execution drivers.
+const (
+	// DriverProcess runs Tunnel as a child process of the adapter.
+	DriverProcess = "process"
+	// DriverContainer runs each Tunnel process in its own container with a read-only root filesystem, dropped
+	// capabilities and, with a sandboxing runtime such as gVisor, its own kernel.
+	DriverContainer = "container"
+	// DriverKubernetes runs each Tunnel process in its own Kubernetes pod, so that scans are scheduled on the
+	// nodes of the cluster rather than on the node of the adapter.
+	DriverKubernetes = "kubernetes"
+)
+
+// tunnelCmd is the name of the Tunnel executable, unless configured otherwise.
+const tunnelCmd = "tunnel"
+
+// executableOf returns the configured Tunnel executable.
+func Executable(config etc.Tunnel) string {
+	if config.Executable != "" {
+		return config.Executable
+	}
+	return tunnelCmd
+}
+
+// Spec describes a Tunnel process.
+type Spec struct {
+	// Args are the args of the tunnel executable.
+	Args []string
+	// Env holds the variables set for Tunnel, in addition to the environment of the adapter.
+	Env []string
+	// Mounts are the directories of the adapter the process reads or writes.
+	Mounts []Mount
+}
+
+// Mount is a directory, or a single file, of the adapter, which is mounted at the same path for Tunnel processes
+// running in containers, so that their args don't change.
+type Mount struct {
+	Path     string
+	ReadOnly bool
+}
+
+// Driver wraps the Command and Release methods.
+// Command returns the command running the Tunnel process with the given Spec.
+// Release removes what was created for the given command by Command, once the command has run or failed to.
+type Driver interface {
+	Command(spec Spec) (*exec.Cmd, error)
+	Release(cmd *exec.Cmd)
+}
+
+// NewDriver constructs the Driver configured by the ExecutionDriver setting.
+func NewDriver(config etc.Tunnel, ambassador ext.Ambassador) Driver {
+	switch config.ExecutionDriver {
+	case DriverContainer:
+		return NewContainerDriver(config, ambassador)
+	case DriverKubernetes:
+		return NewKubernetesDriver(config, ambassador)
+	default:
+		return NewProcessDriver(config, ambassador)
+	}
+}
@@ -0,0 +1,228 @@
+package executor
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/ext"
+)
+
+const (
+	kubectlCmd = "kubectl"
+	// kubernetesVolume is the name of the volume of the pods holding the configured claim.
+	kubernetesVolume = "scanner"
+)
+
+type kubernetesDriver struct {
+	config     etc.Tunnel
+	ambassador ext.Ambassador
+	podName    func() string
+	// secrets maps the commands returned by Command to the names of the Secrets holding their variables.
+	secrets sync.Map
+}
+
+// NewKubernetesDriver constructs a Driver, which runs each Tunnel process in its own pod of the configured
+// SandboxImage with kubectl. The configured volume claim is mounted at the same path in the adapter and in the
+// pods, hence the mounts of the Spec must be in that path. The SandboxRuntime setting is the runtime class of
+// the pods, e.g. gvisor. The variables of the Spec, including registry credentials, are kept in a Secret named
+// after the pod, which is created by Command and deleted by Release, so that they are neither on the kubectl
+// command line nor in the pod spec.
+func NewKubernetesDriver(config etc.Tunnel, ambassador ext.Ambassador) Driver {
+	return &kubernetesDriver{
+		config:     config,
+		ambassador: ambassador,
+		podName:    randomPodName,
+	}
+}
+
+type podOverrides struct {
+	APIVersion string  `json:"apiVersion"`
+	Spec       podSpec `json:"spec"`
+}
+
+type podSpec struct {
+	RuntimeClassName string      `json:"runtimeClassName,omitempty"`
+	Containers       []container `json:"containers"`
+	Volumes          []volume    `json:"volumes"`
+}
+
+type container struct {
+	Name            string          `json:"name"`
+	Image           string          `json:"image"`
+	Command         []string        `json:"command"`
+	Args            []string        `json:"args"`
+	EnvFrom         []envFrom       `json:"envFrom,omitempty"`
+	VolumeMounts    []volumeMount   `json:"volumeMounts"`
+	SecurityContext securityContext `json:"securityContext"`
+}
+
+type envFrom struct {
+	SecretRef secretRef `json:"secretRef"`
+}
+
+type secretRef struct {
+	Name string `json:"name"`
+}
+
+type secret struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   objectMeta        `json:"metadata"`
+	Type       string            `json:"type"`
+	StringData map[string]string `json:"stringData"`
+}
+
+type objectMeta struct {
+	Name string `json:"name"`
+}
+
+type volumeMount struct {
+	Name      string `json:"name"`
+	MountPath string `json:"mountPath"`
+	ReadOnly  bool   `json:"readOnly,omitempty"`
+}
+
+type securityContext struct {
+	ReadOnlyRootFilesystem   bool         `json:"readOnlyRootFilesystem"`
+	AllowPrivilegeEscalation bool         `json:"allowPrivilegeEscalation"`
+	Capabilities             capabilities `json:"capabilities"`
+}
+
+type capabilities struct {
+	Drop []string `json:"drop"`
+}
+
+type volume struct {
+	Name                  string            `json:"name"`
+	PersistentVolumeClaim volumeClaimSource `json:"persistentVolumeClaim"`
+}
+
+type volumeClaimSource struct {
+	ClaimName string `json:"claimName"`
+}
+
+func (d *kubernetesDriver) Command(spec Spec) (*exec.Cmd, error) {
+	volumePath := filepath.Clean(d.config.KubernetesVolumePath)
+	for _, m := range spec.Mounts {
+		if rel, err := filepath.Rel(volumePath, m.Path); err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+			return nil, fmt.Errorf("%s is not in kubernetes volume path %s", m.Path, volumePath)
+		}
+	}
+
+	name, err := d.ambassador.LookPath(kubectlCmd)
+	if err != nil {
+		return nil, err
+	}
+	environ := d.ambassador.Environ()
+
+	podName := d.podName()
+	_, values := containerEnv(environ, spec.Env)
+	var env []envFrom
+	if len(values) > 0 {
+		env = []envFrom{{SecretRef: secretRef{Name: podName}}}
+	}
+
+	overrides, err := json.Marshal(podOverrides{
+		APIVersion: "v1",
+		Spec: podSpec{
+			RuntimeClassName: d.config.SandboxRuntime,
+			Containers: []container{{
+				Name:         podName,
+				Image:        d.config.SandboxImage,
+				Command:      []string{Executable(d.config)},
+				Args:         spec.Args,
+				EnvFrom:      env,
+				VolumeMounts: []volumeMount{{Name: kubernetesVolume, MountPath: volumePath}},
+				SecurityContext: securityContext{
+					ReadOnlyRootFilesystem:   true,
+					AllowPrivilegeEscalation: false,
+					Capabilities:             capabilities{Drop: []string{"ALL"}},
+				},
+			}},
+			Volumes: []volume{{
+				Name:                  kubernetesVolume,
+				PersistentVolumeClaim: volumeClaimSource{ClaimName: d.config.KubernetesVolumeClaim},
+			}},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshalling pod overrides: %w", err)
+	}
+
+	cmd := exec.Command(name, d.namespaced("run", podName,
+		"--image", d.config.SandboxImage,
+		"--restart", "Never",
+		"--rm", "--attach", "--quiet",
+		"--overrides", string(overrides),
+	)...)
+	cmd.Env = environ
+
+	if len(values) > 0 {
+		if err := d.createSecret(name, environ, podName, values); err != nil {
+			return nil, err
+		}
+		d.secrets.Store(cmd, podName)
+	}
+	return cmd, nil
+}
+
+// Release deletes the Secret created for the given command, if any.
+func (d *kubernetesDriver) Release(cmd *exec.Cmd) {
+	name, ok := d.secrets.LoadAndDelete(cmd)
+	if !ok {
+		return
+	}
+	deleteCmd := exec.Command(cmd.Path, d.namespaced("delete", "secret", name.(string), "--ignore-not-found")...)
+	deleteCmd.Env = cmd.Env
+	if out, err := d.ambassador.RunCmd(deleteCmd); err != nil {
+		slog.Warn("Error while deleting secret of tunnel pod", slog.String("secret", name.(string)),
+			slog.String("err", err.Error()), slog.String("std_out", string(out)))
+	}
+}
+
+// createSecret creates the Secret holding the given variables of the pod with the given name. The manifest is
+// piped to kubectl, which keeps the values off its command line.
+func (d *kubernetesDriver) createSecret(kubectl string, environ []string, podName string, values map[string]string) error {
+	manifest, err := json.Marshal(secret{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Metadata:   objectMeta{Name: podName},
+		Type:       "Opaque",
+		StringData: values,
+	})
+	if err != nil {
+		return fmt.Errorf("marshalling pod secret: %w", err)
+	}
+
+	cmd := exec.Command(kubectl, d.namespaced("create", "--filename", "-")...)
+	cmd.Env = environ
+	cmd.Stdin = bytes.NewReader(manifest)
+	if out, err := d.ambassador.RunCmd(cmd); err != nil {
+		return fmt.Errorf("creating pod secret: %w: %s", err, out)
+	}
+	return nil
+}
+
+// namespaced returns the given kubectl args with the configured namespace, if any.
+func (d *kubernetesDriver) namespaced(args ...string) []string {
+	if d.config.KubernetesNamespace != "" {
+		args = append(args, "--namespace", d.config.KubernetesNamespace)
+	}
+	return args
+}
+
+// randomPodName returns a unique name for the pod of a Tunnel process.
+func randomPodName() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return "tunnel-" + hex.EncodeToString(b)
+}
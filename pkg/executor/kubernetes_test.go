@@ -0,0 +1,101 @@
+package executor
+
+import (
+	"errors"
+	"io"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/ext"
+)
+
+func TestKubernetesDriver_Command(t *testing.T) {
+	config := etc.Tunnel{
+		SandboxImage:          "khulnasoft/tunnel:0.50.1",
+		SandboxRuntime:        "gvisor",
+		KubernetesNamespace:   "harbor",
+		KubernetesVolumeClaim: "scanner-cache",
+		KubernetesVolumePath:  "/home/scanner/.cache",
+	}
+
+	t.Run("Should run Tunnel in pod with variables in secret", func(t *testing.T) {
+		var manifest []byte
+		ambassador := ext.NewMockAmbassador()
+		ambassador.On("Environ").Return([]string{"PATH=/usr/bin", "NO_PROXY=.svc"})
+		ambassador.On("LookPath", "kubectl").Return("/usr/bin/kubectl", nil)
+		ambassador.On("RunCmd", mock.MatchedBy(func(cmd *exec.Cmd) bool {
+			return cmd.Args[1] == "create"
+		})).Run(func(args mock.Arguments) {
+			cmd := args.Get(0).(*exec.Cmd)
+			assert.Equal(t, []string{"/usr/bin/kubectl", "create", "--filename", "-", "--namespace", "harbor"}, cmd.Args)
+			manifest, _ = io.ReadAll(cmd.Stdin)
+		}).Return([]byte{}, nil).Once()
+		ambassador.On("RunCmd", mock.MatchedBy(func(cmd *exec.Cmd) bool {
+			return cmd.Args[1] == "delete"
+		})).Run(func(args mock.Arguments) {
+			assert.Equal(t, []string{"/usr/bin/kubectl", "delete", "secret", "tunnel-0a1b2c", "--ignore-not-found",
+				"--namespace", "harbor"}, args.Get(0).(*exec.Cmd).Args)
+		}).Return([]byte{}, nil).Once()
+
+		d := NewKubernetesDriver(config, ambassador).(*kubernetesDriver)
+		d.podName = func() string { return "tunnel-0a1b2c" }
+
+		cmd, err := d.Command(Spec{
+			Args:   []string{"--cache-dir", "/home/scanner/.cache/tunnel", "version"},
+			Env:    []string{"TUNNEL_TIMEOUT=5m0s"},
+			Mounts: []Mount{{Path: "/home/scanner/.cache/tunnel"}, {Path: "/home/scanner/.cache/reports"}},
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"/usr/bin/kubectl", "run", "tunnel-0a1b2c",
+			"--image", "khulnasoft/tunnel:0.50.1",
+			"--restart", "Never",
+			"--rm", "--attach", "--quiet",
+			"--overrides", `{"apiVersion":"v1","spec":{"runtimeClassName":"gvisor","containers":[{` +
+				`"name":"tunnel-0a1b2c","image":"khulnasoft/tunnel:0.50.1","command":["tunnel"],` +
+				`"args":["--cache-dir","/home/scanner/.cache/tunnel","version"],` +
+				`"envFrom":[{"secretRef":{"name":"tunnel-0a1b2c"}}],` +
+				`"volumeMounts":[{"name":"scanner","mountPath":"/home/scanner/.cache"}],` +
+				`"securityContext":{"readOnlyRootFilesystem":true,"allowPrivilegeEscalation":false,"capabilities":{"drop":["ALL"]}}}],` +
+				`"volumes":[{"name":"scanner","persistentVolumeClaim":{"claimName":"scanner-cache"}}]}}`,
+			"--namespace", "harbor",
+		}, cmd.Args)
+		assert.JSONEq(t, `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"tunnel-0a1b2c"},"type":"Opaque",`+
+			`"stringData":{"NO_PROXY":".svc","TUNNEL_TIMEOUT":"5m0s"}}`, string(manifest))
+
+		d.Release(cmd)
+		d.Release(cmd)
+		ambassador.AssertExpectations(t)
+	})
+
+	t.Run("Should return error when secret cannot be created", func(t *testing.T) {
+		ambassador := ext.NewMockAmbassador()
+		ambassador.On("Environ").Return([]string{"PATH=/usr/bin"})
+		ambassador.On("LookPath", "kubectl").Return("/usr/bin/kubectl", nil)
+		ambassador.On("RunCmd", mock.Anything).Return([]byte("forbidden"), errors.New("exit status 1"))
+
+		d := NewKubernetesDriver(config, ambassador).(*kubernetesDriver)
+		d.podName = func() string { return "tunnel-0a1b2c" }
+
+		_, err := d.Command(Spec{Args: []string{"version"}, Env: []string{"TUNNEL_TIMEOUT=5m0s"}})
+
+		assert.EqualError(t, err, "creating pod secret: exit status 1: forbidden")
+	})
+
+	t.Run("Should return error when mount is not in volume path", func(t *testing.T) {
+		ambassador := ext.NewMockAmbassador()
+
+		_, err := NewKubernetesDriver(config, ambassador).Command(Spec{
+			Args:   []string{"image", "alpine:3.10.2"},
+			Mounts: []Mount{{Path: "/home/scanner/opa/policy.rego", ReadOnly: true}},
+		})
+
+		assert.EqualError(t, err, "/home/scanner/opa/policy.rego is not in kubernetes volume path /home/scanner/.cache")
+	})
+}
@@ -0,0 +1,34 @@
+package executor
+
+import (
+	"os/exec"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/ext"
+)
+
+type processDriver struct {
+	config     etc.Tunnel
+	ambassador ext.Ambassador
+}
+
+// NewProcessDriver constructs a Driver, which runs Tunnel as a child process of the adapter. It is the fastest
+// driver, but a vulnerability of Tunnel exploited by a scanned image compromises the adapter.
+func NewProcessDriver(config etc.Tunnel, ambassador ext.Ambassador) Driver {
+	return &processDriver{config: config, ambassador: ambassador}
+}
+
+func (d *processDriver) Command(spec Spec) (*exec.Cmd, error) {
+	name, err := d.ambassador.LookPath(Executable(d.config))
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(name, spec.Args...)
+
+	cmd.Env = append(d.ambassador.Environ(), spec.Env...)
+
+	return cmd, nil
+}
+
+func (d *processDriver) Release(_ *exec.Cmd) {}
@@ -13,6 +13,7 @@ var (
 type File interface {
 	Name() string
 	Read([]byte) (int, error)
+	Write([]byte) (int, error)
 }
 
 // Ambassador the ambassador to the outside "world". Wraps methods that modify global state and hence make the code that
@@ -23,6 +24,7 @@ type Ambassador interface {
 	RunCmd(cmd *exec.Cmd) ([]byte, error)
 	TempFile(dir, pattern string) (File, error)
 	Remove(name string) error
+	RemoveAll(path string) error
 }
 
 type ambassador struct {
@@ -44,6 +46,10 @@ func (a *ambassador) Remove(name string) error {
 	return os.Remove(name)
 }
 
+func (a *ambassador) RemoveAll(path string) error {
+	return os.RemoveAll(path)
+}
+
 func (a *ambassador) LookPath(file string) (string, error) {
 	return exec.LookPath(file)
 }
@@ -12,6 +12,7 @@ type FakeFile struct {
 	name    string
 	content string
 	reader  io.Reader
+	written strings.Builder
 }
 
 // NewFakeFile constructs a new FakeFile with the given name and content.
@@ -31,6 +32,15 @@ func (ff *FakeFile) Read(p []byte) (int, error) {
 	return ff.reader.Read(p)
 }
 
+func (ff *FakeFile) Write(p []byte) (int, error) {
+	return ff.written.Write(p)
+}
+
+// Written returns the content written to the FakeFile.
+func (ff *FakeFile) Written() string {
+	return ff.written.String()
+}
+
 type MockAmbassador struct {
 	mock.Mock
 }
@@ -63,3 +73,8 @@ func (m *MockAmbassador) Remove(name string) error {
 	args := m.Called(name)
 	return args.Error(0)
 }
+
+func (m *MockAmbassador) RemoveAll(path string) error {
+	args := m.Called(path)
+	return args.Error(0)
+}
@@ -0,0 +1,117 @@
+// Package feature gates subsystems behind flags, which enable them per deployment and per Harbor project,
+// so that large fleets served by the same adapter build can roll them out progressively.
+package feature
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+)
+
+// Names of the flags gating subsystems.
+const (
+	// SBOM gates storing the SBOMs of scanned artifacts and scanning the stored SBOMs.
+	SBOM = "sbom"
+	// Webhooks gates the alerts posted by impact assessments, and the notifications of scan reports.
+	Webhooks = "webhooks"
+	// RegistrySBOM gates scanning the SBOMs attached to artifacts in the registry instead of their images.
+	RegistrySBOM = "registry-sbom"
+)
+
+var names = []string{SBOM, Webhooks, RegistrySBOM}
+
+// Config is the feature flags configuration file.
+//
+// Subsystems without a flag are enabled as configured by the environment. A flag can only narrow down
+// where a subsystem is enabled, it cannot enable a subsystem which is disabled by the environment.
+type Config struct {
+	Flags map[string]Flag `json:"flags"`
+}
+
+// Flag enables a subsystem for the Harbor projects whose names match any of the Projects patterns, as
+// defined by path.Match, or for all projects if there is no pattern. Enabled turns the subsystem off for
+// the whole deployment when false.
+type Flag struct {
+	Enabled  bool     `json:"enabled"`
+	Projects []string `json:"projects"`
+}
+
+// Load reads and validates the feature flags from the given JSON file.
+func Load(file string) (Config, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return Config{}, fmt.Errorf("reading feature flags: %w", err)
+	}
+	defer f.Close()
+
+	var c Config
+	decoder := json.NewDecoder(f)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&c); err != nil {
+		return Config{}, fmt.Errorf("decoding feature flags: %w", err)
+	}
+
+	if err := c.Validate(); err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
+
+// Validate returns an error if the feature flags are invalid.
+func (c Config) Validate() error {
+	for name, flag := range c.Flags {
+		if !slices.Contains(names, name) {
+			return fmt.Errorf("unsupported feature flag: %s", name)
+		}
+		for _, project := range flag.Projects {
+			if _, err := path.Match(project, ""); err != nil {
+				return fmt.Errorf("feature flag %s project %q: %w", name, project, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Flags wraps the Enabled method.
+// Enabled returns true if the subsystem gated by the given flag is enabled for the given Harbor project.
+type Flags interface {
+	Enabled(name, project string) bool
+}
+
+type flags struct {
+	config Config
+}
+
+// NewFlags constructs Flags from the given Config, which must be valid.
+func NewFlags(config Config) (Flags, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &flags{config: config}, nil
+}
+
+// AllEnabled returns Flags enabling all subsystems for all projects.
+func AllEnabled() Flags {
+	return &flags{}
+}
+
+func (f *flags) Enabled(name, project string) bool {
+	flag, ok := f.config.Flags[name]
+	if !ok {
+		return true
+	}
+	if !flag.Enabled {
+		return false
+	}
+	if len(flag.Projects) == 0 {
+		return true
+	}
+	for _, pattern := range flag.Projects {
+		if matched, _ := path.Match(pattern, project); matched {
+			return true
+		}
+	}
+	return false
+}
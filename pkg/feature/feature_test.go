@@ -0,0 +1,94 @@
+package feature
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	testCases := []struct {
+		name           string
+		content        string
+		expectedConfig Config
+		expectedError  string
+	}{
+		{
+			name:    "Should load feature flags",
+			content: `{"flags": {"sbom": {"enabled": true, "projects": ["team-*"]}, "webhooks": {"enabled": false}}}`,
+			expectedConfig: Config{
+				Flags: map[string]Flag{
+					SBOM:     {Enabled: true, Projects: []string{"team-*"}},
+					Webhooks: {Enabled: false},
+				},
+			},
+		},
+		{
+			name:          "Should return error when flag is not supported",
+			content:       `{"flags": {"telemetry": {"enabled": true}}}`,
+			expectedError: "unsupported feature flag: telemetry",
+		},
+		{
+			name:          "Should return error when project pattern is malformed",
+			content:       `{"flags": {"sbom": {"enabled": true, "projects": ["team-["]}}}`,
+			expectedError: `feature flag sbom project "team-[": syntax error in pattern`,
+		},
+		{
+			name:          "Should return error when field is unknown",
+			content:       `{"flags": {"sbom": {"enabled": true, "percentage": 10}}}`,
+			expectedError: `decoding feature flags: json: unknown field "percentage"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "flags.json")
+			require.NoError(t, os.WriteFile(file, []byte(tc.content), 0600))
+
+			c, err := Load(file)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedConfig, c)
+		})
+	}
+}
+
+func TestFlags_Enabled(t *testing.T) {
+	flags, err := NewFlags(Config{
+		Flags: map[string]Flag{
+			SBOM:     {Enabled: true, Projects: []string{"team-*", "library"}},
+			Webhooks: {Enabled: false, Projects: []string{"library"}},
+		},
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		flag     string
+		project  string
+		expected bool
+	}{
+		{name: "Should enable flag for matching project", flag: SBOM, project: "team-payments", expected: true},
+		{name: "Should enable flag for exact project", flag: SBOM, project: "library", expected: true},
+		{name: "Should disable flag for other projects", flag: SBOM, project: "staging", expected: false},
+		{name: "Should disable flag turned off for the deployment", flag: Webhooks, project: "library", expected: false},
+		{name: "Should enable flag missing from the configuration", flag: "sbom-v2", project: "staging", expected: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, flags.Enabled(tc.flag, tc.project))
+		})
+	}
+
+	t.Run("Should enable all flags", func(t *testing.T) {
+		assert.True(t, AllEnabled().Enabled(SBOM, "staging"))
+		assert.True(t, AllEnabled().Enabled(Webhooks, "library"))
+	})
+}
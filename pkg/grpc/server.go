@@ -0,0 +1,186 @@
+// Package grpc serves the scan submission and report retrieval operations of the v1 API over gRPC, as defined by
+// the ScannerService of the scannerv1 package, so that internal tooling can use typed clients and watch reports
+// rather than poll for them. Calls are authenticated with the provider of the API, from their metadata, and served
+// with the TLS configuration of the API.
+package grpc
+
+//go:generate protoc --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative -I ../.. pkg/grpc/scannerv1/scanner.proto
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/grpc/scannerv1"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/http/api"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/http/api/auth"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/policy"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/queue"
+)
+
+type Server struct {
+	config   etc.Config
+	auth     auth.Provider
+	service  *service
+	server   *grpc.Server
+	shutdown chan struct{}
+}
+
+type Option func(s *Server)
+
+// WithAuthProvider authenticates calls with the given Provider instead of accepting all calls.
+func WithAuthProvider(provider auth.Provider) Option {
+	return func(s *Server) {
+		s.auth = provider
+	}
+}
+
+// WithSignatureVerifier rejects scan requests unless their x-scanner-request-signature metadata is accepted by the
+// given SignatureVerifier.
+func WithSignatureVerifier(verifier auth.SignatureVerifier) Option {
+	return func(s *Server) {
+		s.service.signatures = verifier
+	}
+}
+
+// WithPolicyEngine extends the timeout of scan requests which do not request one with the timeout of the policy.
+func WithPolicyEngine(engine policy.Engine) Option {
+	return func(s *Server) {
+		s.service.policy = engine
+	}
+}
+
+// NewServer constructs a Server, which serves the ScannerService at the configured address, enqueuing scan jobs
+// with the given Enqueuer and getting them from the given Store.
+func NewServer(config etc.Config, enqueuer queue.Enqueuer, store persistence.Store, opts ...Option) (*Server, error) {
+	s := &Server{
+		config:   config,
+		auth:     auth.NewNoneProvider(),
+		shutdown: make(chan struct{}),
+	}
+	s.service = &service{
+		config:   config,
+		enqueuer: enqueuer,
+		store:    store,
+		shutdown: s.shutdown,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	serverOptions := []grpc.ServerOption{
+		grpc.UnaryInterceptor(s.authenticateUnary),
+		grpc.StreamInterceptor(s.authenticateStream),
+	}
+	if config.API.IsTLSEnabled() {
+		tlsConfig, err := api.NewTLSConfig(config.API)
+		if err != nil {
+			return nil, err
+		}
+		certificate, err := tls.LoadX509KeyPair(config.API.TLSCertificate, config.API.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("loading TLS certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+		serverOptions = append(serverOptions, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	s.server = grpc.NewServer(serverOptions...)
+	scannerv1.RegisterScannerServiceServer(s.server, s.service)
+	return s, nil
+}
+
+func (s *Server) ListenAndServe() {
+	go func() {
+		if err := s.listenAndServe(); err != nil {
+			slog.Error("Error while serving gRPC API", slog.String("err", err.Error()))
+		}
+		slog.Debug("gRPC server stopped listening for incoming connections")
+	}()
+}
+
+func (s *Server) listenAndServe() error {
+	listener, err := net.Listen("tcp", s.config.GRPC.Addr)
+	if err != nil {
+		return err
+	}
+	if s.config.API.IsTLSEnabled() {
+		slog.Debug("Starting gRPC server with TLS", slog.String("addr", s.config.GRPC.Addr))
+	} else {
+		slog.Warn("Starting gRPC server without TLS", slog.String("addr", s.config.GRPC.Addr))
+	}
+	return s.server.Serve(listener)
+}
+
+// Shutdown ends the watches of reports, and waits for the other calls to complete.
+func (s *Server) Shutdown() {
+	slog.Debug("gRPC server shutdown started")
+	close(s.shutdown)
+	s.server.GracefulStop()
+	slog.Debug("gRPC server shutdown completed")
+}
+
+func (s *Server) authenticateUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := s.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) authenticateStream(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+	ctx, err := s.authenticate(stream.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+}
+
+// authenticate returns a copy of the given context carrying the Principal making the call to the given method. It
+// returns an Unauthenticated or PermissionDenied status error if the call cannot be authenticated, or if the
+// Principal lacks the scan role. The Provider is given a request with the headers of the metadata of the call, e.g.
+// its authorization or x-api-key metadata.
+func (s *Server) authenticate(ctx context.Context, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	req := &http.Request{Method: http.MethodPost, URL: &url.URL{Path: method}, Header: make(http.Header, len(md))}
+	for key, values := range md {
+		req.Header[textproto.CanonicalMIMEHeaderKey(key)] = values
+	}
+
+	principal, err := s.auth.Authenticate(req.WithContext(ctx))
+	if err != nil {
+		slog.Warn("Rejecting unauthenticated gRPC call", slog.String("method", method), slog.String("err", err.Error()))
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	if !principal.HasRole(auth.RoleScan) {
+		slog.Warn("Rejecting unauthorized gRPC call", slog.String("method", method),
+			slog.String("subject", principal.Subject), slog.String("role", string(auth.RoleScan)))
+		return nil, status.Error(codes.PermissionDenied, "forbidden")
+	}
+	return auth.WithPrincipal(ctx, principal), nil
+}
+
+// authenticatedStream is a ServerStream whose context carries the authenticated Principal.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
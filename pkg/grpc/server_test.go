@@ -0,0 +1,351 @@
+package grpc
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	testifymock "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/grpc/scannerv1"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/http/api/auth"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/mock"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence/memory"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/queue"
+)
+
+var (
+	config = etc.Config{GRPC: etc.GRPC{Addr: "bufconn", WatchPollInterval: 10 * time.Millisecond}}
+
+	report = harbor.ScanReport{
+		GeneratedAt: time.Date(2024, 4, 2, 9, 30, 0, 0, time.UTC),
+		Artifact:    harbor.Artifact{Repository: "library/mongo", Digest: "sha256:917f"},
+		Scanner:     harbor.Scanner{Name: "Tunnel", Vendor: "Khulnasoft Security", Version: "0.50.0"},
+		Severity:    harbor.SevCritical,
+		Vulnerabilities: []harbor.VulnerabilityItem{
+			{ID: "CVE-2024-3094", Pkg: "xz", Version: "5.6.0", FixVersion: "5.6.2", Severity: harbor.SevCritical,
+				Links: []string{"https://avd.khulnasoft.com/nvd/cve-2024-3094"}},
+		},
+	}
+	expectedReport = &scannerv1.ScanReport{
+		GeneratedAt: timestamppb.New(report.GeneratedAt),
+		Artifact:    &scannerv1.Artifact{Repository: "library/mongo", Digest: "sha256:917f"},
+		Scanner:     &scannerv1.Scanner{Name: "Tunnel", Vendor: "Khulnasoft Security", Version: "0.50.0"},
+		Severity:    scannerv1.Severity_SEVERITY_CRITICAL,
+		Vulnerabilities: []*scannerv1.Vulnerability{
+			{Id: "CVE-2024-3094", Package: "xz", Version: "5.6.0", FixVersion: "5.6.2",
+				Severity: scannerv1.Severity_SEVERITY_CRITICAL, Links: []string{"https://avd.khulnasoft.com/nvd/cve-2024-3094"}},
+		},
+	}
+)
+
+// newClient serves a Server constructed with the given arguments over an in-memory connection, and returns a
+// client of it.
+func newClient(t *testing.T, enqueuer queue.Enqueuer, store persistence.Store, opts ...Option) scannerv1.ScannerServiceClient {
+	t.Helper()
+	server, err := NewServer(config, enqueuer, store, opts...)
+	require.NoError(t, err)
+	listener := bufconn.Listen(1 << 20)
+	go func() {
+		_ = server.server.Serve(listener)
+	}()
+	t.Cleanup(server.Shutdown)
+
+	conn, err := grpc.Dial("bufconn", grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return scannerv1.NewScannerServiceClient(conn)
+}
+
+func newStore(t *testing.T, scanJobs ...job.ScanJob) persistence.Store {
+	t.Helper()
+	store := memory.NewStore(etc.MemoryStore{ScanJobTTL: time.Hour, MaxScanJobs: 10})
+	for _, scanJob := range scanJobs {
+		require.NoError(t, store.Create(context.Background(), scanJob))
+	}
+	return store
+}
+
+func TestService_Scan(t *testing.T) {
+	request := &scannerv1.ScanRequest{
+		Registry: &scannerv1.Registry{Url: "https://core.harbor.domain", Authorization: "Basic cm9ib3Q6czNjcmV0"},
+		Artifact: &scannerv1.Artifact{Repository: "library/mongo", Digest: "sha256:917f"},
+	}
+	scanRequest := harbor.ScanRequest{
+		Registry: harbor.Registry{URL: "https://core.harbor.domain", Authorization: "Basic cm9ib3Q6czNjcmV0"},
+		Artifact: harbor.Artifact{Repository: "library/mongo", Digest: "sha256:917f"},
+	}
+
+	testCases := []struct {
+		name             string
+		request          *scannerv1.ScanRequest
+		enqueuerExpects  []*mock.Expectation
+		expectedResponse *scannerv1.ScanResponse
+		expectedCode     codes.Code
+		expectedMessage  string
+	}{
+		{
+			name:    "Should enqueue scan job and estimate its position",
+			request: request,
+			enqueuerExpects: []*mock.Expectation{
+				{
+					Method:     "Enqueue",
+					Args:       []interface{}{testifymock.Anything, scanRequest},
+					ReturnArgs: []interface{}{job.ScanJob{ID: "job:123"}, nil},
+				},
+				{
+					Method:     "Position",
+					Args:       []interface{}{testifymock.Anything, "job:123"},
+					ReturnArgs: []interface{}{job.QueuePosition{Position: 7, EstimatedWait: 90 * time.Second, Estimated: true}, nil},
+				},
+			},
+			expectedResponse: &scannerv1.ScanResponse{Id: "job:123", QueuePosition: 7, EstimatedWaitSeconds: 90},
+		},
+		{
+			name: "Should return InvalidArgument when digest is missing",
+			request: &scannerv1.ScanRequest{
+				Registry: request.Registry,
+				Artifact: &scannerv1.Artifact{Repository: "library/mongo"},
+			},
+			expectedCode:    codes.InvalidArgument,
+			expectedMessage: "missing artifact.digest",
+		},
+		{
+			name:    "Should return ResourceExhausted when backlog is full",
+			request: request,
+			enqueuerExpects: []*mock.Expectation{
+				{
+					Method:     "Enqueue",
+					Args:       []interface{}{testifymock.Anything, scanRequest},
+					ReturnArgs: []interface{}{job.ScanJob{}, queue.ErrBacklogFull},
+				},
+			},
+			expectedCode:    codes.ResourceExhausted,
+			expectedMessage: "enqueuing scan job: scan job backlog is full",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			enqueuer := mock.NewEnqueuer()
+			mock.ApplyExpectations(t, enqueuer, tc.enqueuerExpects...)
+
+			response, err := newClient(t, enqueuer, newStore(t)).Scan(context.Background(), tc.request)
+			if tc.expectedCode != codes.OK {
+				assert.Equal(t, tc.expectedCode, status.Code(err))
+				assert.Equal(t, tc.expectedMessage, status.Convert(err).Message())
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, proto.Equal(tc.expectedResponse, response), "unexpected response: %v", response)
+			enqueuer.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_Scan_Signature(t *testing.T) {
+	request := &scannerv1.ScanRequest{
+		Registry: &scannerv1.Registry{Url: "https://core.harbor.domain"},
+		Artifact: &scannerv1.Artifact{Repository: "library/mongo", Digest: "sha256:917f"},
+	}
+	verifier, err := auth.NewHMACVerifier("s3cret", time.Minute)
+	require.NoError(t, err)
+
+	sign := func(t *testing.T, req *scannerv1.ScanRequest) string {
+		body, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+		require.NoError(t, err)
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write([]byte(timestamp + "."))
+		mac.Write(body)
+		return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	t.Run("Should enqueue scan request with valid signature", func(t *testing.T) {
+		enqueuer := mock.NewEnqueuer()
+		mock.ApplyExpectations(t, enqueuer, []*mock.Expectation{
+			{
+				Method:     "Enqueue",
+				Args:       []interface{}{testifymock.Anything, testifymock.Anything},
+				ReturnArgs: []interface{}{job.ScanJob{ID: "job:123"}, nil},
+			},
+			{
+				Method:     "Position",
+				Args:       []interface{}{testifymock.Anything, "job:123"},
+				ReturnArgs: []interface{}{job.QueuePosition{}, nil},
+			},
+		}...)
+		client := newClient(t, enqueuer, newStore(t), WithSignatureVerifier(verifier))
+
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-scanner-request-signature", sign(t, request))
+		response, err := client.Scan(ctx, request)
+		require.NoError(t, err)
+		assert.Equal(t, "job:123", response.GetId())
+		enqueuer.AssertExpectations(t)
+	})
+
+	t.Run("Should return Unauthenticated when signature is missing", func(t *testing.T) {
+		client := newClient(t, mock.NewEnqueuer(), newStore(t), WithSignatureVerifier(verifier))
+
+		_, err := client.Scan(context.Background(), request)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		assert.Equal(t, "invalid scan request signature", status.Convert(err).Message())
+	})
+
+	t.Run("Should return Unauthenticated when signature is of another artifact", func(t *testing.T) {
+		client := newClient(t, mock.NewEnqueuer(), newStore(t), WithSignatureVerifier(verifier))
+		other := &scannerv1.ScanRequest{Registry: request.Registry,
+			Artifact: &scannerv1.Artifact{Repository: "library/mongo", Digest: "sha256:6c3c"}}
+
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-scanner-request-signature", sign(t, other))
+		_, err := client.Scan(ctx, request)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+}
+
+func TestService_GetReport(t *testing.T) {
+	store := newStore(t,
+		job.ScanJob{ID: "job:queued", Status: job.Queued},
+		job.ScanJob{ID: "job:failed", Status: job.Failed, Error: "running tunnel: exit status 1"},
+		job.ScanJob{ID: "job:finished", Status: job.Finished, Report: report},
+	)
+	client := newClient(t, mock.NewEnqueuer(), store)
+
+	testCases := []struct {
+		name            string
+		id              string
+		expectedReport  *scannerv1.ScanReport
+		expectedCode    codes.Code
+		expectedMessage string
+	}{
+		{
+			name:           "Should return report of finished scan job",
+			id:             "job:finished",
+			expectedReport: expectedReport,
+		},
+		{
+			name:            "Should return FailedPrecondition when scan job has not finished",
+			id:              "job:queued",
+			expectedCode:    codes.FailedPrecondition,
+			expectedMessage: "scan job job:queued has not finished yet",
+		},
+		{
+			name:            "Should return Internal when scan job failed",
+			id:              "job:failed",
+			expectedCode:    codes.Internal,
+			expectedMessage: "running tunnel: exit status 1",
+		},
+		{
+			name:            "Should return NotFound when scan job does not exist",
+			id:              "job:missing",
+			expectedCode:    codes.NotFound,
+			expectedMessage: "cannot find scan job: job:missing",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			scanReport, err := client.GetReport(context.Background(), &scannerv1.GetReportRequest{Id: tc.id})
+			if tc.expectedCode != codes.OK {
+				assert.Equal(t, tc.expectedCode, status.Code(err))
+				assert.Equal(t, tc.expectedMessage, status.Convert(err).Message())
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, proto.Equal(tc.expectedReport, scanReport), "unexpected report: %v", scanReport)
+		})
+	}
+}
+
+func TestService_WatchReport(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Should stream status changes and report of scan job", func(t *testing.T) {
+		store := newStore(t, job.ScanJob{ID: "job:123", Status: job.Queued})
+		stream, err := newClient(t, mock.NewEnqueuer(), store).WatchReport(ctx, &scannerv1.GetReportRequest{Id: "job:123"})
+		require.NoError(t, err)
+
+		response, err := stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, scannerv1.ScanJobStatus_SCAN_JOB_STATUS_QUEUED, response.GetStatus())
+
+		require.NoError(t, store.UpdateStatus(ctx, "job:123", job.Pending))
+		response, err = stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, scannerv1.ScanJobStatus_SCAN_JOB_STATUS_PENDING, response.GetStatus())
+
+		require.NoError(t, store.UpdateReport(ctx, "job:123", report))
+		require.NoError(t, store.UpdateStatus(ctx, "job:123", job.Finished))
+		response, err = stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, scannerv1.ScanJobStatus_SCAN_JOB_STATUS_FINISHED, response.GetStatus())
+		response, err = stream.Recv()
+		require.NoError(t, err)
+		assert.True(t, proto.Equal(expectedReport, response.GetReport()), "unexpected report: %v", response.GetReport())
+
+		_, err = stream.Recv()
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("Should return error of failed scan job", func(t *testing.T) {
+		store := newStore(t, job.ScanJob{ID: "job:123", Status: job.Failed, Error: "running tunnel: exit status 1"})
+		stream, err := newClient(t, mock.NewEnqueuer(), store).WatchReport(ctx, &scannerv1.GetReportRequest{Id: "job:123"})
+		require.NoError(t, err)
+
+		response, err := stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, scannerv1.ScanJobStatus_SCAN_JOB_STATUS_FAILED, response.GetStatus())
+		_, err = stream.Recv()
+		assert.Equal(t, codes.Internal, status.Code(err))
+		assert.Equal(t, "running tunnel: exit status 1", status.Convert(err).Message())
+	})
+}
+
+func TestServer_Authenticate(t *testing.T) {
+	provider, err := auth.NewStaticProvider([]string{"s3cret"}, nil)
+	require.NoError(t, err)
+	client := newClient(t, mock.NewEnqueuer(), newStore(t), WithAuthProvider(provider))
+	request := &scannerv1.GetReportRequest{Id: "job:missing"}
+
+	t.Run("Should return Unauthenticated without token", func(t *testing.T) {
+		_, err := client.GetReport(context.Background(), request)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("Should authenticate call with bearer token", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer s3cret")
+		_, err := client.GetReport(ctx, request)
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("Should authenticate stream with bearer token", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer s3cret")
+		stream, err := client.WatchReport(ctx, request)
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+}
@@ -0,0 +1,246 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/grpc/scannerv1"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/http/api/auth"
+	v1 "github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/http/api/v1"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/policy"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/queue"
+)
+
+// service implements the ScannerService like the v1 API implements the corresponding endpoints, mapping their
+// HTTP status codes to the equivalent gRPC status codes.
+type service struct {
+	scannerv1.UnimplementedScannerServiceServer
+
+	config     etc.Config
+	enqueuer   queue.Enqueuer
+	store      persistence.Store
+	policy     policy.Engine
+	signatures auth.SignatureVerifier
+	shutdown   <-chan struct{}
+}
+
+func (s *service) Scan(ctx context.Context, req *scannerv1.ScanRequest) (*scannerv1.ScanResponse, error) {
+	if s.config.API.MaintenanceMode {
+		slog.Warn("Rejecting scan request in maintenance mode")
+		return nil, status.Error(codes.Unavailable, s.config.API.MaintenanceMessage)
+	}
+
+	scanRequest := harbor.ScanRequest{
+		Registry: harbor.Registry{
+			URL:           req.GetRegistry().GetUrl(),
+			Authorization: req.GetRegistry().GetAuthorization(),
+		},
+		Artifact: harbor.Artifact{
+			Repository: req.GetArtifact().GetRepository(),
+			Digest:     req.GetArtifact().GetDigest(),
+			MimeType:   req.GetArtifact().GetMimeType(),
+		},
+		TimeoutSeconds: req.GetTimeoutSeconds(),
+	}
+	if validationError := v1.ValidateScanRequest(scanRequest); validationError != nil {
+		slog.Error("Error while validating scan request", slog.String("err", validationError.Message))
+		return nil, status.Error(codes.InvalidArgument, validationError.Message)
+	}
+
+	if s.signatures != nil {
+		if err := s.verifySignature(ctx, req, scanRequest); err != nil {
+			slog.Warn("Rejecting scan request with invalid signature", slog.String("repository", scanRequest.Artifact.Repository),
+				slog.String("digest", scanRequest.Artifact.Digest), slog.String("err", err.Error()))
+			return nil, status.Error(codes.Unauthenticated, "invalid scan request signature")
+		}
+	}
+
+	if scanRequest.TimeoutSeconds == 0 && s.policy != nil {
+		scanRequest.TimeoutSeconds = int64(s.policy.ScanTimeout(scanRequest.Artifact.Repository).Seconds())
+	}
+
+	enqueueCtx := ctx
+	if req.GetForce() {
+		enqueueCtx = queue.WithForceScan(ctx)
+	}
+	scanJob, err := s.enqueuer.Enqueue(enqueueCtx, scanRequest)
+	if err != nil {
+		return nil, enqueueError(err)
+	}
+
+	response := &scannerv1.ScanResponse{Id: scanJob.ID}
+	position, err := s.enqueuer.Position(ctx, scanJob.ID)
+	if err != nil {
+		slog.Warn("Error while estimating scan job queue position", slog.String("scan_job_id", scanJob.ID),
+			slog.String("err", err.Error()))
+		return response, nil
+	}
+	if position.Position > 0 {
+		response.QueuePosition = int64(position.Position)
+		if position.Estimated {
+			response.EstimatedWaitSeconds = int64(position.EstimatedWait.Seconds())
+		}
+	}
+	return response, nil
+}
+
+// verifySignature verifies the signature of the given scan request carried by the x-scanner-request-signature
+// metadata of the call. Signatures covering the body of the request, i.e. hmac ones, cover the deterministic
+// protobuf encoding of the ScanRequest message.
+func (s *service) verifySignature(ctx context.Context, req *scannerv1.ScanRequest, scanRequest harbor.ScanRequest) error {
+	var signature string
+	if values := metadata.ValueFromIncomingContext(ctx, strings.ToLower(auth.HeaderSignature)); len(values) > 0 {
+		signature = values[0]
+	}
+	body, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("marshalling scan request: %w", err)
+	}
+	return s.signatures.Verify(signature, body, scanRequest)
+}
+
+// enqueueError returns the status error of a scan request whose scan job could not be enqueued with the given error.
+func enqueueError(err error) error {
+	if errors.Is(err, persistence.ErrScanJobExists) {
+		slog.Warn("Scan job conflicts with an existing scan job", slog.String("err", err.Error()))
+		return status.Errorf(codes.AlreadyExists, "enqueuing scan job: %s", err)
+	}
+	if errors.Is(err, queue.ErrBacklogFull) {
+		slog.Warn("Rejecting scan request while the backlog is full", slog.String("err", err.Error()))
+		return status.Errorf(codes.ResourceExhausted, "enqueuing scan job: %s", err)
+	}
+	slog.Error("Error while enqueuing scan job", slog.String("err", err.Error()))
+	return status.Errorf(codes.Internal, "enqueuing scan job: %s", err)
+}
+
+func (s *service) GetReport(ctx context.Context, req *scannerv1.GetReportRequest) (*scannerv1.ScanReport, error) {
+	scanJob, err := s.getScanJob(ctx, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	switch scanJob.Status {
+	case job.Finished:
+		return s.toScanReport(scanJob.Report), nil
+	case job.Failed:
+		return nil, status.Error(codes.Internal, scanJob.Error)
+	}
+	return nil, status.Errorf(codes.FailedPrecondition, "scan job %s has not finished yet", scanJob.ID)
+}
+
+func (s *service) WatchReport(req *scannerv1.GetReportRequest, stream scannerv1.ScannerService_WatchReportServer) error {
+	ctx := stream.Context()
+	ticker := time.NewTicker(s.config.GRPC.WatchPollInterval)
+	defer ticker.Stop()
+
+	last := scannerv1.ScanJobStatus_SCAN_JOB_STATUS_UNSPECIFIED
+	for {
+		scanJob, err := s.getScanJob(ctx, req.GetId())
+		if err != nil {
+			return err
+		}
+		if current := toScanJobStatus(scanJob.Status); current != last {
+			if err = stream.Send(&scannerv1.WatchReportResponse{
+				Event: &scannerv1.WatchReportResponse_Status{Status: current},
+			}); err != nil {
+				return err
+			}
+			last = current
+		}
+		switch scanJob.Status {
+		case job.Finished:
+			return stream.Send(&scannerv1.WatchReportResponse{
+				Event: &scannerv1.WatchReportResponse_Report{Report: s.toScanReport(scanJob.Report)},
+			})
+		case job.Failed:
+			return status.Error(codes.Internal, scanJob.Error)
+		}
+
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-s.shutdown:
+			return status.Error(codes.Unavailable, "server is shutting down")
+		case <-ticker.C:
+		}
+	}
+}
+
+// getScanJob returns the scan job with the given ID, or a status error if it cannot be got.
+func (s *service) getScanJob(ctx context.Context, scanJobID string) (*job.ScanJob, error) {
+	if scanJobID == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing id")
+	}
+	scanJob, err := s.store.Get(ctx, scanJobID)
+	if err != nil {
+		slog.Error("Error while getting scan job", slog.String("scan_job_id", scanJobID), slog.String("err", err.Error()))
+		return nil, status.Errorf(codes.Internal, "getting scan job: %v", err)
+	}
+	if scanJob == nil {
+		return nil, status.Errorf(codes.NotFound, "cannot find scan job: %s", scanJobID)
+	}
+	return scanJob, nil
+}
+
+// toScanReport converts the given report, filtered by the ecosystems of the API configuration. Severities keep
+// their values, which scannerv1 shares with harbor.
+func (s *service) toScanReport(report harbor.ScanReport) *scannerv1.ScanReport {
+	report = harbor.FilterEcosystems(report, s.config.API.IncludeEcosystems, s.config.API.ExcludeEcosystems)
+	converted := &scannerv1.ScanReport{
+		GeneratedAt: timestamppb.New(report.GeneratedAt),
+		Artifact: &scannerv1.Artifact{
+			Repository: report.Artifact.Repository,
+			Digest:     report.Artifact.Digest,
+			MimeType:   report.Artifact.MimeType,
+		},
+		Scanner: &scannerv1.Scanner{
+			Name:    report.Scanner.Name,
+			Vendor:  report.Scanner.Vendor,
+			Version: report.Scanner.Version,
+		},
+		Severity:        scannerv1.Severity(report.Severity),
+		Vulnerabilities: make([]*scannerv1.Vulnerability, 0, len(report.Vulnerabilities)),
+		Partial:         report.Partial,
+	}
+	for _, v := range report.Vulnerabilities {
+		converted.Vulnerabilities = append(converted.Vulnerabilities, &scannerv1.Vulnerability{
+			Id:          v.ID,
+			Package:     v.Pkg,
+			Version:     v.Version,
+			FixVersion:  v.FixVersion,
+			Severity:    scannerv1.Severity(v.Severity),
+			Description: v.Description,
+			Links:       v.Links,
+			CweIds:      v.CweIDs,
+		})
+	}
+	return converted
+}
+
+// toScanJobStatus converts the given status of a scan job.
+func toScanJobStatus(status job.ScanJobStatus) scannerv1.ScanJobStatus {
+	switch status {
+	case job.Queued:
+		return scannerv1.ScanJobStatus_SCAN_JOB_STATUS_QUEUED
+	case job.Pending:
+		return scannerv1.ScanJobStatus_SCAN_JOB_STATUS_PENDING
+	case job.Finished:
+		return scannerv1.ScanJobStatus_SCAN_JOB_STATUS_FINISHED
+	case job.Failed:
+		return scannerv1.ScanJobStatus_SCAN_JOB_STATUS_FAILED
+	}
+	return scannerv1.ScanJobStatus_SCAN_JOB_STATUS_UNSPECIFIED
+}
@@ -0,0 +1,15 @@
+package harbor
+
+// VendorAttributeAllowlisted is the vendor attribute marking the vulnerabilities allowlisted by the adapter, which
+// are kept in reports instead of being dropped. VendorAttributeAllowlistExpires holds the date the allowlisting
+// expires, e.g. 2025-06-30, if it does.
+const (
+	VendorAttributeAllowlisted      = "allowlisted"
+	VendorAttributeAllowlistExpires = "allowlist_expires"
+)
+
+// IsAllowlisted returns true if the given vulnerability is marked as allowlisted.
+func IsAllowlisted(v VulnerabilityItem) bool {
+	allowlisted, _ := v.VendorAttributes[VendorAttributeAllowlisted].(bool)
+	return allowlisted
+}
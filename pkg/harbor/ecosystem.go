@@ -0,0 +1,51 @@
+package harbor
+
+import (
+	"slices"
+)
+
+// VendorAttributeEcosystem is the vendor attribute holding the ecosystem of the vulnerable package of a
+// vulnerability, e.g. os, npm, pypi, golang or jar.
+const VendorAttributeEcosystem = "ecosystem"
+
+// Ecosystems of packages found by Tunnel. Other language packages have the ecosystem of their Tunnel type,
+// e.g. cargo or nuget.
+const (
+	EcosystemOS     = "os"
+	EcosystemNPM    = "npm"
+	EcosystemPyPI   = "pypi"
+	EcosystemGolang = "golang"
+	EcosystemJar    = "jar"
+)
+
+// FilterEcosystems returns a copy of the given report with the vulnerabilities of the included ecosystems only,
+// or of all ecosystems if none are included, minus the vulnerabilities of the excluded ecosystems. The severity
+// of the report is recomputed from the remaining vulnerabilities, which are not suppressed.
+//
+// Vulnerabilities without an ecosystem, found in reports generated before ecosystems were recorded, are kept.
+func FilterEcosystems(report ScanReport, include, exclude []string) ScanReport {
+	if len(include) == 0 && len(exclude) == 0 {
+		return report
+	}
+
+	vulnerabilities := make([]VulnerabilityItem, 0, len(report.Vulnerabilities))
+	severity := SevUnknown
+	for _, v := range report.Vulnerabilities {
+		ecosystem, _ := v.VendorAttributes[VendorAttributeEcosystem].(string)
+		if ecosystem != "" {
+			if len(include) > 0 && !slices.Contains(include, ecosystem) {
+				continue
+			}
+			if slices.Contains(exclude, ecosystem) {
+				continue
+			}
+		}
+		vulnerabilities = append(vulnerabilities, v)
+		if !IsSuppressed(v) {
+			severity = max(severity, v.Severity)
+		}
+	}
+	report.Vulnerabilities = vulnerabilities
+	report.Severity = severity
+	return report
+}
@@ -0,0 +1,73 @@
+package harbor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterEcosystems(t *testing.T) {
+	withEcosystem := func(id string, severity Severity, ecosystem string) VulnerabilityItem {
+		v := VulnerabilityItem{ID: id, Severity: severity}
+		if ecosystem != "" {
+			v.VendorAttributes = map[string]interface{}{VendorAttributeEcosystem: ecosystem}
+		}
+		return v
+	}
+	report := ScanReport{
+		Severity: SevCritical,
+		Vulnerabilities: []VulnerabilityItem{
+			withEcosystem("CVE-0000-0001", SevCritical, "npm"),
+			withEcosystem("CVE-0000-0002", SevHigh, "os"),
+			withEcosystem("CVE-0000-0003", SevMedium, "pypi"),
+			withEcosystem("CVE-0000-0004", SevLow, ""),
+		},
+	}
+
+	testCases := []struct {
+		name             string
+		include          []string
+		exclude          []string
+		expectedIDs      []string
+		expectedSeverity Severity
+	}{
+		{
+			name:             "Should keep all vulnerabilities without filter",
+			expectedIDs:      []string{"CVE-0000-0001", "CVE-0000-0002", "CVE-0000-0003", "CVE-0000-0004"},
+			expectedSeverity: SevCritical,
+		},
+		{
+			name:             "Should keep vulnerabilities of included ecosystems",
+			include:          []string{"os"},
+			expectedIDs:      []string{"CVE-0000-0002", "CVE-0000-0004"},
+			expectedSeverity: SevHigh,
+		},
+		{
+			name:             "Should drop vulnerabilities of excluded ecosystems",
+			exclude:          []string{"npm", "pypi"},
+			expectedIDs:      []string{"CVE-0000-0002", "CVE-0000-0004"},
+			expectedSeverity: SevHigh,
+		},
+		{
+			name:             "Should apply exclusions to included ecosystems",
+			include:          []string{"os", "npm"},
+			exclude:          []string{"npm"},
+			expectedIDs:      []string{"CVE-0000-0002", "CVE-0000-0004"},
+			expectedSeverity: SevHigh,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			filtered := FilterEcosystems(report, tc.include, tc.exclude)
+
+			var ids []string
+			for _, v := range filtered.Vulnerabilities {
+				ids = append(ids, v.ID)
+			}
+			assert.Equal(t, tc.expectedIDs, ids)
+			assert.Equal(t, tc.expectedSeverity, filtered.Severity)
+			assert.Len(t, report.Vulnerabilities, 4)
+		})
+	}
+}
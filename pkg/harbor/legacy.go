@@ -0,0 +1,72 @@
+package harbor
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// preferredCVSSSource is the source of the CVSS details preferred over the others.
+const preferredCVSSSource = "nvd"
+
+// cvss mirrors the CVSS details of a single source in the vendor attributes.
+type cvss struct {
+	V2Vector string   `json:"V2Vector,omitempty"`
+	V3Vector string   `json:"V3Vector,omitempty"`
+	V2Score  *float32 `json:"V2Score,omitempty"`
+	V3Score  *float32 `json:"V3Score,omitempty"`
+}
+
+// ToLegacyReport returns a copy of the given report in the structure expected by Harbor releases prior to 2.6.
+//
+// These releases read the CVSS details from the preferred_cvss field rather than from the vendor attributes,
+// so the CVSS details of the preferred source are moved there. The vendor attributes of the report and of its
+// vulnerabilities, as well as the layer and the platform, which are not defined by the Scanners API, are dropped.
+func ToLegacyReport(report ScanReport) ScanReport {
+	vulnerabilities := make([]VulnerabilityItem, len(report.Vulnerabilities))
+	for i, v := range report.Vulnerabilities {
+		if v.PreferredCVSS == nil {
+			v.PreferredCVSS = toPreferredCVSS(v.VendorAttributes)
+		}
+		v.Layer = nil
+		v.VendorAttributes = nil
+		vulnerabilities[i] = v
+	}
+	report.Artifact.Platform = nil
+	report.VendorAttributes = nil
+	report.Vulnerabilities = vulnerabilities
+	return report
+}
+
+// toPreferredCVSS returns the CVSS details of the preferred source in the given vendor attributes, or of the
+// first source in alphabetical order. The attributes hold either typed values, or generic JSON values once
+// the report has been read back from the store, so they are converted through JSON.
+func toPreferredCVSS(attributes map[string]interface{}) *CVSSDetails {
+	value, ok := attributes["CVSS"]
+	if !ok {
+		return nil
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	var sources map[string]cvss
+	if err = json.Unmarshal(b, &sources); err != nil || len(sources) == 0 {
+		return nil
+	}
+
+	source, ok := sources[preferredCVSSSource]
+	if !ok {
+		names := make([]string, 0, len(sources))
+		for name := range sources {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		source = sources[names[0]]
+	}
+	return &CVSSDetails{
+		ScoreV2:  source.V2Score,
+		ScoreV3:  source.V3Score,
+		VectorV2: source.V2Vector,
+		VectorV3: source.V3Vector,
+	}
+}
@@ -0,0 +1,106 @@
+package harbor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToLegacyReport(t *testing.T) {
+	score := func(v float32) *float32 { return &v }
+
+	testCases := []struct {
+		name          string
+		attributes    map[string]interface{}
+		preferredCVSS *CVSSDetails
+		expectedCVSS  *CVSSDetails
+	}{
+		{
+			name: "Should prefer CVSS details of NVD",
+			attributes: map[string]interface{}{
+				"CVSS": map[string]interface{}{
+					"nvd": map[string]interface{}{
+						"V3Vector": "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H",
+						"V3Score":  9.8,
+					},
+					"redhat": map[string]interface{}{
+						"V3Vector": "CVSS:3.0/AV:N/AC:H/PR:N/UI:N/S:U/C:H/I:H/A:H",
+						"V3Score":  8.1,
+					},
+				},
+			},
+			expectedCVSS: &CVSSDetails{
+				ScoreV3:  score(9.8),
+				VectorV3: "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H",
+			},
+		},
+		{
+			name: "Should fall back to first source in alphabetical order",
+			attributes: map[string]interface{}{
+				"CVSS": map[string]interface{}{
+					"redhat": map[string]interface{}{"V2Vector": "AV:N/AC:L/Au:N/C:P/I:N/A:N", "V2Score": 5},
+					"ghsa":   map[string]interface{}{"V3Vector": "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N", "V3Score": 7.5},
+				},
+			},
+			expectedCVSS: &CVSSDetails{
+				ScoreV3:  score(7.5),
+				VectorV3: "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N",
+			},
+		},
+		{
+			name:         "Should leave preferred CVSS blank without CVSS details",
+			attributes:   map[string]interface{}{},
+			expectedCVSS: nil,
+		},
+		{
+			name: "Should keep preferred CVSS",
+			attributes: map[string]interface{}{
+				"CVSS": map[string]interface{}{
+					"nvd": map[string]interface{}{"V3Score": 9.8},
+				},
+			},
+			preferredCVSS: &CVSSDetails{ScoreV3: score(5.3)},
+			expectedCVSS:  &CVSSDetails{ScoreV3: score(5.3)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			report := ScanReport{
+				Severity: SevCritical,
+				Vulnerabilities: []VulnerabilityItem{
+					{
+						ID:               "CVE-2019-14697",
+						Pkg:              "musl",
+						Version:          "1.1.22-r2",
+						Severity:         SevCritical,
+						Links:            []string{},
+						Layer:            &Layer{Digest: "sha256:6010ba43a70055242e61e684d74231bff98f8f0bb489fbbc67eefdfd7269b56a"},
+						PreferredCVSS:    tc.preferredCVSS,
+						CweIDs:           []string{"CWE-787"},
+						VendorAttributes: tc.attributes,
+					},
+				},
+				VendorAttributes: map[string]interface{}{"os_package_manager_detected": true},
+			}
+
+			legacy := ToLegacyReport(report)
+
+			assert.Equal(t, ScanReport{
+				Severity: SevCritical,
+				Vulnerabilities: []VulnerabilityItem{
+					{
+						ID:            "CVE-2019-14697",
+						Pkg:           "musl",
+						Version:       "1.1.22-r2",
+						Severity:      SevCritical,
+						Links:         []string{},
+						PreferredCVSS: tc.expectedCVSS,
+						CweIDs:        []string{"CWE-787"},
+					},
+				},
+			}, legacy)
+			assert.NotNil(t, report.Vulnerabilities[0].Layer, "the given report must not be modified")
+		})
+	}
+}
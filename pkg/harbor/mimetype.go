@@ -0,0 +1,139 @@
+package harbor
+
+import (
+	"fmt"
+	"mime"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ReportMimeType is a versioned MIME type of the reports served to Harbor by the report endpoint.
+type ReportMimeType struct {
+	MediaType string
+	Version   string
+	// Capability is the type of the capability producing reports of this MIME type.
+	Capability string
+}
+
+// Versioned MIME types of the reports defined by the Scanners API.
+var (
+	// ReportMimeTypeVulnerabilityV1_0 is the schema of vulnerability reports read by Harbor releases prior to 2.6,
+	// which lack vendor attributes.
+	ReportMimeTypeVulnerabilityV1_0 = ReportMimeType{
+		MediaType:  "application/vnd.scanner.adapter.vuln.report.harbor+json",
+		Version:    "1.0",
+		Capability: CapabilityTypeVulnerability,
+	}
+	ReportMimeTypeVulnerabilityV1_1 = ReportMimeType{
+		MediaType:  "application/vnd.security.vulnerability.report",
+		Version:    "1.1",
+		Capability: CapabilityTypeVulnerability,
+	}
+	ReportMimeTypeSBOMV1_0 = ReportMimeType{
+		MediaType:  "application/vnd.security.sbom.report+json",
+		Version:    "1.0",
+		Capability: CapabilityTypeSBOM,
+	}
+)
+
+// vulnerabilityReportMimeTypes are the MIME types of vulnerability reports keyed by schema version.
+var vulnerabilityReportMimeTypes = map[VulnerabilityReportVersion]ReportMimeType{
+	VulnerabilityReportV1_0: ReportMimeTypeVulnerabilityV1_0,
+	VulnerabilityReportV1_1: ReportMimeTypeVulnerabilityV1_1,
+}
+
+// String returns the MIME type as sent in the Accept and Content-Type headers.
+func (t ReportMimeType) String() string {
+	return fmt.Sprintf("%s; version=%s", t.MediaType, t.Version)
+}
+
+// VulnerabilityReportVersion is a version of the schema of vulnerability reports.
+type VulnerabilityReportVersion string
+
+const (
+	VulnerabilityReportV1_0 VulnerabilityReportVersion = "1.0"
+	VulnerabilityReportV1_1 VulnerabilityReportVersion = "1.1"
+)
+
+// UnmarshalText parses the given schema version of vulnerability reports, and fails unless it is supported.
+func (v *VulnerabilityReportVersion) UnmarshalText(text []byte) error {
+	version := VulnerabilityReportVersion(strings.TrimSpace(string(text)))
+	if _, ok := vulnerabilityReportMimeTypes[version]; !ok {
+		return fmt.Errorf("unsupported vulnerability report version: %s", text)
+	}
+	*v = version
+	return nil
+}
+
+// MimeType returns the MIME type of vulnerability reports of the schema version.
+func (v VulnerabilityReportVersion) MimeType() ReportMimeType {
+	return vulnerabilityReportMimeTypes[v]
+}
+
+// ServedReportMimeTypes returns the MIME types of the reports served for the given schema versions of
+// vulnerability reports, which default to version 1.1, followed by the MIME types of SBOM reports. The first MIME
+// type is served to requests which accept any MIME type.
+func ServedReportMimeTypes(versions []VulnerabilityReportVersion) []ReportMimeType {
+	if len(versions) == 0 {
+		versions = []VulnerabilityReportVersion{VulnerabilityReportV1_1}
+	}
+	var mimeTypes []ReportMimeType
+	for _, version := range versions {
+		mimeType := version.MimeType()
+		if mimeType == (ReportMimeType{}) || slices.Contains(mimeTypes, mimeType) {
+			continue
+		}
+		mimeTypes = append(mimeTypes, mimeType)
+	}
+	return append(mimeTypes, ReportMimeTypeSBOMV1_0)
+}
+
+// NegotiateReportMimeType returns the MIME type of the report served for the given Accept header among the given
+// MIME types. Media ranges are matched in order of quality, and of appearance for equal qualities. A media range
+// without version matches the first served version of the media type, while wildcards and a blank header match the
+// first served MIME type.
+func NegotiateReportMimeType(accept string, served []ReportMimeType) (ReportMimeType, error) {
+	if strings.TrimSpace(accept) == "" {
+		return served[0], nil
+	}
+
+	var (
+		match   ReportMimeType
+		quality float64
+	)
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(mediaRange)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		if q <= quality {
+			continue
+		}
+		if mimeType, ok := matchMediaRange(mediaType, params["version"], served); ok {
+			match, quality = mimeType, q
+		}
+	}
+	if quality == 0 {
+		return ReportMimeType{}, fmt.Errorf("unsupported mime type: %s", accept)
+	}
+	return match, nil
+}
+
+func matchMediaRange(mediaType, version string, served []ReportMimeType) (ReportMimeType, bool) {
+	if mediaType == "*/*" || mediaType == "application/*" {
+		return served[0], true
+	}
+	for _, mimeType := range served {
+		if mimeType.MediaType == mediaType && (version == "" || mimeType.Version == version) {
+			return mimeType, true
+		}
+	}
+	return ReportMimeType{}, false
+}
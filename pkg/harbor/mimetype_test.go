@@ -0,0 +1,113 @@
+package harbor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiateReportMimeType(t *testing.T) {
+	served := ServedReportMimeTypes([]VulnerabilityReportVersion{VulnerabilityReportV1_1, VulnerabilityReportV1_0})
+
+	testCases := []struct {
+		name             string
+		accept           string
+		served           []ReportMimeType
+		expectedMimeType ReportMimeType
+		expectedError    string
+	}{
+		{
+			name:             "Should default to first served vulnerability report",
+			expectedMimeType: ReportMimeTypeVulnerabilityV1_1,
+		},
+		{
+			name:             "Should default to first served vulnerability report when any mime type is accepted",
+			accept:           "*/*",
+			expectedMimeType: ReportMimeTypeVulnerabilityV1_1,
+		},
+		{
+			name:             "Should accept vulnerability report v1.1",
+			accept:           "application/vnd.security.vulnerability.report; version=1.1",
+			expectedMimeType: ReportMimeTypeVulnerabilityV1_1,
+		},
+		{
+			name:             "Should accept vulnerability report v1.0",
+			accept:           "application/vnd.scanner.adapter.vuln.report.harbor+json; version=1.0",
+			expectedMimeType: ReportMimeTypeVulnerabilityV1_0,
+		},
+		{
+			name:             "Should accept SBOM report",
+			accept:           "application/vnd.security.sbom.report+json; version=1.0",
+			expectedMimeType: ReportMimeTypeSBOMV1_0,
+		},
+		{
+			name:             "Should accept media type without version",
+			accept:           "application/vnd.security.vulnerability.report",
+			expectedMimeType: ReportMimeTypeVulnerabilityV1_1,
+		},
+		{
+			name:             "Should prefer media range of highest quality",
+			accept:           "application/vnd.security.vulnerability.report; version=1.1; q=0.5, application/vnd.scanner.adapter.vuln.report.harbor+json; version=1.0",
+			expectedMimeType: ReportMimeTypeVulnerabilityV1_0,
+		},
+		{
+			name:             "Should prefer first media range of equal quality",
+			accept:           "application/vnd.scanner.adapter.vuln.report.harbor+json; version=1.0, application/vnd.security.vulnerability.report; version=1.1",
+			expectedMimeType: ReportMimeTypeVulnerabilityV1_0,
+		},
+		{
+			name:             "Should skip unsupported media ranges",
+			accept:           "application/json, application/vnd.security.vulnerability.report; version=1.1",
+			expectedMimeType: ReportMimeTypeVulnerabilityV1_1,
+		},
+		{
+			name:          "Should return error when version is not served",
+			accept:        "application/vnd.scanner.adapter.vuln.report.harbor+json; version=1.0",
+			served:        ServedReportMimeTypes(nil),
+			expectedError: "unsupported mime type: application/vnd.scanner.adapter.vuln.report.harbor+json; version=1.0",
+		},
+		{
+			name:          "Should return error when media range is refused",
+			accept:        "application/vnd.security.vulnerability.report; version=1.1; q=0",
+			expectedError: "unsupported mime type: application/vnd.security.vulnerability.report; version=1.1; q=0",
+		},
+		{
+			name:          "Should return error when mime type is not supported",
+			accept:        "application/vnd.scanner.adapter.vuln.report.raw",
+			expectedError: "unsupported mime type: application/vnd.scanner.adapter.vuln.report.raw",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mimeTypes := served
+			if tc.served != nil {
+				mimeTypes = tc.served
+			}
+			mimeType, err := NegotiateReportMimeType(tc.accept, mimeTypes)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedMimeType, mimeType)
+		})
+	}
+}
+
+func TestServedReportMimeTypes(t *testing.T) {
+	assert.Equal(t, []ReportMimeType{ReportMimeTypeVulnerabilityV1_1, ReportMimeTypeSBOMV1_0},
+		ServedReportMimeTypes(nil))
+	assert.Equal(t, []ReportMimeType{ReportMimeTypeVulnerabilityV1_0, ReportMimeTypeVulnerabilityV1_1, ReportMimeTypeSBOMV1_0},
+		ServedReportMimeTypes([]VulnerabilityReportVersion{VulnerabilityReportV1_0, VulnerabilityReportV1_1, VulnerabilityReportV1_0}))
+}
+
+func TestVulnerabilityReportVersion_UnmarshalText(t *testing.T) {
+	var version VulnerabilityReportVersion
+
+	assert.NoError(t, version.UnmarshalText([]byte(" 1.0")))
+	assert.Equal(t, VulnerabilityReportV1_0, version)
+
+	assert.EqualError(t, version.UnmarshalText([]byte("2.0")), "unsupported vulnerability report version: 2.0")
+	assert.Equal(t, VulnerabilityReportV1_0, version)
+}
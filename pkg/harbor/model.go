@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/url"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -41,6 +44,16 @@ var stringToSeverity = map[string]Severity{
 	"Critical": SevCritical,
 }
 
+// ParseSeverity returns the Severity with the given name, ignoring case.
+func ParseSeverity(name string) (Severity, error) {
+	for s, value := range severityToString {
+		if strings.EqualFold(value, name) {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity: %s", name)
+}
+
 // MarshalJSON marshals the Severity enum value as a quoted JSON string.
 func (s Severity) MarshalJSON() ([]byte, error) {
 	buffer := bytes.NewBufferString(`"`)
@@ -69,14 +82,118 @@ type Artifact struct {
 	Repository string `json:"repository"`
 	Digest     string `json:"digest"`
 	MimeType   string `json:"mime_type,omitempty"`
+	// Platform is detected while scanning the artifact, it is only set in scan reports.
+	Platform *Platform `json:"platform,omitempty"`
+}
+
+// Platform describes the image of a scanned artifact. It is not defined by the Scanners API.
+type Platform struct {
+	OSFamily     string `json:"os_family,omitempty"`
+	OSVersion    string `json:"os_version,omitempty"`
+	Architecture string `json:"architecture,omitempty"`
+	// Size is the size of the image in bytes, unknown when the artifact is scanned from a stored SBOM.
+	Size int64 `json:"size,omitempty"`
 }
 
+// Types of the capabilities of scanners.
+const (
+	CapabilityTypeVulnerability = "vulnerability"
+	CapabilityTypeSBOM          = "sbom"
+)
+
+// Media types of the SBOMs generated by Tunnel.
+const (
+	MediaTypeCycloneDX = "application/vnd.cyclonedx+json"
+	MediaTypeSPDX      = "application/spdx+json"
+)
+
+// SupportedSBOMMediaTypes are the media types of the SBOMs generated by the sbom capability, the first of which is
+// generated unless another one is requested.
+var SupportedSBOMMediaTypes = []string{MediaTypeCycloneDX, MediaTypeSPDX}
+
 type ScanRequest struct {
 	Registry Registry `json:"registry"`
 	Artifact Artifact `json:"artifact"`
+	// EnabledCapabilities are the capabilities the scan is requested for, e.g. generating an SBOM. Requests
+	// without capabilities, sent by Harbor releases older than 2.11, only request a vulnerability report.
+	EnabledCapabilities []EnabledCapability `json:"enabled_capabilities,omitempty"`
+	// TimeoutSeconds is an extension of the Harbor scan request, which extends the timeout of scanning the
+	// artifact, e.g. of huge images, up to the maximum timeout configured for the adapter.
+	TimeoutSeconds int64 `json:"timeout_seconds,omitempty"`
+}
+
+// Timeout returns the timeout of scanning the artifact requested by the scan request, or zero if the scan
+// request does not extend the timeout.
+func (c ScanRequest) Timeout() time.Duration {
+	return time.Duration(c.TimeoutSeconds) * time.Second
+}
+
+// EnabledCapability is a capability of the scanner enabled by a scan request.
+type EnabledCapability struct {
+	Type              string                `json:"type"`
+	ProducesMIMETypes []string              `json:"produces_mime_types,omitempty"`
+	Parameters        *CapabilityParameters `json:"parameters,omitempty"`
+}
+
+// CapabilityParameters are the parameters of a capability enabled by a scan request.
+type CapabilityParameters struct {
+	// SBOMMediaTypes are the media types of the SBOM requested by the sbom capability.
+	SBOMMediaTypes []string `json:"sbom_media_types,omitempty"`
+}
+
+// HasCapability returns true if the scan is requested for the capability of the given type. The vulnerability
+// capability is requested by scan requests without capabilities.
+func (c ScanRequest) HasCapability(capabilityType string) bool {
+	if len(c.EnabledCapabilities) == 0 {
+		return capabilityType == CapabilityTypeVulnerability
+	}
+	for _, capability := range c.EnabledCapabilities {
+		if capability.Type == capabilityType {
+			return true
+		}
+	}
+	return false
+}
+
+// SBOMMediaType returns the first supported media type of the SBOM requested by the sbom capability, or the
+// default media type if none is requested.
+func (c ScanRequest) SBOMMediaType() string {
+	for _, mediaType := range c.SBOMMediaTypes() {
+		if slices.Contains(SupportedSBOMMediaTypes, mediaType) {
+			return mediaType
+		}
+	}
+	return SupportedSBOMMediaTypes[0]
+}
+
+// SBOMMediaTypes returns the media types of the SBOM requested by the sbom capability, if any.
+func (c ScanRequest) SBOMMediaTypes() []string {
+	for _, capability := range c.EnabledCapabilities {
+		if capability.Type == CapabilityTypeSBOM && capability.Parameters != nil {
+			return capability.Parameters.SBOMMediaTypes
+		}
+	}
+	return nil
 }
 
-// GetImageRef returns Docker image reference for this ScanRequest.
+// CapabilitiesKey returns the types of the capabilities the scan is requested for, along with the media type of
+// the SBOM if it is requested, which tells apart the scans of the same artifact producing different reports.
+func (c ScanRequest) CapabilitiesKey() string {
+	var types []string
+	for _, capabilityType := range []string{CapabilityTypeVulnerability, CapabilityTypeSBOM} {
+		if c.HasCapability(capabilityType) {
+			types = append(types, capabilityType)
+		}
+	}
+	key := strings.Join(types, ",")
+	if c.HasCapability(CapabilityTypeSBOM) {
+		key += ";" + c.SBOMMediaType()
+	}
+	return key
+}
+
+// GetImageRef returns Docker image reference for this ScanRequest. The repository is used as is, see
+// RepositoryNormalizer.
 // Example: core.harbor.domain/scanners/mysql@sha256:3b00a364fb74246ca119d16111eb62f7302b2ff66d51e373c2bb209f8a1f3b9e
 func (c ScanRequest) GetImageRef() (imageRef string, insecureRegistry bool, err error) {
 	registryURL, err := url.Parse(c.Registry.URL)
@@ -93,13 +210,17 @@ func (c ScanRequest) GetImageRef() (imageRef string, insecureRegistry bool, err
 		port = "443"
 	}
 
-	imageRef = fmt.Sprintf("%s:%s/%s@%s", registryURL.Hostname(), port, c.Artifact.Repository, c.Artifact.Digest)
+	imageRef = fmt.Sprintf("%s/%s@%s", net.JoinHostPort(registryURL.Hostname(), port), c.Artifact.Repository, c.Artifact.Digest)
 	insecureRegistry = "http" == registryURL.Scheme
 	return
 }
 
 type ScanResponse struct {
 	ID string `json:"id"`
+	// QueuePosition and EstimatedWaitSeconds are not defined by Scanners API. They tell Harbor admins
+	// how long an accepted scan is expected to wait, e.g. during scan-all.
+	QueuePosition        *int   `json:"queue_position,omitempty"`
+	EstimatedWaitSeconds *int64 `json:"estimated_wait_seconds,omitempty"`
 }
 
 type ScanReport struct {
@@ -108,6 +229,21 @@ type ScanReport struct {
 	Scanner         Scanner             `json:"scanner"`
 	Severity        Severity            `json:"severity"`
 	Vulnerabilities []VulnerabilityItem `json:"vulnerabilities"`
+	// Partial is not defined by the Scanners API. It is true if the scan was interrupted, e.g. timed out, and
+	// the report only holds the findings detected before.
+	Partial bool `json:"partial,omitempty"`
+	// VendorAttributes are not defined by the Scanners API. They hold the detection metadata of the image, e.g.
+	// whether an OS package manager was detected, and hints explaining the findings.
+	VendorAttributes map[string]interface{} `json:"vendor_attributes,omitempty"`
+}
+
+// SBOMReport is the report of the sbom capability, which holds the SBOM of the scanned artifact.
+type SBOMReport struct {
+	GeneratedAt time.Time       `json:"generated_at"`
+	Artifact    Artifact        `json:"artifact"`
+	Scanner     Scanner         `json:"scanner"`
+	MediaType   string          `json:"media_type"`
+	SBOM        json.RawMessage `json:"sbom"`
 }
 
 type Layer struct {
@@ -150,8 +286,18 @@ type Scanner struct {
 }
 
 type Capability struct {
-	ConsumesMIMETypes []string `json:"consumes_mime_types"`
-	ProducesMIMETypes []string `json:"produces_mime_types"`
+	// Type is either vulnerability or sbom. Harbor releases older than 2.11 ignore it, and only look for the
+	// capability producing vulnerability reports.
+	Type                 string                `json:"type,omitempty"`
+	ConsumesMIMETypes    []string              `json:"consumes_mime_types"`
+	ProducesMIMETypes    []string              `json:"produces_mime_types"`
+	AdditionalAttributes *CapabilityAttributes `json:"additional_attributes,omitempty"`
+}
+
+// CapabilityAttributes describe a capability of the scanner beyond the MIME types it consumes and produces.
+type CapabilityAttributes struct {
+	// SBOMMediaTypes are the media types of the SBOMs generated by the sbom capability.
+	SBOMMediaTypes []string `json:"sbom_media_types,omitempty"`
 }
 
 // Error holds the information about an error, including metadata about its JSON structure.
@@ -69,6 +69,20 @@ func TestScanRequest_GetImageRef(t *testing.T) {
 			expectedImageRef: "core.harbor.domain:8443/library/nginx@test:DEF",
 			expectedInsecure: false,
 		},
+		{
+			name: "Should get imageRef when registry host is IPv6 address",
+			request: ScanRequest{
+				Registry: Registry{
+					URL: "https://[::1]:8443",
+				},
+				Artifact: Artifact{
+					Repository: "library/nginx",
+					Digest:     "test:DEF",
+				},
+			},
+			expectedImageRef: "[::1]:8443/library/nginx@test:DEF",
+			expectedInsecure: false,
+		},
 
 		{
 			name: "Should return error when registry URL is invalid",
@@ -166,3 +180,46 @@ func TestSeverity_UnmarshalJSON(t *testing.T) {
 	}
 
 }
+
+func TestParseSeverity(t *testing.T) {
+	severity, err := ParseSeverity("high")
+	assert.NoError(t, err)
+	assert.Equal(t, SevHigh, severity)
+
+	severity, err = ParseSeverity("Critical")
+	assert.NoError(t, err)
+	assert.Equal(t, SevCritical, severity)
+
+	_, err = ParseSeverity("severe")
+	assert.EqualError(t, err, "unknown severity: severe")
+}
+
+func TestScanRequest_HasCapability(t *testing.T) {
+	legacy := ScanRequest{}
+	assert.True(t, legacy.HasCapability(CapabilityTypeVulnerability))
+	assert.False(t, legacy.HasCapability(CapabilityTypeSBOM))
+	assert.Nil(t, legacy.SBOMMediaTypes())
+
+	sbom := ScanRequest{EnabledCapabilities: []EnabledCapability{{
+		Type:       CapabilityTypeSBOM,
+		Parameters: &CapabilityParameters{SBOMMediaTypes: []string{MediaTypeCycloneDX}},
+	}}}
+	assert.False(t, sbom.HasCapability(CapabilityTypeVulnerability))
+	assert.True(t, sbom.HasCapability(CapabilityTypeSBOM))
+	assert.Equal(t, []string{MediaTypeCycloneDX}, sbom.SBOMMediaTypes())
+	assert.Equal(t, MediaTypeCycloneDX, sbom.SBOMMediaType())
+	assert.Equal(t, MediaTypeCycloneDX, legacy.SBOMMediaType())
+
+	spdx := ScanRequest{EnabledCapabilities: []EnabledCapability{{
+		Type:       CapabilityTypeSBOM,
+		Parameters: &CapabilityParameters{SBOMMediaTypes: []string{"text/spdx", MediaTypeSPDX, MediaTypeCycloneDX}},
+	}}}
+	assert.Equal(t, MediaTypeSPDX, spdx.SBOMMediaType())
+
+	assert.Equal(t, "vulnerability", legacy.CapabilitiesKey())
+	assert.Equal(t, "vulnerability", ScanRequest{EnabledCapabilities: []EnabledCapability{{
+		Type: CapabilityTypeVulnerability,
+	}}}.CapabilitiesKey())
+	assert.Equal(t, "sbom;application/vnd.cyclonedx+json", sbom.CapabilitiesKey())
+	assert.Equal(t, "sbom;application/spdx+json", spdx.CapabilitiesKey())
+}
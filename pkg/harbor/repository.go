@@ -0,0 +1,95 @@
+package harbor
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Strategies of normalizing the repositories of scan requests.
+const (
+	// RepositoryNormalizationNone pulls repositories as sent by Harbor.
+	RepositoryNormalizationNone = "none"
+	// RepositoryNormalizationStandard removes empty path segments, e.g. of leading or doubled slashes, and the
+	// registry host prefixed to repositories, e.g. core.harbor.domain/library/mongo.
+	RepositoryNormalizationStandard = "standard"
+	// RepositoryNormalizationProxyCache also removes the upstream registry host following the project of proxy
+	// cache repositories, e.g. dockerhub-proxy/docker.io/library/mongo, and adds the library namespace to the
+	// official images of Docker Hub, e.g. dockerhub-proxy/docker.io/mongo.
+	RepositoryNormalizationProxyCache = "proxy-cache"
+)
+
+// dockerHubHosts are the hosts of Docker Hub, whose official images are pulled from the library namespace.
+var dockerHubHosts = []string{"docker.io", "index.docker.io", "registry-1.docker.io"}
+
+// RepositoryNormalizer wraps the Normalize method.
+// Normalize returns the path of the given repository in the registry with the given URL, i.e. the part of the
+// image reference pulled by Tunnel between the registry and the digest. Reports keep the repository sent by Harbor.
+type RepositoryNormalizer interface {
+	Normalize(registryURL, repository string) string
+}
+
+// NewRepositoryNormalizer constructs the RepositoryNormalizer of the given strategy. The proxy-cache strategy
+// removes the given upstream registry hosts, which default to the hosts of Docker Hub.
+func NewRepositoryNormalizer(strategy string, upstreams []string) (RepositoryNormalizer, error) {
+	switch strategy {
+	case RepositoryNormalizationNone:
+		return noneNormalizer{}, nil
+	case "", RepositoryNormalizationStandard:
+		return standardNormalizer{}, nil
+	case RepositoryNormalizationProxyCache:
+		if len(upstreams) == 0 {
+			upstreams = dockerHubHosts
+		}
+		return proxyCacheNormalizer{upstreams: upstreams}, nil
+	}
+	return nil, fmt.Errorf("unsupported repository normalization: %s", strategy)
+}
+
+type noneNormalizer struct{}
+
+func (noneNormalizer) Normalize(_, repository string) string {
+	return repository
+}
+
+type standardNormalizer struct{}
+
+func (standardNormalizer) Normalize(registryURL, repository string) string {
+	return strings.Join(normalizeSegments(registryURL, repository), "/")
+}
+
+type proxyCacheNormalizer struct {
+	upstreams []string
+}
+
+func (n proxyCacheNormalizer) Normalize(registryURL, repository string) string {
+	segments := normalizeSegments(registryURL, repository)
+	// The project is followed by the upstream host and at least one segment of the upstream repository.
+	if len(segments) > 2 && containsFold(n.upstreams, segments[1]) {
+		upstream := segments[1]
+		segments = slices.Delete(segments, 1, 2)
+		if containsFold(dockerHubHosts, upstream) && len(segments) == 2 {
+			segments = slices.Insert(segments, 1, "library")
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// normalizeSegments returns the non-empty path segments of the given repository, without the host of the given
+// registry URL if the repository is prefixed with it.
+func normalizeSegments(registryURL, repository string) []string {
+	segments := strings.FieldsFunc(strings.TrimSpace(repository), func(r rune) bool { return r == '/' })
+	u, err := url.Parse(registryURL)
+	if err != nil || u.Host == "" {
+		return segments
+	}
+	for len(segments) > 1 && (strings.EqualFold(segments[0], u.Host) || strings.EqualFold(segments[0], u.Hostname())) {
+		segments = segments[1:]
+	}
+	return segments
+}
+
+func containsFold(values []string, value string) bool {
+	return slices.ContainsFunc(values, func(v string) bool { return strings.EqualFold(v, value) })
+}
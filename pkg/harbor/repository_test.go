@@ -0,0 +1,147 @@
+package harbor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoryNormalizer_Normalize(t *testing.T) {
+	testCases := []struct {
+		name               string
+		strategy           string
+		upstreams          []string
+		registryURL        string
+		repository         string
+		expectedRepository string
+	}{
+		{
+			name:               "Should keep repository as is with none strategy",
+			strategy:           RepositoryNormalizationNone,
+			registryURL:        "https://core.harbor.domain",
+			repository:         "core.harbor.domain//library/mongo",
+			expectedRepository: "core.harbor.domain//library/mongo",
+		},
+		{
+			name:               "Should keep repository without empty segments",
+			strategy:           RepositoryNormalizationStandard,
+			registryURL:        "https://core.harbor.domain",
+			repository:         "library/mongo",
+			expectedRepository: "library/mongo",
+		},
+		{
+			name:               "Should remove leading and doubled slashes",
+			strategy:           RepositoryNormalizationStandard,
+			registryURL:        "https://core.harbor.domain",
+			repository:         "/library//mongo/",
+			expectedRepository: "library/mongo",
+		},
+		{
+			name:               "Should keep deeply nested repository",
+			strategy:           RepositoryNormalizationStandard,
+			registryURL:        "https://core.harbor.domain",
+			repository:         "platform/team-a/services/api/mongo",
+			expectedRepository: "platform/team-a/services/api/mongo",
+		},
+		{
+			name:               "Should remove registry host prefix",
+			strategy:           RepositoryNormalizationStandard,
+			registryURL:        "https://core.harbor.domain",
+			repository:         "core.harbor.domain/library/mongo",
+			expectedRepository: "library/mongo",
+		},
+		{
+			name:               "Should remove registry host prefix with port",
+			strategy:           RepositoryNormalizationStandard,
+			registryURL:        "http://harbor-harbor-registry:5000",
+			repository:         "harbor-harbor-registry:5000/scanners/mongo",
+			expectedRepository: "scanners/mongo",
+		},
+		{
+			name:               "Should remove registry host prefix without port",
+			strategy:           RepositoryNormalizationStandard,
+			registryURL:        "https://core.harbor.domain:8443",
+			repository:         "Core.Harbor.Domain/library/mongo",
+			expectedRepository: "library/mongo",
+		},
+		{
+			name:               "Should not remove repository named after registry host",
+			strategy:           RepositoryNormalizationStandard,
+			registryURL:        "https://core.harbor.domain",
+			repository:         "core.harbor.domain",
+			expectedRepository: "core.harbor.domain",
+		},
+		{
+			name:               "Should keep upstream host of proxy cache repository with standard strategy",
+			strategy:           RepositoryNormalizationStandard,
+			registryURL:        "https://core.harbor.domain",
+			repository:         "dockerhub-proxy/docker.io/library/mongo",
+			expectedRepository: "dockerhub-proxy/docker.io/library/mongo",
+		},
+		{
+			name:               "Should remove Docker Hub host of proxy cache repository",
+			strategy:           RepositoryNormalizationProxyCache,
+			registryURL:        "https://core.harbor.domain",
+			repository:         "dockerhub-proxy/docker.io/library/mongo",
+			expectedRepository: "dockerhub-proxy/library/mongo",
+		},
+		{
+			name:               "Should add library namespace to official image of Docker Hub",
+			strategy:           RepositoryNormalizationProxyCache,
+			registryURL:        "https://core.harbor.domain",
+			repository:         "dockerhub-proxy/docker.io/mongo",
+			expectedRepository: "dockerhub-proxy/library/mongo",
+		},
+		{
+			name:               "Should remove registry and Docker Hub hosts of proxy cache repository",
+			strategy:           RepositoryNormalizationProxyCache,
+			registryURL:        "https://core.harbor.domain",
+			repository:         "core.harbor.domain/dockerhub-proxy/index.docker.io/bitnami/mongodb",
+			expectedRepository: "dockerhub-proxy/bitnami/mongodb",
+		},
+		{
+			name:               "Should not add library namespace to proxy cache repository without upstream host",
+			strategy:           RepositoryNormalizationProxyCache,
+			registryURL:        "https://core.harbor.domain",
+			repository:         "dockerhub-proxy/mongo",
+			expectedRepository: "dockerhub-proxy/mongo",
+		},
+		{
+			name:               "Should remove configured upstream host of proxy cache repository",
+			strategy:           RepositoryNormalizationProxyCache,
+			upstreams:          []string{"quay.io"},
+			registryURL:        "https://core.harbor.domain",
+			repository:         "quay-proxy/quay.io/prometheus/node-exporter",
+			expectedRepository: "quay-proxy/prometheus/node-exporter",
+		},
+		{
+			name:               "Should keep upstream host which is not configured",
+			strategy:           RepositoryNormalizationProxyCache,
+			upstreams:          []string{"quay.io"},
+			registryURL:        "https://core.harbor.domain",
+			repository:         "dockerhub-proxy/docker.io/library/mongo",
+			expectedRepository: "dockerhub-proxy/docker.io/library/mongo",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			normalizer, err := NewRepositoryNormalizer(tc.strategy, tc.upstreams)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedRepository, normalizer.Normalize(tc.registryURL, tc.repository))
+		})
+	}
+}
+
+func TestNewRepositoryNormalizer(t *testing.T) {
+	t.Run("Should default to standard strategy", func(t *testing.T) {
+		normalizer, err := NewRepositoryNormalizer("", nil)
+		require.NoError(t, err)
+		assert.Equal(t, standardNormalizer{}, normalizer)
+	})
+
+	t.Run("Should return error when strategy is unsupported", func(t *testing.T) {
+		_, err := NewRepositoryNormalizer("fancy", nil)
+		assert.EqualError(t, err, "unsupported repository normalization: fancy")
+	})
+}
@@ -0,0 +1,19 @@
+package harbor
+
+// VendorAttributeVEXStatus is the vendor attribute holding the status of a vulnerability according to the VEX
+// statement matching it, i.e. not_affected or fixed. VendorAttributeVEXJustification holds the justification of
+// not_affected statements, e.g. vulnerable_code_not_in_execute_path, and VendorAttributeVEXDocument the ID of
+// the document the statement was read from.
+const (
+	VendorAttributeVEXStatus        = "vex_status"
+	VendorAttributeVEXJustification = "vex_justification"
+	VendorAttributeVEXDocument      = "vex_document"
+)
+
+// IsSuppressed returns true if the given vulnerability is marked as allowlisted, or as not exploitable by a VEX
+// statement. Suppressed vulnerabilities are kept in reports, but neither raise their severity nor violate the
+// policy.
+func IsSuppressed(v VulnerabilityItem) bool {
+	status, _ := v.VendorAttributes[VendorAttributeVEXStatus].(string)
+	return IsAllowlisted(v) || status != ""
+}
@@ -0,0 +1,40 @@
+package harbor
+
+import "strings"
+
+// EventTypePushArtifact is the type of the webhook events sent by Harbor when an artifact is pushed.
+const EventTypePushArtifact = "PUSH_ARTIFACT"
+
+// WebhookEvent is the payload of the webhook events sent by Harbor 2.x in the default format.
+type WebhookEvent struct {
+	Type      string           `json:"type"`
+	OccurAt   int64            `json:"occur_at"`
+	Operator  string           `json:"operator"`
+	EventData WebhookEventData `json:"event_data"`
+}
+
+type WebhookEventData struct {
+	Resources  []WebhookResource `json:"resources"`
+	Repository WebhookRepository `json:"repository"`
+}
+
+// WebhookResource is an artifact of a webhook event.
+type WebhookResource struct {
+	Digest string `json:"digest"`
+	Tag    string `json:"tag"`
+	// ResourceURL is the reference of the artifact, prefixed with the host of the registry, e.g.
+	// core.harbor.domain/library/nginx:1.25.
+	ResourceURL string `json:"resource_url"`
+}
+
+type WebhookRepository struct {
+	Name         string `json:"name"`
+	Namespace    string `json:"namespace"`
+	RepoFullName string `json:"repo_full_name"`
+}
+
+// RegistryHost returns the host of the registry of the given resource, i.e. the prefix of its resource URL.
+func (r WebhookResource) RegistryHost() string {
+	host, _, _ := strings.Cut(r.ResourceURL, "/")
+	return host
+}
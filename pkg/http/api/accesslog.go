@@ -0,0 +1,79 @@
+package api
+
+import (
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+)
+
+// AccessLog logs a line per request with its method, path, status, size and duration as configured. Requests
+// are logged at the configured sample ratio, while the ones failed with a server error, or taking at least the
+// slow threshold, are always logged. Client errors, e.g. the 429 responses to scan requests rejected while the
+// backlog is full, are sampled, as they come in bursts.
+func AccessLog(config etc.AccessLog) func(http.Handler) http.Handler {
+	return newAccessLog(config, slog.Default, rand.Float64)
+}
+
+func newAccessLog(config etc.AccessLog, logger func() *slog.Logger, random func() float64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !config.Enabled {
+			return next
+		}
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			started := time.Now()
+			recorder := &statusRecorder{ResponseWriter: res, status: http.StatusOK}
+			next.ServeHTTP(recorder, req)
+			duration := time.Since(started)
+
+			var reason string
+			switch {
+			case recorder.status >= http.StatusInternalServerError:
+				reason = "failed"
+			case config.SlowThreshold > 0 && duration >= config.SlowThreshold:
+				reason = "slow"
+			case random() < config.SampleRatio:
+				reason = "sampled"
+			default:
+				return
+			}
+
+			// Query parameters are left out, so that the lines of an endpoint are alike.
+			logger().Info("Access",
+				slog.String("method", req.Method),
+				slog.String("path", req.URL.Path),
+				slog.Int("status", recorder.status),
+				slog.Int64("bytes", recorder.bytes),
+				slog.Duration("duration", duration),
+				slog.String("addr", req.RemoteAddr),
+				slog.String("user_agent", req.UserAgent()),
+				slog.String("reason", reason),
+			)
+		})
+	}
+}
+
+// statusRecorder records the status and the size of the response written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying http.ResponseWriter, e.g. to flush it.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
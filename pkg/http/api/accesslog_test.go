@@ -0,0 +1,89 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLog(t *testing.T) {
+	testCases := []struct {
+		name           string
+		config         etc.AccessLog
+		random         float64
+		status         int
+		delay          time.Duration
+		expectedReason string
+	}{
+		{
+			name:           "Should log sampled request",
+			config:         etc.AccessLog{Enabled: true, SampleRatio: 0.5},
+			random:         0.25,
+			status:         http.StatusOK,
+			expectedReason: "sampled",
+		},
+		{
+			name:   "Should not log request left out of sample",
+			config: etc.AccessLog{Enabled: true, SampleRatio: 0.5},
+			random: 0.75,
+			status: http.StatusTooManyRequests,
+		},
+		{
+			name:           "Should always log failed request",
+			config:         etc.AccessLog{Enabled: true},
+			random:         0.75,
+			status:         http.StatusInternalServerError,
+			expectedReason: "failed",
+		},
+		{
+			name:           "Should always log slow request",
+			config:         etc.AccessLog{Enabled: true, SlowThreshold: time.Millisecond},
+			random:         0.75,
+			status:         http.StatusOK,
+			delay:          5 * time.Millisecond,
+			expectedReason: "slow",
+		},
+		{
+			name:   "Should not log when disabled",
+			config: etc.AccessLog{SampleRatio: 1},
+			status: http.StatusInternalServerError,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&logs, nil))
+			handler := newAccessLog(tc.config, func() *slog.Logger { return logger }, func() float64 { return tc.random })(
+				http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+					time.Sleep(tc.delay)
+					res.WriteHeader(tc.status)
+					_, _ = res.Write([]byte("hello"))
+				}))
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/metadata?token=s3cret", nil))
+			assert.Equal(t, tc.status, rr.Code)
+
+			if tc.expectedReason == "" {
+				assert.Empty(t, logs.String())
+				return
+			}
+			var line map[string]interface{}
+			require.NoError(t, json.Unmarshal(logs.Bytes(), &line))
+			assert.Equal(t, "Access", line["msg"])
+			assert.Equal(t, "GET", line["method"])
+			assert.Equal(t, "/api/v1/metadata", line["path"])
+			assert.Equal(t, float64(tc.status), line["status"])
+			assert.Equal(t, float64(5), line["bytes"])
+			assert.Equal(t, tc.expectedReason, line["reason"])
+		})
+	}
+}
@@ -0,0 +1,156 @@
+// Package auth authenticates the callers of the adapter's API.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/http/api"
+)
+
+const (
+	ProviderNone   = "none"
+	ProviderStatic = "static"
+	ProviderOIDC   = "oidc"
+)
+
+const headerAuthorization = "Authorization"
+
+// headerAPIKey is the header used by Harbor for scanners registered with the X-ScannerAdapter-API-Key
+// authorization type.
+const headerAPIKey = "X-ScannerAdapter-API-Key"
+
+// ErrUnauthenticated is returned when a request has no credentials, or invalid ones.
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Role grants access to a group of endpoints.
+type Role string
+
+const (
+	// RoleScan grants access to the endpoints called by Harbor.
+	RoleScan Role = "scan"
+	// RoleAdmin grants access to the endpoints managing the adapter, e.g. its queue and policies,
+	// in addition to the endpoints called by Harbor.
+	RoleAdmin Role = "admin"
+)
+
+// Principal identifies the authenticated caller.
+type Principal struct {
+	// Subject is the name of the caller, e.g. the `sub` claim of an OIDC token.
+	Subject string
+	Roles   []Role
+}
+
+// HasRole returns true if the Principal was granted the given role, either directly or through the admin role.
+func (p Principal) HasRole(role Role) bool {
+	return slices.Contains(p.Roles, role) || slices.Contains(p.Roles, RoleAdmin)
+}
+
+// Provider wraps the Authenticate method.
+// Authenticate returns the Principal making the given request. It returns an error wrapping ErrUnauthenticated
+// if the request has no valid credentials.
+type Provider interface {
+	Authenticate(req *http.Request) (Principal, error)
+}
+
+// NewProvider constructs the Provider configured by the given name.
+func NewProvider(ctx context.Context, config etc.Auth) (Provider, error) {
+	switch config.Provider {
+	case "", ProviderNone:
+		return NewNoneProvider(), nil
+	case ProviderStatic:
+		return NewStaticProvider(config.StaticTokens, config.StaticAdminTokens)
+	case ProviderOIDC:
+		return NewOIDCProvider(ctx, OIDCConfig{
+			IssuerURL:     config.OIDCIssuerURL,
+			Audience:      config.OIDCAudience,
+			JWKSURL:       config.OIDCJWKSURL,
+			AdminSubjects: config.OIDCAdminSubjects,
+		})
+	}
+	return nil, fmt.Errorf("unsupported auth provider: %s", config.Provider)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of the context carrying the given Principal.
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the Principal authenticated by the Middleware, if any.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
+
+// Middleware rejects requests which cannot be authenticated by the given Provider with 401 Unauthorized.
+func Middleware(provider Provider) func(http.Handler) http.Handler {
+	var handler api.BaseHandler
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			principal, err := provider.Authenticate(req)
+			if err != nil {
+				slog.Warn("Rejecting unauthenticated request", slog.String("uri", req.URL.RequestURI()),
+					slog.String("err", err.Error()))
+				res.Header().Set("WWW-Authenticate", "Bearer")
+				handler.WriteJSONError(res, harbor.Error{
+					HTTPCode: http.StatusUnauthorized,
+					Message:  "unauthorized",
+				})
+				return
+			}
+			next.ServeHTTP(res, req.WithContext(WithPrincipal(req.Context(), principal)))
+		})
+	}
+}
+
+// RequireRole rejects requests made by a Principal without the given role with 403 Forbidden.
+// It must be used after the Middleware.
+func RequireRole(role Role) func(http.Handler) http.Handler {
+	var handler api.BaseHandler
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			principal, _ := PrincipalFromContext(req.Context())
+			if !principal.HasRole(role) {
+				slog.Warn("Rejecting unauthorized request", slog.String("uri", req.URL.RequestURI()),
+					slog.String("subject", principal.Subject), slog.String("role", string(role)))
+				handler.WriteJSONError(res, harbor.Error{
+					HTTPCode: http.StatusForbidden,
+					Message:  "forbidden",
+				})
+				return
+			}
+			next.ServeHTTP(res, req)
+		})
+	}
+}
+
+type noneProvider struct {
+}
+
+// NewNoneProvider constructs a Provider, which authenticates all requests as anonymous with the scan role. The
+// admin role is never granted without credentials, hence the admin endpoints require another Provider.
+func NewNoneProvider() Provider {
+	return &noneProvider{}
+}
+
+func (p *noneProvider) Authenticate(_ *http.Request) (Principal, error) {
+	return Principal{Subject: "anonymous", Roles: []Role{RoleScan}}, nil
+}
+
+// bearerToken returns the token of the Authorization header with the Bearer scheme.
+func bearerToken(req *http.Request) string {
+	scheme, token, ok := strings.Cut(req.Header.Get(headerAuthorization), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
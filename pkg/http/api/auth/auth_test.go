@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProvider(t *testing.T) {
+	testCases := []struct {
+		name          string
+		config        etc.Auth
+		expectedType  Provider
+		expectedError string
+	}{
+		{
+			name:         "Should default to none provider",
+			config:       etc.Auth{},
+			expectedType: &noneProvider{},
+		},
+		{
+			name:         "Should return static provider",
+			config:       etc.Auth{Provider: "static", StaticTokens: []string{"s3cret"}},
+			expectedType: &staticProvider{},
+		},
+		{
+			name:          "Should return error for static provider without tokens",
+			config:        etc.Auth{Provider: "static"},
+			expectedError: "static tokens must not be empty",
+		},
+		{
+			name:          "Should return error for OIDC provider without issuer",
+			config:        etc.Auth{Provider: "oidc", OIDCAudience: "harbor-scanner-tunnel"},
+			expectedError: "OIDC issuer URL must not be blank",
+		},
+		{
+			name:          "Should return error for unknown provider",
+			config:        etc.Auth{Provider: "ldap"},
+			expectedError: "unsupported auth provider: ldap",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider, err := NewProvider(context.Background(), tc.config)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.IsType(t, tc.expectedType, provider)
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	provider, err := NewStaticProvider([]string{"s3cret"}, nil)
+	require.NoError(t, err)
+
+	next := http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		principal, ok := PrincipalFromContext(req.Context())
+		require.True(t, ok)
+		_, _ = res.Write([]byte(principal.Subject))
+	})
+	handler := Middleware(provider)(next)
+
+	t.Run("Should pass authenticated principal to next handler", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/metadata", nil)
+		req.Header.Set("Authorization", "Bearer s3cret")
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "static-token", rr.Body.String())
+	})
+
+	t.Run("Should respond with 401 Unauthorized when token is invalid", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/metadata", nil)
+		req.Header.Set("Authorization", "Bearer wrong")
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		assert.Equal(t, "Bearer", rr.Header().Get("WWW-Authenticate"))
+		assert.Equal(t, "application/vnd.scanner.adapter.error; version=1.0", rr.Header().Get("Content-Type"))
+		assert.JSONEq(t, `{"error":{"message":"unauthorized"}}`, rr.Body.String())
+	})
+}
+
+func TestRequireRole(t *testing.T) {
+	next := http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+		res.WriteHeader(http.StatusNoContent)
+	})
+	handler := RequireRole(RoleAdmin)(next)
+
+	testCases := []struct {
+		name               string
+		principal          *Principal
+		expectedStatusCode int
+	}{
+		{
+			name:               "Should allow principal with required role",
+			principal:          &Principal{Subject: "ops", Roles: []Role{RoleAdmin}},
+			expectedStatusCode: http.StatusNoContent,
+		},
+		{
+			name:               "Should reject principal without required role",
+			principal:          &Principal{Subject: "harbor", Roles: []Role{RoleScan}},
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "Should reject request without principal",
+			expectedStatusCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/queue/purge", nil)
+			if tc.principal != nil {
+				req = req.WithContext(WithPrincipal(req.Context(), *tc.principal))
+			}
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rr.Code)
+		})
+	}
+}
+
+func TestNoneProvider_Authenticate(t *testing.T) {
+	principal, err := NewNoneProvider().Authenticate(httptest.NewRequest(http.MethodGet, "/api/v1/metadata", nil))
+	require.NoError(t, err)
+	assert.True(t, principal.HasRole(RoleScan))
+	assert.False(t, principal.HasRole(RoleAdmin), "anonymous requests should not be granted admin role")
+}
+
+func TestPrincipal_HasRole(t *testing.T) {
+	assert.True(t, Principal{Roles: []Role{RoleScan}}.HasRole(RoleScan))
+	assert.False(t, Principal{Roles: []Role{RoleScan}}.HasRole(RoleAdmin))
+	assert.True(t, Principal{Roles: []Role{RoleAdmin}}.HasRole(RoleScan), "admin role should imply scan role")
+	assert.False(t, Principal{}.HasRole(RoleScan))
+}
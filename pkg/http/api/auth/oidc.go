@@ -0,0 +1,363 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/httpx"
+)
+
+const (
+	// clockSkew is the leeway applied when checking the exp and nbf claims.
+	clockSkew = time.Minute
+	// keysMinRefreshInterval rate limits fetching the JSON Web Key Set when a token refers to an unknown key.
+	keysMinRefreshInterval = 30 * time.Second
+	// keysMaxAge is the age after which the JSON Web Key Set is fetched again to pick up rotated keys.
+	keysMaxAge = time.Hour
+	// oidcTimeout bounds the requests for the discovery document and the keys.
+	oidcTimeout = 30 * time.Second
+)
+
+// OIDCConfig configures the Provider constructed with NewOIDCProvider.
+type OIDCConfig struct {
+	// IssuerURL must match the iss claim of accepted tokens.
+	IssuerURL string
+	// Audience must be one of the values of the aud claim of accepted tokens.
+	Audience string
+	// JWKSURL is the URL of the JSON Web Key Set. If blank it's discovered from the issuer's
+	// /.well-known/openid-configuration document.
+	JWKSURL string
+	// AdminSubjects are the values of the sub claim granted the admin role. Other subjects are granted the
+	// scan role.
+	AdminSubjects []string
+	// Client is the HTTP client used to fetch the discovery document and the keys. Defaults to a client sharing
+	// the pooled connections of httpx.Client.
+	Client *http.Client
+}
+
+type oidcProvider struct {
+	issuer   string
+	audience string
+	jwksURL  string
+	admins   []string
+	client   *http.Client
+	now      func() time.Time
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+// NewOIDCProvider constructs a Provider, which accepts requests carrying a JWT bearer token signed by the
+// configured OpenID Connect issuer for the configured audience. Tokens must be signed with one of the RS256,
+// RS384, RS512, ES256, ES384 or ES512 algorithms.
+func NewOIDCProvider(ctx context.Context, config OIDCConfig) (Provider, error) {
+	if config.IssuerURL == "" {
+		return nil, errors.New("OIDC issuer URL must not be blank")
+	}
+	if config.Audience == "" {
+		return nil, errors.New("OIDC audience must not be blank")
+	}
+
+	p := &oidcProvider{
+		issuer:   config.IssuerURL,
+		audience: config.Audience,
+		jwksURL:  config.JWKSURL,
+		admins:   config.AdminSubjects,
+		client:   config.Client,
+		now:      time.Now,
+	}
+	if p.client == nil {
+		p.client = httpx.Client("oidc", oidcTimeout)
+	}
+
+	if p.jwksURL == "" {
+		jwksURL, err := p.discover(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("discovering OIDC provider: %w", err)
+		}
+		p.jwksURL = jwksURL
+	}
+
+	return p, nil
+}
+
+func (p *oidcProvider) Authenticate(req *http.Request) (Principal, error) {
+	token := bearerToken(req)
+	if token == "" {
+		return Principal{}, fmt.Errorf("%w: missing bearer token", ErrUnauthenticated)
+	}
+
+	claims, err := p.verify(req.Context(), token)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+	role := RoleScan
+	if claims.Subject != "" && slices.Contains(p.admins, claims.Subject) {
+		role = RoleAdmin
+	}
+	return Principal{Subject: claims.Subject, Roles: []Role{role}}, nil
+}
+
+// signingAlgorithms are the algorithms accepted tokens may be signed with.
+var signingAlgorithms = []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}
+
+func (p *oidcProvider) verify(ctx context.Context, token string) (*jwt.RegisteredClaims, error) {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods(signingAlgorithms),
+		jwt.WithIssuer(p.issuer),
+		jwt.WithAudience(p.audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(clockSkew),
+		jwt.WithTimeFunc(p.now),
+	)
+	claims := &jwt.RegisteredClaims{}
+	// keyErr is the reason the signing key of the token could not be got, if any, which the parser would wrap.
+	var keyErr error
+	parsed, err := parser.ParseWithClaims(token, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := p.key(ctx, kid)
+		if err == nil {
+			err = matchKey(token.Method, key)
+		}
+		keyErr = err
+		return key, err
+	})
+	if keyErr != nil {
+		return nil, keyErr
+	}
+	if err != nil {
+		return nil, reason(parsed, claims, err)
+	}
+	return claims, nil
+}
+
+// matchKey returns an error if the given key is not meant to verify signatures of the given method.
+func matchKey(method jwt.SigningMethod, key crypto.PublicKey) error {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		if _, ok := method.(*jwt.SigningMethodRSA); !ok {
+			return fmt.Errorf("signing algorithm %s does not match RSA key", method.Alg())
+		}
+	case *ecdsa.PublicKey:
+		if _, ok := method.(*jwt.SigningMethodECDSA); !ok {
+			return fmt.Errorf("signing algorithm %s does not match EC key", method.Alg())
+		}
+	}
+	return nil
+}
+
+// reason returns why the given token was rejected with the given error by the parser.
+func reason(token *jwt.Token, claims *jwt.RegisteredClaims, err error) error {
+	switch {
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return errors.New("malformed token")
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid):
+		if token != nil && !slices.Contains(signingAlgorithms, token.Method.Alg()) {
+			return fmt.Errorf("unsupported signing algorithm: %s", token.Method.Alg())
+		}
+		return errors.New("invalid signature")
+	case errors.Is(err, jwt.ErrTokenRequiredClaimMissing):
+		switch {
+		case claims.ExpiresAt == nil:
+			return errors.New("missing exp claim")
+		case claims.Issuer == "":
+			return errors.New("missing iss claim")
+		}
+		return errors.New("missing aud claim")
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		return fmt.Errorf("unexpected issuer: %s", claims.Issuer)
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		return fmt.Errorf("unexpected audience: %s", strings.Join(claims.Audience, ","))
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return errors.New("token is expired")
+	case errors.Is(err, jwt.ErrTokenNotValidYet):
+		return errors.New("token is not valid yet")
+	}
+	return err
+}
+
+// key returns the public key with the given identifier. The JSON Web Key Set is fetched again when it's
+// older than keysMaxAge, or when the key is unknown and the last fetch is older than keysMinRefreshInterval.
+// Keys are fetched without holding the lock, so that the requests verified with the cached keys are not held up
+// by a slow issuer, and only the request due to fetch them does.
+func (p *oidcProvider) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	now := p.now()
+	age := now.Sub(p.fetchedAt)
+	key, known := p.lookup(kid)
+	fetch := p.keys == nil || age > keysMaxAge || (!known && age > keysMinRefreshInterval)
+	if fetch {
+		p.fetchedAt = now
+	}
+	p.mu.Unlock()
+
+	if fetch {
+		keys, err := p.fetchKeys(ctx)
+
+		p.mu.Lock()
+		if err == nil {
+			p.keys = keys
+		}
+		key, known = p.lookup(kid)
+		fetched := p.keys != nil
+		p.mu.Unlock()
+
+		if err != nil {
+			if !fetched {
+				return nil, fmt.Errorf("fetching JSON Web Key Set: %w", err)
+			}
+			slog.Warn("Error while refreshing JSON Web Key Set", slog.String("url", p.jwksURL),
+				slog.String("err", err.Error()))
+		}
+	}
+
+	if !known {
+		return nil, fmt.Errorf("unknown signing key: %s", kid)
+	}
+	return key, nil
+}
+
+// lookup returns the key with the given identifier. Tokens without a kid header are accepted as long as
+// the key set holds a single key. The lock must be held.
+func (p *oidcProvider) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func (p *oidcProvider) discover(ctx context.Context) (string, error) {
+	var document struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.issuer, "/")+"/.well-known/openid-configuration", &document); err != nil {
+		return "", err
+	}
+	if document.Issuer != p.issuer {
+		return "", fmt.Errorf("issuer mismatch: expected %s, got %s", p.issuer, document.Issuer)
+	}
+	if document.JWKSURI == "" {
+		return "", errors.New("jwks_uri must not be blank")
+	}
+	return document.JWKSURI, nil
+}
+
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	Y       string `json:"y"`
+}
+
+func (p *oidcProvider) fetchKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.getJSON(ctx, p.jwksURL, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			slog.Warn("Skipping JSON Web Key", slog.String("kid", jwk.KeyID), slog.String("err", err.Error()))
+			continue
+		}
+		keys[jwk.KeyID] = key
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("decoding modulus: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("decoding exponent: %w", err)
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("exponent is too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve: %s", k.Curve)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("decoding x coordinate: %w", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("decoding y coordinate: %w", err)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type: %s", k.KeyType)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func (p *oidcProvider) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", res.StatusCode, url)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
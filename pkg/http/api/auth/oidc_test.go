@@ -0,0 +1,356 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAudience = "harbor-scanner-tunnel"
+
+// testIssuer is an OpenID Connect issuer serving the discovery document and the JSON Web Key Set.
+type testIssuer struct {
+	*httptest.Server
+	rsaKey     *rsa.PrivateKey
+	ecKey      *ecdsa.PrivateKey
+	keyFetches int
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	issuer := &testIssuer{rsaKey: rsaKey, ecKey: ecKey}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(res http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(res).Encode(map[string]string{
+			"issuer":   issuer.URL,
+			"jwks_uri": issuer.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(res http.ResponseWriter, _ *http.Request) {
+		issuer.keyFetches++
+		_ = json.NewEncoder(res).Encode(map[string]any{
+			"keys": []map[string]string{
+				{
+					"kty": "RSA",
+					"kid": "rsa",
+					"use": "sig",
+					"n":   encode(rsaKey.N.Bytes()),
+					"e":   encode(big.NewInt(int64(rsaKey.E)).Bytes()),
+				},
+				{
+					"kty": "EC",
+					"kid": "ec",
+					"crv": "P-256",
+					"x":   encode(ecKey.X.FillBytes(make([]byte, 32))),
+					"y":   encode(ecKey.Y.FillBytes(make([]byte, 32))),
+				},
+			},
+		})
+	})
+	issuer.Server = httptest.NewServer(mux)
+	t.Cleanup(issuer.Close)
+	return issuer
+}
+
+func (i *testIssuer) sign(t *testing.T, alg, kid string, claims map[string]any) string {
+	t.Helper()
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	signed := encode(header) + "." + encode(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch alg {
+	case "RS256":
+		signature, err = rsa.SignPKCS1v15(rand.Reader, i.rsaKey, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, i.ecKey, digest[:])
+		require.NoError(t, err)
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return signed + "." + encode(signature)
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func TestOIDCProvider_Authenticate(t *testing.T) {
+	issuer := newTestIssuer(t)
+	now := time.Now()
+
+	validClaims := func(overrides map[string]any) map[string]any {
+		claims := map[string]any{
+			"iss": issuer.URL,
+			"sub": "system:serviceaccount:harbor:core",
+			"aud": testAudience,
+			"exp": now.Add(time.Hour).Unix(),
+			"nbf": now.Add(-time.Minute).Unix(),
+		}
+		for k, v := range overrides {
+			if v == nil {
+				delete(claims, k)
+				continue
+			}
+			claims[k] = v
+		}
+		return claims
+	}
+
+	provider, err := NewOIDCProvider(context.Background(), OIDCConfig{
+		IssuerURL: issuer.URL,
+		Audience:  testAudience,
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name          string
+		token         string
+		expectedError string
+	}{
+		{
+			name:  "Should accept token signed with RS256",
+			token: issuer.sign(t, "RS256", "rsa", validClaims(nil)),
+		},
+		{
+			name:  "Should accept token signed with ES256",
+			token: issuer.sign(t, "ES256", "ec", validClaims(nil)),
+		},
+		{
+			name:  "Should accept token with audience array",
+			token: issuer.sign(t, "RS256", "rsa", validClaims(map[string]any{"aud": []string{"other", testAudience}})),
+		},
+		{
+			name:          "Should reject missing token",
+			expectedError: "unauthenticated: missing bearer token",
+		},
+		{
+			name:          "Should reject malformed token",
+			token:         "not-a-jwt",
+			expectedError: "unauthenticated: malformed token",
+		},
+		{
+			name:          "Should reject token signed with unknown key",
+			token:         issuer.sign(t, "RS256", "rotated", validClaims(nil)),
+			expectedError: "unauthenticated: unknown signing key: rotated",
+		},
+		{
+			name:          "Should reject token with algorithm not matching key",
+			token:         issuer.sign(t, "ES256", "rsa", validClaims(nil)),
+			expectedError: "unauthenticated: signing algorithm ES256 does not match RSA key",
+		},
+		{
+			name:          "Should reject unsigned token",
+			token:         issuer.sign(t, "none", "rsa", validClaims(nil)),
+			expectedError: "unauthenticated: unsupported signing algorithm: none",
+		},
+		{
+			name:          "Should reject token from other issuer",
+			token:         issuer.sign(t, "RS256", "rsa", validClaims(map[string]any{"iss": "https://evil.example.com"})),
+			expectedError: "unauthenticated: unexpected issuer: https://evil.example.com",
+		},
+		{
+			name:          "Should reject token for other audience",
+			token:         issuer.sign(t, "RS256", "rsa", validClaims(map[string]any{"aud": "other"})),
+			expectedError: "unauthenticated: unexpected audience: other",
+		},
+		{
+			name:          "Should reject expired token",
+			token:         issuer.sign(t, "RS256", "rsa", validClaims(map[string]any{"exp": now.Add(-time.Hour).Unix()})),
+			expectedError: "unauthenticated: token is expired",
+		},
+		{
+			name:          "Should reject token without expiry",
+			token:         issuer.sign(t, "RS256", "rsa", validClaims(map[string]any{"exp": nil})),
+			expectedError: "unauthenticated: missing exp claim",
+		},
+		{
+			name:          "Should reject token not valid yet",
+			token:         issuer.sign(t, "RS256", "rsa", validClaims(map[string]any{"nbf": now.Add(time.Hour).Unix()})),
+			expectedError: "unauthenticated: token is not valid yet",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/metadata", nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+
+			principal, err := provider.Authenticate(req)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				assert.ErrorIs(t, err, ErrUnauthenticated)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, Principal{Subject: "system:serviceaccount:harbor:core", Roles: []Role{RoleScan}}, principal)
+		})
+	}
+
+	t.Run("Should grant admin role to admin subjects", func(t *testing.T) {
+		admin, err := NewOIDCProvider(context.Background(), OIDCConfig{
+			IssuerURL:     issuer.URL,
+			Audience:      testAudience,
+			AdminSubjects: []string{"system:serviceaccount:ops:janitor"},
+		})
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/admin", nil)
+		req.Header.Set("Authorization", "Bearer "+issuer.sign(t, "RS256", "rsa",
+			validClaims(map[string]any{"sub": "system:serviceaccount:ops:janitor"})))
+		principal, err := admin.Authenticate(req)
+		require.NoError(t, err)
+		assert.Equal(t, Principal{Subject: "system:serviceaccount:ops:janitor", Roles: []Role{RoleAdmin}}, principal)
+	})
+
+	t.Run("Should reject token with tampered claims", func(t *testing.T) {
+		token := issuer.sign(t, "RS256", "rsa", validClaims(nil))
+		forged := issuer.sign(t, "RS256", "rsa", validClaims(map[string]any{"sub": "admin"}))
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/metadata", nil)
+		req.Header.Set("Authorization", "Bearer "+token[:len(token)-342]+forged[len(forged)-342:])
+		_, err := provider.Authenticate(req)
+		assert.EqualError(t, err, "unauthenticated: invalid signature")
+	})
+}
+
+func TestOIDCProvider_KeyRefresh(t *testing.T) {
+	issuer := newTestIssuer(t)
+	now := time.Now()
+
+	provider, err := NewOIDCProvider(context.Background(), OIDCConfig{
+		IssuerURL: issuer.URL,
+		Audience:  testAudience,
+		JWKSURL:   issuer.URL + "/keys",
+	})
+	require.NoError(t, err)
+	p := provider.(*oidcProvider)
+	p.now = func() time.Time { return now }
+
+	authenticate := func(kid string) error {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/metadata", nil)
+		req.Header.Set("Authorization", "Bearer "+issuer.sign(t, "RS256", kid, map[string]any{
+			"iss": issuer.URL,
+			"aud": testAudience,
+			"exp": now.Add(time.Hour).Unix(),
+		}))
+		_, err := provider.Authenticate(req)
+		return err
+	}
+
+	require.NoError(t, authenticate("rsa"))
+	require.NoError(t, authenticate("rsa"))
+	assert.Equal(t, 1, issuer.keyFetches, "keys should be cached")
+
+	assert.Error(t, authenticate("rotated"))
+	assert.Equal(t, 1, issuer.keyFetches, "unknown keys should not refetch keys within the minimum refresh interval")
+
+	now = now.Add(keysMinRefreshInterval + time.Second)
+	assert.Error(t, authenticate("rotated"))
+	assert.Equal(t, 2, issuer.keyFetches, "unknown keys should refetch keys after the minimum refresh interval")
+
+	now = now.Add(keysMaxAge + time.Second)
+	require.NoError(t, authenticate("rsa"))
+	assert.Equal(t, 3, issuer.keyFetches, "keys should be refetched when stale")
+}
+
+func TestOIDCProvider_SlowKeyRefresh(t *testing.T) {
+	issuer := newTestIssuer(t)
+	now := time.Now()
+
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	keys := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if fetches.Add(1) > 1 {
+			<-release
+		}
+		issuer.Config.Handler.ServeHTTP(res, req)
+	}))
+	defer keys.Close()
+
+	provider, err := NewOIDCProvider(context.Background(), OIDCConfig{
+		IssuerURL: issuer.URL,
+		Audience:  testAudience,
+		JWKSURL:   keys.URL + "/keys",
+	})
+	require.NoError(t, err)
+	p := provider.(*oidcProvider)
+	p.now = func() time.Time { return now }
+
+	token := issuer.sign(t, "RS256", "rsa", map[string]any{
+		"iss": issuer.URL,
+		"aud": testAudience,
+		"exp": now.Add(2 * keysMaxAge).Unix(),
+	})
+	authenticate := func() <-chan error {
+		done := make(chan error, 1)
+		go func() {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/metadata", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			_, err := provider.Authenticate(req)
+			done <- err
+		}()
+		return done
+	}
+
+	require.NoError(t, <-authenticate())
+	now = now.Add(keysMaxAge + time.Second)
+
+	refreshing := authenticate()
+	require.Eventually(t, func() bool { return fetches.Load() == 2 }, 5*time.Second, 10*time.Millisecond)
+
+	select {
+	case err := <-authenticate():
+		assert.NoError(t, err, "cached keys should verify tokens while keys are refreshed")
+	case <-time.After(5 * time.Second):
+		t.Error("cached keys should not wait for keys to be refreshed")
+	}
+
+	close(release)
+	assert.NoError(t, <-refreshing)
+}
+
+func TestNewOIDCProvider(t *testing.T) {
+	issuer := newTestIssuer(t)
+
+	t.Run("Should return error when discovery document is missing", func(t *testing.T) {
+		_, err := NewOIDCProvider(context.Background(), OIDCConfig{
+			IssuerURL: issuer.URL + "/realms/harbor",
+			Audience:  testAudience,
+		})
+		assert.EqualError(t, err, "discovering OIDC provider: unexpected status 404 from "+
+			issuer.URL+"/realms/harbor/.well-known/openid-configuration")
+	})
+
+	t.Run("Should return error when audience is blank", func(t *testing.T) {
+		_, err := NewOIDCProvider(context.Background(), OIDCConfig{IssuerURL: issuer.URL})
+		assert.EqualError(t, err, "OIDC audience must not be blank")
+	})
+}
@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+)
+
+const (
+	SignatureNone = "none"
+	SignatureHMAC = "hmac"
+	SignatureJWT  = "jwt"
+)
+
+// HeaderSignature carries the signature of a scan request, attached by Harbor or a gateway in front of the adapter.
+const HeaderSignature = "X-Scanner-Request-Signature"
+
+// ErrInvalidSignature is returned when a scan request has no signature, or an invalid one.
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// SignatureVerifier wraps the Verify method.
+// Verify returns an error wrapping ErrInvalidSignature unless the given signature binds the given scan request,
+// decoded from the given body, to its artifact digest.
+type SignatureVerifier interface {
+	Verify(signature string, body []byte, req harbor.ScanRequest) error
+}
+
+// NewSignatureVerifier constructs the SignatureVerifier configured by the given scheme, or nil if scan requests
+// are not signed.
+func NewSignatureVerifier(config etc.Auth) (SignatureVerifier, error) {
+	switch config.SignatureScheme {
+	case "", SignatureNone:
+		return nil, nil
+	case SignatureHMAC:
+		return NewHMACVerifier(config.SignatureSecret, config.SignatureMaxAge)
+	case SignatureJWT:
+		return NewJWTVerifier(config.SignatureSecret)
+	}
+	return nil, fmt.Errorf("unsupported signature scheme: %s", config.SignatureScheme)
+}
+
+type hmacVerifier struct {
+	secret []byte
+	maxAge time.Duration
+	now    func() time.Time
+}
+
+// NewHMACVerifier constructs a SignatureVerifier accepting signatures of the form t=<timestamp>,v1=<signature>,
+// where the timestamp is in Unix seconds and the signature is the hex encoded HMAC-SHA256 of
+// <timestamp>.<body> keyed with the given secret. As the body holds the artifact digest, a signature cannot be
+// reused for another artifact, and it is only accepted within the given max age to limit replays.
+func NewHMACVerifier(secret string, maxAge time.Duration) (SignatureVerifier, error) {
+	if secret == "" {
+		return nil, errors.New("signature secret must not be blank")
+	}
+	return &hmacVerifier{secret: []byte(secret), maxAge: maxAge, now: time.Now}, nil
+}
+
+func (v *hmacVerifier) Verify(signature string, body []byte, _ harbor.ScanRequest) error {
+	var timestamp, signatures []string
+	for _, field := range strings.Split(signature, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		switch key {
+		case "t":
+			timestamp = append(timestamp, value)
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if len(timestamp) != 1 || len(signatures) == 0 {
+		return fmt.Errorf("%w: malformed signature", ErrInvalidSignature)
+	}
+
+	seconds, err := strconv.ParseInt(timestamp[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: malformed timestamp", ErrInvalidSignature)
+	}
+	if age := v.now().Sub(time.Unix(seconds, 0)); age > v.maxAge || age < -clockSkew {
+		return fmt.Errorf("%w: timestamp outside of tolerance", ErrInvalidSignature)
+	}
+
+	mac := hmac.New(sha256.New, v.secret)
+	mac.Write([]byte(timestamp[0] + "."))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+
+	// Several signatures are accepted, so that the secret can be rotated without rejecting requests.
+	for _, s := range signatures {
+		actual, err := hex.DecodeString(s)
+		if err == nil && hmac.Equal(actual, expected) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: signature mismatch", ErrInvalidSignature)
+}
+
+type jwtVerifier struct {
+	secret []byte
+	now    func() time.Time
+}
+
+// requestClaims are the claims of a JWT signing a scan request.
+type requestClaims struct {
+	jwt.RegisteredClaims
+	Digest     string `json:"digest"`
+	Repository string `json:"repository"`
+}
+
+// NewJWTVerifier constructs a SignatureVerifier accepting JWTs signed with HS256 and the given secret. The digest
+// claim must match the artifact digest of the scan request, as must the repository claim if present, and the exp
+// claim is required.
+func NewJWTVerifier(secret string) (SignatureVerifier, error) {
+	if secret == "" {
+		return nil, errors.New("signature secret must not be blank")
+	}
+	return &jwtVerifier{secret: []byte(secret), now: time.Now}, nil
+}
+
+func (v *jwtVerifier) Verify(signature string, _ []byte, req harbor.ScanRequest) error {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(clockSkew),
+		jwt.WithTimeFunc(v.now),
+	)
+	var claims requestClaims
+	token, err := parser.ParseWithClaims(signature, &claims, func(_ *jwt.Token) (any, error) {
+		return v.secret, nil
+	})
+	switch {
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return fmt.Errorf("%w: malformed token", ErrInvalidSignature)
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid):
+		if token != nil && token.Method != jwt.SigningMethodHS256 {
+			return fmt.Errorf("%w: unsupported algorithm: %s", ErrInvalidSignature, token.Method.Alg())
+		}
+		return fmt.Errorf("%w: signature mismatch", ErrInvalidSignature)
+	case errors.Is(err, jwt.ErrTokenRequiredClaimMissing):
+		return fmt.Errorf("%w: missing exp claim", ErrInvalidSignature)
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return fmt.Errorf("%w: token is expired", ErrInvalidSignature)
+	case err != nil:
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if claims.Digest != req.Artifact.Digest {
+		return fmt.Errorf("%w: digest claim does not match artifact", ErrInvalidSignature)
+	}
+	if claims.Repository != "" && claims.Repository != req.Artifact.Repository {
+		return fmt.Errorf("%w: repository claim does not match artifact", ErrInvalidSignature)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+)
+
+var signedRequest = harbor.ScanRequest{
+	Registry: harbor.Registry{URL: "https://core.harbor.domain"},
+	Artifact: harbor.Artifact{
+		Repository: "library/mongo",
+		Digest:     "sha256:917f5b7f4bef1b35ee90f03033f33a81002511c1e0767fd44276d4bd9cd2fa8e",
+	},
+}
+
+func signHMAC(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(fmt.Sprintf("%d.", timestamp)))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func signJWT(t *testing.T, secret string, header, claims map[string]any) string {
+	t.Helper()
+	encode := func(v any) string {
+		b, err := json.Marshal(v)
+		require.NoError(t, err)
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	signed := encode(header) + "." + encode(claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestHMACVerifier_Verify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"artifact":{"repository":"library/mongo","digest":"sha256:917f"}}`)
+
+	verifier, err := NewHMACVerifier("s3cret", 5*time.Minute)
+	require.NoError(t, err)
+	verifier.(*hmacVerifier).now = func() time.Time { return now }
+
+	testCases := []struct {
+		name          string
+		signature     string
+		body          []byte
+		expectedError string
+	}{
+		{
+			name:      "Should accept valid signature",
+			signature: fmt.Sprintf("t=%d,v1=%s", now.Unix(), signHMAC("s3cret", now.Unix(), body)),
+			body:      body,
+		},
+		{
+			name: "Should accept any valid signature when secret is rotated",
+			signature: fmt.Sprintf("t=%d, v1=%s, v1=%s", now.Unix(),
+				signHMAC("0ld", now.Unix(), body), signHMAC("s3cret", now.Unix(), body)),
+			body: body,
+		},
+		{
+			name:          "Should reject missing signature",
+			body:          body,
+			expectedError: "invalid signature: malformed signature",
+		},
+		{
+			name:          "Should reject signature of another body",
+			signature:     fmt.Sprintf("t=%d,v1=%s", now.Unix(), signHMAC("s3cret", now.Unix(), body)),
+			body:          []byte(`{"artifact":{"repository":"library/mongo","digest":"sha256:0000"}}`),
+			expectedError: "invalid signature: signature mismatch",
+		},
+		{
+			name:          "Should reject signature with another secret",
+			signature:     fmt.Sprintf("t=%d,v1=%s", now.Unix(), signHMAC("wrong", now.Unix(), body)),
+			body:          body,
+			expectedError: "invalid signature: signature mismatch",
+		},
+		{
+			name: "Should reject expired signature",
+			signature: fmt.Sprintf("t=%d,v1=%s", now.Add(-10*time.Minute).Unix(),
+				signHMAC("s3cret", now.Add(-10*time.Minute).Unix(), body)),
+			body:          body,
+			expectedError: "invalid signature: timestamp outside of tolerance",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := verifier.Verify(tc.signature, tc.body, signedRequest)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				assert.ErrorIs(t, err, ErrInvalidSignature)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestJWTVerifier_Verify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	hs256 := map[string]any{"alg": "HS256", "typ": "JWT"}
+
+	verifier, err := NewJWTVerifier("s3cret")
+	require.NoError(t, err)
+	verifier.(*jwtVerifier).now = func() time.Time { return now }
+
+	testCases := []struct {
+		name          string
+		signature     string
+		expectedError string
+	}{
+		{
+			name: "Should accept token bound to artifact",
+			signature: signJWT(t, "s3cret", hs256, map[string]any{
+				"digest":     signedRequest.Artifact.Digest,
+				"repository": signedRequest.Artifact.Repository,
+				"exp":        now.Add(time.Minute).Unix(),
+			}),
+		},
+		{
+			name: "Should reject token bound to another digest",
+			signature: signJWT(t, "s3cret", hs256, map[string]any{
+				"digest": "sha256:0000",
+				"exp":    now.Add(time.Minute).Unix(),
+			}),
+			expectedError: "invalid signature: digest claim does not match artifact",
+		},
+		{
+			name: "Should reject token bound to another repository",
+			signature: signJWT(t, "s3cret", hs256, map[string]any{
+				"digest":     signedRequest.Artifact.Digest,
+				"repository": "library/redis",
+				"exp":        now.Add(time.Minute).Unix(),
+			}),
+			expectedError: "invalid signature: repository claim does not match artifact",
+		},
+		{
+			name: "Should reject expired token",
+			signature: signJWT(t, "s3cret", hs256, map[string]any{
+				"digest": signedRequest.Artifact.Digest,
+				"exp":    now.Add(-time.Hour).Unix(),
+			}),
+			expectedError: "invalid signature: token is expired",
+		},
+		{
+			name: "Should reject token without exp claim",
+			signature: signJWT(t, "s3cret", hs256, map[string]any{
+				"digest": signedRequest.Artifact.Digest,
+			}),
+			expectedError: "invalid signature: missing exp claim",
+		},
+		{
+			name: "Should reject token signed with another secret",
+			signature: signJWT(t, "wrong", hs256, map[string]any{
+				"digest": signedRequest.Artifact.Digest,
+				"exp":    now.Add(time.Minute).Unix(),
+			}),
+			expectedError: "invalid signature: signature mismatch",
+		},
+		{
+			name: "Should reject unsigned token",
+			signature: signJWT(t, "s3cret", map[string]any{"alg": "none"}, map[string]any{
+				"digest": signedRequest.Artifact.Digest,
+				"exp":    now.Add(time.Minute).Unix(),
+			}),
+			expectedError: "invalid signature: unsupported algorithm: none",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := verifier.Verify(tc.signature, nil, signedRequest)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestNewSignatureVerifier(t *testing.T) {
+	verifier, err := NewSignatureVerifier(etc.Auth{SignatureScheme: SignatureNone})
+	assert.NoError(t, err)
+	assert.Nil(t, verifier)
+
+	_, err = NewSignatureVerifier(etc.Auth{SignatureScheme: SignatureHMAC})
+	assert.EqualError(t, err, "signature secret must not be blank")
+
+	_, err = NewSignatureVerifier(etc.Auth{SignatureScheme: "ed25519"})
+	assert.EqualError(t, err, "unsupported signature scheme: ed25519")
+}
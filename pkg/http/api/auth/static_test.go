@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticProvider_Authenticate(t *testing.T) {
+	provider, err := NewStaticProvider([]string{"first", "second"}, []string{"admin"})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name              string
+		headers           map[string]string
+		expectedPrincipal Principal
+		expectedError     string
+	}{
+		{
+			name:              "Should accept bearer token",
+			headers:           map[string]string{"Authorization": "Bearer second"},
+			expectedPrincipal: Principal{Subject: "static-token", Roles: []Role{RoleScan}},
+		},
+		{
+			name:              "Should accept bearer token with lower case scheme",
+			headers:           map[string]string{"Authorization": "bearer first"},
+			expectedPrincipal: Principal{Subject: "static-token", Roles: []Role{RoleScan}},
+		},
+		{
+			name:              "Should accept API key header",
+			headers:           map[string]string{"X-ScannerAdapter-API-Key": "first"},
+			expectedPrincipal: Principal{Subject: "static-token", Roles: []Role{RoleScan}},
+		},
+		{
+			name:              "Should accept admin token",
+			headers:           map[string]string{"Authorization": "Bearer admin"},
+			expectedPrincipal: Principal{Subject: "static-admin-token", Roles: []Role{RoleAdmin}},
+		},
+		{
+			name:          "Should reject missing token",
+			expectedError: "unauthenticated: missing token",
+		},
+		{
+			name:          "Should reject basic credentials",
+			headers:       map[string]string{"Authorization": "Basic Zmlyc3Q6"},
+			expectedError: "unauthenticated: missing token",
+		},
+		{
+			name:          "Should reject unknown token",
+			headers:       map[string]string{"Authorization": "Bearer third"},
+			expectedError: "unauthenticated: invalid token",
+		},
+		{
+			name:          "Should reject prefix of known token",
+			headers:       map[string]string{"Authorization": "Bearer firs"},
+			expectedError: "unauthenticated: invalid token",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/metadata", nil)
+			for name, value := range tc.headers {
+				req.Header.Set(name, value)
+			}
+
+			principal, err := provider.Authenticate(req)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				assert.ErrorIs(t, err, ErrUnauthenticated)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedPrincipal, principal)
+		})
+	}
+
+	t.Run("Should return error for blank token", func(t *testing.T) {
+		_, err := NewStaticProvider([]string{"first"}, []string{""})
+		assert.EqualError(t, err, "static token must not be blank")
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"sort"
 	"strings"
 
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
@@ -24,10 +25,14 @@ var MimeTypeDockerImageManifestV2 = MimeType{Type: "application", Subtype: "vnd.
 
 var MimeTypeScanResponse = MimeType{Type: "application", Subtype: "vnd.scanner.adapter.scan.response+json", Params: MimeTypeVersion}
 
-var MimeTypeSecurityVulnerabilityReport = MimeType{Type: "application", Subtype: "vnd.security.vulnerability.report", Params: map[string]string{"version": "1.1"}}
+var MimeTypeSecurityVulnerabilityReport = ReportMimeType(harbor.ReportMimeTypeVulnerabilityV1_1)
+var MimeTypeSecuritySBOMReport = ReportMimeType(harbor.ReportMimeTypeSBOMV1_0)
 var MimeTypeMetadata = MimeType{Type: "application", Subtype: "vnd.scanner.adapter.metadata+json", Params: MimeTypeVersion}
 var MimeTypeError = MimeType{Type: "application", Subtype: "vnd.scanner.adapter.error", Params: MimeTypeVersion}
 
+// MimeTypeJSON is the media type of responses of the endpoints, which are not defined by Scanners API.
+var MimeTypeJSON = MimeType{Type: "application", Subtype: "json"}
+
 type MimeType struct {
 	Type    string
 	Subtype string
@@ -43,23 +48,14 @@ func (mt MimeType) String() string {
 	for k, v := range mt.Params {
 		params = append(params, fmt.Sprintf("%s=%s", k, v))
 	}
+	sort.Strings(params)
 	return fmt.Sprintf("%s; %s", s, strings.Join(params, ";"))
 }
 
-func (mt *MimeType) FromAcceptHeader(value string) error {
-	switch value {
-	case "", "*/*", MimeTypeSecurityVulnerabilityReport.String():
-		mt.Type = MimeTypeSecurityVulnerabilityReport.Type
-		mt.Subtype = MimeTypeSecurityVulnerabilityReport.Subtype
-		mt.Params = MimeTypeSecurityVulnerabilityReport.Params
-		return nil
-	case MimeTypeSecurityVulnerabilityReport.String():
-		mt.Type = MimeTypeSecurityVulnerabilityReport.Type
-		mt.Subtype = MimeTypeSecurityVulnerabilityReport.Subtype
-		mt.Params = MimeTypeSecurityVulnerabilityReport.Params
-		return nil
-	}
-	return fmt.Errorf("unsupported mime type: %s", value)
+// ReportMimeType returns the MimeType of the given versioned MIME type of reports.
+func ReportMimeType(t harbor.ReportMimeType) MimeType {
+	typ, subtype, _ := strings.Cut(t.MediaType, "/")
+	return MimeType{Type: typ, Subtype: subtype, Params: MimeTypeParams{"version": t.Version}}
 }
 
 type BaseHandler struct {
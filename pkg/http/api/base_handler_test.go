@@ -34,6 +34,18 @@ func TestMimeType_String(t *testing.T) {
 	}
 }
 
+func TestReportMimeType(t *testing.T) {
+	for _, mimeType := range []harbor.ReportMimeType{
+		harbor.ReportMimeTypeVulnerabilityV1_0,
+		harbor.ReportMimeTypeVulnerabilityV1_1,
+		harbor.ReportMimeTypeSBOMV1_0,
+	} {
+		t.Run(mimeType.String(), func(t *testing.T) {
+			assert.Equal(t, mimeType.String(), ReportMimeType(mimeType).String())
+		})
+	}
+}
+
 func TestBaseHandler_WriteJSONError(t *testing.T) {
 	// given
 	recorder := httptest.NewRecorder()
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+)
+
+const (
+	headerOrigin                      = "Origin"
+	headerVary                        = "Vary"
+	headerAccessControlRequestMethod  = "Access-Control-Request-Method"
+	headerAccessControlRequestHeaders = "Access-Control-Request-Headers"
+	headerAccessControlAllowOrigin    = "Access-Control-Allow-Origin"
+	headerAccessControlAllowMethods   = "Access-Control-Allow-Methods"
+	headerAccessControlAllowHeaders   = "Access-Control-Allow-Headers"
+	headerAccessControlExposeHeaders  = "Access-Control-Expose-Headers"
+	headerAccessControlMaxAge         = "Access-Control-Max-Age"
+)
+
+const (
+	corsAnyOrigin = "*"
+	// corsExposedHeaders are the response headers, besides the CORS-safelisted ones, readable by browsers.
+	corsExposedHeaders = "ETag, Location, WWW-Authenticate, X-Scanner-Job-Status, X-Scanner-Severity, " +
+		"X-Scanner-Critical-Count, X-Scanner-High-Count, X-Scanner-Medium-Count, X-Scanner-Low-Count, X-Scanner-Unknown-Count"
+)
+
+// CORS implements Cross-Origin Resource Sharing for the configured origins, methods and headers.
+type CORS struct {
+	config etc.CORS
+}
+
+func NewCORS(config etc.CORS) *CORS {
+	return &CORS{config: config}
+}
+
+// Middleware adds CORS headers to responses for requests from allowed origins. It must run before
+// authentication, so that browsers can read error responses too.
+func (c *CORS) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Add(headerVary, headerOrigin)
+		if origin := req.Header.Get(headerOrigin); origin != "" && c.isOriginAllowed(origin) {
+			res.Header().Set(headerAccessControlAllowOrigin, c.allowOrigin(origin))
+			res.Header().Set(headerAccessControlExposeHeaders, corsExposedHeaders)
+		}
+		next.ServeHTTP(res, req)
+	})
+}
+
+// Preflight responds to preflight requests with 204 No Content if the origin, the method and the headers
+// of the actual request are allowed, or with 403 Forbidden otherwise.
+func (c *CORS) Preflight(res http.ResponseWriter, req *http.Request) {
+	res.Header().Add(headerVary, headerOrigin)
+	res.Header().Add(headerVary, headerAccessControlRequestMethod)
+	res.Header().Add(headerVary, headerAccessControlRequestHeaders)
+
+	origin := req.Header.Get(headerOrigin)
+	if origin == "" || !c.isOriginAllowed(origin) {
+		res.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if !c.isMethodAllowed(req.Header.Get(headerAccessControlRequestMethod)) {
+		res.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if !c.areHeadersAllowed(req.Header.Get(headerAccessControlRequestHeaders)) {
+		res.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	res.Header().Set(headerAccessControlAllowOrigin, c.allowOrigin(origin))
+	res.Header().Set(headerAccessControlAllowMethods, strings.Join(c.config.AllowedMethods, ", "))
+	if len(c.config.AllowedHeaders) > 0 {
+		res.Header().Set(headerAccessControlAllowHeaders, strings.Join(c.config.AllowedHeaders, ", "))
+	}
+	if c.config.MaxAge > 0 {
+		res.Header().Set(headerAccessControlMaxAge, strconv.Itoa(int(c.config.MaxAge.Seconds())))
+	}
+	res.WriteHeader(http.StatusNoContent)
+}
+
+func (c *CORS) allowOrigin(origin string) string {
+	if slices.Contains(c.config.AllowedOrigins, corsAnyOrigin) {
+		return corsAnyOrigin
+	}
+	return origin
+}
+
+func (c *CORS) isOriginAllowed(origin string) bool {
+	for _, allowed := range c.config.AllowedOrigins {
+		if allowed == corsAnyOrigin || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CORS) isMethodAllowed(method string) bool {
+	return containsFold(c.config.AllowedMethods, method)
+}
+
+func (c *CORS) areHeadersAllowed(headers string) bool {
+	for _, header := range strings.Split(headers, ",") {
+		header = strings.TrimSpace(header)
+		if header != "" && !containsFold(c.config.AllowedHeaders, header) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsFold(values []string, value string) bool {
+	return slices.ContainsFunc(values, func(v string) bool {
+		return strings.EqualFold(v, value)
+	})
+}
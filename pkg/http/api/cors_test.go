@@ -0,0 +1,129 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORS_Preflight(t *testing.T) {
+	cors := NewCORS(etc.CORS{
+		AllowedOrigins: []string{"https://ui.example.com"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Accept", "Authorization", "Content-Type"},
+		MaxAge:         10 * time.Minute,
+	})
+
+	testCases := []struct {
+		name            string
+		headers         map[string]string
+		expectedStatus  int
+		expectedHeaders map[string]string
+	}{
+		{
+			name: "Should allow preflight request",
+			headers: map[string]string{
+				"Origin":                         "https://ui.example.com",
+				"Access-Control-Request-Method":  "POST",
+				"Access-Control-Request-Headers": "authorization, content-type",
+			},
+			expectedStatus: http.StatusNoContent,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin":  "https://ui.example.com",
+				"Access-Control-Allow-Methods": "GET, POST",
+				"Access-Control-Allow-Headers": "Accept, Authorization, Content-Type",
+				"Access-Control-Max-Age":       "600",
+			},
+		},
+		{
+			name: "Should reject preflight request from unknown origin",
+			headers: map[string]string{
+				"Origin":                        "https://evil.example.com",
+				"Access-Control-Request-Method": "GET",
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin": "",
+			},
+		},
+		{
+			name: "Should reject preflight request for disallowed method",
+			headers: map[string]string{
+				"Origin":                        "https://ui.example.com",
+				"Access-Control-Request-Method": "DELETE",
+			},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name: "Should reject preflight request for disallowed header",
+			headers: map[string]string{
+				"Origin":                         "https://ui.example.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "X-Custom",
+			},
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodOptions, "/api/v1/scan", nil)
+			for name, value := range tc.headers {
+				req.Header.Set(name, value)
+			}
+			rr := httptest.NewRecorder()
+
+			cors.Preflight(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			for name, value := range tc.expectedHeaders {
+				assert.Equal(t, value, rr.Header().Get(name), name)
+			}
+		})
+	}
+}
+
+func TestCORS_Middleware(t *testing.T) {
+	next := http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+		res.WriteHeader(http.StatusOK)
+	})
+
+	t.Run("Should add headers for allowed origin", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/metadata", nil)
+		req.Header.Set("Origin", "https://ui.example.com")
+		rr := httptest.NewRecorder()
+
+		NewCORS(etc.CORS{AllowedOrigins: []string{"https://ui.example.com"}}).Middleware(next).ServeHTTP(rr, req)
+
+		assert.Equal(t, "https://ui.example.com", rr.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "ETag, Location, WWW-Authenticate, X-Scanner-Job-Status, X-Scanner-Severity, "+
+			"X-Scanner-Critical-Count, X-Scanner-High-Count, X-Scanner-Medium-Count, X-Scanner-Low-Count, X-Scanner-Unknown-Count",
+			rr.Header().Get("Access-Control-Expose-Headers"))
+		assert.Equal(t, "Origin", rr.Header().Get("Vary"))
+	})
+
+	t.Run("Should allow any origin with wildcard", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/metadata", nil)
+		req.Header.Set("Origin", "https://ui.example.com")
+		rr := httptest.NewRecorder()
+
+		NewCORS(etc.CORS{AllowedOrigins: []string{"*"}}).Middleware(next).ServeHTTP(rr, req)
+
+		assert.Equal(t, "*", rr.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("Should not add headers for unknown origin", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/metadata", nil)
+		req.Header.Set("Origin", "https://evil.example.com")
+		rr := httptest.NewRecorder()
+
+		NewCORS(etc.CORS{AllowedOrigins: []string{"https://ui.example.com"}}).Middleware(next).ServeHTTP(rr, req)
+
+		assert.Empty(t, rr.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+}
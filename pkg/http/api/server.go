@@ -6,12 +6,14 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"strings"
 
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
 	"golang.org/x/net/context"
+	"golang.org/x/net/netutil"
 )
 
 type Server struct {
@@ -28,48 +30,60 @@ func NewServer(config etc.API, handler http.Handler) (server *Server, err error)
 			ReadTimeout:  config.ReadTimeout,
 			WriteTimeout: config.WriteTimeout,
 			IdleTimeout:  config.IdleTimeout,
+
+			ReadHeaderTimeout: config.ReadHeaderTimeout,
+			MaxHeaderBytes:    config.MaxHeaderBytes,
 		},
 	}
 
 	if config.IsTLSEnabled() {
-		server.server.TLSConfig = &tls.Config{
-			MinVersion: tls.VersionTLS12,
-			// The API server prefers elliptic curves which have assembly implementations
-			// to ensure performance under heavy loads.
-			CurvePreferences: []tls.CurveID{
-				tls.X25519,
-				tls.CurveP256,
-			},
-			// The API server only supports cipher suites which use ECDHE (forward secrecy)
-			// and does not support weak cipher suites that use RC4, 3DES or CBC.
-			CipherSuites: []uint16{
-				tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
-				tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-				tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
-				tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
-				tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
-				tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
-			},
+		if server.server.TLSConfig, err = NewTLSConfig(config); err != nil {
+			return nil, err
 		}
+	}
+
+	return
+}
 
-		if len(config.ClientCAs) > 0 {
-			certPool := x509.NewCertPool()
+// NewTLSConfig returns the TLS configuration of the API server, which requires and verifies client certificates
+// when client CAs are configured. Its certificate is loaded by the server.
+func NewTLSConfig(config etc.API) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// The API server prefers elliptic curves which have assembly implementations
+		// to ensure performance under heavy loads.
+		CurvePreferences: []tls.CurveID{
+			tls.X25519,
+			tls.CurveP256,
+		},
+		// The API server only supports cipher suites which use ECDHE (forward secrecy)
+		// and does not support weak cipher suites that use RC4, 3DES or CBC.
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		},
+	}
 
-			for _, clientCAPath := range config.ClientCAs {
-				clientCA, err := os.ReadFile(clientCAPath)
-				if err != nil {
-					return nil, fmt.Errorf("cound not read file %s: %w", clientCAPath, err)
-				}
+	if len(config.ClientCAs) > 0 {
+		certPool := x509.NewCertPool()
 
-				certPool.AppendCertsFromPEM(clientCA)
+		for _, clientCAPath := range config.ClientCAs {
+			clientCA, err := os.ReadFile(clientCAPath)
+			if err != nil {
+				return nil, fmt.Errorf("cound not read file %s: %w", clientCAPath, err)
 			}
 
-			server.server.TLSConfig.ClientCAs = certPool
-			server.server.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+			certPool.AppendCertsFromPEM(clientCA)
 		}
-	}
 
-	return
+		tlsConfig.ClientCAs = certPool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
 }
 
 func (s *Server) ListenAndServe() {
@@ -83,6 +97,11 @@ func (s *Server) ListenAndServe() {
 }
 
 func (s *Server) listenAndServe() error {
+	listener, err := s.listen()
+	if err != nil {
+		return err
+	}
+
 	if s.config.IsTLSEnabled() {
 		slog.Debug("Starting API server with TLS",
 			slog.String("certificate", s.config.TLSCertificate),
@@ -90,10 +109,44 @@ func (s *Server) listenAndServe() error {
 			slog.String("clientCAs", strings.Join(s.config.ClientCAs, ", ")),
 			slog.String("addr", s.config.Addr),
 		)
-		return s.server.ListenAndServeTLS(s.config.TLSCertificate, s.config.TLSKey)
+		return s.server.ServeTLS(listener, s.config.TLSCertificate, s.config.TLSKey)
 	}
 	slog.Warn("Starting API server without TLS", slog.String("addr", s.config.Addr))
-	return s.server.ListenAndServe()
+	return s.server.Serve(listener)
+}
+
+// listen returns the listener for the configured address. When the number of connections is capped,
+// further connections wait in the accept backlog until one is closed.
+func (s *Server) listen() (net.Listener, error) {
+	addr := s.server.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if s.config.MaxConnections > 0 {
+		slog.Debug("Limiting API server connections", slog.Int("max_connections", s.config.MaxConnections))
+		listener = netutil.LimitListener(listener, s.config.MaxConnections)
+	}
+	return listener, nil
+}
+
+// LimitRequestBody limits the size of request bodies to the given number of bytes. Reading past the limit
+// fails with *http.MaxBytesError. A non-positive limit disables the check.
+func LimitRequestBody(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limit <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			if req.Body != nil {
+				req.Body = http.MaxBytesReader(res, req.Body, limit)
+			}
+			next.ServeHTTP(res, req)
+		})
+	}
 }
 
 func (s *Server) Shutdown() {
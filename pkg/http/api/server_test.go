@@ -1 +1,56 @@
 package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitRequestBody(t *testing.T) {
+	testCases := []struct {
+		name         string
+		limit        int64
+		body         string
+		expectedBody string
+		expectedErr  string
+	}{
+		{
+			name:         "Should read body within limit",
+			limit:        5,
+			body:         "hello",
+			expectedBody: "hello",
+		},
+		{
+			name:        "Should fail reading body over limit",
+			limit:       4,
+			body:        "hello",
+			expectedErr: "http: request body too large",
+		},
+		{
+			name:         "Should not limit body when limit is zero",
+			body:         "hello",
+			expectedBody: "hello",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := LimitRequestBody(tc.limit)(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+				body, err := io.ReadAll(req.Body)
+				if tc.expectedErr != "" {
+					assert.EqualError(t, err, tc.expectedErr)
+					return
+				}
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedBody, string(body))
+			}))
+
+			handler.ServeHTTP(httptest.NewRecorder(),
+				httptest.NewRequest(http.MethodPost, "/api/v1/scan", strings.NewReader(tc.body)))
+		})
+	}
+}
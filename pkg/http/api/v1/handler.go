@@ -1,31 +1,79 @@
 package v1
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"math"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/gorilla/mux"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/clock"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/connectivity"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/http/api"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/http/api/auth"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/impact"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence/history"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/policy"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/queue"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/slo"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/support"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tracing"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
-	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/samber/lo"
 )
 
 const (
-	pathVarScanRequestID = "scan_request_id"
+	pathVarScanRequestID   = "scan_request_id"
+	pathVarVulnerabilityID = "vulnerability_id"
+	pathVarAssessmentID    = "assessment_id"
+	pathVarExceptionID     = "exception_id"
 
 	propertyScannerType    = "harbor.scanner-adapter/scanner-type"
 	propertyDBUpdatedAt    = "harbor.scanner-adapter/vulnerability-database-updated-at"
 	propertyDBNextUpdateAt = "harbor.scanner-adapter/vulnerability-database-next-update-at"
+
+	// headerScanJobStatus and the summary headers let clients gate on scan results without parsing the body.
+	headerScanJobStatus = "X-Scanner-Job-Status"
+	headerSeverity      = "X-Scanner-Severity"
+
+	// queryIncludeEcosystems and queryExcludeEcosystems override the ecosystems of the vulnerabilities included in
+	// and excluded from scan reports, as comma-separated lists.
+	queryIncludeEcosystems = "include_ecosystems"
+	queryExcludeEcosystems = "exclude_ecosystems"
+
+	// querySBOMMediaType is the media type of the SBOM requested from the report endpoint by Harbor.
+	querySBOMMediaType = "sbom_media_type"
+
+	// queryForce forces a fresh scan of the artifact, rather than reusing the report of the same digest.
+	queryForce = "force"
+
+	// queryStatus, queryAfter and queryLimit filter and paginate the scan jobs listed by the ListScanJobs
+	// endpoint. The status may be repeated, or hold comma-separated statuses.
+	queryStatus = "status"
+	queryAfter  = "after"
+	queryLimit  = "limit"
+)
+
+const (
+	defaultScanJobsLimit = 100
+	maxScanJobsLimit     = 1000
 )
 
 type requestHandler struct {
@@ -34,25 +82,234 @@ type requestHandler struct {
 	enqueuer queue.Enqueuer
 	store    persistence.Store
 	wrapper  tunnel.Wrapper
+	auth     auth.Provider
+	index    persistence.VulnerabilityIndex
+	assessor impact.Assessor
+	policy   policy.Engine
+	support  support.Generator
+	// signatures verifies the signatures of scan requests, nil if scan requests are not signed.
+	signatures auth.SignatureVerifier
+	slo        slo.Tracker
+	// pin is the pin of the Tunnel binary, nil if it is not pinned.
+	pin tunnel.Pin
+	// redisCheck checks the connection to Redis, nil if it is not checked.
+	redisCheck func(ctx context.Context) error
+	metadata   *metadataCache
+	// versions caches the version of the vulnerability database checked by readiness probes.
+	versions *tunnel.VersionCache
+	// reportMimeTypes are the MIME types of the reports served by the report endpoint, in order of preference.
+	reportMimeTypes []harbor.ReportMimeType
+	// prober probes the outbound destinations of the adapter, nil if probes are disabled.
+	prober connectivity.Prober
+	// history keeps the scan jobs completed recently, nil if they are not kept.
+	history history.History
+	// lifetimes keeps the lifetimes of the vulnerabilities of artifacts, nil if they are not tracked.
+	lifetimes persistence.LifetimeStore
+	// exceptions keeps the exceptions to the policy honored by verdicts, nil if exceptions are not served.
+	exceptions persistence.ExceptionStore
+	// clock times the policy exceptions, the stats, the support bundles and the cached metadata and versions.
+	clock clock.Clock
 	api.BaseHandler
 }
 
-func NewAPIHandler(info etc.BuildInfo, config etc.Config, enqueuer queue.Enqueuer, store persistence.Store, wrapper tunnel.Wrapper) http.Handler {
+// Option customizes the handler constructed with NewAPIHandler.
+type Option func(h *requestHandler)
+
+// WithAuthProvider authenticates requests to the /api/v1 endpoints with the given Provider.
+// The endpoints called by Harbor require the scan role, while the /api/v1/admin endpoints require the
+// admin role. Health probes and metrics are never authenticated.
+func WithAuthProvider(provider auth.Provider) Option {
+	return func(h *requestHandler) {
+		h.auth = provider
+	}
+}
+
+// WithVulnerabilityIndex exposes the artifacts affected by a vulnerability at
+// /api/v1/vulnerabilities/{vulnerability_id}/artifacts.
+func WithVulnerabilityIndex(index persistence.VulnerabilityIndex) Option {
+	return func(h *requestHandler) {
+		h.index = index
+	}
+}
+
+// WithImpactAssessor exposes impact assessments at /api/v1/admin/impact-assessments.
+func WithImpactAssessor(assessor impact.Assessor) Option {
+	return func(h *requestHandler) {
+		h.assessor = assessor
+	}
+}
+
+// WithPolicyEngine exposes the verdict of the given Engine on scan reports at
+// /api/v1/scan/{scan_request_id}/verdict.
+func WithPolicyEngine(engine policy.Engine) Option {
+	return func(h *requestHandler) {
+		h.policy = engine
+	}
+}
+
+// WithSupportBundleGenerator exposes the support bundles generated by the given Generator at
+// /api/v1/admin/support-bundle.
+func WithSupportBundleGenerator(generator support.Generator) Option {
+	return func(h *requestHandler) {
+		h.support = generator
+	}
+}
+
+// WithSignatureVerifier rejects scan requests unless their X-Scanner-Request-Signature header is accepted by
+// the given SignatureVerifier.
+func WithSignatureVerifier(verifier auth.SignatureVerifier) Option {
+	return func(h *requestHandler) {
+		h.signatures = verifier
+	}
+}
+
+// WithSLOTracker exposes the status of the SLO tracked by the given Tracker at /api/v1/slo.
+func WithSLOTracker(tracker slo.Tracker) Option {
+	return func(h *requestHandler) {
+		h.slo = tracker
+	}
+}
+
+// WithTunnelPin reports the adapter as not ready while the Tunnel binary does not match the given Pin.
+func WithTunnelPin(pin tunnel.Pin) Option {
+	return func(h *requestHandler) {
+		h.pin = pin
+	}
+}
+
+// WithVersionCache checks the version of the vulnerability database with the given VersionCache, e.g. the one
+// shared with the vulnerability database metrics.
+func WithVersionCache(cache *tunnel.VersionCache) Option {
+	return func(h *requestHandler) {
+		h.versions = cache
+	}
+}
+
+// WithClock times the expiry and approval of policy exceptions, the caches and the readiness probes with the given
+// Clock rather than the system time.
+func WithClock(c clock.Clock) Option {
+	return func(h *requestHandler) {
+		h.clock = c
+	}
+}
+
+// WithRedisCheck reports the adapter as not ready while the given check of the connection to Redis fails.
+func WithRedisCheck(check func(ctx context.Context) error) Option {
+	return func(h *requestHandler) {
+		h.redisCheck = check
+	}
+}
+
+// WithConnectivityProber exposes the reachability of the outbound destinations probed by the given Prober at
+// /api/v1/admin/connectivity, and has it probe the registries of scan requests.
+func WithConnectivityProber(prober connectivity.Prober) Option {
+	return func(h *requestHandler) {
+		h.prober = prober
+	}
+}
+
+// WithJobHistory exposes the scan jobs completed recently, as kept by the given History, at
+// /api/v1/admin/jobs/recent.
+func WithJobHistory(history history.History) Option {
+	return func(h *requestHandler) {
+		h.history = history
+	}
+}
+
+// WithLifetimeStore exposes the stats of the lifetimes of vulnerabilities, as kept by the given LifetimeStore, at
+// /api/v1/admin/stats/vulnerabilities.
+func WithLifetimeStore(store persistence.LifetimeStore) Option {
+	return func(h *requestHandler) {
+		h.lifetimes = store
+	}
+}
+
+// WithExceptionStore exposes the exceptions to the policy, as kept by the given ExceptionStore, at
+// /api/v1/policy/exceptions, and has verdicts honor the approved ones. Exceptions are approved at
+// /api/v1/admin/policy/exceptions/{exception_id}/approve. It has no effect without a policy Engine.
+func WithExceptionStore(store persistence.ExceptionStore) Option {
+	return func(h *requestHandler) {
+		h.exceptions = store
+	}
+}
+
+func NewAPIHandler(info etc.BuildInfo, config etc.Config, enqueuer queue.Enqueuer, store persistence.Store, wrapper tunnel.Wrapper, opts ...Option) http.Handler {
 	handler := &requestHandler{
 		info:     info,
 		config:   config,
 		enqueuer: enqueuer,
 		store:    store,
 		wrapper:  wrapper,
+		auth:     auth.NewNoneProvider(),
+		metadata: &metadataCache{ttl: config.API.MetadataCacheTTL},
+		versions: tunnel.NewVersionCache(tunnel.VersionCacheTTL),
+		clock:    clock.System,
+
+		reportMimeTypes: harbor.ServedReportMimeTypes(config.API.VulnerabilityReportVersions),
+	}
+	for _, opt := range opts {
+		opt(handler)
 	}
 
 	router := mux.NewRouter()
-	router.Use(handler.logRequest)
+	router.Use(handler.logRequest, api.AccessLog(config.AccessLog), api.LimitRequestBody(config.API.MaxRequestBodyBytes))
 
 	apiV1Router := router.PathPrefix("/api/v1").Subrouter()
+	// Probes and metrics scrapes are not traced.
+	apiV1Router.Use(tracing.Middleware)
+	if config.CORS.IsEnabled() {
+		cors := api.NewCORS(config.CORS)
+		// Preflight requests don't carry credentials, hence they're handled before authentication.
+		router.Methods(http.MethodOptions).PathPrefix("/api/v1").HandlerFunc(cors.Preflight)
+		apiV1Router.Use(cors.Middleware)
+	}
+	apiV1Router.Use(auth.Middleware(handler.auth), auth.RequireRole(auth.RoleScan))
 	apiV1Router.Methods(http.MethodPost).Path("/scan").HandlerFunc(handler.AcceptScanRequest)
 	apiV1Router.Methods(http.MethodGet).Path("/scan/{scan_request_id}/report").HandlerFunc(handler.GetScanReport)
 	apiV1Router.Methods(http.MethodGet).Path("/metadata").HandlerFunc(handler.GetMetadata)
+	if handler.policy != nil {
+		apiV1Router.Methods(http.MethodGet).Path("/scan/{scan_request_id}/verdict").HandlerFunc(handler.GetScanVerdict)
+	}
+	if handler.policy != nil && handler.exceptions != nil {
+		apiV1Router.Methods(http.MethodPost).Path("/policy/exceptions").HandlerFunc(handler.RequestPolicyException)
+		apiV1Router.Methods(http.MethodGet).Path("/policy/exceptions").HandlerFunc(handler.ListPolicyExceptions)
+	}
+	if handler.slo != nil {
+		apiV1Router.Methods(http.MethodGet).Path("/slo").HandlerFunc(handler.GetSLOStatus)
+	}
+	if handler.index != nil {
+		apiV1Router.Methods(http.MethodGet).Path("/vulnerabilities/{vulnerability_id}/artifacts").HandlerFunc(handler.GetAffectedArtifacts)
+	}
+
+	// Admin endpoints are nested in the v1 API, and additionally require the admin role.
+	adminRouter := apiV1Router.PathPrefix("/admin").Subrouter()
+	adminRouter.Use(auth.RequireRole(auth.RoleAdmin))
+	if handler.assessor != nil {
+		adminRouter.Methods(http.MethodPost).Path("/impact-assessments").HandlerFunc(handler.StartImpactAssessment)
+		adminRouter.Methods(http.MethodGet).Path("/impact-assessments/{assessment_id}").HandlerFunc(handler.GetImpactAssessment)
+	}
+	if handler.support != nil {
+		adminRouter.Methods(http.MethodGet).Path("/support-bundle").HandlerFunc(handler.GetSupportBundle)
+	}
+	adminRouter.Methods(http.MethodGet).Path("/jobs").HandlerFunc(handler.ListScanJobs)
+	if handler.history != nil {
+		adminRouter.Methods(http.MethodGet).Path("/jobs/recent").HandlerFunc(handler.ListRecentScanJobs)
+	}
+	if handler.prober != nil {
+		adminRouter.Methods(http.MethodGet).Path("/connectivity").HandlerFunc(handler.GetConnectivity)
+	}
+	if handler.lifetimes != nil {
+		adminRouter.Methods(http.MethodGet).Path("/stats/vulnerabilities").HandlerFunc(handler.GetVulnerabilityStats)
+	}
+	if handler.policy != nil && handler.exceptions != nil {
+		adminRouter.Methods(http.MethodPost).Path("/policy/exceptions/{exception_id}/approve").HandlerFunc(handler.ApprovePolicyException)
+	}
+
+	// Harbor webhooks authenticate with their shared secret rather than with the credentials of the API.
+	if config.HarborWebhook.IsEnabled() {
+		router.Methods(http.MethodPost).Path("/webhooks/harbor").Handler(tracing.Middleware(
+			http.HandlerFunc(handler.AcceptHarborWebhook)))
+	}
 
 	probeRouter := router.PathPrefix("/probe").Subrouter()
 	probeRouter.Methods(http.MethodGet).Path("/healthy").HandlerFunc(handler.GetHealthy)
@@ -75,10 +332,46 @@ func (h *requestHandler) logRequest(next http.Handler) http.Handler {
 	})
 }
 
+// defaultRetryAfter is the delay after which Harbor is asked to retry rejected scan requests, unless the average
+// scan duration is known.
+const defaultRetryAfter = time.Minute
+
+// retryAfterSeconds returns the delay in seconds after which a scan request rejected because the backlog is full
+// may be retried, i.e. the average scan duration, by which time a worker likely picked up a scan job.
+func (h *requestHandler) retryAfterSeconds(ctx context.Context) int {
+	stats, err := h.enqueuer.Stats(ctx)
+	if err != nil || stats.AverageScanDuration <= 0 {
+		return int(defaultRetryAfter.Seconds())
+	}
+	return max(1, int(math.Ceil(stats.AverageScanDuration.Seconds())))
+}
+
 func (h *requestHandler) AcceptScanRequest(res http.ResponseWriter, req *http.Request) {
+	if h.config.API.MaintenanceMode {
+		slog.Warn("Rejecting scan request in maintenance mode")
+		h.WriteJSONError(res, harbor.Error{
+			HTTPCode: http.StatusServiceUnavailable,
+			Message:  h.config.API.MaintenanceMessage,
+		})
+		return
+	}
+
+	// The body is kept to verify its signature.
+	body, err := io.ReadAll(req.Body)
 	scanRequest := harbor.ScanRequest{}
-	if err := json.NewDecoder(req.Body).Decode(&scanRequest); err != nil {
+	if err == nil {
+		err = json.NewDecoder(bytes.NewReader(body)).Decode(&scanRequest)
+	}
+	if err != nil {
 		slog.Error("Error while unmarshalling scan request", slog.String("err", err.Error()))
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			h.WriteJSONError(res, harbor.Error{
+				HTTPCode: http.StatusRequestEntityTooLarge,
+				Message:  fmt.Sprintf("scan request exceeds %d bytes", maxBytesErr.Limit),
+			})
+			return
+		}
 		h.WriteJSONError(res, harbor.Error{
 			HTTPCode: http.StatusBadRequest,
 			Message:  fmt.Sprintf("unmarshalling scan request: %s", err.Error()),
@@ -92,22 +385,89 @@ func (h *requestHandler) AcceptScanRequest(res http.ResponseWriter, req *http.Re
 		return
 	}
 
-	scanJob, err := h.enqueuer.Enqueue(req.Context(), scanRequest)
+	if h.signatures != nil {
+		if err := h.signatures.Verify(req.Header.Get(auth.HeaderSignature), body, scanRequest); err != nil {
+			slog.Warn("Rejecting scan request with invalid signature", slog.String("repository", scanRequest.Artifact.Repository),
+				slog.String("digest", scanRequest.Artifact.Digest), slog.String("err", err.Error()))
+			h.WriteJSONError(res, harbor.Error{
+				HTTPCode: http.StatusUnauthorized,
+				Message:  "invalid scan request signature",
+			})
+			return
+		}
+	}
+
+	if h.prober != nil {
+		h.prober.ObserveRegistry(scanRequest.Registry.URL)
+	}
+
+	// The timeout requested by Harbor takes precedence over the one of the policy. Both are capped by the
+	// wrapper.
+	if scanRequest.TimeoutSeconds == 0 && h.policy != nil {
+		scanRequest.TimeoutSeconds = int64(h.policy.ScanTimeout(scanRequest.Artifact.Repository).Seconds())
+	}
+
+	ctx := req.Context()
+	if force, _ := strconv.ParseBool(req.URL.Query().Get(queryForce)); force {
+		ctx = queue.WithForceScan(ctx)
+	}
+
+	scanJob, err := h.enqueuer.Enqueue(ctx, scanRequest)
 	if err != nil {
-		slog.Error("Error while enqueuing scan job", slog.String("err", err.Error()))
-		h.WriteJSONError(res, harbor.Error{
-			HTTPCode: http.StatusInternalServerError,
-			Message:  fmt.Sprintf("enqueuing scan job: %s", err.Error()),
-		})
+		h.writeEnqueueError(res, req, err)
 		return
 	}
 
 	scanResponse := harbor.ScanResponse{ID: scanJob.ID}
 
+	position, err := h.enqueuer.Position(req.Context(), scanJob.ID)
+	if err != nil {
+		slog.Warn("Error while estimating scan job queue position", slog.String("scan_job_id", scanJob.ID),
+			slog.String("err", err.Error()))
+	}
+	if err == nil && position.Position > 0 {
+		scanResponse.QueuePosition = lo.ToPtr(position.Position)
+	}
+	if err == nil && position.Position > 0 && position.Estimated {
+		scanResponse.EstimatedWaitSeconds = lo.ToPtr(int64(position.EstimatedWait.Seconds()))
+	}
+
 	h.WriteJSON(res, scanResponse, api.MimeTypeScanResponse, http.StatusAccepted)
 }
 
+// writeEnqueueError writes the response to a request whose scan job could not be enqueued with the given error.
+func (h *requestHandler) writeEnqueueError(res http.ResponseWriter, req *http.Request, err error) {
+	if errors.Is(err, persistence.ErrScanJobExists) {
+		slog.Warn("Scan job conflicts with an existing scan job", slog.String("err", err.Error()))
+		h.WriteJSONError(res, harbor.Error{
+			HTTPCode: http.StatusConflict,
+			Message:  fmt.Sprintf("enqueuing scan job: %s", err.Error()),
+		})
+		return
+	}
+	if errors.Is(err, queue.ErrBacklogFull) {
+		slog.Warn("Rejecting scan request while the backlog is full", slog.String("err", err.Error()))
+		res.Header().Set("Retry-After", strconv.Itoa(h.retryAfterSeconds(req.Context())))
+		h.WriteJSONError(res, harbor.Error{
+			HTTPCode: http.StatusTooManyRequests,
+			Message:  fmt.Sprintf("enqueuing scan job: %s", err.Error()),
+		})
+		return
+	}
+	slog.Error("Error while enqueuing scan job", slog.String("err", err.Error()))
+	h.WriteJSONError(res, harbor.Error{
+		HTTPCode: http.StatusInternalServerError,
+		Message:  fmt.Sprintf("enqueuing scan job: %s", err.Error()),
+	})
+}
+
 func (h *requestHandler) ValidateScanRequest(req harbor.ScanRequest) *harbor.Error {
+	return ValidateScanRequest(req)
+}
+
+// ValidateScanRequest returns the error describing the first invalid field of the given scan request, if any. It is
+// shared by the other APIs accepting scan requests, so that they accept the same ones.
+func ValidateScanRequest(req harbor.ScanRequest) *harbor.Error {
 	if req.Registry.URL == "" {
 		return &harbor.Error{
 			HTTPCode: http.StatusUnprocessableEntity,
@@ -136,13 +496,35 @@ func (h *requestHandler) ValidateScanRequest(req harbor.ScanRequest) *harbor.Err
 		}
 	}
 
+	for _, capability := range req.EnabledCapabilities {
+		if capability.Type != harbor.CapabilityTypeVulnerability && capability.Type != harbor.CapabilityTypeSBOM {
+			return &harbor.Error{
+				HTTPCode: http.StatusUnprocessableEntity,
+				Message:  fmt.Sprintf("unsupported capability %s", capability.Type),
+			}
+		}
+	}
+
+	if req.TimeoutSeconds < 0 {
+		return &harbor.Error{
+			HTTPCode: http.StatusUnprocessableEntity,
+			Message:  "timeout_seconds must not be negative",
+		}
+	}
+
+	if mediaTypes := req.SBOMMediaTypes(); len(mediaTypes) > 0 && !slices.ContainsFunc(mediaTypes, isSupportedSBOMMediaType) {
+		return &harbor.Error{
+			HTTPCode: http.StatusUnprocessableEntity,
+			Message:  fmt.Sprintf("unsupported SBOM media types %s", strings.Join(mediaTypes, ", ")),
+		}
+	}
+
 	return nil
 }
 
 func (h *requestHandler) GetScanReport(res http.ResponseWriter, req *http.Request) {
-	var reportMimeType api.MimeType
-
-	if err := reportMimeType.FromAcceptHeader(req.Header.Get(api.HeaderAccept)); err != nil {
+	reportMimeType, err := harbor.NegotiateReportMimeType(req.Header.Get(api.HeaderAccept), h.reportMimeTypes)
+	if err != nil {
 		h.WriteJSONError(res, harbor.Error{
 			HTTPCode: http.StatusUnsupportedMediaType,
 			Message:  fmt.Sprintf("unsupported media type %s", req.Header.Get(api.HeaderAccept)),
@@ -150,6 +532,83 @@ func (h *requestHandler) GetScanReport(res http.ResponseWriter, req *http.Reques
 		return
 	}
 
+	scanJob, ok := h.getFinishedScanJob(res, req)
+	if !ok {
+		return
+	}
+
+	if reportMimeType.Capability == harbor.CapabilityTypeSBOM {
+		h.writeSBOMReport(res, req, scanJob, api.ReportMimeType(reportMimeType))
+		return
+	}
+
+	include, exclude := h.config.API.IncludeEcosystems, h.config.API.ExcludeEcosystems
+	query := req.URL.Query()
+	if query.Has(queryIncludeEcosystems) {
+		include = splitList(query.Get(queryIncludeEcosystems))
+	}
+	if query.Has(queryExcludeEcosystems) {
+		exclude = splitList(query.Get(queryExcludeEcosystems))
+	}
+
+	report := harbor.FilterEcosystems(scanJob.Report, include, exclude)
+	setSummaryHeaders(res.Header(), report)
+	if h.config.API.HarborLegacyMode || reportMimeType == harbor.ReportMimeTypeVulnerabilityV1_0 {
+		report = harbor.ToLegacyReport(report)
+	}
+
+	h.WriteJSON(res, report, api.ReportMimeType(reportMimeType), http.StatusOK)
+}
+
+// writeSBOMReport responds with the SBOM report of the given scan job, which was requested for the sbom capability.
+func (h *requestHandler) writeSBOMReport(res http.ResponseWriter, req *http.Request, scanJob *job.ScanJob, mimeType api.MimeType) {
+	mediaType := req.URL.Query().Get(querySBOMMediaType)
+	if mediaType != "" && !isSupportedSBOMMediaType(mediaType) {
+		h.WriteJSONError(res, harbor.Error{
+			HTTPCode: http.StatusBadRequest,
+			Message:  fmt.Sprintf("unsupported SBOM media type %s", mediaType),
+		})
+		return
+	}
+
+	if scanJob.SBOM == nil {
+		h.WriteJSONError(res, harbor.Error{
+			HTTPCode: http.StatusNotFound,
+			Message:  fmt.Sprintf("scan job %s was not requested for an SBOM", scanJob.ID),
+		})
+		return
+	}
+
+	if mediaType != "" && mediaType != scanJob.SBOM.MediaType {
+		h.WriteJSONError(res, harbor.Error{
+			HTTPCode: http.StatusNotFound,
+			Message:  fmt.Sprintf("scan job %s has no SBOM of media type %s", scanJob.ID, mediaType),
+		})
+		return
+	}
+
+	h.WriteJSON(res, scanJob.SBOM, mimeType, http.StatusOK)
+}
+
+// isSupportedSBOMMediaType returns true if the sbom capability generates SBOMs of the given media type.
+func isSupportedSBOMMediaType(mediaType string) bool {
+	return slices.Contains(harbor.SupportedSBOMMediaTypes, mediaType)
+}
+
+// splitList splits the given comma-separated list, ignoring blank items.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// getFinishedScanJob returns the finished scan job requested by the given request. Otherwise, it responds
+// with the status of the scan job and returns false.
+func (h *requestHandler) getFinishedScanJob(res http.ResponseWriter, req *http.Request) (*job.ScanJob, bool) {
 	vars := mux.Vars(req)
 	scanJobID, ok := vars[pathVarScanRequestID]
 	if !ok {
@@ -158,7 +617,7 @@ func (h *requestHandler) GetScanReport(res http.ResponseWriter, req *http.Reques
 			HTTPCode: http.StatusBadRequest,
 			Message:  "missing scan_request_id",
 		})
-		return
+		return nil, false
 	}
 
 	reqLog := slog.With(slog.String("scan_job_id", scanJobID))
@@ -170,7 +629,7 @@ func (h *requestHandler) GetScanReport(res http.ResponseWriter, req *http.Reques
 			HTTPCode: http.StatusInternalServerError,
 			Message:  fmt.Sprintf("getting scan job: %v", err),
 		})
-		return
+		return nil, false
 	}
 
 	if scanJob == nil {
@@ -179,16 +638,17 @@ func (h *requestHandler) GetScanReport(res http.ResponseWriter, req *http.Reques
 			HTTPCode: http.StatusNotFound,
 			Message:  fmt.Sprintf("cannot find scan job: %v", scanJobID),
 		})
-		return
+		return nil, false
 	}
 
 	scanJobLog := reqLog.With(slog.String("scan_job_status", scanJob.Status.String()))
+	res.Header().Set(headerScanJobStatus, scanJob.Status.String())
 
 	if scanJob.Status == job.Queued || scanJob.Status == job.Pending {
 		scanJobLog.Debug("Scan job has not finished yet")
 		res.Header().Add("Location", req.URL.String())
 		res.WriteHeader(http.StatusFound)
-		return
+		return nil, false
 	}
 
 	if scanJob.Status == job.Failed {
@@ -197,7 +657,7 @@ func (h *requestHandler) GetScanReport(res http.ResponseWriter, req *http.Reques
 			HTTPCode: http.StatusInternalServerError,
 			Message:  scanJob.Error,
 		})
-		return
+		return nil, false
 	}
 
 	if scanJob.Status != job.Finished {
@@ -206,13 +666,437 @@ func (h *requestHandler) GetScanReport(res http.ResponseWriter, req *http.Reques
 			HTTPCode: http.StatusInternalServerError,
 			Message:  fmt.Sprintf("unexpected status %v of scan job %v", scanJob.Status, scanJob.ID),
 		})
+		return nil, false
+	}
+
+	setSummaryHeaders(res.Header(), scanJob.Report)
+	return scanJob, true
+}
+
+// setSummaryHeaders sets the overall severity of the given report, and the number of its vulnerabilities of
+// each severity, e.g. X-Scanner-Critical-Count.
+func setSummaryHeaders(header http.Header, report harbor.ScanReport) {
+	counts := make(map[harbor.Severity]int)
+	for _, v := range report.Vulnerabilities {
+		counts[v.Severity]++
+	}
+	for severity := harbor.SevUnknown; severity <= harbor.SevCritical; severity++ {
+		header.Set(fmt.Sprintf("X-Scanner-%s-Count", severity), strconv.Itoa(counts[severity]))
+	}
+	header.Set(headerSeverity, report.Severity.String())
+}
+
+func (h *requestHandler) GetScanVerdict(res http.ResponseWriter, req *http.Request) {
+	scanJob, ok := h.getFinishedScanJob(res, req)
+	if !ok {
 		return
 	}
 
-	h.WriteJSON(res, scanJob.Report, reportMimeType, http.StatusOK)
+	verdict := h.policy.Evaluate(scanJob.Report)
+	if h.exceptions != nil {
+		exceptions, err := h.exceptions.List(req.Context())
+		if err != nil {
+			slog.Error("Error while listing policy exceptions", slog.String("err", err.Error()))
+			h.WriteJSONError(res, harbor.Error{
+				HTTPCode: http.StatusInternalServerError,
+				Message:  fmt.Sprintf("listing policy exceptions: %v", err),
+			})
+			return
+		}
+		verdict = policy.ApplyExceptions(verdict, scanJob.Report.Artifact, exceptions, h.clock.Now())
+	}
+
+	h.WriteJSON(res, verdict, api.MimeTypeJSON, http.StatusOK)
 }
 
-func (h *requestHandler) GetMetadata(res http.ResponseWriter, _ *http.Request) {
+func (h *requestHandler) GetSLOStatus(res http.ResponseWriter, req *http.Request) {
+	status, err := h.slo.Status(req.Context())
+	if err != nil {
+		slog.Error("Error while computing SLO status", slog.String("err", err.Error()))
+		h.WriteJSONError(res, harbor.Error{
+			HTTPCode: http.StatusInternalServerError,
+			Message:  fmt.Sprintf("computing SLO status: %v", err),
+		})
+		return
+	}
+
+	h.WriteJSON(res, status, api.MimeTypeJSON, http.StatusOK)
+}
+
+// affectedArtifacts is the response of the GetAffectedArtifacts endpoint.
+type affectedArtifacts struct {
+	VulnerabilityID string                         `json:"vulnerability_id"`
+	Artifacts       []persistence.AffectedArtifact `json:"artifacts"`
+}
+
+func (h *requestHandler) GetAffectedArtifacts(res http.ResponseWriter, req *http.Request) {
+	vulnerabilityID := mux.Vars(req)[pathVarVulnerabilityID]
+	reqLog := slog.With(slog.String("vulnerability_id", vulnerabilityID))
+
+	artifacts, err := h.index.FindArtifacts(req.Context(), vulnerabilityID)
+	if err != nil {
+		reqLog.Error("Error while finding affected artifacts", slog.String("err", err.Error()))
+		h.WriteJSONError(res, harbor.Error{
+			HTTPCode: http.StatusInternalServerError,
+			Message:  fmt.Sprintf("finding affected artifacts: %v", err),
+		})
+		return
+	}
+
+	h.WriteJSON(res, affectedArtifacts{
+		VulnerabilityID: vulnerabilityID,
+		Artifacts:       artifacts,
+	}, api.MimeTypeJSON, http.StatusOK)
+}
+
+func (h *requestHandler) StartImpactAssessment(res http.ResponseWriter, _ *http.Request) {
+	report, err := h.assessor.Start(impact.TriggerAdmin)
+	if errors.Is(err, impact.ErrAssessmentRunning) {
+		h.WriteJSONError(res, harbor.Error{
+			HTTPCode: http.StatusConflict,
+			Message:  err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		slog.Error("Error while starting impact assessment", slog.String("err", err.Error()))
+		h.WriteJSONError(res, harbor.Error{
+			HTTPCode: http.StatusInternalServerError,
+			Message:  fmt.Sprintf("starting impact assessment: %v", err),
+		})
+		return
+	}
+
+	h.WriteJSON(res, report, api.MimeTypeJSON, http.StatusAccepted)
+}
+
+func (h *requestHandler) GetImpactAssessment(res http.ResponseWriter, req *http.Request) {
+	assessmentID := mux.Vars(req)[pathVarAssessmentID]
+
+	report, ok := h.assessor.Get(assessmentID)
+	if !ok {
+		h.WriteJSONError(res, harbor.Error{
+			HTTPCode: http.StatusNotFound,
+			Message:  fmt.Sprintf("cannot find impact assessment: %v", assessmentID),
+		})
+		return
+	}
+
+	h.WriteJSON(res, report, api.MimeTypeJSON, http.StatusOK)
+}
+
+// scanJobList is the response of the ListScanJobs endpoint.
+type scanJobList struct {
+	Jobs []scanJobEntry `json:"jobs"`
+	// Next is the value of the after parameter listing the next page, blank on the last page.
+	Next string `json:"next,omitempty"`
+}
+
+// scanJobEntry names the status of a listed scan job.
+type scanJobEntry struct {
+	job.Entry
+	Status string `json:"status"`
+}
+
+func (h *requestHandler) ListScanJobs(res http.ResponseWriter, req *http.Request) {
+	options, err := parseListOptions(req.URL.Query())
+	if err != nil {
+		h.WriteJSONError(res, harbor.Error{
+			HTTPCode: http.StatusBadRequest,
+			Message:  err.Error(),
+		})
+		return
+	}
+
+	entries, err := h.store.List(req.Context(), options)
+	if err != nil {
+		slog.Error("Error while listing scan jobs", slog.String("err", err.Error()))
+		h.WriteJSONError(res, harbor.Error{
+			HTTPCode: http.StatusInternalServerError,
+			Message:  fmt.Sprintf("listing scan jobs: %v", err),
+		})
+		return
+	}
+
+	list := scanJobList{Jobs: make([]scanJobEntry, len(entries))}
+	for i, entry := range entries {
+		list.Jobs[i] = scanJobEntry{Entry: entry, Status: entry.Status.String()}
+	}
+	// A full page may be followed by another one.
+	if len(entries) == options.Limit {
+		list.Next = entries[len(entries)-1].ID
+	}
+	h.WriteJSON(res, list, api.MimeTypeJSON, http.StatusOK)
+}
+
+// ListRecentScanJobs responds with the scan jobs completed recently by this replica, most recent first, including
+// the ones expired from the store.
+func (h *requestHandler) ListRecentScanJobs(res http.ResponseWriter, _ *http.Request) {
+	entries := h.history.Recent()
+	list := scanJobList{Jobs: make([]scanJobEntry, len(entries))}
+	for i, entry := range entries {
+		list.Jobs[i] = scanJobEntry{Entry: entry, Status: entry.Status.String()}
+	}
+	h.WriteJSON(res, list, api.MimeTypeJSON, http.StatusOK)
+}
+
+func parseListOptions(query url.Values) (persistence.ListOptions, error) {
+	options := persistence.ListOptions{After: query.Get(queryAfter), Limit: defaultScanJobsLimit}
+	for _, value := range query[queryStatus] {
+		for _, name := range strings.Split(value, ",") {
+			status, err := job.ParseScanJobStatus(strings.TrimSpace(name))
+			if err != nil {
+				return persistence.ListOptions{}, err
+			}
+			options.Statuses = append(options.Statuses, status)
+		}
+	}
+	if value := query.Get(queryLimit); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxScanJobsLimit {
+			return persistence.ListOptions{}, fmt.Errorf("limit must be between 1 and %d: %s", maxScanJobsLimit, value)
+		}
+		options.Limit = limit
+	}
+	return options, nil
+}
+
+// connectivityStatus is the response of the GetConnectivity endpoint.
+type connectivityStatus struct {
+	Destinations []connectivity.Status `json:"destinations"`
+}
+
+func (h *requestHandler) GetConnectivity(res http.ResponseWriter, _ *http.Request) {
+	h.WriteJSON(res, connectivityStatus{
+		Destinations: h.prober.Statuses(),
+	}, api.MimeTypeJSON, http.StatusOK)
+}
+
+func (h *requestHandler) GetVulnerabilityStats(res http.ResponseWriter, req *http.Request) {
+	summary, err := h.lifetimes.Summarize(req.Context())
+	if err != nil {
+		slog.Error("Error while summarizing vulnerability lifetimes", slog.String("err", err.Error()))
+		h.WriteJSONError(res, harbor.Error{
+			HTTPCode: http.StatusInternalServerError,
+			Message:  fmt.Sprintf("summarizing vulnerability lifetimes: %v", err),
+		})
+		return
+	}
+
+	h.WriteJSON(res, summary, api.MimeTypeJSON, http.StatusOK)
+}
+
+// exceptionRequest is the body of the RequestPolicyException endpoint.
+type exceptionRequest struct {
+	Repository      string    `json:"repository"`
+	Digest          string    `json:"digest"`
+	VulnerabilityID string    `json:"vulnerability_id"`
+	Justification   string    `json:"justification"`
+	ExpiresAt       time.Time `json:"expires_at"`
+}
+
+// exceptionList is the response of the ListPolicyExceptions endpoint.
+type exceptionList struct {
+	Exceptions []policy.Exception `json:"exceptions"`
+}
+
+func (h *requestHandler) RequestPolicyException(res http.ResponseWriter, req *http.Request) {
+	var request exceptionRequest
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		h.WriteJSONError(res, harbor.Error{
+			HTTPCode: http.StatusBadRequest,
+			Message:  fmt.Sprintf("unmarshalling policy exception request: %v", err),
+		})
+		return
+	}
+
+	now := h.clock.Now()
+	if message := h.validateExceptionRequest(request, now); message != "" {
+		h.WriteJSONError(res, harbor.Error{
+			HTTPCode: http.StatusUnprocessableEntity,
+			Message:  message,
+		})
+		return
+	}
+
+	id, err := newExceptionID()
+	if err != nil {
+		slog.Error("Error while generating policy exception ID", slog.String("err", err.Error()))
+		h.WriteJSONError(res, harbor.Error{
+			HTTPCode: http.StatusInternalServerError,
+			Message:  fmt.Sprintf("generating policy exception ID: %v", err),
+		})
+		return
+	}
+	principal, _ := auth.PrincipalFromContext(req.Context())
+	exception := policy.Exception{
+		ID:              id,
+		Repository:      request.Repository,
+		Digest:          request.Digest,
+		VulnerabilityID: request.VulnerabilityID,
+		Justification:   request.Justification,
+		ExpiresAt:       request.ExpiresAt.UTC(),
+		Status:          policy.ExceptionRequested,
+		RequestedBy:     principal.Subject,
+		RequestedAt:     now.UTC(),
+	}
+	if err = h.exceptions.Save(req.Context(), exception); err != nil {
+		slog.Error("Error while saving policy exception", slog.String("err", err.Error()))
+		h.WriteJSONError(res, harbor.Error{
+			HTTPCode: http.StatusInternalServerError,
+			Message:  fmt.Sprintf("saving policy exception: %v", err),
+		})
+		return
+	}
+
+	slog.Info("Policy exception requested",
+		slog.String("exception_id", exception.ID),
+		slog.String("repository", exception.Repository),
+		slog.String("vulnerability_id", exception.VulnerabilityID),
+		slog.String("requested_by", exception.RequestedBy),
+	)
+	h.WriteJSON(res, exception, api.MimeTypeJSON, http.StatusCreated)
+}
+
+// validateExceptionRequest returns the reason why the given exception request is invalid at the given time, or
+// a blank string if it is valid.
+func (h *requestHandler) validateExceptionRequest(request exceptionRequest, now time.Time) string {
+	switch {
+	case request.Repository == "":
+		return "missing repository"
+	case request.VulnerabilityID == "":
+		return "missing vulnerability_id"
+	case request.Justification == "":
+		return "missing justification"
+	case !request.ExpiresAt.After(now):
+		return "expires_at must be in the future"
+	}
+	if maxDuration := h.config.Policy.MaxExceptionDuration; maxDuration > 0 && request.ExpiresAt.Sub(now) > maxDuration {
+		return fmt.Sprintf("expires_at must be within %s", maxDuration)
+	}
+	return ""
+}
+
+func (h *requestHandler) ListPolicyExceptions(res http.ResponseWriter, req *http.Request) {
+	exceptions, err := h.exceptions.List(req.Context())
+	if err != nil {
+		slog.Error("Error while listing policy exceptions", slog.String("err", err.Error()))
+		h.WriteJSONError(res, harbor.Error{
+			HTTPCode: http.StatusInternalServerError,
+			Message:  fmt.Sprintf("listing policy exceptions: %v", err),
+		})
+		return
+	}
+
+	if repository := req.URL.Query().Get("repository"); repository != "" {
+		exceptions = slices.DeleteFunc(exceptions, func(e policy.Exception) bool {
+			return e.Repository != repository
+		})
+	}
+	h.WriteJSON(res, exceptionList{Exceptions: exceptions}, api.MimeTypeJSON, http.StatusOK)
+}
+
+// ApprovePolicyException approves a requested exception, which verdicts honor from then on until it expires.
+func (h *requestHandler) ApprovePolicyException(res http.ResponseWriter, req *http.Request) {
+	exceptionID := mux.Vars(req)[pathVarExceptionID]
+
+	exception, err := h.exceptions.Get(req.Context(), exceptionID)
+	if err != nil {
+		slog.Error("Error while getting policy exception", slog.String("err", err.Error()))
+		h.WriteJSONError(res, harbor.Error{
+			HTTPCode: http.StatusInternalServerError,
+			Message:  fmt.Sprintf("getting policy exception: %v", err),
+		})
+		return
+	}
+	if exception == nil {
+		h.WriteJSONError(res, harbor.Error{
+			HTTPCode: http.StatusNotFound,
+			Message:  fmt.Sprintf("cannot find policy exception: %v", exceptionID),
+		})
+		return
+	}
+	if exception.Status == policy.ExceptionApproved {
+		h.WriteJSONError(res, harbor.Error{
+			HTTPCode: http.StatusConflict,
+			Message:  fmt.Sprintf("policy exception already approved: %v", exceptionID),
+		})
+		return
+	}
+
+	principal, _ := auth.PrincipalFromContext(req.Context())
+	approvedAt := h.clock.Now().UTC()
+	exception.Status = policy.ExceptionApproved
+	exception.ApprovedBy = principal.Subject
+	exception.ApprovedAt = &approvedAt
+	if err = h.exceptions.Save(req.Context(), *exception); err != nil {
+		slog.Error("Error while saving policy exception", slog.String("err", err.Error()))
+		h.WriteJSONError(res, harbor.Error{
+			HTTPCode: http.StatusInternalServerError,
+			Message:  fmt.Sprintf("saving policy exception: %v", err),
+		})
+		return
+	}
+
+	slog.Info("Policy exception approved",
+		slog.String("exception_id", exception.ID),
+		slog.String("approved_by", exception.ApprovedBy),
+	)
+	h.WriteJSON(res, exception, api.MimeTypeJSON, http.StatusOK)
+}
+
+func newExceptionID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("reading random bytes: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// GetSupportBundle responds with a gzipped tarball of the support bundle. The bundle is generated in memory
+// before responding, so that a failure is reported with an error status rather than a truncated tarball.
+func (h *requestHandler) GetSupportBundle(res http.ResponseWriter, req *http.Request) {
+	var bundle bytes.Buffer
+	if err := h.support.Generate(req.Context(), &bundle); err != nil {
+		slog.Error("Error while generating support bundle", slog.String("err", err.Error()))
+		h.WriteJSONError(res, harbor.Error{
+			HTTPCode: http.StatusInternalServerError,
+			Message:  fmt.Sprintf("generating support bundle: %v", err),
+		})
+		return
+	}
+
+	filename := fmt.Sprintf("support-bundle-%s.tar.gz", h.clock.Now().UTC().Format("20060102T150405Z"))
+	res.Header().Set(api.HeaderContentType, "application/gzip")
+	res.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	res.WriteHeader(http.StatusOK)
+	_, _ = res.Write(bundle.Bytes())
+}
+
+// GetMetadata responds with the scanner adapter metadata, which is cached for the configured TTL. Responses
+// carry ETag and Last-Modified headers, so that clients polling the endpoint can send conditional requests.
+func (h *requestHandler) GetMetadata(res http.ResponseWriter, req *http.Request) {
+	entry, err := h.metadata.get(h.clock.Now(), h.buildMetadata)
+	if err != nil {
+		slog.Error("Error while encoding metadata", slog.String("err", err.Error()))
+		h.SendInternalServerError(res)
+		return
+	}
+
+	res.Header().Set("ETag", entry.etag)
+	res.Header().Set("Last-Modified", entry.lastModified.Format(http.TimeFormat))
+	if entry.notModified(req) {
+		res.WriteHeader(http.StatusNotModified)
+		return
+	}
+	res.Header().Set(api.HeaderContentType, api.MimeTypeMetadata.String())
+	res.WriteHeader(http.StatusOK)
+	_, _ = res.Write(entry.body)
+}
+
+// buildMetadata returns the scanner adapter metadata, which is incomplete if the version of the vulnerability
+// database could not be retrieved.
+func (h *requestHandler) buildMetadata() (interface{}, bool) {
 	properties := map[string]string{
 		propertyScannerType: "os-package-vulnerability",
 
@@ -232,6 +1116,16 @@ func (h *requestHandler) GetMetadata(res http.ResponseWriter, _ *http.Request) {
 		"env.SCANNER_TUNNEL_TIMEOUT":         h.config.Tunnel.Timeout.String(),
 	}
 
+	if h.config.Tunnel.SeverityThreshold != "" {
+		properties["env.SCANNER_TUNNEL_SEVERITY_THRESHOLD"] = h.config.Tunnel.SeverityThreshold
+	}
+
+	if h.config.Telemetry.Enabled {
+		properties["env.SCANNER_TELEMETRY_ENABLED"] = "true"
+		properties["env.SCANNER_TELEMETRY_ENDPOINT"] = h.config.Telemetry.Endpoint
+		properties["env.SCANNER_TELEMETRY_INTERVAL"] = h.config.Telemetry.Interval.String()
+	}
+
 	vi, err := h.wrapper.GetVersion()
 	if err != nil {
 		slog.Error("Error while retrieving vulnerability DB version", slog.String("err", err.Error()))
@@ -249,18 +1143,39 @@ func (h *requestHandler) GetMetadata(res http.ResponseWriter, _ *http.Request) {
 		Scanner: etc.GetScannerMetadata(),
 		Capabilities: []harbor.Capability{
 			{
+				Type: harbor.CapabilityTypeVulnerability,
+				ConsumesMIMETypes: []string{
+					api.MimeTypeOCIImageManifest.String(),
+					api.MimeTypeDockerImageManifestV2.String(),
+				},
+				ProducesMIMETypes: h.producedMIMETypes(harbor.CapabilityTypeVulnerability),
+			},
+			{
+				Type: harbor.CapabilityTypeSBOM,
 				ConsumesMIMETypes: []string{
 					api.MimeTypeOCIImageManifest.String(),
 					api.MimeTypeDockerImageManifestV2.String(),
 				},
-				ProducesMIMETypes: []string{
-					api.MimeTypeSecurityVulnerabilityReport.String(),
+				ProducesMIMETypes: h.producedMIMETypes(harbor.CapabilityTypeSBOM),
+				AdditionalAttributes: &harbor.CapabilityAttributes{
+					SBOMMediaTypes: harbor.SupportedSBOMMediaTypes,
 				},
 			},
 		},
 		Properties: properties,
 	}
-	h.WriteJSON(res, metadata, api.MimeTypeMetadata, http.StatusOK)
+	return metadata, err == nil
+}
+
+// producedMIMETypes returns the MIME types of the reports served for the given capability.
+func (h *requestHandler) producedMIMETypes(capability string) []string {
+	var mimeTypes []string
+	for _, mimeType := range h.reportMimeTypes {
+		if mimeType.Capability == capability {
+			mimeTypes = append(mimeTypes, mimeType.String())
+		}
+	}
+	return mimeTypes
 }
 
 func (h *requestHandler) GetHealthy(res http.ResponseWriter, req *http.Request) {
@@ -268,5 +1183,38 @@ func (h *requestHandler) GetHealthy(res http.ResponseWriter, req *http.Request)
 }
 
 func (h *requestHandler) GetReady(res http.ResponseWriter, req *http.Request) {
+	if h.pin != nil {
+		if err := h.pin.Err(); err != nil {
+			slog.Warn("Tunnel binary does not match the pinned version", slog.String("err", err.Error()))
+			res.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+	}
+	if h.redisCheck != nil {
+		if err := h.redisCheck(req.Context()); err != nil {
+			slog.Error("Error while checking connection to Redis", slog.String("err", err.Error()))
+			res.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+	}
+	if maxStaleness := h.config.Tunnel.VulnDBMaxStaleness; maxStaleness > 0 {
+		now := h.clock.Now()
+		vi, err := h.versions.Get(now, h.wrapper.GetVersion)
+		if err != nil {
+			slog.Error("Error while retrieving vulnerability DB version", slog.String("err", err.Error()))
+			res.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if vi.VulnerabilityDB == nil {
+			slog.Warn("Vulnerability DB has not been downloaded yet")
+			res.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if age := vi.VulnerabilityDB.Age(now); age > maxStaleness {
+			slog.Warn("Vulnerability DB is stale", slog.Duration("age", age), slog.Duration("max_staleness", maxStaleness))
+			res.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+	}
 	res.WriteHeader(http.StatusOK)
 }
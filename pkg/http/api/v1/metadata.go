@@ -0,0 +1,82 @@
+package v1
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metadataCache holds the encoded scanner adapter metadata, so that Harbor polling the metadata endpoint
+// does not exec Tunnel for each request.
+type metadataCache struct {
+	ttl time.Duration
+
+	mu sync.Mutex
+	// last is the most recently built metadata, which is served until expiresAt if it is complete.
+	last      metadataEntry
+	expiresAt time.Time
+}
+
+// metadataEntry is the encoded metadata, with its validators for conditional requests.
+type metadataEntry struct {
+	body         []byte
+	etag         string
+	lastModified time.Time
+}
+
+// get returns the cached metadata, or encodes the metadata built by the given function if the cache has
+// expired. The metadata is not cached if build reports that it is incomplete, e.g. because the version of
+// the vulnerability database could not be retrieved. Concurrent requests wait for the metadata being built.
+func (c *metadataCache) get(now time.Time, build func() (metadata interface{}, complete bool)) (metadataEntry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Before(c.expiresAt) {
+		return c.last, nil
+	}
+
+	metadata, complete := build()
+	body, err := json.Marshal(metadata)
+	if err != nil {
+		return metadataEntry{}, err
+	}
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	// Last-Modified only changes with the content, so that clients revalidating with If-Modified-Since
+	// still get 304 responses after the cache expired.
+	lastModified := c.last.lastModified
+	if etag != c.last.etag {
+		lastModified = now.UTC().Truncate(time.Second)
+	}
+	c.last = metadataEntry{body: body, etag: etag, lastModified: lastModified}
+
+	if complete {
+		c.expiresAt = now.Add(c.ttl)
+	}
+	return c.last, nil
+}
+
+// notModified returns true if the validators of the given conditional request match the given entry.
+// If-None-Match takes precedence over If-Modified-Since, as defined by RFC 9110.
+func (e metadataEntry) notModified(req *http.Request) bool {
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		for _, etag := range strings.Split(inm, ",") {
+			etag = strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+			if etag == e.etag || etag == "*" {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := req.Header.Get("If-Modified-Since"); ims != "" {
+		if t, err := http.ParseTime(ims); err == nil && !e.lastModified.After(t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package v1
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/http/api"
+)
+
+// webhookResponse lists the IDs of the scan jobs enqueued for the artifacts of a Harbor webhook event.
+type webhookResponse struct {
+	ScanRequestIDs []string `json:"scan_request_ids"`
+}
+
+// AcceptHarborWebhook enqueues scans of the artifacts pushed according to a Harbor webhook event, so that their
+// reports are ready, or reused, by the time Harbor requests their scan, and so that artifacts are scanned on push
+// without the scanner framework of Harbor. Events other than artifact pushes are ignored.
+func (h *requestHandler) AcceptHarborWebhook(res http.ResponseWriter, req *http.Request) {
+	secret := []byte(h.config.HarborWebhook.Secret)
+	if subtle.ConstantTimeCompare([]byte(req.Header.Get("Authorization")), secret) != 1 {
+		slog.Warn("Rejecting Harbor webhook event with invalid secret", slog.String("addr", req.RemoteAddr))
+		h.WriteJSONError(res, harbor.Error{
+			HTTPCode: http.StatusUnauthorized,
+			Message:  "invalid webhook secret",
+		})
+		return
+	}
+
+	if h.config.API.MaintenanceMode {
+		slog.Warn("Rejecting Harbor webhook event in maintenance mode")
+		h.WriteJSONError(res, harbor.Error{
+			HTTPCode: http.StatusServiceUnavailable,
+			Message:  h.config.API.MaintenanceMessage,
+		})
+		return
+	}
+
+	var event harbor.WebhookEvent
+	if err := json.NewDecoder(req.Body).Decode(&event); err != nil {
+		slog.Error("Error while unmarshalling Harbor webhook event", slog.String("err", err.Error()))
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			h.WriteJSONError(res, harbor.Error{
+				HTTPCode: http.StatusRequestEntityTooLarge,
+				Message:  fmt.Sprintf("webhook event exceeds %d bytes", maxBytesErr.Limit),
+			})
+			return
+		}
+		h.WriteJSONError(res, harbor.Error{
+			HTTPCode: http.StatusBadRequest,
+			Message:  fmt.Sprintf("unmarshalling webhook event: %s", err.Error()),
+		})
+		return
+	}
+	if event.Type != harbor.EventTypePushArtifact {
+		slog.Debug("Ignoring Harbor webhook event", slog.String("type", event.Type))
+		res.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	response := webhookResponse{ScanRequestIDs: make([]string, 0, len(event.EventData.Resources))}
+	for _, resource := range event.EventData.Resources {
+		scanRequest := h.toWebhookScanRequest(event.EventData.Repository, resource)
+		if validationError := h.ValidateScanRequest(scanRequest); validationError != nil {
+			slog.Error("Error while validating Harbor webhook event", slog.String("err", validationError.Message))
+			h.WriteJSONError(res, *validationError)
+			return
+		}
+		if h.prober != nil {
+			h.prober.ObserveRegistry(scanRequest.Registry.URL)
+		}
+		if h.policy != nil {
+			scanRequest.TimeoutSeconds = int64(h.policy.ScanTimeout(scanRequest.Artifact.Repository).Seconds())
+		}
+
+		// The scan jobs enqueued for the previous artifacts are kept if one fails, Harbor retrying failed events
+		// reuses or conflicts with them.
+		scanJob, err := h.enqueuer.Enqueue(req.Context(), scanRequest)
+		if err != nil {
+			h.writeEnqueueError(res, req, err)
+			return
+		}
+		slog.Info("Enqueued scan job for pushed artifact", slog.String("scan_job_id", scanJob.ID),
+			slog.String("repository", scanRequest.Artifact.Repository), slog.String("digest", scanRequest.Artifact.Digest))
+		response.ScanRequestIDs = append(response.ScanRequestIDs, scanJob.ID)
+	}
+
+	h.WriteJSON(res, response, api.MimeTypeJSON, http.StatusAccepted)
+}
+
+// toWebhookScanRequest returns the scan request of the given artifact pushed to the given repository, which is
+// pulled from the configured registry with the configured credentials. The credentials are never sent to the
+// registry named by the event, which anyone knowing the webhook secret controls.
+func (h *requestHandler) toWebhookScanRequest(repository harbor.WebhookRepository, resource harbor.WebhookResource) harbor.ScanRequest {
+	config := h.config.HarborWebhook
+	registryURL := config.RegistryURL
+	if registryURL == "" && resource.RegistryHost() != "" {
+		registryURL = "https://" + resource.RegistryHost()
+	}
+	var authorization string
+	if config.RegistryUsername != "" && config.RegistryURL != "" {
+		authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(config.RegistryUsername+":"+config.RegistryPassword))
+	}
+	return harbor.ScanRequest{
+		Registry: harbor.Registry{URL: registryURL, Authorization: authorization},
+		Artifact: harbor.Artifact{Repository: repository.RepoFullName, Digest: resource.Digest},
+	}
+}
@@ -0,0 +1,109 @@
+package httpx
+
+import (
+	"crypto/tls"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RequestDuration is the histogram of the durations of the requests sent by the clients returned by Client and
+// wrapped by Instrument, labelled by client name and status code, or `error` if no response was received.
+var RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "scanner_http_client_request_duration_seconds",
+	Help:    "Duration of outbound HTTP requests, until the response headers are received.",
+	Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+}, []string{"client", "code"})
+
+const (
+	// maxIdleConnsPerHost is raised from the default of 2, which closes the connections to a webhook or API as soon
+	// as more than two requests were sent to it concurrently, e.g. by several workers.
+	maxIdleConnsPerHost = 16
+	maxIdleConns        = 128
+	idleConnTimeout     = 90 * time.Second
+)
+
+var (
+	sharedTransport     *http.Transport
+	sharedTransportOnce sync.Once
+
+	clientsMu sync.Mutex
+	clients   = make(map[clientKey]*http.Client)
+)
+
+type clientKey struct {
+	name    string
+	timeout time.Duration
+}
+
+// Client returns the client with the given name and timeout, which is constructed on the first call and shared by
+// all callers afterwards. All clients send requests over the pooled connections of a single transport, and record
+// their durations in RequestDuration with the given name. The client is safe for concurrent use.
+func Client(name string, timeout time.Duration) *http.Client {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+
+	key := clientKey{name: name, timeout: timeout}
+	if client, ok := clients[key]; ok {
+		return client
+	}
+	client := &http.Client{
+		Transport: Instrument(RequestDuration, name, transport()),
+		Timeout:   timeout,
+	}
+	clients[key] = client
+	return client
+}
+
+// NewTransport constructs a transport with the given TLS configuration for the clients which cannot share the
+// transport of Client, e.g. the ones trusting additional CAs. It is tuned like the shared transport and resolves
+// hosts with the same DNS cache.
+func NewTransport(tlsConfig *tls.Config) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = defaultResolver.DialContext
+	t.MaxIdleConns = maxIdleConns
+	t.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	t.IdleConnTimeout = idleConnTimeout
+	t.TLSClientConfig = tlsConfig
+	return t
+}
+
+func transport() *http.Transport {
+	sharedTransportOnce.Do(func() {
+		sharedTransport = NewTransport(nil)
+	})
+	return sharedTransport
+}
+
+// Instrument returns a http.RoundTripper, which sends requests with the given one and records their durations in
+// the given histogram, labelled with the given client name and the status code of the response.
+func Instrument(duration *prometheus.HistogramVec, name string, next http.RoundTripper) http.RoundTripper {
+	return &instrumentedTransport{next: next, duration: duration, name: name}
+}
+
+type instrumentedTransport struct {
+	next     http.RoundTripper
+	duration *prometheus.HistogramVec
+	name     string
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	started := time.Now()
+	res, err := t.next.RoundTrip(req)
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(res.StatusCode)
+	}
+	t.duration.WithLabelValues(t.name, code).Observe(time.Since(started).Seconds())
+	return res, err
+}
+
+// CloseIdleConnections closes the idle connections of the wrapped http.RoundTripper, if it supports it.
+func (t *instrumentedTransport) CloseIdleConnections() {
+	if c, ok := t.next.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,95 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient(t *testing.T) {
+	t.Run("Should return same client for same name and timeout", func(t *testing.T) {
+		assert.Same(t, Client("test", time.Second), Client("test", time.Second))
+		assert.NotSame(t, Client("test", time.Second), Client("test", time.Minute))
+		assert.NotSame(t, Client("test", time.Second), Client("other", time.Second))
+	})
+
+	t.Run("Should share transport between clients", func(t *testing.T) {
+		a := Client("a", time.Second).Transport.(*instrumentedTransport)
+		b := Client("b", time.Second).Transport.(*instrumentedTransport)
+		assert.Same(t, a.next, b.next)
+	})
+}
+
+func TestInstrument(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+		res.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "test"}, []string{"client", "code"})
+	client := &http.Client{Transport: Instrument(duration, "webhook", http.DefaultTransport)}
+
+	res, err := client.Get(server.URL)
+	require.NoError(t, err)
+	_ = res.Body.Close()
+
+	_, err = client.Get("http://127.0.0.1:0")
+	require.Error(t, err)
+
+	assert.Equal(t, 2, testutil.CollectAndCount(duration))
+	assert.True(t, duration.DeleteLabelValues("webhook", "202"))
+	assert.True(t, duration.DeleteLabelValues("webhook", "error"))
+}
+
+func TestResolver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {}))
+	defer server.Close()
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	require.NoError(t, err)
+
+	t.Run("Should cache addresses for TTL", func(t *testing.T) {
+		var lookups int
+		r := newResolver(func(_ context.Context, host string) ([]string, error) {
+			lookups++
+			return []string{"127.0.0.1"}, nil
+		}, time.Minute)
+		now := time.Now()
+		r.now = func() time.Time { return now }
+
+		for i := 0; i < 3; i++ {
+			conn, err := r.DialContext(context.TODO(), "tcp", net.JoinHostPort("webhook.local", port))
+			require.NoError(t, err)
+			_ = conn.Close()
+		}
+		assert.Equal(t, 1, lookups)
+
+		now = now.Add(time.Minute)
+		conn, err := r.DialContext(context.TODO(), "tcp", net.JoinHostPort("webhook.local", port))
+		require.NoError(t, err)
+		_ = conn.Close()
+		assert.Equal(t, 2, lookups)
+	})
+
+	t.Run("Should not cache failed lookups", func(t *testing.T) {
+		var lookups int
+		r := newResolver(func(_ context.Context, host string) ([]string, error) {
+			lookups++
+			return nil, errors.New("no such host")
+		}, time.Minute)
+
+		for i := 0; i < 2; i++ {
+			_, err := r.DialContext(context.TODO(), "tcp", "webhook.local:443")
+			assert.EqualError(t, err, "no such host")
+		}
+		assert.Equal(t, 2, lookups)
+	})
+}
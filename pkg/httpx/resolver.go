@@ -0,0 +1,97 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// dnsCacheTTL is how long the addresses of a host are reused before it is resolved again. It is short enough for
+// webhooks and APIs moved behind a new address to be reached within a minute.
+const dnsCacheTTL = 30 * time.Second
+
+var defaultResolver = newResolver(net.DefaultResolver.LookupHost, dnsCacheTTL)
+
+type resolvedHost struct {
+	addrs   []string
+	expires time.Time
+}
+
+// resolver dials the addresses of hosts resolved at most once per TTL, so that each new connection to a webhook or
+// API does not wait for a DNS lookup. Failed lookups are not cached.
+type resolver struct {
+	lookup func(ctx context.Context, host string) ([]string, error)
+	ttl    time.Duration
+	now    func() time.Time
+	dialer *net.Dialer
+
+	mu    sync.Mutex
+	hosts map[string]resolvedHost
+}
+
+func newResolver(lookup func(ctx context.Context, host string) ([]string, error), ttl time.Duration) *resolver {
+	return &resolver{
+		lookup: lookup,
+		ttl:    ttl,
+		now:    time.Now,
+		dialer: &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+		hosts:  make(map[string]resolvedHost),
+	}
+}
+
+// DialContext connects to the given address, trying each address of its host in turn.
+func (r *resolver) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(host) != nil {
+		return r.dialer.DialContext(ctx, network, address)
+	}
+
+	addrs, err := r.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, addr := range addrs {
+		conn, err := r.dialer.DialContext(ctx, network, net.JoinHostPort(addr, port))
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+	}
+	// The host is resolved again by the next dial, in case its addresses changed.
+	r.forget(host)
+	return nil, errors.Join(errs...)
+}
+
+func (r *resolver) resolve(ctx context.Context, host string) ([]string, error) {
+	r.mu.Lock()
+	cached, ok := r.hosts[host]
+	r.mu.Unlock()
+	if ok && r.now().Before(cached.expires) {
+		return cached.addrs, nil
+	}
+
+	addrs, err := r.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	r.mu.Lock()
+	r.hosts[host] = resolvedHost{addrs: addrs, expires: r.now().Add(r.ttl)}
+	r.mu.Unlock()
+	return addrs, nil
+}
+
+func (r *resolver) forget(host string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.hosts, host)
+}
@@ -0,0 +1,262 @@
+// Package impact assesses the impact of newly published vulnerabilities on the artifacts scanned before.
+package impact
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+)
+
+const (
+	TriggerAdmin    = "admin"
+	TriggerDBUpdate = "db-update"
+)
+
+// maxReports is the number of assessment reports kept in memory.
+const maxReports = 10
+
+// ErrAssessmentRunning is returned when an assessment is started while another one is still running.
+var ErrAssessmentRunning = errors.New("impact assessment is already running")
+
+// Artifact identifies an artifact with a cached SBOM.
+type Artifact struct {
+	Registry   string `json:"registry"`
+	Repository string `json:"repository"`
+	Digest     string `json:"digest"`
+}
+
+// Source wraps the methods giving access to cached SBOMs.
+// Artifacts returns the artifacts with cached SBOMs. Rescan matches the vulnerability database against
+// the cached SBOM of the given artifact, without pulling the artifact's image.
+type Source interface {
+	Artifacts(ctx context.Context) ([]Artifact, error)
+	Rescan(ctx context.Context, artifact Artifact) (harbor.ScanReport, error)
+}
+
+type Status string
+
+const (
+	StatusRunning  Status = "Running"
+	StatusFinished Status = "Finished"
+	StatusFailed   Status = "Failed"
+)
+
+// Finding is a vulnerability of a package of an artifact.
+type Finding struct {
+	ID         string          `json:"id"`
+	Package    string          `json:"package"`
+	Version    string          `json:"version"`
+	FixVersion string          `json:"fix_version,omitempty"`
+	Severity   harbor.Severity `json:"severity"`
+}
+
+// Delta lists the vulnerabilities of an artifact, which were added or removed since the artifact was indexed.
+type Delta struct {
+	Artifact Artifact  `json:"artifact"`
+	Added    []Finding `json:"added,omitempty"`
+	Removed  []string  `json:"removed,omitempty"`
+}
+
+// Report is the outcome of an assessment.
+type Report struct {
+	ID         string     `json:"id"`
+	Trigger    string     `json:"trigger"`
+	Status     Status     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// Assessed is the number of artifacts rescanned, and Failed the number of artifacts which could not be.
+	Assessed int     `json:"assessed"`
+	Failed   int     `json:"failed"`
+	Deltas   []Delta `json:"deltas"`
+}
+
+// Assessor runs assessments in the background and keeps the reports of the most recent ones.
+type Assessor interface {
+	// Start starts an assessment and returns its report in the Running status. It returns ErrAssessmentRunning
+	// if an assessment is running already.
+	Start(trigger string) (Report, error)
+	// Get returns the report of the assessment with the given identifier.
+	Get(id string) (Report, bool)
+}
+
+type assessor struct {
+	source   Source
+	index    persistence.VulnerabilityIndex
+	notifier Notifier
+	now      func() time.Time
+
+	mu      sync.Mutex
+	running bool
+	reports []*Report
+}
+
+// NewAssessor constructs an Assessor, which rescans the artifacts of the given Source, updates the
+// vulnerability index with the results, and sends the artifacts newly affected by vulnerabilities to the
+// given Notifier.
+func NewAssessor(source Source, index persistence.VulnerabilityIndex, notifier Notifier) Assessor {
+	return &assessor{
+		source:   source,
+		index:    index,
+		notifier: notifier,
+		now:      time.Now,
+	}
+}
+
+func (a *assessor) Start(trigger string) (Report, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.running {
+		return Report{}, ErrAssessmentRunning
+	}
+
+	id, err := newReportID()
+	if err != nil {
+		return Report{}, err
+	}
+	report := &Report{
+		ID:        id,
+		Trigger:   trigger,
+		Status:    StatusRunning,
+		StartedAt: a.now(),
+		Deltas:    []Delta{},
+	}
+	a.running = true
+	a.reports = append(a.reports, report)
+	if len(a.reports) > maxReports {
+		a.reports = a.reports[len(a.reports)-maxReports:]
+	}
+
+	go a.run(context.Background(), report)
+
+	return *report, nil
+}
+
+func (a *assessor) Get(id string) (Report, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, report := range a.reports {
+		if report.ID == id {
+			return *report, true
+		}
+	}
+	return Report{}, false
+}
+
+func (a *assessor) run(ctx context.Context, report *Report) {
+	logger := slog.With(slog.String("assessment_id", report.ID), slog.String("trigger", report.Trigger))
+	logger.Info("Impact assessment started")
+
+	result, err := a.assess(ctx, logger)
+
+	a.mu.Lock()
+	finishedAt := a.now()
+	report.FinishedAt = &finishedAt
+	report.Assessed = result.Assessed
+	report.Failed = result.Failed
+	report.Deltas = result.Deltas
+	report.Status = StatusFinished
+	if err != nil {
+		report.Status = StatusFailed
+		report.Error = err.Error()
+	}
+	a.running = false
+	done := *report
+	a.mu.Unlock()
+
+	if err != nil {
+		logger.Error("Impact assessment failed", slog.String("err", err.Error()))
+		return
+	}
+	logger.Info("Impact assessment finished", slog.Int("assessed", done.Assessed), slog.Int("failed", done.Failed),
+		slog.Int("changed", len(done.Deltas)))
+
+	if a.notifier != nil {
+		if err := a.notifier.Notify(ctx, done); err != nil {
+			logger.Error("Error while sending impact assessment alert", slog.String("err", err.Error()))
+		}
+	}
+}
+
+func (a *assessor) assess(ctx context.Context, logger *slog.Logger) (Report, error) {
+	result := Report{Deltas: []Delta{}}
+
+	artifacts, err := a.source.Artifacts(ctx)
+	if err != nil {
+		return result, fmt.Errorf("listing artifacts: %w", err)
+	}
+
+	for _, artifact := range artifacts {
+		delta, err := a.assessArtifact(ctx, artifact)
+		if err != nil {
+			logger.Warn("Error while assessing artifact", slog.String("repository", artifact.Repository),
+				slog.String("digest", artifact.Digest), slog.String("err", err.Error()))
+			result.Failed++
+			continue
+		}
+		result.Assessed++
+		if len(delta.Added) > 0 || len(delta.Removed) > 0 {
+			result.Deltas = append(result.Deltas, delta)
+		}
+	}
+	return result, nil
+}
+
+func (a *assessor) assessArtifact(ctx context.Context, artifact Artifact) (Delta, error) {
+	harborArtifact := harbor.Artifact{Repository: artifact.Repository, Digest: artifact.Digest}
+
+	previous, err := a.index.FindVulnerabilities(ctx, artifact.Registry, harborArtifact)
+	if err != nil {
+		return Delta{}, fmt.Errorf("finding indexed vulnerabilities: %w", err)
+	}
+
+	report, err := a.source.Rescan(ctx, artifact)
+	if err != nil {
+		return Delta{}, fmt.Errorf("rescanning: %w", err)
+	}
+	report.Artifact = harborArtifact
+
+	delta := Delta{Artifact: artifact}
+	current := make(map[string]bool)
+	for _, v := range report.Vulnerabilities {
+		current[v.ID] = true
+		if !slices.Contains(previous, v.ID) {
+			delta.Added = append(delta.Added, Finding{
+				ID:         v.ID,
+				Package:    v.Pkg,
+				Version:    v.Version,
+				FixVersion: v.FixVersion,
+				Severity:   v.Severity,
+			})
+		}
+	}
+	for _, id := range previous {
+		if !current[id] {
+			delta.Removed = append(delta.Removed, id)
+		}
+	}
+
+	if err := a.index.Index(ctx, artifact.Registry, report); err != nil {
+		return Delta{}, fmt.Errorf("indexing vulnerabilities: %w", err)
+	}
+	return delta, nil
+}
+
+func newReportID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("reading random bytes: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
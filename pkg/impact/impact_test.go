@@ -0,0 +1,139 @@
+package impact
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSource struct {
+	artifacts []Artifact
+	reports   map[string]harbor.ScanReport
+	release   chan struct{}
+}
+
+func (s *fakeSource) Artifacts(_ context.Context) ([]Artifact, error) {
+	if s.release != nil {
+		<-s.release
+	}
+	return s.artifacts, nil
+}
+
+func (s *fakeSource) Rescan(_ context.Context, artifact Artifact) (harbor.ScanReport, error) {
+	report, ok := s.reports[artifact.Digest]
+	if !ok {
+		return harbor.ScanReport{}, errors.New("no cached SBOM")
+	}
+	return report, nil
+}
+
+type fakeNotifier struct {
+	mu      sync.Mutex
+	reports []Report
+}
+
+func (n *fakeNotifier) Notify(_ context.Context, report Report) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.reports = append(n.reports, report)
+	return nil
+}
+
+func waitForReport(t *testing.T, assessor Assessor, id string) Report {
+	t.Helper()
+	var report Report
+	require.Eventually(t, func() bool {
+		var ok bool
+		report, ok = assessor.Get(id)
+		return ok && report.Status != StatusRunning
+	}, 5*time.Second, 10*time.Millisecond)
+	return report
+}
+
+func TestAssessor(t *testing.T) {
+	artifact := Artifact{Registry: "core.harbor.domain", Repository: "library/mongo", Digest: "sha256:6c3c"}
+	missing := Artifact{Registry: "core.harbor.domain", Repository: "library/nginx", Digest: "sha256:0a2e"}
+	harborArtifact := harbor.Artifact{Repository: "library/mongo", Digest: "sha256:6c3c"}
+
+	t.Run("Should report vulnerabilities added and removed since the artifact was indexed", func(t *testing.T) {
+		rescanned := harbor.ScanReport{
+			Vulnerabilities: []harbor.VulnerabilityItem{
+				{ID: "CVE-0000-0001", Pkg: "openssl", Version: "1.1.1", FixVersion: "1.1.2", Severity: harbor.SevHigh},
+				{ID: "CVE-0000-0002", Pkg: "zlib", Version: "1.2.11", Severity: harbor.SevLow},
+			},
+		}
+		expectedIndexed := rescanned
+		expectedIndexed.Artifact = harborArtifact
+
+		source := &fakeSource{
+			artifacts: []Artifact{artifact, missing},
+			reports:   map[string]harbor.ScanReport{artifact.Digest: rescanned},
+		}
+		index := mock.NewVulnerabilityIndex()
+		index.On("FindVulnerabilities", mock.Anything, "core.harbor.domain", harborArtifact).
+			Return([]string{"CVE-0000-0002", "CVE-0000-0003"}, nil)
+		index.On("FindVulnerabilities", mock.Anything, "core.harbor.domain",
+			harbor.Artifact{Repository: "library/nginx", Digest: "sha256:0a2e"}).Return([]string{}, nil)
+		index.On("Index", mock.Anything, "core.harbor.domain", expectedIndexed).Return(nil)
+		notifier := &fakeNotifier{}
+
+		assessor := NewAssessor(source, index, notifier)
+		started, err := assessor.Start(TriggerAdmin)
+		require.NoError(t, err)
+		assert.Equal(t, StatusRunning, started.Status)
+		assert.Equal(t, TriggerAdmin, started.Trigger)
+
+		report := waitForReport(t, assessor, started.ID)
+		assert.Equal(t, StatusFinished, report.Status)
+		assert.NotNil(t, report.FinishedAt)
+		assert.Equal(t, 1, report.Assessed)
+		assert.Equal(t, 1, report.Failed)
+		assert.Equal(t, []Delta{
+			{
+				Artifact: artifact,
+				Added: []Finding{
+					{ID: "CVE-0000-0001", Package: "openssl", Version: "1.1.1", FixVersion: "1.1.2", Severity: harbor.SevHigh},
+				},
+				Removed: []string{"CVE-0000-0003"},
+			},
+		}, report.Deltas)
+		index.AssertExpectations(t)
+
+		require.Eventually(t, func() bool {
+			notifier.mu.Lock()
+			defer notifier.mu.Unlock()
+			return len(notifier.reports) == 1
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Should not start an assessment while another one is running", func(t *testing.T) {
+		source := &fakeSource{release: make(chan struct{})}
+		assessor := NewAssessor(source, mock.NewVulnerabilityIndex(), nil)
+
+		started, err := assessor.Start(TriggerAdmin)
+		require.NoError(t, err)
+
+		_, err = assessor.Start(TriggerDBUpdate)
+		assert.ErrorIs(t, err, ErrAssessmentRunning)
+
+		close(source.release)
+		report := waitForReport(t, assessor, started.ID)
+		assert.Equal(t, StatusFinished, report.Status)
+
+		_, err = assessor.Start(TriggerDBUpdate)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Should not find unknown assessment", func(t *testing.T) {
+		assessor := NewAssessor(&fakeSource{}, mock.NewVulnerabilityIndex(), nil)
+		_, ok := assessor.Get("unknown")
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,63 @@
+package impact
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/scan"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+)
+
+type sbomSource struct {
+	sboms       persistence.SBOMStore
+	wrapper     tunnel.Wrapper
+	transformer scan.Transformer
+}
+
+// NewSBOMSource constructs a Source, which rescans the artifacts with SBOMs in the given SBOMStore.
+func NewSBOMSource(sboms persistence.SBOMStore, wrapper tunnel.Wrapper, transformer scan.Transformer) Source {
+	return &sbomSource{
+		sboms:       sboms,
+		wrapper:     wrapper,
+		transformer: transformer,
+	}
+}
+
+func (s *sbomSource) Artifacts(ctx context.Context) ([]Artifact, error) {
+	stored, err := s.sboms.FindArtifacts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts := make([]Artifact, 0, len(stored))
+	for _, a := range stored {
+		artifacts = append(artifacts, Artifact{
+			Registry:   a.Registry,
+			Repository: a.Repository,
+			Digest:     a.Digest,
+		})
+	}
+	return artifacts, nil
+}
+
+func (s *sbomSource) Rescan(ctx context.Context, artifact Artifact) (harbor.ScanReport, error) {
+	sbom, err := s.sboms.Get(ctx, artifact.Digest)
+	if err != nil {
+		return harbor.ScanReport{}, err
+	}
+	if sbom == nil {
+		return harbor.ScanReport{}, fmt.Errorf("SBOM has expired: %s", artifact.Digest)
+	}
+
+	report, err := s.wrapper.ScanSBOM(sbom)
+	if err != nil {
+		return harbor.ScanReport{}, err
+	}
+
+	return s.transformer.Transform(harbor.Artifact{
+		Repository: artifact.Repository,
+		Digest:     artifact.Digest,
+	}, report.Vulnerabilities), nil
+}
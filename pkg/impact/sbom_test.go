@@ -0,0 +1,60 @@
+package impact
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/mock"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSBOMSource(t *testing.T) {
+	ctx := context.Background()
+	artifact := Artifact{Registry: "https://core.harbor.domain", Repository: "library/mongo", Digest: "sha256:917f"}
+	harborArtifact := harbor.Artifact{Repository: "library/mongo", Digest: "sha256:917f"}
+	sbom := []byte(`{"bomFormat": "CycloneDX"}`)
+
+	t.Run("Should list artifacts with stored SBOMs", func(t *testing.T) {
+		sboms := mock.NewSBOMStore()
+		sboms.On("FindArtifacts", ctx).Return([]persistence.SBOMArtifact{
+			{Registry: artifact.Registry, Repository: artifact.Repository, Digest: artifact.Digest, StoredAt: time.Now()},
+		}, nil)
+
+		artifacts, err := NewSBOMSource(sboms, tunnel.NewMockWrapper(), mock.NewTransformer()).Artifacts(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []Artifact{artifact}, artifacts)
+	})
+
+	t.Run("Should rescan stored SBOM", func(t *testing.T) {
+		vulnerabilities := []tunnel.Vulnerability{{VulnerabilityID: "CVE-2019-1549"}}
+		expected := harbor.ScanReport{
+			Artifact:        harborArtifact,
+			Vulnerabilities: []harbor.VulnerabilityItem{{ID: "CVE-2019-1549"}},
+		}
+
+		sboms := mock.NewSBOMStore()
+		sboms.On("Get", ctx, artifact.Digest).Return(sbom, nil)
+		wrapper := tunnel.NewMockWrapper()
+		wrapper.On("ScanSBOM", sbom).Return(tunnel.Report{Vulnerabilities: vulnerabilities}, nil)
+		transformer := mock.NewTransformer()
+		transformer.On("Transform", harborArtifact, vulnerabilities).Return(expected)
+
+		report, err := NewSBOMSource(sboms, wrapper, transformer).Rescan(ctx, artifact)
+		require.NoError(t, err)
+		assert.Equal(t, expected, report)
+		wrapper.AssertExpectations(t)
+	})
+
+	t.Run("Should return error when SBOM has expired", func(t *testing.T) {
+		sboms := mock.NewSBOMStore()
+		sboms.On("Get", ctx, artifact.Digest).Return([]byte(nil), nil)
+
+		_, err := NewSBOMSource(sboms, tunnel.NewMockWrapper(), mock.NewTransformer()).Rescan(ctx, artifact)
+		assert.EqualError(t, err, "SBOM has expired: sha256:917f")
+	})
+}
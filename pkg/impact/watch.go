@@ -0,0 +1,51 @@
+package impact
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+)
+
+// WatchDBUpdates polls the vulnerability database metadata of the given Wrapper at the given interval, and
+// starts an assessment whenever the database has been updated. It returns when the context is done.
+func WatchDBUpdates(ctx context.Context, assessor Assessor, wrapper tunnel.Wrapper, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastUpdatedAt time.Time
+	for {
+		updatedAt, err := dbUpdatedAt(wrapper)
+		if err != nil {
+			slog.Warn("Error while polling vulnerability DB", slog.String("err", err.Error()))
+		} else if updatedAt.After(lastUpdatedAt) {
+			// The first observation only establishes the baseline, the cached SBOMs were scanned against it.
+			if !lastUpdatedAt.IsZero() {
+				slog.Info("Vulnerability DB updated", slog.Time("updated_at", updatedAt))
+				if _, err := assessor.Start(TriggerDBUpdate); err != nil {
+					slog.Warn("Error while starting impact assessment", slog.String("err", err.Error()))
+				}
+			}
+			lastUpdatedAt = updatedAt
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func dbUpdatedAt(wrapper tunnel.Wrapper) (time.Time, error) {
+	vi, err := wrapper.GetVersion()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if vi.VulnerabilityDB == nil {
+		return time.Time{}, errors.New("vulnerability DB has not been downloaded yet")
+	}
+	return vi.VulnerabilityDB.UpdatedAt, nil
+}
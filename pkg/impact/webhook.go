@@ -0,0 +1,116 @@
+package impact
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/httpx"
+)
+
+// Notifier wraps the Notify method.
+// Notify alerts about the artifacts newly affected by vulnerabilities in the given report.
+type Notifier interface {
+	Notify(ctx context.Context, report Report) error
+}
+
+// Alert is the payload posted to the webhook.
+type Alert struct {
+	AssessmentID string    `json:"assessment_id"`
+	Trigger      string    `json:"trigger"`
+	FinishedAt   time.Time `json:"finished_at"`
+	// Artifacts hold the artifacts newly affected by vulnerabilities of at least the minimum severity,
+	// listing only these vulnerabilities.
+	Artifacts []Delta `json:"artifacts"`
+}
+
+type webhookNotifier struct {
+	url         string
+	minSeverity harbor.Severity
+	client      *http.Client
+}
+
+// NewWebhookNotifier constructs a Notifier, which posts an Alert to the given URL when an assessment finds
+// artifacts newly affected by vulnerabilities of at least the given severity.
+func NewWebhookNotifier(url string, minSeverity harbor.Severity) Notifier {
+	return &webhookNotifier{
+		url:         url,
+		minSeverity: minSeverity,
+		client:      httpx.Client("impact_webhook", 30*time.Second),
+	}
+}
+
+func (n *webhookNotifier) Notify(ctx context.Context, report Report) error {
+	alert := Alert{
+		AssessmentID: report.ID,
+		Trigger:      report.Trigger,
+		Artifacts:    []Delta{},
+	}
+	if report.FinishedAt != nil {
+		alert.FinishedAt = *report.FinishedAt
+	}
+	for _, delta := range report.Deltas {
+		var added []Finding
+		for _, finding := range delta.Added {
+			if finding.Severity >= n.minSeverity {
+				added = append(added, finding)
+			}
+		}
+		if len(added) > 0 {
+			alert.Artifacts = append(alert.Artifacts, Delta{Artifact: delta.Artifact, Added: added})
+		}
+	}
+	if len(alert.Artifacts) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("marshalling alert: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting alert: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("posting alert: unexpected status %d", res.StatusCode)
+	}
+	return nil
+}
+
+type filteringNotifier struct {
+	notifier Notifier
+	accept   func(artifact Artifact) bool
+}
+
+// NewFilteringNotifier constructs a Notifier, which alerts the given Notifier only about the artifacts
+// accepted by the given function.
+func NewFilteringNotifier(notifier Notifier, accept func(artifact Artifact) bool) Notifier {
+	return &filteringNotifier{
+		notifier: notifier,
+		accept:   accept,
+	}
+}
+
+func (n *filteringNotifier) Notify(ctx context.Context, report Report) error {
+	deltas := make([]Delta, 0, len(report.Deltas))
+	for _, delta := range report.Deltas {
+		if n.accept(delta.Artifact) {
+			deltas = append(deltas, delta)
+		}
+	}
+	report.Deltas = deltas
+	return n.notifier.Notify(ctx, report)
+}
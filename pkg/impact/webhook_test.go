@@ -0,0 +1,104 @@
+package impact
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookNotifier_Notify(t *testing.T) {
+	finishedAt := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	artifact := Artifact{Registry: "core.harbor.domain", Repository: "library/mongo", Digest: "sha256:6c3c"}
+	report := Report{
+		ID:         "a1b2",
+		Trigger:    TriggerDBUpdate,
+		Status:     StatusFinished,
+		FinishedAt: &finishedAt,
+		Deltas: []Delta{
+			{
+				Artifact: artifact,
+				Added: []Finding{
+					{ID: "CVE-0000-0001", Package: "openssl", Version: "1.1.1", Severity: harbor.SevCritical},
+					{ID: "CVE-0000-0002", Package: "zlib", Version: "1.2.11", Severity: harbor.SevLow},
+				},
+			},
+			{
+				Artifact: Artifact{Registry: "core.harbor.domain", Repository: "library/nginx", Digest: "sha256:0a2e"},
+				Removed:  []string{"CVE-0000-0003"},
+			},
+		},
+	}
+
+	t.Run("Should post newly affected artifacts with findings of at least the minimum severity", func(t *testing.T) {
+		var alert Alert
+		server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, http.MethodPost, req.Method)
+			assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+			assert.NoError(t, json.NewDecoder(req.Body).Decode(&alert))
+			res.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		err := NewWebhookNotifier(server.URL, harbor.SevHigh).Notify(context.Background(), report)
+		require.NoError(t, err)
+		assert.Equal(t, Alert{
+			AssessmentID: "a1b2",
+			Trigger:      TriggerDBUpdate,
+			FinishedAt:   finishedAt,
+			Artifacts: []Delta{
+				{
+					Artifact: artifact,
+					Added: []Finding{
+						{ID: "CVE-0000-0001", Package: "openssl", Version: "1.1.1", Severity: harbor.SevCritical},
+					},
+				},
+			},
+		}, alert)
+	})
+
+	t.Run("Should not post when no artifact is newly affected above the minimum severity", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			t.Error("unexpected request")
+		}))
+		defer server.Close()
+
+		err := NewWebhookNotifier(server.URL, harbor.SevLow).Notify(context.Background(), Report{ID: "c3d4", Deltas: report.Deltas[1:]})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Should return error when webhook responds with error status", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			res.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		err := NewWebhookNotifier(server.URL, harbor.SevLow).Notify(context.Background(), report)
+		assert.EqualError(t, err, "posting alert: unexpected status 500")
+	})
+}
+
+func TestFilteringNotifier_Notify(t *testing.T) {
+	report := Report{
+		ID: "a1b2",
+		Deltas: []Delta{
+			{Artifact: Artifact{Repository: "library/mongo"}, Removed: []string{"CVE-0000-0001"}},
+			{Artifact: Artifact{Repository: "team-a/nginx"}, Removed: []string{"CVE-0000-0002"}},
+		},
+	}
+
+	fake := &fakeNotifier{}
+	notifier := NewFilteringNotifier(fake, func(artifact Artifact) bool {
+		return artifact.Repository == "team-a/nginx"
+	})
+
+	err := notifier.Notify(context.Background(), report)
+	require.NoError(t, err)
+	assert.Equal(t, []Report{{ID: "a1b2", Deltas: report.Deltas[1:]}}, fake.reports)
+}
@@ -0,0 +1,128 @@
+package job
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+)
+
+const (
+	IDGeneratorRandom = "random"
+	IDGeneratorUUIDv7 = "uuidv7"
+	IDGeneratorULID   = "ulid"
+	IDGeneratorDigest = "digest"
+)
+
+// IDGenerator wraps the NewID method.
+// NewID returns an identifier for the scan job created for the given request.
+type IDGenerator interface {
+	NewID(request harbor.ScanRequest) (string, error)
+}
+
+// NewIDGenerator constructs the IDGenerator registered under the given name.
+//
+// The random generator produces the historical 24 hex characters identifiers. The uuidv7 and ulid
+// generators produce time-ordered identifiers, which sort naturally in Redis scans and logs. The digest
+// generator derives the identifier from the registry URL, the artifact and the requested capabilities, so
+// repeated requests to scan the same artifact for the same reports map to the same scan job.
+func NewIDGenerator(name string) (IDGenerator, error) {
+	switch name {
+	case "", IDGeneratorRandom:
+		return &randomGenerator{rand: rand.Reader}, nil
+	case IDGeneratorUUIDv7:
+		return &uuidV7Generator{now: time.Now, rand: rand.Reader}, nil
+	case IDGeneratorULID:
+		return &ulidGenerator{now: time.Now, rand: rand.Reader}, nil
+	case IDGeneratorDigest:
+		return &digestGenerator{}, nil
+	}
+	return nil, fmt.Errorf("unsupported scan job ID generator: %s", name)
+}
+
+type randomGenerator struct {
+	rand io.Reader
+}
+
+func (g *randomGenerator) NewID(_ harbor.ScanRequest) (string, error) {
+	b := make([]byte, 12)
+	if _, err := io.ReadFull(g.rand, b); err != nil {
+		return "", fmt.Errorf("reading random bytes: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// uuidV7Generator generates UUID version 7 identifiers as specified by RFC 9562.
+type uuidV7Generator struct {
+	now  func() time.Time
+	rand io.Reader
+}
+
+func (g *uuidV7Generator) NewID(_ harbor.ScanRequest) (string, error) {
+	var b [16]byte
+	if _, err := io.ReadFull(g.rand, b[6:]); err != nil {
+		return "", fmt.Errorf("reading random bytes: %w", err)
+	}
+	putUnixMilli(b[:6], g.now())
+
+	b[6] = (b[6] & 0x0f) | 0x70 // version 7
+	b[8] = (b[8] & 0x3f) | 0x80 // variant 10
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// crockfordAlphabet is the Base32 alphabet used to encode ULIDs.
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ulidGenerator generates Universally Unique Lexicographically Sortable Identifiers.
+// See https://github.com/ulid/spec.
+type ulidGenerator struct {
+	now  func() time.Time
+	rand io.Reader
+}
+
+func (g *ulidGenerator) NewID(_ harbor.ScanRequest) (string, error) {
+	var b [16]byte
+	if _, err := io.ReadFull(g.rand, b[6:]); err != nil {
+		return "", fmt.Errorf("reading random bytes: %w", err)
+	}
+	putUnixMilli(b[:6], g.now())
+
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+
+	// 128 bits are encoded as 26 characters of 5 bits each, the first character holding the top 3 bits.
+	var id [26]byte
+	for i := 25; i >= 0; i-- {
+		id[i] = crockfordAlphabet[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(id[:]), nil
+}
+
+type digestGenerator struct {
+}
+
+func (g *digestGenerator) NewID(request harbor.ScanRequest) (string, error) {
+	if request.Artifact.Digest == "" {
+		return "", fmt.Errorf("artifact digest must not be blank")
+	}
+	sum := sha256.Sum256([]byte(request.Registry.URL + "/" + request.Artifact.Repository + "@" + request.Artifact.Digest +
+		"#" + request.CapabilitiesKey()))
+	return hex.EncodeToString(sum[:16]), nil
+}
+
+// putUnixMilli writes the 48-bit big-endian Unix timestamp in milliseconds into b.
+func putUnixMilli(b []byte, t time.Time) {
+	ms := uint64(t.UnixMilli())
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(ms)
+		ms >>= 8
+	}
+}
@@ -0,0 +1,130 @@
+package job
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewIDGenerator(t *testing.T) {
+	testCases := []struct {
+		name          string
+		generator     string
+		expectedType  IDGenerator
+		expectedError string
+	}{
+		{
+			name:         "Should default to random generator",
+			generator:    "",
+			expectedType: &randomGenerator{},
+		},
+		{
+			name:         "Should return UUIDv7 generator",
+			generator:    "uuidv7",
+			expectedType: &uuidV7Generator{},
+		},
+		{
+			name:         "Should return ULID generator",
+			generator:    "ulid",
+			expectedType: &ulidGenerator{},
+		},
+		{
+			name:         "Should return digest generator",
+			generator:    "digest",
+			expectedType: &digestGenerator{},
+		},
+		{
+			name:          "Should return error for unknown generator",
+			generator:     "snowflake",
+			expectedError: "unsupported scan job ID generator: snowflake",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			generator, err := NewIDGenerator(tc.generator)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.IsType(t, tc.expectedType, generator)
+		})
+	}
+}
+
+func TestIDGenerator_NewID(t *testing.T) {
+	fixedTime := time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC)
+	fixedNow := func() time.Time { return fixedTime }
+	randomBytes := bytes.Repeat([]byte{0xff}, 16)
+
+	request := harbor.ScanRequest{
+		Registry: harbor.Registry{URL: "https://core.harbor.domain"},
+		Artifact: harbor.Artifact{
+			Repository: "library/mongo",
+			Digest:     "sha256:917f5b7f4bef1b35ee90f03033f33a81002511c1e0767fd44276d4bd9cd2fa8e",
+		},
+	}
+
+	t.Run("Random", func(t *testing.T) {
+		id, err := (&randomGenerator{rand: bytes.NewReader(randomBytes)}).NewID(request)
+		require.NoError(t, err)
+		assert.Equal(t, "ffffffffffffffffffffffff", id)
+	})
+
+	t.Run("UUIDv7", func(t *testing.T) {
+		id, err := (&uuidV7Generator{now: fixedNow, rand: bytes.NewReader(randomBytes)}).NewID(request)
+		require.NoError(t, err)
+		assert.Equal(t, "018bcfe5-6800-7fff-bfff-ffffffffffff", id)
+	})
+
+	t.Run("ULID", func(t *testing.T) {
+		id, err := (&ulidGenerator{now: fixedNow, rand: bytes.NewReader(randomBytes)}).NewID(request)
+		require.NoError(t, err)
+		assert.Equal(t, "01HF7YAT00ZZZZZZZZZZZZZZZZ", id)
+	})
+
+	t.Run("ULIDs should sort by time", func(t *testing.T) {
+		first, err := (&ulidGenerator{now: fixedNow, rand: bytes.NewReader(randomBytes)}).NewID(request)
+		require.NoError(t, err)
+		second, err := (&ulidGenerator{
+			now:  func() time.Time { return fixedTime.Add(time.Millisecond) },
+			rand: bytes.NewReader(make([]byte, 16)),
+		}).NewID(request)
+		require.NoError(t, err)
+		assert.Less(t, first, second)
+	})
+
+	t.Run("Digest", func(t *testing.T) {
+		generator := &digestGenerator{}
+		first, err := generator.NewID(request)
+		require.NoError(t, err)
+		second, err := generator.NewID(request)
+		require.NoError(t, err)
+
+		assert.Regexp(t, regexp.MustCompile("^[0-9a-f]{32}$"), first)
+		assert.Equal(t, first, second)
+
+		sbomRequest := request
+		sbomRequest.EnabledCapabilities = []harbor.EnabledCapability{{Type: harbor.CapabilityTypeSBOM}}
+		sbom, err := generator.NewID(sbomRequest)
+		require.NoError(t, err)
+		assert.NotEqual(t, first, sbom)
+
+		sbomRequest.EnabledCapabilities = []harbor.EnabledCapability{{
+			Type:       harbor.CapabilityTypeSBOM,
+			Parameters: &harbor.CapabilityParameters{SBOMMediaTypes: []string{harbor.MediaTypeSPDX}},
+		}}
+		spdx, err := generator.NewID(sbomRequest)
+		require.NoError(t, err)
+		assert.NotEqual(t, sbom, spdx)
+
+		_, err = generator.NewID(harbor.ScanRequest{})
+		assert.EqualError(t, err, "artifact digest must not be blank")
+	})
+}
@@ -1,6 +1,10 @@
 package job
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
 )
 
@@ -20,9 +24,81 @@ func (s ScanJobStatus) String() string {
 	return [...]string{"Queued", "Pending", "Finished", "Failed"}[s]
 }
 
+// ParseScanJobStatus returns the status of the given name, which is matched case-insensitively.
+func ParseScanJobStatus(name string) (ScanJobStatus, error) {
+	for s := Queued; s <= Failed; s++ {
+		if strings.EqualFold(s.String(), name) {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown scan job status: %s", name)
+}
+
 type ScanJob struct {
 	ID     string            `json:"id"`
 	Status ScanJobStatus     `json:"status"`
 	Error  string            `json:"error"`
 	Report harbor.ScanReport `json:"report"`
+	// SBOM is the report of the sbom capability, nil unless the scan job was requested for it.
+	SBOM *harbor.SBOMReport `json:"sbom,omitempty"`
+	// Request is the scan request the job was created for, kept to requeue the job after the queue is lost.
+	Request *harbor.ScanRequest `json:"request,omitempty"`
+	// TraceContext is the trace context of the accepted scan request, which the scan job is traced in.
+	TraceContext map[string]string `json:"trace_context,omitempty"`
+}
+
+// QueuePosition describes where a scan job stands in the queue.
+type QueuePosition struct {
+	// Position is the 1-based position of the scan job in the backlog, or 0 if it is no longer queued.
+	Position int
+	// EstimatedWait is the estimated time before the scan job is picked up by a worker.
+	EstimatedWait time.Duration
+	// Estimated is false if there are no historical scan durations to base the estimate on.
+	Estimated bool
+}
+
+// QueueStats describes the backlog of the queue.
+type QueueStats struct {
+	// Backlog is the number of enqueued scan jobs not yet picked up by a worker.
+	Backlog int64 `json:"backlog"`
+	// AverageScanDuration is zero if there are no recorded scan durations.
+	AverageScanDuration time.Duration `json:"average_scan_duration"`
+}
+
+// Summary is the compact record of a scan job, which is kept apart from its report so that dashboards and
+// listings never decode reports.
+type Summary struct {
+	ID     string        `json:"id"`
+	Status ScanJobStatus `json:"status"`
+	Error  string        `json:"error,omitempty"`
+	// Counts are the numbers of reported vulnerabilities keyed by severity name.
+	Counts map[string]int `json:"counts"`
+	// CreatedAt is when the scan job was queued. StartedAt and FinishedAt are zero until the scan job is
+	// picked up by a worker and completed respectively.
+	CreatedAt  time.Time `json:"created_at"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// Entry is the record of a scan job in listings, i.e. its summary and the artifact it scans, without its report.
+type Entry struct {
+	Summary
+	// Artifact is nil for scan jobs stored without their scan request.
+	Artifact *harbor.Artifact `json:"artifact,omitempty"`
+}
+
+// QueueDuration returns how long the scan job waited in the queue, or 0 if it has not been picked up yet.
+func (s Summary) QueueDuration() time.Duration {
+	if s.StartedAt.IsZero() {
+		return 0
+	}
+	return s.StartedAt.Sub(s.CreatedAt)
+}
+
+// ScanDuration returns how long the scan job ran, or 0 if it has not completed yet.
+func (s Summary) ScanDuration() time.Duration {
+	if s.StartedAt.IsZero() || s.FinishedAt.IsZero() {
+		return 0
+	}
+	return s.FinishedAt.Sub(s.StartedAt)
 }
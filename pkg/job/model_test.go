@@ -0,0 +1,57 @@
+package job
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummary_Durations(t *testing.T) {
+	createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name                  string
+		summary               Summary
+		expectedQueueDuration time.Duration
+		expectedScanDuration  time.Duration
+	}{
+		{
+			name:    "Should return zero durations when scan job is queued",
+			summary: Summary{Status: Queued, CreatedAt: createdAt},
+		},
+		{
+			name:                  "Should return queue duration when scan job is running",
+			summary:               Summary{Status: Pending, CreatedAt: createdAt, StartedAt: createdAt.Add(time.Minute)},
+			expectedQueueDuration: time.Minute,
+		},
+		{
+			name: "Should return queue and scan durations when scan job is completed",
+			summary: Summary{
+				Status:     Finished,
+				CreatedAt:  createdAt,
+				StartedAt:  createdAt.Add(time.Minute),
+				FinishedAt: createdAt.Add(3 * time.Minute),
+			},
+			expectedQueueDuration: time.Minute,
+			expectedScanDuration:  2 * time.Minute,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedQueueDuration, tc.summary.QueueDuration())
+			assert.Equal(t, tc.expectedScanDuration, tc.summary.ScanDuration())
+		})
+	}
+}
+
+func TestParseScanJobStatus(t *testing.T) {
+	status, err := ParseScanJobStatus("finished")
+	require.NoError(t, err)
+	assert.Equal(t, Finished, status)
+
+	_, err = ParseScanJobStatus("Running")
+	assert.EqualError(t, err, "unknown scan job status: Running")
+}
@@ -0,0 +1,113 @@
+package job
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+)
+
+// SchemaVersion is the version of the structure of stored scan jobs, including their scan reports.
+// Whenever a change to ScanJob or harbor.ScanReport would prevent decoding scan jobs stored by a previous
+// version of the adapter, SchemaVersion must be incremented and an upgrade appended to upgrades.
+const SchemaVersion = 1
+
+// upgrades holds, at index v, the function which upgrades the JSON object of a scan job stored with schema
+// version v to version v+1.
+var upgrades = []func(scanJob map[string]json.RawMessage) error{
+	// Scan jobs stored before schema versioning was introduced have the same structure as version 1.
+	func(map[string]json.RawMessage) error { return nil },
+}
+
+// versionedScanJob is the representation of a scan job in stores.
+type versionedScanJob struct {
+	SchemaVersion int `json:"schema_version"`
+	ScanJob
+}
+
+// Marshal encodes the given scan job for storing, tagged with the current SchemaVersion.
+func Marshal(scanJob ScanJob) ([]byte, error) {
+	return json.Marshal(versionedScanJob{SchemaVersion: SchemaVersion, ScanJob: scanJob})
+}
+
+// UnmarshalStatus decodes only the status of a stored scan job, which is much cheaper than decoding the whole
+// scan job when it holds a large scan report. It returns an error for scan jobs stored by a newer version of the
+// adapter.
+func UnmarshalStatus(data []byte) (ScanJobStatus, error) {
+	var header struct {
+		SchemaVersion int           `json:"schema_version"`
+		Status        ScanJobStatus `json:"status"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return 0, err
+	}
+	if header.SchemaVersion > SchemaVersion {
+		return 0, fmt.Errorf("unsupported scan job schema version %d, expected at most %d", header.SchemaVersion, SchemaVersion)
+	}
+	return header.Status, nil
+}
+
+// UnmarshalEntry decodes the ID, status, error and artifact of a stored scan job, leaving the timestamps and
+// counts of its summary zero. Like UnmarshalStatus, its report is not decoded. It returns an error for scan jobs
+// stored by a newer version of the adapter.
+func UnmarshalEntry(data []byte) (Entry, error) {
+	var header struct {
+		SchemaVersion int           `json:"schema_version"`
+		ID            string        `json:"id"`
+		Status        ScanJobStatus `json:"status"`
+		Error         string        `json:"error"`
+		Request       *struct {
+			Artifact harbor.Artifact `json:"artifact"`
+		} `json:"request"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return Entry{}, err
+	}
+	if header.SchemaVersion > SchemaVersion {
+		return Entry{}, fmt.Errorf("unsupported scan job schema version %d, expected at most %d", header.SchemaVersion, SchemaVersion)
+	}
+	entry := Entry{Summary: Summary{ID: header.ID, Status: header.Status, Error: header.Error}}
+	if header.Request != nil {
+		entry.Artifact = &header.Request.Artifact
+	}
+	return entry, nil
+}
+
+// Unmarshal decodes a stored scan job, upgrading it from the schema version it was stored with to the
+// current SchemaVersion. It returns an error for scan jobs stored by a newer version of the adapter.
+func Unmarshal(data []byte) (ScanJob, error) {
+	var header struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return ScanJob{}, err
+	}
+
+	version := header.SchemaVersion
+	if version > SchemaVersion {
+		return ScanJob{}, fmt.Errorf("unsupported scan job schema version %d, expected at most %d", version, SchemaVersion)
+	}
+
+	if version < SchemaVersion {
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(data, &object); err != nil {
+			return ScanJob{}, err
+		}
+		for ; version < SchemaVersion; version++ {
+			if err := upgrades[version](object); err != nil {
+				return ScanJob{}, fmt.Errorf("upgrading scan job schema from version %d: %w", version, err)
+			}
+		}
+		upgraded, err := json.Marshal(object)
+		if err != nil {
+			return ScanJob{}, err
+		}
+		data = upgraded
+	}
+
+	var scanJob ScanJob
+	if err := json.Unmarshal(data, &scanJob); err != nil {
+		return ScanJob{}, err
+	}
+	return scanJob, nil
+}
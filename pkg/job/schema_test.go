@@ -0,0 +1,97 @@
+package job
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchema(t *testing.T) {
+	t.Run("Should register an upgrade for each previous schema version", func(t *testing.T) {
+		assert.Len(t, upgrades, SchemaVersion)
+	})
+
+	t.Run("Should tag marshalled scan job with current schema version", func(t *testing.T) {
+		data, err := Marshal(ScanJob{ID: "123", Status: Finished})
+		require.NoError(t, err)
+
+		var object map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(data, &object))
+		assert.JSONEq(t, "1", string(object["schema_version"]))
+		assert.JSONEq(t, `"123"`, string(object["id"]))
+	})
+
+	t.Run("Should unmarshal marshalled scan job", func(t *testing.T) {
+		scanJob := ScanJob{
+			ID:     "123",
+			Status: Finished,
+			Report: harbor.ScanReport{
+				Severity:        harbor.SevHigh,
+				Vulnerabilities: []harbor.VulnerabilityItem{{ID: "CVE-2013-1400", Severity: harbor.SevHigh}},
+			},
+		}
+
+		data, err := Marshal(scanJob)
+		require.NoError(t, err)
+
+		unmarshalled, err := Unmarshal(data)
+		require.NoError(t, err)
+		assert.Equal(t, scanJob, unmarshalled)
+	})
+
+	t.Run("Should unmarshal status of marshalled scan job", func(t *testing.T) {
+		data, err := Marshal(ScanJob{ID: "123", Status: Failed, Error: "out of memory"})
+		require.NoError(t, err)
+
+		status, err := UnmarshalStatus(data)
+		require.NoError(t, err)
+		assert.Equal(t, Failed, status)
+	})
+
+	t.Run("Should return error when unmarshalling status of scan job stored by newer version", func(t *testing.T) {
+		_, err := UnmarshalStatus([]byte(`{"schema_version": 99, "id": "123", "status": 2}`))
+		assert.EqualError(t, err, "unsupported scan job schema version 99, expected at most 1")
+	})
+
+	t.Run("Should unmarshal entry of marshalled scan job", func(t *testing.T) {
+		data, err := Marshal(ScanJob{
+			ID:      "123",
+			Status:  Failed,
+			Error:   "out of memory",
+			Request: &harbor.ScanRequest{Artifact: harbor.Artifact{Repository: "library/mongo", Digest: "sha256:917f"}},
+		})
+		require.NoError(t, err)
+
+		entry, err := UnmarshalEntry(data)
+		require.NoError(t, err)
+		assert.Equal(t, Entry{
+			Summary:  Summary{ID: "123", Status: Failed, Error: "out of memory"},
+			Artifact: &harbor.Artifact{Repository: "library/mongo", Digest: "sha256:917f"},
+		}, entry)
+	})
+
+	t.Run("Should unmarshal entry of scan job stored without request", func(t *testing.T) {
+		entry, err := UnmarshalEntry([]byte(`{"id": "123", "status": 2, "error": ""}`))
+		require.NoError(t, err)
+		assert.Equal(t, Entry{Summary: Summary{ID: "123", Status: Finished}}, entry)
+	})
+
+	t.Run("Should upgrade scan job stored before schema versioning", func(t *testing.T) {
+		scanJob, err := Unmarshal([]byte(`{"id": "123", "status": 3, "error": "out of memory", "report": {"severity": "Unknown"}}`))
+		require.NoError(t, err)
+		assert.Equal(t, ScanJob{
+			ID:     "123",
+			Status: Failed,
+			Error:  "out of memory",
+			Report: harbor.ScanReport{Severity: harbor.SevUnknown},
+		}, scanJob)
+	})
+
+	t.Run("Should return error for scan job stored with newer schema version", func(t *testing.T) {
+		_, err := Unmarshal([]byte(`{"schema_version": 2, "id": "123"}`))
+		assert.EqualError(t, err, "unsupported scan job schema version 2, expected at most 1")
+	})
+}
@@ -0,0 +1,112 @@
+// Package log attaches the scan job ID, the artifact digest and the trace ID carried by contexts to the records
+// logged with them, so that the records of a scan job are correlated without repeating these attributes on every
+// slog call. Contexts carry them once WithScanJobID and WithDigest are called, e.g. when a worker picks up a scan
+// job, and the records logged with the *Context functions of slog, e.g. slog.WarnContext, get them from the
+// Handler installed as the default handler.
+package log
+
+import (
+	"context"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Keys of the attributes attached to records.
+const (
+	KeyScanJobID = "scan_job_id"
+	KeyDigest    = "digest"
+	KeyTraceID   = "trace_id"
+)
+
+type scanJobIDKey struct{}
+
+type digestKey struct{}
+
+// WithScanJobID returns a copy of the given context carrying the given scan job ID.
+func WithScanJobID(ctx context.Context, scanJobID string) context.Context {
+	return context.WithValue(ctx, scanJobIDKey{}, scanJobID)
+}
+
+// WithDigest returns a copy of the given context carrying the given artifact digest.
+func WithDigest(ctx context.Context, digest string) context.Context {
+	return context.WithValue(ctx, digestKey{}, digest)
+}
+
+// ScanJobID returns the scan job ID carried by the given context, or an empty string.
+func ScanJobID(ctx context.Context) string {
+	id, _ := ctx.Value(scanJobIDKey{}).(string)
+	return id
+}
+
+// Attrs returns the attributes carried by the given context, i.e. the scan job ID, the artifact digest and the ID
+// of the trace of its span, if any.
+func Attrs(ctx context.Context) []slog.Attr {
+	var attrs []slog.Attr
+	if id := ScanJobID(ctx); id != "" {
+		attrs = append(attrs, slog.String(KeyScanJobID, id))
+	}
+	if digest, _ := ctx.Value(digestKey{}).(string); digest != "" {
+		attrs = append(attrs, slog.String(KeyDigest, digest))
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		attrs = append(attrs, slog.String(KeyTraceID, spanContext.TraceID().String()))
+	}
+	return attrs
+}
+
+// handler adds the attributes of the contexts of records to them, unless the records or the attributes bound to
+// the handler already have attributes with the same keys.
+type handler struct {
+	slog.Handler
+	// bound are the keys of the top-level attributes bound with WithAttrs.
+	bound map[string]bool
+	// grouped is true once a group is opened, after which bound attributes do not collide with top-level ones.
+	grouped bool
+}
+
+// NewHandler wraps the given Handler, adding the attributes carried by the context of each record to the record.
+func NewHandler(next slog.Handler) slog.Handler {
+	return &handler{Handler: next}
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	attrs := Attrs(ctx)
+	if len(attrs) == 0 {
+		return h.Handler.Handle(ctx, r)
+	}
+
+	present := make(map[string]bool, r.NumAttrs()+len(h.bound))
+	for key := range h.bound {
+		present[key] = true
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		present[a.Key] = true
+		return true
+	})
+	r = r.Clone()
+	for _, a := range attrs {
+		if !present[a.Key] {
+			r.AddAttrs(a)
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	bound := h.bound
+	if !h.grouped {
+		bound = make(map[string]bool, len(h.bound)+len(attrs))
+		for key := range h.bound {
+			bound[key] = true
+		}
+		for _, a := range attrs {
+			bound[a.Key] = true
+		}
+	}
+	return &handler{Handler: h.Handler.WithAttrs(attrs), bound: bound, grouped: h.grouped}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{Handler: h.Handler.WithGroup(name), bound: h.bound, grouped: true}
+}
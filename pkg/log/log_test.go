@@ -0,0 +1,100 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestHandler(t *testing.T) {
+	traceID, err := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	require.NoError(t, err)
+	spanID, err := trace.SpanIDFromHex("00f067aa0ba902b7")
+	require.NoError(t, err)
+	spanCtx := trace.ContextWithSpanContext(context.Background(),
+		trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+
+	testCases := []struct {
+		name           string
+		ctx            context.Context
+		log            func(ctx context.Context, logger *slog.Logger)
+		expectedRecord map[string]any
+	}{
+		{
+			name: "Should add scan job ID, digest and trace ID of context",
+			ctx:  WithDigest(WithScanJobID(spanCtx, "job:123"), "sha256:917f"),
+			log: func(ctx context.Context, logger *slog.Logger) {
+				logger.WarnContext(ctx, "Error while indexing vulnerabilities", slog.String("err", "timeout"))
+			},
+			expectedRecord: map[string]any{
+				"level":       "WARN",
+				"msg":         "Error while indexing vulnerabilities",
+				"err":         "timeout",
+				"scan_job_id": "job:123",
+				"digest":      "sha256:917f",
+				"trace_id":    "4bf92f3577b34da6a3ce929d0e0e4736",
+			},
+		},
+		{
+			name: "Should not add attributes without context values",
+			ctx:  context.Background(),
+			log: func(ctx context.Context, logger *slog.Logger) {
+				logger.InfoContext(ctx, "Job queue shutdown started")
+			},
+			expectedRecord: map[string]any{
+				"level": "INFO",
+				"msg":   "Job queue shutdown started",
+			},
+		},
+		{
+			name: "Should not duplicate attributes of record",
+			ctx:  WithScanJobID(context.Background(), "job:123"),
+			log: func(ctx context.Context, logger *slog.Logger) {
+				logger.InfoContext(ctx, "Recovering scan job", slog.String("scan_job_id", "job:456"))
+			},
+			expectedRecord: map[string]any{
+				"level":       "INFO",
+				"msg":         "Recovering scan job",
+				"scan_job_id": "job:456",
+			},
+		},
+		{
+			name: "Should not duplicate attributes bound to logger",
+			ctx:  WithDigest(WithScanJobID(context.Background(), "job:123"), "sha256:917f"),
+			log: func(ctx context.Context, logger *slog.Logger) {
+				logger.With(slog.String("digest", "sha256:0a1b")).InfoContext(ctx, "Saving SBOM")
+			},
+			expectedRecord: map[string]any{
+				"level":       "INFO",
+				"msg":         "Saving SBOM",
+				"scan_job_id": "job:123",
+				"digest":      "sha256:0a1b",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(NewHandler(slog.NewJSONHandler(&buf, &slog.HandlerOptions{
+				ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+					if a.Key == slog.TimeKey {
+						return slog.Attr{}
+					}
+					return a
+				},
+			})))
+
+			tc.log(tc.ctx, logger)
+
+			var record map[string]any
+			require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+			assert.Equal(t, tc.expectedRecord, record)
+		})
+	}
+}
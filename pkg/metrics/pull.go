@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	pullBytesDesc = prometheus.NewDesc(
+		"scanner_registry_pull_bytes_total",
+		"Total number of bytes pulled from registries through the bandwidth-limiting proxy.",
+		nil, nil,
+	)
+	pullThroughputDesc = prometheus.NewDesc(
+		"scanner_registry_pull_throughput_bytes_per_second",
+		"Number of bytes pulled from registries during the last second.",
+		nil, nil,
+	)
+	pullBandwidthLimitDesc = prometheus.NewDesc(
+		"scanner_registry_pull_bandwidth_limit_bytes_per_second",
+		"Configured maximum aggregate bandwidth of image pulls.",
+		nil, nil,
+	)
+)
+
+// PullMeter wraps the Transferred and Throughput methods.
+type PullMeter interface {
+	Transferred() int64
+	Throughput() int64
+}
+
+type pullCollector struct {
+	meter PullMeter
+	limit int64
+}
+
+// NewPullCollector constructs a prometheus.Collector reporting the bandwidth used to pull images,
+// as measured by the given PullMeter, against the configured limit in bytes per second.
+func NewPullCollector(meter PullMeter, limit int64) prometheus.Collector {
+	return &pullCollector{
+		meter: meter,
+		limit: limit,
+	}
+}
+
+func (c *pullCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- pullBytesDesc
+	ch <- pullThroughputDesc
+	ch <- pullBandwidthLimitDesc
+}
+
+func (c *pullCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(pullBytesDesc, prometheus.CounterValue, float64(c.meter.Transferred()))
+	ch <- prometheus.MustNewConstMetric(pullThroughputDesc, prometheus.GaugeValue, float64(c.meter.Throughput()))
+	ch <- prometheus.MustNewConstMetric(pullBandwidthLimitDesc, prometheus.GaugeValue, float64(c.limit))
+}
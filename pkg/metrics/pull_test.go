@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakePullMeter struct {
+	transferred int64
+	throughput  int64
+}
+
+func (m *fakePullMeter) Transferred() int64 {
+	return m.transferred
+}
+
+func (m *fakePullMeter) Throughput() int64 {
+	return m.throughput
+}
+
+func TestPullCollector(t *testing.T) {
+	collector := NewPullCollector(&fakePullMeter{transferred: 52428800, throughput: 1048576}, 2097152)
+
+	err := testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP scanner_registry_pull_bandwidth_limit_bytes_per_second Configured maximum aggregate bandwidth of image pulls.
+# TYPE scanner_registry_pull_bandwidth_limit_bytes_per_second gauge
+scanner_registry_pull_bandwidth_limit_bytes_per_second 2.097152e+06
+# HELP scanner_registry_pull_bytes_total Total number of bytes pulled from registries through the bandwidth-limiting proxy.
+# TYPE scanner_registry_pull_bytes_total counter
+scanner_registry_pull_bytes_total 5.24288e+07
+# HELP scanner_registry_pull_throughput_bytes_per_second Number of bytes pulled from registries during the last second.
+# TYPE scanner_registry_pull_throughput_bytes_per_second gauge
+scanner_registry_pull_throughput_bytes_per_second 1.048576e+06
+`))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+)
+
+var (
+	queueUpDesc = prometheus.NewDesc(
+		"scanner_queue_up",
+		"Whether the statistics of the job queue could be retrieved (1) or not (0).",
+		nil, nil,
+	)
+	queueBacklogDesc = prometheus.NewDesc(
+		"scanner_queue_backlog_scan_jobs",
+		"Number of enqueued scan jobs not yet picked up by a worker.",
+		nil, nil,
+	)
+)
+
+// QueueStatser wraps the Stats method.
+type QueueStatser interface {
+	Stats(ctx context.Context) (job.QueueStats, error)
+}
+
+// queueCollector collects the depth of the job queue on each scrape. As the backlog is shared, all replicas
+// report the same value.
+type queueCollector struct {
+	queue QueueStatser
+}
+
+// NewQueueCollector constructs a prometheus.Collector reporting the depth of the job queue, as returned by
+// the given QueueStatser.
+func NewQueueCollector(queue QueueStatser) prometheus.Collector {
+	return &queueCollector{queue: queue}
+}
+
+func (c *queueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueUpDesc
+	ch <- queueBacklogDesc
+}
+
+func (c *queueCollector) Collect(ch chan<- prometheus.Metric) {
+	stats, err := c.queue.Stats(context.Background())
+	if err != nil {
+		slog.Warn("Error while getting job queue stats", slog.String("err", err.Error()))
+		ch <- prometheus.MustNewConstMetric(queueUpDesc, prometheus.GaugeValue, 0)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(queueUpDesc, prometheus.GaugeValue, 1)
+	ch <- prometheus.MustNewConstMetric(queueBacklogDesc, prometheus.GaugeValue, float64(stats.Backlog))
+}
@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+)
+
+type fakeQueue struct {
+	stats job.QueueStats
+	err   error
+}
+
+func (q *fakeQueue) Stats(_ context.Context) (job.QueueStats, error) {
+	return q.stats, q.err
+}
+
+func TestQueueCollector(t *testing.T) {
+	t.Run("Should report backlog of job queue", func(t *testing.T) {
+		collector := NewQueueCollector(&fakeQueue{stats: job.QueueStats{Backlog: 7}})
+
+		err := testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP scanner_queue_backlog_scan_jobs Number of enqueued scan jobs not yet picked up by a worker.
+# TYPE scanner_queue_backlog_scan_jobs gauge
+scanner_queue_backlog_scan_jobs 7
+# HELP scanner_queue_up Whether the statistics of the job queue could be retrieved (1) or not (0).
+# TYPE scanner_queue_up gauge
+scanner_queue_up 1
+`))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Should report job queue down when stats cannot be retrieved", func(t *testing.T) {
+		collector := NewQueueCollector(&fakeQueue{err: errors.New("connection refused")})
+
+		err := testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP scanner_queue_up Whether the statistics of the job queue could be retrieved (1) or not (0).
+# TYPE scanner_queue_up gauge
+scanner_queue_up 0
+`))
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,26 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RegistryUnauthorized counts the scan jobs failed because the registry rejected the credentials sent by
+// Harbor, labelled by registry URL, so that rotations of robot account credentials going wrong are noticed.
+var RegistryUnauthorized = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "scanner_registry_unauthorized_total",
+	Help: "Total number of scan jobs failed because the registry rejected their credentials.",
+}, []string{"registry"})
+
+// NVDLookups counts the lookups of CVEs in the NVD API, labelled by whether they were served from the cache,
+// fetched or failed, so that rate limiting by the NVD is noticed.
+var NVDLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "scanner_nvd_lookups_total",
+	Help: "Total number of lookups of CVEs in the NVD API.",
+}, []string{"result"})
+
+// AuditEvents counts the scan job events handed to the audit sink, labelled by whether they were exported,
+// failed to be exported or dropped because the buffer was full, so that gaps in the audit trail are noticed.
+var AuditEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "scanner_audit_events_total",
+	Help: "Total number of scan job events handed to the audit sink.",
+}, []string{"result"})
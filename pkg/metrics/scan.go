@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Results of enqueued scan jobs.
+const (
+	ResultQueued     = "queued"
+	ResultReused     = "reused"
+	ResultFailedFast = "failed_fast"
+	ResultRejected   = "rejected"
+)
+
+// Results of completed scan jobs.
+const (
+	ResultSucceeded = "succeeded"
+	ResultFailed    = "failed"
+)
+
+// Reasons of retried scan jobs.
+const (
+	RetryStalled   = "stalled"
+	RetryRecovered = "recovered"
+)
+
+// scanDurationBuckets span scans served from the cache of Tunnel up to scans of huge images.
+var scanDurationBuckets = []float64{1, 5, 10, 30, 60, 120, 300, 600, 1200, 1800}
+
+// ScanJobsEnqueued counts the accepted scan requests, labelled by whether their scan jobs were queued, served the
+// report of a recently scanned digest, failed fast because their digest failed repeatedly, or were rejected because
+// the backlog was full.
+var ScanJobsEnqueued = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "scanner_scan_jobs_enqueued_total",
+	Help: "Total number of scan jobs enqueued.",
+}, []string{"result"})
+
+// ScanJobsStarted counts the scan jobs picked up by the workers of this replica.
+var ScanJobsStarted = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "scanner_scan_jobs_started_total",
+	Help: "Total number of scan jobs picked up by workers.",
+})
+
+// ScanJobsCompleted counts the scan jobs completed by the workers of this replica, labelled by whether they
+// succeeded or failed.
+var ScanJobsCompleted = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "scanner_scan_jobs_completed_total",
+	Help: "Total number of scan jobs completed by workers.",
+}, []string{"result"})
+
+// ScanJobsFailed counts the scan jobs failed by the workers of this replica, labelled by the class of their error,
+// i.e. auth, network, timeout, oom or scanner-internal, as returned by tunnel.ErrorClass.
+var ScanJobsFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "scanner_scan_jobs_failed_total",
+	Help: "Total number of scan jobs failed by workers, by class of error.",
+}, []string{"class"})
+
+// ScanJobsRetried counts the scan jobs queued again by this replica, labelled by whether they were taken over from
+// a worker which stopped sending heartbeats, or recovered from the store at startup.
+var ScanJobsRetried = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "scanner_scan_jobs_retried_total",
+	Help: "Total number of scan jobs queued again.",
+}, []string{"reason"})
+
+// ScanDuration is the histogram of the durations of the scan jobs completed by the workers of this replica,
+// labelled by whether they succeeded or failed.
+var ScanDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "scanner_scan_duration_seconds",
+	Help:    "Duration of scan jobs, from being picked up by a worker to being completed.",
+	Buckets: scanDurationBuckets,
+}, []string{"result"})
+
+// CleanScans counts the scan jobs completed by the workers of this replica whose complete reports list no
+// vulnerability.
+var CleanScans = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "scanner_clean_scans_total",
+	Help: "Total number of scan jobs which found no vulnerability.",
+})
+
+// ReportTransformDuration is the histogram of the durations of transforming Tunnel reports to Harbor reports.
+var ReportTransformDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+	Name:    "scanner_report_transform_duration_seconds",
+	Help:    "Duration of transforming Tunnel reports to Harbor reports.",
+	Buckets: []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+})
@@ -0,0 +1,25 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ShadowScans counts the shadow scans, labelled by result, i.e. compared, failed, or skipped because all
+// shadow scans were running.
+var ShadowScans = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "scanner_shadow_scans_total",
+	Help: "Total number of shadow scans by result.",
+}, []string{"result"})
+
+// ShadowFindings counts the findings of compared shadow scans, labelled by whether they were found by both
+// scanners, only by the primary scanner, or only by the secondary scanner.
+var ShadowFindings = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "scanner_shadow_findings_total",
+	Help: "Total number of findings of compared shadow scans by scanner.",
+}, []string{"found_by"})
+
+// ShadowSeverityDisagreements counts the findings of both scanners, which they rate with different severities.
+var ShadowSeverityDisagreements = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "scanner_shadow_severity_disagreements_total",
+	Help: "Total number of findings of both scanners rated with different severities.",
+})
@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/slo"
+)
+
+var (
+	sloUpDesc = prometheus.NewDesc(
+		"scanner_slo_up",
+		"Whether the status of the scan job SLO could be computed (1) or not (0).",
+		nil, nil,
+	)
+	sloObjectiveDesc = prometheus.NewDesc(
+		"scanner_slo_objective_ratio",
+		"Ratio of scan jobs which must finish within the SLO latency.",
+		nil, nil,
+	)
+	sloLatencyDesc = prometheus.NewDesc(
+		"scanner_slo_latency_seconds",
+		"Duration from being queued within which scan jobs must finish.",
+		nil, nil,
+	)
+	sloScanJobsDesc = prometheus.NewDesc(
+		"scanner_slo_scan_jobs",
+		"Number of scan jobs queued within the SLO window, which either finished within the SLO latency (good) or not (bad).",
+		[]string{"result"}, nil,
+	)
+	sloErrorBudgetRemainingDesc = prometheus.NewDesc(
+		"scanner_slo_error_budget_remaining_ratio",
+		"Ratio of the error budget of the SLO window not consumed yet.",
+		nil, nil,
+	)
+	sloBurnRateDesc = prometheus.NewDesc(
+		"scanner_slo_burn_rate",
+		"Rate at which the error budget is consumed over the window in seconds.",
+		[]string{"window"}, nil,
+	)
+	sloAlertingDesc = prometheus.NewDesc(
+		"scanner_slo_alerting",
+		"Whether the error budget burns faster than the threshold over both windows (1) or not (0).",
+		nil, nil,
+	)
+)
+
+// sloCollector collects the status of the scan job SLO on each scrape. As the status is computed from the store,
+// all replicas report the same values.
+type sloCollector struct {
+	tracker slo.Tracker
+}
+
+// NewSLOCollector constructs a prometheus.Collector reporting the status of the SLO tracked by the given Tracker.
+func NewSLOCollector(tracker slo.Tracker) prometheus.Collector {
+	return &sloCollector{tracker: tracker}
+}
+
+func (c *sloCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sloUpDesc
+	ch <- sloObjectiveDesc
+	ch <- sloLatencyDesc
+	ch <- sloScanJobsDesc
+	ch <- sloErrorBudgetRemainingDesc
+	ch <- sloBurnRateDesc
+	ch <- sloAlertingDesc
+}
+
+func (c *sloCollector) Collect(ch chan<- prometheus.Metric) {
+	status, err := c.tracker.Status(context.Background())
+	if err != nil {
+		slog.Warn("Error while computing SLO status", slog.String("err", err.Error()))
+		ch <- prometheus.MustNewConstMetric(sloUpDesc, prometheus.GaugeValue, 0)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(sloUpDesc, prometheus.GaugeValue, 1)
+	ch <- prometheus.MustNewConstMetric(sloObjectiveDesc, prometheus.GaugeValue, status.Objective)
+	ch <- prometheus.MustNewConstMetric(sloLatencyDesc, prometheus.GaugeValue, float64(status.LatencySeconds))
+	ch <- prometheus.MustNewConstMetric(sloScanJobsDesc, prometheus.GaugeValue, float64(status.Good), "good")
+	ch <- prometheus.MustNewConstMetric(sloScanJobsDesc, prometheus.GaugeValue, float64(status.Total-status.Good), "bad")
+	ch <- prometheus.MustNewConstMetric(sloErrorBudgetRemainingDesc, prometheus.GaugeValue, status.ErrorBudgetRemaining)
+	for _, burnRate := range status.BurnRates {
+		ch <- prometheus.MustNewConstMetric(sloBurnRateDesc, prometheus.GaugeValue, burnRate.Rate,
+			strconv.FormatInt(burnRate.WindowSeconds, 10))
+	}
+	alerting := 0.0
+	if status.Alerting {
+		alerting = 1
+	}
+	ch <- prometheus.MustNewConstMetric(sloAlertingDesc, prometheus.GaugeValue, alerting)
+}
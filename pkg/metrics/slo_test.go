@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/slo"
+)
+
+type fakeTracker struct {
+	status slo.Status
+	err    error
+}
+
+func (t *fakeTracker) Status(_ context.Context) (slo.Status, error) {
+	return t.status, t.err
+}
+
+func TestSLOCollector(t *testing.T) {
+	t.Run("Should report status of SLO", func(t *testing.T) {
+		collector := NewSLOCollector(&fakeTracker{status: slo.Status{
+			Objective:            0.95,
+			LatencySeconds:       600,
+			WindowSeconds:        3600,
+			Total:                20,
+			Good:                 18,
+			Compliance:           0.9,
+			ErrorBudgetRemaining: -1,
+			BurnRates: []slo.BurnRate{
+				{WindowSeconds: 3600, Rate: 2},
+				{WindowSeconds: 300, Rate: 20},
+			},
+		}})
+
+		err := testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP scanner_slo_alerting Whether the error budget burns faster than the threshold over both windows (1) or not (0).
+# TYPE scanner_slo_alerting gauge
+scanner_slo_alerting 0
+# HELP scanner_slo_burn_rate Rate at which the error budget is consumed over the window in seconds.
+# TYPE scanner_slo_burn_rate gauge
+scanner_slo_burn_rate{window="300"} 20
+scanner_slo_burn_rate{window="3600"} 2
+# HELP scanner_slo_error_budget_remaining_ratio Ratio of the error budget of the SLO window not consumed yet.
+# TYPE scanner_slo_error_budget_remaining_ratio gauge
+scanner_slo_error_budget_remaining_ratio -1
+# HELP scanner_slo_latency_seconds Duration from being queued within which scan jobs must finish.
+# TYPE scanner_slo_latency_seconds gauge
+scanner_slo_latency_seconds 600
+# HELP scanner_slo_objective_ratio Ratio of scan jobs which must finish within the SLO latency.
+# TYPE scanner_slo_objective_ratio gauge
+scanner_slo_objective_ratio 0.95
+# HELP scanner_slo_scan_jobs Number of scan jobs queued within the SLO window, which either finished within the SLO latency (good) or not (bad).
+# TYPE scanner_slo_scan_jobs gauge
+scanner_slo_scan_jobs{result="bad"} 2
+scanner_slo_scan_jobs{result="good"} 18
+# HELP scanner_slo_up Whether the status of the scan job SLO could be computed (1) or not (0).
+# TYPE scanner_slo_up gauge
+scanner_slo_up 1
+`))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Should report SLO down when status cannot be computed", func(t *testing.T) {
+		collector := NewSLOCollector(&fakeTracker{err: errors.New("redis is down")})
+
+		err := testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP scanner_slo_up Whether the status of the scan job SLO could be computed (1) or not (0).
+# TYPE scanner_slo_up gauge
+scanner_slo_up 0
+`))
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/clock"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+)
+
+// StoreOperationDuration is the histogram of the durations of the operations of the store, labelled by operation
+// and by whether they succeeded, regardless of the backend.
+var StoreOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "scanner_store_operation_duration_seconds",
+	Help:    "Duration of store operations.",
+	Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+}, []string{"operation", "status"})
+
+// Option customizes the store constructed with NewStore, and the collector constructed with NewVulnDBCollector.
+type Option func(o *options)
+
+type options struct {
+	clock    clock.Clock
+	versions *tunnel.VersionCache
+}
+
+// WithClock times store operations, and ages the vulnerability database, with the given Clock rather than the
+// system time.
+func WithClock(c clock.Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+// WithVersionCache has the collector retrieve the version of the vulnerability database from the given
+// VersionCache, e.g. the one shared with the readiness probes.
+func WithVersionCache(cache *tunnel.VersionCache) Option {
+	return func(o *options) {
+		o.versions = cache
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{clock: clock.System, versions: tunnel.NewVersionCache(tunnel.VersionCacheTTL)}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+type store struct {
+	persistence.Store
+	duration *prometheus.HistogramVec
+	clock    clock.Clock
+}
+
+// NewStore wraps the given Store, recording the durations of its operations in StoreOperationDuration.
+func NewStore(delegate persistence.Store, opts ...Option) persistence.Store {
+	return &store{Store: delegate, duration: StoreOperationDuration, clock: newOptions(opts).clock}
+}
+
+// observe starts timing the given operation, and returns the function recording its duration once it returned
+// the given error.
+func (s *store) observe(operation string) func(err *error) {
+	started := s.clock.Now()
+	return func(err *error) {
+		status := "ok"
+		if *err != nil {
+			status = "error"
+		}
+		s.duration.WithLabelValues(operation, status).Observe(clock.Since(s.clock, started).Seconds())
+	}
+}
+
+func (s *store) Create(ctx context.Context, scanJob job.ScanJob) (err error) {
+	defer s.observe("create")(&err)
+	return s.Store.Create(ctx, scanJob)
+}
+
+func (s *store) Get(ctx context.Context, scanJobID string) (scanJob *job.ScanJob, err error) {
+	defer s.observe("get")(&err)
+	return s.Store.Get(ctx, scanJobID)
+}
+
+func (s *store) UpdateStatus(ctx context.Context, scanJobID string, newStatus job.ScanJobStatus, error ...string) (err error) {
+	defer s.observe("update_status")(&err)
+	return s.Store.UpdateStatus(ctx, scanJobID, newStatus, error...)
+}
+
+func (s *store) UpdateReport(ctx context.Context, scanJobID string, report harbor.ScanReport) (err error) {
+	defer s.observe("update_report")(&err)
+	return s.Store.UpdateReport(ctx, scanJobID, report)
+}
+
+func (s *store) Replace(ctx context.Context, scanJob job.ScanJob) (err error) {
+	defer s.observe("replace")(&err)
+	return s.Store.Replace(ctx, scanJob)
+}
+
+func (s *store) UpdateSBOM(ctx context.Context, scanJobID string, sbom harbor.SBOMReport) (err error) {
+	defer s.observe("update_sbom")(&err)
+	return s.Store.UpdateSBOM(ctx, scanJobID, sbom)
+}
+
+func (s *store) FindByStatus(ctx context.Context, statuses ...job.ScanJobStatus) (scanJobs []job.ScanJob, err error) {
+	defer s.observe("find_by_status")(&err)
+	return s.Store.FindByStatus(ctx, statuses...)
+}
+
+func (s *store) Extend(ctx context.Context, scanJobID string, ttl time.Duration) (err error) {
+	defer s.observe("extend")(&err)
+	return s.Store.Extend(ctx, scanJobID, ttl)
+}
+
+func (s *store) ListSummaries(ctx context.Context, limit int) (summaries []job.Summary, err error) {
+	defer s.observe("list_summaries")(&err)
+	return s.Store.ListSummaries(ctx, limit)
+}
+
+func (s *store) List(ctx context.Context, options persistence.ListOptions) (entries []job.Entry, err error) {
+	defer s.observe("list")(&err)
+	return s.Store.List(ctx, options)
+}
@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/clock"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/mock"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	delegate := mock.NewStore()
+	delegate.On("Create", ctx, job.ScanJob{ID: "job:123"}).Return(nil)
+	delegate.On("Get", ctx, "job:456").Return((*job.ScanJob)(nil), errors.New("connection refused"))
+
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "scanner_store_operation_duration_seconds",
+	}, []string{"operation", "status"})
+	s := &store{Store: delegate, duration: duration, clock: clock.System}
+
+	assert.NoError(t, s.Create(ctx, job.ScanJob{ID: "job:123"}))
+	_, err := s.Get(ctx, "job:456")
+	assert.EqualError(t, err, "connection refused")
+
+	assert.Equal(t, 2, testutil.CollectAndCount(duration))
+	assert.True(t, duration.DeleteLabelValues("create", "ok"))
+	assert.True(t, duration.DeleteLabelValues("get", "error"))
+	delegate.AssertExpectations(t)
+}
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+)
+
+var (
+	vulnDBUpDesc = prometheus.NewDesc(
+		"scanner_vulndb_up",
+		"Whether the vulnerability database metadata could be retrieved from the scanner (1) or not (0).",
+		nil, nil,
+	)
+	vulnDBAgeDesc = prometheus.NewDesc(
+		"scanner_vulndb_age_seconds",
+		"Time elapsed since the vulnerability database was last updated.",
+		nil, nil,
+	)
+	vulnDBUpdatedAtDesc = prometheus.NewDesc(
+		"scanner_vulndb_last_update_timestamp_seconds",
+		"Unix time of the last successful vulnerability database update.",
+		nil, nil,
+	)
+	vulnDBNextUpdateDesc = prometheus.NewDesc(
+		"scanner_vulndb_next_update_timestamp_seconds",
+		"Unix time after which the vulnerability database is due for an update.",
+		nil, nil,
+	)
+)
+
+// vulnDBCollector collects the freshness of the vulnerability database on each scrape, which
+// makes it easy to alert on scanners serving stale results. The version of the vulnerability database is
+// cached, so that scrapes do not exec Tunnel each time.
+type vulnDBCollector struct {
+	wrapper  tunnel.Wrapper
+	versions *tunnel.VersionCache
+	now      func() time.Time
+}
+
+// NewVulnDBCollector constructs a prometheus.Collector reporting the state of the vulnerability
+// database used by the given Wrapper.
+func NewVulnDBCollector(wrapper tunnel.Wrapper, opts ...Option) prometheus.Collector {
+	o := newOptions(opts)
+	return &vulnDBCollector{
+		wrapper:  wrapper,
+		versions: o.versions,
+		now:      o.clock.Now,
+	}
+}
+
+func (c *vulnDBCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- vulnDBUpDesc
+	ch <- vulnDBAgeDesc
+	ch <- vulnDBUpdatedAtDesc
+	ch <- vulnDBNextUpdateDesc
+}
+
+func (c *vulnDBCollector) Collect(ch chan<- prometheus.Metric) {
+	now := c.now()
+	vi, err := c.versions.Get(now, c.wrapper.GetVersion)
+	if err != nil {
+		slog.Warn("Error while retrieving vulnerability DB version", slog.String("err", err.Error()))
+	}
+	if err != nil || vi.VulnerabilityDB == nil {
+		ch <- prometheus.MustNewConstMetric(vulnDBUpDesc, prometheus.GaugeValue, 0)
+		return
+	}
+
+	db := vi.VulnerabilityDB
+	ch <- prometheus.MustNewConstMetric(vulnDBUpDesc, prometheus.GaugeValue, 1)
+	ch <- prometheus.MustNewConstMetric(vulnDBAgeDesc, prometheus.GaugeValue, db.Age(now).Seconds())
+	ch <- prometheus.MustNewConstMetric(vulnDBUpdatedAtDesc, prometheus.GaugeValue, float64(db.UpdatedAt.Unix()))
+	ch <- prometheus.MustNewConstMetric(vulnDBNextUpdateDesc, prometheus.GaugeValue, float64(db.NextUpdate.Unix()))
+}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+)
+
+func TestVulnDBCollector(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	t.Run("Should report age of vulnerability DB", func(t *testing.T) {
+		wrapper := tunnel.NewMockWrapper()
+		wrapper.On("GetVersion").Return(tunnel.VersionInfo{
+			VulnerabilityDB: &tunnel.Metadata{
+				UpdatedAt:  now.Add(-90 * time.Minute),
+				NextUpdate: now.Add(-30 * time.Minute),
+			},
+		}, nil)
+
+		collector := &vulnDBCollector{wrapper: wrapper, versions: tunnel.NewVersionCache(time.Minute), now: func() time.Time { return now }}
+
+		err := testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP scanner_vulndb_age_seconds Time elapsed since the vulnerability database was last updated.
+# TYPE scanner_vulndb_age_seconds gauge
+scanner_vulndb_age_seconds 5400
+# HELP scanner_vulndb_last_update_timestamp_seconds Unix time of the last successful vulnerability database update.
+# TYPE scanner_vulndb_last_update_timestamp_seconds gauge
+scanner_vulndb_last_update_timestamp_seconds 1.6999946e+09
+# HELP scanner_vulndb_next_update_timestamp_seconds Unix time after which the vulnerability database is due for an update.
+# TYPE scanner_vulndb_next_update_timestamp_seconds gauge
+scanner_vulndb_next_update_timestamp_seconds 1.6999982e+09
+# HELP scanner_vulndb_up Whether the vulnerability database metadata could be retrieved from the scanner (1) or not (0).
+# TYPE scanner_vulndb_up gauge
+scanner_vulndb_up 1
+`))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Should report vulnerability DB down when version cannot be retrieved", func(t *testing.T) {
+		wrapper := tunnel.NewMockWrapper()
+		wrapper.On("GetVersion").Return(tunnel.VersionInfo{}, errors.New("tunnel not found"))
+
+		collector := &vulnDBCollector{wrapper: wrapper, versions: tunnel.NewVersionCache(time.Minute), now: func() time.Time { return now }}
+
+		err := testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP scanner_vulndb_up Whether the vulnerability database metadata could be retrieved from the scanner (1) or not (0).
+# TYPE scanner_vulndb_up gauge
+scanner_vulndb_up 0
+`))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Should not retrieve cached version of vulnerability DB on each scrape", func(t *testing.T) {
+		wrapper := tunnel.NewMockWrapper()
+		wrapper.On("GetVersion").Return(tunnel.VersionInfo{
+			VulnerabilityDB: &tunnel.Metadata{UpdatedAt: now.Add(-time.Hour), NextUpdate: now.Add(11 * time.Hour)},
+		}, nil).Once()
+
+		collector := &vulnDBCollector{wrapper: wrapper, versions: tunnel.NewVersionCache(time.Minute), now: func() time.Time { return now }}
+
+		assert.Equal(t, 4, testutil.CollectAndCount(collector))
+		assert.Equal(t, 4, testutil.CollectAndCount(collector))
+		wrapper.AssertNumberOfCalls(t, "GetVersion", 1)
+	})
+}
@@ -0,0 +1,25 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type DigestIndex struct {
+	mock.Mock
+}
+
+func NewDigestIndex() *DigestIndex {
+	return &DigestIndex{}
+}
+
+func (i *DigestIndex) Record(ctx context.Context, digest, scanJobID string) error {
+	args := i.Called(ctx, digest, scanJobID)
+	return args.Error(0)
+}
+
+func (i *DigestIndex) Find(ctx context.Context, digest string) (string, error) {
+	args := i.Called(ctx, digest)
+	return args.String(0), args.Error(1)
+}
@@ -19,3 +19,13 @@ func (em *Enqueuer) Enqueue(ctx context.Context, request harbor.ScanRequest) (jo
 	args := em.Called(ctx, request)
 	return args.Get(0).(job.ScanJob), args.Error(1)
 }
+
+func (em *Enqueuer) Position(ctx context.Context, scanJobID string) (job.QueuePosition, error) {
+	args := em.Called(ctx, scanJobID)
+	return args.Get(0).(job.QueuePosition), args.Error(1)
+}
+
+func (em *Enqueuer) Stats(ctx context.Context) (job.QueueStats, error) {
+	args := em.Called(ctx)
+	return args.Get(0).(job.QueueStats), args.Error(1)
+}
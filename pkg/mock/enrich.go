@@ -0,0 +1,21 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/stretchr/testify/mock"
+)
+
+type Enricher struct {
+	mock.Mock
+}
+
+func NewEnricher() *Enricher {
+	return &Enricher{}
+}
+
+func (e *Enricher) Enrich(ctx context.Context, report harbor.ScanReport) (harbor.ScanReport, error) {
+	args := e.Called(ctx, report)
+	return args.Get(0).(harbor.ScanReport), args.Error(1)
+}
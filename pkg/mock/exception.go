@@ -0,0 +1,31 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/policy"
+	"github.com/stretchr/testify/mock"
+)
+
+type ExceptionStore struct {
+	mock.Mock
+}
+
+func NewExceptionStore() *ExceptionStore {
+	return &ExceptionStore{}
+}
+
+func (s *ExceptionStore) Save(ctx context.Context, exception policy.Exception) error {
+	args := s.Called(ctx, exception)
+	return args.Error(0)
+}
+
+func (s *ExceptionStore) Get(ctx context.Context, id string) (*policy.Exception, error) {
+	args := s.Called(ctx, id)
+	return args.Get(0).(*policy.Exception), args.Error(1)
+}
+
+func (s *ExceptionStore) List(ctx context.Context) ([]policy.Exception, error) {
+	args := s.Called(ctx)
+	return args.Get(0).([]policy.Exception), args.Error(1)
+}
@@ -9,6 +9,9 @@ import (
 
 const Anything = mock.Anything
 
+// MatchedBy matches the arguments for which the given function returns true.
+var MatchedBy = mock.MatchedBy
+
 // Expectation represents an expectation of a method being called and its return values.
 type Expectation struct {
 	Method     string
@@ -43,6 +46,61 @@ func ApplyExpectations(t *testing.T, mock interface{}, expectations ...*Expectat
 		for _, e := range expectations {
 			m.On(e.Method, e.Args...).Return(e.ReturnArgs...)
 		}
+	case *VulnerabilityIndex:
+		m := mock.(*VulnerabilityIndex)
+		for _, e := range expectations {
+			m.On(e.Method, e.Args...).Return(e.ReturnArgs...)
+		}
+	case *DigestIndex:
+		m := mock.(*DigestIndex)
+		for _, e := range expectations {
+			m.On(e.Method, e.Args...).Return(e.ReturnArgs...)
+		}
+	case *FailureIndex:
+		m := mock.(*FailureIndex)
+		for _, e := range expectations {
+			m.On(e.Method, e.Args...).Return(e.ReturnArgs...)
+		}
+	case *SBOMStore:
+		m := mock.(*SBOMStore)
+		for _, e := range expectations {
+			m.On(e.Method, e.Args...).Return(e.ReturnArgs...)
+		}
+	case *LifetimeStore:
+		m := mock.(*LifetimeStore)
+		for _, e := range expectations {
+			m.On(e.Method, e.Args...).Return(e.ReturnArgs...)
+		}
+	case *ExceptionStore:
+		m := mock.(*ExceptionStore)
+		for _, e := range expectations {
+			m.On(e.Method, e.Args...).Return(e.ReturnArgs...)
+		}
+	case *SBOMFetcher:
+		m := mock.(*SBOMFetcher)
+		for _, e := range expectations {
+			m.On(e.Method, e.Args...).Return(e.ReturnArgs...)
+		}
+	case *CredentialsRefresher:
+		m := mock.(*CredentialsRefresher)
+		for _, e := range expectations {
+			m.On(e.Method, e.Args...).Return(e.ReturnArgs...)
+		}
+	case *Comparator:
+		m := mock.(*Comparator)
+		for _, e := range expectations {
+			m.On(e.Method, e.Args...).Return(e.ReturnArgs...)
+		}
+	case *Enricher:
+		m := mock.(*Enricher)
+		for _, e := range expectations {
+			m.On(e.Method, e.Args...).Return(e.ReturnArgs...)
+		}
+	case *PolicyEvaluator:
+		m := mock.(*PolicyEvaluator)
+		for _, e := range expectations {
+			m.On(e.Method, e.Args...).Return(e.ReturnArgs...)
+		}
 	default:
 		t.Fatalf("Unrecognized mock type: %T!", v)
 	}
@@ -0,0 +1,25 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type FailureIndex struct {
+	mock.Mock
+}
+
+func NewFailureIndex() *FailureIndex {
+	return &FailureIndex{}
+}
+
+func (i *FailureIndex) Record(ctx context.Context, digest, message string) error {
+	args := i.Called(ctx, digest, message)
+	return args.Error(0)
+}
+
+func (i *FailureIndex) Find(ctx context.Context, digest string) (string, error) {
+	args := i.Called(ctx, digest)
+	return args.String(0), args.Error(1)
+}
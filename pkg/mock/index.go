@@ -0,0 +1,32 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	"github.com/stretchr/testify/mock"
+)
+
+type VulnerabilityIndex struct {
+	mock.Mock
+}
+
+func NewVulnerabilityIndex() *VulnerabilityIndex {
+	return &VulnerabilityIndex{}
+}
+
+func (i *VulnerabilityIndex) Index(ctx context.Context, registry string, report harbor.ScanReport) error {
+	args := i.Called(ctx, registry, report)
+	return args.Error(0)
+}
+
+func (i *VulnerabilityIndex) FindArtifacts(ctx context.Context, vulnerabilityID string) ([]persistence.AffectedArtifact, error) {
+	args := i.Called(ctx, vulnerabilityID)
+	return args.Get(0).([]persistence.AffectedArtifact), args.Error(1)
+}
+
+func (i *VulnerabilityIndex) FindVulnerabilities(ctx context.Context, registry string, artifact harbor.Artifact) ([]string, error) {
+	args := i.Called(ctx, registry, artifact)
+	return args.Get(0).([]string), args.Error(1)
+}
@@ -0,0 +1,31 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	"github.com/stretchr/testify/mock"
+)
+
+type LifetimeStore struct {
+	mock.Mock
+}
+
+func NewLifetimeStore() *LifetimeStore {
+	return &LifetimeStore{}
+}
+
+func (s *LifetimeStore) Get(ctx context.Context, digest string) (map[string]persistence.VulnerabilityLifetime, error) {
+	args := s.Called(ctx, digest)
+	return args.Get(0).(map[string]persistence.VulnerabilityLifetime), args.Error(1)
+}
+
+func (s *LifetimeStore) Save(ctx context.Context, digest string, lifetimes map[string]persistence.VulnerabilityLifetime) error {
+	args := s.Called(ctx, digest, lifetimes)
+	return args.Error(0)
+}
+
+func (s *LifetimeStore) Summarize(ctx context.Context) (persistence.LifetimeSummary, error) {
+	args := s.Called(ctx)
+	return args.Get(0).(persistence.LifetimeSummary), args.Error(1)
+}
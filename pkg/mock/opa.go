@@ -0,0 +1,23 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+	"github.com/stretchr/testify/mock"
+)
+
+type PolicyEvaluator struct {
+	mock.Mock
+}
+
+func NewPolicyEvaluator() *PolicyEvaluator {
+	return &PolicyEvaluator{}
+}
+
+func (e *PolicyEvaluator) Evaluate(ctx context.Context, request harbor.ScanRequest, tunnelReport tunnel.Report,
+	report harbor.ScanReport) (harbor.ScanReport, error) {
+	args := e.Called(ctx, request, tunnelReport, report)
+	return args.Get(0).(harbor.ScanReport), args.Error(1)
+}
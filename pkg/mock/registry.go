@@ -0,0 +1,34 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/stretchr/testify/mock"
+)
+
+type SBOMFetcher struct {
+	mock.Mock
+}
+
+func NewSBOMFetcher() *SBOMFetcher {
+	return &SBOMFetcher{}
+}
+
+func (f *SBOMFetcher) Fetch(ctx context.Context, req harbor.ScanRequest) ([]byte, error) {
+	args := f.Called(ctx, req)
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+type CredentialsRefresher struct {
+	mock.Mock
+}
+
+func NewCredentialsRefresher() *CredentialsRefresher {
+	return &CredentialsRefresher{}
+}
+
+func (r *CredentialsRefresher) Refresh(ctx context.Context, scanJobID string, req harbor.ScanRequest) error {
+	args := r.Called(ctx, scanJobID, req)
+	return args.Error(0)
+}
@@ -0,0 +1,32 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	"github.com/stretchr/testify/mock"
+)
+
+type SBOMStore struct {
+	mock.Mock
+}
+
+func NewSBOMStore() *SBOMStore {
+	return &SBOMStore{}
+}
+
+func (s *SBOMStore) Save(ctx context.Context, registry string, artifact harbor.Artifact, sbom []byte) error {
+	args := s.Called(ctx, registry, artifact, sbom)
+	return args.Error(0)
+}
+
+func (s *SBOMStore) Get(ctx context.Context, digest string) ([]byte, error) {
+	args := s.Called(ctx, digest)
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (s *SBOMStore) FindArtifacts(ctx context.Context) ([]persistence.SBOMArtifact, error) {
+	args := s.Called(ctx)
+	return args.Get(0).([]persistence.SBOMArtifact), args.Error(1)
+}
@@ -0,0 +1,18 @@
+package mock
+
+import (
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+	"github.com/stretchr/testify/mock"
+)
+
+type Comparator struct {
+	mock.Mock
+}
+
+func NewComparator() *Comparator {
+	return &Comparator{}
+}
+
+func (c *Comparator) Compare(imageRef tunnel.ImageRef, primary tunnel.Report) {
+	c.Called(imageRef, primary)
+}
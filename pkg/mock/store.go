@@ -2,8 +2,11 @@ package mock
 
 import (
 	"context"
+	"time"
+
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -34,3 +37,33 @@ func (s *Store) UpdateReport(ctx context.Context, scanJobID string, report harbo
 	args := s.Called(ctx, scanJobID, report)
 	return args.Error(0)
 }
+
+func (s *Store) Replace(ctx context.Context, scanJob job.ScanJob) error {
+	args := s.Called(ctx, scanJob)
+	return args.Error(0)
+}
+
+func (s *Store) UpdateSBOM(ctx context.Context, scanJobID string, sbom harbor.SBOMReport) error {
+	args := s.Called(ctx, scanJobID, sbom)
+	return args.Error(0)
+}
+
+func (s *Store) FindByStatus(ctx context.Context, statuses ...job.ScanJobStatus) ([]job.ScanJob, error) {
+	args := s.Called(ctx, statuses)
+	return args.Get(0).([]job.ScanJob), args.Error(1)
+}
+
+func (s *Store) Extend(ctx context.Context, scanJobID string, ttl time.Duration) error {
+	args := s.Called(ctx, scanJobID, ttl)
+	return args.Error(0)
+}
+
+func (s *Store) ListSummaries(ctx context.Context, limit int) ([]job.Summary, error) {
+	args := s.Called(ctx, limit)
+	return args.Get(0).([]job.Summary), args.Error(1)
+}
+
+func (s *Store) List(ctx context.Context, options persistence.ListOptions) ([]job.Entry, error) {
+	args := s.Called(ctx, options)
+	return args.Get(0).([]job.Entry), args.Error(1)
+}
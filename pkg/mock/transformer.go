@@ -18,3 +18,8 @@ func (t *Transformer) Transform(artifact harbor.Artifact, source []tunnel.Vulner
 	args := t.Called(artifact, source)
 	return args.Get(0).(harbor.ScanReport)
 }
+
+func (t *Transformer) TransformSBOM(artifact harbor.Artifact, mediaType string, sbom []byte) harbor.SBOMReport {
+	args := t.Called(artifact, mediaType, sbom)
+	return args.Get(0).(harbor.SBOMReport)
+}
@@ -0,0 +1,137 @@
+// Package notify posts human-readable summaries of scan reports to chat services, i.e. Slack and Microsoft Teams,
+// so that teams learn about severe vulnerabilities of their artifacts without polling Harbor.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/httpx"
+)
+
+// Summary summarizes a scan report for humans.
+type Summary struct {
+	ScanJobID string
+	// Registry is the URL of the registry the artifact was pulled from.
+	Registry string
+	Artifact harbor.Artifact
+	// Severity is the overall severity of the report, i.e. the highest severity of its vulnerabilities.
+	Severity harbor.Severity
+	// Counts are the numbers of vulnerabilities keyed by severity.
+	Counts map[harbor.Severity]int
+	Total  int
+	// Fixable is the number of vulnerabilities with a fix version.
+	Fixable int
+}
+
+// Summarize returns the Summary of the given report of the given scan job of an artifact pulled from the given
+// registry.
+func Summarize(scanJobID, registry string, report harbor.ScanReport) Summary {
+	summary := Summary{
+		ScanJobID: scanJobID,
+		Registry:  registry,
+		Artifact:  report.Artifact,
+		Severity:  report.Severity,
+		Counts:    make(map[harbor.Severity]int),
+		Total:     len(report.Vulnerabilities),
+	}
+	for _, v := range report.Vulnerabilities {
+		summary.Counts[v.Severity]++
+		if v.FixVersion != "" {
+			summary.Fixable++
+		}
+	}
+	return summary
+}
+
+// Reference returns the reference of the artifact, prefixed with the host of the registry if it is known, e.g.
+// core.harbor.domain/library/nginx@sha256:6c3c.
+func (s Summary) Reference() string {
+	reference := s.Artifact.Repository + "@" + s.Artifact.Digest
+	if u, err := url.Parse(s.Registry); err == nil && u.Host != "" {
+		return u.Host + "/" + reference
+	}
+	return reference
+}
+
+// Title returns the headline of the notification, e.g. Critical vulnerabilities found in library/nginx.
+func (s Summary) Title() string {
+	return fmt.Sprintf("%s vulnerabilities found in %s", s.Severity, s.Artifact.Repository)
+}
+
+// CountsText returns the numbers of vulnerabilities of each severity found, the most severe first, e.g.
+// 2 Critical, 5 High.
+func (s Summary) CountsText() string {
+	var counts []string
+	for severity := harbor.SevCritical; severity >= harbor.SevUnknown; severity-- {
+		if count := s.Counts[severity]; count > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", count, severity))
+		}
+	}
+	return strings.Join(counts, ", ")
+}
+
+// FixableText returns the number of vulnerabilities with a fix version out of all vulnerabilities, e.g. 6 of 7.
+func (s Summary) FixableText() string {
+	return fmt.Sprintf("%d of %d", s.Fixable, s.Total)
+}
+
+// Notifier wraps the Notify method.
+// Notify posts the given summary of a scan report.
+type Notifier interface {
+	Notify(ctx context.Context, summary Summary) error
+}
+
+// webhookNotifier posts the messages rendered from the summaries of reports of at least the minimum severity to
+// the incoming webhook of a chat service.
+type webhookNotifier struct {
+	service     string
+	url         string
+	minSeverity harbor.Severity
+	render      func(summary Summary) interface{}
+	client      *http.Client
+}
+
+func newWebhookNotifier(service, url string, minSeverity harbor.Severity, render func(summary Summary) interface{}) Notifier {
+	return &webhookNotifier{
+		service:     service,
+		url:         url,
+		minSeverity: minSeverity,
+		render:      render,
+		client:      httpx.Client(strings.ToLower(service)+"_notifications", 30*time.Second),
+	}
+}
+
+func (n *webhookNotifier) Notify(ctx context.Context, summary Summary) error {
+	if summary.Total == 0 || summary.Severity < n.minSeverity {
+		return nil
+	}
+
+	body, err := json.Marshal(n.render(summary))
+	if err != nil {
+		return fmt.Errorf("marshalling %s notification: %w", n.service, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting %s notification: %w", n.service, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("posting %s notification: unexpected status %d", n.service, res.StatusCode)
+	}
+	return nil
+}
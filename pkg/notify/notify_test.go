@@ -0,0 +1,68 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummarize(t *testing.T) {
+	summary := Summarize("job:123", "https://core.harbor.domain", harbor.ScanReport{
+		Artifact: harbor.Artifact{Repository: "library/nginx", Digest: "sha256:6c3c"},
+		Severity: harbor.SevCritical,
+		Vulnerabilities: []harbor.VulnerabilityItem{
+			{ID: "CVE-2019-1549", Severity: harbor.SevCritical, FixVersion: "1.1.1d"},
+			{ID: "CVE-2019-1563", Severity: harbor.SevHigh, FixVersion: "1.1.1d"},
+			{ID: "CVE-2019-1547", Severity: harbor.SevHigh},
+			{ID: "CVE-2020-1967", Severity: harbor.SevLow},
+		},
+	})
+
+	assert.Equal(t, Summary{
+		ScanJobID: "job:123",
+		Registry:  "https://core.harbor.domain",
+		Artifact:  harbor.Artifact{Repository: "library/nginx", Digest: "sha256:6c3c"},
+		Severity:  harbor.SevCritical,
+		Counts:    map[harbor.Severity]int{harbor.SevCritical: 1, harbor.SevHigh: 2, harbor.SevLow: 1},
+		Total:     4,
+		Fixable:   2,
+	}, summary)
+	assert.Equal(t, "core.harbor.domain/library/nginx@sha256:6c3c", summary.Reference())
+	assert.Equal(t, "Critical vulnerabilities found in library/nginx", summary.Title())
+	assert.Equal(t, "1 Critical, 2 High, 1 Low", summary.CountsText())
+	assert.Equal(t, "2 of 4", summary.FixableText())
+}
+
+func TestWebhookNotifier_Notify(t *testing.T) {
+	summary := Summary{
+		ScanJobID: "job:123",
+		Artifact:  harbor.Artifact{Repository: "library/nginx", Digest: "sha256:6c3c"},
+		Severity:  harbor.SevMedium,
+		Counts:    map[harbor.Severity]int{harbor.SevMedium: 1},
+		Total:     1,
+	}
+
+	t.Run("Should not post summary of report below min severity", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+			t.Error("unexpected notification")
+		}))
+		defer server.Close()
+
+		err := NewSlackNotifier(server.URL, harbor.SevHigh).Notify(context.Background(), summary)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Should return error when webhook fails", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+			res.WriteHeader(http.StatusForbidden)
+		}))
+		defer server.Close()
+
+		err := NewTeamsNotifier(server.URL, harbor.SevLow).Notify(context.Background(), summary)
+		assert.EqualError(t, err, "posting Teams notification: unexpected status 403")
+	})
+}
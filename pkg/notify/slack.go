@@ -0,0 +1,56 @@
+package notify
+
+import (
+	"fmt"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+)
+
+// slackMessage is the payload of a Slack incoming webhook. Text is shown in notifications, and the blocks in the
+// channel.
+type slackMessage struct {
+	Text   string       `json:"text"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type     string      `json:"type"`
+	Text     *slackText  `json:"text,omitempty"`
+	Fields   []slackText `json:"fields,omitempty"`
+	Elements []slackText `json:"elements,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// NewSlackNotifier constructs a Notifier, which posts the summaries of the reports of at least the given severity
+// to the given Slack incoming webhook URL.
+func NewSlackNotifier(url string, minSeverity harbor.Severity) Notifier {
+	return newWebhookNotifier("Slack", url, minSeverity, renderSlack)
+}
+
+func renderSlack(summary Summary) interface{} {
+	return slackMessage{
+		Text: summary.Title(),
+		Blocks: []slackBlock{
+			{
+				Type: "section",
+				Text: &slackText{Type: "mrkdwn", Text: fmt.Sprintf("*%s*\n`%s`", summary.Title(), summary.Reference())},
+			},
+			{
+				Type: "section",
+				Fields: []slackText{
+					{Type: "mrkdwn", Text: "*Severity*\n" + summary.Severity.String()},
+					{Type: "mrkdwn", Text: "*Vulnerabilities*\n" + summary.CountsText()},
+					{Type: "mrkdwn", Text: "*Fixable*\n" + summary.FixableText()},
+				},
+			},
+			{
+				Type:     "context",
+				Elements: []slackText{{Type: "mrkdwn", Text: "Scan job " + summary.ScanJobID}},
+			},
+		},
+	}
+}
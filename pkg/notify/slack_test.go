@@ -0,0 +1,58 @@
+package notify
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlackNotifier_Notify(t *testing.T) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		var err error
+		body, err = io.ReadAll(req.Body)
+		assert.NoError(t, err)
+		res.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	err := NewSlackNotifier(server.URL, harbor.SevHigh).Notify(context.Background(), Summary{
+		ScanJobID: "job:123",
+		Registry:  "https://core.harbor.domain",
+		Artifact:  harbor.Artifact{Repository: "library/nginx", Digest: "sha256:6c3c"},
+		Severity:  harbor.SevCritical,
+		Counts:    map[harbor.Severity]int{harbor.SevCritical: 2, harbor.SevHigh: 5},
+		Total:     7,
+		Fixable:   6,
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+  "text": "Critical vulnerabilities found in library/nginx",
+  "blocks": [
+    {
+      "type": "section",
+      "text": {"type": "mrkdwn", "text": "*Critical vulnerabilities found in library/nginx*\n`+"`core.harbor.domain/library/nginx@sha256:6c3c`"+`"}
+    },
+    {
+      "type": "section",
+      "fields": [
+        {"type": "mrkdwn", "text": "*Severity*\nCritical"},
+        {"type": "mrkdwn", "text": "*Vulnerabilities*\n2 Critical, 5 High"},
+        {"type": "mrkdwn", "text": "*Fixable*\n6 of 7"}
+      ]
+    },
+    {
+      "type": "context",
+      "elements": [{"type": "mrkdwn", "text": "Scan job job:123"}]
+    }
+  ]
+}`, string(body))
+}
@@ -0,0 +1,74 @@
+package notify
+
+import (
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+)
+
+// teamsMessage is the payload of a Microsoft Teams incoming webhook, or of a workflow triggered by webhook requests,
+// holding an Adaptive Card.
+type teamsMessage struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+type teamsAttachment struct {
+	ContentType string    `json:"contentType"`
+	Content     teamsCard `json:"content"`
+}
+
+type teamsCard struct {
+	Schema  string          `json:"$schema"`
+	Type    string          `json:"type"`
+	Version string          `json:"version"`
+	Body    []teamsCardItem `json:"body"`
+}
+
+type teamsCardItem struct {
+	Type     string      `json:"type"`
+	Text     string      `json:"text,omitempty"`
+	Weight   string      `json:"weight,omitempty"`
+	Size     string      `json:"size,omitempty"`
+	Color    string      `json:"color,omitempty"`
+	FontType string      `json:"fontType,omitempty"`
+	Wrap     bool        `json:"wrap,omitempty"`
+	Facts    []teamsFact `json:"facts,omitempty"`
+}
+
+type teamsFact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+// NewTeamsNotifier constructs a Notifier, which posts the summaries of the reports of at least the given severity
+// to the given Microsoft Teams webhook URL.
+func NewTeamsNotifier(url string, minSeverity harbor.Severity) Notifier {
+	return newWebhookNotifier("Teams", url, minSeverity, renderTeams)
+}
+
+func renderTeams(summary Summary) interface{} {
+	color := "Warning"
+	if summary.Severity >= harbor.SevHigh {
+		color = "Attention"
+	}
+	return teamsMessage{
+		Type: "message",
+		Attachments: []teamsAttachment{{
+			ContentType: "application/vnd.microsoft.card.adaptive",
+			Content: teamsCard{
+				Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+				Type:    "AdaptiveCard",
+				Version: "1.4",
+				Body: []teamsCardItem{
+					{Type: "TextBlock", Text: summary.Title(), Weight: "Bolder", Size: "Medium", Color: color, Wrap: true},
+					{Type: "TextBlock", Text: summary.Reference(), FontType: "Monospace", Wrap: true},
+					{Type: "FactSet", Facts: []teamsFact{
+						{Title: "Severity", Value: summary.Severity.String()},
+						{Title: "Vulnerabilities", Value: summary.CountsText()},
+						{Title: "Fixable", Value: summary.FixableText()},
+						{Title: "Scan job", Value: summary.ScanJobID},
+					}},
+				},
+			},
+		}},
+	}
+}
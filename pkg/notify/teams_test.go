@@ -0,0 +1,58 @@
+package notify
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTeamsNotifier_Notify(t *testing.T) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		var err error
+		body, err = io.ReadAll(req.Body)
+		assert.NoError(t, err)
+		res.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	err := NewTeamsNotifier(server.URL, harbor.SevHigh).Notify(context.Background(), Summary{
+		ScanJobID: "job:123",
+		Registry:  "https://core.harbor.domain",
+		Artifact:  harbor.Artifact{Repository: "library/nginx", Digest: "sha256:6c3c"},
+		Severity:  harbor.SevHigh,
+		Counts:    map[harbor.Severity]int{harbor.SevHigh: 5, harbor.SevLow: 1},
+		Total:     6,
+		Fixable:   5,
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+  "type": "message",
+  "attachments": [{
+    "contentType": "application/vnd.microsoft.card.adaptive",
+    "content": {
+      "$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+      "type": "AdaptiveCard",
+      "version": "1.4",
+      "body": [
+        {"type": "TextBlock", "text": "High vulnerabilities found in library/nginx", "weight": "Bolder", "size": "Medium", "color": "Attention", "wrap": true},
+        {"type": "TextBlock", "text": "core.harbor.domain/library/nginx@sha256:6c3c", "fontType": "Monospace", "wrap": true},
+        {"type": "FactSet", "facts": [
+          {"title": "Severity", "value": "High"},
+          {"title": "Vulnerabilities", "value": "5 High, 1 Low"},
+          {"title": "Fixable", "value": "5 of 6"},
+          {"title": "Scan job", "value": "job:123"}
+        ]}
+      ]
+    }
+  }]
+}`, string(body))
+}
@@ -0,0 +1,241 @@
+// Package nvd looks up CVEs in the NVD 2.0 API to fill in the CVSS details and descriptions, which the offline
+// vulnerability database lacks for recently published CVEs. Lookups are rate limited to the NVD's public limits
+// and cached, and the API is never called unless configured, so that air-gapped deployments are unaffected.
+package nvd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/httpx"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/metrics"
+)
+
+const headerAPIKey = "apiKey"
+
+// CVE is the subset of a CVE record of the NVD used to enrich reports.
+type CVE struct {
+	ID          string
+	Description string
+	// VectorV2, VectorV3, ScoreV2 and ScoreV3 are the CVSS details of the primary source, usually the NVD.
+	VectorV2 string
+	VectorV3 string
+	ScoreV2  *float32
+	ScoreV3  *float32
+}
+
+// Client wraps the Lookup method.
+// Lookup returns the CVE with the given ID, or nil if the NVD does not know it.
+type Client interface {
+	Lookup(ctx context.Context, id string) (*CVE, error)
+}
+
+// entry is a cached lookup, including lookups of unknown CVEs.
+type entry struct {
+	cve       *CVE
+	expiresAt time.Time
+}
+
+type client struct {
+	url      string
+	apiKey   string
+	http     *http.Client
+	interval time.Duration
+	ttl      time.Duration
+	size     int
+	now      func() time.Time
+
+	// limiter holds the token of the single request allowed at a time. The next request is allowed once the
+	// interval since the previous request has elapsed.
+	limiter chan struct{}
+	last    time.Time
+
+	mu    sync.Mutex
+	cache map[string]entry
+	// order is the insertion order of the cached IDs, the oldest of which is evicted when the cache is full.
+	order []string
+}
+
+// NewClient constructs a Client of the configured NVD API, which spaces requests by the configured interval and
+// caches lookups for the configured TTL.
+func NewClient(config etc.NVD) Client {
+	return &client{
+		url:      config.URL,
+		apiKey:   config.APIKey,
+		http:     httpx.Client("nvd", 30*time.Second),
+		interval: config.RequestInterval,
+		ttl:      config.CacheTTL,
+		size:     max(config.CacheSize, 1),
+		now:      time.Now,
+		limiter:  make(chan struct{}, 1),
+		cache:    make(map[string]entry),
+	}
+}
+
+func (c *client) Lookup(ctx context.Context, id string) (*CVE, error) {
+	if cve, ok := c.cached(id); ok {
+		metrics.NVDLookups.WithLabelValues("cached").Inc()
+		return cve, nil
+	}
+
+	cve, err := c.fetch(ctx, id)
+	if err != nil {
+		metrics.NVDLookups.WithLabelValues("failed").Inc()
+		return nil, err
+	}
+	metrics.NVDLookups.WithLabelValues("fetched").Inc()
+	c.store(id, cve)
+	return cve, nil
+}
+
+func (c *client) cached(id string) (*CVE, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.cache[id]
+	if !ok || c.now().After(e.expiresAt) {
+		return nil, false
+	}
+	return e.cve, true
+}
+
+func (c *client) store(id string, cve *CVE) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.cache[id]; !ok {
+		if len(c.order) >= c.size {
+			delete(c.cache, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.order = append(c.order, id)
+	}
+	c.cache[id] = entry{cve: cve, expiresAt: c.now().Add(c.ttl)}
+}
+
+// wait blocks until the interval since the previous request has elapsed, and returns a function releasing the
+// limiter once the request has been sent.
+func (c *client) wait(ctx context.Context) (func(), error) {
+	select {
+	case c.limiter <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if delay := c.last.Add(c.interval).Sub(c.now()); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			<-c.limiter
+			return nil, ctx.Err()
+		}
+	}
+	return func() {
+		c.last = c.now()
+		<-c.limiter
+	}, nil
+}
+
+// response is the subset of the response of the NVD 2.0 CVE API used to enrich reports.
+type response struct {
+	Vulnerabilities []struct {
+		CVE struct {
+			ID           string `json:"id"`
+			Descriptions []struct {
+				Lang  string `json:"lang"`
+				Value string `json:"value"`
+			} `json:"descriptions"`
+			Metrics struct {
+				CVSSMetricV31 []cvssMetric `json:"cvssMetricV31"`
+				CVSSMetricV30 []cvssMetric `json:"cvssMetricV30"`
+				CVSSMetricV2  []cvssMetric `json:"cvssMetricV2"`
+			} `json:"metrics"`
+		} `json:"cve"`
+	} `json:"vulnerabilities"`
+}
+
+type cvssMetric struct {
+	Type     string `json:"type"`
+	CVSSData struct {
+		VectorString string  `json:"vectorString"`
+		BaseScore    float32 `json:"baseScore"`
+	} `json:"cvssData"`
+}
+
+func (c *client) fetch(ctx context.Context, id string) (*CVE, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+"?cveId="+url.QueryEscape(id), nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	if c.apiKey != "" {
+		req.Header.Set(headerAPIKey, c.apiKey)
+	}
+
+	release, err := c.wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.http.Do(req)
+	release()
+	if err != nil {
+		return nil, fmt.Errorf("looking up %s: %w", id, err)
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("looking up %s: unexpected status %d", id, res.StatusCode)
+	}
+
+	var r response
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", id, err)
+	}
+	if len(r.Vulnerabilities) == 0 {
+		return nil, nil
+	}
+
+	record := r.Vulnerabilities[0].CVE
+	cve := &CVE{ID: record.ID}
+	for _, d := range record.Descriptions {
+		if d.Lang == "en" {
+			cve.Description = d.Value
+			break
+		}
+	}
+	if m, ok := primary(record.Metrics.CVSSMetricV31, record.Metrics.CVSSMetricV30); ok {
+		cve.VectorV3, cve.ScoreV3 = m.CVSSData.VectorString, &m.CVSSData.BaseScore
+	}
+	if m, ok := primary(record.Metrics.CVSSMetricV2); ok {
+		cve.VectorV2, cve.ScoreV2 = m.CVSSData.VectorString, &m.CVSSData.BaseScore
+	}
+	return cve, nil
+}
+
+// primary returns the metric of the primary source in the first non-empty list of metrics, or the first metric
+// if there is no primary source.
+func primary(lists ...[]cvssMetric) (cvssMetric, bool) {
+	for _, list := range lists {
+		if len(list) == 0 {
+			continue
+		}
+		for _, m := range list {
+			if m.Type == "Primary" {
+				return m, true
+			}
+		}
+		return list[0], true
+	}
+	return cvssMetric{}, false
+}
@@ -0,0 +1,108 @@
+package nvd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+)
+
+const cveResponse = `{
+  "resultsPerPage": 1,
+  "vulnerabilities": [
+    {
+      "cve": {
+        "id": "CVE-2024-3094",
+        "descriptions": [
+          {"lang": "es", "value": "Se descubrió código malicioso en xz."},
+          {"lang": "en", "value": "Malicious code was discovered in the upstream tarballs of xz."}
+        ],
+        "metrics": {
+          "cvssMetricV31": [
+            {"source": "secalert@redhat.com", "type": "Secondary", "cvssData": {"vectorString": "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:C/C:H/I:H/A:H", "baseScore": 10.0}},
+            {"source": "nvd@nist.gov", "type": "Primary", "cvssData": {"vectorString": "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", "baseScore": 9.8}}
+          ]
+        }
+      }
+    }
+  ]
+}`
+
+func TestClient_Lookup(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("apiKey") != "s3cret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Query().Get("cveId") {
+		case "CVE-2024-3094":
+			_, _ = w.Write([]byte(cveResponse))
+		case "CVE-2099-0001":
+			_, _ = w.Write([]byte(`{"resultsPerPage": 0, "vulnerabilities": []}`))
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	newClient := func() Client {
+		return NewClient(etc.NVD{URL: server.URL, APIKey: "s3cret", CacheTTL: time.Hour, CacheSize: 10})
+	}
+
+	t.Run("Should return CVE with CVSS details of primary source", func(t *testing.T) {
+		cve, err := newClient().Lookup(context.Background(), "CVE-2024-3094")
+		require.NoError(t, err)
+
+		score := float32(9.8)
+		assert.Equal(t, &CVE{
+			ID:          "CVE-2024-3094",
+			Description: "Malicious code was discovered in the upstream tarballs of xz.",
+			VectorV3:    "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H",
+			ScoreV3:     &score,
+		}, cve)
+	})
+
+	t.Run("Should return nil when CVE is unknown", func(t *testing.T) {
+		cve, err := newClient().Lookup(context.Background(), "CVE-2099-0001")
+		require.NoError(t, err)
+		assert.Nil(t, cve)
+	})
+
+	t.Run("Should cache lookups", func(t *testing.T) {
+		client := newClient()
+		before := requests.Load()
+
+		for i := 0; i < 3; i++ {
+			_, err := client.Lookup(context.Background(), "CVE-2099-0001")
+			require.NoError(t, err)
+		}
+
+		assert.Equal(t, before+1, requests.Load())
+	})
+
+	t.Run("Should return error when NVD is unavailable", func(t *testing.T) {
+		_, err := newClient().Lookup(context.Background(), "CVE-2021-44228")
+		assert.EqualError(t, err, "looking up CVE-2021-44228: unexpected status 503")
+	})
+
+	t.Run("Should space requests by interval", func(t *testing.T) {
+		client := NewClient(etc.NVD{URL: server.URL, APIKey: "s3cret", RequestInterval: 50 * time.Millisecond, CacheSize: 10})
+
+		start := time.Now()
+		for _, id := range []string{"CVE-2024-3094", "CVE-2099-0001", "CVE-2024-3094"} {
+			_, err := client.Lookup(context.Background(), id)
+			require.NoError(t, err)
+		}
+
+		assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	})
+}
@@ -0,0 +1,93 @@
+package nvd
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/enrich"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+)
+
+// cvssSource is the source of the CVSS details filled in, as keyed in the CVSS vendor attribute.
+const cvssSource = "nvd"
+
+type enricher struct {
+	client  Client
+	timeout time.Duration
+}
+
+// NewEnricher constructs an Enricher, which fills in the missing CVSS details and descriptions of the CVEs in
+// reports with the CVE records looked up with the given Client. The lookups of a report are bounded by the given
+// timeout, after which the remaining vulnerabilities are left as they are. Lookups never fail scan jobs.
+func NewEnricher(client Client, timeout time.Duration) enrich.Enricher {
+	return &enricher{
+		client:  client,
+		timeout: timeout,
+	}
+}
+
+func (e *enricher) Enrich(ctx context.Context, report harbor.ScanReport) (harbor.ScanReport, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	cves := make(map[string]*CVE)
+	vulnerabilities := make([]harbor.VulnerabilityItem, len(report.Vulnerabilities))
+	copy(vulnerabilities, report.Vulnerabilities)
+
+	for i, v := range vulnerabilities {
+		if !strings.HasPrefix(v.ID, "CVE-") || (v.Description != "" && hasCVSS(v)) {
+			continue
+		}
+		cve, ok := cves[v.ID]
+		if !ok {
+			var err error
+			if cve, err = e.client.Lookup(ctx, v.ID); err != nil {
+				if ctx.Err() != nil {
+					slog.Warn("Skipped NVD lookups exceeding timeout", slog.String("timeout", e.timeout.String()))
+					break
+				}
+				slog.Warn("Error while looking up CVE in NVD", slog.String("vulnerability_id", v.ID),
+					slog.String("err", err.Error()))
+			}
+			cves[v.ID] = cve
+		}
+		if cve != nil {
+			vulnerabilities[i] = fill(v, cve)
+		}
+	}
+
+	report.Vulnerabilities = vulnerabilities
+	return report, nil
+}
+
+func hasCVSS(v harbor.VulnerabilityItem) bool {
+	_, ok := v.VendorAttributes["CVSS"]
+	return ok || v.PreferredCVSS != nil
+}
+
+// fill returns the given vulnerability with its missing description and CVSS details taken from the given CVE.
+func fill(v harbor.VulnerabilityItem, cve *CVE) harbor.VulnerabilityItem {
+	if v.Description == "" {
+		v.Description = cve.Description
+	}
+	if !hasCVSS(v) && (cve.VectorV2 != "" || cve.VectorV3 != "") {
+		// The vendor attributes are copied, as they are shared with the original report.
+		attributes := make(map[string]interface{}, len(v.VendorAttributes)+1)
+		for key, value := range v.VendorAttributes {
+			attributes[key] = value
+		}
+		attributes["CVSS"] = map[string]tunnel.CVSSInfo{
+			cvssSource: {
+				V2Vector: cve.VectorV2,
+				V3Vector: cve.VectorV3,
+				V2Score:  cve.ScoreV2,
+				V3Score:  cve.ScoreV3,
+			},
+		}
+		v.VendorAttributes = attributes
+	}
+	return v
+}
@@ -0,0 +1,71 @@
+package nvd
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+)
+
+// fakeClient returns the given CVEs, and fails the lookups of other IDs.
+type fakeClient struct {
+	cves    map[string]*CVE
+	lookups []string
+}
+
+func (c *fakeClient) Lookup(_ context.Context, id string) (*CVE, error) {
+	c.lookups = append(c.lookups, id)
+	cve, ok := c.cves[id]
+	if !ok {
+		return nil, errors.New("unexpected status 503")
+	}
+	return cve, nil
+}
+
+func TestEnricher_Enrich(t *testing.T) {
+	score := float32(9.8)
+	client := &fakeClient{cves: map[string]*CVE{
+		"CVE-2024-3094": {
+			ID:          "CVE-2024-3094",
+			Description: "Malicious code was discovered in the upstream tarballs of xz.",
+			VectorV3:    "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H",
+			ScoreV3:     &score,
+		},
+		"CVE-2099-0001": nil,
+	}}
+	existing := map[string]interface{}{"CVSS": map[string]tunnel.CVSSInfo{"redhat": {V3Vector: "CVSS:3.1/AV:L"}}}
+
+	report := harbor.ScanReport{Vulnerabilities: []harbor.VulnerabilityItem{
+		{ID: "CVE-2024-3094", Pkg: "xz-libs", VendorAttributes: map[string]interface{}{"fix_stage": "runtime"}},
+		{ID: "CVE-2024-3094", Pkg: "xz-utils", Description: "Backdoor in xz.", VendorAttributes: existing},
+		{ID: "CVE-2099-0001", Pkg: "openssl"},
+		{ID: "CVE-2021-44228", Pkg: "log4j-core"},
+		{ID: "GHSA-jfh8-c2jp-5v3q", Pkg: "log4j-core"},
+	}}
+
+	enriched, err := NewEnricher(client, time.Minute).Enrich(context.Background(), report)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"CVE-2024-3094", "CVE-2099-0001", "CVE-2021-44228"}, client.lookups)
+	assert.Equal(t, harbor.VulnerabilityItem{
+		ID:          "CVE-2024-3094",
+		Pkg:         "xz-libs",
+		Description: "Malicious code was discovered in the upstream tarballs of xz.",
+		VendorAttributes: map[string]interface{}{
+			"fix_stage": "runtime",
+			"CVSS": map[string]tunnel.CVSSInfo{
+				"nvd": {V3Vector: "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", V3Score: &score},
+			},
+		},
+	}, enriched.Vulnerabilities[0])
+	assert.Equal(t, report.Vulnerabilities[1], enriched.Vulnerabilities[1], "complete vulnerability should be kept")
+	assert.Equal(t, report.Vulnerabilities[2:], enriched.Vulnerabilities[2:])
+	assert.Equal(t, map[string]interface{}{"fix_stage": "runtime"}, report.Vulnerabilities[0].VendorAttributes,
+		"original report should not be modified")
+}
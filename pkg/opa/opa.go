@@ -0,0 +1,325 @@
+// Package opa post-processes scan reports with a Rego policy before they are saved, so that security teams control
+// centrally which findings are reported and how. The policy is given the report, the report of Tunnel it was
+// transformed from and the metadata of the artifact, and evaluates to a Decision, which drops, relabels and
+// annotates findings. The policy is evaluated either by an OPA server, or by the OPA executable.
+package opa
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/httpx"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+)
+
+const (
+	// maxDecisionSize bounds the size of the decisions returned by OPA.
+	maxDecisionSize = 16 << 20
+
+	// attributeDropped is the vendor attribute of reports holding the number of findings dropped by the policy.
+	attributeDropped = "policy_dropped"
+)
+
+// Input is the input document of the policy.
+type Input struct {
+	Artifact Artifact `json:"artifact"`
+	// TunnelReport is the report of Tunnel the report was transformed from.
+	TunnelReport tunnel.Report `json:"tunnel_report"`
+	// Report is the report to post-process.
+	Report harbor.ScanReport `json:"report"`
+}
+
+// Artifact is the metadata of the scanned artifact. It never holds the credentials of the registry.
+type Artifact struct {
+	Registry   string           `json:"registry"`
+	Repository string           `json:"repository"`
+	Digest     string           `json:"digest"`
+	MimeType   string           `json:"mime_type,omitempty"`
+	Platform   *harbor.Platform `json:"platform,omitempty"`
+}
+
+// Decision is the document the policy evaluates to. A policy whose decision is undefined leaves the report as is.
+type Decision struct {
+	// Findings are applied in order to the vulnerabilities they match.
+	Findings []Finding `json:"findings"`
+	// Annotations are added to the vendor attributes of the report.
+	Annotations map[string]interface{} `json:"annotations"`
+}
+
+// Finding is the decision on the vulnerabilities with the given ID, of the given package unless it is blank.
+type Finding struct {
+	ID      string `json:"id"`
+	Package string `json:"package"`
+	// Drop removes the vulnerabilities from the report.
+	Drop bool `json:"drop"`
+	// Severity relabels the vulnerabilities with the severity of the given name, unless it is blank.
+	Severity string `json:"severity"`
+	// Annotations are added to the vendor attributes of the vulnerabilities.
+	Annotations map[string]interface{} `json:"annotations"`
+}
+
+// Evaluator wraps the Evaluate method.
+// Evaluate returns the given scan report, transformed from the given report of Tunnel for the given scan request,
+// post-processed according to the decision of the policy.
+type Evaluator interface {
+	Evaluate(ctx context.Context, request harbor.ScanRequest, tunnelReport tunnel.Report,
+		report harbor.ScanReport) (harbor.ScanReport, error)
+}
+
+// engine evaluates the policy on the given input document, and returns the decision as JSON, or nil if it is
+// undefined.
+type engine interface {
+	name() string
+	eval(ctx context.Context, input []byte) ([]byte, error)
+}
+
+type evaluator struct {
+	engine      engine
+	timeout     time.Duration
+	failOnError bool
+}
+
+// NewEvaluator constructs an Evaluator of the configured policy. Unless the configuration fails on errors, a
+// policy which cannot be evaluated, or whose decision is invalid, is logged and the report is kept as is.
+func NewEvaluator(config etc.OPA) Evaluator {
+	var e engine
+	if config.URL != "" {
+		e = &serverEngine{url: config.URL, client: httpx.Client("opa", 0)}
+	} else {
+		e = &execEngine{executable: config.Executable, policyPath: config.PolicyPath, query: config.Query}
+	}
+	return &evaluator{
+		engine:      e,
+		timeout:     config.Timeout,
+		failOnError: config.FailOnError,
+	}
+}
+
+func (e *evaluator) Evaluate(ctx context.Context, request harbor.ScanRequest, tunnelReport tunnel.Report,
+	report harbor.ScanReport) (harbor.ScanReport, error) {
+	evaluated, err := e.evaluate(ctx, request, tunnelReport, report)
+	if err != nil {
+		err = fmt.Errorf("OPA policy %s: %w", e.engine.name(), err)
+		if e.failOnError {
+			return report, err
+		}
+		slog.WarnContext(ctx, "Error while evaluating OPA policy", slog.String("err", err.Error()))
+		return report, nil
+	}
+	return evaluated, nil
+}
+
+func (e *evaluator) evaluate(ctx context.Context, request harbor.ScanRequest, tunnelReport tunnel.Report,
+	report harbor.ScanReport) (harbor.ScanReport, error) {
+	input, err := json.Marshal(Input{
+		Artifact: Artifact{
+			Registry:   request.Registry.URL,
+			Repository: request.Artifact.Repository,
+			Digest:     request.Artifact.Digest,
+			MimeType:   request.Artifact.MimeType,
+			Platform:   report.Artifact.Platform,
+		},
+		TunnelReport: tunnelReport,
+		Report:       report,
+	})
+	if err != nil {
+		return report, fmt.Errorf("marshalling input: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	out, err := e.engine.eval(ctx, input)
+	if err != nil || out == nil {
+		return report, err
+	}
+	var decision Decision
+	decoder := json.NewDecoder(bytes.NewReader(out))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(&decision); err != nil {
+		return report, fmt.Errorf("decoding decision: %w", err)
+	}
+	return Apply(decision, report)
+}
+
+// Apply returns a copy of the given report post-processed according to the given decision. The severity of the
+// report is the highest severity of the vulnerabilities left. It returns an error if the decision is invalid.
+func Apply(decision Decision, report harbor.ScanReport) (harbor.ScanReport, error) {
+	findings := make(map[string][]Finding, len(decision.Findings))
+	severities := make(map[string]harbor.Severity)
+	for i, f := range decision.Findings {
+		if f.ID == "" {
+			return report, fmt.Errorf("invalid decision: finding %d must have an id", i)
+		}
+		if f.Severity != "" {
+			severity, err := harbor.ParseSeverity(f.Severity)
+			if err != nil {
+				return report, fmt.Errorf("invalid decision: finding %d: %w", i, err)
+			}
+			severities[f.Severity] = severity
+		}
+		findings[f.ID] = append(findings[f.ID], f)
+	}
+
+	vulnerabilities := make([]harbor.VulnerabilityItem, 0, len(report.Vulnerabilities))
+	dropped := 0
+	highest := harbor.SevUnknown
+	for _, v := range report.Vulnerabilities {
+		drop := false
+		for _, f := range findings[v.ID] {
+			if f.Package != "" && f.Package != v.Pkg {
+				continue
+			}
+			drop = drop || f.Drop
+			if f.Severity != "" {
+				v.Severity = severities[f.Severity]
+			}
+			if len(f.Annotations) > 0 {
+				attributes := make(map[string]interface{}, len(v.VendorAttributes)+len(f.Annotations))
+				maps.Copy(attributes, v.VendorAttributes)
+				maps.Copy(attributes, f.Annotations)
+				v.VendorAttributes = attributes
+			}
+		}
+		if drop {
+			dropped++
+			continue
+		}
+		if v.Severity > highest {
+			highest = v.Severity
+		}
+		vulnerabilities = append(vulnerabilities, v)
+	}
+
+	if dropped > 0 || len(decision.Annotations) > 0 {
+		attributes := make(map[string]interface{}, len(report.VendorAttributes)+len(decision.Annotations)+1)
+		maps.Copy(attributes, report.VendorAttributes)
+		maps.Copy(attributes, decision.Annotations)
+		if dropped > 0 {
+			attributes[attributeDropped] = dropped
+		}
+		report.VendorAttributes = attributes
+	}
+	report.Vulnerabilities = vulnerabilities
+	report.Severity = highest
+	return report, nil
+}
+
+// serverEngine evaluates the policy with the Data API of an OPA server, which responds with the decision in the
+// result of the document at the given URL.
+type serverEngine struct {
+	url    string
+	client *http.Client
+}
+
+// name returns the URL of the document without its query, which may embed secrets.
+func (e *serverEngine) name() string {
+	u, _, _ := strings.Cut(e.url, "?")
+	return u
+}
+
+func (e *serverEngine) eval(ctx context.Context, input []byte) ([]byte, error) {
+	body, err := json.Marshal(map[string]json.RawMessage{"input": input})
+	if err != nil {
+		return nil, fmt.Errorf("marshalling request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("posting input: %w", err)
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxDecisionSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+	if len(data) > maxDecisionSize {
+		return nil, fmt.Errorf("decision exceeds %d bytes", maxDecisionSize)
+	}
+	var response struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err = json.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	return nullable(response.Result), nil
+}
+
+// execEngine evaluates the query of the decision with the OPA executable and the policies of the given path.
+type execEngine struct {
+	executable string
+	policyPath string
+	query      string
+}
+
+func (e *execEngine) name() string {
+	return e.policyPath
+}
+
+func (e *execEngine) eval(ctx context.Context, input []byte) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, e.executable, "eval", "--format", "json", "--stdin-input",
+		"--data", e.policyPath, e.query)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("running opa: %w", ctx.Err())
+		}
+		return nil, fmt.Errorf("running opa: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if stdout.Len() > maxDecisionSize {
+		return nil, fmt.Errorf("decision exceeds %d bytes", maxDecisionSize)
+	}
+	var output struct {
+		Result []struct {
+			Expressions []struct {
+				Value json.RawMessage `json:"value"`
+			} `json:"expressions"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &output); err != nil {
+		return nil, fmt.Errorf("decoding output: %w", err)
+	}
+	// The result of an undefined query is empty.
+	if len(output.Result) == 0 || len(output.Result[0].Expressions) == 0 {
+		return nil, nil
+	}
+	if len(output.Result) > 1 {
+		return nil, errors.New("query has more than one result")
+	}
+	return nullable(output.Result[0].Expressions[0].Value), nil
+}
+
+// nullable returns nil if the given decision is missing or null, i.e. undefined.
+func nullable(decision json.RawMessage) []byte {
+	if len(decision) == 0 || string(decision) == "null" {
+		return nil
+	}
+	return decision
+}
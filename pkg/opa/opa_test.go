@@ -0,0 +1,166 @@
+package opa
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+)
+
+var (
+	request = harbor.ScanRequest{
+		Registry: harbor.Registry{URL: "https://core.harbor.domain", Authorization: "Basic cm9ib3Q6czNjcmV0"},
+		Artifact: harbor.Artifact{Repository: "library/mongo", Digest: "sha256:917f"},
+	}
+	report = harbor.ScanReport{
+		Artifact: harbor.Artifact{Repository: "library/mongo", Digest: "sha256:917f"},
+		Severity: harbor.SevCritical,
+		Vulnerabilities: []harbor.VulnerabilityItem{
+			{ID: "CVE-2024-3094", Pkg: "xz", Severity: harbor.SevCritical},
+			{ID: "CVE-2019-1549", Pkg: "openssl", Severity: harbor.SevHigh},
+			{ID: "CVE-2019-1549", Pkg: "libssl1.1", Severity: harbor.SevHigh},
+		},
+	}
+)
+
+func TestApply(t *testing.T) {
+	testCases := []struct {
+		name           string
+		decision       Decision
+		expectedReport harbor.ScanReport
+		expectedError  string
+	}{
+		{
+			name:           "Should keep report as is given empty decision",
+			expectedReport: report,
+		},
+		{
+			name: "Should drop, relabel and annotate matching findings",
+			decision: Decision{
+				Findings: []Finding{
+					{ID: "CVE-2024-3094", Drop: true},
+					{ID: "CVE-2019-1549", Package: "openssl", Severity: "low",
+						Annotations: map[string]interface{}{"owner": "team-a"}},
+				},
+				Annotations: map[string]interface{}{"policy_version": "v3"},
+			},
+			expectedReport: harbor.ScanReport{
+				Artifact: report.Artifact,
+				Severity: harbor.SevHigh,
+				Vulnerabilities: []harbor.VulnerabilityItem{
+					{ID: "CVE-2019-1549", Pkg: "openssl", Severity: harbor.SevLow,
+						VendorAttributes: map[string]interface{}{"owner": "team-a"}},
+					{ID: "CVE-2019-1549", Pkg: "libssl1.1", Severity: harbor.SevHigh},
+				},
+				VendorAttributes: map[string]interface{}{"policy_version": "v3", "policy_dropped": 1},
+			},
+		},
+		{
+			name:          "Should return error when finding has no id",
+			decision:      Decision{Findings: []Finding{{Package: "xz", Drop: true}}},
+			expectedError: "invalid decision: finding 0 must have an id",
+		},
+		{
+			name:          "Should return error when severity is unknown",
+			decision:      Decision{Findings: []Finding{{ID: "CVE-2024-3094", Severity: "Severe"}}},
+			expectedError: "invalid decision: finding 0: unknown severity: Severe",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			applied, err := Apply(tc.decision, report)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedReport, applied)
+		})
+	}
+}
+
+func TestEvaluator_Evaluate(t *testing.T) {
+	ctx := context.Background()
+	dropped := report
+	dropped.Severity = harbor.SevHigh
+	dropped.Vulnerabilities = report.Vulnerabilities[1:]
+	dropped.VendorAttributes = map[string]interface{}{"policy_dropped": 1}
+
+	var input map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		input = body["input"]
+		switch r.URL.Path {
+		case "/v1/data/scanner/report":
+			_, _ = w.Write([]byte(`{"result": {"findings": [{"id": "CVE-2024-3094", "drop": true}]}}`))
+		case "/v1/data/scanner/undefined":
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	t.Run("Should apply decision of OPA server", func(t *testing.T) {
+		evaluator := NewEvaluator(etc.OPA{URL: server.URL + "/v1/data/scanner/report", Timeout: time.Second})
+
+		evaluated, err := evaluator.Evaluate(ctx, request, tunnel.Report{}, report)
+		require.NoError(t, err)
+		assert.Equal(t, dropped, evaluated)
+		assert.Equal(t, map[string]interface{}{"registry": "https://core.harbor.domain",
+			"repository": "library/mongo", "digest": "sha256:917f"}, input["artifact"])
+	})
+
+	t.Run("Should keep report as is given undefined decision", func(t *testing.T) {
+		evaluator := NewEvaluator(etc.OPA{URL: server.URL + "/v1/data/scanner/undefined", Timeout: time.Second})
+
+		evaluated, err := evaluator.Evaluate(ctx, request, tunnel.Report{}, report)
+		require.NoError(t, err)
+		assert.Equal(t, report, evaluated)
+	})
+
+	t.Run("Should keep report as is when policy fails", func(t *testing.T) {
+		evaluator := NewEvaluator(etc.OPA{URL: server.URL + "/v1/data/missing", Timeout: time.Second})
+
+		evaluated, err := evaluator.Evaluate(ctx, request, tunnel.Report{}, report)
+		require.NoError(t, err)
+		assert.Equal(t, report, evaluated)
+	})
+
+	t.Run("Should return error when policy fails given fail on error", func(t *testing.T) {
+		evaluator := NewEvaluator(etc.OPA{URL: server.URL + "/v1/data/missing", Timeout: time.Second,
+			FailOnError: true})
+
+		_, err := evaluator.Evaluate(ctx, request, tunnel.Report{}, report)
+		assert.EqualError(t, err, "OPA policy "+server.URL+"/v1/data/missing: unexpected status 404")
+	})
+
+	t.Run("Should apply decision of OPA executable", func(t *testing.T) {
+		dir := t.TempDir()
+		executable := filepath.Join(dir, "opa")
+		// The fake executable checks its arguments, and outputs the decision like opa eval does.
+		require.NoError(t, os.WriteFile(executable, []byte(`#!/bin/sh
+[ "$*" = "eval --format json --stdin-input --data `+dir+` data.scanner.report" ] || exit 1
+cat > /dev/null
+echo '{"result": [{"expressions": [{"value": {"findings": [{"id": "CVE-2024-3094", "drop": true}]}, "text": "data.scanner.report"}]}]}'
+`), 0700))
+		evaluator := NewEvaluator(etc.OPA{PolicyPath: dir, Query: "data.scanner.report", Executable: executable,
+			Timeout: time.Second, FailOnError: true})
+
+		evaluated, err := evaluator.Evaluate(ctx, request, tunnel.Report{}, report)
+		require.NoError(t, err)
+		assert.Equal(t, dropped, evaluated)
+	})
+}
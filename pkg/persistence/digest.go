@@ -0,0 +1,15 @@
+package persistence
+
+import (
+	"context"
+)
+
+// DigestIndex maps the digests of scanned artifacts to the scan jobs which last scanned them, so that the report
+// of an artifact pushed under several tags, or to several repositories, is reused rather than rescanned.
+type DigestIndex interface {
+	// Record records that the scan job with the given ID scans the artifact with the given digest.
+	Record(ctx context.Context, digest, scanJobID string) error
+	// Find returns the ID of the scan job which last scanned the given digest, or an empty string if the digest
+	// was not scanned recently.
+	Find(ctx context.Context, digest string) (string, error)
+}
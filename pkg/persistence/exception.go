@@ -0,0 +1,18 @@
+package persistence
+
+import (
+	"context"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/policy"
+)
+
+// ExceptionStore keeps the exceptions to the policy requested for artifacts until they expire, so that the
+// verdicts on scan reports honor the approved ones.
+type ExceptionStore interface {
+	// Save creates or replaces the given exception, which is evicted when it expires.
+	Save(ctx context.Context, exception policy.Exception) error
+	// Get returns the exception with the given ID, or nil if it does not exist or expired.
+	Get(ctx context.Context, id string) (*policy.Exception, error)
+	// List returns the exceptions which have not expired, in the order they were requested.
+	List(ctx context.Context) ([]policy.Exception, error)
+}
@@ -0,0 +1,16 @@
+package persistence
+
+import (
+	"context"
+)
+
+// FailureIndex counts the permanent failures of scanning the artifacts of a digest, e.g. because its manifest is
+// unknown, so that requests to scan a digest which failed repeatedly fail fast during a cooldown period rather
+// than occupying workers with scans which are bound to fail again.
+type FailureIndex interface {
+	// Record records that scanning the artifact with the given digest failed permanently with the given error.
+	Record(ctx context.Context, digest, message string) error
+	// Find returns the error of the last failure of the given digest if it failed repeatedly within the cooldown
+	// period, or an empty string otherwise.
+	Find(ctx context.Context, digest string) (string, error)
+}
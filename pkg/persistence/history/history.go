@@ -0,0 +1,56 @@
+// Package history keeps the entries of the scan jobs completed recently in memory, so that recent activity can be
+// inspected after the scan jobs expired from the store, without querying it.
+package history
+
+import (
+	"sync"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+)
+
+// History wraps the Add and Recent methods.
+// Add records the entry of a completed scan job, replacing the oldest entry if the history is full.
+// Recent returns the recorded entries, most recently completed first.
+type History interface {
+	Add(entry job.Entry)
+	Recent() []job.Entry
+}
+
+// history is a ring buffer of entries, where next is the index of the slot written by the next Add.
+type history struct {
+	mu      sync.Mutex
+	entries []job.Entry
+	next    int
+	full    bool
+}
+
+// NewHistory constructs a History of the given number of entries, which must be positive.
+func NewHistory(size int) History {
+	return &history{entries: make([]job.Entry, size)}
+}
+
+func (h *history) Add(entry job.Entry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.entries[h.next] = entry
+	h.next = (h.next + 1) % len(h.entries)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+func (h *history) Recent() []job.Entry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	n := h.next
+	if h.full {
+		n = len(h.entries)
+	}
+	recent := make([]job.Entry, n)
+	for i := range recent {
+		recent[i] = h.entries[(h.next-1-i+len(h.entries))%len(h.entries)]
+	}
+	return recent
+}
@@ -0,0 +1,36 @@
+package history
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+)
+
+func TestHistory(t *testing.T) {
+	entry := func(id string) job.Entry {
+		return job.Entry{Summary: job.Summary{ID: id}}
+	}
+	ids := func(entries []job.Entry) []string {
+		ids := make([]string, len(entries))
+		for i, e := range entries {
+			ids[i] = e.ID
+		}
+		return ids
+	}
+
+	h := NewHistory(3)
+	assert.Empty(t, h.Recent())
+
+	h.Add(entry("job:1"))
+	h.Add(entry("job:2"))
+	assert.Equal(t, []string{"job:2", "job:1"}, ids(h.Recent()))
+
+	h.Add(entry("job:3"))
+	assert.Equal(t, []string{"job:3", "job:2", "job:1"}, ids(h.Recent()))
+
+	h.Add(entry("job:4"))
+	h.Add(entry("job:5"))
+	assert.Equal(t, []string{"job:5", "job:4", "job:3"}, ids(h.Recent()))
+}
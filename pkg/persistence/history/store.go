@@ -0,0 +1,112 @@
+package history
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+)
+
+// maxInFlight caps the number of scan jobs whose creation and start times are kept until they complete, so that
+// scan jobs which never complete in this process, e.g. the ones picked up by another replica, do not pile up.
+const maxInFlight = 10000
+
+type store struct {
+	persistence.Store
+	history History
+	now     func() time.Time
+
+	mu sync.Mutex
+	// inFlight are the summaries of the scan jobs created or started in this process, until they complete.
+	inFlight map[string]job.Summary
+}
+
+// NewStore constructs a Store, which delegates to the given Store and adds the entries of the scan jobs completed
+// through it to the given History. The completed scan job is read back from the given Store to record its
+// artifact and the counts of its vulnerabilities.
+func NewStore(s persistence.Store, history History) persistence.Store {
+	return &store{
+		Store:    s,
+		history:  history,
+		now:      time.Now,
+		inFlight: make(map[string]job.Summary),
+	}
+}
+
+func (s *store) Create(ctx context.Context, scanJob job.ScanJob) error {
+	if err := s.Store.Create(ctx, scanJob); err != nil {
+		return err
+	}
+	now := s.now()
+	// Scan jobs reusing the report of a digest, or failing fast, are created completed.
+	if scanJob.Status == job.Finished || scanJob.Status == job.Failed {
+		s.record(ctx, scanJob.ID, job.Summary{CreatedAt: now}, now)
+		return nil
+	}
+	s.track(scanJob.ID, func(summary *job.Summary) {
+		summary.CreatedAt = now
+	})
+	return nil
+}
+
+func (s *store) UpdateStatus(ctx context.Context, scanJobID string, newStatus job.ScanJobStatus, error ...string) error {
+	if err := s.Store.UpdateStatus(ctx, scanJobID, newStatus, error...); err != nil {
+		return err
+	}
+	now := s.now()
+	switch newStatus {
+	case job.Pending:
+		s.track(scanJobID, func(summary *job.Summary) {
+			summary.StartedAt = now
+		})
+	case job.Finished, job.Failed:
+		s.mu.Lock()
+		summary := s.inFlight[scanJobID]
+		delete(s.inFlight, scanJobID)
+		s.mu.Unlock()
+		s.record(ctx, scanJobID, summary, now)
+	}
+	return nil
+}
+
+// track updates the summary of the given in flight scan job with the given function.
+func (s *store) track(scanJobID string, update func(summary *job.Summary)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	summary, ok := s.inFlight[scanJobID]
+	if !ok && len(s.inFlight) >= maxInFlight {
+		return
+	}
+	update(&summary)
+	s.inFlight[scanJobID] = summary
+}
+
+// record adds the entry of the completed scan job to the history. Errors are logged rather than returned, as the
+// scan job was completed regardless.
+func (s *store) record(ctx context.Context, scanJobID string, summary job.Summary, finishedAt time.Time) {
+	scanJob, err := s.Store.Get(ctx, scanJobID)
+	if err != nil || scanJob == nil {
+		if err != nil {
+			slog.WarnContext(ctx, "Error while recording completed scan job", slog.String("scan_job_id", scanJobID),
+				slog.String("err", err.Error()))
+		}
+		return
+	}
+
+	summary.ID = scanJob.ID
+	summary.Status = scanJob.Status
+	summary.Error = scanJob.Error
+	summary.FinishedAt = finishedAt
+	summary.Counts = persistence.CountSeverities(scanJob.Report)
+
+	entry := job.Entry{Summary: summary}
+	if scanJob.Request != nil {
+		artifact := scanJob.Request.Artifact
+		entry.Artifact = &artifact
+	}
+	s.history.Add(entry)
+}
@@ -0,0 +1,77 @@
+package history
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/mock"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	request := &harbor.ScanRequest{Artifact: harbor.Artifact{Repository: "library/mongo", Digest: "sha256:917f"}}
+	noCounts := map[string]int{"Unknown": 0, "Low": 0, "Medium": 0, "High": 0, "Critical": 0}
+
+	backend := mock.NewStore()
+	backend.On("Create", ctx, job.ScanJob{ID: "job:1", Request: request}).Return(nil)
+	backend.On("UpdateStatus", ctx, "job:1", job.Pending, []string(nil)).Return(nil)
+	backend.On("UpdateStatus", ctx, "job:1", job.Finished, []string(nil)).Return(nil)
+	backend.On("Get", ctx, "job:1").Return(&job.ScanJob{
+		ID:      "job:1",
+		Status:  job.Finished,
+		Request: request,
+		Report: harbor.ScanReport{Vulnerabilities: []harbor.VulnerabilityItem{
+			{ID: "CVE-2019-1549", Severity: harbor.SevHigh},
+			{ID: "CVE-2019-1551", Severity: harbor.SevHigh},
+		}},
+	}, nil)
+	backend.On("Create", ctx, job.ScanJob{ID: "job:2", Status: job.Failed, Error: "manifest unknown"}).Return(nil)
+	backend.On("Get", ctx, "job:2").Return(&job.ScanJob{ID: "job:2", Status: job.Failed, Error: "manifest unknown"}, nil)
+	backend.On("UpdateStatus", ctx, "job:3", job.Failed, []string{"out of memory"}).Return(xerrors.New("connection refused"))
+
+	h := NewHistory(10)
+	s := NewStore(backend, h).(*store)
+	s.now = func() time.Time { return now }
+
+	require.NoError(t, s.Create(ctx, job.ScanJob{ID: "job:1", Request: request}))
+	now = now.Add(time.Second)
+	require.NoError(t, s.UpdateStatus(ctx, "job:1", job.Pending))
+	now = now.Add(time.Minute)
+	require.NoError(t, s.UpdateStatus(ctx, "job:1", job.Finished))
+	require.NoError(t, s.Create(ctx, job.ScanJob{ID: "job:2", Status: job.Failed, Error: "manifest unknown"}))
+	assert.Error(t, s.UpdateStatus(ctx, "job:3", job.Failed, "out of memory"))
+
+	assert.Equal(t, []job.Entry{
+		{
+			Summary: job.Summary{
+				ID:         "job:2",
+				Status:     job.Failed,
+				Error:      "manifest unknown",
+				Counts:     noCounts,
+				CreatedAt:  now,
+				FinishedAt: now,
+			},
+		},
+		{
+			Summary: job.Summary{
+				ID:         "job:1",
+				Status:     job.Finished,
+				Counts:     map[string]int{"Unknown": 0, "Low": 0, "Medium": 0, "High": 2, "Critical": 0},
+				CreatedAt:  time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+				StartedAt:  time.Date(2024, 3, 1, 12, 0, 1, 0, time.UTC),
+				FinishedAt: now,
+			},
+			Artifact: &harbor.Artifact{Repository: "library/mongo", Digest: "sha256:917f"},
+		},
+	}, h.Recent())
+	assert.Empty(t, s.inFlight)
+	backend.AssertExpectations(t)
+}
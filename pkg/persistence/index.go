@@ -0,0 +1,37 @@
+package persistence
+
+import (
+	"context"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+)
+
+// AffectedPackage is a package of an artifact affected by a vulnerability.
+type AffectedPackage struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	FixVersion string `json:"fix_version,omitempty"`
+}
+
+// AffectedArtifact is an artifact affected by a vulnerability, as found by the artifact's most recent scan.
+type AffectedArtifact struct {
+	Registry   string            `json:"registry,omitempty"`
+	Repository string            `json:"repository"`
+	Digest     string            `json:"digest"`
+	Severity   harbor.Severity   `json:"severity"`
+	Packages   []AffectedPackage `json:"packages"`
+	ScannedAt  time.Time         `json:"scanned_at"`
+}
+
+// VulnerabilityIndex maps vulnerabilities to the artifacts affected by them, so that the impact of a
+// vulnerability is known without rescanning.
+type VulnerabilityIndex interface {
+	// Index replaces the vulnerabilities indexed for the artifact of the given report, which was pulled from
+	// the given registry, with the vulnerabilities found in the report.
+	Index(ctx context.Context, registry string, report harbor.ScanReport) error
+	// FindArtifacts returns the artifacts affected by the vulnerability with the given identifier, e.g. a CVE.
+	FindArtifacts(ctx context.Context, vulnerabilityID string) ([]AffectedArtifact, error)
+	// FindVulnerabilities returns the identifiers of the vulnerabilities indexed for the given artifact.
+	FindVulnerabilities(ctx context.Context, registry string, artifact harbor.Artifact) ([]string, error)
+}
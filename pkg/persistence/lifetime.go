@@ -0,0 +1,76 @@
+package persistence
+
+import (
+	"context"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+)
+
+// VulnerabilityLifetime tells when a vulnerability was found in an artifact across the scans of its digest.
+type VulnerabilityLifetime struct {
+	Severity  harbor.Severity `json:"severity"`
+	FirstSeen time.Time       `json:"first_seen"`
+	LastSeen  time.Time       `json:"last_seen"`
+	// RemediatedAt is when a scan first no longer found the vulnerability, or zero while it is still found.
+	RemediatedAt time.Time `json:"remediated_at"`
+}
+
+// IsRemediated returns true if the most recent scans no longer found the vulnerability.
+func (l VulnerabilityLifetime) IsRemediated() bool {
+	return !l.RemediatedAt.IsZero()
+}
+
+// LifetimeStats summarizes the lifetimes of vulnerabilities.
+type LifetimeStats struct {
+	Open       int `json:"open"`
+	Remediated int `json:"remediated"`
+	// MeanTimeToRemediateSeconds is the mean time between the first scan which found a remediated vulnerability
+	// and the first scan which no longer found it, or zero if no vulnerability was remediated.
+	MeanTimeToRemediateSeconds int64 `json:"mean_time_to_remediate_seconds"`
+
+	timeToRemediate time.Duration
+}
+
+// Add counts the given lifetime of a vulnerability.
+func (s *LifetimeStats) Add(lifetime VulnerabilityLifetime) {
+	if !lifetime.IsRemediated() {
+		s.Open++
+		return
+	}
+	s.Remediated++
+	s.timeToRemediate += lifetime.RemediatedAt.Sub(lifetime.FirstSeen)
+	s.MeanTimeToRemediateSeconds = int64((s.timeToRemediate / time.Duration(s.Remediated)).Seconds())
+}
+
+// LifetimeSummary summarizes the lifetimes of the vulnerabilities of all artifacts, overall and by severity.
+type LifetimeSummary struct {
+	Artifacts int `json:"artifacts"`
+	LifetimeStats
+	BySeverity map[string]*LifetimeStats `json:"by_severity"`
+}
+
+// Add counts the given lifetime of a vulnerability of an artifact.
+func (s *LifetimeSummary) Add(lifetime VulnerabilityLifetime) {
+	s.LifetimeStats.Add(lifetime)
+	if s.BySeverity == nil {
+		s.BySeverity = make(map[string]*LifetimeStats)
+	}
+	severity := lifetime.Severity.String()
+	if s.BySeverity[severity] == nil {
+		s.BySeverity[severity] = &LifetimeStats{}
+	}
+	s.BySeverity[severity].Add(lifetime)
+}
+
+// LifetimeStore keeps the lifetimes of the vulnerabilities found in artifacts, so that reports tell how long each
+// vulnerability has been present in the artifact, and that the time taken to remediate vulnerabilities is known.
+type LifetimeStore interface {
+	// Get returns the lifetimes of the vulnerabilities of the artifact with the given digest keyed by
+	// vulnerability ID, or an empty map if the digest was not scanned before.
+	Get(ctx context.Context, digest string) (map[string]VulnerabilityLifetime, error)
+	// Save replaces the lifetimes of the vulnerabilities of the artifact with the given digest.
+	Save(ctx context.Context, digest string, lifetimes map[string]VulnerabilityLifetime) error
+	// Summarize returns the summary of the lifetimes of the vulnerabilities of all artifacts.
+	Summarize(ctx context.Context) (LifetimeSummary, error)
+}
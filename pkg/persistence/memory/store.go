@@ -0,0 +1,315 @@
+// Package memory implements the persistence.Store kept in the memory of the adapter, for single-replica
+// installations which do without a database. Scan jobs are lost on restart, and never shared between replicas.
+package memory
+
+import (
+	"container/list"
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/clock"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	"golang.org/x/xerrors"
+)
+
+// entry is a scan job encoded like the scan jobs of the Redis store, so that callers never share its report,
+// next to its summary.
+type entry struct {
+	data      []byte
+	summary   job.Summary
+	updatedAt time.Time
+	expiresAt time.Time
+}
+
+// store keeps the entries in a list ordered from the most recently updated, which is the order of summaries, and
+// the reverse order of eviction once the store is full.
+type store struct {
+	cfg etc.MemoryStore
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+// Option customizes the store constructed with NewStore.
+type Option func(s *store)
+
+// WithClock expires scan jobs, and times their summaries, with the given Clock rather than the system time.
+func WithClock(c clock.Clock) Option {
+	return func(s *store) {
+		s.now = c.Now
+	}
+}
+
+func NewStore(cfg etc.MemoryStore, opts ...Option) persistence.Store {
+	s := &store{
+		cfg:     cfg,
+		now:     clock.System.Now,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *store) Create(ctx context.Context, scanJob job.ScanJob) error {
+	data, err := job.Marshal(scanJob)
+	if err != nil {
+		return xerrors.Errorf("marshalling scan job: %w", err)
+	}
+
+	slog.DebugContext(ctx, "Saving scan job",
+		slog.String("scan_job_id", scanJob.ID),
+		slog.String("scan_job_status", scanJob.Status.String()),
+		slog.Duration("expire", s.cfg.ScanJobTTL),
+	)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.evictExpired(now)
+	if _, ok := s.lookup(scanJob.ID, now); ok {
+		return xerrors.Errorf("creating scan job %s: %w", scanJob.ID, persistence.ErrScanJobExists)
+	}
+	for s.cfg.MaxScanJobs > 0 && s.order.Len() >= s.cfg.MaxScanJobs {
+		evicted := s.order.Remove(s.order.Back()).(*entry)
+		delete(s.entries, evicted.summary.ID)
+		slog.WarnContext(ctx, "Evicted scan job from full in-memory store", slog.String("scan_job_id", evicted.summary.ID),
+			slog.Int("max_scan_jobs", s.cfg.MaxScanJobs))
+	}
+
+	e := &entry{
+		data:      data,
+		summary:   job.Summary{ID: scanJob.ID, CreatedAt: now},
+		expiresAt: now.Add(s.cfg.ScanJobTTL),
+	}
+	s.save(e, scanJob, now)
+	s.entries[scanJob.ID] = s.order.PushFront(e)
+	return nil
+}
+
+func (s *store) Get(_ context.Context, scanJobID string) (*job.ScanJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.lookup(scanJobID, s.now())
+	if !ok {
+		return nil, nil
+	}
+
+	scanJob, err := job.Unmarshal(e.data)
+	if err != nil {
+		return nil, xerrors.Errorf("unmarshalling scan job: %w", err)
+	}
+	return &scanJob, nil
+}
+
+func (s *store) Replace(_ context.Context, scanJob job.ScanJob) error {
+	return s.update(scanJob.ID, func(existing *job.ScanJob) {
+		*existing = scanJob
+	})
+}
+
+func (s *store) UpdateStatus(ctx context.Context, scanJobID string, newStatus job.ScanJobStatus, error ...string) error {
+	slog.DebugContext(ctx, "Updating status for scan job", slog.String("scan_job_id", scanJobID),
+		slog.String("new_status", newStatus.String()),
+	)
+
+	return s.update(scanJobID, func(scanJob *job.ScanJob) {
+		scanJob.Status = newStatus
+		if len(error) > 0 {
+			scanJob.Error = error[0]
+		}
+	})
+}
+
+func (s *store) UpdateReport(ctx context.Context, scanJobID string, report harbor.ScanReport) error {
+	slog.DebugContext(ctx, "Updating reports for scan job", slog.String("scan_job_id", scanJobID))
+
+	return s.update(scanJobID, func(scanJob *job.ScanJob) {
+		scanJob.Report = report
+	})
+}
+
+func (s *store) UpdateSBOM(ctx context.Context, scanJobID string, sbom harbor.SBOMReport) error {
+	slog.DebugContext(ctx, "Updating SBOM for scan job", slog.String("scan_job_id", scanJobID))
+
+	return s.update(scanJobID, func(scanJob *job.ScanJob) {
+		scanJob.SBOM = &sbom
+	})
+}
+
+// update modifies the given scan job, and resets its TTL like the Redis store does.
+func (s *store) update(scanJobID string, modify func(*job.ScanJob)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	e, ok := s.lookup(scanJobID, now)
+	if !ok {
+		return xerrors.Errorf("scan job %s not found", scanJobID)
+	}
+	scanJob, err := job.Unmarshal(e.data)
+	if err != nil {
+		return xerrors.Errorf("unmarshalling scan job: %w", err)
+	}
+
+	modify(&scanJob)
+
+	if e.data, err = job.Marshal(scanJob); err != nil {
+		return xerrors.Errorf("marshalling scan job: %w", err)
+	}
+	e.expiresAt = now.Add(s.cfg.ScanJobTTL)
+	s.save(e, scanJob, now)
+	s.order.MoveToFront(s.entries[scanJobID])
+	return nil
+}
+
+// save updates the summary of the given entry with the given scan job, like the Redis store does.
+func (s *store) save(e *entry, scanJob job.ScanJob, now time.Time) {
+	e.updatedAt = now
+	e.summary.Status = scanJob.Status
+	e.summary.Error = scanJob.Error
+	switch scanJob.Status {
+	case job.Pending:
+		e.summary.StartedAt = now
+	case job.Finished, job.Failed:
+		e.summary.FinishedAt = now
+	}
+	e.summary.Counts = persistence.CountSeverities(scanJob.Report)
+}
+
+func (s *store) FindByStatus(_ context.Context, statuses ...job.ScanJobStatus) ([]job.ScanJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	var scanJobs []job.ScanJob
+	for el := s.order.Front(); el != nil; el = el.Next() {
+		e := el.Value.(*entry)
+		if !e.expiresAt.After(now) || !slices.Contains(statuses, e.summary.Status) {
+			continue
+		}
+		scanJob, err := job.Unmarshal(e.data)
+		if err != nil {
+			return nil, xerrors.Errorf("unmarshalling scan job: %w", err)
+		}
+		scanJobs = append(scanJobs, scanJob)
+	}
+	return scanJobs, nil
+}
+
+// Extend never shortens the expiry of the scan job, which is bounded by the configured TTL like the expiry of
+// scan jobs kept in Redis.
+func (s *store) Extend(_ context.Context, scanJobID string, ttl time.Duration) error {
+	if ttl < s.cfg.ScanJobTTL {
+		ttl = s.cfg.ScanJobTTL
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	e, ok := s.lookup(scanJobID, now)
+	if !ok {
+		return xerrors.Errorf("scan job %s not found", scanJobID)
+	}
+	if expiresAt := now.Add(ttl); expiresAt.After(e.expiresAt) {
+		e.expiresAt = expiresAt
+	}
+	return nil
+}
+
+func (s *store) ListSummaries(_ context.Context, limit int) ([]job.Summary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	summaries := make([]job.Summary, 0)
+	for el := s.order.Front(); el != nil && (limit <= 0 || len(summaries) < limit); el = el.Next() {
+		e := el.Value.(*entry)
+		if !e.expiresAt.After(now) {
+			continue
+		}
+		summaries = append(summaries, copySummary(e.summary))
+	}
+	return summaries, nil
+}
+
+// List copies the summaries of the matching scan jobs, and only decodes the artifacts of the listed page.
+func (s *store) List(_ context.Context, options persistence.ListOptions) ([]job.Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	matching := make(map[string]*entry)
+	listed := make([]job.Entry, 0)
+	for el := s.order.Front(); el != nil; el = el.Next() {
+		e := el.Value.(*entry)
+		if !e.expiresAt.After(now) || !options.Matches(job.Entry{Summary: e.summary}) {
+			continue
+		}
+		matching[e.summary.ID] = e
+		listed = append(listed, job.Entry{Summary: copySummary(e.summary)})
+	}
+	listed = options.Page(listed)
+
+	for i := range listed {
+		decoded, err := job.UnmarshalEntry(matching[listed[i].ID].data)
+		if err != nil {
+			return nil, xerrors.Errorf("unmarshalling scan job: %w", err)
+		}
+		listed[i].Artifact = decoded.Artifact
+	}
+	return listed, nil
+}
+
+// copySummary returns a copy of the given summary, which does not share its counts.
+func copySummary(summary job.Summary) job.Summary {
+	counts := make(map[string]int, len(summary.Counts))
+	for severity, count := range summary.Counts {
+		counts[severity] = count
+	}
+	summary.Counts = counts
+	return summary
+}
+
+// lookup returns the entry of the given scan job unless it expired.
+func (s *store) lookup(scanJobID string, now time.Time) (*entry, bool) {
+	el, ok := s.entries[scanJobID]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*entry)
+	if !e.expiresAt.After(now) {
+		return nil, false
+	}
+	return e, true
+}
+
+// evictExpired removes the expired entries from the least recently updated. Entries updated within the TTL cannot
+// have expired yet, hence the scan stops at the first of them.
+func (s *store) evictExpired(now time.Time) {
+	for el := s.order.Back(); el != nil; {
+		e := el.Value.(*entry)
+		if e.updatedAt.Add(s.cfg.ScanJobTTL).After(now) {
+			return
+		}
+		prev := el.Prev()
+		if !e.expiresAt.After(now) {
+			s.order.Remove(el)
+			delete(s.entries, e.summary.ID)
+		}
+		el = prev
+	}
+}
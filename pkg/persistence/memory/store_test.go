@@ -0,0 +1,191 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/clock"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStore(cfg etc.MemoryStore) (*store, *clock.Fake) {
+	c := clock.NewFake(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	return NewStore(cfg, WithClock(c)).(*store), c
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Should create, get and update scan job", func(t *testing.T) {
+		s, _ := newTestStore(etc.MemoryStore{ScanJobTTL: time.Hour, MaxScanJobs: 10})
+
+		require.NoError(t, s.Create(ctx, job.ScanJob{ID: "123", Status: job.Queued}))
+
+		err := s.Create(ctx, job.ScanJob{ID: "123", Status: job.Queued})
+		assert.True(t, errors.Is(err, persistence.ErrScanJobExists))
+
+		require.NoError(t, s.UpdateStatus(ctx, "123", job.Pending))
+		report := harbor.ScanReport{
+			Severity:        harbor.SevHigh,
+			Vulnerabilities: []harbor.VulnerabilityItem{{ID: "CVE-2013-1400", Severity: harbor.SevHigh}},
+		}
+		require.NoError(t, s.UpdateReport(ctx, "123", report))
+		require.NoError(t, s.UpdateStatus(ctx, "123", job.Finished))
+
+		scanJob, err := s.Get(ctx, "123")
+		require.NoError(t, err)
+		assert.Equal(t, &job.ScanJob{ID: "123", Status: job.Finished, Report: report}, scanJob)
+
+		scanJob.Report.Vulnerabilities[0].ID = "CVE-2019-1111"
+		scanJob, err = s.Get(ctx, "123")
+		require.NoError(t, err)
+		assert.Equal(t, "CVE-2013-1400", scanJob.Report.Vulnerabilities[0].ID, "scan jobs should not be shared")
+
+		assert.EqualError(t, s.UpdateStatus(ctx, "unknown", job.Finished), "scan job unknown not found")
+	})
+
+	t.Run("Should replace scan job", func(t *testing.T) {
+		s, _ := newTestStore(etc.MemoryStore{ScanJobTTL: time.Hour, MaxScanJobs: 10})
+
+		require.NoError(t, s.Create(ctx, job.ScanJob{ID: "123", Status: job.Failed, Error: "running tunnel: exit status 1",
+			Request: &harbor.ScanRequest{Artifact: harbor.Artifact{Repository: "library/mongo", Digest: "sha256:917f5b7f"}}}))
+
+		replacement := job.ScanJob{
+			ID:      "123",
+			Status:  job.Queued,
+			Request: &harbor.ScanRequest{Artifact: harbor.Artifact{Repository: "library/mongo", Digest: "sha256:2a8ba8b4"}},
+		}
+		require.NoError(t, s.Replace(ctx, replacement))
+
+		scanJob, err := s.Get(ctx, "123")
+		require.NoError(t, err)
+		assert.Equal(t, &replacement, scanJob)
+
+		assert.EqualError(t, s.Replace(ctx, job.ScanJob{ID: "unknown"}), "scan job unknown not found")
+	})
+
+	t.Run("Should expire scan jobs", func(t *testing.T) {
+		s, c := newTestStore(etc.MemoryStore{ScanJobTTL: time.Hour, MaxScanJobs: 10})
+
+		require.NoError(t, s.Create(ctx, job.ScanJob{ID: "running", Status: job.Pending}))
+		require.NoError(t, s.Create(ctx, job.ScanJob{ID: "queued", Status: job.Queued}))
+		require.NoError(t, s.Extend(ctx, "running", 3*time.Hour))
+
+		c.Advance(2 * time.Hour)
+
+		scanJob, err := s.Get(ctx, "queued")
+		require.NoError(t, err)
+		assert.Nil(t, scanJob, "scan job should have expired")
+		scanJob, err = s.Get(ctx, "running")
+		require.NoError(t, err)
+		assert.NotNil(t, scanJob, "extended scan job should not have expired")
+
+		assert.EqualError(t, s.Extend(ctx, "queued", time.Hour), "scan job queued not found")
+		require.NoError(t, s.Create(ctx, job.ScanJob{ID: "queued", Status: job.Queued}),
+			"expired scan job should be replaced")
+		assert.Len(t, s.entries, 2, "expired scan jobs should be evicted")
+	})
+
+	t.Run("Should evict least recently updated scan jobs when full", func(t *testing.T) {
+		s, c := newTestStore(etc.MemoryStore{ScanJobTTL: time.Hour, MaxScanJobs: 2})
+
+		require.NoError(t, s.Create(ctx, job.ScanJob{ID: "mongo", Status: job.Queued}))
+		c.Advance(time.Second)
+		require.NoError(t, s.Create(ctx, job.ScanJob{ID: "nginx", Status: job.Queued}))
+		c.Advance(time.Second)
+		require.NoError(t, s.UpdateStatus(ctx, "mongo", job.Pending))
+		c.Advance(time.Second)
+		require.NoError(t, s.Create(ctx, job.ScanJob{ID: "redis", Status: job.Queued}))
+
+		scanJob, err := s.Get(ctx, "nginx")
+		require.NoError(t, err)
+		assert.Nil(t, scanJob, "least recently updated scan job should be evicted")
+
+		scanJobs, err := s.FindByStatus(ctx, job.Queued, job.Pending)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []job.ScanJob{
+			{ID: "mongo", Status: job.Pending},
+			{ID: "redis", Status: job.Queued},
+		}, scanJobs)
+	})
+
+	t.Run("Should list summaries from the most recently updated", func(t *testing.T) {
+		s, c := newTestStore(etc.MemoryStore{ScanJobTTL: time.Hour, MaxScanJobs: 10})
+		createdAt := c.Now()
+
+		require.NoError(t, s.Create(ctx, job.ScanJob{ID: "mongo", Status: job.Queued}))
+		require.NoError(t, s.Create(ctx, job.ScanJob{ID: "nginx", Status: job.Queued}))
+		c.Advance(time.Second)
+		require.NoError(t, s.UpdateStatus(ctx, "mongo", job.Pending))
+		require.NoError(t, s.UpdateReport(ctx, "mongo", harbor.ScanReport{
+			Vulnerabilities: []harbor.VulnerabilityItem{
+				{ID: "CVE-2019-1549", Severity: harbor.SevHigh},
+				{ID: "CVE-2019-14697", Severity: harbor.SevHigh},
+				{ID: "CVE-2019-1547", Severity: harbor.SevLow},
+			},
+		}))
+		c.Advance(time.Second)
+		require.NoError(t, s.UpdateStatus(ctx, "mongo", job.Finished))
+
+		summaries, err := s.ListSummaries(ctx, 0)
+		require.NoError(t, err)
+		require.Len(t, summaries, 2)
+		assert.Equal(t, job.Summary{
+			ID:     "mongo",
+			Status: job.Finished,
+			Counts: map[string]int{
+				"Unknown":  0,
+				"Low":      1,
+				"Medium":   0,
+				"High":     2,
+				"Critical": 0,
+			},
+			CreatedAt:  createdAt,
+			StartedAt:  createdAt.Add(time.Second),
+			FinishedAt: createdAt.Add(2 * time.Second),
+		}, summaries[0])
+		assert.Equal(t, "nginx", summaries[1].ID)
+		assert.True(t, summaries[1].StartedAt.IsZero())
+
+		summaries, err = s.ListSummaries(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, summaries, 1)
+		assert.Equal(t, "mongo", summaries[0].ID)
+	})
+	t.Run("Should list pages of scan jobs by ID", func(t *testing.T) {
+		s, c := newTestStore(etc.MemoryStore{ScanJobTTL: time.Hour, MaxScanJobs: 10})
+		artifact := harbor.Artifact{Repository: "library/mongo", Digest: "sha256:917f"}
+
+		require.NoError(t, s.Create(ctx, job.ScanJob{ID: "3", Status: job.Queued}))
+		require.NoError(t, s.Create(ctx, job.ScanJob{ID: "1", Status: job.Queued,
+			Request: &harbor.ScanRequest{Artifact: artifact}}))
+		require.NoError(t, s.Create(ctx, job.ScanJob{ID: "2", Status: job.Queued}))
+		require.NoError(t, s.UpdateStatus(ctx, "2", job.Failed, "out of memory"))
+
+		entries, err := s.List(ctx, persistence.ListOptions{Limit: 2})
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		assert.Equal(t, "1", entries[0].ID)
+		assert.Equal(t, &artifact, entries[0].Artifact)
+		assert.Equal(t, c.Now(), entries[0].CreatedAt)
+		assert.Equal(t, "2", entries[1].ID)
+		assert.Equal(t, "out of memory", entries[1].Error)
+
+		entries, err = s.List(ctx, persistence.ListOptions{After: "2", Limit: 2})
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "3", entries[0].ID)
+
+		entries, err = s.List(ctx, persistence.ListOptions{Statuses: []job.ScanJobStatus{job.Failed}})
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, job.Failed, entries[0].Status)
+	})
+}
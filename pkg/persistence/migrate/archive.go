@@ -0,0 +1,112 @@
+package migrate
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+)
+
+// archiveDir is the directory of the archive holding a JSON file per scan job.
+const archiveDir = "scan-jobs"
+
+// maxArchivedScanJobSize bounds the size of a scan job read from an archive.
+const maxArchivedScanJobSize = 256 << 20
+
+// Export writes all scan jobs of the given Store, including their reports, to a gzipped tarball holding a JSON file
+// per scan job, which are encoded like stored scan jobs. The registry authorizations of their scan requests are left
+// out, so that archives never hold registry credentials. It returns the number of exported scan jobs.
+func Export(ctx context.Context, store persistence.Store, w io.Writer) (int, error) {
+	scanJobs, err := store.FindByStatus(ctx, allStatuses...)
+	if err != nil {
+		return 0, fmt.Errorf("listing scan jobs: %w", err)
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	now := time.Now()
+	for _, scanJob := range scanJobs {
+		if scanJob.Request != nil {
+			request := *scanJob.Request
+			request.Registry.Authorization = ""
+			scanJob.Request = &request
+		}
+		data, err := job.Marshal(scanJob)
+		if err != nil {
+			return 0, fmt.Errorf("marshalling scan job %s: %w", scanJob.ID, err)
+		}
+		if err = tw.WriteHeader(&tar.Header{
+			Name:    path.Join(archiveDir, scanJob.ID+".json"),
+			Mode:    0600,
+			Size:    int64(len(data)),
+			ModTime: now,
+		}); err != nil {
+			return 0, fmt.Errorf("writing scan job %s: %w", scanJob.ID, err)
+		}
+		if _, err = tw.Write(data); err != nil {
+			return 0, fmt.Errorf("writing scan job %s: %w", scanJob.ID, err)
+		}
+	}
+	if err = tw.Close(); err != nil {
+		return 0, fmt.Errorf("closing archive: %w", err)
+	}
+	if err = gw.Close(); err != nil {
+		return 0, fmt.Errorf("closing archive: %w", err)
+	}
+	return len(scanJobs), nil
+}
+
+// Import copies the scan jobs of a tarball written by Export to the given Store. Like Migrate, it can be run
+// repeatedly, scan jobs already imported are left as is, while outdated copies are brought up to date. Scan jobs
+// exported by an older version of the adapter are upgraded to the current schema version.
+func Import(ctx context.Context, store persistence.Store, r io.Reader) (Result, error) {
+	var result Result
+
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return result, fmt.Errorf("reading archive: %w", err)
+	}
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return result, nil
+		}
+		if err != nil {
+			return result, fmt.Errorf("reading archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg || path.Dir(header.Name) != archiveDir || !strings.HasSuffix(header.Name, ".json") {
+			continue
+		}
+		if header.Size > maxArchivedScanJobSize {
+			return result, fmt.Errorf("archived scan job %s exceeds %d bytes", header.Name, maxArchivedScanJobSize)
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return result, fmt.Errorf("reading archived scan job %s: %w", header.Name, err)
+		}
+		scanJob, err := job.Unmarshal(data)
+		if err != nil {
+			return result, fmt.Errorf("unmarshalling archived scan job %s: %w", header.Name, err)
+		}
+
+		copied, err := copyScanJob(ctx, store, scanJob)
+		if err != nil {
+			return result, fmt.Errorf("importing scan job %s: %w", scanJob.ID, err)
+		}
+		if copied {
+			result.Copied++
+		} else {
+			result.Unchanged++
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Package migrate moves scan jobs between persistence backends without downtime.
+//
+// A migration runs in two steps. First, the adapter is restarted with a dual-write Store, which keeps serving
+// reads from the current backend and mirrors every write to the new one. Then, Migrate copies the scan jobs
+// created before dual-write was enabled and verifies the copies. Once verified, the adapter can be switched
+// to the new backend.
+package migrate
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+)
+
+type dualWriteStore struct {
+	primary   persistence.Store
+	secondary persistence.Store
+}
+
+// NewDualWriteStore constructs a Store, which reads from the primary Store and writes to both. Writes to the
+// secondary Store are best effort: failures are logged and never fail the request.
+func NewDualWriteStore(primary, secondary persistence.Store) persistence.Store {
+	return &dualWriteStore{
+		primary:   primary,
+		secondary: secondary,
+	}
+}
+
+func (s *dualWriteStore) Create(ctx context.Context, scanJob job.ScanJob) error {
+	if err := s.primary.Create(ctx, scanJob); err != nil {
+		return err
+	}
+	if err := s.secondary.Create(ctx, scanJob); err != nil {
+		slog.WarnContext(ctx, "Error while mirroring scan job creation", slog.String("scan_job_id", scanJob.ID),
+			slog.String("err", err.Error()))
+	}
+	return nil
+}
+
+func (s *dualWriteStore) Get(ctx context.Context, scanJobID string) (*job.ScanJob, error) {
+	return s.primary.Get(ctx, scanJobID)
+}
+
+func (s *dualWriteStore) UpdateStatus(ctx context.Context, scanJobID string, newStatus job.ScanJobStatus, error ...string) error {
+	if err := s.primary.UpdateStatus(ctx, scanJobID, newStatus, error...); err != nil {
+		return err
+	}
+	if err := s.secondary.UpdateStatus(ctx, scanJobID, newStatus, error...); err != nil {
+		s.copy(ctx, scanJobID, err)
+	}
+	return nil
+}
+
+func (s *dualWriteStore) UpdateReport(ctx context.Context, scanJobID string, report harbor.ScanReport) error {
+	if err := s.primary.UpdateReport(ctx, scanJobID, report); err != nil {
+		return err
+	}
+	if err := s.secondary.UpdateReport(ctx, scanJobID, report); err != nil {
+		s.copy(ctx, scanJobID, err)
+	}
+	return nil
+}
+
+func (s *dualWriteStore) Replace(ctx context.Context, scanJob job.ScanJob) error {
+	if err := s.primary.Replace(ctx, scanJob); err != nil {
+		return err
+	}
+	if err := s.secondary.Replace(ctx, scanJob); err != nil {
+		s.copy(ctx, scanJob.ID, err)
+	}
+	return nil
+}
+
+func (s *dualWriteStore) UpdateSBOM(ctx context.Context, scanJobID string, sbom harbor.SBOMReport) error {
+	if err := s.primary.UpdateSBOM(ctx, scanJobID, sbom); err != nil {
+		return err
+	}
+	if err := s.secondary.UpdateSBOM(ctx, scanJobID, sbom); err != nil {
+		s.copy(ctx, scanJobID, err)
+	}
+	return nil
+}
+
+func (s *dualWriteStore) FindByStatus(ctx context.Context, statuses ...job.ScanJobStatus) ([]job.ScanJob, error) {
+	return s.primary.FindByStatus(ctx, statuses...)
+}
+
+func (s *dualWriteStore) Extend(ctx context.Context, scanJobID string, ttl time.Duration) error {
+	if err := s.primary.Extend(ctx, scanJobID, ttl); err != nil {
+		return err
+	}
+	if err := s.secondary.Extend(ctx, scanJobID, ttl); err != nil {
+		slog.DebugContext(ctx, "Error while mirroring scan job extension", slog.String("scan_job_id", scanJobID),
+			slog.String("err", err.Error()))
+	}
+	return nil
+}
+
+func (s *dualWriteStore) ListSummaries(ctx context.Context, limit int) ([]job.Summary, error) {
+	return s.primary.ListSummaries(ctx, limit)
+}
+
+func (s *dualWriteStore) List(ctx context.Context, options persistence.ListOptions) ([]job.Entry, error) {
+	return s.primary.List(ctx, options)
+}
+
+// copy copies the whole scan job to the secondary Store after an update failed, which is expected for
+// scan jobs created before dual-write was enabled.
+func (s *dualWriteStore) copy(ctx context.Context, scanJobID string, updateErr error) {
+	logger := slog.With(slog.String("scan_job_id", scanJobID))
+	logger.Debug("Copying scan job after mirroring update failed", slog.String("err", updateErr.Error()))
+
+	scanJob, err := s.primary.Get(ctx, scanJobID)
+	if err != nil || scanJob == nil {
+		logger.Warn("Error while mirroring scan job update, scan job cannot be read", slog.Any("err", err))
+		return
+	}
+	if err = s.secondary.Create(ctx, *scanJob); err != nil {
+		logger.Warn("Error while mirroring scan job update", slog.String("err", err.Error()))
+	}
+}
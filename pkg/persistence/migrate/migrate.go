@@ -0,0 +1,116 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+)
+
+// allStatuses lists every scan job status, so that FindByStatus returns all scan jobs.
+var allStatuses = []job.ScanJobStatus{job.Queued, job.Pending, job.Finished, job.Failed}
+
+// Result summarises a migration.
+type Result struct {
+	// Copied is the number of scan jobs created or updated in the target Store.
+	Copied int
+	// Unchanged is the number of scan jobs already identical in the target Store.
+	Unchanged int
+	// Mismatched lists the IDs of scan jobs whose copy differs from the source after the migration.
+	Mismatched []string
+}
+
+// Verified returns true if every scan job has been copied successfully.
+func (r Result) Verified() bool {
+	return len(r.Mismatched) == 0
+}
+
+// Migrate copies all scan jobs from the source Store to the target Store, and verifies that each copy
+// is identical to the source. It can be run repeatedly, scan jobs already migrated are left as is.
+func Migrate(ctx context.Context, source, target persistence.Store) (Result, error) {
+	var result Result
+
+	scanJobs, err := source.FindByStatus(ctx, allStatuses...)
+	if err != nil {
+		return result, fmt.Errorf("listing source scan jobs: %w", err)
+	}
+
+	for _, scanJob := range scanJobs {
+		copied, err := copyScanJob(ctx, target, scanJob)
+		if err != nil {
+			return result, fmt.Errorf("copying scan job %s: %w", scanJob.ID, err)
+		}
+		if copied {
+			result.Copied++
+		} else {
+			result.Unchanged++
+		}
+
+		verified, err := verify(ctx, source, target, scanJob.ID)
+		if err != nil {
+			return result, fmt.Errorf("verifying scan job %s: %w", scanJob.ID, err)
+		}
+		if !verified {
+			slog.Warn("Migrated scan job differs from source", slog.String("scan_job_id", scanJob.ID))
+			result.Mismatched = append(result.Mismatched, scanJob.ID)
+		}
+	}
+
+	return result, nil
+}
+
+// copyScanJob creates the scan job in the target Store, or replaces an existing copy which differs from it.
+func copyScanJob(ctx context.Context, target persistence.Store, scanJob job.ScanJob) (bool, error) {
+	existing, err := target.Get(ctx, scanJob.ID)
+	if err != nil {
+		return false, err
+	}
+	if existing == nil {
+		return true, target.Create(ctx, scanJob)
+	}
+
+	equal, err := equalScanJobs(*existing, scanJob)
+	if err != nil || equal {
+		return false, err
+	}
+	return true, target.Replace(ctx, scanJob)
+}
+
+// verify compares the copy of the scan job with the source. The source is read again, so that scan jobs
+// updated by dual-write while being migrated are not reported as mismatched.
+func verify(ctx context.Context, source, target persistence.Store, scanJobID string) (bool, error) {
+	original, err := source.Get(ctx, scanJobID)
+	if err != nil {
+		return false, err
+	}
+	if original == nil {
+		// Expired in the source meanwhile, there is nothing left to compare with.
+		return true, nil
+	}
+
+	migrated, err := target.Get(ctx, scanJobID)
+	if err != nil {
+		return false, err
+	}
+	if migrated == nil {
+		return false, nil
+	}
+	return equalScanJobs(*original, *migrated)
+}
+
+// equalScanJobs compares the serialized forms of scan jobs, which are what backends persist.
+func equalScanJobs(a, b job.ScanJob) (bool, error) {
+	aJSON, err := json.Marshal(a)
+	if err != nil {
+		return false, err
+	}
+	bJSON, err := json.Marshal(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(aJSON, bJSON), nil
+}
@@ -0,0 +1,242 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore is a map backed persistence.Store.
+type fakeStore struct {
+	mu       sync.Mutex
+	scanJobs map[string]job.ScanJob
+	err      error
+	// stale drops the replacements of scan jobs, so that they are not brought up to date.
+	stale bool
+}
+
+func newFakeStore(scanJobs ...job.ScanJob) *fakeStore {
+	s := &fakeStore{scanJobs: make(map[string]job.ScanJob)}
+	for _, scanJob := range scanJobs {
+		s.scanJobs[scanJob.ID] = scanJob
+	}
+	return s
+}
+
+func (s *fakeStore) Create(_ context.Context, scanJob job.ScanJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	if _, ok := s.scanJobs[scanJob.ID]; !ok {
+		s.scanJobs[scanJob.ID] = scanJob
+	}
+	return nil
+}
+
+func (s *fakeStore) Get(_ context.Context, scanJobID string) (*job.ScanJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scanJob, ok := s.scanJobs[scanJobID]
+	if !ok {
+		return nil, nil
+	}
+	return &scanJob, nil
+}
+
+func (s *fakeStore) UpdateStatus(_ context.Context, scanJobID string, newStatus job.ScanJobStatus, error ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scanJob, ok := s.scanJobs[scanJobID]
+	if !ok {
+		return fmt.Errorf("scan job %s not found", scanJobID)
+	}
+	scanJob.Status = newStatus
+	if len(error) > 0 {
+		scanJob.Error = error[0]
+	}
+	s.scanJobs[scanJobID] = scanJob
+	return nil
+}
+
+func (s *fakeStore) Replace(_ context.Context, scanJob job.ScanJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.scanJobs[scanJob.ID]; !ok {
+		return fmt.Errorf("scan job %s not found", scanJob.ID)
+	}
+	if !s.stale {
+		s.scanJobs[scanJob.ID] = scanJob
+	}
+	return nil
+}
+
+func (s *fakeStore) UpdateReport(_ context.Context, scanJobID string, report harbor.ScanReport) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scanJob, ok := s.scanJobs[scanJobID]
+	if !ok {
+		return fmt.Errorf("scan job %s not found", scanJobID)
+	}
+	scanJob.Report = report
+	s.scanJobs[scanJobID] = scanJob
+	return nil
+}
+
+func (s *fakeStore) UpdateSBOM(_ context.Context, scanJobID string, sbom harbor.SBOMReport) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scanJob, ok := s.scanJobs[scanJobID]
+	if !ok {
+		return fmt.Errorf("scan job %s not found", scanJobID)
+	}
+	scanJob.SBOM = &sbom
+	s.scanJobs[scanJobID] = scanJob
+	return nil
+}
+
+func (s *fakeStore) FindByStatus(_ context.Context, statuses ...job.ScanJobStatus) ([]job.ScanJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var scanJobs []job.ScanJob
+	for _, scanJob := range s.scanJobs {
+		if slices.Contains(statuses, scanJob.Status) {
+			scanJobs = append(scanJobs, scanJob)
+		}
+	}
+	sort.Slice(scanJobs, func(i, j int) bool { return scanJobs[i].ID < scanJobs[j].ID })
+	return scanJobs, nil
+}
+
+func (s *fakeStore) Extend(_ context.Context, scanJobID string, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.scanJobs[scanJobID]; !ok {
+		return fmt.Errorf("scan job %s not found", scanJobID)
+	}
+	return nil
+}
+
+func (s *fakeStore) ListSummaries(_ context.Context, _ int) ([]job.Summary, error) {
+	return nil, nil
+}
+
+func (s *fakeStore) List(_ context.Context, _ persistence.ListOptions) ([]job.Entry, error) {
+	return nil, nil
+}
+
+var report = harbor.ScanReport{
+	GeneratedAt: time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC),
+	Severity:    harbor.SevHigh,
+	Vulnerabilities: []harbor.VulnerabilityItem{
+		{ID: "CVE-2019-1549", Pkg: "openssl", Version: "1.1.1c-r0", Severity: harbor.SevHigh},
+	},
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Should copy and verify scan jobs", func(t *testing.T) {
+		source := newFakeStore(
+			job.ScanJob{ID: "1", Status: job.Queued},
+			job.ScanJob{ID: "2", Status: job.Finished, Report: report},
+			job.ScanJob{ID: "3", Status: job.Failed, Error: "running tunnel: exit status 1"},
+		)
+		target := newFakeStore(
+			job.ScanJob{ID: "1", Status: job.Queued},
+			job.ScanJob{ID: "2", Status: job.Pending},
+		)
+
+		result, err := Migrate(ctx, source, target)
+		require.NoError(t, err)
+
+		assert.Equal(t, Result{Copied: 2, Unchanged: 1}, result)
+		assert.True(t, result.Verified())
+		assert.Equal(t, source.scanJobs, target.scanJobs)
+	})
+
+	t.Run("Should replace whole scan jobs which differ", func(t *testing.T) {
+		request := &harbor.ScanRequest{Artifact: harbor.Artifact{Repository: "library/mongo", Digest: "sha256:917f5b7f"}}
+		source := newFakeStore(job.ScanJob{ID: "1", Status: job.Queued, Request: request,
+			TraceContext: map[string]string{"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"}})
+		target := newFakeStore(job.ScanJob{ID: "1", Status: job.Queued, Request: &harbor.ScanRequest{}})
+
+		result, err := Migrate(ctx, source, target)
+		require.NoError(t, err)
+
+		assert.Equal(t, Result{Copied: 1}, result)
+		assert.Equal(t, source.scanJobs, target.scanJobs)
+	})
+
+	t.Run("Should report scan jobs which cannot be copied", func(t *testing.T) {
+		source := newFakeStore(job.ScanJob{ID: "1", Status: job.Queued})
+		target := newFakeStore(job.ScanJob{ID: "1", Status: job.Queued, Request: &harbor.ScanRequest{}})
+		target.stale = true
+
+		result, err := Migrate(ctx, source, target)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"1"}, result.Mismatched)
+		assert.False(t, result.Verified())
+	})
+
+	t.Run("Should return error when target fails", func(t *testing.T) {
+		source := newFakeStore(job.ScanJob{ID: "1", Status: job.Queued})
+		target := newFakeStore()
+		target.err = errors.New("connection refused")
+
+		_, err := Migrate(ctx, source, target)
+		assert.EqualError(t, err, "copying scan job 1: connection refused")
+	})
+}
+
+func TestDualWriteStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Should mirror writes to secondary store", func(t *testing.T) {
+		primary, secondary := newFakeStore(), newFakeStore()
+		store := NewDualWriteStore(primary, secondary)
+
+		require.NoError(t, store.Create(ctx, job.ScanJob{ID: "1", Status: job.Queued}))
+		require.NoError(t, store.UpdateStatus(ctx, "1", job.Finished))
+		require.NoError(t, store.UpdateReport(ctx, "1", report))
+
+		assert.Equal(t, job.ScanJob{ID: "1", Status: job.Finished, Report: report}, secondary.scanJobs["1"])
+		assert.Equal(t, primary.scanJobs, secondary.scanJobs)
+	})
+
+	t.Run("Should copy scan job created before dual-write was enabled", func(t *testing.T) {
+		primary := newFakeStore(job.ScanJob{ID: "1", Status: job.Pending})
+		secondary := newFakeStore()
+		store := NewDualWriteStore(primary, secondary)
+
+		require.NoError(t, store.UpdateStatus(ctx, "1", job.Failed, "timeout"))
+
+		assert.Equal(t, job.ScanJob{ID: "1", Status: job.Failed, Error: "timeout"}, secondary.scanJobs["1"])
+	})
+
+	t.Run("Should not fail when secondary store fails", func(t *testing.T) {
+		primary, secondary := newFakeStore(), newFakeStore()
+		secondary.err = errors.New("connection refused")
+		store := NewDualWriteStore(primary, secondary)
+
+		require.NoError(t, store.Create(ctx, job.ScanJob{ID: "1", Status: job.Queued}))
+
+		scanJob, err := store.Get(ctx, "1")
+		require.NoError(t, err)
+		assert.Equal(t, &job.ScanJob{ID: "1", Status: job.Queued}, scanJob)
+		assert.Empty(t, secondary.scanJobs)
+	})
+}
@@ -0,0 +1,81 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"golang.org/x/xerrors"
+)
+
+// migrationLockID is the key of the advisory lock serializing the migrations of concurrently starting replicas.
+const migrationLockID = 7263091
+
+// migrations are the schema migrations, applied in order. Applied migrations must never be edited, changes to the
+// schema are appended as new migrations.
+var migrations = []string{
+	// 1: Scan jobs are kept encoded like the scan jobs of the Redis store, next to the columns of their summaries.
+	`CREATE TABLE scan_jobs (
+		id          TEXT PRIMARY KEY,
+		status      SMALLINT NOT NULL,
+		error       TEXT NOT NULL DEFAULT '',
+		data        BYTEA NOT NULL,
+		counts      JSONB NOT NULL DEFAULT '{}',
+		created_at  TIMESTAMPTZ NOT NULL,
+		updated_at  TIMESTAMPTZ NOT NULL,
+		started_at  TIMESTAMPTZ,
+		finished_at TIMESTAMPTZ,
+		expires_at  TIMESTAMPTZ NOT NULL
+	);
+	CREATE INDEX scan_jobs_status_idx ON scan_jobs (status);
+	CREATE INDEX scan_jobs_updated_at_idx ON scan_jobs (updated_at);
+	CREATE INDEX scan_jobs_expires_at_idx ON scan_jobs (expires_at);`,
+}
+
+// Migrate applies the schema migrations which have not been applied yet, each within its own transaction. The
+// applied migrations are recorded in the scanner_schema_migrations table.
+func Migrate(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS scanner_schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`); err != nil {
+		return xerrors.Errorf("creating schema migrations table: %w", err)
+	}
+
+	for i, migration := range migrations {
+		if err := migrate(ctx, db, i+1, migration); err != nil {
+			return xerrors.Errorf("applying schema migration %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func migrate(ctx context.Context, db *sql.DB, version int, migration string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLockID); err != nil {
+		return xerrors.Errorf("locking schema migrations: %w", err)
+	}
+	var applied bool
+	if err = tx.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM scanner_schema_migrations WHERE version = $1)`, version,
+	).Scan(&applied); err != nil {
+		return err
+	}
+	if applied {
+		return nil
+	}
+
+	if _, err = tx.ExecContext(ctx, migration); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `INSERT INTO scanner_schema_migrations (version) VALUES ($1)`, version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,44 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"golang.org/x/xerrors"
+)
+
+// Reap deletes the expired scan jobs at the configured interval until the given context is done. Expired scan
+// jobs are already ignored by the store, hence a failure only delays their deletion to the next tick.
+func Reap(ctx context.Context, db *sql.DB, cfg etc.PostgresStore, opts ...Option) {
+	clk := newOptions(opts).clock
+	ticker := time.NewTicker(cfg.ReaperInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := DeleteExpired(ctx, db, clk.Now())
+			if err != nil {
+				slog.Warn("Error while deleting expired scan jobs", slog.String("err", err.Error()))
+				continue
+			}
+			if deleted > 0 {
+				slog.Debug("Deleted expired scan jobs", slog.Int64("count", deleted))
+			}
+		}
+	}
+}
+
+// DeleteExpired deletes the scan jobs expired at the given time, and returns the number of deleted scan jobs.
+func DeleteExpired(ctx context.Context, db *sql.DB, now time.Time) (int64, error) {
+	res, err := db.ExecContext(ctx, `DELETE FROM scan_jobs WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, xerrors.Errorf("deleting expired scan jobs: %w", err)
+	}
+	return res.RowsAffected()
+}
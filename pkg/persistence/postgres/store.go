@@ -0,0 +1,368 @@
+// Package postgres implements the persistence.Store backed by a PostgreSQL database, for installations which
+// prefer to keep scan jobs in the database they already operate and back up.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/clock"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	"github.com/lib/pq"
+	"golang.org/x/xerrors"
+)
+
+// store keeps each scan job encoded like the Redis store does, next to the columns of its summary, so that
+// listing scan jobs never decodes their reports. Expired scan jobs are ignored until deleted by the Reaper.
+type store struct {
+	cfg etc.PostgresStore
+	db  *sql.DB
+	now func() time.Time
+}
+
+// Option customizes the store constructed with NewStore, and the deletions of Reap.
+type Option func(o *options)
+
+type options struct {
+	clock clock.Clock
+}
+
+// WithClock expires scan jobs, and times their summaries, with the given Clock rather than the system time.
+func WithClock(c clock.Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{clock: clock.System}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+func NewStore(cfg etc.PostgresStore, db *sql.DB, opts ...Option) persistence.Store {
+	return &store{cfg: cfg, db: db, now: newOptions(opts).clock.Now}
+}
+
+// Create inserts the given scan job, or replaces a scan job with the same ID which already expired but has not
+// been deleted yet.
+func (s *store) Create(ctx context.Context, scanJob job.ScanJob) error {
+	data, err := job.Marshal(scanJob)
+	if err != nil {
+		return xerrors.Errorf("marshalling scan job: %w", err)
+	}
+	counts, err := countSeverities(scanJob)
+	if err != nil {
+		return err
+	}
+
+	slog.DebugContext(ctx, "Saving scan job",
+		slog.String("scan_job_id", scanJob.ID),
+		slog.String("scan_job_status", scanJob.Status.String()),
+		slog.Duration("expire", s.cfg.ScanJobTTL),
+	)
+
+	now := s.now()
+	res, err := s.db.ExecContext(ctx, `INSERT INTO scan_jobs
+		(id, status, error, data, counts, created_at, updated_at, started_at, finished_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6, NULL, NULL, $7)
+		ON CONFLICT (id) DO UPDATE SET
+			status = EXCLUDED.status, error = EXCLUDED.error, data = EXCLUDED.data, counts = EXCLUDED.counts,
+			created_at = EXCLUDED.created_at, updated_at = EXCLUDED.updated_at, started_at = NULL,
+			finished_at = NULL, expires_at = EXCLUDED.expires_at
+		WHERE scan_jobs.expires_at <= $6`,
+		scanJob.ID, int(scanJob.Status), scanJob.Error, data, counts, now, now.Add(s.cfg.ScanJobTTL),
+	)
+	if err != nil {
+		return xerrors.Errorf("creating scan job: %w", err)
+	}
+	created, err := res.RowsAffected()
+	if err != nil {
+		return xerrors.Errorf("creating scan job: %w", err)
+	}
+	if created == 0 {
+		return xerrors.Errorf("creating scan job %s: %w", scanJob.ID, persistence.ErrScanJobExists)
+	}
+	return nil
+}
+
+func (s *store) Get(ctx context.Context, scanJobID string) (*job.ScanJob, error) {
+	var data []byte
+	err := s.db.QueryRowContext(ctx, `SELECT data FROM scan_jobs WHERE id = $1 AND expires_at > $2`,
+		scanJobID, s.now()).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	scanJob, err := job.Unmarshal(data)
+	if err != nil {
+		return nil, xerrors.Errorf("unmarshalling scan job: %w", err)
+	}
+
+	return &scanJob, nil
+}
+
+func (s *store) Replace(ctx context.Context, scanJob job.ScanJob) error {
+	return s.update(ctx, scanJob.ID, func(existing *job.ScanJob) {
+		*existing = scanJob
+	})
+}
+
+func (s *store) UpdateStatus(ctx context.Context, scanJobID string, newStatus job.ScanJobStatus, error ...string) error {
+	slog.DebugContext(ctx, "Updating status for scan job", slog.String("scan_job_id", scanJobID),
+		slog.String("new_status", newStatus.String()),
+	)
+
+	return s.update(ctx, scanJobID, func(scanJob *job.ScanJob) {
+		scanJob.Status = newStatus
+		if len(error) > 0 {
+			scanJob.Error = error[0]
+		}
+	})
+}
+
+func (s *store) UpdateReport(ctx context.Context, scanJobID string, report harbor.ScanReport) error {
+	slog.DebugContext(ctx, "Updating reports for scan job", slog.String("scan_job_id", scanJobID))
+
+	return s.update(ctx, scanJobID, func(scanJob *job.ScanJob) {
+		scanJob.Report = report
+	})
+}
+
+func (s *store) UpdateSBOM(ctx context.Context, scanJobID string, sbom harbor.SBOMReport) error {
+	slog.DebugContext(ctx, "Updating SBOM for scan job", slog.String("scan_job_id", scanJobID))
+
+	return s.update(ctx, scanJobID, func(scanJob *job.ScanJob) {
+		scanJob.SBOM = &sbom
+	})
+}
+
+// update reads the given scan job, locking its row until the modified scan job is written back, so that
+// concurrent updates are never lost. Like the Redis store, updates reset the TTL of the scan job.
+func (s *store) update(ctx context.Context, scanJobID string, modify func(*job.ScanJob)) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return xerrors.Errorf("updating scan job: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	now := s.now()
+	var data []byte
+	err = tx.QueryRowContext(ctx, `SELECT data FROM scan_jobs WHERE id = $1 AND expires_at > $2 FOR UPDATE`,
+		scanJobID, now).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return xerrors.Errorf("scan job %s not found", scanJobID)
+	} else if err != nil {
+		return xerrors.Errorf("getting scan job: %w", err)
+	}
+	scanJob, err := job.Unmarshal(data)
+	if err != nil {
+		return xerrors.Errorf("unmarshalling scan job: %w", err)
+	}
+
+	modify(&scanJob)
+
+	if data, err = job.Marshal(scanJob); err != nil {
+		return xerrors.Errorf("marshalling scan job: %w", err)
+	}
+	counts, err := countSeverities(scanJob)
+	if err != nil {
+		return err
+	}
+	var startedAt, finishedAt *time.Time
+	switch scanJob.Status {
+	case job.Pending:
+		startedAt = &now
+	case job.Finished, job.Failed:
+		finishedAt = &now
+	}
+
+	slog.DebugContext(ctx, "Updating scan job",
+		slog.String("scan_job_id", scanJob.ID),
+		slog.String("scan_job_status", scanJob.Status.String()),
+		slog.Duration("expire", s.cfg.ScanJobTTL),
+	)
+
+	if _, err = tx.ExecContext(ctx, `UPDATE scan_jobs SET
+		status = $2, error = $3, data = $4, counts = $5, updated_at = $6,
+		started_at = COALESCE($7, started_at), finished_at = COALESCE($8, finished_at), expires_at = $9
+		WHERE id = $1`,
+		scanJob.ID, int(scanJob.Status), scanJob.Error, data, counts, now, startedAt, finishedAt,
+		now.Add(s.cfg.ScanJobTTL),
+	); err != nil {
+		return xerrors.Errorf("updating scan job: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return xerrors.Errorf("updating scan job: %w", err)
+	}
+	return nil
+}
+
+func (s *store) FindByStatus(ctx context.Context, statuses ...job.ScanJobStatus) ([]job.ScanJob, error) {
+	values := make([]int64, len(statuses))
+	for i, status := range statuses {
+		values[i] = int64(status)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT data FROM scan_jobs WHERE status = ANY($1) AND expires_at > $2`,
+		pq.Array(values), s.now())
+	if err != nil {
+		return nil, xerrors.Errorf("finding scan jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var scanJobs []job.ScanJob
+	for rows.Next() {
+		var data []byte
+		if err = rows.Scan(&data); err != nil {
+			return nil, xerrors.Errorf("finding scan jobs: %w", err)
+		}
+		scanJob, err := job.Unmarshal(data)
+		if err != nil {
+			return nil, xerrors.Errorf("unmarshalling scan job: %w", err)
+		}
+		scanJobs = append(scanJobs, scanJob)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, xerrors.Errorf("finding scan jobs: %w", err)
+	}
+	return scanJobs, nil
+}
+
+// Extend never shortens the expiry of the scan job, which is bounded by the configured TTL like the expiry of
+// scan jobs kept in Redis.
+func (s *store) Extend(ctx context.Context, scanJobID string, ttl time.Duration) error {
+	if ttl < s.cfg.ScanJobTTL {
+		ttl = s.cfg.ScanJobTTL
+	}
+
+	now := s.now()
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE scan_jobs SET expires_at = GREATEST(expires_at, $2) WHERE id = $1 AND expires_at > $3`,
+		scanJobID, now.Add(ttl), now)
+	if err != nil {
+		return xerrors.Errorf("extending scan job: %w", err)
+	}
+	extended, err := res.RowsAffected()
+	if err != nil {
+		return xerrors.Errorf("extending scan job: %w", err)
+	}
+	if extended == 0 {
+		return xerrors.Errorf("scan job %s not found", scanJobID)
+	}
+	return nil
+}
+
+// ListSummaries reads the summary columns of the most recently updated scan jobs, leaving out their encoded
+// scan jobs.
+func (s *store) ListSummaries(ctx context.Context, limit int) ([]job.Summary, error) {
+	// A NULL limit lists all summaries.
+	var rowLimit *int
+	if limit > 0 {
+		rowLimit = &limit
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id, status, error, counts, created_at, started_at, finished_at
+		FROM scan_jobs WHERE expires_at > $1 ORDER BY updated_at DESC LIMIT $2`, s.now(), rowLimit)
+	if err != nil {
+		return nil, xerrors.Errorf("listing scan job summaries: %w", err)
+	}
+	defer rows.Close()
+
+	summaries := make([]job.Summary, 0)
+	for rows.Next() {
+		var (
+			summary               job.Summary
+			status                int
+			counts                []byte
+			startedAt, finishedAt sql.NullTime
+		)
+		if err = rows.Scan(&summary.ID, &status, &summary.Error, &counts, &summary.CreatedAt, &startedAt,
+			&finishedAt); err != nil {
+			return nil, xerrors.Errorf("listing scan job summaries: %w", err)
+		}
+		summary.Status = job.ScanJobStatus(status)
+		summary.StartedAt = startedAt.Time
+		summary.FinishedAt = finishedAt.Time
+		if err = json.Unmarshal(counts, &summary.Counts); err != nil {
+			return nil, xerrors.Errorf("parsing counts of scan job summary %s: %w", summary.ID, err)
+		}
+		summaries = append(summaries, summary)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, xerrors.Errorf("listing scan job summaries: %w", err)
+	}
+	return summaries, nil
+}
+
+// List pages the scan jobs by ID with a keyset, and decodes the artifacts of the listed scan jobs without their
+// reports. The status and summary columns are read as is.
+func (s *store) List(ctx context.Context, options persistence.ListOptions) ([]job.Entry, error) {
+	statuses := make([]int64, len(options.Statuses))
+	for i, status := range options.Statuses {
+		statuses[i] = int64(status)
+	}
+	// A NULL limit lists all scan jobs.
+	var rowLimit *int
+	if options.Limit > 0 {
+		rowLimit = &options.Limit
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT data, counts, created_at, started_at, finished_at
+		FROM scan_jobs WHERE expires_at > $1 AND id > $2 AND (cardinality($3::BIGINT[]) = 0 OR status = ANY($3))
+		ORDER BY id LIMIT $4`, s.now(), options.After, pq.Array(statuses), rowLimit)
+	if err != nil {
+		return nil, xerrors.Errorf("listing scan jobs: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]job.Entry, 0)
+	for rows.Next() {
+		var (
+			data, counts          []byte
+			createdAt             time.Time
+			startedAt, finishedAt sql.NullTime
+		)
+		if err = rows.Scan(&data, &counts, &createdAt, &startedAt, &finishedAt); err != nil {
+			return nil, xerrors.Errorf("listing scan jobs: %w", err)
+		}
+		entry, err := job.UnmarshalEntry(data)
+		if err != nil {
+			return nil, xerrors.Errorf("unmarshalling scan job: %w", err)
+		}
+		entry.CreatedAt = createdAt
+		entry.StartedAt = startedAt.Time
+		entry.FinishedAt = finishedAt.Time
+		if err = json.Unmarshal(counts, &entry.Counts); err != nil {
+			return nil, xerrors.Errorf("parsing counts of scan job %s: %w", entry.ID, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, xerrors.Errorf("listing scan jobs: %w", err)
+	}
+	return entries, nil
+}
+
+// countSeverities returns the JSON encoded numbers of reported vulnerabilities of the given scan job keyed by
+// severity name, including the severities which have not been reported.
+func countSeverities(scanJob job.ScanJob) ([]byte, error) {
+	data, err := json.Marshal(persistence.CountSeverities(scanJob.Report))
+	if err != nil {
+		return nil, xerrors.Errorf("marshalling severity counts: %w", err)
+	}
+	return data, nil
+}
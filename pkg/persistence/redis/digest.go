@@ -0,0 +1,54 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	redis "github.com/redis/go-redis/v9"
+	"golang.org/x/xerrors"
+)
+
+// digestIndex keeps a string per digest holding the ID of the scan job which last scanned it, which expires
+// once the digest was not scanned for the reuse window.
+type digestIndex struct {
+	cfg    etc.RedisStore
+	rdb    redis.UniversalClient
+	window time.Duration
+}
+
+// NewDigestIndex constructs a DigestIndex forgetting the scan jobs of digests after the given window.
+func NewDigestIndex(cfg etc.RedisStore, rdb redis.UniversalClient, window time.Duration) persistence.DigestIndex {
+	return &digestIndex{cfg: cfg, rdb: rdb, window: window}
+}
+
+func (i *digestIndex) Record(ctx context.Context, digest, scanJobID string) error {
+	slog.DebugContext(ctx, "Recording scan job of digest",
+		slog.String("digest", digest),
+		slog.String("scan_job_id", scanJobID),
+		slog.Duration("expire", i.window),
+	)
+
+	if err := i.rdb.Set(ctx, i.keyForDigest(digest), scanJobID, i.window).Err(); err != nil {
+		return xerrors.Errorf("recording scan job of digest: %w", err)
+	}
+	return nil
+}
+
+func (i *digestIndex) Find(ctx context.Context, digest string) (string, error) {
+	scanJobID, err := i.rdb.Get(ctx, i.keyForDigest(digest)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	} else if err != nil {
+		return "", xerrors.Errorf("finding scan job of digest: %w", err)
+	}
+	return scanJobID, nil
+}
+
+func (i *digestIndex) keyForDigest(digest string) string {
+	return fmt.Sprintf("%s:digest:%s", i.cfg.Namespace, digest)
+}
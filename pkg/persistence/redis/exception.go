@@ -0,0 +1,114 @@
+package redis
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/policy"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/redisx"
+	redis "github.com/redis/go-redis/v9"
+	"golang.org/x/xerrors"
+)
+
+// exceptionListBatchSize is the number of exceptions which are scanned, and got with a single pipeline, at once.
+const exceptionListBatchSize = 100
+
+// exceptionStore keeps a key per exception holding its JSON, which Redis evicts when the exception expires.
+type exceptionStore struct {
+	cfg etc.RedisStore
+	rdb redis.UniversalClient
+}
+
+func NewExceptionStore(cfg etc.RedisStore, rdb redis.UniversalClient) persistence.ExceptionStore {
+	return &exceptionStore{cfg: cfg, rdb: rdb}
+}
+
+func (s *exceptionStore) Save(ctx context.Context, exception policy.Exception) error {
+	slog.DebugContext(ctx, "Saving policy exception",
+		slog.String("exception_id", exception.ID),
+		slog.String("status", string(exception.Status)),
+		slog.Time("expires_at", exception.ExpiresAt),
+	)
+
+	bytes, err := json.Marshal(exception)
+	if err != nil {
+		return xerrors.Errorf("marshalling policy exception: %w", err)
+	}
+	key := s.keyForException(exception.ID)
+	_, err = s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, key, bytes, 0)
+		pipe.ExpireAt(ctx, key, exception.ExpiresAt)
+		return nil
+	})
+	if err != nil {
+		return xerrors.Errorf("saving policy exception: %w", err)
+	}
+	return nil
+}
+
+func (s *exceptionStore) Get(ctx context.Context, id string) (*policy.Exception, error) {
+	value, err := s.rdb.Get(ctx, s.keyForException(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	} else if err != nil {
+		return nil, xerrors.Errorf("getting policy exception: %w", err)
+	}
+
+	var exception policy.Exception
+	if err = json.Unmarshal(value, &exception); err != nil {
+		return nil, xerrors.Errorf("unmarshalling policy exception: %w", err)
+	}
+	return &exception, nil
+}
+
+func (s *exceptionStore) List(ctx context.Context) ([]policy.Exception, error) {
+	exceptions := []policy.Exception{}
+	pattern := escapePattern(s.cfg.Namespace) + ":policy-exception:*"
+	err := redisx.Scan(ctx, s.rdb, pattern, exceptionListBatchSize, func(keys []string) error {
+		cmds := make([]*redis.StringCmd, len(keys))
+		_, err := s.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, key := range keys {
+				cmds[i] = pipe.Get(ctx, key)
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return xerrors.Errorf("getting policy exceptions: %w", err)
+		}
+		for _, cmd := range cmds {
+			value, err := cmd.Bytes()
+			if errors.Is(err, redis.Nil) {
+				// Expired since the key was scanned.
+				continue
+			}
+			var exception policy.Exception
+			if err = json.Unmarshal(value, &exception); err != nil {
+				return xerrors.Errorf("unmarshalling policy exception: %w", err)
+			}
+			exceptions = append(exceptions, exception)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(exceptions, func(a, b policy.Exception) int {
+		if c := a.RequestedAt.Compare(b.RequestedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return exceptions, nil
+}
+
+func (s *exceptionStore) keyForException(id string) string {
+	return fmt.Sprintf("%s:policy-exception:%s", s.cfg.Namespace, id)
+}
@@ -0,0 +1,72 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	redis "github.com/redis/go-redis/v9"
+	"golang.org/x/xerrors"
+)
+
+const (
+	failureFieldCount = "count"
+	failureFieldError = "error"
+)
+
+// failureIndex keeps a hash per digest holding the number of its permanent failures and the error of the last
+// one, which expires once the digest did not fail for the cooldown period.
+type failureIndex struct {
+	cfg       etc.RedisStore
+	rdb       redis.UniversalClient
+	threshold int
+	cooldown  time.Duration
+}
+
+// NewFailureIndex constructs a FailureIndex failing digests fast once they failed the given number of times,
+// until they did not fail for the given cooldown period.
+func NewFailureIndex(cfg etc.RedisStore, rdb redis.UniversalClient, threshold int, cooldown time.Duration) persistence.FailureIndex {
+	return &failureIndex{cfg: cfg, rdb: rdb, threshold: threshold, cooldown: cooldown}
+}
+
+func (i *failureIndex) Record(ctx context.Context, digest, message string) error {
+	slog.DebugContext(ctx, "Recording permanent failure of digest",
+		slog.String("digest", digest),
+		slog.Duration("expire", i.cooldown),
+	)
+
+	key := i.keyForDigest(digest)
+	_, err := i.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.HIncrBy(ctx, key, failureFieldCount, 1)
+		p.HSet(ctx, key, failureFieldError, message)
+		p.Expire(ctx, key, i.cooldown)
+		return nil
+	})
+	if err != nil {
+		return xerrors.Errorf("recording failure of digest: %w", err)
+	}
+	return nil
+}
+
+func (i *failureIndex) Find(ctx context.Context, digest string) (string, error) {
+	values, err := i.rdb.HMGet(ctx, i.keyForDigest(digest), failureFieldCount, failureFieldError).Result()
+	if err != nil {
+		return "", xerrors.Errorf("finding failure of digest: %w", err)
+	}
+
+	// The values of a digest which did not fail recently are nil.
+	count, _ := values[0].(string)
+	message, _ := values[1].(string)
+	if n, _ := strconv.Atoi(count); n < i.threshold {
+		return "", nil
+	}
+	return message, nil
+}
+
+func (i *failureIndex) keyForDigest(digest string) string {
+	return fmt.Sprintf("%s:failure:%s", i.cfg.Namespace, digest)
+}
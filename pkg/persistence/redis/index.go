@@ -0,0 +1,163 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	redis "github.com/redis/go-redis/v9"
+	"golang.org/x/xerrors"
+)
+
+// vulnerabilityIndex keeps a hash per vulnerability, which maps artifacts to their affected packages,
+// and a set per artifact holding the vulnerabilities indexed for the artifact. The latter is used to remove
+// an artifact from vulnerabilities that are no longer reported when the artifact is scanned again.
+type vulnerabilityIndex struct {
+	cfg etc.RedisStore
+	rdb redis.UniversalClient
+	now func() time.Time
+}
+
+func NewVulnerabilityIndex(cfg etc.RedisStore, rdb redis.UniversalClient, opts ...Option) persistence.VulnerabilityIndex {
+	return &vulnerabilityIndex{cfg: cfg, rdb: rdb, now: newOptions(opts).clock.Now}
+}
+
+func (i *vulnerabilityIndex) Index(ctx context.Context, registry string, report harbor.ScanReport) error {
+	artifactKey := artifactField(registry, report.Artifact)
+	scannedAt := report.GeneratedAt
+	if scannedAt.IsZero() {
+		scannedAt = i.now()
+	}
+
+	affected := make(map[string]*persistence.AffectedArtifact)
+	for _, v := range report.Vulnerabilities {
+		if v.ID == "" {
+			continue
+		}
+		a, ok := affected[v.ID]
+		if !ok {
+			a = &persistence.AffectedArtifact{
+				Registry:   registry,
+				Repository: report.Artifact.Repository,
+				Digest:     report.Artifact.Digest,
+				ScannedAt:  scannedAt,
+			}
+			affected[v.ID] = a
+		}
+		a.Severity = max(a.Severity, v.Severity)
+		a.Packages = append(a.Packages, persistence.AffectedPackage{
+			Name:       v.Pkg,
+			Version:    v.Version,
+			FixVersion: v.FixVersion,
+		})
+	}
+
+	previous, err := i.rdb.SMembers(ctx, i.keyForArtifact(artifactKey)).Result()
+	if err != nil {
+		return xerrors.Errorf("getting indexed vulnerabilities: %w", err)
+	}
+
+	slog.DebugContext(ctx, "Indexing vulnerabilities",
+		slog.String("artifact", artifactKey),
+		slog.Int("vulnerabilities", len(affected)),
+	)
+
+	_, err = i.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, id := range previous {
+			if _, ok := affected[id]; !ok {
+				pipe.HDel(ctx, i.keyForVulnerability(id), artifactKey)
+			}
+		}
+		pipe.Del(ctx, i.keyForArtifact(artifactKey))
+
+		for id, a := range affected {
+			bytes, err := json.Marshal(a)
+			if err != nil {
+				return xerrors.Errorf("marshalling affected artifact: %w", err)
+			}
+			pipe.HSet(ctx, i.keyForVulnerability(id), artifactKey, string(bytes))
+			pipe.SAdd(ctx, i.keyForArtifact(artifactKey), id)
+			if i.cfg.VulnerabilityIndexTTL > 0 {
+				pipe.Expire(ctx, i.keyForVulnerability(id), i.cfg.VulnerabilityIndexTTL)
+			}
+		}
+		if len(affected) > 0 && i.cfg.VulnerabilityIndexTTL > 0 {
+			pipe.Expire(ctx, i.keyForArtifact(artifactKey), i.cfg.VulnerabilityIndexTTL)
+		}
+		return nil
+	})
+	if err != nil {
+		return xerrors.Errorf("indexing vulnerabilities: %w", err)
+	}
+	return nil
+}
+
+func (i *vulnerabilityIndex) FindArtifacts(ctx context.Context, vulnerabilityID string) ([]persistence.AffectedArtifact, error) {
+	key := i.keyForVulnerability(vulnerabilityID)
+	values, err := i.rdb.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, xerrors.Errorf("getting affected artifacts: %w", err)
+	}
+
+	// The hash expires as a whole, hence artifacts which have not been scanned within the TTL are
+	// evicted on read.
+	oldest := i.now().Add(-i.cfg.VulnerabilityIndexTTL)
+	var stale []string
+
+	artifacts := make([]persistence.AffectedArtifact, 0, len(values))
+	for field, value := range values {
+		var a persistence.AffectedArtifact
+		if err := json.Unmarshal([]byte(value), &a); err != nil {
+			return nil, xerrors.Errorf("unmarshalling affected artifact: %w", err)
+		}
+		if i.cfg.VulnerabilityIndexTTL > 0 && a.ScannedAt.Before(oldest) {
+			stale = append(stale, field)
+			continue
+		}
+		artifacts = append(artifacts, a)
+	}
+
+	if len(stale) > 0 {
+		if err := i.rdb.HDel(ctx, key, stale...).Err(); err != nil {
+			slog.Warn("Error while evicting stale affected artifacts", slog.String("vulnerability_id", vulnerabilityID),
+				slog.String("err", err.Error()))
+		}
+	}
+
+	slices.SortFunc(artifacts, func(a, b persistence.AffectedArtifact) int {
+		if c := strings.Compare(a.Repository, b.Repository); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Digest, b.Digest)
+	})
+	return artifacts, nil
+}
+
+func (i *vulnerabilityIndex) FindVulnerabilities(ctx context.Context, registry string, artifact harbor.Artifact) ([]string, error) {
+	ids, err := i.rdb.SMembers(ctx, i.keyForArtifact(artifactField(registry, artifact))).Result()
+	if err != nil {
+		return nil, xerrors.Errorf("getting indexed vulnerabilities: %w", err)
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// artifactField identifies the artifact in the hash of a vulnerability.
+func artifactField(registry string, artifact harbor.Artifact) string {
+	return registry + "/" + artifact.Repository + "@" + artifact.Digest
+}
+
+func (i *vulnerabilityIndex) keyForVulnerability(vulnerabilityID string) string {
+	return fmt.Sprintf("%s:vulnerability:%s", i.cfg.Namespace, vulnerabilityID)
+}
+
+func (i *vulnerabilityIndex) keyForArtifact(artifactKey string) string {
+	return fmt.Sprintf("%s:artifact-vulnerabilities:%s", i.cfg.Namespace, artifactKey)
+}
@@ -0,0 +1,149 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/redisx"
+	redis "github.com/redis/go-redis/v9"
+	"golang.org/x/xerrors"
+)
+
+// lifetimeSummaryBatchSize is the number of digests whose lifetimes are scanned, and got with a single pipeline,
+// at once.
+const lifetimeSummaryBatchSize = 100
+
+// lifetimeStore keeps a hash per digest mapping the IDs of its vulnerabilities to their lifetimes, which expires
+// when the digest is not scanned again within the lifetime TTL. It supersedes the hash per digest mapping the IDs of
+// its vulnerabilities to when they were first seen, which expired with the SBOM of the digest, and which is read for
+// the digests without lifetimes, so that the times recorded there are kept.
+type lifetimeStore struct {
+	cfg etc.RedisStore
+	rdb redis.UniversalClient
+}
+
+func NewLifetimeStore(cfg etc.RedisStore, rdb redis.UniversalClient) persistence.LifetimeStore {
+	return &lifetimeStore{cfg: cfg, rdb: rdb}
+}
+
+func (s *lifetimeStore) Get(ctx context.Context, digest string) (map[string]persistence.VulnerabilityLifetime, error) {
+	values, err := s.rdb.HGetAll(ctx, s.keyForDigest(digest)).Result()
+	if err != nil {
+		return nil, xerrors.Errorf("getting vulnerability lifetimes: %w", err)
+	}
+	if len(values) == 0 {
+		return s.getFirstSeen(ctx, digest)
+	}
+	return unmarshalLifetimes(values)
+}
+
+// getFirstSeen returns the lifetimes of the vulnerabilities of the artifact with the given digest as of the times
+// they were first seen, which are also the earliest times they may have been seen last.
+func (s *lifetimeStore) getFirstSeen(ctx context.Context, digest string) (map[string]persistence.VulnerabilityLifetime, error) {
+	values, err := s.rdb.HGetAll(ctx, s.keyForFirstSeen(digest)).Result()
+	if err != nil {
+		return nil, xerrors.Errorf("getting first seen vulnerabilities: %w", err)
+	}
+	lifetimes := make(map[string]persistence.VulnerabilityLifetime, len(values))
+	for id, value := range values {
+		seen, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, xerrors.Errorf("parsing first seen time of %s: %w", id, err)
+		}
+		lifetimes[id] = persistence.VulnerabilityLifetime{FirstSeen: seen, LastSeen: seen}
+	}
+	return lifetimes, nil
+}
+
+func (s *lifetimeStore) Save(ctx context.Context, digest string, lifetimes map[string]persistence.VulnerabilityLifetime) error {
+	slog.DebugContext(ctx, "Saving vulnerability lifetimes",
+		slog.String("digest", digest),
+		slog.Int("count", len(lifetimes)),
+		slog.Duration("expire", s.cfg.LifetimeTTL),
+	)
+
+	values := make(map[string]interface{}, len(lifetimes))
+	for id, lifetime := range lifetimes {
+		bytes, err := json.Marshal(lifetime)
+		if err != nil {
+			return xerrors.Errorf("marshalling vulnerability lifetime: %w", err)
+		}
+		values[id] = string(bytes)
+	}
+	key := s.keyForDigest(digest)
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key, s.keyForFirstSeen(digest))
+		if len(values) > 0 {
+			pipe.HSet(ctx, key, values)
+			if s.cfg.LifetimeTTL > 0 {
+				pipe.Expire(ctx, key, s.cfg.LifetimeTTL)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return xerrors.Errorf("saving vulnerability lifetimes: %w", err)
+	}
+	return nil
+}
+
+func (s *lifetimeStore) Summarize(ctx context.Context) (persistence.LifetimeSummary, error) {
+	var summary persistence.LifetimeSummary
+	pattern := escapePattern(s.cfg.Namespace) + ":vulnerability-lifetime:*"
+	err := redisx.Scan(ctx, s.rdb, pattern, lifetimeSummaryBatchSize, func(keys []string) error {
+		cmds := make([]*redis.MapStringStringCmd, len(keys))
+		_, err := s.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, key := range keys {
+				cmds[i] = pipe.HGetAll(ctx, key)
+			}
+			return nil
+		})
+		if err != nil {
+			return xerrors.Errorf("getting vulnerability lifetimes: %w", err)
+		}
+		for _, cmd := range cmds {
+			lifetimes, err := unmarshalLifetimes(cmd.Val())
+			if err != nil {
+				return err
+			}
+			// Keys expired since they were scanned are empty.
+			if len(lifetimes) == 0 {
+				continue
+			}
+			summary.Artifacts++
+			for _, lifetime := range lifetimes {
+				summary.Add(lifetime)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return persistence.LifetimeSummary{}, err
+	}
+	return summary, nil
+}
+
+func unmarshalLifetimes(values map[string]string) (map[string]persistence.VulnerabilityLifetime, error) {
+	lifetimes := make(map[string]persistence.VulnerabilityLifetime, len(values))
+	for id, value := range values {
+		var lifetime persistence.VulnerabilityLifetime
+		if err := json.Unmarshal([]byte(value), &lifetime); err != nil {
+			return nil, xerrors.Errorf("unmarshalling lifetime of %s: %w", id, err)
+		}
+		lifetimes[id] = lifetime
+	}
+	return lifetimes, nil
+}
+
+func (s *lifetimeStore) keyForDigest(digest string) string {
+	return fmt.Sprintf("%s:vulnerability-lifetime:%s", s.cfg.Namespace, digest)
+}
+
+func (s *lifetimeStore) keyForFirstSeen(digest string) string {
+	return fmt.Sprintf("%s:sbom-first-seen:%s", s.cfg.Namespace, digest)
+}
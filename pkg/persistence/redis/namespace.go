@@ -0,0 +1,190 @@
+package redis
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/redisx"
+	redis "github.com/redis/go-redis/v9"
+	"golang.org/x/xerrors"
+)
+
+// noKeyTTL is the TTL returned by PTTL for keys which do not exist.
+const noKeyTTL = -2 * time.Nanosecond
+
+// defaultNamespaceBatchSize is the number of keys scanned, and migrated with a single pipeline, at once.
+const defaultNamespaceBatchSize = 100
+
+// NamespaceMigration describes the migration of the keys of a namespace to another namespace.
+type NamespaceMigration struct {
+	From string
+	To   string
+	// Copy keeps the keys of the old namespace, e.g. to roll back, rather than renaming them.
+	Copy bool
+	// BatchSize is the number of keys migrated at once, 100 if zero.
+	BatchSize int
+	// Progress is called after each batch with the result so far.
+	Progress func(NamespaceResult)
+}
+
+// NamespaceResult is the result of a namespace migration.
+type NamespaceResult struct {
+	// Migrated is the number of keys renamed or copied to the new namespace.
+	Migrated int
+	// Skipped is the number of keys left as is, because they already exist in the new namespace, or expired
+	// since they were scanned.
+	Skipped int
+}
+
+// MigrateNamespace renames, or copies, all keys of a namespace to another namespace in batches, preserving their
+// TTLs. Keys already existing in the new namespace are never overwritten, so that the migration can be run again,
+// e.g. after being interrupted. Copies are made with DUMP and RESTORE, which Redis 5 supports.
+func MigrateNamespace(ctx context.Context, rdb redis.UniversalClient, m NamespaceMigration) (NamespaceResult, error) {
+	var result NamespaceResult
+
+	if m.From == "" || m.To == "" {
+		return result, xerrors.New("namespaces must not be blank")
+	}
+	// Renamed keys would be scanned again if the new namespace was nested in the old one.
+	if strings.HasPrefix(m.From+":", m.To+":") || strings.HasPrefix(m.To+":", m.From+":") {
+		return result, xerrors.Errorf("namespaces %s and %s must not be nested", m.From, m.To)
+	}
+	// RENAME fails unless both keys are on the same slot, which keys of distinct hash-tagged namespaces never are.
+	if redisx.IsCluster(rdb) && !m.Copy {
+		return result, xerrors.New("keys of a Redis Cluster must be copied rather than renamed")
+	}
+	batchSize := m.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultNamespaceBatchSize
+	}
+
+	migrate := renameKeys
+	if m.Copy {
+		migrate = copyKeys
+	}
+	err := redisx.Scan(ctx, rdb, escapePattern(m.From)+":*", int64(batchSize), func(keys []string) error {
+		migrated, err := migrate(ctx, rdb, keys, m.From, m.To)
+		if err != nil {
+			return err
+		}
+		result.Migrated += migrated
+		result.Skipped += len(keys) - migrated
+		if m.Progress != nil {
+			m.Progress(result)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// renameKeys renames the given keys of the old namespace with a single pipeline, and returns the number of keys
+// renamed.
+func renameKeys(ctx context.Context, rdb redis.UniversalClient, keys []string, from, to string) (int, error) {
+	cmds := make([]*redis.BoolCmd, len(keys))
+	_, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.RenameNX(ctx, key, toNamespace(key, from, to))
+		}
+		return nil
+	})
+	if err != nil && !isNoSuchKey(err) {
+		return 0, xerrors.Errorf("renaming keys: %w", err)
+	}
+
+	var renamed int
+	for _, cmd := range cmds {
+		ok, err := cmd.Result()
+		if err != nil && !isNoSuchKey(err) {
+			return renamed, xerrors.Errorf("renaming key: %w", err)
+		}
+		if ok {
+			renamed++
+		}
+	}
+	return renamed, nil
+}
+
+// copyKeys copies the given keys of the old namespace with a pipeline dumping them, and another one restoring
+// them, and returns the number of keys copied.
+func copyKeys(ctx context.Context, rdb redis.UniversalClient, keys []string, from, to string) (int, error) {
+	dumps := make([]*redis.StringCmd, len(keys))
+	ttls := make([]*redis.DurationCmd, len(keys))
+	_, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			dumps[i] = pipe.Dump(ctx, key)
+			ttls[i] = pipe.PTTL(ctx, key)
+		}
+		return nil
+	})
+	if err != nil && !xerrors.Is(err, redis.Nil) {
+		return 0, xerrors.Errorf("dumping keys: %w", err)
+	}
+
+	var restores []*redis.StatusCmd
+	_, err = rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			value, err := dumps[i].Result()
+			if xerrors.Is(err, redis.Nil) {
+				// Expired since the key was scanned.
+				continue
+			}
+			if err != nil {
+				return xerrors.Errorf("dumping key: %w", err)
+			}
+			ttl, err := ttls[i].Result()
+			if err != nil {
+				return xerrors.Errorf("getting TTL of key: %w", err)
+			}
+			switch {
+			case ttl == noKeyTTL:
+				continue
+			case ttl < 0:
+				// The key has no TTL.
+				ttl = 0
+			}
+			restores = append(restores, pipe.Restore(ctx, toNamespace(key, from, to), ttl, value))
+		}
+		return nil
+	})
+	if err != nil && !isBusyKey(err) {
+		return 0, xerrors.Errorf("restoring keys: %w", err)
+	}
+
+	var copied int
+	for _, cmd := range restores {
+		if err := cmd.Err(); err != nil {
+			if isBusyKey(err) {
+				continue
+			}
+			return copied, xerrors.Errorf("restoring key: %w", err)
+		}
+		copied++
+	}
+	return copied, nil
+}
+
+// toNamespace returns the given key of the old namespace in the new namespace.
+func toNamespace(key, from, to string) string {
+	return to + strings.TrimPrefix(key, from)
+}
+
+func isNoSuchKey(err error) bool {
+	return strings.Contains(err.Error(), "no such key")
+}
+
+func isBusyKey(err error) bool {
+	return strings.HasPrefix(err.Error(), "BUSYKEY")
+}
+
+// escapePattern escapes the special characters of glob-style patterns matched by SCAN.
+func escapePattern(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,114 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	redis "github.com/redis/go-redis/v9"
+	"golang.org/x/xerrors"
+)
+
+// sbomStore keeps a string per digest holding the SBOM, and a hash mapping the artifacts with stored SBOMs
+// to their registries, repositories and digests.
+type sbomStore struct {
+	cfg etc.RedisStore
+	rdb redis.UniversalClient
+	now func() time.Time
+}
+
+func NewSBOMStore(cfg etc.RedisStore, rdb redis.UniversalClient, opts ...Option) persistence.SBOMStore {
+	return &sbomStore{cfg: cfg, rdb: rdb, now: newOptions(opts).clock.Now}
+}
+
+func (s *sbomStore) Save(ctx context.Context, registry string, artifact harbor.Artifact, sbom []byte) error {
+	bytes, err := json.Marshal(persistence.SBOMArtifact{
+		Registry:   registry,
+		Repository: artifact.Repository,
+		Digest:     artifact.Digest,
+		StoredAt:   s.now(),
+	})
+	if err != nil {
+		return xerrors.Errorf("marshalling SBOM artifact: %w", err)
+	}
+
+	slog.DebugContext(ctx, "Saving SBOM",
+		slog.String("digest", artifact.Digest),
+		slog.Int("size", len(sbom)),
+		slog.Duration("expire", s.cfg.SBOMTTL),
+	)
+
+	_, err = s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.keyForSBOM(artifact.Digest), sbom, s.cfg.SBOMTTL)
+		pipe.HSet(ctx, s.keyForArtifacts(), artifactField(registry, artifact), string(bytes))
+		return nil
+	})
+	if err != nil {
+		return xerrors.Errorf("saving SBOM: %w", err)
+	}
+	return nil
+}
+
+func (s *sbomStore) Get(ctx context.Context, digest string) ([]byte, error) {
+	sbom, err := s.rdb.Get(ctx, s.keyForSBOM(digest)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	} else if err != nil {
+		return nil, xerrors.Errorf("getting SBOM: %w", err)
+	}
+	return sbom, nil
+}
+
+func (s *sbomStore) FindArtifacts(ctx context.Context) ([]persistence.SBOMArtifact, error) {
+	values, err := s.rdb.HGetAll(ctx, s.keyForArtifacts()).Result()
+	if err != nil {
+		return nil, xerrors.Errorf("getting SBOM artifacts: %w", err)
+	}
+
+	// SBOMs expire on their own, hence artifacts whose SBOMs have expired are evicted on read.
+	oldest := s.now().Add(-s.cfg.SBOMTTL)
+	var stale []string
+
+	artifacts := make([]persistence.SBOMArtifact, 0, len(values))
+	for field, value := range values {
+		var a persistence.SBOMArtifact
+		if err := json.Unmarshal([]byte(value), &a); err != nil {
+			return nil, xerrors.Errorf("unmarshalling SBOM artifact: %w", err)
+		}
+		if s.cfg.SBOMTTL > 0 && a.StoredAt.Before(oldest) {
+			stale = append(stale, field)
+			continue
+		}
+		artifacts = append(artifacts, a)
+	}
+
+	if len(stale) > 0 {
+		if err := s.rdb.HDel(ctx, s.keyForArtifacts(), stale...).Err(); err != nil {
+			slog.Warn("Error while evicting stale SBOM artifacts", slog.String("err", err.Error()))
+		}
+	}
+
+	slices.SortFunc(artifacts, func(a, b persistence.SBOMArtifact) int {
+		if c := strings.Compare(a.Repository, b.Repository); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Digest, b.Digest)
+	})
+	return artifacts, nil
+}
+
+func (s *sbomStore) keyForSBOM(digest string) string {
+	return fmt.Sprintf("%s:sbom:%s", s.cfg.Namespace, digest)
+}
+
+func (s *sbomStore) keyForArtifacts() string {
+	return fmt.Sprintf("%s:sbom-artifacts", s.cfg.Namespace)
+}
@@ -2,70 +2,118 @@ package redis
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"time"
 
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/clock"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/redisx"
 	redis "github.com/redis/go-redis/v9"
 	"golang.org/x/xerrors"
 )
 
+// store keeps each scan job as a JSON string, and its summary as a hash next to it. The summaries are listed
+// by a sorted set, so that listing scan jobs never decodes their reports.
 type store struct {
 	cfg etc.RedisStore
-	rdb *redis.Client
+	rdb redis.UniversalClient
+	now func() time.Time
 }
 
-func NewStore(cfg etc.RedisStore, rdb *redis.Client) persistence.Store {
-	return &store{cfg: cfg, rdb: rdb}
+// Option customizes the stores and indexes constructed by this package.
+type Option func(o *options)
+
+type options struct {
+	clock clock.Clock
+}
+
+// WithClock expires scan jobs, SBOMs and indexed artifacts, and times their summaries, with the given Clock rather
+// than the system time.
+func WithClock(c clock.Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{clock: clock.System}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+func NewStore(cfg etc.RedisStore, rdb redis.UniversalClient, opts ...Option) persistence.Store {
+	return &store{cfg: cfg, rdb: rdb, now: newOptions(opts).clock.Now}
 }
 
 func (s *store) Create(ctx context.Context, scanJob job.ScanJob) error {
-	bytes, err := json.Marshal(scanJob)
+	bytes, err := job.Marshal(scanJob)
 	if err != nil {
 		return xerrors.Errorf("marshalling scan job: %w", err)
 	}
 
 	key := s.keyForScanJob(scanJob.ID)
 
-	slog.Debug("Saving scan job",
+	slog.DebugContext(ctx, "Saving scan job",
 		slog.String("scan_job_id", scanJob.ID),
 		slog.String("scan_job_status", scanJob.Status.String()),
 		slog.String("redis_key", key),
 		slog.Duration("expire", s.cfg.ScanJobTTL),
 	)
 
-	if err = s.rdb.SetNX(ctx, key, string(bytes), s.cfg.ScanJobTTL).Err(); err != nil {
+	created, err := s.rdb.SetNX(ctx, key, string(bytes), s.cfg.ScanJobTTL).Result()
+	if err != nil {
 		return xerrors.Errorf("creating scan job: %w", err)
 	}
+	if !created {
+		return xerrors.Errorf("creating scan job %s: %w", scanJob.ID, persistence.ErrScanJobExists)
+	}
 
-	return nil
+	return s.saveSummary(ctx, scanJob, true)
 }
 
 func (s *store) update(ctx context.Context, scanJob job.ScanJob) error {
-	bytes, err := json.Marshal(scanJob)
+	bytes, err := job.Marshal(scanJob)
 	if err != nil {
 		return xerrors.Errorf("marshalling scan job: %w", err)
 	}
 
 	key := s.keyForScanJob(scanJob.ID)
 
-	slog.Debug("Updating scan job",
+	slog.DebugContext(ctx, "Updating scan job",
 		slog.String("scan_job_id", scanJob.ID),
 		slog.String("scan_job_status", scanJob.Status.String()),
 		slog.String("redis_key", key),
 		slog.Duration("expire", s.cfg.ScanJobTTL),
 	)
 
-	if err = s.rdb.SetXX(ctx, key, string(bytes), s.cfg.ScanJobTTL).Err(); err != nil {
+	updated, err := s.rdb.SetXX(ctx, key, string(bytes), s.cfg.ScanJobTTL).Result()
+	if err != nil {
 		return xerrors.Errorf("updating scan job: %w", err)
 	}
+	if !updated {
+		return nil
+	}
 
-	return nil
+	return s.saveSummary(ctx, scanJob, false)
+}
+
+func (s *store) Replace(ctx context.Context, scanJob job.ScanJob) error {
+	existing, err := s.Get(ctx, scanJob.ID)
+	if existing == nil {
+		return xerrors.Errorf("scan job %s not found", scanJob.ID)
+	} else if err != nil {
+		return err
+	}
+
+	return s.update(ctx, scanJob)
 }
 
 func (s *store) Get(ctx context.Context, scanJobID string) (*job.ScanJob, error) {
@@ -77,8 +125,8 @@ func (s *store) Get(ctx context.Context, scanJobID string) (*job.ScanJob, error)
 		return nil, err
 	}
 
-	var scanJob job.ScanJob
-	if err = json.Unmarshal([]byte(value), &scanJob); err != nil {
+	scanJob, err := job.Unmarshal([]byte(value))
+	if err != nil {
 		return nil, xerrors.Errorf("unmarshalling scan job: %w", err)
 	}
 
@@ -86,7 +134,7 @@ func (s *store) Get(ctx context.Context, scanJobID string) (*job.ScanJob, error)
 }
 
 func (s *store) UpdateStatus(ctx context.Context, scanJobID string, newStatus job.ScanJobStatus, error ...string) error {
-	slog.Debug("Updating status for scan job", slog.String("scan_job_id", scanJobID),
+	slog.DebugContext(ctx, "Updating status for scan job", slog.String("scan_job_id", scanJobID),
 		slog.String("new_status", newStatus.String()),
 	)
 
@@ -106,7 +154,7 @@ func (s *store) UpdateStatus(ctx context.Context, scanJobID string, newStatus jo
 }
 
 func (s *store) UpdateReport(ctx context.Context, scanJobID string, report harbor.ScanReport) error {
-	slog.Debug("Updating reports for scan job", slog.String("scan_job_id", scanJobID))
+	slog.DebugContext(ctx, "Updating reports for scan job", slog.String("scan_job_id", scanJobID))
 
 	scanJob, err := s.Get(ctx, scanJobID)
 	if err != nil {
@@ -117,6 +165,91 @@ func (s *store) UpdateReport(ctx context.Context, scanJobID string, report harbo
 	return s.update(ctx, *scanJob)
 }
 
+func (s *store) UpdateSBOM(ctx context.Context, scanJobID string, sbom harbor.SBOMReport) error {
+	slog.DebugContext(ctx, "Updating SBOM for scan job", slog.String("scan_job_id", scanJobID))
+
+	scanJob, err := s.Get(ctx, scanJobID)
+	if scanJob == nil {
+		return xerrors.Errorf("scan job %s not found", scanJobID)
+	} else if err != nil {
+		return err
+	}
+
+	scanJob.SBOM = &sbom
+	return s.update(ctx, *scanJob)
+}
+
+// findBatchSize is the number of keys scanned, and of scan jobs fetched with a single MGET, at once.
+const findBatchSize = 100
+
+// FindByStatus fetches the scan jobs of each batch of scanned keys with a single MGET, and only decodes the
+// whole scan jobs, including their reports, if they are in any of the given statuses.
+func (s *store) FindByStatus(ctx context.Context, statuses ...job.ScanJobStatus) ([]job.ScanJob, error) {
+	var scanJobs []job.ScanJob
+
+	err := redisx.Scan(ctx, s.rdb, s.keyForScanJob("*"), findBatchSize, func(keys []string) error {
+		values, err := s.rdb.MGet(ctx, keys...).Result()
+		if err != nil {
+			return xerrors.Errorf("getting scan jobs: %w", err)
+		}
+		for _, value := range values {
+			// Expired since the key was scanned.
+			if value == nil {
+				continue
+			}
+			data := []byte(value.(string))
+
+			status, err := job.UnmarshalStatus(data)
+			if err != nil {
+				return xerrors.Errorf("unmarshalling scan job status: %w", err)
+			}
+			if !slices.Contains(statuses, status) {
+				continue
+			}
+
+			scanJob, err := job.Unmarshal(data)
+			if err != nil {
+				return xerrors.Errorf("unmarshalling scan job: %w", err)
+			}
+			scanJobs = append(scanJobs, scanJob)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return scanJobs, nil
+}
+
+func (s *store) Extend(ctx context.Context, scanJobID string, ttl time.Duration) error {
+	if s.cfg.ScanJobTTL <= 0 {
+		// Scan jobs never expire, which EXPIRE would change.
+		return nil
+	}
+	// EXPIRE cannot only extend the TTL prior to Redis 7, hence the configured TTL is the lower bound.
+	if ttl < s.cfg.ScanJobTTL {
+		ttl = s.cfg.ScanJobTTL
+	}
+
+	ok, err := s.rdb.Expire(ctx, s.keyForScanJob(scanJobID), ttl).Result()
+	if err != nil {
+		return xerrors.Errorf("extending scan job: %w", err)
+	} else if !ok {
+		return xerrors.Errorf("scan job %s not found", scanJobID)
+	}
+
+	_, err = s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		s.expireSummary(ctx, pipe, scanJobID, s.now(), ttl)
+		pipe.Expire(ctx, s.keyForSummary(scanJobID), ttl)
+		return nil
+	})
+	if err != nil {
+		return xerrors.Errorf("extending scan job summary: %w", err)
+	}
+
+	return nil
+}
+
 func (s *store) keyForScanJob(scanJobID string) string {
 	return fmt.Sprintf("%s:scan-job:%s", s.cfg.Namespace, scanJobID)
 }
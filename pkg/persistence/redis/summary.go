@@ -0,0 +1,207 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/redisx"
+	redis "github.com/redis/go-redis/v9"
+	"golang.org/x/xerrors"
+)
+
+// Fields of the scan job summary hashes. Severity counts are kept in a field per severity, which is named
+// after the severity with the summaryFieldCountPrefix.
+const (
+	summaryFieldStatus      = "status"
+	summaryFieldError       = "error"
+	summaryFieldCreatedAt   = "created_at"
+	summaryFieldStartedAt   = "started_at"
+	summaryFieldFinishedAt  = "finished_at"
+	summaryFieldCountPrefix = "count:"
+)
+
+// saveSummary writes the summary of the given scan job, which expires with the scan job, and scores the scan
+// job in the summaries sorted set with its expiry time. Expired members are pruned from the sorted set by the
+// same transaction, so that it never outgrows the scan jobs.
+func (s *store) saveSummary(ctx context.Context, scanJob job.ScanJob, created bool) error {
+	now := s.now()
+	fields := map[string]interface{}{
+		summaryFieldStatus: int(scanJob.Status),
+		summaryFieldError:  scanJob.Error,
+	}
+	if created {
+		fields[summaryFieldCreatedAt] = now.Format(time.RFC3339Nano)
+	}
+	switch scanJob.Status {
+	case job.Pending:
+		fields[summaryFieldStartedAt] = now.Format(time.RFC3339Nano)
+	case job.Finished, job.Failed:
+		fields[summaryFieldFinishedAt] = now.Format(time.RFC3339Nano)
+	}
+	for severity, count := range persistence.CountSeverities(scanJob.Report) {
+		fields[summaryFieldCountPrefix+severity] = count
+	}
+
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		s.expireSummary(ctx, pipe, scanJob.ID, now, s.cfg.ScanJobTTL)
+		pipe.HSet(ctx, s.keyForSummary(scanJob.ID), fields)
+		pipe.Expire(ctx, s.keyForSummary(scanJob.ID), s.cfg.ScanJobTTL)
+		return nil
+	})
+	if err != nil {
+		return xerrors.Errorf("saving scan job summary: %w", err)
+	}
+	return nil
+}
+
+// expireSummary queues the commands rescoring the summary of the given scan job with its expiry time, and
+// pruning the summaries which already expired.
+func (s *store) expireSummary(ctx context.Context, pipe redis.Pipeliner, scanJobID string, now time.Time, ttl time.Duration) {
+	pipe.ZAdd(ctx, s.keyForSummaries(), redis.Z{Score: float64(now.Add(ttl).UnixMilli()), Member: scanJobID})
+	pipe.ZRemRangeByScore(ctx, s.keyForSummaries(), "-inf", "("+strconv.FormatInt(now.UnixMilli(), 10))
+}
+
+// ListSummaries reads the summaries sorted set from the most recently updated scan job, and fetches the
+// summary hashes with a single pipeline. Scan jobs expired in the meantime are skipped.
+func (s *store) ListSummaries(ctx context.Context, limit int) ([]job.Summary, error) {
+	ids, err := s.rdb.ZRevRangeByScore(ctx, s.keyForSummaries(), &redis.ZRangeBy{
+		Min: strconv.FormatInt(s.now().UnixMilli(), 10),
+		Max: "+inf",
+		// A zero count lists all summaries.
+		Count: int64(max(limit, 0)),
+	}).Result()
+	if err != nil {
+		return nil, xerrors.Errorf("listing scan job summaries: %w", err)
+	}
+
+	cmds := make([]*redis.MapStringStringCmd, len(ids))
+	_, err = s.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, id := range ids {
+			cmds[i] = pipe.HGetAll(ctx, s.keyForSummary(id))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("getting scan job summaries: %w", err)
+	}
+
+	summaries := make([]job.Summary, 0, len(ids))
+	for i, cmd := range cmds {
+		if len(cmd.Val()) == 0 {
+			continue
+		}
+		summary, err := parseSummary(ids[i], cmd.Val())
+		if err != nil {
+			return nil, xerrors.Errorf("parsing scan job summary %s: %w", ids[i], err)
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+// List scans the scan job keys of the namespace like FindByStatus, and decodes the ID, status, error and artifact
+// of each scan job without its report. The summary hashes of the listed page are fetched with a single pipeline.
+func (s *store) List(ctx context.Context, options persistence.ListOptions) ([]job.Entry, error) {
+	entries := make([]job.Entry, 0)
+	err := redisx.Scan(ctx, s.rdb, s.keyForScanJob("*"), findBatchSize, func(keys []string) error {
+		values, err := s.rdb.MGet(ctx, keys...).Result()
+		if err != nil {
+			return xerrors.Errorf("getting scan jobs: %w", err)
+		}
+		for _, value := range values {
+			// Expired since the key was scanned.
+			if value == nil {
+				continue
+			}
+			entry, err := job.UnmarshalEntry([]byte(value.(string)))
+			if err != nil {
+				return xerrors.Errorf("unmarshalling scan job: %w", err)
+			}
+			if options.Matches(entry) {
+				entries = append(entries, entry)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	entries = options.Page(entries)
+
+	cmds := make([]*redis.MapStringStringCmd, len(entries))
+	_, err = s.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, entry := range entries {
+			cmds[i] = pipe.HGetAll(ctx, s.keyForSummary(entry.ID))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("getting scan job summaries: %w", err)
+	}
+
+	for i, cmd := range cmds {
+		// Scan jobs stored before summaries were introduced have none.
+		if len(cmd.Val()) == 0 {
+			continue
+		}
+		summary, err := parseSummary(entries[i].ID, cmd.Val())
+		if err != nil {
+			return nil, xerrors.Errorf("parsing scan job summary %s: %w", entries[i].ID, err)
+		}
+		// The status and error of the scan job are authoritative.
+		summary.Status, summary.Error = entries[i].Status, entries[i].Error
+		entries[i].Summary = summary
+	}
+	return entries, nil
+}
+
+func parseSummary(scanJobID string, fields map[string]string) (job.Summary, error) {
+	status, err := strconv.Atoi(fields[summaryFieldStatus])
+	if err != nil {
+		return job.Summary{}, xerrors.Errorf("parsing status: %w", err)
+	}
+	summary := job.Summary{
+		ID:     scanJobID,
+		Status: job.ScanJobStatus(status),
+		Error:  fields[summaryFieldError],
+		Counts: make(map[string]int),
+	}
+
+	for field, timestamp := range map[string]*time.Time{
+		summaryFieldCreatedAt:  &summary.CreatedAt,
+		summaryFieldStartedAt:  &summary.StartedAt,
+		summaryFieldFinishedAt: &summary.FinishedAt,
+	} {
+		value, ok := fields[field]
+		if !ok {
+			continue
+		}
+		if *timestamp, err = time.Parse(time.RFC3339Nano, value); err != nil {
+			return job.Summary{}, xerrors.Errorf("parsing %s: %w", field, err)
+		}
+	}
+
+	for field, value := range fields {
+		severity, ok := strings.CutPrefix(field, summaryFieldCountPrefix)
+		if !ok {
+			continue
+		}
+		if summary.Counts[severity], err = strconv.Atoi(value); err != nil {
+			return job.Summary{}, xerrors.Errorf("parsing %s: %w", field, err)
+		}
+	}
+	return summary, nil
+}
+
+func (s *store) keyForSummary(scanJobID string) string {
+	return fmt.Sprintf("%s:scan-job-summary:%s", s.cfg.Namespace, scanJobID)
+}
+
+func (s *store) keyForSummaries() string {
+	return fmt.Sprintf("%s:scan-job-summaries", s.cfg.Namespace)
+}
@@ -0,0 +1,28 @@
+package persistence
+
+import (
+	"context"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+)
+
+// SBOMArtifact is an artifact with a stored SBOM.
+type SBOMArtifact struct {
+	Registry   string    `json:"registry,omitempty"`
+	Repository string    `json:"repository"`
+	Digest     string    `json:"digest"`
+	StoredAt   time.Time `json:"stored_at"`
+}
+
+// SBOMStore keeps the SBOMs of scanned artifacts, so that artifacts can be rescanned without pulling and
+// analyzing their images again.
+type SBOMStore interface {
+	// Save stores the SBOM of the given artifact, which was pulled from the given registry. SBOMs are keyed
+	// by digest, hence artifacts with the same digest share the SBOM.
+	Save(ctx context.Context, registry string, artifact harbor.Artifact, sbom []byte) error
+	// Get returns the SBOM stored for the given digest, or nil if there is none.
+	Get(ctx context.Context, digest string) ([]byte, error)
+	// FindArtifacts returns the artifacts with stored SBOMs.
+	FindArtifacts(ctx context.Context) ([]SBOMArtifact, error)
+}
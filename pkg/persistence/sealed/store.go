@@ -0,0 +1,145 @@
+// Package sealed keeps the registry credentials of scan requests from being stored in plain text. Scan jobs keep
+// their scan request, so that the jobs published with ID-only envelopes or recovered after a restart can be
+// scanned, and that request carries the Authorization header sent to the registry.
+package sealed
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+)
+
+// prefix marks sealed authorizations, which tells them apart from the plain ones stored by earlier versions.
+const prefix = "sealed:v1:"
+
+type store struct {
+	persistence.Store
+	aead cipher.AEAD
+}
+
+// NewStore constructs a Store, which delegates to the given Store, but encrypts the registry authorization of the
+// scan requests of the created scan jobs with AES-GCM under the given key, and decrypts it when they are got.
+// Without a key the authorization is not stored at all.
+func NewStore(s persistence.Store, key []byte) (persistence.Store, error) {
+	if len(key) == 0 {
+		return &store{Store: s}, nil
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("constructing cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("constructing cipher: %w", err)
+	}
+	return &store{Store: s, aead: aead}, nil
+}
+
+func (s *store) Create(ctx context.Context, scanJob job.ScanJob) error {
+	request, err := s.sealRequest(scanJob.Request)
+	if err != nil {
+		return err
+	}
+	scanJob.Request = request
+	return s.Store.Create(ctx, scanJob)
+}
+
+func (s *store) Replace(ctx context.Context, scanJob job.ScanJob) error {
+	request, err := s.sealRequest(scanJob.Request)
+	if err != nil {
+		return err
+	}
+	scanJob.Request = request
+	return s.Store.Replace(ctx, scanJob)
+}
+
+func (s *store) Get(ctx context.Context, scanJobID string) (*job.ScanJob, error) {
+	scanJob, err := s.Store.Get(ctx, scanJobID)
+	if err != nil || scanJob == nil {
+		return scanJob, err
+	}
+	s.unseal(scanJob.ID, scanJob.Request)
+	return scanJob, nil
+}
+
+func (s *store) FindByStatus(ctx context.Context, statuses ...job.ScanJobStatus) ([]job.ScanJob, error) {
+	scanJobs, err := s.Store.FindByStatus(ctx, statuses...)
+	if err != nil {
+		return nil, err
+	}
+	for i := range scanJobs {
+		s.unseal(scanJobs[i].ID, scanJobs[i].Request)
+	}
+	return scanJobs, nil
+}
+
+// sealRequest returns a copy of the given scan request with its authorization sealed.
+func (s *store) sealRequest(request *harbor.ScanRequest) (*harbor.ScanRequest, error) {
+	if request == nil || request.Registry.Authorization == "" {
+		return request, nil
+	}
+	sealed := *request
+	authorization, err := s.seal(sealed.Registry.Authorization)
+	if err != nil {
+		return nil, err
+	}
+	sealed.Registry.Authorization = authorization
+	return &sealed, nil
+}
+
+// seal returns the encrypted authorization, or blank if there is no key to encrypt it with.
+func (s *store) seal(authorization string) (string, error) {
+	if s.aead == nil {
+		return "", nil
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("generating nonce: %w", err)
+	}
+	sealed := s.aead.Seal(nonce, nonce, []byte(authorization), nil)
+	return prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// unseal decrypts the authorization of the given scan request in place. Authorizations which cannot be decrypted,
+// e.g. because the key was rotated, are cleared, so that the registry is accessed anonymously rather than with
+// garbage credentials. Plain authorizations stored by earlier versions are left as is.
+func (s *store) unseal(scanJobID string, request *harbor.ScanRequest) {
+	if request == nil || !strings.HasPrefix(request.Registry.Authorization, prefix) {
+		return
+	}
+	authorization, err := s.open(strings.TrimPrefix(request.Registry.Authorization, prefix))
+	if err != nil {
+		slog.Warn("Error while decrypting registry authorization", slog.String("scan_job_id", scanJobID),
+			slog.String("err", err.Error()))
+	}
+	request.Registry.Authorization = authorization
+}
+
+func (s *store) open(encoded string) (string, error) {
+	if s.aead == nil {
+		return "", errors.New("no credentials key configured")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < s.aead.NonceSize() {
+		return "", errors.New("sealed authorization too short")
+	}
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+	plaintext, err := s.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,274 @@
+// Package spill keeps the vulnerabilities of large reports on disk rather than in the store. Stores encode whole
+// scan jobs, reports included, which holds several copies of huge reports in memory at once, e.g. the encoded
+// scan job and the Redis command sent with it, and bloats the memory of Redis itself. Spilled vulnerabilities
+// are instead streamed to and from files one at a time, and only a record of them is stored with the report.
+package spill
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/clock"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	"golang.org/x/xerrors"
+)
+
+// filePattern is the pattern of the names of the files vulnerabilities are spilled to.
+const filePattern = "vulnerabilities-*.json"
+
+type store struct {
+	persistence.Store
+	dir       string
+	threshold int
+}
+
+// NewStore constructs a Store, which delegates to the given Store, but spills the vulnerabilities of the reports
+// with more vulnerabilities than the configured threshold to files in the configured directory. The given Store
+// saves these reports without their vulnerabilities, which are read back from their file when scan jobs are got.
+func NewStore(s persistence.Store, cfg etc.Store) persistence.Store {
+	return &store{
+		Store:     s,
+		dir:       cfg.SpillDir,
+		threshold: cfg.SpillThreshold,
+	}
+}
+
+func (s *store) Create(ctx context.Context, scanJob job.ScanJob) error {
+	report, file, err := s.spill(ctx, scanJob.Report)
+	if err != nil {
+		return err
+	}
+	scanJob.Report = report
+	if err = s.Store.Create(ctx, scanJob); err != nil {
+		s.remove(file)
+		return err
+	}
+	return nil
+}
+
+func (s *store) Get(ctx context.Context, scanJobID string) (*job.ScanJob, error) {
+	scanJob, err := s.Store.Get(ctx, scanJobID)
+	if err != nil || scanJob == nil {
+		return scanJob, err
+	}
+	if scanJob.Report, err = s.restore(scanJob.Report); err != nil {
+		return nil, err
+	}
+	return scanJob, nil
+}
+
+func (s *store) Replace(ctx context.Context, scanJob job.ScanJob) error {
+	report, file, err := s.spill(ctx, scanJob.Report)
+	if err != nil {
+		return err
+	}
+	scanJob.Report = report
+	if err = s.Store.Replace(ctx, scanJob); err != nil {
+		s.remove(file)
+		return err
+	}
+	return nil
+}
+
+func (s *store) UpdateReport(ctx context.Context, scanJobID string, report harbor.ScanReport) error {
+	report, file, err := s.spill(ctx, report)
+	if err != nil {
+		return err
+	}
+	if err = s.Store.UpdateReport(ctx, scanJobID, report); err != nil {
+		s.remove(file)
+		return err
+	}
+	return nil
+}
+
+func (s *store) FindByStatus(ctx context.Context, statuses ...job.ScanJobStatus) ([]job.ScanJob, error) {
+	scanJobs, err := s.Store.FindByStatus(ctx, statuses...)
+	if err != nil {
+		return nil, err
+	}
+	for i := range scanJobs {
+		if scanJobs[i].Report, err = s.restore(scanJobs[i].Report); err != nil {
+			return nil, err
+		}
+	}
+	return scanJobs, nil
+}
+
+// spill writes the vulnerabilities of the given report to a new file if they are more than the threshold, and
+// returns a copy of the report recording the file instead of holding them, along with the path of the file. The
+// report is returned as is, with a blank path, if it is not spilled.
+func (s *store) spill(ctx context.Context, report harbor.ScanReport) (harbor.ScanReport, string, error) {
+	if len(report.Vulnerabilities) <= s.threshold {
+		return report, "", nil
+	}
+
+	f, err := os.CreateTemp(s.dir, filePattern)
+	if err != nil {
+		return report, "", xerrors.Errorf("creating spill file: %w", err)
+	}
+	if err = write(f, report.Vulnerabilities); err != nil {
+		s.remove(f.Name())
+		return report, "", xerrors.Errorf("spilling vulnerabilities: %w", err)
+	}
+	slog.DebugContext(ctx, "Spilled vulnerabilities of report", slog.String("file", f.Name()),
+		slog.Int("vulnerabilities", len(report.Vulnerabilities)))
+
+	attributes := make(map[string]interface{}, len(report.VendorAttributes)+1)
+	maps.Copy(attributes, report.VendorAttributes)
+	attributes[persistence.VendorAttributeSpilled] = persistence.Spilled{
+		File:   filepath.Base(f.Name()),
+		Counts: persistence.CountSeverities(report),
+	}
+	report.VendorAttributes = attributes
+	report.Vulnerabilities = nil
+	return report, f.Name(), nil
+}
+
+// write writes the given vulnerabilities to the given file as a JSON array, encoding them one at a time rather
+// than the whole array at once, and closes the file.
+func write(f *os.File, vulnerabilities []harbor.VulnerabilityItem) error {
+	w := bufio.NewWriter(f)
+	encoder := json.NewEncoder(w)
+	_, err := w.WriteString("[")
+	for i := 0; err == nil && i < len(vulnerabilities); i++ {
+		if i > 0 {
+			_, err = w.WriteString(",")
+		}
+		if err == nil {
+			err = encoder.Encode(vulnerabilities[i])
+		}
+	}
+	if err == nil {
+		_, err = w.WriteString("]")
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	return errors.Join(err, f.Close())
+}
+
+// restore returns a copy of the given report holding the vulnerabilities read back from the file it records, or
+// the report as is if it was not spilled.
+func (s *store) restore(report harbor.ScanReport) (harbor.ScanReport, error) {
+	spilled, ok := persistence.SpilledOf(report)
+	if !ok {
+		return report, nil
+	}
+	vulnerabilities, err := read(filepath.Join(s.dir, filepath.Base(spilled.File)))
+	if err != nil {
+		return report, xerrors.Errorf("reading spilled vulnerabilities: %w", err)
+	}
+
+	attributes := maps.Clone(report.VendorAttributes)
+	delete(attributes, persistence.VendorAttributeSpilled)
+	if len(attributes) == 0 {
+		attributes = nil
+	}
+	report.VendorAttributes = attributes
+	report.Vulnerabilities = vulnerabilities
+	return report, nil
+}
+
+// read decodes the vulnerabilities written to the given file one at a time.
+func read(path string) ([]harbor.VulnerabilityItem, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	decoder := json.NewDecoder(bufio.NewReader(f))
+	if t, err := decoder.Token(); err != nil {
+		return nil, err
+	} else if t != json.Delim('[') {
+		return nil, xerrors.Errorf("unexpected token %v", t)
+	}
+	vulnerabilities := make([]harbor.VulnerabilityItem, 0)
+	for decoder.More() {
+		var v harbor.VulnerabilityItem
+		if err = decoder.Decode(&v); err != nil {
+			return nil, err
+		}
+		vulnerabilities = append(vulnerabilities, v)
+	}
+	// A truncated file misses the end of the array.
+	if _, err = decoder.Token(); err != nil {
+		return nil, err
+	}
+	return vulnerabilities, nil
+}
+
+// remove removes the given spill file, if any. Failing to remove it only delays its removal to its expiry.
+func (s *store) remove(path string) {
+	if path == "" {
+		return
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Warn("Error while removing spill file", slog.String("file", path), slog.String("err", err.Error()))
+	}
+}
+
+// reapInterval is how often expired spill files are deleted.
+const reapInterval = 10 * time.Minute
+
+// Reap deletes the spill files of the configured directory once they are older than the configured TTL, until
+// the given context is done, as timed by the given Clock. The files of the reports replaced by newer ones, or of
+// expired scan jobs, are only deleted this way.
+func Reap(ctx context.Context, cfg etc.Store, c clock.Clock) {
+	ticker := time.NewTicker(reapInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := DeleteExpired(cfg.SpillDir, c.Now().Add(-cfg.SpillTTL))
+			if err != nil {
+				slog.Warn("Error while deleting expired spill files", slog.String("err", err.Error()))
+				continue
+			}
+			if deleted > 0 {
+				slog.Debug("Deleted expired spill files", slog.Int("count", deleted))
+			}
+		}
+	}
+}
+
+// DeleteExpired deletes the spill files of the given directory last modified before the given time, and returns
+// the number of deleted files.
+func DeleteExpired(dir string, before time.Time) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, xerrors.Errorf("listing spill files: %w", err)
+	}
+	prefix, suffix, _ := strings.Cut(filePattern, "*")
+	deleted := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(before) {
+			continue
+		}
+		if err = os.Remove(filepath.Join(dir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return deleted, xerrors.Errorf("deleting spill file: %w", err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
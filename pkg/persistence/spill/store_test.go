@@ -0,0 +1,120 @@
+package spill
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence/memory"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	small := harbor.ScanReport{
+		Severity:        harbor.SevHigh,
+		Vulnerabilities: []harbor.VulnerabilityItem{{ID: "CVE-2013-1400", Severity: harbor.SevHigh}},
+	}
+	large := harbor.ScanReport{
+		Severity: harbor.SevCritical,
+		Vulnerabilities: []harbor.VulnerabilityItem{
+			{ID: "CVE-2024-3094", Pkg: "xz", Severity: harbor.SevCritical},
+			{ID: "CVE-2023-4911", Pkg: "glibc", Severity: harbor.SevHigh},
+			{ID: "CVE-2023-5363", Pkg: "openssl", Severity: harbor.SevHigh},
+		},
+		VendorAttributes: map[string]interface{}{"image_kind": "distro"},
+	}
+
+	newStore := func(t *testing.T) (persistence.Store, persistence.Store, string) {
+		dir := t.TempDir()
+		backend := memory.NewStore(etc.MemoryStore{ScanJobTTL: time.Hour, MaxScanJobs: 10})
+		return NewStore(backend, etc.Store{SpillDir: dir, SpillThreshold: 2}), backend, dir
+	}
+
+	t.Run("Should store report within threshold as is", func(t *testing.T) {
+		s, backend, dir := newStore(t)
+		require.NoError(t, s.Create(ctx, job.ScanJob{ID: "123", Status: job.Queued}))
+		require.NoError(t, s.UpdateReport(ctx, "123", small))
+
+		stored, err := backend.Get(ctx, "123")
+		require.NoError(t, err)
+		assert.Equal(t, small, stored.Report)
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("Should spill vulnerabilities of report above threshold", func(t *testing.T) {
+		s, backend, dir := newStore(t)
+		require.NoError(t, s.Create(ctx, job.ScanJob{ID: "123", Status: job.Queued}))
+		require.NoError(t, s.UpdateReport(ctx, "123", large))
+		require.NoError(t, s.UpdateStatus(ctx, "123", job.Finished))
+
+		stored, err := backend.Get(ctx, "123")
+		require.NoError(t, err)
+		assert.Nil(t, stored.Report.Vulnerabilities)
+		spilled, ok := persistence.SpilledOf(stored.Report)
+		require.True(t, ok)
+		assert.FileExists(t, filepath.Join(dir, spilled.File))
+
+		scanJob, err := s.Get(ctx, "123")
+		require.NoError(t, err)
+		assert.Equal(t, large, scanJob.Report)
+
+		summaries, err := s.ListSummaries(ctx, 0)
+		require.NoError(t, err)
+		require.Len(t, summaries, 1)
+		assert.Equal(t, map[string]int{"Unknown": 0, "Low": 0, "Medium": 0, "High": 2, "Critical": 1},
+			summaries[0].Counts)
+	})
+
+	t.Run("Should spill vulnerabilities of created scan job above threshold", func(t *testing.T) {
+		s, _, _ := newStore(t)
+		require.NoError(t, s.Create(ctx, job.ScanJob{ID: "123", Status: job.Finished, Report: large}))
+
+		scanJobs, err := s.FindByStatus(ctx, job.Finished)
+		require.NoError(t, err)
+		require.Len(t, scanJobs, 1)
+		assert.Equal(t, large, scanJobs[0].Report)
+	})
+
+	t.Run("Should return error when spilled vulnerabilities are gone", func(t *testing.T) {
+		s, _, dir := newStore(t)
+		require.NoError(t, s.Create(ctx, job.ScanJob{ID: "123", Status: job.Finished, Report: large}))
+		deleted, err := DeleteExpired(dir, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, 1, deleted)
+
+		_, err = s.Get(ctx, "123")
+		assert.ErrorContains(t, err, "reading spilled vulnerabilities")
+	})
+}
+
+func TestDeleteExpired(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	for name, modTime := range map[string]time.Time{
+		"vulnerabilities-1.json": now.Add(-3 * time.Hour),
+		"vulnerabilities-2.json": now.Add(-time.Minute),
+		"other.json":             now.Add(-3 * time.Hour),
+	} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte("[]"), 0600))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+
+	deleted, err := DeleteExpired(dir, now.Add(-2*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	assert.NoFileExists(t, filepath.Join(dir, "vulnerabilities-1.json"))
+	assert.FileExists(t, filepath.Join(dir, "vulnerabilities-2.json"))
+	assert.FileExists(t, filepath.Join(dir, "other.json"))
+}
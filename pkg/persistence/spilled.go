@@ -0,0 +1,59 @@
+package persistence
+
+import (
+	"encoding/json"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+)
+
+// VendorAttributeSpilled is the vendor attribute of the reports stored without their vulnerabilities, which were
+// spilled to disk because they were too many. It holds the Spilled record of the vulnerabilities, and is removed
+// once they are read back.
+const VendorAttributeSpilled = "spilled"
+
+// Spilled records the file the vulnerabilities of a report were spilled to, along with their numbers keyed by
+// severity name, so that the summaries of scan jobs are counted without reading the vulnerabilities back.
+type Spilled struct {
+	File   string         `json:"file"`
+	Counts map[string]int `json:"counts"`
+}
+
+// SpilledOf returns the Spilled record of the given report, or false if its vulnerabilities are stored with it.
+func SpilledOf(report harbor.ScanReport) (Spilled, bool) {
+	value, ok := report.VendorAttributes[VendorAttributeSpilled]
+	if !ok {
+		return Spilled{}, false
+	}
+	if spilled, ok := value.(Spilled); ok {
+		return spilled, true
+	}
+	// Stores decoding reports from JSON hold the record as a generic map.
+	data, err := json.Marshal(value)
+	if err != nil {
+		return Spilled{}, false
+	}
+	var spilled Spilled
+	if err = json.Unmarshal(data, &spilled); err != nil || spilled.File == "" {
+		return Spilled{}, false
+	}
+	return spilled, true
+}
+
+// CountSeverities returns the numbers of vulnerabilities of the given report keyed by severity name, including
+// the severities which have not been reported. The vulnerabilities of spilled reports are counted by their
+// Spilled record.
+func CountSeverities(report harbor.ScanReport) map[string]int {
+	counts := make(map[string]int)
+	for severity := harbor.SevUnknown; severity <= harbor.SevCritical; severity++ {
+		counts[severity.String()] = 0
+	}
+	if spilled, ok := SpilledOf(report); ok {
+		for severity, count := range spilled.Counts {
+			counts[severity] += count
+		}
+	}
+	for _, v := range report.Vulnerabilities {
+		counts[v.Severity.String()]++
+	}
+	return counts
+}
@@ -2,13 +2,66 @@ package persistence
 
 import (
 	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
 )
 
+// ErrScanJobExists is returned by Create if a scan job with the same ID already exists.
+var ErrScanJobExists = errors.New("scan job already exists")
+
 type Store interface {
+	// Create saves the given scan job, or returns an error wrapping ErrScanJobExists if a scan job with the same
+	// ID already exists, which is left as is.
 	Create(ctx context.Context, scanJob job.ScanJob) error
 	Get(ctx context.Context, scanJobID string) (*job.ScanJob, error)
+	// Replace overwrites the scan job with the same ID as the given scan job, or returns an error if there is none.
+	// Like updates, it resets the TTL of the scan job.
+	Replace(ctx context.Context, scanJob job.ScanJob) error
 	UpdateStatus(ctx context.Context, scanJobID string, newStatus job.ScanJobStatus, error ...string) error
 	UpdateReport(ctx context.Context, scanJobID string, report harbor.ScanReport) error
+	// UpdateSBOM saves the SBOM report of the scan job requested for the sbom capability.
+	UpdateSBOM(ctx context.Context, scanJobID string, sbom harbor.SBOMReport) error
+	// FindByStatus returns the scan jobs in any of the given statuses.
+	FindByStatus(ctx context.Context, statuses ...job.ScanJobStatus) ([]job.ScanJob, error)
+	// Extend makes sure that the scan job does not expire within the given duration, nor within its configured
+	// TTL, which keeps running scan jobs from expiring mid-flight.
+	Extend(ctx context.Context, scanJobID string, ttl time.Duration) error
+	// ListSummaries returns the summaries of the most recently updated scan jobs, most recent first, without
+	// reading their reports. A limit of zero returns the summaries of all scan jobs.
+	ListSummaries(ctx context.Context, limit int) ([]job.Summary, error)
+	// List returns the entries of the scan jobs matching the given options ordered by ID, without reading their
+	// reports.
+	List(ctx context.Context, options ListOptions) ([]job.Entry, error)
+}
+
+// ListOptions filters and paginates the scan jobs returned by List.
+type ListOptions struct {
+	// Statuses keeps the scan jobs in any of the given statuses, or all scan jobs if empty.
+	Statuses []job.ScanJobStatus
+	// After is the ID of the last scan job of the previous page, or blank for the first page.
+	After string
+	// Limit is the maximum number of entries returned. A limit of zero returns all entries.
+	Limit int
+}
+
+// Matches returns true if the given entry is listed with the options, regardless of the limit.
+func (o ListOptions) Matches(entry job.Entry) bool {
+	if o.After != "" && entry.ID <= o.After {
+		return false
+	}
+	return len(o.Statuses) == 0 || slices.Contains(o.Statuses, entry.Status)
+}
+
+// Page sorts the given entries by ID and keeps the first ones up to the limit.
+func (o ListOptions) Page(entries []job.Entry) []job.Entry {
+	slices.SortFunc(entries, func(a, b job.Entry) int { return strings.Compare(a.ID, b.ID) })
+	if o.Limit > 0 && len(entries) > o.Limit {
+		entries = entries[:o.Limit]
+	}
+	return entries
 }
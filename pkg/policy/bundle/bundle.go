@@ -0,0 +1,92 @@
+// Package bundle loads the policy and the Tunnel ignore policy from a signed bundle, which is pulled from the
+// registry as an OCI artifact, or downloaded as a tarball, so that a single bundle can be distributed to many
+// adapter deployments. Bundles are verified before being applied, and refreshed periodically.
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/opencontainers/go-digest"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/policy"
+)
+
+// Files of the bundle tarball.
+const (
+	FilePolicy       = "policy.json"
+	FileIgnorePolicy = "ignore-policy.rego"
+)
+
+// maxBundleSize bounds the size of downloaded bundles, and of the files they hold.
+const maxBundleSize = 8 << 20
+
+// Bundle is a verified policy bundle.
+type Bundle struct {
+	// Digest is the digest of the bundle tarball, which identifies the bundle regardless of where it is pulled
+	// from.
+	Digest digest.Digest
+	Policy policy.Policy
+	// IgnorePolicy is the Tunnel ignore policy, nil if the bundle does not hold one.
+	IgnorePolicy []byte
+}
+
+// Parse reads the policy bundle from the given gzipped tarball, which must hold the policy file, and may hold
+// the Tunnel ignore policy. Bundles holding any other file are rejected, so that a bundle is never applied
+// partially.
+func Parse(tarball []byte) (Bundle, error) {
+	b := Bundle{Digest: digest.FromBytes(tarball)}
+
+	gz, err := gzip.NewReader(bytes.NewReader(tarball))
+	if err != nil {
+		return Bundle{}, fmt.Errorf("decompressing bundle: %w", err)
+	}
+	tr := tar.NewReader(gz)
+
+	var policyFile []byte
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return Bundle{}, fmt.Errorf("reading bundle: %w", err)
+		}
+		if header.Typeflag == tar.TypeDir {
+			continue
+		}
+		if header.Typeflag != tar.TypeReg {
+			return Bundle{}, fmt.Errorf("unexpected entry in bundle: %s", header.Name)
+		}
+
+		content, err := io.ReadAll(io.LimitReader(tr, maxBundleSize+1))
+		if err != nil {
+			return Bundle{}, fmt.Errorf("reading %s: %w", header.Name, err)
+		}
+		if len(content) > maxBundleSize {
+			return Bundle{}, fmt.Errorf("reading %s: exceeds %d bytes", header.Name, maxBundleSize)
+		}
+
+		switch path.Clean(header.Name) {
+		case FilePolicy:
+			policyFile = content
+		case FileIgnorePolicy:
+			b.IgnorePolicy = content
+		default:
+			return Bundle{}, fmt.Errorf("unexpected file in bundle: %s", header.Name)
+		}
+	}
+
+	if policyFile == nil {
+		return Bundle{}, fmt.Errorf("bundle does not hold %s", FilePolicy)
+	}
+	if b.Policy, err = policy.Decode(bytes.NewReader(policyFile)); err != nil {
+		return Bundle{}, err
+	}
+	return b, nil
+}
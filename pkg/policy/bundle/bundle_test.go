@@ -0,0 +1,97 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"sort"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/policy"
+)
+
+const (
+	testPolicy       = `{"fail_on": "High", "projects": [{"name": "storefront", "tags": ["exposure=internet"]}], "severity_weights": {"exposure=internet": 1}}`
+	testIgnorePolicy = "package tunnel\n\ndefault ignore = true\n"
+)
+
+// newTarball returns the gzipped tarball of the given files, which are written in order of name.
+func newTarball(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b bytes.Buffer
+	gz := gzip.NewWriter(&b)
+	tw := tar.NewWriter(gz)
+	for _, name := range names {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(files[name])), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(files[name]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return b.Bytes()
+}
+
+func TestParse(t *testing.T) {
+	t.Run("Should parse bundle", func(t *testing.T) {
+		tarball := newTarball(t, map[string]string{
+			FilePolicy:              testPolicy,
+			"./" + FileIgnorePolicy: testIgnorePolicy,
+		})
+
+		b, err := Parse(tarball)
+		require.NoError(t, err)
+		assert.Equal(t, Bundle{
+			Digest: digest.FromBytes(tarball),
+			Policy: policy.Policy{
+				FailOn:          "High",
+				Projects:        []policy.Project{{Name: "storefront", Tags: []string{"exposure=internet"}}},
+				SeverityWeights: map[string]int{"exposure=internet": 1},
+			},
+			IgnorePolicy: []byte(testIgnorePolicy),
+		}, b)
+	})
+
+	testCases := []struct {
+		name          string
+		files         map[string]string
+		expectedError string
+	}{
+		{
+			name:          "Should return error when bundle does not hold policy",
+			files:         map[string]string{FileIgnorePolicy: testIgnorePolicy},
+			expectedError: "bundle does not hold policy.json",
+		},
+		{
+			name:          "Should return error when bundle holds unexpected file",
+			files:         map[string]string{FilePolicy: testPolicy, "../etc/passwd": "root:x:0:0"},
+			expectedError: "unexpected file in bundle: ../etc/passwd",
+		},
+		{
+			name:          "Should return error when policy is invalid",
+			files:         map[string]string{FilePolicy: `{"fail_on": "Severe"}`},
+			expectedError: "policy fail_on: unknown severity: Severe",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse(newTarball(t, tc.files))
+			assert.EqualError(t, err, tc.expectedError)
+		})
+	}
+
+	t.Run("Should return error when bundle is not gzipped", func(t *testing.T) {
+		_, err := Parse([]byte(testPolicy))
+		assert.EqualError(t, err, "decompressing bundle: gzip: invalid header")
+	})
+}
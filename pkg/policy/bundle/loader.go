@@ -0,0 +1,170 @@
+package bundle
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/policy"
+)
+
+// defaultIgnorePolicy is written in place of the Tunnel ignore policy of bundles which do not hold one, as the
+// path of the ignore policy is passed to Tunnel before the bundle is known.
+const defaultIgnorePolicy = `package tunnel
+
+default ignore = false
+`
+
+// Loader loads the policy bundle, and evaluates scan reports against the policy of the bundle applied last.
+type Loader interface {
+	policy.Engine
+	// Load fetches and verifies the bundle, and applies it unless it is the bundle applied last.
+	Load(ctx context.Context) error
+	// Run reloads the bundle at the configured interval until the given context is done. The bundle applied
+	// last is kept when a reload fails.
+	Run(ctx context.Context)
+	// IgnorePolicyFile returns the path the Tunnel ignore policy of the bundle applied last is written to.
+	IgnorePolicyFile() string
+}
+
+type loader struct {
+	config   etc.Policy
+	source   Source
+	verifier Verifier
+
+	mu     sync.RWMutex
+	digest digest.Digest
+	engine policy.Engine
+}
+
+// NewLoader constructs a Loader of the bundle fetched from the given Source, which is only applied once its
+// signature is verified by the given Verifier.
+func NewLoader(config etc.Policy, source Source, verifier Verifier) Loader {
+	return &loader{
+		config:   config,
+		source:   source,
+		verifier: verifier,
+	}
+}
+
+func (l *loader) IgnorePolicyFile() string {
+	return filepath.Join(l.config.BundleDir, FileIgnorePolicy)
+}
+
+// Evaluate fails every scan report until a bundle has been applied.
+func (l *loader) Evaluate(report harbor.ScanReport) policy.Verdict {
+	l.mu.RLock()
+	engine := l.engine
+	l.mu.RUnlock()
+
+	if engine == nil {
+		return policy.Verdict{Project: policy.ProjectOf(report.Artifact.Repository)}
+	}
+	return engine.Evaluate(report)
+}
+
+// ScanTimeout does not extend the timeout until a bundle has been applied.
+func (l *loader) ScanTimeout(repository string) time.Duration {
+	l.mu.RLock()
+	engine := l.engine
+	l.mu.RUnlock()
+
+	if engine == nil {
+		return 0
+	}
+	return engine.ScanTimeout(repository)
+}
+
+func (l *loader) Load(ctx context.Context) error {
+	tarball, signature, err := l.source.Fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("fetching bundle: %w", err)
+	}
+	if err = l.verifier.Verify(tarball, signature); err != nil {
+		return fmt.Errorf("verifying bundle: %w", err)
+	}
+
+	dgst := digest.FromBytes(tarball)
+	l.mu.RLock()
+	unchanged := dgst == l.digest
+	l.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	b, err := Parse(tarball)
+	if err != nil {
+		return fmt.Errorf("parsing bundle %s: %w", dgst, err)
+	}
+	engine, err := policy.NewEngine(b.Policy)
+	if err != nil {
+		return fmt.Errorf("parsing bundle %s: %w", dgst, err)
+	}
+	ignorePolicy := b.IgnorePolicy
+	if ignorePolicy == nil {
+		ignorePolicy = []byte(defaultIgnorePolicy)
+	}
+	if err = l.writeIgnorePolicy(ignorePolicy); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	l.digest, l.engine = b.Digest, engine
+	l.mu.Unlock()
+
+	slog.Info("Applied policy bundle", slog.String("digest", b.Digest.String()),
+		slog.Bool("ignore_policy", b.IgnorePolicy != nil))
+	return nil
+}
+
+// writeIgnorePolicy replaces the Tunnel ignore policy file with a rename, so that Tunnel never reads a partially
+// written file.
+func (l *loader) writeIgnorePolicy(content []byte) error {
+	if err := os.MkdirAll(l.config.BundleDir, 0o755); err != nil {
+		return fmt.Errorf("creating bundle dir: %w", err)
+	}
+	f, err := os.CreateTemp(l.config.BundleDir, FileIgnorePolicy+".*")
+	if err != nil {
+		return fmt.Errorf("writing ignore policy: %w", err)
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	if _, err = f.Write(content); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("writing ignore policy: %w", err)
+	}
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("writing ignore policy: %w", err)
+	}
+	if err = os.Chmod(f.Name(), 0o644); err != nil {
+		return fmt.Errorf("writing ignore policy: %w", err)
+	}
+	if err = os.Rename(f.Name(), l.IgnorePolicyFile()); err != nil {
+		return fmt.Errorf("writing ignore policy: %w", err)
+	}
+	return nil
+}
+
+func (l *loader) Run(ctx context.Context) {
+	ticker := time.NewTicker(l.config.BundleRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.Load(ctx); err != nil {
+				slog.Error("Error while reloading policy bundle, keeping the bundle applied last",
+					slog.String("err", err.Error()))
+			}
+		}
+	}
+}
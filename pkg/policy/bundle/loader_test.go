@@ -0,0 +1,84 @@
+package bundle
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+)
+
+// fakeSource returns the given bundle, or fails with the given error.
+type fakeSource struct {
+	tarball   []byte
+	signature string
+	err       error
+}
+
+func (s *fakeSource) Fetch(_ context.Context) ([]byte, string, error) {
+	return s.tarball, s.signature, s.err
+}
+
+func TestLoader(t *testing.T) {
+	ctx := context.Background()
+	publicKey, sign := newSigner(t)
+	verifier, err := NewVerifier(publicKey)
+	require.NoError(t, err)
+
+	report := harbor.ScanReport{
+		Artifact:        harbor.Artifact{Repository: "storefront/web"},
+		Vulnerabilities: []harbor.VulnerabilityItem{{ID: "CVE-2019-1549", Severity: harbor.SevMedium}},
+	}
+
+	t.Run("Should apply verified bundle", func(t *testing.T) {
+		tarball := newTarball(t, map[string]string{FilePolicy: testPolicy, FileIgnorePolicy: testIgnorePolicy})
+		loader := NewLoader(etc.Policy{BundleDir: t.TempDir()}, &fakeSource{tarball: tarball, signature: sign(tarball)}, verifier)
+
+		assert.False(t, loader.Evaluate(report).Passed, "scan reports should fail until a bundle is applied")
+
+		require.NoError(t, loader.Load(ctx))
+
+		verdict := loader.Evaluate(report)
+		assert.False(t, verdict.Passed)
+		assert.Equal(t, harbor.SevHigh, verdict.Violations[0].WeightedSeverity)
+		ignorePolicy, err := os.ReadFile(loader.IgnorePolicyFile())
+		require.NoError(t, err)
+		assert.Equal(t, testIgnorePolicy, string(ignorePolicy))
+	})
+
+	t.Run("Should write default ignore policy when bundle does not hold one", func(t *testing.T) {
+		tarball := newTarball(t, map[string]string{FilePolicy: testPolicy})
+		loader := NewLoader(etc.Policy{BundleDir: t.TempDir()}, &fakeSource{tarball: tarball, signature: sign(tarball)}, verifier)
+
+		require.NoError(t, loader.Load(ctx))
+
+		ignorePolicy, err := os.ReadFile(loader.IgnorePolicyFile())
+		require.NoError(t, err)
+		assert.Equal(t, defaultIgnorePolicy, string(ignorePolicy))
+	})
+
+	t.Run("Should keep bundle applied last when reload fails", func(t *testing.T) {
+		tarball := newTarball(t, map[string]string{FilePolicy: testPolicy, FileIgnorePolicy: testIgnorePolicy})
+		source := &fakeSource{tarball: tarball, signature: sign(tarball)}
+		loader := NewLoader(etc.Policy{BundleDir: t.TempDir()}, source, verifier)
+		require.NoError(t, loader.Load(ctx))
+
+		tampered := newTarball(t, map[string]string{FilePolicy: `{"fail_on": "Critical"}`})
+		source.tarball = tampered
+		assert.ErrorIs(t, loader.Load(ctx), ErrInvalidSignature)
+
+		source.tarball, source.signature = nil, ""
+		source.err = errors.New("connection refused")
+		assert.EqualError(t, loader.Load(ctx), "fetching bundle: connection refused")
+
+		assert.Equal(t, harbor.SevHigh, loader.Evaluate(report).Violations[0].WeightedSeverity)
+		ignorePolicy, err := os.ReadFile(loader.IgnorePolicyFile())
+		require.NoError(t, err)
+		assert.Equal(t, testIgnorePolicy, string(ignorePolicy))
+	})
+}
@@ -0,0 +1,173 @@
+package bundle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/registry"
+)
+
+const (
+	// MediaTypeBundle is the media type of the layer of the OCI artifact holding the bundle tarball.
+	MediaTypeBundle = "application/vnd.khulnasoft.harbor-scanner-tunnel.policy-bundle.v1.tar+gzip"
+	// AnnotationSignature is the annotation of the bundle layer holding the base64 encoded signature of the bundle.
+	AnnotationSignature = "io.khulnasoft.harbor-scanner-tunnel.policy-bundle.signature"
+	// SignatureSuffix is appended to the URL of a bundle tarball to download its base64 encoded signature.
+	SignatureSuffix = ".sig"
+)
+
+const mediaTypeOCIManifest = "application/vnd.oci.image.manifest.v1+json"
+
+// Source wraps the Fetch method.
+// Fetch downloads the bundle tarball and its base64 encoded signature.
+type Source interface {
+	Fetch(ctx context.Context) (tarball []byte, signature string, err error)
+}
+
+// NewSource constructs the Source of the configured bundle URL, which authenticates with the configured
+// credentials. TLS certificates are verified like the ones of the registries of scan requests.
+func NewSource(config etc.Policy, tunnel etc.Tunnel) (Source, error) {
+	u, err := url.Parse(config.BundleURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing bundle URL: %w", err)
+	}
+	client, err := registry.NewHTTPClient(tunnel, u.Host)
+	if err != nil {
+		return nil, err
+	}
+	c := &httpClient{client: client, username: config.BundleUsername, password: config.BundlePassword}
+
+	switch u.Scheme {
+	case "oci":
+		repository, reference := parseReference(strings.TrimPrefix(u.Path, "/"))
+		if repository == "" {
+			return nil, fmt.Errorf("bundle URL has no repository: %s", config.BundleURL)
+		}
+		return &ociSource{client: c, url: "https://" + u.Host + "/v2/" + repository, reference: reference}, nil
+	case "http", "https":
+		return &tarballSource{client: c, url: config.BundleURL}, nil
+	}
+	return nil, fmt.Errorf("unsupported bundle URL scheme: %s", u.Scheme)
+}
+
+// parseReference splits the given repository path into the repository and the tag or digest, which defaults to
+// the latest tag.
+func parseReference(path string) (repository, reference string) {
+	if repository, reference, ok := strings.Cut(path, "@"); ok {
+		return repository, reference
+	}
+	if i := strings.LastIndex(path, ":"); i > strings.LastIndex(path, "/") {
+		return path[:i], path[i+1:]
+	}
+	return path, "latest"
+}
+
+// tarballSource downloads the bundle tarball from its URL, and its signature from the URL with the
+// SignatureSuffix.
+type tarballSource struct {
+	client *httpClient
+	url    string
+}
+
+func (s *tarballSource) Fetch(ctx context.Context) ([]byte, string, error) {
+	tarball, err := s.client.get(ctx, s.url, "*/*")
+	if err != nil {
+		return nil, "", err
+	}
+	signature, err := s.client.get(ctx, s.url+SignatureSuffix, "*/*")
+	if err != nil {
+		return nil, "", err
+	}
+	return tarball, string(signature), nil
+}
+
+// ociSource pulls the bundle tarball from the layer of an OCI artifact, whose signature is annotated on the
+// layer descriptor.
+type ociSource struct {
+	client    *httpClient
+	url       string
+	reference string
+}
+
+type manifest struct {
+	Layers []struct {
+		MediaType   string            `json:"mediaType"`
+		Digest      string            `json:"digest"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"layers"`
+}
+
+func (s *ociSource) Fetch(ctx context.Context) ([]byte, string, error) {
+	b, err := s.client.get(ctx, s.url+"/manifests/"+s.reference, mediaTypeOCIManifest)
+	if err != nil {
+		return nil, "", err
+	}
+	var m manifest
+	if err = json.Unmarshal(b, &m); err != nil {
+		return nil, "", fmt.Errorf("decoding manifest: %w", err)
+	}
+
+	for _, layer := range m.Layers {
+		if layer.MediaType != MediaTypeBundle {
+			continue
+		}
+		expected, err := digest.Parse(layer.Digest)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid digest: %s", layer.Digest)
+		}
+		tarball, err := s.client.get(ctx, s.url+"/blobs/"+layer.Digest, "*/*")
+		if err != nil {
+			return nil, "", err
+		}
+		if actual := expected.Algorithm().FromBytes(tarball); actual != expected {
+			return nil, "", fmt.Errorf("blob digest mismatch: expected %s, got %s", expected, actual)
+		}
+		return tarball, layer.Annotations[AnnotationSignature], nil
+	}
+	return nil, "", fmt.Errorf("manifest %s has no layer of media type %s", s.reference, MediaTypeBundle)
+}
+
+type httpClient struct {
+	client   *http.Client
+	username string
+	password string
+}
+
+// get returns the body of the response to a GET request of the given URL, which fails unless the status is 200.
+func (c *httpClient) get(ctx context.Context, rawURL, accept string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("getting %s: %w", req.URL.Path, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("getting %s: unexpected status %d", req.URL.Path, res.StatusCode)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(res.Body, maxBundleSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", req.URL.Path, err)
+	}
+	if len(b) > maxBundleSize {
+		return nil, fmt.Errorf("reading %s: exceeds %d bytes", req.URL.Path, maxBundleSize)
+	}
+	return b, nil
+}
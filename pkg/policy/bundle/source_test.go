@@ -0,0 +1,110 @@
+package bundle
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+)
+
+func TestParseReference(t *testing.T) {
+	testCases := []struct {
+		path               string
+		expectedRepository string
+		expectedReference  string
+	}{
+		{path: "security/policy:v3", expectedRepository: "security/policy", expectedReference: "v3"},
+		{path: "security/policy@sha256:917f", expectedRepository: "security/policy", expectedReference: "sha256:917f"},
+		{path: "security/policy", expectedRepository: "security/policy", expectedReference: "latest"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			repository, reference := parseReference(tc.path)
+			assert.Equal(t, tc.expectedRepository, repository)
+			assert.Equal(t, tc.expectedReference, reference)
+		})
+	}
+}
+
+func TestSource(t *testing.T) {
+	tarball := newTarball(t, map[string]string{FilePolicy: testPolicy})
+	dgst := digest.FromBytes(tarball)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "robot$policy" || password != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/bundles/policy.tar.gz":
+			_, _ = w.Write(tarball)
+		case "/bundles/policy.tar.gz.sig":
+			_, _ = w.Write([]byte("c2lnbmF0dXJl\n"))
+		case "/v2/security/policy/manifests/v3":
+			assert.Equal(t, mediaTypeOCIManifest, r.Header.Get("Accept"))
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"layers": []map[string]any{
+					{"mediaType": "application/vnd.oci.empty.v1+json", "digest": digest.FromString("{}")},
+					{"mediaType": MediaTypeBundle, "digest": dgst, "annotations": map[string]string{AnnotationSignature: "c2lnbmF0dXJl"}},
+				},
+			})
+		case "/v2/security/policy/blobs/" + dgst.String():
+			_, _ = w.Write(tarball)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "https://")
+
+	testCases := []struct {
+		name      string
+		bundleURL string
+	}{
+		{
+			name:      "Should download tarball and its signature",
+			bundleURL: server.URL + "/bundles/policy.tar.gz",
+		},
+		{
+			name:      "Should pull OCI artifact and its signature",
+			bundleURL: "oci://" + host + "/security/policy:v3",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			source, err := NewSource(etc.Policy{BundleURL: tc.bundleURL, BundleUsername: "robot$policy", BundlePassword: "s3cret"},
+				etc.Tunnel{Insecure: true})
+			require.NoError(t, err)
+
+			b, signature, err := source.Fetch(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, tarball, b)
+			assert.Equal(t, "c2lnbmF0dXJl", strings.TrimSpace(signature))
+		})
+	}
+
+	t.Run("Should return error when OCI artifact does not exist", func(t *testing.T) {
+		source, err := NewSource(etc.Policy{BundleURL: "oci://" + host + "/security/policy:v4", BundleUsername: "robot$policy", BundlePassword: "s3cret"},
+			etc.Tunnel{Insecure: true})
+		require.NoError(t, err)
+
+		_, _, err = source.Fetch(context.Background())
+		assert.EqualError(t, err, "getting /v2/security/policy/manifests/v4: unexpected status 404")
+	})
+
+	t.Run("Should return error when unauthorized", func(t *testing.T) {
+		source, err := NewSource(etc.Policy{BundleURL: server.URL + "/bundles/policy.tar.gz"}, etc.Tunnel{Insecure: true})
+		require.NoError(t, err)
+
+		_, _, err = source.Fetch(context.Background())
+		assert.EqualError(t, err, "getting /bundles/policy.tar.gz: unexpected status 401")
+	})
+}
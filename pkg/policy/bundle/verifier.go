@@ -0,0 +1,80 @@
+package bundle
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrInvalidSignature is returned when the signature of a bundle cannot be verified with the public key.
+var ErrInvalidSignature = errors.New("invalid bundle signature")
+
+// Verifier wraps the Verify method.
+// Verify returns an error unless the given base64 encoded signature of the given bundle tarball is valid.
+type Verifier interface {
+	Verify(tarball []byte, signature string) error
+}
+
+type verifier struct {
+	key crypto.PublicKey
+}
+
+// NewVerifier constructs a Verifier of the signatures made with the private key of the given PEM encoded ECDSA,
+// Ed25519 or RSA public key. ECDSA and RSA signatures are made over the SHA-256 digest of the bundle, like the
+// signatures of `cosign sign-blob`, while Ed25519 signatures are made over the bundle itself.
+func NewVerifier(publicKeyPEM []byte) (Verifier, error) {
+	block, _ := pem.Decode(publicKeyPEM)
+	if block == nil {
+		return nil, errors.New("decoding public key: no PEM block")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing public key: %w", err)
+	}
+	switch key.(type) {
+	case *ecdsa.PublicKey, ed25519.PublicKey, *rsa.PublicKey:
+		return &verifier{key: key}, nil
+	}
+	return nil, fmt.Errorf("unsupported public key type: %T", key)
+}
+
+// LoadVerifier constructs a Verifier of the signatures made with the private key of the public key held by the
+// given PEM file.
+func LoadVerifier(file string) (Verifier, error) {
+	publicKeyPEM, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading public key: %w", err)
+	}
+	return NewVerifier(publicKeyPEM)
+}
+
+func (v *verifier) Verify(tarball []byte, signature string) error {
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(signature))
+	if err != nil {
+		return fmt.Errorf("%w: decoding signature: %v", ErrInvalidSignature, err)
+	}
+
+	sum := sha256.Sum256(tarball)
+	var valid bool
+	switch key := v.key.(type) {
+	case *ecdsa.PublicKey:
+		valid = ecdsa.VerifyASN1(key, sum[:], sig)
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(key, tarball, sig)
+	case *rsa.PublicKey:
+		valid = rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], sig) == nil
+	}
+	if !valid {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package bundle
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSigner returns the PEM encoded public key of a new ECDSA P-256 key, and a function signing bundles with it.
+func newSigner(t *testing.T) ([]byte, func(tarball []byte) string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return publicKeyPEM(t, key.Public()), func(tarball []byte) string {
+		sum := sha256.Sum256(tarball)
+		sig, err := ecdsa.SignASN1(rand.Reader, key, sum[:])
+		require.NoError(t, err)
+		return base64.StdEncoding.EncodeToString(sig)
+	}
+}
+
+func publicKeyPEM(t *testing.T, key crypto.PublicKey) []byte {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func TestVerifier(t *testing.T) {
+	tarball := []byte("bundle")
+	sum := sha256.Sum256(tarball)
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	ecdsaSig, err := ecdsa.SignASN1(rand.Reader, ecdsaKey, sum[:])
+	require.NoError(t, err)
+
+	ed25519Public, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaSig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, sum[:])
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name      string
+		publicKey crypto.PublicKey
+		signature []byte
+	}{
+		{
+			name:      "Should verify ECDSA signature",
+			publicKey: ecdsaKey.Public(),
+			signature: ecdsaSig,
+		},
+		{
+			name:      "Should verify Ed25519 signature",
+			publicKey: ed25519Public,
+			signature: ed25519.Sign(ed25519Key, tarball),
+		},
+		{
+			name:      "Should verify RSA signature",
+			publicKey: rsaKey.Public(),
+			signature: rsaSig,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			verifier, err := NewVerifier(publicKeyPEM(t, tc.publicKey))
+			require.NoError(t, err)
+
+			assert.NoError(t, verifier.Verify(tarball, base64.StdEncoding.EncodeToString(tc.signature)+"\n"))
+			assert.ErrorIs(t, verifier.Verify([]byte("tampered bundle"), base64.StdEncoding.EncodeToString(tc.signature)),
+				ErrInvalidSignature)
+		})
+	}
+
+	t.Run("Should return error when signature is not base64 encoded", func(t *testing.T) {
+		verifier, err := NewVerifier(publicKeyPEM(t, ecdsaKey.Public()))
+		require.NoError(t, err)
+
+		assert.ErrorIs(t, verifier.Verify(tarball, "not base64!"), ErrInvalidSignature)
+	})
+
+	t.Run("Should return error when public key is not PEM encoded", func(t *testing.T) {
+		_, err := NewVerifier([]byte("s3cret"))
+		assert.EqualError(t, err, "decoding public key: no PEM block")
+	})
+}
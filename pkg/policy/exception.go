@@ -0,0 +1,66 @@
+package policy
+
+import (
+	"slices"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+)
+
+// ExceptionStatus is the status of an Exception.
+type ExceptionStatus string
+
+const (
+	// ExceptionRequested is the status of an Exception waiting for the approval of an admin.
+	ExceptionRequested ExceptionStatus = "requested"
+	// ExceptionApproved is the status of an Exception honored by verdicts until it expires.
+	ExceptionApproved ExceptionStatus = "approved"
+)
+
+// Exception temporarily exempts the artifacts of a repository from the violations of the policy by a
+// vulnerability. It covers any artifact of the repository unless Digest is set.
+type Exception struct {
+	ID              string          `json:"id"`
+	Repository      string          `json:"repository"`
+	Digest          string          `json:"digest,omitempty"`
+	VulnerabilityID string          `json:"vulnerability_id"`
+	Justification   string          `json:"justification"`
+	ExpiresAt       time.Time       `json:"expires_at"`
+	Status          ExceptionStatus `json:"status"`
+	RequestedBy     string          `json:"requested_by"`
+	RequestedAt     time.Time       `json:"requested_at"`
+	ApprovedBy      string          `json:"approved_by,omitempty"`
+	ApprovedAt      *time.Time      `json:"approved_at,omitempty"`
+}
+
+// IsActive returns true if the exception is approved and not expired at the given time.
+func (e Exception) IsActive(now time.Time) bool {
+	return e.Status == ExceptionApproved && now.Before(e.ExpiresAt)
+}
+
+// Covers returns true if the exception applies to the given vulnerability of the given artifact.
+func (e Exception) Covers(artifact harbor.Artifact, vulnerabilityID string) bool {
+	return e.VulnerabilityID == vulnerabilityID && e.Repository == artifact.Repository &&
+		(e.Digest == "" || e.Digest == artifact.Digest)
+}
+
+// ApplyExceptions returns a copy of the given verdict on the given artifact, whose violations covered by the
+// exceptions active at the given time are moved to its excepted findings. The verdict passes if no violation
+// remains.
+func ApplyExceptions(verdict Verdict, artifact harbor.Artifact, exceptions []Exception, now time.Time) Verdict {
+	violations := make([]Finding, 0, len(verdict.Violations))
+	for _, finding := range verdict.Violations {
+		i := slices.IndexFunc(exceptions, func(e Exception) bool {
+			return e.IsActive(now) && e.Covers(artifact, finding.ID)
+		})
+		if i < 0 {
+			violations = append(violations, finding)
+			continue
+		}
+		finding.ExceptionID = exceptions[i].ID
+		verdict.Excepted = append(verdict.Excepted, finding)
+	}
+	verdict.Violations = violations
+	verdict.Passed = len(violations) == 0
+	return verdict
+}
@@ -0,0 +1,81 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyExceptions(t *testing.T) {
+	now := time.Date(2024, 3, 15, 9, 30, 0, 0, time.UTC)
+	artifact := harbor.Artifact{Repository: "storefront-web/nginx", Digest: "sha256:6c3c"}
+	violations := []Finding{
+		{ID: "CVE-2019-1549", Package: "openssl", Severity: harbor.SevHigh, WeightedSeverity: harbor.SevCritical},
+		{ID: "CVE-2019-1563", Package: "openssl", Severity: harbor.SevHigh, WeightedSeverity: harbor.SevCritical},
+	}
+
+	testCases := []struct {
+		name            string
+		exceptions      []Exception
+		expectedVerdict Verdict
+	}{
+		{
+			name: "Should pass when all violations are excepted",
+			exceptions: []Exception{
+				{ID: "e1", Repository: "storefront-web/nginx", VulnerabilityID: "CVE-2019-1549",
+					Status: ExceptionApproved, ExpiresAt: now.Add(time.Hour)},
+				{ID: "e2", Repository: "storefront-web/nginx", Digest: "sha256:6c3c", VulnerabilityID: "CVE-2019-1563",
+					Status: ExceptionApproved, ExpiresAt: now.Add(time.Hour)},
+			},
+			expectedVerdict: Verdict{
+				Passed:     true,
+				Violations: []Finding{},
+				Excepted: []Finding{
+					{ID: "CVE-2019-1549", Package: "openssl", Severity: harbor.SevHigh, WeightedSeverity: harbor.SevCritical,
+						ExceptionID: "e1"},
+					{ID: "CVE-2019-1563", Package: "openssl", Severity: harbor.SevHigh, WeightedSeverity: harbor.SevCritical,
+						ExceptionID: "e2"},
+				},
+			},
+		},
+		{
+			name: "Should fail when some violations are not excepted",
+			exceptions: []Exception{
+				{ID: "e1", Repository: "storefront-web/nginx", VulnerabilityID: "CVE-2019-1549",
+					Status: ExceptionApproved, ExpiresAt: now.Add(time.Hour)},
+			},
+			expectedVerdict: Verdict{
+				Violations: violations[1:],
+				Excepted: []Finding{
+					{ID: "CVE-2019-1549", Package: "openssl", Severity: harbor.SevHigh, WeightedSeverity: harbor.SevCritical,
+						ExceptionID: "e1"},
+				},
+			},
+		},
+		{
+			name: "Should ignore requested, expired and unrelated exceptions",
+			exceptions: []Exception{
+				{ID: "e1", Repository: "storefront-web/nginx", VulnerabilityID: "CVE-2019-1549",
+					Status: ExceptionRequested, ExpiresAt: now.Add(time.Hour)},
+				{ID: "e2", Repository: "storefront-web/nginx", VulnerabilityID: "CVE-2019-1549",
+					Status: ExceptionApproved, ExpiresAt: now},
+				{ID: "e3", Repository: "storefront-web/redis", VulnerabilityID: "CVE-2019-1549",
+					Status: ExceptionApproved, ExpiresAt: now.Add(time.Hour)},
+				{ID: "e4", Repository: "storefront-web/nginx", Digest: "sha256:0e6c", VulnerabilityID: "CVE-2019-1563",
+					Status: ExceptionApproved, ExpiresAt: now.Add(time.Hour)},
+			},
+			expectedVerdict: Verdict{
+				Violations: violations,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			verdict := ApplyExceptions(Verdict{Violations: violations}, artifact, tc.exceptions, now)
+			assert.Equal(t, tc.expectedVerdict, verdict)
+		})
+	}
+}
@@ -0,0 +1,217 @@
+// Package policy evaluates scan reports against a risk-based policy, which weights the severities of
+// vulnerabilities by the context of the project an artifact belongs to.
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+)
+
+// Policy is the policy configuration file.
+//
+// Projects assign context tags, e.g. exposure=internet, to the Harbor projects matching the given name
+// pattern. SeverityWeights raise or lower the severity of vulnerabilities found in artifacts of projects
+// tagged with the given tag by the given number of levels. An artifact fails the policy if any
+// vulnerability has a weighted severity of at least FailOn.
+type Policy struct {
+	FailOn          string         `json:"fail_on"`
+	Projects        []Project      `json:"projects"`
+	SeverityWeights map[string]int `json:"severity_weights"`
+}
+
+// Project assigns context tags to the Harbor projects whose names match the Name pattern, as defined
+// by path.Match. ScanTimeout, e.g. 30m, extends the timeout of scanning the artifacts of the projects.
+type Project struct {
+	Name        string   `json:"name"`
+	Tags        []string `json:"tags"`
+	ScanTimeout string   `json:"scan_timeout,omitempty"`
+}
+
+// Load reads and validates the policy from the given JSON file.
+func Load(file string) (Policy, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return Policy{}, fmt.Errorf("reading policy: %w", err)
+	}
+	defer f.Close()
+
+	return Decode(f)
+}
+
+// Decode reads and validates the policy from the given JSON.
+func Decode(r io.Reader) (Policy, error) {
+	var p Policy
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&p); err != nil {
+		return Policy{}, fmt.Errorf("decoding policy: %w", err)
+	}
+
+	if err := p.Validate(); err != nil {
+		return Policy{}, err
+	}
+	return p, nil
+}
+
+// Validate returns an error if the policy is invalid.
+func (p Policy) Validate() error {
+	if _, err := harbor.ParseSeverity(p.FailOn); err != nil {
+		return fmt.Errorf("policy fail_on: %w", err)
+	}
+	for _, project := range p.Projects {
+		if _, err := path.Match(project.Name, ""); err != nil {
+			return fmt.Errorf("policy project name %q: %w", project.Name, err)
+		}
+		for _, tag := range project.Tags {
+			if !strings.Contains(tag, "=") {
+				return fmt.Errorf("policy project %q: tag %q must be in key=value form", project.Name, tag)
+			}
+		}
+		if project.ScanTimeout != "" {
+			if timeout, err := time.ParseDuration(project.ScanTimeout); err != nil || timeout <= 0 {
+				return fmt.Errorf("policy project %q: scan timeout %q must be a positive duration", project.Name, project.ScanTimeout)
+			}
+		}
+	}
+	for tag := range p.SeverityWeights {
+		if !strings.Contains(tag, "=") {
+			return fmt.Errorf("policy severity weight: tag %q must be in key=value form", tag)
+		}
+	}
+	return nil
+}
+
+// Finding is a vulnerability violating the policy.
+type Finding struct {
+	ID       string          `json:"id"`
+	Package  string          `json:"package"`
+	Version  string          `json:"version"`
+	Severity harbor.Severity `json:"severity"`
+	// WeightedSeverity is the severity of the vulnerability in the context of the artifact's project.
+	WeightedSeverity harbor.Severity `json:"weighted_severity"`
+	// ExceptionID is the ID of the Exception exempting the artifact from the violation, if any.
+	ExceptionID string `json:"exception_id,omitempty"`
+}
+
+// Verdict is the outcome of evaluating a scan report against the policy.
+type Verdict struct {
+	Passed     bool            `json:"passed"`
+	Project    string          `json:"project"`
+	Tags       []string        `json:"tags"`
+	FailOn     harbor.Severity `json:"fail_on"`
+	Violations []Finding       `json:"violations"`
+	// Excepted are the violations exempted by approved exceptions, which no longer fail the verdict.
+	Excepted []Finding `json:"excepted,omitempty"`
+}
+
+// Engine evaluates scan reports against the policy.
+type Engine interface {
+	// Evaluate returns the verdict of the policy on the given scan report.
+	Evaluate(report harbor.ScanReport) Verdict
+	// ScanTimeout returns the longest scan timeout of the project entries matching the project of the given
+	// repository, or zero if none of them extends the timeout.
+	ScanTimeout(repository string) time.Duration
+}
+
+type engine struct {
+	policy Policy
+	failOn harbor.Severity
+}
+
+// NewEngine constructs an Engine evaluating scan reports against the given Policy, which must be valid.
+func NewEngine(policy Policy) (Engine, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	failOn, _ := harbor.ParseSeverity(policy.FailOn)
+	return &engine{policy: policy, failOn: failOn}, nil
+}
+
+func (e *engine) Evaluate(report harbor.ScanReport) Verdict {
+	project := ProjectOf(report.Artifact.Repository)
+	tags := e.tagsOf(project)
+
+	weight := 0
+	for _, tag := range tags {
+		weight += e.policy.SeverityWeights[tag]
+	}
+
+	verdict := Verdict{
+		Passed:     true,
+		Project:    project,
+		Tags:       tags,
+		FailOn:     e.failOn,
+		Violations: []Finding{},
+	}
+	for _, v := range report.Vulnerabilities {
+		// Suppressed vulnerabilities are kept in reports marked as such, but never violate the policy.
+		if harbor.IsSuppressed(v) {
+			continue
+		}
+		weighted := Weigh(v.Severity, weight)
+		if weighted < e.failOn {
+			continue
+		}
+		verdict.Passed = false
+		verdict.Violations = append(verdict.Violations, Finding{
+			ID:               v.ID,
+			Package:          v.Pkg,
+			Version:          v.Version,
+			Severity:         v.Severity,
+			WeightedSeverity: weighted,
+		})
+	}
+	return verdict
+}
+
+func (e *engine) ScanTimeout(repository string) time.Duration {
+	var timeout time.Duration
+	for _, p := range e.policy.Projects {
+		if matched, _ := path.Match(p.Name, ProjectOf(repository)); !matched || p.ScanTimeout == "" {
+			continue
+		}
+		d, _ := time.ParseDuration(p.ScanTimeout)
+		timeout = max(timeout, d)
+	}
+	return timeout
+}
+
+// tagsOf returns the sorted tags of all project entries matching the given project.
+func (e *engine) tagsOf(project string) []string {
+	tags := []string{}
+	for _, p := range e.policy.Projects {
+		if matched, _ := path.Match(p.Name, project); !matched {
+			continue
+		}
+		for _, tag := range p.Tags {
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	slices.Sort(tags)
+	return tags
+}
+
+// Weigh raises or lowers the given severity by the given number of levels, without going beyond Low
+// and Critical. Unknown severities are not weighted, as their actual level cannot be told.
+func Weigh(severity harbor.Severity, weight int) harbor.Severity {
+	if severity < harbor.SevLow {
+		return severity
+	}
+	return min(max(severity+harbor.Severity(weight), harbor.SevLow), harbor.SevCritical)
+}
+
+// ProjectOf returns the Harbor project of the given repository, i.e. its first path component.
+func ProjectOf(repository string) string {
+	project, _, _ := strings.Cut(repository, "/")
+	return project
+}
@@ -0,0 +1,172 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	testCases := []struct {
+		name           string
+		content        string
+		expectedPolicy Policy
+		expectedError  string
+	}{
+		{
+			name: "Should load policy",
+			content: `{
+  "fail_on": "high",
+  "projects": [{"name": "storefront-*", "tags": ["exposure=internet"]}],
+  "severity_weights": {"exposure=internet": 1}
+}`,
+			expectedPolicy: Policy{
+				FailOn:          "high",
+				Projects:        []Project{{Name: "storefront-*", Tags: []string{"exposure=internet"}}},
+				SeverityWeights: map[string]int{"exposure=internet": 1},
+			},
+		},
+		{
+			name:          "Should return error when fail_on is unknown",
+			content:       `{"fail_on": "severe"}`,
+			expectedError: "policy fail_on: unknown severity: severe",
+		},
+		{
+			name:          "Should return error when tag is not a key=value pair",
+			content:       `{"fail_on": "High", "projects": [{"name": "library", "tags": ["internet"]}]}`,
+			expectedError: `policy project "library": tag "internet" must be in key=value form`,
+		},
+		{
+			name:          "Should return error when scan timeout is not a duration",
+			content:       `{"fail_on": "High", "projects": [{"name": "ml-*", "scan_timeout": "1 hour"}]}`,
+			expectedError: `policy project "ml-*": scan timeout "1 hour" must be a positive duration`,
+		},
+		{
+			name:          "Should return error when field is unknown",
+			content:       `{"fail_on": "High", "fail_above": "Low"}`,
+			expectedError: `decoding policy: json: unknown field "fail_above"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "policy.json")
+			require.NoError(t, os.WriteFile(file, []byte(tc.content), 0600))
+
+			p, err := Load(file)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedPolicy, p)
+		})
+	}
+}
+
+func TestEngine_Evaluate(t *testing.T) {
+	engine, err := NewEngine(Policy{
+		FailOn: "High",
+		Projects: []Project{
+			{Name: "storefront", Tags: []string{"exposure=internet"}},
+			{Name: "batch-*", Tags: []string{"exposure=internal"}},
+			{Name: "*", Tags: []string{"tier=production"}},
+		},
+		SeverityWeights: map[string]int{
+			"exposure=internet": 1,
+			"exposure=internal": -1,
+		},
+	})
+	require.NoError(t, err)
+
+	vulnerabilities := []harbor.VulnerabilityItem{
+		{ID: "CVE-0000-0001", Pkg: "openssl", Version: "1.1.1", Severity: harbor.SevMedium},
+		{ID: "CVE-0000-0002", Pkg: "zlib", Version: "1.2.11", Severity: harbor.SevHigh},
+		{ID: "CVE-0000-0003", Pkg: "musl", Version: "1.1.22", Severity: harbor.SevUnknown},
+		// Allowlisted vulnerabilities never violate the policy.
+		{ID: "CVE-0000-0004", Pkg: "curl", Version: "7.64.0", Severity: harbor.SevCritical,
+			VendorAttributes: map[string]interface{}{harbor.VendorAttributeAllowlisted: true}},
+	}
+
+	testCases := []struct {
+		name            string
+		repository      string
+		expectedVerdict Verdict
+	}{
+		{
+			name:       "Should raise severities of internet-facing project",
+			repository: "storefront/web",
+			expectedVerdict: Verdict{
+				Passed:  false,
+				Project: "storefront",
+				Tags:    []string{"exposure=internet", "tier=production"},
+				FailOn:  harbor.SevHigh,
+				Violations: []Finding{
+					{ID: "CVE-0000-0001", Package: "openssl", Version: "1.1.1", Severity: harbor.SevMedium, WeightedSeverity: harbor.SevHigh},
+					{ID: "CVE-0000-0002", Package: "zlib", Version: "1.2.11", Severity: harbor.SevHigh, WeightedSeverity: harbor.SevCritical},
+				},
+			},
+		},
+		{
+			name:       "Should lower severities of internal project",
+			repository: "batch-jobs/etl",
+			expectedVerdict: Verdict{
+				Passed:     true,
+				Project:    "batch-jobs",
+				Tags:       []string{"exposure=internal", "tier=production"},
+				FailOn:     harbor.SevHigh,
+				Violations: []Finding{},
+			},
+		},
+		{
+			name:       "Should not weight severities of project without weighted tags",
+			repository: "library/mongo",
+			expectedVerdict: Verdict{
+				Passed:  false,
+				Project: "library",
+				Tags:    []string{"tier=production"},
+				FailOn:  harbor.SevHigh,
+				Violations: []Finding{
+					{ID: "CVE-0000-0002", Package: "zlib", Version: "1.2.11", Severity: harbor.SevHigh, WeightedSeverity: harbor.SevHigh},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			verdict := engine.Evaluate(harbor.ScanReport{
+				Artifact:        harbor.Artifact{Repository: tc.repository},
+				Vulnerabilities: vulnerabilities,
+			})
+			assert.Equal(t, tc.expectedVerdict, verdict)
+		})
+	}
+}
+
+func TestEngine_ScanTimeout(t *testing.T) {
+	engine, err := NewEngine(Policy{
+		FailOn: "High",
+		Projects: []Project{
+			{Name: "ml-*", ScanTimeout: "20m"},
+			{Name: "ml-models", ScanTimeout: "45m"},
+			{Name: "*", Tags: []string{"tier=production"}},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 45*time.Minute, engine.ScanTimeout("ml-models/llama"))
+	assert.Equal(t, 20*time.Minute, engine.ScanTimeout("ml-datasets/wiki"))
+	assert.Equal(t, time.Duration(0), engine.ScanTimeout("library/mongo"))
+}
+
+func TestWeigh(t *testing.T) {
+	assert.Equal(t, harbor.SevCritical, Weigh(harbor.SevHigh, 3))
+	assert.Equal(t, harbor.SevLow, Weigh(harbor.SevMedium, -2))
+	assert.Equal(t, harbor.SevUnknown, Weigh(harbor.SevUnknown, 2))
+}
@@ -0,0 +1,93 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/xerrors"
+)
+
+// broker carries the published scan jobs to the workers, and tracks the backlog and the running scan jobs the
+// estimates of the Enqueuer are based on.
+type broker interface {
+	// publish adds the given job to the backlog as of the given time, and publishes it to the workers.
+	publish(ctx context.Context, j Job, now time.Time) error
+	// rank returns the zero-based rank of the given scan job in the backlog, and false if it is not in the backlog.
+	rank(ctx context.Context, scanJobID string) (int64, bool, error)
+	// backlog returns the number of scan jobs in the backlog.
+	backlog(ctx context.Context) (int64, error)
+	// started returns the times the running scan jobs were started at.
+	started(ctx context.Context) ([]time.Time, error)
+	// durations returns the most recent scan durations in milliseconds.
+	durations(ctx context.Context) ([]string, error)
+	// trim removes the scan jobs which entered the backlog, or were started, before the given time.
+	trim(ctx context.Context, before time.Time) error
+}
+
+// redisBroker publishes scan jobs to the workers of all replicas subscribed to the job channel of its namespace.
+type redisBroker struct {
+	rdb       redis.UniversalClient
+	namespace string
+	envelope  string
+	version   int
+}
+
+func (b *redisBroker) publish(ctx context.Context, j Job, now time.Time) error {
+	return publish(ctx, b.rdb, b.namespace, b.envelope, b.version, j, now)
+}
+
+func (b *redisBroker) rank(ctx context.Context, scanJobID string) (int64, bool, error) {
+	rank, err := b.rdb.ZRank(ctx, redisBacklogKey(b.namespace), scanJobID).Result()
+	if errors.Is(err, redis.Nil) {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, xerrors.Errorf("getting backlog rank: %w", err)
+	}
+	return rank, true, nil
+}
+
+func (b *redisBroker) backlog(ctx context.Context) (int64, error) {
+	backlog, err := b.rdb.ZCard(ctx, redisBacklogKey(b.namespace)).Result()
+	if err != nil {
+		return 0, xerrors.Errorf("counting backlog: %w", err)
+	}
+	return backlog, nil
+}
+
+func (b *redisBroker) started(ctx context.Context) ([]time.Time, error) {
+	started, err := b.rdb.ZRangeWithScores(ctx, redisStartedKey(b.namespace), 0, -1).Result()
+	if err != nil {
+		return nil, xerrors.Errorf("getting running scan jobs: %w", err)
+	}
+	times := make([]time.Time, len(started))
+	for i, z := range started {
+		times[i] = time.UnixMilli(int64(z.Score))
+	}
+	return times, nil
+}
+
+func (b *redisBroker) durations(ctx context.Context) ([]string, error) {
+	samples, err := b.rdb.LRange(ctx, redisDurationsKey(b.namespace), 0, durationSamples-1).Result()
+	if err != nil {
+		return nil, xerrors.Errorf("getting scan durations: %w", err)
+	}
+	return samples, nil
+}
+
+// trim removes the scan jobs lost from the backlog, e.g. by workers killed before picking them up, and the running
+// scan jobs of workers killed before completing them.
+func (b *redisBroker) trim(ctx context.Context, before time.Time) error {
+	minScore := strconv.FormatInt(before.UnixMilli(), 10)
+	_, err := b.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(ctx, redisBacklogKey(b.namespace), "-inf", "("+minScore)
+		pipe.ZRemRangeByScore(ctx, redisStartedKey(b.namespace), "-inf", "("+minScore)
+		return nil
+	})
+	if err != nil {
+		return xerrors.Errorf("trimming backlog: %w", err)
+	}
+	return nil
+}
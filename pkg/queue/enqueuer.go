@@ -2,99 +2,492 @@ package queue
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/json"
-	"fmt"
-	"io"
+	"errors"
 	"log/slog"
+	"slices"
+	"strconv"
+	"time"
 
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/xerrors"
 
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/clock"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/log"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/metrics"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tracing"
 )
 
 const scanArtifactJobName = "scan_artifact"
 
+// backlogMaxAge bounds how long a scan job is counted in the backlog. Jobs whose messages were never
+// consumed, e.g. because no worker was subscribed at the time, are dropped from the estimate afterwards.
+const backlogMaxAge = time.Hour
+
+// durationSamples is the number of recent scan durations used to estimate wait times.
+const durationSamples = 100
+
+// ErrBacklogFull is returned by Enqueue when the backlog holds the configured maximum number of scan jobs.
+var ErrBacklogFull = errors.New("scan job backlog is full")
+
 type Enqueuer interface {
 	Enqueue(ctx context.Context, request harbor.ScanRequest) (job.ScanJob, error)
+	// Position returns the estimated position of the given scan job in the backlog, and the estimated
+	// time it will wait before a worker picks it up.
+	Position(ctx context.Context, scanJobID string) (job.QueuePosition, error)
+	// Stats returns the number of scan jobs in the backlog and the average of the most recent scan durations.
+	Stats(ctx context.Context) (job.QueueStats, error)
 }
 
 type enqueuer struct {
-	namespace string
-	rdb       *redis.Client
-	store     persistence.Store
+	concurrency int
+	// maxBacklog is the number of scan jobs in the backlog beyond which scan requests are rejected, zero if there
+	// is no limit.
+	maxBacklog  int
+	broker      broker
+	store       persistence.Store
+	idGenerator job.IDGenerator
+	// digests finds the recently completed scan jobs of the same digest, nil if every request is scanned.
+	digests persistence.DigestIndex
+	// failures finds the digests which failed permanently and repeatedly, nil if every request is scanned.
+	failures persistence.FailureIndex
+	// clock times the scan jobs entering the backlog, whose age bounds the backlog.
+	clock clock.Clock
+}
+
+// EnqueuerOption configures optional behaviors of the Enqueuer.
+type EnqueuerOption func(*enqueuer)
+
+// WithDigestIndex serves the report of a recently completed scan job of the same digest, found in the given
+// DigestIndex, to requests to scan an artifact rather than rescanning it, unless a fresh scan is forced.
+func WithDigestIndex(digests persistence.DigestIndex) EnqueuerOption {
+	return func(e *enqueuer) {
+		e.digests = digests
+	}
+}
+
+// WithFailureIndex fails requests to scan an artifact whose digest failed permanently and repeatedly, as found in
+// the given FailureIndex, with the error of its last failure rather than scanning it, unless a fresh scan is forced.
+func WithFailureIndex(failures persistence.FailureIndex) EnqueuerOption {
+	return func(e *enqueuer) {
+		e.failures = failures
+	}
+}
+
+// WithClock times the scan jobs entering the backlog with the given Clock rather than the system time.
+func WithClock(c clock.Clock) EnqueuerOption {
+	return func(e *enqueuer) {
+		e.clock = c
+	}
+}
+
+type forceScanKey struct{}
+
+// WithForceScan returns a copy of the context forcing Enqueue to scan the artifact even if a report of the same
+// digest could be reused.
+func WithForceScan(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceScanKey{}, true)
+}
+
+// ForceScanFromContext returns true if the context forces a fresh scan.
+func ForceScanFromContext(ctx context.Context) bool {
+	force, _ := ctx.Value(forceScanKey{}).(bool)
+	return force
 }
 
 type Job struct {
 	Name string
 	ID   string
 	Args Args
+	// TraceContext is the trace context of the enqueued scan job, which is also kept with the scan job in the store
+	// for the envelopes carrying the scan job ID only.
+	TraceContext map[string]string `json:",omitempty"`
 }
 
 type Args struct {
 	ScanRequest *harbor.ScanRequest `json:",omitempty"`
 }
 
-func NewEnqueuer(config etc.JobQueue, rdb *redis.Client, store persistence.Store) Enqueuer {
-	return &enqueuer{
-		namespace: config.Namespace,
+func NewEnqueuer(config etc.JobQueue, rdb redis.UniversalClient, store persistence.Store, idGenerator job.IDGenerator, opts ...EnqueuerOption) Enqueuer {
+	return newEnqueuer(config, &redisBroker{
 		rdb:       rdb,
-		store:     store,
+		namespace: config.Namespace,
+		envelope:  config.Envelope,
+		version:   config.EnvelopeVersion,
+	}, store, idGenerator, opts...)
+}
+
+func newEnqueuer(config etc.JobQueue, b broker, store persistence.Store, idGenerator job.IDGenerator, opts ...EnqueuerOption) Enqueuer {
+	e := &enqueuer{
+		concurrency: config.WorkerConcurrency,
+		maxBacklog:  config.MaxBacklog,
+		broker:      b,
+		store:       store,
+		idGenerator: idGenerator,
+		clock:       clock.System,
+	}
+	for _, opt := range opts {
+		opt(e)
 	}
+	return e
 }
 
-func (e *enqueuer) Enqueue(ctx context.Context, request harbor.ScanRequest) (job.ScanJob, error) {
-	slog.Debug("Enqueueing scan job")
+func (e *enqueuer) Enqueue(ctx context.Context, request harbor.ScanRequest) (_ job.ScanJob, err error) {
+	// The scan job is traced as a child of the span of the enqueuing, which ends once the job is published.
+	spanCtx, span := tracing.Start(ctx, "Enqueue", trace.WithSpanKind(trace.SpanKindProducer))
+	defer func() { tracing.End(span, err) }()
+
+	slog.DebugContext(ctx, "Enqueueing scan job")
+	id, err := e.idGenerator.NewID(request)
+	if err != nil {
+		return job.ScanJob{}, xerrors.Errorf("generating scan job ID: %w", err)
+	}
+	span.SetAttributes(tracing.ScanJobID(id))
+	ctx = log.WithDigest(log.WithScanJobID(ctx, id), request.Artifact.Digest)
+
 	j := Job{
 		Name: scanArtifactJobName,
-		ID:   makeIdentifier(),
+		ID:   id,
 		Args: Args{
 			ScanRequest: &request,
 		},
+		TraceContext: tracing.Inject(spanCtx),
+	}
+
+	if e.failures != nil && !ForceScanFromContext(ctx) {
+		if scanJob, failed := e.failFast(ctx, id, request); failed {
+			metrics.ScanJobsEnqueued.WithLabelValues(metrics.ResultFailedFast).Inc()
+			return scanJob, nil
+		}
+	}
+
+	if e.digests != nil && !ForceScanFromContext(ctx) {
+		if scanJob, reused := e.reuseReport(ctx, id, request); reused {
+			metrics.ScanJobsEnqueued.WithLabelValues(metrics.ResultReused).Inc()
+			return scanJob, nil
+		}
+	}
+
+	// Requests served the report of a recent scan, or failed fast, are never rejected.
+	if e.maxBacklog > 0 {
+		if err = e.checkBacklog(ctx); err != nil {
+			if errors.Is(err, ErrBacklogFull) {
+				metrics.ScanJobsEnqueued.WithLabelValues(metrics.ResultRejected).Inc()
+			}
+			return job.ScanJob{}, err
+		}
 	}
 
 	scanJob := job.ScanJob{
-		ID:     j.ID,
-		Status: job.Queued,
+		ID:           j.ID,
+		Status:       job.Queued,
+		Request:      &request,
+		TraceContext: j.TraceContext,
+	}
+
+	// Persist the scan job before publishing it, so that workers, the reaper and recovery find its request in the store.
+	if err = e.store.Create(ctx, scanJob); err != nil {
+		if !errors.Is(err, persistence.ErrScanJobExists) {
+			return job.ScanJob{}, xerrors.Errorf("creating scan job %v", err)
+		}
+		var existing job.ScanJob
+		var reused bool
+		if existing, reused, err = e.reuse(ctx, scanJob); err != nil || reused {
+			return existing, err
+		}
+	}
+
+	if err = e.broker.publish(ctx, j, e.clock.Now()); err != nil {
+		return job.ScanJob{}, err
+	}
+
+	if e.digests != nil {
+		if err = e.digests.Record(ctx, request.Artifact.Digest, j.ID); err != nil {
+			slog.WarnContext(ctx, "Error while recording scan job of digest", slog.String("err", err.Error()))
+		}
+	}
+
+	slog.DebugContext(ctx, "Successfully enqueued scan job")
+	metrics.ScanJobsEnqueued.WithLabelValues(metrics.ResultQueued).Inc()
+
+	return scanJob, nil
+}
+
+// reuse handles a scan job whose ID is taken by an existing scan job, e.g. because the digest ID generator maps
+// repeated requests to scan the same artifact to the same scan job. A queued or pending scan job of the same
+// artifact is reused as is, while a finished or failed one is replaced by the given queued scan job, whose request
+// and trace context are the ones published, so that the artifact is rescanned.
+// Otherwise, an error wrapping persistence.ErrScanJobExists is returned rather than overwriting another scan job.
+func (e *enqueuer) reuse(ctx context.Context, scanJob job.ScanJob) (job.ScanJob, bool, error) {
+	existing, err := e.store.Get(ctx, scanJob.ID)
+	if err != nil {
+		return job.ScanJob{}, false, xerrors.Errorf("getting existing scan job: %v", err)
+	}
+	if existing == nil || !sameArtifact(existing.Request, scanJob.Request) {
+		return job.ScanJob{}, false, xerrors.Errorf("creating scan job %s: %w", scanJob.ID, persistence.ErrScanJobExists)
 	}
 
-	// Save the job status to Redis
-	if err := e.store.Create(ctx, scanJob); err != nil {
-		return job.ScanJob{}, xerrors.Errorf("creating scan job %v", err)
+	switch existing.Status {
+	case job.Queued, job.Pending:
+		slog.InfoContext(ctx, "Reusing existing scan job of the same artifact",
+			slog.String("scan_job_status", existing.Status.String()))
+		return *existing, true, nil
+	default:
+		slog.InfoContext(ctx, "Queueing existing scan job of the same artifact again",
+			slog.String("scan_job_status", existing.Status.String()))
+		if err = e.store.Replace(ctx, scanJob); err != nil {
+			return job.ScanJob{}, false, xerrors.Errorf("replacing existing scan job: %v", err)
+		}
+		return scanJob, false, nil
 	}
+}
 
-	b, err := json.Marshal(j)
+// reuseReport serves the report of the recently completed scan job of the same digest, if any, with the
+// coordinates of the requested artifact, under the scan job with the given ID. Scan jobs which failed, or whose
+// reports are partial, are not reused. Errors are logged rather than returned, since the artifact can always be
+// scanned instead.
+func (e *enqueuer) reuseReport(ctx context.Context, id string, request harbor.ScanRequest) (job.ScanJob, bool) {
+	reportJobID, err := e.digests.Find(ctx, request.Artifact.Digest)
 	if err != nil {
-		return job.ScanJob{}, xerrors.Errorf("marshalling scan request: %v", err)
+		slog.WarnContext(ctx, "Error while finding scan job of digest", slog.String("err", err.Error()))
+		return job.ScanJob{}, false
+	}
+	if reportJobID == "" {
+		return job.ScanJob{}, false
+	}
+
+	reportJob, err := e.store.Get(ctx, reportJobID)
+	if err != nil {
+		slog.WarnContext(ctx, "Error while getting scan job of digest", slog.String("err", err.Error()))
+		return job.ScanJob{}, false
+	}
+	if reportJob == nil || reportJob.Status != job.Finished || reportJob.Error != "" || reportJob.Report.Partial {
+		return job.ScanJob{}, false
+	}
+	// The scan job of the report may not have been requested for the same capabilities.
+	vulnerabilities := reportJob.Request == nil || reportJob.Request.HasCapability(harbor.CapabilityTypeVulnerability)
+	if request.HasCapability(harbor.CapabilityTypeVulnerability) && !vulnerabilities {
+		return job.ScanJob{}, false
+	}
+	if request.HasCapability(harbor.CapabilityTypeSBOM) && (reportJob.SBOM == nil || reportJob.SBOM.MediaType != request.SBOMMediaType()) {
+		return job.ScanJob{}, false
+	}
+
+	scanJob := job.ScanJob{ID: id, Status: job.Finished, Report: reportJob.Report, Request: &request}
+	artifact := request.Artifact
+	artifact.Platform = scanJob.Report.Artifact.Platform
+	scanJob.Report.Artifact = artifact
+	if reportJob.SBOM != nil {
+		sbom := *reportJob.SBOM
+		sbom.Artifact = request.Artifact
+		scanJob.SBOM = &sbom
+	}
+
+	if reportJobID == id {
+		// The digest ID generator maps the request to the scan job of the report.
+		err = e.store.UpdateReport(ctx, id, scanJob.Report)
+	} else {
+		err = e.store.Create(ctx, scanJob)
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Error while reusing report of digest", slog.String("err", err.Error()))
+		return job.ScanJob{}, false
+	}
+
+	slog.InfoContext(ctx, "Reusing report of recently scanned digest", slog.String("report_scan_job_id", reportJobID))
+	return scanJob, true
+}
+
+// failFast creates the scan job with the given ID as failed with the error of the last failure of the requested
+// digest, if it failed permanently and repeatedly within the cooldown period. Errors are logged rather than
+// returned, since the artifact can always be scanned instead. A scan job whose ID is taken, e.g. by a scan job of
+// the same artifact in progress, is left as is.
+func (e *enqueuer) failFast(ctx context.Context, id string, request harbor.ScanRequest) (job.ScanJob, bool) {
+	message, err := e.failures.Find(ctx, request.Artifact.Digest)
+	if err != nil {
+		slog.WarnContext(ctx, "Error while finding failure of digest", slog.String("err", err.Error()))
+		return job.ScanJob{}, false
+	}
+	if message == "" {
+		return job.ScanJob{}, false
+	}
+
+	scanJob := job.ScanJob{ID: id, Status: job.Failed, Error: message, Request: &request}
+	if err = e.store.Create(ctx, scanJob); err != nil {
+		if !errors.Is(err, persistence.ErrScanJobExists) {
+			slog.WarnContext(ctx, "Error while failing scan job of failed digest", slog.String("err", err.Error()))
+		}
+		return job.ScanJob{}, false
+	}
+
+	slog.InfoContext(ctx, "Failing scan job of digest which failed repeatedly", slog.String("err", message))
+	return scanJob, true
+}
+
+// sameArtifact returns true if the given scan requests are requests to scan the same artifact of the same registry
+// for the same capabilities and SBOM media type.
+func sameArtifact(a, b *harbor.ScanRequest) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	return a.Registry.URL == b.Registry.URL &&
+		a.Artifact.Repository == b.Artifact.Repository &&
+		a.Artifact.Digest == b.Artifact.Digest &&
+		a.CapabilitiesKey() == b.CapabilitiesKey()
+}
+
+// publish adds the given job to the backlog as of the given time, and publishes it to the workers in the given
+// envelope format and version.
+func publish(ctx context.Context, rdb redis.UniversalClient, namespace, envelope string, version int, j Job, now time.Time) error {
+	b, err := encode(envelope, version, j)
+	if err != nil {
+		return xerrors.Errorf("marshalling scan request: %v", err)
+	}
+
+	// Track the job in the backlog before publishing, so that a fast worker cannot remove it first.
+	if err = rdb.ZAdd(ctx, redisBacklogKey(namespace), redis.Z{
+		Score:  float64(now.UnixMilli()),
+		Member: j.ID,
+	}).Err(); err != nil {
+		return xerrors.Errorf("adding scan job to backlog: %v", err)
 	}
 
 	// Publish the job to the workers
-	if err = e.rdb.Publish(ctx, e.redisJobChannel(), b).Err(); err != nil {
-		return job.ScanJob{}, xerrors.Errorf("enqueuing scan artifact job: %v", err)
+	if err = rdb.Publish(ctx, redisJobChannel(namespace), b).Err(); err != nil {
+		return xerrors.Errorf("enqueuing scan artifact job: %v", err)
 	}
+	return nil
+}
 
-	slog.Debug("Successfully enqueued scan job", slog.String("job_id", j.ID))
+func (e *enqueuer) Position(ctx context.Context, scanJobID string) (job.QueuePosition, error) {
+	if err := e.trimBacklog(ctx); err != nil {
+		return job.QueuePosition{}, err
+	}
 
-	return scanJob, nil
+	rank, ok, err := e.broker.rank(ctx, scanJobID)
+	if err != nil || !ok {
+		return job.QueuePosition{}, err
+	}
+
+	samples, err := e.broker.durations(ctx)
+	if err != nil {
+		return job.QueuePosition{}, err
+	}
+
+	started, err := e.broker.started(ctx)
+	if err != nil {
+		return job.QueuePosition{}, err
+	}
+	now := e.clock.Now()
+	elapsed := make([]time.Duration, len(started))
+	for i, t := range started {
+		elapsed[i] = now.Sub(t)
+	}
+
+	position := job.QueuePosition{Position: int(rank) + 1}
+	position.EstimatedWait, position.Estimated = estimateWait(int(rank), max(e.concurrency, 1), elapsed, samples)
+	return position, nil
 }
 
-func (e *enqueuer) redisJobChannel() string {
-	return redisJobChannel(e.namespace)
+func (e *enqueuer) Stats(ctx context.Context) (job.QueueStats, error) {
+	if err := e.trimBacklog(ctx); err != nil {
+		return job.QueueStats{}, err
+	}
+
+	backlog, err := e.broker.backlog(ctx)
+	if err != nil {
+		return job.QueueStats{}, err
+	}
+
+	samples, err := e.broker.durations(ctx)
+	if err != nil {
+		return job.QueueStats{}, err
+	}
+
+	average, _ := averageDuration(samples)
+	return job.QueueStats{Backlog: backlog, AverageScanDuration: average}, nil
 }
 
-func makeIdentifier() string {
-	b := make([]byte, 12)
-	_, err := io.ReadFull(rand.Reader, b)
+// checkBacklog returns an error wrapping ErrBacklogFull if the backlog holds maxBacklog scan jobs or more.
+func (e *enqueuer) checkBacklog(ctx context.Context) error {
+	if err := e.trimBacklog(ctx); err != nil {
+		return err
+	}
+	backlog, err := e.broker.backlog(ctx)
 	if err != nil {
-		return ""
+		return err
 	}
-	return fmt.Sprintf("%x", b)
+	if backlog >= int64(e.maxBacklog) {
+		return xerrors.Errorf("%d scan jobs waiting for %d workers: %w", backlog, max(e.concurrency, 1), ErrBacklogFull)
+	}
+	return nil
+}
+
+// trimBacklog removes the scan jobs lost from the backlog, e.g. by workers killed before picking them up, and
+// the running scan jobs of workers killed before completing them.
+func (e *enqueuer) trimBacklog(ctx context.Context) error {
+	return e.broker.trim(ctx, e.clock.Now().Add(-backlogMaxAge))
+}
+
+// estimateWait estimates the time for workers to drain the given number of scan jobs ahead, after completing the
+// running scan jobs started the given elapsed times ago, based on the average of the recorded scan durations in
+// milliseconds.
+func estimateWait(ahead, concurrency int, elapsed []time.Duration, samples []string) (time.Duration, bool) {
+	average, ok := averageDuration(samples)
+	if !ok {
+		return 0, false
+	}
+
+	// free holds when each worker is expected to be free. A running scan job keeps its worker busy for the rest
+	// of the average duration, and one running longer than average may complete any moment.
+	free := make([]time.Duration, max(concurrency, len(elapsed)))
+	for i, e := range elapsed {
+		free[i] = max(average-e, 0)
+	}
+	// Each job ahead is picked up by the first worker to be free.
+	for i := 0; i < ahead; i++ {
+		next := slices.Index(free, slices.Min(free))
+		free[next] += average
+	}
+	return slices.Min(free), true
+}
+
+// averageDuration returns the average of the given scan durations in milliseconds, skipping malformed ones.
+func averageDuration(samples []string) (time.Duration, bool) {
+	var total, count int64
+	for _, sample := range samples {
+		ms, err := strconv.ParseInt(sample, 10, 64)
+		if err != nil {
+			continue
+		}
+		total += ms
+		count++
+	}
+	if count == 0 {
+		return 0, false
+	}
+	return time.Duration(total/count) * time.Millisecond, true
 }
 
 func redisJobChannel(namespace string) string {
 	return namespace + "jobs:" + scanArtifactJobName
 }
+
+// redisBacklogKey returns the key of the sorted set tracking enqueued scan jobs not yet picked up by a worker.
+func redisBacklogKey(namespace string) string {
+	return redisJobChannel(namespace) + ":backlog"
+}
+
+// redisStartedKey returns the key of the sorted set of running scan jobs, scored by the time they were started.
+func redisStartedKey(namespace string) string {
+	return redisJobChannel(namespace) + ":started"
+}
+
+// redisDurationsKey returns the key of the list holding the most recent scan durations in milliseconds.
+func redisDurationsKey(namespace string) string {
+	return redisJobChannel(namespace) + ":durations"
+}
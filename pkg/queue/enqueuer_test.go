@@ -0,0 +1,464 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/log"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/mock"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+)
+
+func TestEstimateWait(t *testing.T) {
+	testCases := []struct {
+		name              string
+		ahead             int
+		concurrency       int
+		elapsed           []time.Duration
+		samples           []string
+		expectedWait      time.Duration
+		expectedEstimated bool
+	}{
+		{
+			name:        "Should not estimate without samples",
+			ahead:       3,
+			concurrency: 1,
+		},
+		{
+			name:              "Should not wait when first in line",
+			ahead:             0,
+			concurrency:       1,
+			samples:           []string{"60000"},
+			expectedEstimated: true,
+		},
+		{
+			name:              "Should multiply average duration by jobs ahead",
+			ahead:             3,
+			concurrency:       1,
+			samples:           []string{"30000", "90000"},
+			expectedWait:      3 * time.Minute,
+			expectedEstimated: true,
+		},
+		{
+			name:              "Should account for worker concurrency",
+			ahead:             5,
+			concurrency:       2,
+			samples:           []string{"60000"},
+			expectedWait:      2 * time.Minute,
+			expectedEstimated: true,
+		},
+		{
+			name:              "Should wait for the rest of running scan jobs",
+			ahead:             0,
+			concurrency:       1,
+			elapsed:           []time.Duration{20 * time.Second},
+			samples:           []string{"60000"},
+			expectedWait:      40 * time.Second,
+			expectedEstimated: true,
+		},
+		{
+			name:              "Should pick up jobs ahead with the first worker to be free",
+			ahead:             3,
+			concurrency:       2,
+			elapsed:           []time.Duration{50 * time.Second, 10 * time.Second},
+			samples:           []string{"60000"},
+			expectedWait:      110 * time.Second,
+			expectedEstimated: true,
+		},
+		{
+			name:              "Should not wait for running scan jobs taking longer than average",
+			ahead:             2,
+			concurrency:       2,
+			elapsed:           []time.Duration{90 * time.Second},
+			samples:           []string{"60000"},
+			expectedWait:      time.Minute,
+			expectedEstimated: true,
+		},
+		{
+			name:              "Should skip malformed samples",
+			ahead:             1,
+			concurrency:       1,
+			samples:           []string{"oops", "10000"},
+			expectedWait:      10 * time.Second,
+			expectedEstimated: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			wait, estimated := estimateWait(tc.ahead, tc.concurrency, tc.elapsed, tc.samples)
+			assert.Equal(t, tc.expectedWait, wait)
+			assert.Equal(t, tc.expectedEstimated, estimated)
+		})
+	}
+}
+
+func TestEnqueuer_Enqueue_ExistingScanJob(t *testing.T) {
+	ctx := context.Background()
+	request := harbor.ScanRequest{
+		Registry: harbor.Registry{URL: "https://core.harbor.domain"},
+		Artifact: harbor.Artifact{
+			Repository: "library/mongo",
+			Digest:     "sha256:917f5b7f4bef1b35ee90f03033f33a81002511c1e0767fd44276d4bd9cd2fa8e",
+		},
+	}
+	otherRequest := request
+	otherRequest.Artifact.Repository = "library/redis"
+	sbomRequest := request
+	sbomRequest.EnabledCapabilities = []harbor.EnabledCapability{{Type: harbor.CapabilityTypeSBOM}}
+
+	idGenerator, err := job.NewIDGenerator(job.IDGeneratorDigest)
+	require.NoError(t, err)
+	id, err := idGenerator.NewID(request)
+	require.NoError(t, err)
+	jobCtx := log.WithDigest(log.WithScanJobID(ctx, id), request.Artifact.Digest)
+	exists := xerrors.Errorf("creating scan job %s: %w", id, persistence.ErrScanJobExists)
+	queued := job.ScanJob{ID: id, Status: job.Queued, Request: &request,
+		TraceContext: map[string]string{"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"}}
+
+	t.Run("Should return existing scan job without publishing it again", func(t *testing.T) {
+		existing := &job.ScanJob{ID: id, Status: job.Pending, Request: &request}
+		store := mock.NewStore()
+		mock.ApplyExpectations(t, store, []*mock.Expectation{
+			{Method: "Create", Args: []interface{}{jobCtx, mock.Anything}, ReturnArgs: []interface{}{exists}},
+			{Method: "Get", Args: []interface{}{jobCtx, id}, ReturnArgs: []interface{}{existing, nil}},
+		}...)
+
+		scanJob, err := NewEnqueuer(etc.JobQueue{}, nil, store, idGenerator).Enqueue(ctx, request)
+		require.NoError(t, err)
+		assert.Equal(t, *existing, scanJob)
+		store.AssertExpectations(t)
+	})
+
+	testCases := []struct {
+		name              string
+		existing          *job.ScanJob
+		storeExpectations []*mock.Expectation
+		expectedReused    bool
+		expectedError     string
+	}{
+		{
+			name:           "Should reuse queued scan job of the same artifact",
+			existing:       &job.ScanJob{ID: id, Status: job.Queued, Request: &request},
+			expectedReused: true,
+		},
+		{
+			name:     "Should queue failed scan job of the same artifact again",
+			existing: &job.ScanJob{ID: id, Status: job.Failed, Error: "timeout", Request: &request},
+			storeExpectations: []*mock.Expectation{
+				{
+					Method:     "Replace",
+					Args:       []interface{}{jobCtx, queued},
+					ReturnArgs: []interface{}{nil},
+				},
+			},
+		},
+		{
+			name: "Should replace request and report of finished scan job of the same artifact",
+			existing: &job.ScanJob{ID: id, Status: job.Finished, Report: harbor.ScanReport{Severity: harbor.SevHigh},
+				Request: &harbor.ScanRequest{
+					Registry: harbor.Registry{URL: "https://core.harbor.domain", Authorization: "Basic c3RhbGU6c3RhbGU="},
+					Artifact: request.Artifact,
+				}},
+			storeExpectations: []*mock.Expectation{
+				{
+					Method:     "Replace",
+					Args:       []interface{}{jobCtx, queued},
+					ReturnArgs: []interface{}{nil},
+				},
+			},
+		},
+		{
+			name:          "Should return conflict when existing scan job is of another artifact",
+			existing:      &job.ScanJob{ID: id, Status: job.Finished, Request: &otherRequest},
+			expectedError: "creating scan job " + id + ": scan job already exists",
+		},
+		{
+			name:          "Should return conflict when existing scan job is of other capabilities",
+			existing:      &job.ScanJob{ID: id, Status: job.Queued, Request: &sbomRequest},
+			expectedError: "creating scan job " + id + ": scan job already exists",
+		},
+		{
+			name:          "Should return conflict when existing scan job has expired",
+			expectedError: "creating scan job " + id + ": scan job already exists",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := mock.NewStore()
+			mock.ApplyExpectations(t, store, &mock.Expectation{
+				Method:     "Get",
+				Args:       []interface{}{jobCtx, id},
+				ReturnArgs: []interface{}{tc.existing, nil},
+			})
+			mock.ApplyExpectations(t, store, tc.storeExpectations...)
+			e := &enqueuer{store: store}
+
+			_, reused, err := e.reuse(jobCtx, queued)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				assert.ErrorIs(t, err, persistence.ErrScanJobExists)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedReused, reused)
+			store.AssertExpectations(t)
+		})
+	}
+}
+
+func TestEnqueuer_Enqueue_ReuseReport(t *testing.T) {
+	ctx := context.Background()
+	digest := "sha256:917f5b7f4bef1b35ee90f03033f33a81002511c1e0767fd44276d4bd9cd2fa8e"
+	request := harbor.ScanRequest{
+		Registry: harbor.Registry{URL: "https://core.harbor.domain"},
+		Artifact: harbor.Artifact{Repository: "library/mongo", Digest: digest, MimeType: "application/vnd.oci.image.manifest.v1+json"},
+	}
+	scannedRequest := request
+	scannedRequest.Artifact.Repository = "proxy/mongo"
+
+	idGenerator, err := job.NewIDGenerator(job.IDGeneratorDigest)
+	require.NoError(t, err)
+	id, err := idGenerator.NewID(request)
+	require.NoError(t, err)
+	jobCtx := log.WithDigest(log.WithScanJobID(ctx, id), request.Artifact.Digest)
+	scannedID, err := idGenerator.NewID(scannedRequest)
+	require.NoError(t, err)
+
+	platform := &harbor.Platform{OSFamily: "debian", OSVersion: "12.5"}
+	report := harbor.ScanReport{
+		Artifact: harbor.Artifact{Repository: "proxy/mongo", Digest: digest, Platform: platform},
+		Severity: harbor.SevHigh,
+		Vulnerabilities: []harbor.VulnerabilityItem{
+			{ID: "CVE-2024-0001", Pkg: "openssl", Version: "3.0.11", Severity: harbor.SevHigh},
+		},
+	}
+	reusedReport := report
+	reusedReport.Artifact = harbor.Artifact{
+		Repository: "library/mongo",
+		Digest:     digest,
+		MimeType:   "application/vnd.oci.image.manifest.v1+json",
+		Platform:   platform,
+	}
+	reusedJob := job.ScanJob{ID: id, Status: job.Finished, Report: reusedReport, Request: &request}
+
+	t.Run("Should serve report of recently scanned digest without scanning", func(t *testing.T) {
+		digests := mock.NewDigestIndex()
+		mock.ApplyExpectations(t, digests, &mock.Expectation{
+			Method: "Find", Args: []interface{}{jobCtx, digest}, ReturnArgs: []interface{}{scannedID, nil},
+		})
+		store := mock.NewStore()
+		mock.ApplyExpectations(t, store, []*mock.Expectation{
+			{
+				Method:     "Get",
+				Args:       []interface{}{jobCtx, scannedID},
+				ReturnArgs: []interface{}{&job.ScanJob{ID: scannedID, Status: job.Finished, Report: report}, nil},
+			},
+			{Method: "Create", Args: []interface{}{jobCtx, reusedJob}, ReturnArgs: []interface{}{nil}},
+		}...)
+
+		scanJob, err := NewEnqueuer(etc.JobQueue{}, nil, store, idGenerator, WithDigestIndex(digests)).Enqueue(ctx, request)
+		require.NoError(t, err)
+		assert.Equal(t, reusedJob, scanJob)
+		digests.AssertExpectations(t)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("Should update report of the same scan job", func(t *testing.T) {
+		digests := mock.NewDigestIndex()
+		mock.ApplyExpectations(t, digests, &mock.Expectation{
+			Method: "Find", Args: []interface{}{jobCtx, digest}, ReturnArgs: []interface{}{id, nil},
+		})
+		store := mock.NewStore()
+		mock.ApplyExpectations(t, store, []*mock.Expectation{
+			{
+				Method:     "Get",
+				Args:       []interface{}{jobCtx, id},
+				ReturnArgs: []interface{}{&job.ScanJob{ID: id, Status: job.Finished, Report: report}, nil},
+			},
+			{Method: "UpdateReport", Args: []interface{}{jobCtx, id, reusedReport}, ReturnArgs: []interface{}{nil}},
+		}...)
+
+		scanJob, err := NewEnqueuer(etc.JobQueue{}, nil, store, idGenerator, WithDigestIndex(digests)).Enqueue(ctx, request)
+		require.NoError(t, err)
+		assert.Equal(t, reusedJob, scanJob)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("Should not look up digest when fresh scan is forced", func(t *testing.T) {
+		digests := mock.NewDigestIndex()
+		store := mock.NewStore()
+		mock.ApplyExpectations(t, store, &mock.Expectation{
+			Method: "Create", Args: []interface{}{mock.Anything, mock.Anything}, ReturnArgs: []interface{}{xerrors.New("store is down")},
+		})
+
+		_, err := NewEnqueuer(etc.JobQueue{}, nil, store, idGenerator, WithDigestIndex(digests)).
+			Enqueue(WithForceScan(ctx), request)
+		assert.EqualError(t, err, "creating scan job store is down")
+		digests.AssertNotCalled(t, "Find", mock.Anything, mock.Anything)
+	})
+
+	testCases := []struct {
+		name              string
+		scannedID         string
+		findError         error
+		scanned           *job.ScanJob
+		capabilities      []harbor.EnabledCapability
+		storeExpectations []*mock.Expectation
+	}{
+		{
+			name: "Should scan digest which was not scanned recently",
+		},
+		{
+			name:      "Should scan digest when finding its scan job fails",
+			findError: xerrors.New("redis is down"),
+		},
+		{
+			name:      "Should scan digest whose scan job has expired",
+			scannedID: scannedID,
+		},
+		{
+			name:      "Should scan digest whose scan job is still running",
+			scannedID: scannedID,
+			scanned:   &job.ScanJob{ID: scannedID, Status: job.Pending},
+		},
+		{
+			name:      "Should scan digest whose scan job failed",
+			scannedID: scannedID,
+			scanned:   &job.ScanJob{ID: scannedID, Status: job.Failed, Error: "timeout"},
+		},
+		{
+			name:      "Should scan digest whose report is partial",
+			scannedID: scannedID,
+			scanned: &job.ScanJob{ID: scannedID, Status: job.Finished, Report: harbor.ScanReport{Partial: true},
+				Error: "scan interrupted, the report is partial: timeout"},
+		},
+		{
+			name:         "Should scan digest whose scan job has no SBOM requested by sbom capability",
+			scannedID:    scannedID,
+			scanned:      &job.ScanJob{ID: scannedID, Status: job.Finished, Report: report},
+			capabilities: []harbor.EnabledCapability{{Type: harbor.CapabilityTypeSBOM}},
+		},
+		{
+			name:      "Should scan digest whose SBOM was generated in another media type",
+			scannedID: scannedID,
+			scanned: &job.ScanJob{ID: scannedID, Status: job.Finished, Report: report,
+				SBOM: &harbor.SBOMReport{MediaType: harbor.MediaTypeCycloneDX}},
+			capabilities: []harbor.EnabledCapability{{
+				Type:       harbor.CapabilityTypeSBOM,
+				Parameters: &harbor.CapabilityParameters{SBOMMediaTypes: []string{harbor.MediaTypeSPDX}},
+			}},
+		},
+		{
+			name:      "Should scan digest whose scan job was only requested for an SBOM",
+			scannedID: scannedID,
+			scanned: &job.ScanJob{ID: scannedID, Status: job.Finished, Request: &harbor.ScanRequest{
+				EnabledCapabilities: []harbor.EnabledCapability{{Type: harbor.CapabilityTypeSBOM}},
+			}},
+		},
+		{
+			name:      "Should scan digest when reused report cannot be saved",
+			scannedID: scannedID,
+			scanned:   &job.ScanJob{ID: scannedID, Status: job.Finished, Report: report},
+			storeExpectations: []*mock.Expectation{
+				{Method: "Create", Args: []interface{}{jobCtx, reusedJob}, ReturnArgs: []interface{}{xerrors.New("store is down")}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			digests := mock.NewDigestIndex()
+			mock.ApplyExpectations(t, digests, &mock.Expectation{
+				Method: "Find", Args: []interface{}{jobCtx, digest}, ReturnArgs: []interface{}{tc.scannedID, tc.findError},
+			})
+			store := mock.NewStore()
+			if tc.scannedID != "" {
+				mock.ApplyExpectations(t, store, &mock.Expectation{
+					Method: "Get", Args: []interface{}{jobCtx, tc.scannedID}, ReturnArgs: []interface{}{tc.scanned, nil},
+				})
+			}
+			mock.ApplyExpectations(t, store, tc.storeExpectations...)
+			e := &enqueuer{store: store, digests: digests}
+			request := request
+			request.EnabledCapabilities = tc.capabilities
+
+			_, reused := e.reuseReport(jobCtx, id, request)
+			assert.False(t, reused)
+			digests.AssertExpectations(t)
+			store.AssertExpectations(t)
+		})
+	}
+}
+
+func TestEnqueuer_Enqueue_FailFast(t *testing.T) {
+	ctx := context.Background()
+	digest := "sha256:917f5b7f4bef1b35ee90f03033f33a81002511c1e0767fd44276d4bd9cd2fa8e"
+	request := harbor.ScanRequest{
+		Registry: harbor.Registry{URL: "https://core.harbor.domain"},
+		Artifact: harbor.Artifact{Repository: "library/mongo", Digest: digest},
+	}
+	message := "running tunnel wrapper: artifact cannot be scanned: MANIFEST_UNKNOWN: manifest unknown"
+
+	idGenerator, err := job.NewIDGenerator(job.IDGeneratorDigest)
+	require.NoError(t, err)
+	id, err := idGenerator.NewID(request)
+	require.NoError(t, err)
+	jobCtx := log.WithDigest(log.WithScanJobID(ctx, id), request.Artifact.Digest)
+
+	t.Run("Should fail scan job of digest which failed repeatedly", func(t *testing.T) {
+		failedJob := job.ScanJob{ID: id, Status: job.Failed, Error: message, Request: &request}
+		failures := mock.NewFailureIndex()
+		mock.ApplyExpectations(t, failures, &mock.Expectation{
+			Method: "Find", Args: []interface{}{jobCtx, digest}, ReturnArgs: []interface{}{message, nil},
+		})
+		store := mock.NewStore()
+		mock.ApplyExpectations(t, store, &mock.Expectation{
+			Method: "Create", Args: []interface{}{jobCtx, failedJob}, ReturnArgs: []interface{}{nil},
+		})
+
+		scanJob, err := NewEnqueuer(etc.JobQueue{}, nil, store, idGenerator, WithFailureIndex(failures)).Enqueue(ctx, request)
+		require.NoError(t, err)
+		assert.Equal(t, failedJob, scanJob)
+		failures.AssertExpectations(t)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("Should queue scan job of digest which did not fail repeatedly", func(t *testing.T) {
+		failures := mock.NewFailureIndex()
+		mock.ApplyExpectations(t, failures, &mock.Expectation{
+			Method: "Find", Args: []interface{}{jobCtx, digest}, ReturnArgs: []interface{}{"", nil},
+		})
+		store := mock.NewStore()
+		mock.ApplyExpectations(t, store, &mock.Expectation{
+			Method: "Create", Args: []interface{}{jobCtx, job.ScanJob{ID: id, Status: job.Queued, Request: &request}},
+			ReturnArgs: []interface{}{xerrors.New("store is down")},
+		})
+
+		_, err := NewEnqueuer(etc.JobQueue{}, nil, store, idGenerator, WithFailureIndex(failures)).Enqueue(ctx, request)
+		assert.EqualError(t, err, "creating scan job store is down")
+		store.AssertExpectations(t)
+	})
+
+	t.Run("Should not look up digest when fresh scan is forced", func(t *testing.T) {
+		failures := mock.NewFailureIndex()
+		store := mock.NewStore()
+		mock.ApplyExpectations(t, store, &mock.Expectation{
+			Method: "Create", Args: []interface{}{mock.Anything, mock.Anything}, ReturnArgs: []interface{}{xerrors.New("store is down")},
+		})
+
+		_, err := NewEnqueuer(etc.JobQueue{}, nil, store, idGenerator, WithFailureIndex(failures)).
+			Enqueue(WithForceScan(ctx), request)
+		assert.EqualError(t, err, "creating scan job store is down")
+		failures.AssertNotCalled(t, "Find", mock.Anything, mock.Anything)
+	})
+}
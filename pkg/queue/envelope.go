@@ -0,0 +1,137 @@
+package queue
+
+import (
+	"encoding/json"
+
+	"github.com/klauspost/compress/zstd"
+	"golang.org/x/xerrors"
+)
+
+// Envelope formats of the queue messages.
+//
+// The json format publishes the unversioned JSON messages understood by all releases, and is meant for
+// rolling upgrades. The zstd format publishes the same JSON messages compressed with zstd. The id format
+// publishes the scan job identifier only, and workers look up the scan request in the store, which keeps
+// the registry credentials out of Redis Pub/Sub.
+const (
+	EnvelopeJSON = "json"
+	EnvelopeZstd = "zstd"
+	EnvelopeID   = "id"
+)
+
+// envelopeMagic starts versioned envelopes. It cannot start a JSON document, which tells versioned
+// envelopes apart from the unversioned JSON messages.
+const envelopeMagic byte = 0xff
+
+// Versions of the versioned envelopes. Workers decode the envelopes of the current and the previous
+// version, so that the replicas of a rolling upgrade understand each other's messages as long as
+// publishers are pinned to the previous version until all replicas are upgraded.
+//
+// A version 1 envelope is made of envelopeMagic, the version, the encoding and the encoded job. A version 2
+// envelope adds the minimum version a worker must understand to decode it after the version, so that future
+// versions keeping this layout remain readable by the workers of this release.
+const (
+	envelopeVersion1 = 1
+	envelopeVersion2 = 2
+
+	// envelopeVersion is the latest version understood by this release.
+	envelopeVersion = envelopeVersion2
+)
+
+const (
+	encodingZstd byte = 'z'
+	encodingID   byte = 'i'
+)
+
+// errNewerEnvelope is returned when decoding an envelope published by a newer release, which is left to the
+// upgraded replicas.
+var errNewerEnvelope = xerrors.New("envelope published by a newer release")
+
+// maxDecodedSize bounds the size of decompressed messages.
+const maxDecodedSize = 16 << 20
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxDecodedSize))
+)
+
+// encode returns the message carrying the given job in the given envelope format and version. The version
+// is ignored by the unversioned json format.
+func encode(format string, version int, j Job) ([]byte, error) {
+	var header []byte
+	switch version {
+	case envelopeVersion1:
+		header = []byte{envelopeMagic, envelopeVersion1}
+	case 0, envelopeVersion2:
+		header = []byte{envelopeMagic, envelopeVersion2, envelopeVersion2}
+	default:
+		return nil, xerrors.Errorf("unsupported job queue envelope version: %d", version)
+	}
+
+	switch format {
+	case "", EnvelopeJSON:
+		return json.Marshal(j)
+	case EnvelopeZstd:
+		b, err := json.Marshal(j)
+		if err != nil {
+			return nil, err
+		}
+		return zstdEncoder.EncodeAll(b, append(header, encodingZstd)), nil
+	case EnvelopeID:
+		return append(append(header, encodingID), j.ID...), nil
+	}
+	return nil, xerrors.Errorf("unsupported job queue envelope: %s", format)
+}
+
+// decode returns the job carried by the given message in any envelope format of the current or the previous
+// version. The scan request of the returned job is nil if the message carries the scan job identifier only.
+// The returned error wraps errNewerEnvelope if the message was published by a newer release.
+func decode(payload []byte) (Job, error) {
+	var j Job
+	if len(payload) == 0 || payload[0] != envelopeMagic {
+		if err := json.Unmarshal(payload, &j); err != nil {
+			return Job{}, err
+		}
+		return j, nil
+	}
+
+	if len(payload) < 3 {
+		return Job{}, xerrors.New("truncated envelope")
+	}
+
+	var encoding byte
+	var body []byte
+	switch version := int(payload[1]); {
+	case version == envelopeVersion1:
+		encoding, body = payload[2], payload[3:]
+	case version >= envelopeVersion2:
+		if minVersion := int(payload[2]); minVersion > envelopeVersion {
+			return Job{}, xerrors.Errorf("unsupported envelope version %d: %w", version, errNewerEnvelope)
+		}
+		if len(payload) < 4 {
+			return Job{}, xerrors.New("truncated envelope")
+		}
+		encoding, body = payload[3], payload[4:]
+	default:
+		return Job{}, xerrors.Errorf("unsupported envelope version %d", version)
+	}
+
+	switch encoding {
+	case encodingZstd:
+		b, err := zstdDecoder.DecodeAll(body, nil)
+		if err != nil {
+			return Job{}, xerrors.Errorf("decompressing envelope: %w", err)
+		}
+		if err = json.Unmarshal(b, &j); err != nil {
+			return Job{}, err
+		}
+		return j, nil
+	case encodingID:
+		if len(body) == 0 {
+			return Job{}, xerrors.New("blank scan job ID in envelope")
+		}
+		return Job{Name: scanArtifactJobName, ID: string(body)}, nil
+	default:
+		return Job{}, xerrors.Errorf("unsupported envelope encoding %q", encoding)
+	}
+}
@@ -0,0 +1,145 @@
+package queue
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+)
+
+func TestEnvelope(t *testing.T) {
+	j := Job{
+		Name: scanArtifactJobName,
+		ID:   "job:123",
+		Args: Args{
+			ScanRequest: &harbor.ScanRequest{
+				Registry: harbor.Registry{
+					URL:           "https://core.harbor.domain",
+					Authorization: "Bearer: SECRET",
+				},
+				Artifact: harbor.Artifact{
+					Repository: "library/mongo",
+					Digest:     "sha256:917f5b7f4bef1b35ee90f03033f33a81002511c1e0767fd44276d4bd9cd2fa8e",
+				},
+			},
+		},
+		TraceContext: map[string]string{
+			"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		},
+	}
+
+	testCases := []struct {
+		name        string
+		envelope    string
+		version     int
+		expectedJob Job
+	}{
+		{
+			name:        "Should round-trip unversioned JSON envelope",
+			envelope:    EnvelopeJSON,
+			expectedJob: j,
+		},
+		{
+			name:        "Should round-trip zstd envelope",
+			envelope:    EnvelopeZstd,
+			expectedJob: j,
+		},
+		{
+			name:        "Should round-trip scan job ID only",
+			envelope:    EnvelopeID,
+			expectedJob: Job{Name: scanArtifactJobName, ID: "job:123"},
+		},
+		{
+			name:        "Should round-trip zstd envelope pinned to previous version",
+			envelope:    EnvelopeZstd,
+			version:     envelopeVersion1,
+			expectedJob: j,
+		},
+		{
+			name:        "Should round-trip scan job ID only pinned to previous version",
+			envelope:    EnvelopeID,
+			version:     envelopeVersion1,
+			expectedJob: Job{Name: scanArtifactJobName, ID: "job:123"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			payload, err := encode(tc.envelope, tc.version, j)
+			require.NoError(t, err)
+
+			decoded, err := decode(payload)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedJob, decoded)
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	testCases := []struct {
+		name          string
+		payload       []byte
+		expectedJob   Job
+		expectedError string
+	}{
+		{
+			name:        "Should decode envelope published by previous release",
+			payload:     []byte{envelopeMagic, envelopeVersion1, encodingID, 'j', 'o', 'b', ':', '1'},
+			expectedJob: Job{Name: scanArtifactJobName, ID: "job:1"},
+		},
+		{
+			name:        "Should decode newer envelope readable by this release",
+			payload:     []byte{envelopeMagic, envelopeVersion + 1, envelopeVersion, encodingID, 'j', 'o', 'b', ':', '1'},
+			expectedJob: Job{Name: scanArtifactJobName, ID: "job:1"},
+		},
+		{
+			name:          "Should return error when envelope version is newer",
+			payload:       []byte{envelopeMagic, envelopeVersion + 1, envelopeVersion + 1, encodingID, 'x'},
+			expectedError: "unsupported envelope version 3: envelope published by a newer release",
+		},
+		{
+			name:          "Should return error when envelope version is unknown",
+			payload:       []byte{envelopeMagic, 0, encodingID, 'x'},
+			expectedError: "unsupported envelope version 0",
+		},
+		{
+			name:          "Should return error when envelope is truncated",
+			payload:       []byte{envelopeMagic, envelopeVersion},
+			expectedError: "truncated envelope",
+		},
+		{
+			name:          "Should return error when envelope header is truncated",
+			payload:       []byte{envelopeMagic, envelopeVersion, envelopeVersion},
+			expectedError: "truncated envelope",
+		},
+		{
+			name:          "Should return error when encoding is unknown",
+			payload:       []byte{envelopeMagic, envelopeVersion, envelopeVersion, 'x'},
+			expectedError: `unsupported envelope encoding 'x'`,
+		},
+		{
+			name:          "Should return error when scan job ID is blank",
+			payload:       []byte{envelopeMagic, envelopeVersion, envelopeVersion, encodingID},
+			expectedError: "blank scan job ID in envelope",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			decoded, err := decode(tc.payload)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedJob, decoded)
+		})
+	}
+
+	t.Run("Should tell envelopes of newer releases apart", func(t *testing.T) {
+		_, err := decode([]byte{envelopeMagic, envelopeVersion + 1, envelopeVersion + 1, encodingID, 'x'})
+		assert.ErrorIs(t, err, errNewerEnvelope)
+	})
+}
@@ -0,0 +1,219 @@
+package queue
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/clock"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/log"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/metrics"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/scan"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tracing"
+)
+
+// Local is an in-process job queue, which carries the scan jobs from the Enqueuer to the Worker of the same
+// process rather than through Redis. It suits single-replica installations only, as the scan jobs enqueued by a
+// replica are scanned by that replica, and the ones queued when it exits are lost unless RecoverLocal
+// republishes them from a persistent store.
+type Local struct {
+	mu sync.Mutex
+	// queued holds the scan jobs waiting for a worker, in the order they were published.
+	queued []Job
+	// running holds the times the running scan jobs were started at.
+	running map[string]time.Time
+	// samples holds the most recent scan durations in milliseconds, the most recent first.
+	samples []string
+	// ready wakes up a worker waiting for scan jobs.
+	ready chan struct{}
+}
+
+// NewLocal constructs an empty Local job queue.
+func NewLocal() *Local {
+	return &Local{
+		running: make(map[string]time.Time),
+		ready:   make(chan struct{}, 1),
+	}
+}
+
+// NewLocalEnqueuer constructs an Enqueuer publishing scan jobs to the given Local job queue.
+func NewLocalEnqueuer(config etc.JobQueue, local *Local, store persistence.Store, idGenerator job.IDGenerator, opts ...EnqueuerOption) Enqueuer {
+	return newEnqueuer(config, local, store, idGenerator, opts...)
+}
+
+// publish queues the given job, unless a job with the same ID is already queued.
+func (l *Local) publish(_ context.Context, j Job, _ time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !slices.ContainsFunc(l.queued, func(queued Job) bool { return queued.ID == j.ID }) {
+		l.queued = append(l.queued, j)
+	}
+	l.signal()
+	return nil
+}
+
+func (l *Local) rank(_ context.Context, scanJobID string) (int64, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	i := slices.IndexFunc(l.queued, func(j Job) bool { return j.ID == scanJobID })
+	return int64(i), i >= 0, nil
+}
+
+func (l *Local) backlog(_ context.Context) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int64(len(l.queued)), nil
+}
+
+func (l *Local) started(_ context.Context) ([]time.Time, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return lo.Values(l.running), nil
+}
+
+func (l *Local) durations(_ context.Context) ([]string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Clone(l.samples), nil
+}
+
+// trim keeps every scan job, which cannot be lost by a worker of the same process.
+func (l *Local) trim(_ context.Context, _ time.Time) error {
+	return nil
+}
+
+// take removes the first queued job, if any, and records it as running since the given time.
+func (l *Local) take(now time.Time) (Job, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.queued) == 0 {
+		return Job{}, false
+	}
+	j := l.queued[0]
+	l.queued = slices.Delete(l.queued, 0, 1)
+	l.running[j.ID] = now
+	if len(l.queued) > 0 {
+		// Another worker may be waiting while this one is busy.
+		l.signal()
+	}
+	return j, true
+}
+
+// finish removes the given scan job from the running ones.
+func (l *Local) finish(scanJobID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.running, scanJobID)
+}
+
+// record keeps the given duration among the most recent scan durations.
+func (l *Local) record(duration time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.samples = append([]string{strconv.FormatInt(duration.Milliseconds(), 10)}, l.samples...)
+	if len(l.samples) > durationSamples {
+		l.samples = l.samples[:durationSamples]
+	}
+}
+
+// isActive returns true if the given scan job is queued or running.
+func (l *Local) isActive(scanJobID string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, running := l.running[scanJobID]
+	return running || slices.ContainsFunc(l.queued, func(j Job) bool { return j.ID == scanJobID })
+}
+
+func (l *Local) signal() {
+	select {
+	case l.ready <- struct{}{}:
+	default:
+	}
+}
+
+type localWorker struct {
+	local       *Local
+	concurrency int
+	controller  scan.Controller
+	clock       clock.Clock
+	done        chan struct{}
+}
+
+// NewLocalWorker constructs a Worker scanning the scan jobs of the given Local job queue.
+func NewLocalWorker(config etc.JobQueue, local *Local, controller scan.Controller) Worker {
+	return &localWorker{
+		local:       local,
+		concurrency: config.WorkerConcurrency,
+		controller:  controller,
+		clock:       clock.System,
+		done:        make(chan struct{}),
+	}
+}
+
+func (w *localWorker) Start(ctx context.Context) {
+	for i := 0; i < w.concurrency; i++ {
+		go w.run(ctx)
+	}
+}
+
+func (w *localWorker) Stop() {
+	slog.Debug("Job queue shutdown started")
+	close(w.done)
+	slog.Debug("Job queue shutdown completed")
+}
+
+func (w *localWorker) run(ctx context.Context) {
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		started := w.clock.Now()
+		j, ok := w.local.take(started)
+		if !ok {
+			select {
+			case <-w.local.ready:
+			case <-w.done:
+				return
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+
+		if err := w.scanArtifact(ctx, j, started); err != nil {
+			slog.Error("Failed to scan artifact", slog.String("scan_job_id", j.ID), slog.String("err", err.Error()))
+		}
+	}
+}
+
+func (w *localWorker) scanArtifact(ctx context.Context, j Job, started time.Time) (err error) {
+	defer w.local.finish(j.ID)
+	ctx = log.WithScanJobID(ctx, j.ID)
+
+	ctx, span := tracing.Start(tracing.Extract(ctx, j.TraceContext), "ScanJob",
+		trace.WithSpanKind(trace.SpanKindConsumer), trace.WithAttributes(tracing.ScanJobID(j.ID)))
+	defer func() { tracing.End(span, err) }()
+
+	slog.DebugContext(ctx, "Executing enqueued scan job")
+	metrics.ScanJobsStarted.Inc()
+	if err = w.controller.Scan(ctx, j.ID, lo.FromPtr(j.Args.ScanRequest)); err != nil {
+		return err
+	}
+
+	w.local.record(clock.Since(w.clock, started))
+	return nil
+}
@@ -0,0 +1,100 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/clock"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence/memory"
+)
+
+// blockingController records the scan jobs it is asked to scan, and completes them once released.
+type blockingController struct {
+	scanned chan string
+	release chan struct{}
+}
+
+func (c *blockingController) Scan(_ context.Context, scanJobID string, _ harbor.ScanRequest) error {
+	c.scanned <- scanJobID
+	<-c.release
+	return nil
+}
+
+func TestLocal(t *testing.T) {
+	ctx := context.Background()
+	config := etc.JobQueue{WorkerConcurrency: 1}
+	request := harbor.ScanRequest{
+		Registry: harbor.Registry{URL: "https://core.harbor.domain"},
+		Artifact: harbor.Artifact{Repository: "library/mongo", Digest: "sha256:917f5b7f"},
+	}
+
+	newStore := func() persistence.Store {
+		return memory.NewStore(etc.MemoryStore{ScanJobTTL: time.Hour, MaxScanJobs: 10})
+	}
+	idGenerator, err := job.NewIDGenerator(job.IDGeneratorRandom)
+	require.NoError(t, err)
+
+	t.Run("Should scan enqueued scan jobs in order", func(t *testing.T) {
+		local := NewLocal()
+		controller := &blockingController{scanned: make(chan string), release: make(chan struct{})}
+		enqueuer := NewLocalEnqueuer(config, local, newStore(), idGenerator)
+		worker := NewLocalWorker(config, local, controller)
+		worker.Start(ctx)
+		defer worker.Stop()
+
+		first, err := enqueuer.Enqueue(ctx, request)
+		require.NoError(t, err)
+		second, err := enqueuer.Enqueue(ctx, request)
+		require.NoError(t, err)
+
+		assert.Equal(t, first.ID, <-controller.scanned)
+
+		position, err := enqueuer.Position(ctx, second.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, position.Position)
+		stats, err := enqueuer.Stats(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), stats.Backlog)
+
+		controller.release <- struct{}{}
+		assert.Equal(t, second.ID, <-controller.scanned)
+		controller.release <- struct{}{}
+
+		position, err = enqueuer.Position(ctx, second.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 0, position.Position)
+	})
+
+	t.Run("Should recover unfinished scan jobs once", func(t *testing.T) {
+		store := newStore()
+		require.NoError(t, store.Create(ctx, job.ScanJob{ID: "queued", Status: job.Queued, Request: &request}))
+		require.NoError(t, store.Create(ctx, job.ScanJob{ID: "pending", Status: job.Pending, Request: &request}))
+		require.NoError(t, store.Create(ctx, job.ScanJob{ID: "finished", Status: job.Finished, Request: &request}))
+		require.NoError(t, store.Create(ctx, job.ScanJob{ID: "lost", Status: job.Queued}))
+		local := NewLocal()
+
+		recovered, err := RecoverLocal(ctx, local, store, clock.System)
+		require.NoError(t, err)
+		assert.Equal(t, 2, recovered)
+
+		recovered, err = RecoverLocal(ctx, local, store, clock.System)
+		require.NoError(t, err)
+		assert.Equal(t, 0, recovered)
+
+		backlog, err := local.backlog(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), backlog)
+
+		lost, err := store.Get(ctx, "lost")
+		require.NoError(t, err)
+		assert.Equal(t, job.Failed, lost.Status)
+	})
+}
@@ -0,0 +1,124 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/xerrors"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/log"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/metrics"
+)
+
+// maxTakeovers is the number of times a stalled scan job is requeued before it is marked as failed.
+// It protects workers from scan jobs that repeatedly crash the process, e.g. because they run out of memory.
+const maxTakeovers = 3
+
+// reap periodically requeues scan jobs whose worker stopped sending heartbeats, so that a crashed
+// replica doesn't leave scan jobs pending until their TTL expires.
+func (w *worker) reap(ctx context.Context) {
+	ticker := time.NewTicker(w.heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			if err := w.requeueStalled(ctx); err != nil {
+				slog.Warn("Error while requeueing stalled scan jobs", slog.String("err", err.Error()))
+			}
+		}
+	}
+}
+
+func (w *worker) requeueStalled(ctx context.Context) error {
+	deadline := w.clock.Now().Add(-w.stallTimeout).UnixMilli()
+	stalled, err := w.rdb.ZRangeByScore(ctx, redisInFlightKey(w.namespace), &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(deadline, 10),
+	}).Result()
+	if err != nil {
+		return xerrors.Errorf("listing stalled scan jobs: %w", err)
+	}
+
+	for _, scanJobID := range stalled {
+		if err = w.takeOver(ctx, scanJobID); err != nil {
+			slog.WarnContext(log.WithScanJobID(ctx, scanJobID), "Error while taking over stalled scan job",
+				slog.String("err", err.Error()))
+		}
+	}
+	return nil
+}
+
+func (w *worker) takeOver(ctx context.Context, scanJobID string) error {
+	// Removing the in-flight entry acts as the claim, only one replica can succeed.
+	removed, err := w.rdb.ZRem(ctx, redisInFlightKey(w.namespace), scanJobID).Result()
+	if err != nil {
+		return xerrors.Errorf("claiming scan job: %w", err)
+	} else if removed == 0 {
+		return nil
+	}
+
+	takeovers, err := w.rdb.HIncrBy(ctx, redisTakeoversKey(w.namespace), scanJobID, 1).Result()
+	if err != nil {
+		return xerrors.Errorf("counting takeovers: %w", err)
+	}
+
+	ctx = log.WithScanJobID(ctx, scanJobID)
+	logger := slog.With(slog.Int64("takeovers", takeovers))
+
+	if takeovers > maxTakeovers {
+		logger.ErrorContext(ctx, "Giving up on stalled scan job")
+		if _, err = w.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HDel(ctx, redisPayloadsKey(w.namespace), scanJobID)
+			pipe.HDel(ctx, redisTakeoversKey(w.namespace), scanJobID)
+			return nil
+		}); err != nil {
+			return xerrors.Errorf("unregistering scan job: %w", err)
+		}
+		return w.store.UpdateStatus(ctx, scanJobID, job.Failed,
+			fmt.Sprintf("scan job stalled %d times, the worker may be running out of resources", maxTakeovers))
+	}
+
+	// The scan request is looked up in the store by the worker picking up the scan job.
+	payload, err := encode(EnvelopeID, w.envelopeVersion, Job{ID: scanJobID})
+	if err != nil {
+		return xerrors.Errorf("encoding scan job: %w", err)
+	}
+
+	logger.WarnContext(ctx, "Requeueing stalled scan job")
+	_, err = w.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HDel(ctx, redisPayloadsKey(w.namespace), scanJobID)
+		pipe.Del(ctx, redisLockKey(w.namespace, scanJobID))
+		pipe.ZAdd(ctx, redisBacklogKey(w.namespace), redis.Z{Score: float64(w.clock.Now().UnixMilli()), Member: scanJobID})
+		pipe.Publish(ctx, w.redisJobChannel(), payload)
+		return nil
+	})
+	if err != nil {
+		return xerrors.Errorf("requeueing scan job: %w", err)
+	}
+	metrics.ScanJobsRetried.WithLabelValues(metrics.RetryStalled).Inc()
+	return nil
+}
+
+// redisInFlightKey returns the key of the sorted set of scan jobs being processed, scored by their last heartbeat.
+func redisInFlightKey(namespace string) string {
+	return redisJobChannel(namespace) + ":in-flight"
+}
+
+// redisPayloadsKey returns the key of the hash holding the queue messages of in-flight scan jobs registered by
+// previous releases. Its entries are deleted along with the in-flight scan jobs, and no longer written.
+func redisPayloadsKey(namespace string) string {
+	return redisJobChannel(namespace) + ":payloads"
+}
+
+// redisTakeoversKey returns the key of the hash counting how many times in-flight scan jobs were taken over.
+func redisTakeoversKey(namespace string) string {
+	return redisJobChannel(namespace) + ":takeovers"
+}
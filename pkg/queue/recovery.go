@@ -0,0 +1,107 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/xerrors"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/clock"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/log"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/metrics"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+)
+
+// Recover rebuilds the queue from the scan jobs persisted in the store, which is the source of truth.
+// Queue messages are not persisted by Redis, so without recovery the scan jobs accepted before Redis was
+// restarted, flushed or restored from a backup would never be picked up by a worker.
+//
+// Queued and pending scan jobs are republished unless a worker holds their lock or sends heartbeats for them.
+// Recover must be called once workers are subscribed, and returns the number of republished scan jobs. The
+// republished scan jobs enter the backlog at the time of the given Clock.
+func Recover(ctx context.Context, config etc.JobQueue, rdb redis.UniversalClient, store persistence.Store, c clock.Clock) (int, error) {
+	b := &redisBroker{rdb: rdb, namespace: config.Namespace, envelope: config.Envelope, version: config.EnvelopeVersion}
+	return recoverScanJobs(ctx, c, store, b, func(ctx context.Context, scanJobID string) (bool, error) {
+		return isActive(ctx, config.Namespace, rdb, scanJobID)
+	})
+}
+
+// RecoverLocal republishes the queued and pending scan jobs persisted in the store to the given Local queue, e.g.
+// the ones left by the previous run of a single-replica installation with the postgres store. It returns the number
+// of republished scan jobs.
+func RecoverLocal(ctx context.Context, local *Local, store persistence.Store, c clock.Clock) (int, error) {
+	return recoverScanJobs(ctx, c, store, local, func(_ context.Context, scanJobID string) (bool, error) {
+		return local.isActive(scanJobID), nil
+	})
+}
+
+// recoverScanJobs republishes the queued and pending scan jobs of the store with the given broker as of the time
+// of the given Clock, unless the given function reports them as active.
+func recoverScanJobs(ctx context.Context, c clock.Clock, store persistence.Store, b broker, active func(ctx context.Context, scanJobID string) (bool, error)) (int, error) {
+	scanJobs, err := store.FindByStatus(ctx, job.Queued, job.Pending)
+	if err != nil {
+		return 0, xerrors.Errorf("finding unfinished scan jobs: %w", err)
+	}
+
+	recovered := 0
+	for _, scanJob := range scanJobs {
+		ctx := log.WithScanJobID(ctx, scanJob.ID)
+		logger := slog.With(slog.String("scan_job_status", scanJob.Status.String()))
+
+		running, err := active(ctx, scanJob.ID)
+		if err != nil {
+			return recovered, err
+		} else if running {
+			logger.DebugContext(ctx, "Skip recovering active scan job")
+			continue
+		}
+
+		if scanJob.Request == nil {
+			// Scan jobs created before requests were persisted cannot be replayed.
+			logger.WarnContext(ctx, "Cannot recover scan job without scan request")
+			if err = store.UpdateStatus(ctx, scanJob.ID, job.Failed, "scan job was lost and cannot be recovered"); err != nil {
+				return recovered, err
+			}
+			continue
+		}
+
+		logger.InfoContext(ctx, "Recovering scan job")
+		if err = b.publish(ctx, Job{
+			Name: scanArtifactJobName,
+			ID:   scanJob.ID,
+			Args: Args{ScanRequest: scanJob.Request},
+			// The recovered scan job is traced in the trace of its scan request.
+			TraceContext: scanJob.TraceContext,
+		}, c.Now()); err != nil {
+			return recovered, err
+		}
+		metrics.ScanJobsRetried.WithLabelValues(metrics.RetryRecovered).Inc()
+		recovered++
+	}
+
+	return recovered, nil
+}
+
+// isActive returns true if a worker has picked up the scan job and may still be processing it.
+func isActive(ctx context.Context, namespace string, rdb redis.UniversalClient, scanJobID string) (bool, error) {
+	locked, err := rdb.Exists(ctx, redisLockKey(namespace, scanJobID)).Result()
+	if err != nil {
+		return false, xerrors.Errorf("checking scan job lock: %w", err)
+	}
+	if locked > 0 {
+		return true, nil
+	}
+
+	// A stalled in-flight scan job is requeued by the reaper, so it must not be requeued twice.
+	_, err = rdb.ZScore(ctx, redisInFlightKey(namespace), scanJobID).Result()
+	if err == nil {
+		return true, nil
+	} else if !errors.Is(err, redis.Nil) {
+		return false, xerrors.Errorf("checking in-flight scan job: %w", err)
+	}
+	return false, nil
+}
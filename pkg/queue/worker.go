@@ -2,16 +2,23 @@ package queue
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"log/slog"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/samber/lo"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/xerrors"
 
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/clock"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/log"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/metrics"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/scan"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tracing"
 )
 
 type Worker interface {
@@ -23,25 +30,59 @@ type worker struct {
 	namespace   string
 	concurrency int
 
-	rdb    *redis.Client
+	heartbeatInterval time.Duration
+	stallTimeout      time.Duration
+	// envelopeVersion is the version of the envelopes requeueing the scan jobs taken over by the reaper.
+	envelopeVersion int
+
+	rdb    redis.UniversalClient
 	pubsub *redis.PubSub
+	done   chan struct{}
 
+	store      persistence.Store
 	controller scan.Controller
+	// clock times the heartbeats of scan jobs, and their stalls taken over by the reaper.
+	clock clock.Clock
 }
 
-func NewWorker(config etc.JobQueue, rdb *redis.Client, controller scan.Controller) Worker {
-	return &worker{
+// WorkerOption configures optional behaviors of the Worker.
+type WorkerOption func(*worker)
+
+// WithWorkerClock times heartbeats, stalls and scan durations with the given Clock rather than the system time.
+func WithWorkerClock(c clock.Clock) WorkerOption {
+	return func(w *worker) {
+		w.clock = c
+	}
+}
+
+func NewWorker(config etc.JobQueue, rdb redis.UniversalClient, store persistence.Store, controller scan.Controller, opts ...WorkerOption) Worker {
+	w := &worker{
 		namespace:   config.Namespace,
 		concurrency: config.WorkerConcurrency,
 
-		rdb: rdb,
+		heartbeatInterval: config.HeartbeatInterval,
+		stallTimeout:      config.StallTimeout,
+		envelopeVersion:   config.EnvelopeVersion,
+
+		rdb:  rdb,
+		done: make(chan struct{}),
 
+		store:      store,
 		controller: controller,
+		clock:      clock.System,
+	}
+	for _, opt := range opts {
+		opt(w)
 	}
+	return w
 }
 
 func (w *worker) Start(ctx context.Context) {
 	w.pubsub = w.rdb.Subscribe(ctx, w.redisJobChannel())
+	// Wait for the subscription to be confirmed, so that jobs published once Start returns are not lost.
+	if _, err := w.pubsub.Receive(ctx); err != nil {
+		slog.Warn("Error while subscribing to job queue", slog.String("err", err.Error()))
+	}
 	ch := w.pubsub.Channel()
 
 	for i := 0; i < w.concurrency; i++ {
@@ -49,10 +90,15 @@ func (w *worker) Start(ctx context.Context) {
 			w.subscribe(ctx, ch)
 		}()
 	}
+
+	if w.heartbeatInterval > 0 {
+		go w.reap(ctx)
+	}
 }
 
 func (w *worker) Stop() {
 	slog.Debug("Job queue shutdown started")
+	close(w.done)
 	_ = w.pubsub.Close()
 	slog.Debug("Job queue shutdown completed")
 }
@@ -65,34 +111,166 @@ func (w *worker) subscribe(ctx context.Context, ch <-chan *redis.Message) {
 	for msg := range ch {
 		chLog := slog.With(
 			slog.String("channel", msg.Channel),
-			slog.String("payload", msg.Payload),
+			slog.Int("payload_size", len(msg.Payload)),
 		)
 		chLog.Debug("Message subscribed")
 
-		if err := w.scanArtifact(ctx, msg); err != nil {
+		if err := w.scanArtifact(ctx, msg); errors.Is(err, errNewerEnvelope) {
+			// Every replica receives the message, so the upgraded ones pick up the scan job.
+			chLog.Info("Skip scan job published by a newer release", slog.String("err", err.Error()))
+			continue
+		} else if err != nil {
 			chLog.Error("Failed to scan artifact", slog.String("err", err.Error()))
 			continue
 		}
 	}
 }
 
-func (w *worker) scanArtifact(ctx context.Context, msg *redis.Message) error {
-	var job Job
-	if err := json.Unmarshal([]byte(msg.Payload), &job); err != nil {
+func (w *worker) scanArtifact(ctx context.Context, msg *redis.Message) (err error) {
+	job, err := decode([]byte(msg.Payload))
+	if err != nil {
 		return xerrors.Errorf("unmarshalling scan request: %w", err)
 	}
+	ctx = log.WithScanJobID(ctx, job.ID)
 
 	// Lock the job so that other workers won't process it.
 	nx, err := w.rdb.SetNX(ctx, redisLockKey(w.namespace, job.ID), "", 5*time.Minute).Result()
 	if err != nil {
 		return xerrors.Errorf("redis lock: %w", err)
 	} else if !nx {
-		slog.Debug("Skip the locked job", slog.String("scan_job_id", job.ID))
+		slog.DebugContext(ctx, "Skip the locked job")
 		return nil
 	}
 
-	slog.Debug("Executing enqueued scan job", slog.String("scan_job_id", job.ID))
-	return w.controller.Scan(ctx, job.ID, lo.FromPtr(job.Args.ScanRequest))
+	if err = w.rdb.ZRem(ctx, redisBacklogKey(w.namespace), job.ID).Err(); err != nil {
+		slog.WarnContext(ctx, "Error while removing scan job from backlog", slog.String("err", err.Error()))
+	}
+
+	if job.Args.ScanRequest == nil {
+		// ID-only envelopes leave the scan request and its trace context in the store.
+		if job.Args.ScanRequest, job.TraceContext, err = w.findScanRequest(ctx, job.ID); err != nil {
+			return err
+		}
+	}
+
+	ctx, span := tracing.Start(tracing.Extract(ctx, job.TraceContext), "ScanJob",
+		trace.WithSpanKind(trace.SpanKindConsumer), trace.WithAttributes(tracing.ScanJobID(job.ID)))
+	defer func() { tracing.End(span, err) }()
+
+	stopHeartbeat, err := w.startHeartbeat(ctx, job.ID)
+	if err != nil {
+		return xerrors.Errorf("starting heartbeat: %w", err)
+	}
+	defer stopHeartbeat()
+
+	slog.DebugContext(ctx, "Executing enqueued scan job")
+	metrics.ScanJobsStarted.Inc()
+	started := w.clock.Now()
+	w.trackStarted(ctx, job.ID, started)
+	defer w.untrackStarted(ctx, job.ID)
+	if err = w.controller.Scan(ctx, job.ID, lo.FromPtr(job.Args.ScanRequest)); err != nil {
+		return err
+	}
+
+	w.recordDuration(ctx, clock.Since(w.clock, started))
+	return nil
+}
+
+// findScanRequest returns the scan request of the given scan job along with its trace context.
+func (w *worker) findScanRequest(ctx context.Context, scanJobID string) (*harbor.ScanRequest, map[string]string, error) {
+	scanJob, err := w.store.Get(ctx, scanJobID)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("getting scan job: %w", err)
+	}
+	if scanJob == nil || scanJob.Request == nil {
+		return nil, nil, xerrors.Errorf("cannot find scan request of scan job: %s", scanJobID)
+	}
+	return scanJob.Request, scanJob.TraceContext, nil
+}
+
+// startHeartbeat registers the scan job as in-flight and keeps refreshing its heartbeat until the returned
+// function is called. Only the scan job identifier is registered, and the scan request is looked up in the
+// store if the job is requeued because this worker died, which keeps the registry credentials out of Redis.
+// Each heartbeat also extends the TTL of the scan job, which is reset to the configured TTL once it completes.
+func (w *worker) startHeartbeat(ctx context.Context, scanJobID string) (func(), error) {
+	if w.heartbeatInterval <= 0 {
+		return func() {}, nil
+	}
+
+	err := w.rdb.ZAdd(ctx, redisInFlightKey(w.namespace), redis.Z{Score: float64(w.clock.Now().UnixMilli()), Member: scanJobID}).Err()
+	if err != nil {
+		return nil, err
+	}
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(w.heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				// XX makes sure that a job which has already been taken over by another replica is not resurrected.
+				if err := w.rdb.ZAddXX(ctx, redisInFlightKey(w.namespace), redis.Z{
+					Score:  float64(w.clock.Now().UnixMilli()),
+					Member: scanJobID,
+				}).Err(); err != nil {
+					slog.WarnContext(ctx, "Error while sending heartbeat", slog.String("err", err.Error()))
+				}
+				// The scan job must outlive the stall timeout, after which the reaper takes it over.
+				if err := w.store.Extend(ctx, scanJobID, w.stallTimeout); err != nil {
+					slog.WarnContext(ctx, "Error while extending scan job TTL", slog.String("err", err.Error()))
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-stopped
+		_, err := w.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.ZRem(ctx, redisInFlightKey(w.namespace), scanJobID)
+			pipe.HDel(ctx, redisPayloadsKey(w.namespace), scanJobID)
+			pipe.HDel(ctx, redisTakeoversKey(w.namespace), scanJobID)
+			return nil
+		})
+		if err != nil {
+			slog.WarnContext(ctx, "Error while unregistering in-flight scan job", slog.String("err", err.Error()))
+		}
+	}, nil
+}
+
+// trackStarted records when the scan job was started, which the enqueuer uses to estimate the remaining time of
+// running scan jobs.
+func (w *worker) trackStarted(ctx context.Context, scanJobID string, started time.Time) {
+	if err := w.rdb.ZAdd(ctx, redisStartedKey(w.namespace), redis.Z{
+		Score:  float64(started.UnixMilli()),
+		Member: scanJobID,
+	}).Err(); err != nil {
+		slog.WarnContext(ctx, "Error while recording scan job start", slog.String("err", err.Error()))
+	}
+}
+
+func (w *worker) untrackStarted(ctx context.Context, scanJobID string) {
+	if err := w.rdb.ZRem(ctx, redisStartedKey(w.namespace), scanJobID).Err(); err != nil {
+		slog.WarnContext(ctx, "Error while removing scan job start", slog.String("err", err.Error()))
+	}
+}
+
+// recordDuration keeps the most recent scan durations, which the enqueuer uses to estimate wait times.
+func (w *worker) recordDuration(ctx context.Context, duration time.Duration) {
+	key := redisDurationsKey(w.namespace)
+	_, err := w.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, key, duration.Milliseconds())
+		pipe.LTrim(ctx, key, 0, durationSamples-1)
+		return nil
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "Error while recording scan duration", slog.String("err", err.Error()))
+	}
 }
 
 func redisLockKey(namespace, jobID string) string {
@@ -0,0 +1,76 @@
+package redisx
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/xerrors"
+)
+
+// IsCluster returns true if the given client is connected to a Redis Cluster.
+func IsCluster(rdb redis.UniversalClient) bool {
+	_, ok := rdb.(*redis.ClusterClient)
+	return ok
+}
+
+// HashTag returns the given namespace wrapped in a hash tag, e.g. `{harbor.scanner.tunnel:data-store}`, unless it
+// already holds one. Redis Cluster only hashes the hash tag of keys, hence all keys of a hash-tagged namespace are
+// kept on one slot, which multi-key commands, transactions and pipelines of the adapter require.
+func HashTag(namespace string) string {
+	if start := strings.IndexByte(namespace, '{'); start >= 0 {
+		if end := strings.IndexByte(namespace[start+1:], '}'); end > 0 {
+			return namespace
+		}
+	}
+	return "{" + namespace + "}"
+}
+
+// Scan calls the given function with each batch of the keys matching the given pattern, and stops at its first
+// error. The keys of a Redis Cluster are scanned on each master, one at a time, as SCAN only iterates the keys of
+// the node it is sent to.
+func Scan(ctx context.Context, rdb redis.UniversalClient, match string, count int64, fn func(keys []string) error) error {
+	cluster, ok := rdb.(*redis.ClusterClient)
+	if !ok {
+		return scan(ctx, rdb, match, count, fn)
+	}
+
+	// ForEachMaster calls the function concurrently.
+	var mu sync.Mutex
+	return cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return scan(ctx, client, match, count, fn)
+	})
+}
+
+func scan(ctx context.Context, rdb redis.Cmdable, match string, count int64, fn func(keys []string) error) error {
+	var cursor uint64
+	for {
+		keys, next, err := rdb.Scan(ctx, cursor, match, count).Result()
+		if err != nil {
+			return xerrors.Errorf("scanning keys: %w", err)
+		}
+		if len(keys) > 0 {
+			if err = fn(keys); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// Ping checks the connection to Redis. Every shard of a Redis Cluster is pinged, so that an unreachable node fails
+// the check even though the commands sent to the other nodes succeed.
+func Ping(ctx context.Context, rdb redis.UniversalClient) error {
+	if cluster, ok := rdb.(*redis.ClusterClient); ok {
+		return cluster.ForEachShard(ctx, func(ctx context.Context, client *redis.Client) error {
+			return client.Ping(ctx).Err()
+		})
+	}
+	return rdb.Ping(ctx).Err()
+}
@@ -0,0 +1,32 @@
+package redisx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashTag(t *testing.T) {
+	testCases := []struct {
+		namespace         string
+		expectedNamespace string
+	}{
+		{
+			namespace:         "harbor.scanner.tunnel:data-store",
+			expectedNamespace: "{harbor.scanner.tunnel:data-store}",
+		},
+		{
+			namespace:         "{harbor}.scanner.tunnel:data-store",
+			expectedNamespace: "{harbor}.scanner.tunnel:data-store",
+		},
+		{
+			namespace:         "harbor{}:data-store",
+			expectedNamespace: "{harbor{}:data-store}",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.namespace, func(t *testing.T) {
+			assert.Equal(t, tc.expectedNamespace, HashTag(tc.namespace))
+		})
+	}
+}
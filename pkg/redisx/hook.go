@@ -0,0 +1,113 @@
+package redisx
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tracing"
+)
+
+// CommandDuration is the histogram of the durations of the Redis commands and pipelines sent by the
+// clients constructed with NewClient.
+var CommandDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "scanner_redis_command_duration_seconds",
+	Help:    "Duration of Redis commands and pipelines, including the round trip to the server.",
+	Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+}, []string{"command", "status"})
+
+// pipelineCommand labels the durations of pipelines and transactions.
+const pipelineCommand = "pipeline"
+
+// attributePipelineSize is the span attribute holding the number of commands sent at once.
+const attributePipelineSize = attribute.Key("db.redis.pipeline_size")
+
+// instrumentationHook measures the duration of Redis commands, which tells apart latency spikes
+// originating in Redis from the ones originating in the scanner, and traces them within the traced scan requests.
+type instrumentationHook struct {
+	duration      *prometheus.HistogramVec
+	slowThreshold time.Duration
+}
+
+// NewInstrumentationHook constructs a redis.Hook, which records the duration of Redis commands and pipelines
+// in the given histogram, and logs the ones taking longer than the given threshold. Slow commands are not
+// logged if the threshold is zero. Commands and pipelines sent with a traced context get a child span of its span,
+// whereas the other ones, e.g. the ones of the job queue waiting for scan jobs, are not traced.
+func NewInstrumentationHook(duration *prometheus.HistogramVec, slowThreshold time.Duration) redis.Hook {
+	return &instrumentationHook{
+		duration:      duration,
+		slowThreshold: slowThreshold,
+	}
+}
+
+func (h *instrumentationHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (h *instrumentationHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := h.start(ctx, cmd.Name(), 1)
+		started := time.Now()
+		err := next(ctx, cmd)
+		h.observe(cmd.Name(), 1, time.Since(started), err)
+		end(span, err)
+		return err
+	}
+}
+
+func (h *instrumentationHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx, span := h.start(ctx, pipelineCommand, len(cmds))
+		started := time.Now()
+		err := next(ctx, cmds)
+		h.observe(pipelineCommand, len(cmds), time.Since(started), err)
+		end(span, err)
+		return err
+	}
+}
+
+// start starts a span for the given command, or returns nil if the given context is not traced.
+func (h *instrumentationHook) start(ctx context.Context, command string, size int) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, nil
+	}
+	// The statement is not recorded, as its arguments may hold scan requests with registry credentials.
+	return tracing.Start(ctx, "Redis."+command, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemRedis, semconv.DBOperation(command), attributePipelineSize.Int(size)))
+}
+
+// end ends the given span, if any, which is marked as failed if the given error is not a missing key.
+func end(span trace.Span, err error) {
+	if span == nil {
+		return
+	}
+	if errors.Is(err, redis.Nil) {
+		err = nil
+	}
+	tracing.End(span, err)
+}
+
+func (h *instrumentationHook) observe(command string, size int, duration time.Duration, err error) {
+	status := "ok"
+	// A missing key is a regular reply rather than a failure.
+	if err != nil && !errors.Is(err, redis.Nil) {
+		status = "error"
+	}
+	h.duration.WithLabelValues(command, status).Observe(duration.Seconds())
+
+	if h.slowThreshold > 0 && duration >= h.slowThreshold {
+		// Arguments are not logged, as they may hold scan requests with registry credentials.
+		slog.Warn("Slow Redis command", slog.String("command", command), slog.Int("size", size),
+			slog.Duration("duration", duration), slog.Duration("threshold", h.slowThreshold))
+	}
+}
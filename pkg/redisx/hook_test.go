@@ -0,0 +1,100 @@
+package redisx
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestInstrumentationHook(t *testing.T) {
+	ctx := context.TODO()
+
+	t.Run("Should record duration of commands by status", func(t *testing.T) {
+		duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "test"}, []string{"command", "status"})
+		hook := NewInstrumentationHook(duration, 0)
+
+		process := hook.ProcessHook(func(ctx context.Context, cmd redis.Cmder) error {
+			if cmd.Name() == "hget" {
+				return redis.Nil
+			}
+			return errors.New("connection refused")
+		})
+
+		assert.Equal(t, redis.Nil, process(ctx, redis.NewStringCmd(ctx, "hget", "key", "field")))
+		assert.EqualError(t, process(ctx, redis.NewStatusCmd(ctx, "set", "key", "value")), "connection refused")
+
+		assert.Equal(t, 2, testutil.CollectAndCount(duration))
+		assert.True(t, duration.DeleteLabelValues("hget", "ok"))
+		assert.True(t, duration.DeleteLabelValues("set", "error"))
+	})
+
+	t.Run("Should record duration of pipelines", func(t *testing.T) {
+		duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "test"}, []string{"command", "status"})
+		hook := NewInstrumentationHook(duration, 0)
+
+		process := hook.ProcessPipelineHook(func(ctx context.Context, cmds []redis.Cmder) error {
+			return nil
+		})
+
+		assert.NoError(t, process(ctx, []redis.Cmder{
+			redis.NewIntCmd(ctx, "zadd", "key", 1, "member"),
+			redis.NewIntCmd(ctx, "publish", "channel", "message"),
+		}))
+
+		assert.Equal(t, 1, testutil.CollectAndCount(duration))
+		assert.True(t, duration.DeleteLabelValues("pipeline", "ok"))
+	})
+
+	t.Run("Should trace commands and pipelines within traced context", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		otel.SetTracerProvider(provider)
+		defer otel.SetTracerProvider(sdktrace.NewTracerProvider())
+
+		duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "test"}, []string{"command", "status"})
+		hook := NewInstrumentationHook(duration, 0)
+		process := hook.ProcessHook(func(ctx context.Context, cmd redis.Cmder) error {
+			if cmd.Name() == "hget" {
+				return redis.Nil
+			}
+			return errors.New("connection refused")
+		})
+		processPipeline := hook.ProcessPipelineHook(func(ctx context.Context, cmds []redis.Cmder) error {
+			return nil
+		})
+
+		assert.Equal(t, redis.Nil, process(ctx, redis.NewStringCmd(ctx, "hget", "key", "field")))
+		assert.Empty(t, recorder.Ended(), "commands sent without traced context should not be traced")
+
+		scanCtx, scan := provider.Tracer("test").Start(ctx, "ScanJob")
+		assert.Equal(t, redis.Nil, process(scanCtx, redis.NewStringCmd(scanCtx, "hget", "key", "field")))
+		assert.EqualError(t, process(scanCtx, redis.NewStatusCmd(scanCtx, "set", "key", "value")), "connection refused")
+		assert.NoError(t, processPipeline(scanCtx, []redis.Cmder{
+			redis.NewIntCmd(scanCtx, "zadd", "key", 1, "member"),
+			redis.NewIntCmd(scanCtx, "publish", "channel", "message"),
+		}))
+		scan.End()
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 4)
+		for _, span := range spans[:3] {
+			assert.Equal(t, scan.SpanContext().SpanID(), span.Parent().SpanID())
+		}
+		assert.Equal(t, "Redis.hget", spans[0].Name())
+		assert.Equal(t, codes.Unset, spans[0].Status().Code, "missing key should not fail span")
+		assert.Equal(t, "Redis.set", spans[1].Name())
+		assert.Equal(t, codes.Error, spans[1].Status().Code)
+		assert.Equal(t, "Redis.pipeline", spans[2].Name())
+		assert.Contains(t, spans[2].Attributes(), attributePipelineSize.Int(2))
+	})
+}
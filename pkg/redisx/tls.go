@@ -0,0 +1,50 @@
+package redisx
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+
+	"golang.org/x/xerrors"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+)
+
+// NewTLSConfig returns the TLS configuration of the connections to Redis, or nil if TLS is disabled.
+// Certificates are verified with the system roots and the certificates of the configured CA bundle, and
+// the configured client certificate, if any, is presented to Redis servers requiring mutual TLS.
+func NewTLSConfig(config etc.RedisPool) (*tls.Config, error) {
+	if !config.TLSEnabled {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: config.TLSInsecureSkipVerify,
+	}
+
+	if config.TLSCABundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(config.TLSCABundle)
+		if err != nil {
+			return nil, xerrors.Errorf("reading redis TLS CA bundle: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, xerrors.Errorf("no certificates in redis TLS CA bundle: %s", config.TLSCABundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if config.TLSCertificate != "" {
+		certificate, err := tls.LoadX509KeyPair(config.TLSCertificate, config.TLSKey)
+		if err != nil {
+			return nil, xerrors.Errorf("loading redis TLS certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,87 @@
+package redisx
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+)
+
+// writeCertificate writes a new self-signed certificate and its key to the given directory, and returns the
+// paths of the PEM files.
+func writeCertificate(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "harbor-scanner-tunnel"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile, keyFile = filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	certFile, keyFile := writeCertificate(t, t.TempDir())
+
+	t.Run("Should return nil when TLS is disabled", func(t *testing.T) {
+		tlsConfig, err := NewTLSConfig(etc.RedisPool{})
+		require.NoError(t, err)
+		assert.Nil(t, tlsConfig)
+	})
+
+	t.Run("Should load CA bundle and client certificate", func(t *testing.T) {
+		tlsConfig, err := NewTLSConfig(etc.RedisPool{
+			TLSEnabled:     true,
+			TLSCABundle:    certFile,
+			TLSCertificate: certFile,
+			TLSKey:         keyFile,
+		})
+		require.NoError(t, err)
+		assert.NotNil(t, tlsConfig.RootCAs)
+		assert.Len(t, tlsConfig.Certificates, 1)
+		assert.False(t, tlsConfig.InsecureSkipVerify)
+	})
+
+	t.Run("Should skip verification", func(t *testing.T) {
+		tlsConfig, err := NewTLSConfig(etc.RedisPool{TLSEnabled: true, TLSInsecureSkipVerify: true})
+		require.NoError(t, err)
+		assert.Nil(t, tlsConfig.RootCAs)
+		assert.True(t, tlsConfig.InsecureSkipVerify)
+	})
+
+	t.Run("Should return error when CA bundle holds no certificates", func(t *testing.T) {
+		_, err := NewTLSConfig(etc.RedisPool{TLSEnabled: true, TLSCABundle: keyFile})
+		assert.EqualError(t, err, "no certificates in redis TLS CA bundle: "+keyFile)
+	})
+
+	t.Run("Should return error when client key does not match certificate", func(t *testing.T) {
+		_, otherKeyFile := writeCertificate(t, t.TempDir())
+		_, err := NewTLSConfig(etc.RedisPool{TLSEnabled: true, TLSCertificate: certFile, TLSKey: otherKeyFile})
+		assert.EqualError(t, err, "loading redis TLS certificate: tls: private key does not match public key")
+	})
+}
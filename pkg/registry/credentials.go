@@ -0,0 +1,69 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/httpx"
+)
+
+// CredentialsRefresher wraps the Refresh method.
+// Refresh reports that the registry rejected the credentials of the given scan job, so that they get refreshed
+// before Harbor requests the scan again.
+type CredentialsRefresher interface {
+	Refresh(ctx context.Context, scanJobID string, req harbor.ScanRequest) error
+}
+
+// RefreshRequest is the payload posted to the credentials refresh hook. It never holds the rejected credentials.
+type RefreshRequest struct {
+	ScanJobID  string `json:"scan_job_id"`
+	Registry   string `json:"registry"`
+	Repository string `json:"repository"`
+	Digest     string `json:"digest"`
+}
+
+type refreshHook struct {
+	url    string
+	client *http.Client
+}
+
+// NewRefreshHook constructs a CredentialsRefresher, which posts a RefreshRequest to the given URL.
+func NewRefreshHook(url string) CredentialsRefresher {
+	return &refreshHook{
+		url:    url,
+		client: httpx.Client("credentials_refresh_hook", 30*time.Second),
+	}
+}
+
+func (h *refreshHook) Refresh(ctx context.Context, scanJobID string, req harbor.ScanRequest) error {
+	body, err := json.Marshal(RefreshRequest{
+		ScanJobID:  scanJobID,
+		Registry:   req.Registry.URL,
+		Repository: req.Artifact.Repository,
+		Digest:     req.Artifact.Digest,
+	})
+	if err != nil {
+		return fmt.Errorf("marshalling refresh request: %w", err)
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json")
+
+	res, err := h.client.Do(r)
+	if err != nil {
+		return fmt.Errorf("posting refresh request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("posting refresh request: unexpected status %d", res.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+)
+
+func TestRefreshHook_Refresh(t *testing.T) {
+	req := harbor.ScanRequest{
+		Registry: harbor.Registry{URL: "https://core.harbor.domain", Authorization: "Basic cm9ib3Q6czNjcmV0"},
+		Artifact: harbor.Artifact{Repository: "library/mongo", Digest: artifactDigest},
+	}
+
+	t.Run("Should post refresh request without credentials", func(t *testing.T) {
+		var body map[string]string
+		server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			res.WriteHeader(http.StatusAccepted)
+		}))
+		defer server.Close()
+
+		err := NewRefreshHook(server.URL).Refresh(context.Background(), "job:123", req)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			"scan_job_id": "job:123",
+			"registry":    "https://core.harbor.domain",
+			"repository":  "library/mongo",
+			"digest":      artifactDigest,
+		}, body)
+	})
+
+	t.Run("Should return error when hook fails", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+			res.WriteHeader(http.StatusBadGateway)
+		}))
+		defer server.Close()
+
+		err := NewRefreshHook(server.URL).Refresh(context.Background(), "job:123", req)
+		assert.EqualError(t, err, "posting refresh request: unexpected status 502")
+	})
+}
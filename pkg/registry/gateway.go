@@ -0,0 +1,124 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+)
+
+// Gateway relays the registry requests of Tunnel processes to the registries, adding the configured
+// RegistryHeaders. Tunnel tunnels HTTPS traffic through proxies, so headers cannot be added on the way. Instead,
+// Tunnel is pointed at a plain HTTP listener of the Gateway on the loopback interface for each registry, and the
+// Gateway establishes the TLS connections to the registry, whose certificates are verified as configured.
+//
+// Token endpoints and redirects of the registry host are rewritten to the listener, so that the requests for
+// tokens and blobs carry the headers too.
+type Gateway struct {
+	config etc.Tunnel
+
+	mu sync.Mutex
+	// servers are keyed by the URL of the registry, i.e. its scheme and host.
+	servers map[string]*gatewayServer
+	closed  bool
+}
+
+type gatewayServer struct {
+	address  string
+	listener net.Listener
+	server   *http.Server
+}
+
+// NewGateway constructs a Gateway, which starts listening for a registry on the first call to Address.
+func NewGateway(config etc.Tunnel) *Gateway {
+	return &Gateway{
+		config:  config,
+		servers: make(map[string]*gatewayServer),
+	}
+}
+
+// Address returns the host and port of the listener relaying requests to the given registry host, which is
+// reached over plain HTTP if plainHTTP is true.
+func (g *Gateway) Address(host string, plainHTTP bool) (string, error) {
+	target := &url.URL{Scheme: "https", Host: host}
+	if plainHTTP {
+		target.Scheme = "http"
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closed {
+		return "", errors.New("registry gateway closed")
+	}
+	if s, ok := g.servers[target.String()]; ok {
+		return s.address, nil
+	}
+
+	client, err := NewHTTPClient(g.config, host)
+	if err != nil {
+		return "", err
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+
+	s := &gatewayServer{address: listener.Addr().String(), listener: listener}
+	s.server = &http.Server{
+		Handler:           newGatewayHandler(target, s.address, client.Transport),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Registry gateway stopped", slog.String("registry", host), slog.String("err", err.Error()))
+		}
+	}()
+
+	slog.Debug("Started registry gateway", slog.String("registry", target.String()), slog.String("address", s.address))
+	g.servers[target.String()] = s
+	return s.address, nil
+}
+
+// Close stops all listeners.
+func (g *Gateway) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.closed = true
+	var errs []error
+	for _, s := range g.servers {
+		errs = append(errs, s.server.Shutdown(context.Background()))
+	}
+	return errors.Join(errs...)
+}
+
+// newGatewayHandler returns a reverse proxy relaying requests to the given registry, and rewriting the URLs of the
+// registry in responses to the given address of the listener.
+func newGatewayHandler(target *url.URL, address string, transport http.RoundTripper) http.Handler {
+	registryURL := target.String()
+	listenerURL := "http://" + address
+	return &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+			r.Out.Host = target.Host
+		},
+		Transport: transport,
+		ModifyResponse: func(res *http.Response) error {
+			for _, name := range []string{"Www-Authenticate", "Location"} {
+				for i, value := range res.Header[name] {
+					res.Header[name][i] = strings.ReplaceAll(value, registryURL, listenerURL)
+				}
+			}
+			return nil
+		},
+	}
+}
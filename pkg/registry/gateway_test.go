@@ -0,0 +1,98 @@
+package registry
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+)
+
+func TestGateway_Address(t *testing.T) {
+	var received http.Header
+	var receivedHost string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		received = req.Header.Clone()
+		receivedHost = req.Host
+		res.Header().Set("Www-Authenticate", `Bearer realm="https://`+req.Host+`/service/token",service="harbor-registry"`)
+		res.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	bundle := filepath.Join(t.TempDir(), "ca.crt")
+	require.NoError(t, os.WriteFile(bundle,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+
+	gateway := NewGateway(etc.Tunnel{
+		RegistryCABundles: []string{serverURL.Host + "=" + bundle},
+		RegistryHeaders:   []string{"X-Tenant=scanner"},
+	})
+	defer func() { _ = gateway.Close() }()
+
+	address, err := gateway.Address(serverURL.Host, false)
+	require.NoError(t, err)
+
+	t.Run("Should reuse the listener of a registry", func(t *testing.T) {
+		again, err := gateway.Address(serverURL.Host, false)
+		require.NoError(t, err)
+		assert.Equal(t, address, again)
+	})
+
+	t.Run("Should relay requests with the configured headers", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "http://"+address+"/v2/", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Basic cm9ib3Q6czNjcmV0")
+
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer func() { _ = res.Body.Close() }()
+
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+		assert.Equal(t, "scanner", received.Get("X-Tenant"))
+		assert.Equal(t, "Basic cm9ib3Q6czNjcmV0", received.Get("Authorization"))
+		assert.Equal(t, serverURL.Host, receivedHost)
+		assert.Equal(t, `Bearer realm="http://`+address+`/service/token",service="harbor-registry"`,
+			res.Header.Get("Www-Authenticate"))
+	})
+
+	t.Run("Should return error when closed", func(t *testing.T) {
+		require.NoError(t, gateway.Close())
+
+		_, err := gateway.Address("registry.internal", false)
+		assert.EqualError(t, err, "registry gateway closed")
+	})
+}
+
+func TestNewHTTPClient(t *testing.T) {
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		received = req.Header.Clone()
+	}))
+	defer server.Close()
+
+	client, err := NewHTTPClient(etc.Tunnel{
+		RegistryHeaders: []string{"X-Tenant=scanner", "Authorization=Bearer gateway"},
+	}, server.Listener.Addr().String())
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/v2/", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer s3cret")
+
+	res, err := client.Do(req)
+	require.NoError(t, err)
+	_ = res.Body.Close()
+
+	assert.Equal(t, "scanner", received.Get("X-Tenant"))
+	assert.Equal(t, "Bearer s3cret", received.Get("Authorization"), "configured headers must not replace credentials")
+	assert.Empty(t, req.Header.Get("X-Tenant"), "request must not be modified")
+}
@@ -0,0 +1,349 @@
+// Package registry downloads the SBOMs attached to artifacts in the registry, either as OCI referrers or with
+// the tag scheme of Cosign, so that artifacts can be scanned without pulling their images.
+package registry
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/httpx"
+)
+
+const (
+	mediaTypeOCIIndex    = "application/vnd.oci.image.index.v1+json"
+	mediaTypeOCIManifest = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeDSSE        = "application/vnd.dsse.envelope.v1+json"
+)
+
+// sbomMediaTypes are the media types of the SBOM formats Tunnel can scan.
+var sbomMediaTypes = []string{
+	"application/vnd.cyclonedx+json",
+	"application/spdx+json",
+	"text/spdx+json",
+}
+
+// sbomPredicateTypes are the in-toto predicate types of the attestations holding SBOMs Tunnel can scan.
+var sbomPredicateTypes = []string{
+	"https://cyclonedx.org/bom",
+	"https://spdx.dev/Document",
+}
+
+// cosignTagSuffixes are the suffixes of the tags Cosign attaches SBOMs and attestations with.
+var cosignTagSuffixes = []string{".sbom", ".att"}
+
+// maxSBOMSize bounds the size of downloaded manifests and SBOMs.
+const maxSBOMSize = 64 << 20
+
+// SBOMFetcher wraps the Fetch method.
+// Fetch returns the SBOM attached to the artifact of the given scan request, or nil if there is none.
+type SBOMFetcher interface {
+	Fetch(ctx context.Context, req harbor.ScanRequest) ([]byte, error)
+}
+
+type descriptor struct {
+	MediaType    string            `json:"mediaType"`
+	ArtifactType string            `json:"artifactType"`
+	Digest       string            `json:"digest"`
+	Annotations  map[string]string `json:"annotations"`
+}
+
+type index struct {
+	Manifests []descriptor `json:"manifests"`
+}
+
+type manifest struct {
+	Layers []descriptor `json:"layers"`
+}
+
+type envelope struct {
+	Payload string `json:"payload"`
+}
+
+type statement struct {
+	PredicateType string          `json:"predicateType"`
+	Predicate     json.RawMessage `json:"predicate"`
+}
+
+type sbomFetcher struct {
+	config etc.Tunnel
+
+	mu sync.Mutex
+	// clients are keyed by registry host, as each host may be verified with its own CA bundle.
+	clients map[string]*http.Client
+}
+
+// NewSBOMFetcher constructs an SBOMFetcher, which authenticates to the registry with the credentials of the
+// scan request. TLS certificates of the registry are verified as configured by the Insecure,
+// RegistryCABundles and InsecureRegistries settings.
+func NewSBOMFetcher(config etc.Tunnel) SBOMFetcher {
+	return &sbomFetcher{
+		config:  config,
+		clients: make(map[string]*http.Client),
+	}
+}
+
+func (f *sbomFetcher) Fetch(ctx context.Context, req harbor.ScanRequest) ([]byte, error) {
+	registryURL, err := url.Parse(req.Registry.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing registry URL: %w", err)
+	}
+	client, err := f.clientFor(registryURL.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	repository := strings.TrimSuffix(req.Registry.URL, "/") + "/v2/" + req.Artifact.Repository
+	r := &repositoryClient{client: client, url: repository, authorization: req.Registry.Authorization}
+
+	sbom, err := r.fetchFromReferrers(ctx, req.Artifact.Digest)
+	if err != nil || sbom != nil {
+		return sbom, err
+	}
+	return r.fetchFromCosignTags(ctx, req.Artifact.Digest)
+}
+
+// clientFor returns the client sending requests to the given registry host.
+func (f *sbomFetcher) clientFor(host string) (*http.Client, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if client, ok := f.clients[host]; ok {
+		return client, nil
+	}
+
+	client, err := NewHTTPClient(f.config, host)
+	if err != nil {
+		return nil, err
+	}
+	f.clients[host] = client
+	return client, nil
+}
+
+// NewHTTPClient constructs a client sending requests to the given registry host, whose TLS certificates are
+// verified as configured by the Insecure, RegistryCABundles and InsecureRegistries settings. The configured
+// RegistryHeaders are added to each request. The durations of requests are recorded in httpx.RequestDuration.
+func NewHTTPClient(config etc.Tunnel, host string) (*http.Client, error) {
+	var tlsConfig *tls.Config
+	switch bundle := config.RegistryCABundle(host); {
+	case config.IsInsecureRegistry(host):
+		tlsConfig = &tls.Config{InsecureSkipVerify: true}
+	case bundle != "":
+		pool, err := certPool(bundle)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig = &tls.Config{RootCAs: pool}
+	}
+
+	roundTripper := httpx.Instrument(httpx.RequestDuration, "registry", httpx.NewTransport(tlsConfig))
+	if headers := config.RegistryHeaderValues(); len(headers) > 0 {
+		roundTripper = &headerTransport{RoundTripper: roundTripper, headers: headers}
+	}
+	return &http.Client{Transport: roundTripper, Timeout: time.Minute}, nil
+}
+
+// headerTransport adds the given headers to the requests it sends, unless they are already set, so that the
+// configured headers never replace the credentials of a request.
+type headerTransport struct {
+	http.RoundTripper
+	headers map[string]string
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, value := range t.headers {
+		if req.Header.Get(name) == "" {
+			req.Header.Set(name, value)
+		}
+	}
+	return t.RoundTripper.RoundTrip(req)
+}
+
+// certPool returns the system roots with the certificates of the given CA bundle added.
+func certPool(bundle string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	pem, err := os.ReadFile(bundle)
+	if err != nil {
+		return nil, fmt.Errorf("reading registry CA bundle: %w", err)
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates in registry CA bundle: %s", bundle)
+	}
+	return pool, nil
+}
+
+// repositoryClient sends the requests of a single fetch to the given repository.
+type repositoryClient struct {
+	client        *http.Client
+	url           string
+	authorization string
+}
+
+// fetchFromReferrers looks up the SBOMs attached to the given digest with the OCI referrers API.
+func (r *repositoryClient) fetchFromReferrers(ctx context.Context, dgst string) ([]byte, error) {
+	b, err := r.get(ctx, "/referrers/"+dgst, mediaTypeOCIIndex)
+	if err != nil || b == nil {
+		return nil, err
+	}
+
+	var referrers index
+	if err = json.Unmarshal(b, &referrers); err != nil {
+		return nil, fmt.Errorf("decoding referrers: %w", err)
+	}
+	for _, d := range referrers.Manifests {
+		if !slices.Contains(sbomMediaTypes, d.ArtifactType) && d.ArtifactType != mediaTypeDSSE {
+			continue
+		}
+		if sbom, err := r.fetchFromManifest(ctx, d.Digest); err != nil || sbom != nil {
+			return sbom, err
+		}
+	}
+	return nil, nil
+}
+
+// fetchFromCosignTags looks up the SBOMs attached to the given digest with the tag scheme of Cosign, which
+// predates the OCI referrers API.
+func (r *repositoryClient) fetchFromCosignTags(ctx context.Context, dgst string) ([]byte, error) {
+	algorithm, encoded, ok := strings.Cut(dgst, ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid digest: %s", dgst)
+	}
+	for _, suffix := range cosignTagSuffixes {
+		if sbom, err := r.fetchFromManifest(ctx, algorithm+"-"+encoded+suffix); err != nil || sbom != nil {
+			return sbom, err
+		}
+	}
+	return nil, nil
+}
+
+// fetchFromManifest returns the SBOM held by the layers of the manifest with the given reference, or nil if
+// there is no such manifest or none of its layers holds an SBOM.
+func (r *repositoryClient) fetchFromManifest(ctx context.Context, reference string) ([]byte, error) {
+	b, err := r.get(ctx, "/manifests/"+reference, mediaTypeOCIManifest)
+	if err != nil || b == nil {
+		return nil, err
+	}
+
+	var m manifest
+	if err = json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("decoding manifest %s: %w", reference, err)
+	}
+	for _, layer := range m.Layers {
+		switch {
+		case slices.Contains(sbomMediaTypes, layer.MediaType):
+			return r.getBlob(ctx, layer.Digest)
+		case layer.MediaType == mediaTypeDSSE:
+			// Attestations of other predicate types, e.g. vulnerability scans, are skipped without being downloaded.
+			if predicateType, ok := layer.Annotations["predicateType"]; ok && !slices.Contains(sbomPredicateTypes, predicateType) {
+				continue
+			}
+			b, err := r.getBlob(ctx, layer.Digest)
+			if err != nil {
+				return nil, err
+			}
+			if sbom, err := predicateOf(b); err != nil || sbom != nil {
+				return sbom, err
+			}
+		}
+	}
+	return nil, nil
+}
+
+// getBlob downloads the blob with the given digest and verifies its content.
+func (r *repositoryClient) getBlob(ctx context.Context, dgst string) ([]byte, error) {
+	expected, err := digest.Parse(dgst)
+	if err != nil {
+		return nil, fmt.Errorf("invalid digest: %s", dgst)
+	}
+	b, err := r.get(ctx, "/blobs/"+dgst, "*/*")
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return nil, fmt.Errorf("blob not found: %s", dgst)
+	}
+	if actual := expected.Algorithm().FromBytes(b); actual != expected {
+		return nil, fmt.Errorf("blob digest mismatch: expected %s, got %s", expected, actual)
+	}
+	return b, nil
+}
+
+// get returns the body of the response to a GET request of the given path of the repository, or nil if the
+// registry responds with the 404 status.
+func (r *repositoryClient) get(ctx context.Context, path, accept string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	if r.authorization != "" {
+		req.Header.Set("Authorization", r.authorization)
+	}
+
+	res, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("getting %s: %w", path, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("getting %s: unexpected status %d", path, res.StatusCode)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(res.Body, maxSBOMSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if len(b) > maxSBOMSize {
+		return nil, fmt.Errorf("reading %s: exceeds %d bytes", path, maxSBOMSize)
+	}
+	return b, nil
+}
+
+// predicateOf returns the SBOM held by the in-toto statement of the given DSSE envelope, or nil if the
+// statement holds another kind of predicate.
+func predicateOf(b []byte) ([]byte, error) {
+	var e envelope
+	if err := json.Unmarshal(b, &e); err != nil {
+		return nil, fmt.Errorf("decoding attestation: %w", err)
+	}
+	payload, err := base64.StdEncoding.DecodeString(e.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("decoding attestation payload: %w", err)
+	}
+
+	var s statement
+	if err = json.Unmarshal(payload, &s); err != nil {
+		return nil, fmt.Errorf("decoding attestation statement: %w", err)
+	}
+	if !slices.Contains(sbomPredicateTypes, s.PredicateType) {
+		return nil, nil
+	}
+	if len(s.Predicate) == 0 {
+		return nil, errors.New("blank SBOM in attestation")
+	}
+	return s.Predicate, nil
+}
@@ -0,0 +1,201 @@
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+)
+
+const artifactDigest = "sha256:917f5b7f4bef1b35ee90f03033f33a81002511c1e0767fd44276d4bd9cd2fa8e"
+
+// fakeRegistry serves the given manifests, keyed by reference, and blobs of the library/mongo repository.
+type fakeRegistry struct {
+	referrers *index
+	manifests map[string]manifest
+	blobs     map[string][]byte
+}
+
+func (r *fakeRegistry) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.Header.Get("Authorization") != "Bearer s3cret" {
+		res.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, "/v2/library/mongo")
+	if path == "/referrers/"+artifactDigest && r.referrers != nil {
+		_ = json.NewEncoder(res).Encode(r.referrers)
+		return
+	}
+	if reference, ok := strings.CutPrefix(path, "/manifests/"); ok {
+		if m, ok := r.manifests[reference]; ok {
+			_ = json.NewEncoder(res).Encode(m)
+			return
+		}
+	}
+	if dgst, ok := strings.CutPrefix(path, "/blobs/"); ok {
+		if b, ok := r.blobs[dgst]; ok {
+			_, _ = res.Write(b)
+			return
+		}
+	}
+	res.WriteHeader(http.StatusNotFound)
+}
+
+func TestSBOMFetcher_Fetch(t *testing.T) {
+	sbom := []byte(`{"bomFormat":"CycloneDX","specVersion":"1.5","components":[]}`)
+	sbomDigest := digest.FromBytes(sbom).String()
+
+	statement, err := json.Marshal(map[string]interface{}{
+		"_type":         "https://in-toto.io/Statement/v0.1",
+		"predicateType": "https://cyclonedx.org/bom",
+		"predicate":     json.RawMessage(sbom),
+	})
+	require.NoError(t, err)
+	attestation, err := json.Marshal(map[string]string{
+		"payloadType": "application/vnd.in-toto+json",
+		"payload":     base64.StdEncoding.EncodeToString(statement),
+	})
+	require.NoError(t, err)
+	attestationDigest := digest.FromBytes(attestation).String()
+
+	spdx := []byte(`{"spdxVersion": "SPDX-2.3"}`)
+
+	sbomManifest := manifest{Layers: []descriptor{{MediaType: "application/vnd.cyclonedx+json", Digest: sbomDigest}}}
+
+	testCases := []struct {
+		name          string
+		registry      *fakeRegistry
+		expectedSBOM  []byte
+		expectedError string
+	}{
+		{
+			name: "Should fetch SBOM referrer",
+			registry: &fakeRegistry{
+				referrers: &index{Manifests: []descriptor{
+					{ArtifactType: "application/vnd.dev.cosign.artifact.sig.v1+json", Digest: "sha256:5191"},
+					{ArtifactType: "application/vnd.cyclonedx+json", Digest: "sha256:ab12"},
+				}},
+				manifests: map[string]manifest{"sha256:ab12": sbomManifest},
+				blobs:     map[string][]byte{sbomDigest: sbom},
+			},
+			expectedSBOM: sbom,
+		},
+		{
+			name: "Should fetch SBOM attached with Cosign when registry does not support referrers",
+			registry: &fakeRegistry{
+				manifests: map[string]manifest{
+					"sha256-917f5b7f4bef1b35ee90f03033f33a81002511c1e0767fd44276d4bd9cd2fa8e.sbom": sbomManifest,
+				},
+				blobs: map[string][]byte{sbomDigest: sbom},
+			},
+			expectedSBOM: sbom,
+		},
+		{
+			name: "Should fetch SBOM attested with Cosign",
+			registry: &fakeRegistry{
+				manifests: map[string]manifest{
+					"sha256-917f5b7f4bef1b35ee90f03033f33a81002511c1e0767fd44276d4bd9cd2fa8e.att": {Layers: []descriptor{
+						{
+							MediaType:   mediaTypeDSSE,
+							Digest:      "sha256:0000",
+							Annotations: map[string]string{"predicateType": "https://slsa.dev/provenance/v0.2"},
+						},
+						{
+							MediaType:   mediaTypeDSSE,
+							Digest:      attestationDigest,
+							Annotations: map[string]string{"predicateType": "https://cyclonedx.org/bom"},
+						},
+					}},
+				},
+				blobs: map[string][]byte{attestationDigest: attestation},
+			},
+			expectedSBOM: sbom,
+		},
+		{
+			name:     "Should return nil when artifact has no SBOM",
+			registry: &fakeRegistry{referrers: &index{Manifests: []descriptor{}}},
+		},
+		{
+			name: "Should return error when SBOM does not match its digest",
+			registry: &fakeRegistry{
+				referrers: &index{Manifests: []descriptor{{ArtifactType: "application/spdx+json", Digest: "sha256:ab12"}}},
+				manifests: map[string]manifest{"sha256:ab12": sbomManifest},
+				blobs:     map[string][]byte{sbomDigest: spdx},
+			},
+			expectedError: "blob digest mismatch: expected " + sbomDigest + ", got " + digest.FromBytes(spdx).String(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(tc.registry)
+			defer server.Close()
+
+			sbom, err := NewSBOMFetcher(etc.Tunnel{}).Fetch(context.Background(), harbor.ScanRequest{
+				Registry: harbor.Registry{URL: server.URL, Authorization: "Bearer s3cret"},
+				Artifact: harbor.Artifact{Repository: "library/mongo", Digest: artifactDigest},
+			})
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedSBOM, sbom)
+		})
+	}
+
+	t.Run("Should return error when registry rejects credentials", func(t *testing.T) {
+		server := httptest.NewServer(&fakeRegistry{})
+		defer server.Close()
+
+		_, err := NewSBOMFetcher(etc.Tunnel{}).Fetch(context.Background(), harbor.ScanRequest{
+			Registry: harbor.Registry{URL: server.URL, Authorization: "Bearer expired"},
+			Artifact: harbor.Artifact{Repository: "library/mongo", Digest: artifactDigest},
+		})
+		assert.EqualError(t, err, "getting /referrers/"+artifactDigest+": unexpected status 401")
+	})
+
+	t.Run("Should verify registry with its CA bundle", func(t *testing.T) {
+		server := httptest.NewTLSServer(&fakeRegistry{referrers: &index{Manifests: []descriptor{}}})
+		defer server.Close()
+		serverURL, err := url.Parse(server.URL)
+		require.NoError(t, err)
+
+		bundle := filepath.Join(t.TempDir(), "ca.crt")
+		require.NoError(t, os.WriteFile(bundle,
+			pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+
+		req := harbor.ScanRequest{
+			Registry: harbor.Registry{URL: server.URL, Authorization: "Bearer s3cret"},
+			Artifact: harbor.Artifact{Repository: "library/mongo", Digest: artifactDigest},
+		}
+
+		_, err = NewSBOMFetcher(etc.Tunnel{}).Fetch(context.Background(), req)
+		assert.ErrorContains(t, err, "certificate signed by unknown authority")
+
+		_, err = NewSBOMFetcher(etc.Tunnel{
+			RegistryCABundles: []string{serverURL.Host + "=" + bundle},
+		}).Fetch(context.Background(), req)
+		assert.NoError(t, err)
+
+		_, err = NewSBOMFetcher(etc.Tunnel{
+			InsecureRegistries: []string{serverURL.Hostname()},
+		}).Fetch(context.Background(), req)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,91 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/httpx"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+)
+
+// attributeCleanScan is the vendor attribute of the complete scan reports listing no vulnerability, which holds
+// the CleanScan.
+const attributeCleanScan = "clean_scan"
+
+// CleanScan tells that the image was scanned successfully and no vulnerability was found, so that an empty report
+// is not mistaken for a silent failure of the scanner.
+type CleanScan struct {
+	// VulnerabilityDB is the vulnerability database the packages of the image were matched against, nil if its
+	// version could not be retrieved.
+	VulnerabilityDB *VulnerabilityDB `json:"vulnerability_db,omitempty"`
+}
+
+// VulnerabilityDB describes the version of a vulnerability database.
+type VulnerabilityDB struct {
+	UpdatedAt  time.Time `json:"updated_at"`
+	NextUpdate time.Time `json:"next_update"`
+}
+
+// ToVulnerabilityDB returns the version of the vulnerability database in the given VersionInfo, or nil if there
+// is none.
+func ToVulnerabilityDB(vi tunnel.VersionInfo) *VulnerabilityDB {
+	if vi.VulnerabilityDB == nil {
+		return nil
+	}
+	return &VulnerabilityDB{UpdatedAt: vi.VulnerabilityDB.UpdatedAt, NextUpdate: vi.VulnerabilityDB.NextUpdate}
+}
+
+// CleanScanEvent is the payload posted to the webhook notified of clean scans.
+type CleanScanEvent struct {
+	ScanJobID       string           `json:"scan_job_id"`
+	Artifact        harbor.Artifact  `json:"artifact"`
+	ScannedAt       time.Time        `json:"scanned_at"`
+	VulnerabilityDB *VulnerabilityDB `json:"vulnerability_db,omitempty"`
+}
+
+// CleanScanNotifier wraps the Notify method.
+// Notify tells that an artifact was scanned successfully and no vulnerability was found.
+type CleanScanNotifier interface {
+	Notify(ctx context.Context, event CleanScanEvent) error
+}
+
+type cleanScanWebhook struct {
+	url    string
+	client *http.Client
+}
+
+// NewCleanScanWebhook constructs a CleanScanNotifier, which posts each CleanScanEvent to the given URL.
+func NewCleanScanWebhook(url string) CleanScanNotifier {
+	return &cleanScanWebhook{
+		url:    url,
+		client: httpx.Client("clean_scan_webhook", 30*time.Second),
+	}
+}
+
+func (n *cleanScanWebhook) Notify(ctx context.Context, event CleanScanEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshalling clean scan event: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting clean scan event: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("posting clean scan event: unexpected status %d", res.StatusCode)
+	}
+	return nil
+}
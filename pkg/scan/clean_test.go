@@ -0,0 +1,59 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanScanWebhook_Notify(t *testing.T) {
+	event := CleanScanEvent{
+		ScanJobID: "job:123",
+		Artifact:  harbor.Artifact{Repository: "library/mongo", Digest: "sha256:6c3c"},
+		ScannedAt: time.Date(2024, 3, 18, 8, 0, 0, 0, time.UTC),
+		VulnerabilityDB: &VulnerabilityDB{
+			UpdatedAt:  time.Date(2024, 3, 18, 6, 0, 0, 0, time.UTC),
+			NextUpdate: time.Date(2024, 3, 18, 12, 0, 0, 0, time.UTC),
+		},
+	}
+
+	t.Run("Should post clean scan event", func(t *testing.T) {
+		var body map[string]interface{}
+		server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, http.MethodPost, req.Method)
+			assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+			assert.NoError(t, json.NewDecoder(req.Body).Decode(&body))
+			res.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		err := NewCleanScanWebhook(server.URL).Notify(context.Background(), event)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"scan_job_id": "job:123",
+			"artifact":    map[string]interface{}{"repository": "library/mongo", "digest": "sha256:6c3c"},
+			"scanned_at":  "2024-03-18T08:00:00Z",
+			"vulnerability_db": map[string]interface{}{
+				"updated_at":  "2024-03-18T06:00:00Z",
+				"next_update": "2024-03-18T12:00:00Z",
+			},
+		}, body)
+	})
+
+	t.Run("Should return error when webhook fails", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+			res.WriteHeader(http.StatusBadGateway)
+		}))
+		defer server.Close()
+
+		err := NewCleanScanWebhook(server.URL).Notify(context.Background(), event)
+		assert.EqualError(t, err, "posting clean scan event: unexpected status 502")
+	})
+}
@@ -3,13 +3,29 @@ package scan
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"log/slog"
+	"maps"
 	"strings"
+	"time"
 
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/clock"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/enrich"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/feature"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/log"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/metrics"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/notify"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/opa"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/policy"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/registry"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/shadow"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tracing"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/xerrors"
 )
 
@@ -18,29 +34,184 @@ type Controller interface {
 }
 
 type controller struct {
-	store       persistence.Store
+	store persistence.Store
+	// index finds the artifacts affected by vulnerabilities, nil if they are not indexed.
+	index       persistence.VulnerabilityIndex
+	sboms       persistence.SBOMStore
 	wrapper     tunnel.Wrapper
 	transformer Transformer
+	flags       feature.Flags
+	// lifetimes records when the vulnerabilities of artifacts were found, nil if they are not tracked.
+	lifetimes persistence.LifetimeStore
+	// registrySBOMs fetches the SBOMs attached to artifacts in the registry, nil if images are always analyzed.
+	registrySBOMs registry.SBOMFetcher
+	// credentials refreshes the credentials rejected by the registry, nil if rejections are only reported.
+	credentials registry.CredentialsRefresher
+	// shadow compares the reports with the reports of a secondary scanner, nil if the shadow mode is disabled.
+	shadow shadow.Comparator
+	// enrichers enrich the transformed reports in order before they are saved.
+	enrichers []enrich.Enricher
+	// reportPolicy post-processes the enriched reports before they are saved, nil if they are saved as is.
+	reportPolicy opa.Evaluator
+	// failures records the digests which cannot be scanned, nil if their requests are always scanned.
+	failures persistence.FailureIndex
+	// cleanScans is notified of the scans which found no vulnerability, nil if they are only reported.
+	cleanScans CleanScanNotifier
+	// notifiers are posted the summaries of the reports with vulnerabilities.
+	notifiers []notify.Notifier
+	// repositories normalizes the repositories of artifacts before they are pulled.
+	repositories harbor.RepositoryNormalizer
+	// clock times the scans and transformations observed in metrics.
+	clock clock.Clock
 }
 
-func NewController(store persistence.Store, wrapper tunnel.Wrapper, transformer Transformer) Controller {
-	return &controller{
+// Option customizes the controller constructed with NewController.
+type Option func(c *controller)
+
+// WithFeatureFlags restricts the subsystems of the controller to the projects they are enabled for
+// by the given Flags.
+func WithFeatureFlags(flags feature.Flags) Option {
+	return func(c *controller) {
+		c.flags = flags
+	}
+}
+
+// WithRegistrySBOMs scans the SBOMs attached to artifacts in the registry, which are fetched with the given
+// SBOMFetcher, rather than pulling and analyzing their images.
+func WithRegistrySBOMs(fetcher registry.SBOMFetcher) Option {
+	return func(c *controller) {
+		c.registrySBOMs = fetcher
+	}
+}
+
+// WithLifetimes tracks the lifetimes of the vulnerabilities of artifacts across the scans of their digests in the
+// given LifetimeStore, and reports when each vulnerability was first and last seen, in the projects the sbom feature
+// is enabled for.
+func WithLifetimes(store persistence.LifetimeStore) Option {
+	return func(c *controller) {
+		c.lifetimes = store
+	}
+}
+
+// WithCredentialsRefresher asks the given CredentialsRefresher to refresh the credentials of the scan jobs
+// failed because the registry rejected them.
+func WithCredentialsRefresher(refresher registry.CredentialsRefresher) Option {
+	return func(c *controller) {
+		c.credentials = refresher
+	}
+}
+
+// WithShadowComparator hands the reports of the finished scan jobs to the given Comparator, which compares
+// them with the reports of a secondary scanner. The reports returned to Harbor are never affected.
+func WithShadowComparator(comparator shadow.Comparator) Option {
+	return func(c *controller) {
+		c.shadow = comparator
+	}
+}
+
+// WithEnricher enriches the transformed reports with the given Enricher before they are saved. Enrichers are
+// applied in the order of the options.
+func WithEnricher(enricher enrich.Enricher) Option {
+	return func(c *controller) {
+		c.enrichers = append(c.enrichers, enricher)
+	}
+}
+
+// WithReportPolicy post-processes the enriched reports with the given Evaluator of a Rego policy before they are
+// saved, i.e. after all enrichers.
+func WithReportPolicy(evaluator opa.Evaluator) Option {
+	return func(c *controller) {
+		c.reportPolicy = evaluator
+	}
+}
+
+// WithFailureIndex records the permanent failures of scanning the digests of artifacts in the given FailureIndex,
+// so that requests to scan digests failing repeatedly fail fast.
+func WithFailureIndex(failures persistence.FailureIndex) Option {
+	return func(c *controller) {
+		c.failures = failures
+	}
+}
+
+// WithCleanScanNotifier notifies the given CleanScanNotifier of the artifacts scanned successfully without any
+// vulnerability found, in the projects the webhooks feature is enabled for.
+func WithCleanScanNotifier(notifier CleanScanNotifier) Option {
+	return func(c *controller) {
+		c.cleanScans = notifier
+	}
+}
+
+// WithReportNotifier posts the summaries of the reports with vulnerabilities to the given Notifier, in the projects
+// the webhooks feature is enabled for. It may be repeated to notify several chat services.
+func WithReportNotifier(notifier notify.Notifier) Option {
+	return func(c *controller) {
+		c.notifiers = append(c.notifiers, notifier)
+	}
+}
+
+// WithRepositoryNormalizer normalizes the repositories of artifacts with the given RepositoryNormalizer before
+// they are pulled, instead of the standard normalization. Reports keep the repositories sent by Harbor.
+func WithRepositoryNormalizer(normalizer harbor.RepositoryNormalizer) Option {
+	return func(c *controller) {
+		c.repositories = normalizer
+	}
+}
+
+// WithClock times scans with the given Clock rather than the system time.
+func WithClock(clk clock.Clock) Option {
+	return func(c *controller) {
+		c.clock = clk
+	}
+}
+
+// NewController constructs a Controller. If the given SBOMStore is not nil, the SBOM of each scanned
+// artifact is stored, and subsequent scans of the same digest are matched against the stored SBOM.
+func NewController(store persistence.Store, index persistence.VulnerabilityIndex, sboms persistence.SBOMStore, wrapper tunnel.Wrapper, transformer Transformer, opts ...Option) Controller {
+	c := &controller{
 		store:       store,
+		index:       index,
+		sboms:       sboms,
 		wrapper:     wrapper,
 		transformer: transformer,
+		flags:       feature.AllEnabled(),
+		clock:       clock.System,
 	}
+	c.repositories, _ = harbor.NewRepositoryNormalizer(harbor.RepositoryNormalizationStandard, nil)
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 func (c *controller) Scan(ctx context.Context, scanJobID string, request harbor.ScanRequest) error {
+	ctx = log.WithDigest(log.WithScanJobID(ctx, scanJobID), request.Artifact.Digest)
+	started := c.clock.Now()
 	if err := c.scan(ctx, scanJobID, request); err != nil {
-		slog.Error("Scan failed", slog.String("err", err.Error()))
+		c.observeScan(started, metrics.ResultFailed)
+		metrics.ScanJobsFailed.WithLabelValues(tunnel.ErrorClass(err)).Inc()
+		tracing.Fail(ctx, err)
+		slog.ErrorContext(ctx, "Scan failed", slog.String("err", err.Error()))
 		if err = c.store.UpdateStatus(ctx, scanJobID, job.Failed, err.Error()); err != nil {
 			return xerrors.Errorf("updating scan job as failed: %v", err)
 		}
+		return nil
 	}
+	c.observeScan(started, metrics.ResultSucceeded)
 	return nil
 }
 
+// pullRequest returns a copy of the given request with the repository of its artifact normalized for pulling it.
+func (c *controller) pullRequest(req harbor.ScanRequest) harbor.ScanRequest {
+	req.Artifact.Repository = c.repositories.Normalize(req.Registry.URL, req.Artifact.Repository)
+	return req
+}
+
+// observeScan counts the scan job started at the given time as completed with the given result.
+func (c *controller) observeScan(started time.Time, result string) {
+	metrics.ScanJobsCompleted.WithLabelValues(result).Inc()
+	metrics.ScanDuration.WithLabelValues(result).Observe(clock.Since(c.clock, started).Seconds())
+}
+
 func (c *controller) scan(ctx context.Context, scanJobID string, req harbor.ScanRequest) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -53,7 +224,7 @@ func (c *controller) scan(ctx context.Context, scanJobID string, req harbor.Scan
 		return xerrors.Errorf("updating scan job status: %v", err)
 	}
 
-	imageRef, insecureRegistry, err := req.GetImageRef()
+	imageRef, insecureRegistry, err := c.pullRequest(req).GetImageRef()
 	if err != nil {
 		return err
 	}
@@ -63,22 +234,281 @@ func (c *controller) scan(ctx context.Context, scanJobID string, req harbor.Scan
 		return err
 	}
 
-	scanReport, err := c.wrapper.Scan(tunnel.ImageRef{Name: imageRef, Auth: auth, Insecure: insecureRegistry})
+	ref := tunnel.ImageRef{Name: imageRef, Auth: auth, Insecure: insecureRegistry, Timeout: req.Timeout()}
+	if req.HasCapability(harbor.CapabilityTypeSBOM) {
+		if err = c.generateSBOM(ctx, scanJobID, req, ref); err != nil {
+			return err
+		}
+	}
+	if !req.HasCapability(harbor.CapabilityTypeVulnerability) {
+		if err = c.store.UpdateStatus(ctx, scanJobID, job.Finished); err != nil {
+			return xerrors.Errorf("updating scan job status: %v", err)
+		}
+		return
+	}
+
+	_, span := tracing.Start(ctx, "Tunnel.Scan", trace.WithAttributes(
+		attribute.String("artifact.repository", req.Artifact.Repository),
+		attribute.String("artifact.digest", req.Artifact.Digest),
+	))
+	scanReport, err := c.scanArtifact(ctx, req, ref)
+	tracing.End(span, err)
 	if err != nil {
+		if errors.Is(err, tunnel.ErrRegistryUnauthorized) {
+			c.reportUnauthorized(ctx, scanJobID, req)
+		}
+		if errors.Is(err, tunnel.ErrArtifactUnscannable) {
+			c.recordUnscannable(ctx, scanJobID, req, err)
+		}
 		return xerrors.Errorf("running tunnel wrapper: %v", err)
 	}
 
-	if err = c.store.UpdateReport(ctx, scanJobID, c.transformer.Transform(req.Artifact, scanReport)); err != nil {
+	transformStarted := c.clock.Now()
+	_, span = tracing.Start(ctx, "Transform", trace.WithAttributes(
+		attribute.Int("vulnerabilities", len(scanReport.Vulnerabilities)),
+	))
+	report := c.transformer.Transform(req.Artifact, scanReport.Vulnerabilities)
+	span.End()
+	metrics.ReportTransformDuration.Observe(clock.Since(c.clock, transformStarted).Seconds())
+	report.Artifact.Platform = ToPlatform(scanReport.Metadata)
+	// The vendor attributes of the transformed report, e.g. its severity threshold, complement the detected ones.
+	attributes := ToDetectionAttributes(scanReport)
+	maps.Copy(attributes, report.VendorAttributes)
+	report.VendorAttributes = attributes
+	if layers := ToLayerSummaries(report.Vulnerabilities); len(layers) > 0 {
+		report.VendorAttributes[attributeLayers] = layers
+	}
+	report.Partial = scanReport.Partial
+	if c.lifetimes != nil && c.flags.Enabled(feature.SBOM, policy.ProjectOf(req.Artifact.Repository)) {
+		report = c.trackLifetimes(ctx, req.Artifact.Digest, report)
+	}
+	for _, enricher := range c.enrichers {
+		if report, err = enricher.Enrich(ctx, report); err != nil {
+			return xerrors.Errorf("enriching scan report: %v", err)
+		}
+	}
+	if c.reportPolicy != nil {
+		if report, err = c.reportPolicy.Evaluate(ctx, req, scanReport, report); err != nil {
+			return xerrors.Errorf("evaluating report policy: %v", err)
+		}
+	}
+	// A partial report without vulnerabilities may still miss the vulnerabilities of the unscanned parts.
+	clean := len(report.Vulnerabilities) == 0 && !report.Partial
+	var cleanScan CleanScan
+	if clean {
+		cleanScan = c.toCleanScan(ctx)
+		report.VendorAttributes[attributeCleanScan] = cleanScan
+	}
+	if err = c.store.UpdateReport(ctx, scanJobID, report); err != nil {
 		return xerrors.Errorf("saving scan report: %v", err)
 	}
 
+	if scanReport.Partial {
+		// The partial report is served to Harbor, but neither replaces the indexed vulnerabilities of the
+		// artifact nor is compared with the shadow scanner.
+		message := "scan interrupted, the report is partial: " + scanReport.Interruption
+		if err = c.store.UpdateStatus(ctx, scanJobID, job.Finished, message); err != nil {
+			return xerrors.Errorf("updating scan job status: %v", err)
+		}
+		return
+	}
+
 	if err = c.store.UpdateStatus(ctx, scanJobID, job.Finished); err != nil {
 		return xerrors.Errorf("updating scan job status: %v", err)
 	}
 
+	// The scan report has been saved, hence an outdated index does not fail the scan job.
+	if c.index != nil {
+		if err := c.index.Index(ctx, req.Registry.URL, report); err != nil {
+			slog.WarnContext(ctx, "Error while indexing vulnerabilities", slog.String("err", err.Error()))
+		}
+	}
+
+	if c.shadow != nil {
+		c.shadow.Compare(ref, scanReport)
+	}
+
+	if clean {
+		c.reportClean(ctx, scanJobID, req, report, cleanScan)
+	} else {
+		c.notifyReport(ctx, scanJobID, req, report)
+	}
+
 	return
 }
 
+// sbomFormats map the media types of the SBOMs requested by the sbom capability to the formats Tunnel generates.
+var sbomFormats = map[string]tunnel.SBOMFormat{
+	harbor.MediaTypeCycloneDX: tunnel.SBOMFormatCycloneDX,
+	harbor.MediaTypeSPDX:      tunnel.SBOMFormatSPDXJSON,
+}
+
+// generateSBOM analyzes the image of the artifact, and saves its SBOM report for the sbom capability, in the
+// requested media type.
+func (c *controller) generateSBOM(ctx context.Context, scanJobID string, req harbor.ScanRequest, ref tunnel.ImageRef) error {
+	mediaType := req.SBOMMediaType()
+	sbom, err := c.wrapper.GenerateSBOM(ref, sbomFormats[mediaType])
+	if err != nil {
+		if errors.Is(err, tunnel.ErrRegistryUnauthorized) {
+			c.reportUnauthorized(ctx, scanJobID, req)
+		}
+		if errors.Is(err, tunnel.ErrArtifactUnscannable) {
+			c.recordUnscannable(ctx, scanJobID, req, err)
+		}
+		return xerrors.Errorf("generating SBOM: %v", err)
+	}
+	if err = c.store.UpdateSBOM(ctx, scanJobID, c.transformer.TransformSBOM(req.Artifact, mediaType, sbom)); err != nil {
+		return xerrors.Errorf("saving SBOM: %v", err)
+	}
+	return nil
+}
+
+// scanArtifact matches vulnerabilities against the stored SBOM of the artifact if there is one, or against
+// the SBOM attached to the artifact in the registry, and falls back to analyzing the image otherwise. An analyzed
+// image is turned into an SBOM, which is stored for subsequent scans and matched against vulnerabilities.
+func (c *controller) scanArtifact(ctx context.Context, req harbor.ScanRequest, imageRef tunnel.ImageRef) (tunnel.Report, error) {
+	project := policy.ProjectOf(req.Artifact.Repository)
+	storeSBOMs := c.sboms != nil && c.flags.Enabled(feature.SBOM, project)
+	fetchSBOMs := c.registrySBOMs != nil && c.flags.Enabled(feature.RegistrySBOM, project)
+	if !storeSBOMs && !fetchSBOMs {
+		return c.wrapper.Scan(imageRef)
+	}
+
+	logger := slog.With(slog.String("repository", req.Artifact.Repository))
+
+	if storeSBOMs {
+		sbom, err := c.sboms.Get(ctx, req.Artifact.Digest)
+		if err != nil {
+			logger.WarnContext(ctx, "Error while getting stored SBOM", slog.String("err", err.Error()))
+		} else if sbom != nil {
+			report, err := c.wrapper.ScanSBOM(sbom)
+			if err == nil {
+				logger.DebugContext(ctx, "Scanned stored SBOM")
+				return report, nil
+			}
+			logger.WarnContext(ctx, "Error while scanning stored SBOM", slog.String("err", err.Error()))
+		}
+	}
+
+	if fetchSBOMs {
+		sbom, err := c.registrySBOMs.Fetch(ctx, c.pullRequest(req))
+		if err != nil {
+			logger.WarnContext(ctx, "Error while fetching SBOM from registry", slog.String("err", err.Error()))
+		} else if sbom != nil {
+			report, err := c.wrapper.ScanSBOM(sbom)
+			if err == nil {
+				logger.DebugContext(ctx, "Scanned SBOM attached in registry")
+				if storeSBOMs {
+					c.saveSBOM(ctx, logger, req, sbom)
+				}
+				return report, nil
+			}
+			logger.WarnContext(ctx, "Error while scanning SBOM attached in registry", slog.String("err", err.Error()))
+		}
+	}
+
+	if storeSBOMs {
+		// Analyzing the image into an SBOM and matching vulnerabilities against that SBOM pulls and analyzes the
+		// image once, whereas scanning the image and then generating its SBOM would do it twice.
+		sbom, err := c.wrapper.GenerateSBOM(imageRef, tunnel.SBOMFormatCycloneDX)
+		if errors.Is(err, tunnel.ErrRegistryUnauthorized) || errors.Is(err, tunnel.ErrArtifactUnscannable) {
+			return tunnel.Report{}, err
+		}
+		if err != nil {
+			logger.WarnContext(ctx, "Error while generating SBOM", slog.String("err", err.Error()))
+		} else {
+			c.saveSBOM(ctx, logger, req, sbom)
+			report, err := c.wrapper.ScanSBOM(sbom)
+			if err == nil {
+				logger.DebugContext(ctx, "Scanned generated SBOM")
+				return report, nil
+			}
+			logger.WarnContext(ctx, "Error while scanning generated SBOM", slog.String("err", err.Error()))
+		}
+	}
+
+	// Scanning the image is the fallback when its SBOM cannot be generated, as it reports the vulnerabilities found
+	// before the scan was interrupted, e.g. by the timeout.
+	return c.wrapper.Scan(imageRef)
+}
+
+// saveSBOM stores the given SBOM of the artifact. Failing to store it does not fail the scan job.
+func (c *controller) saveSBOM(ctx context.Context, logger *slog.Logger, req harbor.ScanRequest, sbom []byte) {
+	if err := c.sboms.Save(ctx, req.Registry.URL, req.Artifact, sbom); err != nil {
+		logger.WarnContext(ctx, "Error while saving SBOM", slog.String("err", err.Error()))
+	}
+}
+
+// toCleanScan returns the CleanScan of a report without vulnerabilities, with the version of the vulnerability
+// database. A version which cannot be retrieved is left out rather than failing the scan job.
+func (c *controller) toCleanScan(ctx context.Context) CleanScan {
+	vi, err := c.wrapper.GetVersion()
+	if err != nil {
+		slog.WarnContext(ctx, "Error while retrieving vulnerability DB version of clean scan",
+			slog.String("err", err.Error()))
+		return CleanScan{}
+	}
+	return CleanScan{VulnerabilityDB: ToVulnerabilityDB(vi)}
+}
+
+// reportClean reports that the given scan job found no vulnerability. Errors are logged rather than returned, as
+// the report has been saved.
+func (c *controller) reportClean(ctx context.Context, scanJobID string, req harbor.ScanRequest, report harbor.ScanReport, cleanScan CleanScan) {
+	metrics.CleanScans.Inc()
+	slog.InfoContext(ctx, "Scan found no vulnerability", slog.String("repository", req.Artifact.Repository))
+	if c.cleanScans == nil || !c.flags.Enabled(feature.Webhooks, policy.ProjectOf(req.Artifact.Repository)) {
+		return
+	}
+	event := CleanScanEvent{
+		ScanJobID:       scanJobID,
+		Artifact:        report.Artifact,
+		ScannedAt:       report.GeneratedAt,
+		VulnerabilityDB: cleanScan.VulnerabilityDB,
+	}
+	if err := c.cleanScans.Notify(ctx, event); err != nil {
+		slog.WarnContext(ctx, "Error while notifying clean scan", slog.String("err", err.Error()))
+	}
+}
+
+// notifyReport posts the summary of the given report to the notifiers. Errors are logged rather than returned, as
+// the report has been saved.
+func (c *controller) notifyReport(ctx context.Context, scanJobID string, req harbor.ScanRequest, report harbor.ScanReport) {
+	if len(c.notifiers) == 0 || !c.flags.Enabled(feature.Webhooks, policy.ProjectOf(req.Artifact.Repository)) {
+		return
+	}
+	summary := notify.Summarize(scanJobID, req.Registry.URL, report)
+	for _, notifier := range c.notifiers {
+		if err := notifier.Notify(ctx, summary); err != nil {
+			slog.WarnContext(ctx, "Error while notifying scan report", slog.String("err", err.Error()))
+		}
+	}
+}
+
+// reportUnauthorized reports that the registry rejected the credentials of the given scan job, which usually
+// means that Harbor rotated the credentials of its robot account while the scan job was queued.
+func (c *controller) reportUnauthorized(ctx context.Context, scanJobID string, req harbor.ScanRequest) {
+	metrics.RegistryUnauthorized.WithLabelValues(req.Registry.URL).Inc()
+	slog.WarnContext(ctx, "Registry rejected credentials",
+		slog.String("registry", req.Registry.URL), slog.String("repository", req.Artifact.Repository))
+	if c.credentials == nil {
+		return
+	}
+	if err := c.credentials.Refresh(ctx, scanJobID, req); err != nil {
+		slog.WarnContext(ctx, "Error while refreshing registry credentials", slog.String("err", err.Error()))
+	}
+}
+
+// recordUnscannable records the permanent failure of scanning the digest of the requested artifact. Errors are
+// logged rather than returned, as the scan job fails anyway.
+func (c *controller) recordUnscannable(ctx context.Context, scanJobID string, req harbor.ScanRequest, err error) {
+	if c.failures == nil {
+		return
+	}
+	if err := c.failures.Record(ctx, req.Artifact.Digest, err.Error()); err != nil {
+		slog.WarnContext(ctx, "Error while recording failure of digest", slog.String("err", err.Error()))
+	}
+}
+
 func (c *controller) ToRegistryAuth(authorization string) (auth tunnel.RegistryAuth, err error) {
 	if authorization == "" {
 		return tunnel.NoAuth{}, nil
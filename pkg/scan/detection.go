@@ -0,0 +1,68 @@
+package scan
+
+import (
+	"slices"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+)
+
+// Vendor attributes of scan reports describing what was detected in the scanned image.
+const (
+	attributeOSPackageManagerDetected = "os_package_manager_detected"
+	attributeLanguagePackageTypes     = "language_package_types"
+	attributeImageKind                = "image_kind"
+	attributeHint                     = "hint"
+)
+
+// Kinds of images whose reports are easily mistaken for the outcome of a broken scanner.
+const (
+	// imageKindApko images are built with apko on Wolfi or Chainguard OS packages, and are usually minimal.
+	imageKindApko = "apko"
+	// imageKindDistroless images have no OS package manager, e.g. distroless or scratch images only holding an
+	// application and its language dependencies.
+	imageKindDistroless = "distroless"
+)
+
+const (
+	hintLanguageDepsOnly = "no OS package manager detected; language deps only"
+	hintNothingDetected  = "no OS package manager or language dependencies detected"
+)
+
+// apkoOSFamilies are the OS families of images built with apko.
+var apkoOSFamilies = []string{"wolfi", "chainguard"}
+
+// ToDetectionAttributes returns the vendor attributes describing what Tunnel detected in the image of the given
+// report, i.e. its OS package manager, language package types and runtimes, with a hint explaining an empty OS
+// section of the report, so that it is not mistaken for a broken scanner.
+func ToDetectionAttributes(report tunnel.Report) map[string]interface{} {
+	var languageTypes []string
+	for _, target := range report.Targets {
+		if target.Class != classOSPackages && target.Type != "" && !slices.Contains(languageTypes, target.Type) {
+			languageTypes = append(languageTypes, target.Type)
+		}
+	}
+	slices.Sort(languageTypes)
+
+	osDetected := report.Metadata.OS != nil && report.Metadata.OS.Family != ""
+	attributes := map[string]interface{}{
+		attributeOSPackageManagerDetected: osDetected,
+	}
+	if len(languageTypes) > 0 {
+		attributes[attributeLanguagePackageTypes] = languageTypes
+	}
+	if runtimes := ToRuntimes(report); len(runtimes) > 0 {
+		attributes[attributeRuntimes] = runtimes
+	}
+
+	switch {
+	case osDetected && slices.Contains(apkoOSFamilies, report.Metadata.OS.Family):
+		attributes[attributeImageKind] = imageKindApko
+	case !osDetected && len(languageTypes) > 0:
+		attributes[attributeImageKind] = imageKindDistroless
+		attributes[attributeHint] = hintLanguageDepsOnly
+	case !osDetected:
+		attributes[attributeImageKind] = imageKindDistroless
+		attributes[attributeHint] = hintNothingDetected
+	}
+	return attributes
+}
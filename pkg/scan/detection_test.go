@@ -0,0 +1,84 @@
+package scan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+)
+
+func TestToDetectionAttributes(t *testing.T) {
+	testCases := []struct {
+		name               string
+		report             tunnel.Report
+		expectedAttributes map[string]interface{}
+	}{
+		{
+			name: "Should detect OS package manager",
+			report: tunnel.Report{
+				Metadata: tunnel.ImageMetadata{OS: &tunnel.OS{Family: "debian", Name: "12.5"}},
+				Targets: []tunnel.Target{
+					{Name: "debian:12.5", Class: "os-pkgs", Type: "debian"},
+					{Name: "app/package-lock.json", Class: "lang-pkgs", Type: "npm"},
+				},
+			},
+			expectedAttributes: map[string]interface{}{
+				"os_package_manager_detected": true,
+				"language_package_types":      []string{"npm"},
+			},
+		},
+		{
+			name: "Should detect runtimes",
+			report: tunnel.Report{
+				Metadata: tunnel.ImageMetadata{
+					OS:          &tunnel.OS{Family: "debian", Name: "12.4"},
+					ImageConfig: tunnel.ImageConfig{Config: tunnel.ContainerConfig{Env: []string{"NODE_VERSION=16.20.2"}}},
+				},
+				Targets: []tunnel.Target{{Name: "node:16 (debian 12.4)", Class: "os-pkgs", Type: "debian"}},
+			},
+			expectedAttributes: map[string]interface{}{
+				"os_package_manager_detected": true,
+				"runtimes":                    []Runtime{{Name: "node", Version: "16.20.2", Source: "ENV NODE_VERSION"}},
+			},
+		},
+		{
+			name: "Should detect apko image",
+			report: tunnel.Report{
+				Metadata: tunnel.ImageMetadata{OS: &tunnel.OS{Family: "wolfi", Name: "20230201"}},
+				Targets:  []tunnel.Target{{Name: "cgr.dev/chainguard/python", Class: "os-pkgs", Type: "wolfi"}},
+			},
+			expectedAttributes: map[string]interface{}{
+				"os_package_manager_detected": true,
+				"image_kind":                  "apko",
+			},
+		},
+		{
+			name: "Should hint that distroless image only has language dependencies",
+			report: tunnel.Report{
+				Targets: []tunnel.Target{
+					{Name: "usr/local/bin/app", Class: "lang-pkgs", Type: "gobinary"},
+					{Name: "app/requirements.txt", Class: "lang-pkgs", Type: "pip"},
+					{Name: "usr/local/bin/cli", Class: "lang-pkgs", Type: "gobinary"},
+				},
+			},
+			expectedAttributes: map[string]interface{}{
+				"os_package_manager_detected": false,
+				"language_package_types":      []string{"gobinary", "pip"},
+				"image_kind":                  "distroless",
+				"hint":                        "no OS package manager detected; language deps only",
+			},
+		},
+		{
+			name:               "Should hint that nothing was detected",
+			report:             tunnel.Report{},
+			expectedAttributes: nothingDetected,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedAttributes, ToDetectionAttributes(tc.report))
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package scan
+
+import (
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+)
+
+// classOSPackages is the class of the scan results listing OS packages.
+const classOSPackages = "os-pkgs"
+
+// typeToEcosystem maps the Tunnel types of language packages to the ecosystem of their registry.
+var typeToEcosystem = map[string]string{
+	"npm":        harbor.EcosystemNPM,
+	"yarn":       harbor.EcosystemNPM,
+	"pnpm":       harbor.EcosystemNPM,
+	"node-pkg":   harbor.EcosystemNPM,
+	"pip":        harbor.EcosystemPyPI,
+	"pipenv":     harbor.EcosystemPyPI,
+	"poetry":     harbor.EcosystemPyPI,
+	"python-pkg": harbor.EcosystemPyPI,
+	"gomod":      harbor.EcosystemGolang,
+	"gobinary":   harbor.EcosystemGolang,
+	"jar":        harbor.EcosystemJar,
+	"pom":        harbor.EcosystemJar,
+	"gradle":     harbor.EcosystemJar,
+}
+
+// toEcosystem returns the ecosystem of the vulnerable package of the given vulnerability, or an empty string if
+// the scan result it was found in is unknown.
+func toEcosystem(v tunnel.Vulnerability) string {
+	if v.Class == classOSPackages {
+		return harbor.EcosystemOS
+	}
+	if ecosystem, ok := typeToEcosystem[v.Type]; ok {
+		return ecosystem
+	}
+	return v.Type
+}
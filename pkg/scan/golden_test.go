@@ -0,0 +1,85 @@
+package scan
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// update rewrites the golden files with the actual output, e.g. `go test ./pkg/scan -run Golden -update`.
+// Review the diff of the golden files before committing them.
+var update = flag.Bool("update", false, "update golden files")
+
+const (
+	goldenDir          = "testdata/golden"
+	goldenArtifactFile = "artifact.json"
+	goldenInputFile    = "tunnel.json"
+	goldenOutputFile   = "harbor.golden.json"
+)
+
+// TestTransformer_Golden transforms each fixture Tunnel report in testdata/golden/<case>/tunnel.json
+// for the artifact in testdata/golden/<case>/artifact.json, and compares the result with
+// testdata/golden/<case>/harbor.golden.json.
+func TestTransformer_Golden(t *testing.T) {
+	t.Setenv("TUNNEL_VERSION", "0.46.1")
+	tf := NewTransformer(&fixedClock{
+		fixedTime: time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC),
+	})
+
+	cases, err := os.ReadDir(goldenDir)
+	require.NoError(t, err)
+
+	for _, c := range cases {
+		if !c.IsDir() {
+			continue
+		}
+		dir := filepath.Join(goldenDir, c.Name())
+		t.Run(c.Name(), func(t *testing.T) {
+			var artifact harbor.Artifact
+			readGoldenJSON(t, filepath.Join(dir, goldenArtifactFile), &artifact)
+
+			var source tunnel.ScanReport
+			readGoldenJSON(t, filepath.Join(dir, goldenInputFile), &source)
+
+			var vulnerabilities []tunnel.Vulnerability
+			for _, result := range source.Results {
+				vulnerabilities = append(vulnerabilities, result.Vulnerabilities...)
+			}
+
+			actual, err := json.MarshalIndent(tf.Transform(artifact, vulnerabilities), "", "  ")
+			require.NoError(t, err)
+			actual = append(actual, '\n')
+
+			assertGolden(t, filepath.Join(dir, goldenOutputFile), actual)
+		})
+	}
+}
+
+func readGoldenJSON(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b, v), "decoding %s", path)
+}
+
+// assertGolden compares actual with the content of the golden file at path, or rewrites the
+// golden file when the -update flag is set.
+func assertGolden(t *testing.T, path string, actual []byte) {
+	t.Helper()
+	if *update {
+		require.NoError(t, os.WriteFile(path, actual, 0644))
+		return
+	}
+
+	expected, err := os.ReadFile(path)
+	require.NoError(t, err, "reading golden file, run the test with -update to create it")
+	assert.Equal(t, string(expected), string(actual), "output differs from %s, run the test with -update if the change is intended", path)
+}
@@ -0,0 +1,62 @@
+package scan
+
+import (
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+)
+
+// attributeLayers is the vendor attribute of scan reports holding the LayerSummary of each layer with
+// vulnerabilities.
+const attributeLayers = "layers"
+
+// LayerSummary counts the vulnerabilities found in the packages installed by a layer of the scanned image.
+type LayerSummary struct {
+	Digest string `json:"digest,omitempty"`
+	DiffID string `json:"diff_id,omitempty"`
+	// Total is the number of vulnerabilities of the layer, and Counts the numbers of vulnerabilities of the layer
+	// keyed by severity name.
+	Total  int            `json:"total"`
+	Counts map[string]int `json:"counts"`
+	// Share is the percentage of all vulnerabilities of the report found in the layer.
+	Share float64 `json:"share"`
+}
+
+// ToLayerSummaries aggregates the given vulnerabilities per layer, so that the layers most vulnerabilities come
+// from, typically an outdated base layer, stand out. The summaries are sorted by decreasing number of
+// vulnerabilities. Vulnerabilities without a layer, e.g. found in SBOMs, are only counted in the shares.
+func ToLayerSummaries(vulnerabilities []harbor.VulnerabilityItem) []LayerSummary {
+	var summaries []LayerSummary
+	index := make(map[harbor.Layer]int)
+	for _, v := range vulnerabilities {
+		if v.Layer == nil || (v.Layer.Digest == "" && v.Layer.DiffID == "") {
+			continue
+		}
+		i, ok := index[*v.Layer]
+		if !ok {
+			i = len(summaries)
+			index[*v.Layer] = i
+			summaries = append(summaries, LayerSummary{
+				Digest: v.Layer.Digest,
+				DiffID: v.Layer.DiffID,
+				Counts: make(map[string]int),
+			})
+		}
+		summaries[i].Total++
+		summaries[i].Counts[v.Severity.String()]++
+	}
+
+	for i := range summaries {
+		// Rounded to one decimal.
+		summaries[i].Share = math.Round(float64(summaries[i].Total)*1000/float64(len(vulnerabilities))) / 10
+	}
+	slices.SortStableFunc(summaries, func(a, b LayerSummary) int {
+		if a.Total != b.Total {
+			return b.Total - a.Total
+		}
+		return strings.Compare(a.Digest+a.DiffID, b.Digest+b.DiffID)
+	})
+	return summaries
+}
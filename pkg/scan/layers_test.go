@@ -0,0 +1,61 @@
+package scan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+)
+
+func TestToLayerSummaries(t *testing.T) {
+	base := &harbor.Layer{Digest: "sha256:aaaa", DiffID: "sha256:1111"}
+	app := &harbor.Layer{Digest: "sha256:bbbb", DiffID: "sha256:2222"}
+
+	testCases := []struct {
+		name              string
+		vulnerabilities   []harbor.VulnerabilityItem
+		expectedSummaries []LayerSummary
+	}{
+		{
+			name: "Should count vulnerabilities per layer",
+			vulnerabilities: []harbor.VulnerabilityItem{
+				{ID: "CVE-2019-1549", Severity: harbor.SevHigh, Layer: app},
+				{ID: "CVE-2019-1563", Severity: harbor.SevCritical, Layer: base},
+				{ID: "CVE-2019-1547", Severity: harbor.SevHigh, Layer: base},
+				{ID: "CVE-2019-1551", Severity: harbor.SevHigh, Layer: &harbor.Layer{Digest: "sha256:aaaa", DiffID: "sha256:1111"}},
+				{ID: "CVE-2020-1967", Severity: harbor.SevLow},
+			},
+			expectedSummaries: []LayerSummary{
+				{Digest: "sha256:aaaa", DiffID: "sha256:1111", Total: 3, Counts: map[string]int{"Critical": 1, "High": 2}, Share: 60},
+				{Digest: "sha256:bbbb", DiffID: "sha256:2222", Total: 1, Counts: map[string]int{"High": 1}, Share: 20},
+			},
+		},
+		{
+			name: "Should round shares to one decimal",
+			vulnerabilities: []harbor.VulnerabilityItem{
+				{ID: "CVE-2019-1549", Severity: harbor.SevHigh, Layer: app},
+				{ID: "CVE-2019-1563", Severity: harbor.SevHigh, Layer: base},
+				{ID: "CVE-2019-1547", Severity: harbor.SevHigh, Layer: &harbor.Layer{Digest: "sha256:cccc"}},
+			},
+			expectedSummaries: []LayerSummary{
+				{Digest: "sha256:aaaa", DiffID: "sha256:1111", Total: 1, Counts: map[string]int{"High": 1}, Share: 33.3},
+				{Digest: "sha256:bbbb", DiffID: "sha256:2222", Total: 1, Counts: map[string]int{"High": 1}, Share: 33.3},
+				{Digest: "sha256:cccc", Total: 1, Counts: map[string]int{"High": 1}, Share: 33.3},
+			},
+		},
+		{
+			name: "Should return nothing when vulnerabilities have no layers",
+			vulnerabilities: []harbor.VulnerabilityItem{
+				{ID: "CVE-2019-1549", Severity: harbor.SevHigh},
+				{ID: "CVE-2019-1563", Severity: harbor.SevHigh, Layer: &harbor.Layer{}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedSummaries, ToLayerSummaries(tc.vulnerabilities))
+		})
+	}
+}
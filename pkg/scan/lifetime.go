@@ -0,0 +1,81 @@
+package scan
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+)
+
+// attributeFirstSeen and attributeLastSeen are the vendor attributes of vulnerabilities holding when they were
+// first and last found in the scanned artifact.
+const (
+	attributeFirstSeen = "first_seen"
+	attributeLastSeen  = "last_seen"
+)
+
+// trackLifetimes returns the given report of the artifact with the given digest, with the lifetimes of its
+// vulnerabilities merged with the ones recorded by the previous scans of the digest, and records them for the next
+// scans. The report is returned as is if the previous lifetimes cannot be got.
+func (c *controller) trackLifetimes(ctx context.Context, digest string, report harbor.ScanReport) harbor.ScanReport {
+	previous, err := c.lifetimes.Get(ctx, digest)
+	if err != nil {
+		slog.WarnContext(ctx, "Error while getting vulnerability lifetimes", slog.String("err", err.Error()))
+		return report
+	}
+
+	report, lifetimes := MergeLifetimes(previous, report)
+	if err = c.lifetimes.Save(ctx, digest, lifetimes); err != nil {
+		slog.WarnContext(ctx, "Error while saving vulnerability lifetimes", slog.String("err", err.Error()))
+	}
+	return report
+}
+
+// MergeLifetimes returns a copy of the given report whose vulnerabilities tell when they were first and last seen
+// in the first_seen and last_seen vendor attributes, and the given previous lifetimes updated with the report.
+// Vulnerabilities new to the artifact, or found again after being remediated, are first seen when the report was
+// generated. Vulnerabilities no longer found are remediated when the report was generated, unless the report is
+// partial, since the scan may have been interrupted before finding them.
+func MergeLifetimes(previous map[string]persistence.VulnerabilityLifetime,
+	report harbor.ScanReport) (harbor.ScanReport, map[string]persistence.VulnerabilityLifetime) {
+	lifetimes := make(map[string]persistence.VulnerabilityLifetime, len(previous))
+	vulnerabilities := make([]harbor.VulnerabilityItem, len(report.Vulnerabilities))
+	for i, v := range report.Vulnerabilities {
+		vulnerabilities[i] = v
+		if v.ID == "" {
+			continue
+		}
+		lifetime, ok := lifetimes[v.ID]
+		if !ok {
+			lifetime, ok = previous[v.ID]
+			if !ok || lifetime.IsRemediated() {
+				lifetime = persistence.VulnerabilityLifetime{FirstSeen: report.GeneratedAt}
+			}
+			lifetime.Severity = v.Severity
+		}
+		// A vulnerability found in several packages is as severe as its most severe finding.
+		lifetime.Severity = max(lifetime.Severity, v.Severity)
+		lifetime.LastSeen = report.GeneratedAt
+		lifetimes[v.ID] = lifetime
+
+		attributes := make(map[string]interface{}, len(v.VendorAttributes)+2)
+		maps.Copy(attributes, v.VendorAttributes)
+		attributes[attributeFirstSeen] = lifetime.FirstSeen
+		attributes[attributeLastSeen] = lifetime.LastSeen
+		vulnerabilities[i].VendorAttributes = attributes
+	}
+	report.Vulnerabilities = vulnerabilities
+
+	for id, lifetime := range previous {
+		if _, ok := lifetimes[id]; ok {
+			continue
+		}
+		if !report.Partial && !lifetime.IsRemediated() {
+			lifetime.RemediatedAt = report.GeneratedAt
+		}
+		lifetimes[id] = lifetime
+	}
+	return report, lifetimes
+}
@@ -0,0 +1,121 @@
+package scan
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+)
+
+func TestMergeLifetimes(t *testing.T) {
+	firstScannedAt := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	fixedAt := time.Date(2024, 3, 15, 9, 30, 0, 0, time.UTC)
+	rescannedAt := time.Date(2024, 4, 2, 9, 30, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name              string
+		previous          map[string]persistence.VulnerabilityLifetime
+		report            harbor.ScanReport
+		expectedReport    harbor.ScanReport
+		expectedLifetimes map[string]persistence.VulnerabilityLifetime
+	}{
+		{
+			name: "Should start lifetimes of vulnerabilities of artifact scanned for the first time",
+			report: harbor.ScanReport{
+				GeneratedAt: rescannedAt,
+				Vulnerabilities: []harbor.VulnerabilityItem{
+					{ID: "CVE-2019-1549", Pkg: "openssl", Severity: harbor.SevMedium},
+					{ID: "CVE-2019-1549", Pkg: "libssl", Severity: harbor.SevHigh},
+					{Pkg: "busybox", Severity: harbor.SevLow},
+				},
+			},
+			expectedReport: harbor.ScanReport{
+				GeneratedAt: rescannedAt,
+				Vulnerabilities: []harbor.VulnerabilityItem{
+					{ID: "CVE-2019-1549", Pkg: "openssl", Severity: harbor.SevMedium, VendorAttributes: map[string]interface{}{
+						"first_seen": rescannedAt,
+						"last_seen":  rescannedAt,
+					}},
+					{ID: "CVE-2019-1549", Pkg: "libssl", Severity: harbor.SevHigh, VendorAttributes: map[string]interface{}{
+						"first_seen": rescannedAt,
+						"last_seen":  rescannedAt,
+					}},
+					{Pkg: "busybox", Severity: harbor.SevLow},
+				},
+			},
+			expectedLifetimes: map[string]persistence.VulnerabilityLifetime{
+				"CVE-2019-1549": {Severity: harbor.SevHigh, FirstSeen: rescannedAt, LastSeen: rescannedAt},
+			},
+		},
+		{
+			name: "Should start new lifetime of vulnerability found again after being remediated",
+			previous: map[string]persistence.VulnerabilityLifetime{
+				"CVE-2019-1549": {Severity: harbor.SevHigh, FirstSeen: firstScannedAt, LastSeen: firstScannedAt,
+					RemediatedAt: fixedAt},
+			},
+			report: harbor.ScanReport{
+				GeneratedAt: rescannedAt,
+				Vulnerabilities: []harbor.VulnerabilityItem{
+					{ID: "CVE-2019-1549", Pkg: "openssl", Severity: harbor.SevHigh, VendorAttributes: map[string]interface{}{
+						"fix_stage": "runtime",
+					}},
+				},
+			},
+			expectedReport: harbor.ScanReport{
+				GeneratedAt: rescannedAt,
+				Vulnerabilities: []harbor.VulnerabilityItem{
+					{ID: "CVE-2019-1549", Pkg: "openssl", Severity: harbor.SevHigh, VendorAttributes: map[string]interface{}{
+						"fix_stage":  "runtime",
+						"first_seen": rescannedAt,
+						"last_seen":  rescannedAt,
+					}},
+				},
+			},
+			expectedLifetimes: map[string]persistence.VulnerabilityLifetime{
+				"CVE-2019-1549": {Severity: harbor.SevHigh, FirstSeen: rescannedAt, LastSeen: rescannedAt},
+			},
+		},
+		{
+			name: "Should keep remediated vulnerabilities as is",
+			previous: map[string]persistence.VulnerabilityLifetime{
+				"CVE-2019-1549": {Severity: harbor.SevHigh, FirstSeen: firstScannedAt, LastSeen: firstScannedAt,
+					RemediatedAt: fixedAt},
+			},
+			report: harbor.ScanReport{GeneratedAt: rescannedAt},
+			expectedReport: harbor.ScanReport{
+				GeneratedAt:     rescannedAt,
+				Vulnerabilities: []harbor.VulnerabilityItem{},
+			},
+			expectedLifetimes: map[string]persistence.VulnerabilityLifetime{
+				"CVE-2019-1549": {Severity: harbor.SevHigh, FirstSeen: firstScannedAt, LastSeen: firstScannedAt,
+					RemediatedAt: fixedAt},
+			},
+		},
+		{
+			name: "Should not remediate vulnerabilities missing from partial report",
+			previous: map[string]persistence.VulnerabilityLifetime{
+				"CVE-2019-1549": {Severity: harbor.SevHigh, FirstSeen: firstScannedAt, LastSeen: firstScannedAt},
+			},
+			report: harbor.ScanReport{GeneratedAt: rescannedAt, Partial: true},
+			expectedReport: harbor.ScanReport{
+				GeneratedAt:     rescannedAt,
+				Vulnerabilities: []harbor.VulnerabilityItem{},
+				Partial:         true,
+			},
+			expectedLifetimes: map[string]persistence.VulnerabilityLifetime{
+				"CVE-2019-1549": {Severity: harbor.SevHigh, FirstSeen: firstScannedAt, LastSeen: firstScannedAt},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			report, lifetimes := MergeLifetimes(tc.previous, tc.report)
+			assert.Equal(t, tc.expectedReport, report)
+			assert.Equal(t, tc.expectedLifetimes, lifetimes)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package scan
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+)
+
+// attributeRuntimes is the vendor attribute of scan reports holding the Runtime of each application runtime
+// detected in the scanned image.
+const attributeRuntimes = "runtimes"
+
+// Runtime is an application runtime installed in the scanned image, e.g. a JRE, reported even if no
+// vulnerability maps to it so that end-of-life runtimes can be spotted.
+type Runtime struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// Source is the evidence of the runtime, i.e. the environment variable of the image or the OS package
+	// declaring its version.
+	Source string `json:"source"`
+}
+
+// runtimeRule tells how a runtime is detected: the environment variable set by its official images, and the
+// names of the OS packages installing it.
+type runtimeRule struct {
+	name     string
+	env      string
+	packages *regexp.Regexp
+}
+
+var runtimeRules = []runtimeRule{
+	{
+		name:     "java",
+		env:      "JAVA_VERSION",
+		packages: regexp.MustCompile(`^(openjdk-?\d+-(jre|jdk)(-headless)?|java-\d+(\.\d+)*-openjdk(-headless)?|temurin-\d+-(jre|jdk))$`),
+	},
+	{
+		name:     "node",
+		env:      "NODE_VERSION",
+		packages: regexp.MustCompile(`^nodejs(-?\d+)?(-current)?$`),
+	},
+	{
+		name:     "python",
+		env:      "PYTHON_VERSION",
+		packages: regexp.MustCompile(`^python-?3(\.\d+)?(-minimal)?$`),
+	},
+}
+
+// ToRuntimes returns the application runtimes detected in the image of the given report, sorted by name. The
+// version set by the official image of a runtime, in its environment, takes precedence over the vulnerable OS
+// packages installing the runtime. As Tunnel only reports vulnerable packages, a runtime installed by a package
+// without vulnerabilities is only detected by its environment variable.
+func ToRuntimes(report tunnel.Report) []Runtime {
+	var runtimes []Runtime
+	for _, rule := range runtimeRules {
+		if version, ok := lookupEnv(report.Metadata.ImageConfig.Config.Env, rule.env); ok {
+			runtimes = append(runtimes, Runtime{Name: rule.name, Version: version, Source: "ENV " + rule.env})
+			continue
+		}
+
+		var detected []Runtime
+		for _, v := range report.Vulnerabilities {
+			if v.Class != classOSPackages || !rule.packages.MatchString(v.PkgName) {
+				continue
+			}
+			runtime := Runtime{Name: rule.name, Version: v.InstalledVersion, Source: "package " + v.PkgName}
+			if !slices.Contains(detected, runtime) {
+				detected = append(detected, runtime)
+			}
+		}
+		slices.SortFunc(detected, func(a, b Runtime) int {
+			return strings.Compare(a.Source+a.Version, b.Source+b.Version)
+		})
+		runtimes = append(runtimes, detected...)
+	}
+	return runtimes
+}
+
+// lookupEnv returns the non-empty value of the given variable of the given KEY=value pairs.
+func lookupEnv(env []string, key string) (string, bool) {
+	for _, kv := range env {
+		if k, v, ok := strings.Cut(kv, "="); ok && k == key && v != "" {
+			return v, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,77 @@
+package scan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+)
+
+func TestToRuntimes(t *testing.T) {
+	testCases := []struct {
+		name             string
+		report           tunnel.Report
+		expectedRuntimes []Runtime
+	}{
+		{
+			name: "Should detect runtimes from image environment",
+			report: tunnel.Report{
+				Metadata: tunnel.ImageMetadata{ImageConfig: tunnel.ImageConfig{Config: tunnel.ContainerConfig{
+					Env: []string{
+						"PATH=/usr/local/bin:/usr/bin",
+						"PYTHON_VERSION=3.8.18",
+						"NODE_VERSION=",
+						"JAVA_VERSION=jdk-11.0.21+9",
+					},
+				}}},
+			},
+			expectedRuntimes: []Runtime{
+				{Name: "java", Version: "jdk-11.0.21+9", Source: "ENV JAVA_VERSION"},
+				{Name: "python", Version: "3.8.18", Source: "ENV PYTHON_VERSION"},
+			},
+		},
+		{
+			name: "Should detect runtimes from vulnerable OS packages",
+			report: tunnel.Report{
+				Vulnerabilities: []tunnel.Vulnerability{
+					{VulnerabilityID: "CVE-2023-21930", PkgName: "openjdk-17-jre-headless", InstalledVersion: "17.0.6+10-1~deb11u1", Class: "os-pkgs"},
+					{VulnerabilityID: "CVE-2023-21937", PkgName: "openjdk-17-jre-headless", InstalledVersion: "17.0.6+10-1~deb11u1", Class: "os-pkgs"},
+					{VulnerabilityID: "CVE-2023-24329", PkgName: "python3.9-minimal", InstalledVersion: "3.9.2-1", Class: "os-pkgs"},
+					{VulnerabilityID: "CVE-2023-24329", PkgName: "python3-minimal", InstalledVersion: "3.9.2-3", Class: "os-pkgs"},
+					{VulnerabilityID: "CVE-2023-30581", PkgName: "nodejs", InstalledVersion: "18.13.0", Class: "lang-pkgs"},
+					{VulnerabilityID: "CVE-2023-0286", PkgName: "openssl", InstalledVersion: "1.1.1n-0+deb11u4", Class: "os-pkgs"},
+				},
+			},
+			expectedRuntimes: []Runtime{
+				{Name: "java", Version: "17.0.6+10-1~deb11u1", Source: "package openjdk-17-jre-headless"},
+				{Name: "python", Version: "3.9.2-3", Source: "package python3-minimal"},
+				{Name: "python", Version: "3.9.2-1", Source: "package python3.9-minimal"},
+			},
+		},
+		{
+			name: "Should prefer image environment to OS packages",
+			report: tunnel.Report{
+				Metadata: tunnel.ImageMetadata{ImageConfig: tunnel.ImageConfig{Config: tunnel.ContainerConfig{
+					Env: []string{"NODE_VERSION=20.10.0"},
+				}}},
+				Vulnerabilities: []tunnel.Vulnerability{
+					{VulnerabilityID: "CVE-2023-30581", PkgName: "nodejs", InstalledVersion: "18.19.0-r0", Class: "os-pkgs"},
+				},
+			},
+			expectedRuntimes: []Runtime{
+				{Name: "node", Version: "20.10.0", Source: "ENV NODE_VERSION"},
+			},
+		},
+		{
+			name:   "Should detect no runtime",
+			report: tunnel.Report{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedRuntimes, ToRuntimes(tc.report))
+		})
+	}
+}
@@ -1,69 +1,208 @@
 package scan
 
 import (
+	"cmp"
 	"log/slog"
+	"slices"
 	"time"
 
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/allowlist"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/classify"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/clock"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/vex"
 )
 
-// Clock wraps the Now method. Introduced to allow replacing the global state with fixed clocks to facilitate testing.
-// Now returns the current time.
-type Clock interface {
-	Now() time.Time
+// attributeSeverityThreshold is the vendor attribute of scan reports holding the lowest severity of the reported
+// vulnerabilities, and attributeBelowSeverityThreshold the number of vulnerabilities left out for being less severe.
+const (
+	attributeSeverityThreshold      = "severity_threshold"
+	attributeBelowSeverityThreshold = "below_severity_threshold"
+)
+
+// Transformer wraps the Transform and TransformSBOM methods.
+// Transform transforms Tunnel's scan report into Harbor's packages vulnerabilities report.
+// TransformSBOM wraps the SBOM of the given media type generated by Tunnel into Harbor's SBOM report.
+type Transformer interface {
+	Transform(artifact harbor.Artifact, source []tunnel.Vulnerability) harbor.ScanReport
+	TransformSBOM(artifact harbor.Artifact, mediaType string, sbom []byte) harbor.SBOMReport
 }
 
-type SystemClock struct {
+type transformer struct {
+	clock clock.Clock
+	// classifier classifies vulnerabilities by where their fix is made, nil if they are not classified.
+	classifier classify.Classifier
+	// allowlist allowlists vulnerabilities, which are dropped or marked as configured by allowlistMode, nil if
+	// no vulnerability is allowlisted.
+	allowlist     allowlist.Allowlist
+	allowlistMode string
+	// vex matches the statements of VEX documents suppressing vulnerabilities, which are annotated with them, nil
+	// if no VEX document is loaded.
+	vex vex.Matcher
+	// severityThreshold is the lowest severity of the reported vulnerabilities, nil if vulnerabilities of any
+	// severity are reported.
+	severityThreshold *harbor.Severity
 }
 
-func (c *SystemClock) Now() time.Time {
-	return time.Now()
+// TransformerOption customizes the transformer constructed with NewTransformer.
+type TransformerOption func(t *transformer)
+
+// WithClassifier exposes the stage where the fix of each vulnerability is made, as classified by the given
+// Classifier, as its fix_stage vendor attribute.
+func WithClassifier(classifier classify.Classifier) TransformerOption {
+	return func(t *transformer) {
+		t.classifier = classifier
+	}
 }
 
-// Transformer wraps the Transform method.
-// Transform transforms Tunnel's scan report into Harbor's packages vulnerabilities report.
-type Transformer interface {
-	Transform(artifact harbor.Artifact, source []tunnel.Vulnerability) harbor.ScanReport
+// WithAllowlist drops the vulnerabilities allowlisted by the given Allowlist from reports, or marks them with the
+// allowlisted vendor attribute if the given mode is allowlist.ModeMark. Marked vulnerabilities do not raise the
+// severity of reports.
+func WithAllowlist(list allowlist.Allowlist, mode string) TransformerOption {
+	return func(t *transformer) {
+		t.allowlist = list
+		t.allowlistMode = mode
+	}
 }
 
-type transformer struct {
-	clock Clock
+// WithVEX annotates the vulnerabilities suppressed by the statements of VEX documents, as matched by the given
+// Matcher, with the vex_status, vex_justification and vex_document vendor attributes. Annotated vulnerabilities
+// are kept in reports, but do not raise their severity.
+func WithVEX(matcher vex.Matcher) TransformerOption {
+	return func(t *transformer) {
+		t.vex = matcher
+	}
+}
+
+// WithSeverityThreshold drops the vulnerabilities less severe than the given Severity from reports. The number of
+// dropped vulnerabilities is recorded in the below_severity_threshold vendor attribute of reports, along with the
+// threshold in the severity_threshold one.
+func WithSeverityThreshold(threshold harbor.Severity) TransformerOption {
+	return func(t *transformer) {
+		t.severityThreshold = &threshold
+	}
 }
 
 // NewTransformer constructs a Transformer with the given Clock.
-func NewTransformer(clock Clock) Transformer {
-	return &transformer{
-		clock: clock,
+func NewTransformer(c clock.Clock, opts ...TransformerOption) Transformer {
+	t := &transformer{
+		clock: c,
+	}
+	for _, opt := range opts {
+		opt(t)
 	}
+	return t
 }
 
 func (t *transformer) Transform(artifact harbor.Artifact, source []tunnel.Vulnerability) harbor.ScanReport {
-	vulnerabilities := make([]harbor.VulnerabilityItem, len(source))
+	now := t.clock.Now()
+	vulnerabilities := make([]harbor.VulnerabilityItem, 0, len(source))
+	belowThreshold := 0
 
-	for i, v := range source {
-		vulnerabilities[i] = harbor.VulnerabilityItem{
+	for _, v := range source {
+		severity := t.toHarborSeverity(v.Severity)
+		if t.severityThreshold != nil && severity < *t.severityThreshold {
+			belowThreshold++
+			continue
+		}
+		item := harbor.VulnerabilityItem{
 			ID:               v.VulnerabilityID,
 			Pkg:              v.PkgName,
 			Version:          v.InstalledVersion,
 			FixVersion:       v.FixedVersion,
-			Severity:         t.toHarborSeverity(v.Severity),
+			Severity:         severity,
 			Description:      v.Description,
 			Links:            t.toLinks(v.PrimaryURL, v.References),
 			Layer:            t.toHarborLayer(v.Layer),
 			CweIDs:           v.CweIDs,
-			VendorAttributes: t.toVendorAttributes(v.CVSS),
+			VendorAttributes: t.toVendorAttributes(v),
+		}
+		if t.allowlist != nil {
+			if entry, ok := t.allowlist.Lookup(v.VulnerabilityID, now); ok {
+				if t.allowlistMode != allowlist.ModeMark {
+					continue
+				}
+				item.VendorAttributes[harbor.VendorAttributeAllowlisted] = true
+				if !entry.Expires.IsZero() {
+					item.VendorAttributes[harbor.VendorAttributeAllowlistExpires] = entry.Expires.Format(time.DateOnly)
+				}
+			}
 		}
+		if t.vex != nil {
+			if statement, ok := t.vex.Match(artifact, v); ok {
+				item.VendorAttributes[harbor.VendorAttributeVEXStatus] = string(statement.Status)
+				if statement.Justification != "" {
+					item.VendorAttributes[harbor.VendorAttributeVEXJustification] = statement.Justification
+				}
+				if statement.Document != "" {
+					item.VendorAttributes[harbor.VendorAttributeVEXDocument] = statement.Document
+				}
+			}
+		}
+		vulnerabilities = append(vulnerabilities, item)
 	}
+	sortVulnerabilities(vulnerabilities)
 
-	return harbor.ScanReport{
-		GeneratedAt:     t.clock.Now(),
+	report := harbor.ScanReport{
+		GeneratedAt:     now,
 		Scanner:         etc.GetScannerMetadata(),
 		Artifact:        artifact,
 		Severity:        t.toHighestSeverity(vulnerabilities),
 		Vulnerabilities: vulnerabilities,
 	}
+	if t.severityThreshold != nil {
+		report.VendorAttributes = map[string]interface{}{
+			attributeSeverityThreshold:      t.severityThreshold.String(),
+			attributeBelowSeverityThreshold: belowThreshold,
+		}
+	}
+	return report
+}
+
+func (t *transformer) TransformSBOM(artifact harbor.Artifact, mediaType string, sbom []byte) harbor.SBOMReport {
+	return harbor.SBOMReport{
+		GeneratedAt: t.clock.Now(),
+		Scanner:     etc.GetScannerMetadata(),
+		Artifact:    artifact,
+		MediaType:   mediaType,
+		SBOM:        sbom,
+	}
+}
+
+// sortVulnerabilities sorts vulnerabilities by descending severity, then by ID, package and version, so that
+// scanning the same artifact against the same vulnerability database yields the same report. Together with
+// encoding/json, which sorts map keys, it makes reports reproducible for diffs and signed attestations.
+func sortVulnerabilities(vulnerabilities []harbor.VulnerabilityItem) {
+	slices.SortStableFunc(vulnerabilities, func(a, b harbor.VulnerabilityItem) int {
+		if c := cmp.Compare(b.Severity, a.Severity); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a.ID, b.ID); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a.Pkg, b.Pkg); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Version, b.Version)
+	})
+}
+
+// ToPlatform returns the platform of an image with the given metadata, or nil if nothing was detected.
+func ToPlatform(metadata tunnel.ImageMetadata) *harbor.Platform {
+	platform := harbor.Platform{
+		Architecture: metadata.ImageConfig.Architecture,
+		Size:         metadata.Size,
+	}
+	if metadata.OS != nil {
+		platform.OSFamily = metadata.OS.Family
+		platform.OSVersion = metadata.OS.Name
+	}
+	if platform == (harbor.Platform{}) {
+		return nil
+	}
+	return &platform
 }
 
 func (t *transformer) toLinks(primaryURL string, references []string) []string {
@@ -105,10 +244,16 @@ func (t *transformer) toHarborSeverity(severity string) harbor.Severity {
 	return harborSev
 }
 
-func (t *transformer) toVendorAttributes(info map[string]tunnel.CVSSInfo) map[string]interface{} {
+func (t *transformer) toVendorAttributes(v tunnel.Vulnerability) map[string]interface{} {
 	attributes := make(map[string]interface{})
-	if len(info) > 0 {
-		attributes["CVSS"] = info
+	if len(v.CVSS) > 0 {
+		attributes["CVSS"] = v.CVSS
+	}
+	if ecosystem := toEcosystem(v); ecosystem != "" {
+		attributes[harbor.VendorAttributeEcosystem] = ecosystem
+	}
+	if t.classifier != nil {
+		attributes["fix_stage"] = t.classifier.Classify(v)
 	}
 	return attributes
 }
@@ -117,6 +262,9 @@ func (t *transformer) toHighestSeverity(vlns []harbor.VulnerabilityItem) (highes
 	highest = harbor.SevUnknown
 
 	for _, vln := range vlns {
+		if harbor.IsSuppressed(vln) {
+			continue
+		}
 		if vln.Severity > highest {
 			highest = vln.Severity
 
@@ -4,9 +4,14 @@ import (
 	"testing"
 	"time"
 
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/allowlist"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/classify"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/vex"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type fixedClock struct {
@@ -226,3 +231,235 @@ func TestTransformer_Transform(t *testing.T) {
 		},
 	}, hr)
 }
+
+func TestTransformer_Transform_Ordering(t *testing.T) {
+	tf := NewTransformer(&fixedClock{
+		fixedTime: time.Now(),
+	})
+
+	hr := tf.Transform(harbor.Artifact{}, []tunnel.Vulnerability{
+		{VulnerabilityID: "CVE-0000-0003", PkgName: "openssl", InstalledVersion: "1.1.1", Severity: "LOW"},
+		{VulnerabilityID: "CVE-0000-0002", PkgName: "zlib", InstalledVersion: "1.2.11", Severity: "CRITICAL"},
+		{VulnerabilityID: "CVE-0000-0002", PkgName: "libssl", InstalledVersion: "1.1.1", Severity: "CRITICAL"},
+		{VulnerabilityID: "CVE-0000-0001", PkgName: "musl", InstalledVersion: "1.1.22", Severity: "LOW"},
+		{VulnerabilityID: "CVE-0000-0004", PkgName: "busybox", InstalledVersion: "1.30.1", Severity: "UNKNOWN"},
+		{VulnerabilityID: "CVE-0000-0005", PkgName: "curl", InstalledVersion: "7.64.0", Severity: "HIGH"},
+	})
+
+	var order []string
+	for _, v := range hr.Vulnerabilities {
+		order = append(order, v.ID+"/"+v.Pkg)
+	}
+	assert.Equal(t, []string{
+		"CVE-0000-0002/libssl",
+		"CVE-0000-0002/zlib",
+		"CVE-0000-0005/curl",
+		"CVE-0000-0001/musl",
+		"CVE-0000-0003/openssl",
+		"CVE-0000-0004/busybox",
+	}, order)
+}
+
+func TestTransformer_Transform_Classification(t *testing.T) {
+	tf := NewTransformer(&fixedClock{
+		fixedTime: time.Now(),
+	}, WithClassifier(classify.NewClassifier(etc.Classification{})))
+
+	hr := tf.Transform(harbor.Artifact{}, []tunnel.Vulnerability{
+		{VulnerabilityID: "CVE-2019-1549", PkgName: "openssl", Severity: "HIGH", Class: "os-pkgs", Type: "alpine"},
+		{VulnerabilityID: "CVE-2021-23337", PkgName: "lodash", Severity: "MEDIUM", Class: "lang-pkgs", Type: "npm"},
+	})
+
+	assert.Equal(t, classify.StageRuntime, hr.Vulnerabilities[0].VendorAttributes["fix_stage"])
+	assert.Equal(t, classify.StageBuild, hr.Vulnerabilities[1].VendorAttributes["fix_stage"])
+}
+
+func TestTransformer_Transform_SeverityThreshold(t *testing.T) {
+	tf := NewTransformer(&fixedClock{
+		fixedTime: time.Now(),
+	}, WithSeverityThreshold(harbor.SevHigh))
+
+	hr := tf.Transform(harbor.Artifact{}, []tunnel.Vulnerability{
+		{VulnerabilityID: "CVE-0000-0001", PkgName: "musl", Severity: "LOW"},
+		{VulnerabilityID: "CVE-0000-0002", PkgName: "curl", Severity: "HIGH"},
+		{VulnerabilityID: "CVE-0000-0003", PkgName: "openssl", Severity: "MEDIUM"},
+		{VulnerabilityID: "CVE-0000-0004", PkgName: "zlib", Severity: "CRITICAL"},
+		{VulnerabilityID: "CVE-0000-0005", PkgName: "busybox", Severity: "UNKNOWN"},
+	})
+
+	var ids []string
+	for _, v := range hr.Vulnerabilities {
+		ids = append(ids, v.ID)
+	}
+	assert.Equal(t, []string{"CVE-0000-0004", "CVE-0000-0002"}, ids)
+	assert.Equal(t, harbor.SevCritical, hr.Severity)
+	assert.Equal(t, map[string]interface{}{
+		"severity_threshold":       "High",
+		"below_severity_threshold": 3,
+	}, hr.VendorAttributes)
+}
+
+func TestTransformer_Transform_Ecosystem(t *testing.T) {
+	tf := NewTransformer(&fixedClock{
+		fixedTime: time.Now(),
+	})
+
+	hr := tf.Transform(harbor.Artifact{}, []tunnel.Vulnerability{
+		{VulnerabilityID: "CVE-0000-0001", PkgName: "openssl", Severity: "CRITICAL", Class: "os-pkgs", Type: "alpine"},
+		{VulnerabilityID: "CVE-0000-0002", PkgName: "lodash", Severity: "HIGH", Class: "lang-pkgs", Type: "yarn"},
+		{VulnerabilityID: "CVE-0000-0003", PkgName: "requests", Severity: "MEDIUM", Class: "lang-pkgs", Type: "python-pkg"},
+		{VulnerabilityID: "CVE-0000-0004", PkgName: "stdlib", Severity: "LOW", Class: "lang-pkgs", Type: "gobinary"},
+		{VulnerabilityID: "CVE-0000-0005", PkgName: "log4j-core", Severity: "UNKNOWN", Class: "lang-pkgs", Type: "pom"},
+		{VulnerabilityID: "CVE-0000-0006", PkgName: "serde", Severity: "UNKNOWN", Class: "lang-pkgs", Type: "cargo"},
+		{VulnerabilityID: "CVE-0000-0007", PkgName: "zlib", Severity: "UNKNOWN"},
+	})
+
+	var ecosystems []interface{}
+	for _, v := range hr.Vulnerabilities {
+		ecosystems = append(ecosystems, v.VendorAttributes[harbor.VendorAttributeEcosystem])
+	}
+	assert.Equal(t, []interface{}{"os", "npm", "pypi", "golang", "jar", "cargo", nil}, ecosystems)
+}
+
+func TestTransformer_Transform_Allowlist(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	list := allowlist.New([]allowlist.Entry{
+		{ID: "CVE-0000-0001"},
+		{ID: "cve-0000-0002", Expires: time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC)},
+		{ID: "CVE-0000-0003", Expires: time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)},
+	})
+	source := []tunnel.Vulnerability{
+		{VulnerabilityID: "CVE-0000-0001", PkgName: "openssl", Severity: "CRITICAL"},
+		{VulnerabilityID: "CVE-0000-0002", PkgName: "zlib", Severity: "HIGH"},
+		{VulnerabilityID: "CVE-0000-0003", PkgName: "musl", Severity: "MEDIUM"},
+		{VulnerabilityID: "CVE-0000-0004", PkgName: "curl", Severity: "LOW"},
+	}
+
+	t.Run("Should drop allowlisted vulnerabilities", func(t *testing.T) {
+		tf := NewTransformer(&fixedClock{fixedTime: now}, WithAllowlist(list, allowlist.ModeDrop))
+
+		hr := tf.Transform(harbor.Artifact{}, source)
+
+		var ids []string
+		for _, v := range hr.Vulnerabilities {
+			ids = append(ids, v.ID)
+		}
+		assert.Equal(t, []string{"CVE-0000-0003", "CVE-0000-0004"}, ids)
+		assert.Equal(t, harbor.SevMedium, hr.Severity)
+	})
+
+	t.Run("Should mark allowlisted vulnerabilities", func(t *testing.T) {
+		tf := NewTransformer(&fixedClock{fixedTime: now}, WithAllowlist(list, allowlist.ModeMark))
+
+		hr := tf.Transform(harbor.Artifact{}, source)
+
+		require.Len(t, hr.Vulnerabilities, 4)
+		assert.Equal(t, map[string]interface{}{harbor.VendorAttributeAllowlisted: true},
+			hr.Vulnerabilities[0].VendorAttributes)
+		assert.Equal(t, map[string]interface{}{
+			harbor.VendorAttributeAllowlisted:      true,
+			harbor.VendorAttributeAllowlistExpires: "2025-06-30",
+		}, hr.Vulnerabilities[1].VendorAttributes)
+		assert.Empty(t, hr.Vulnerabilities[2].VendorAttributes)
+		assert.Empty(t, hr.Vulnerabilities[3].VendorAttributes)
+		assert.Equal(t, harbor.SevMedium, hr.Severity)
+	})
+}
+
+func TestTransformer_Transform_VEX(t *testing.T) {
+	artifact := harbor.Artifact{Repository: "library/mongo", Digest: "sha256:917f"}
+	matcher := vex.NewMatcher([]vex.Statement{
+		{
+			Vulnerability: "CVE-0000-0001",
+			Products:      []vex.Product{{ID: "pkg:oci/mongo@sha256:917f", Subcomponents: []string{"pkg:deb/debian/openssl"}}},
+			Status:        vex.StatusNotAffected,
+			Justification: "vulnerable_code_not_in_execute_path",
+			Document:      "https://vex.example.com/public/vex-2e67563e",
+		},
+		{
+			Vulnerability: "CVE-0000-0002",
+			Products:      []vex.Product{{ID: "pkg:deb/debian/zlib"}},
+			Status:        vex.StatusFixed,
+		},
+	})
+	tf := NewTransformer(&fixedClock{fixedTime: time.Now()}, WithVEX(matcher))
+
+	hr := tf.Transform(artifact, []tunnel.Vulnerability{
+		{VulnerabilityID: "CVE-0000-0001", PkgName: "openssl", Severity: "CRITICAL",
+			PkgIdentifier: tunnel.PkgIdentifier{PURL: "pkg:deb/debian/openssl@1.1.1n-0+deb11u4?arch=amd64"}},
+		{VulnerabilityID: "CVE-0000-0002", PkgName: "zlib", Severity: "HIGH"},
+		{VulnerabilityID: "CVE-0000-0003", PkgName: "musl", Severity: "MEDIUM"},
+	})
+
+	require.Len(t, hr.Vulnerabilities, 3)
+	assert.Equal(t, map[string]interface{}{
+		harbor.VendorAttributeVEXStatus:        "not_affected",
+		harbor.VendorAttributeVEXJustification: "vulnerable_code_not_in_execute_path",
+		harbor.VendorAttributeVEXDocument:      "https://vex.example.com/public/vex-2e67563e",
+	}, hr.Vulnerabilities[0].VendorAttributes)
+	assert.Equal(t, map[string]interface{}{harbor.VendorAttributeVEXStatus: "fixed"},
+		hr.Vulnerabilities[1].VendorAttributes)
+	assert.Empty(t, hr.Vulnerabilities[2].VendorAttributes)
+	assert.Equal(t, harbor.SevMedium, hr.Severity)
+}
+
+func TestTransformer_TransformSBOM(t *testing.T) {
+	fixedTime := time.Now()
+	tf := NewTransformer(&fixedClock{fixedTime: fixedTime})
+	artifact := harbor.Artifact{
+		Repository: "library/mongo",
+		Digest:     "sha256:6c3c624b58dbbcd3c0dd82b4c53f04194d1247c6eebdaab7c610cf7d66709b3b",
+	}
+	sbom := []byte(`{"spdxVersion":"SPDX-2.3"}`)
+
+	assert.Equal(t, harbor.SBOMReport{
+		GeneratedAt: fixedTime,
+		Artifact:    artifact,
+		Scanner:     etc.GetScannerMetadata(),
+		MediaType:   harbor.MediaTypeSPDX,
+		SBOM:        sbom,
+	}, tf.TransformSBOM(artifact, harbor.MediaTypeSPDX, sbom))
+}
+
+func TestToPlatform(t *testing.T) {
+	testCases := []struct {
+		name             string
+		metadata         tunnel.ImageMetadata
+		expectedPlatform *harbor.Platform
+	}{
+		{
+			name: "Should return platform of image",
+			metadata: tunnel.ImageMetadata{
+				Size:        5814784,
+				OS:          &tunnel.OS{Family: "alpine", Name: "3.10.2"},
+				ImageConfig: tunnel.ImageConfig{Architecture: "arm64", OS: "linux"},
+			},
+			expectedPlatform: &harbor.Platform{
+				OSFamily:     "alpine",
+				OSVersion:    "3.10.2",
+				Architecture: "arm64",
+				Size:         5814784,
+			},
+		},
+		{
+			name: "Should return platform without OS when it is not detected",
+			metadata: tunnel.ImageMetadata{
+				ImageConfig: tunnel.ImageConfig{Architecture: "amd64"},
+			},
+			expectedPlatform: &harbor.Platform{
+				Architecture: "amd64",
+			},
+		},
+		{
+			name:             "Should return nil when nothing is detected",
+			metadata:         tunnel.ImageMetadata{},
+			expectedPlatform: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedPlatform, ToPlatform(tc.metadata))
+		})
+	}
+}
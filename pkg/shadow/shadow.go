@@ -0,0 +1,157 @@
+// Package shadow scans a sample of the scanned images again with a secondary scanner, e.g. a Tunnel release
+// being evaluated, and records how its findings differ from the findings of the primary scanner. The reports
+// returned to Harbor are never affected.
+package shadow
+
+import (
+	"log/slog"
+	"math/rand"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/metrics"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+)
+
+// finding identifies a vulnerability of an installed package, regardless of the scanner which found it.
+type finding struct {
+	VulnerabilityID  string
+	PkgName          string
+	InstalledVersion string
+}
+
+func findingOf(v tunnel.Vulnerability) finding {
+	return finding{VulnerabilityID: v.VulnerabilityID, PkgName: v.PkgName, InstalledVersion: v.InstalledVersion}
+}
+
+// SeverityDisagreement is a finding of both scanners rated with different severities.
+type SeverityDisagreement struct {
+	VulnerabilityID string `json:"vulnerability_id"`
+	PkgName         string `json:"pkg_name"`
+	Primary         string `json:"primary"`
+	Secondary       string `json:"secondary"`
+}
+
+// Diff is how the findings of the secondary scanner differ from the findings of the primary scanner.
+type Diff struct {
+	// Overlap is the number of findings of both scanners.
+	Overlap int `json:"overlap"`
+	// PrimaryOnly and SecondaryOnly are the identifiers of the vulnerabilities found by a single scanner.
+	PrimaryOnly           []string               `json:"primary_only"`
+	SecondaryOnly         []string               `json:"secondary_only"`
+	SeverityDisagreements []SeverityDisagreement `json:"severity_disagreements"`
+}
+
+// Compare returns how the given findings of the secondary scanner differ from the given findings of the
+// primary scanner. Findings are matched by vulnerability, package name and installed version.
+func Compare(primary, secondary []tunnel.Vulnerability) Diff {
+	severities := make(map[finding]string, len(primary))
+	for _, v := range primary {
+		severities[findingOf(v)] = v.Severity
+	}
+
+	var diff Diff
+	matched := make(map[finding]bool, len(secondary))
+	for _, v := range secondary {
+		f := findingOf(v)
+		if matched[f] {
+			continue
+		}
+		matched[f] = true
+
+		severity, ok := severities[f]
+		if !ok {
+			diff.SecondaryOnly = append(diff.SecondaryOnly, v.VulnerabilityID)
+			continue
+		}
+		diff.Overlap++
+		if severity != v.Severity {
+			diff.SeverityDisagreements = append(diff.SeverityDisagreements, SeverityDisagreement{
+				VulnerabilityID: v.VulnerabilityID,
+				PkgName:         v.PkgName,
+				Primary:         severity,
+				Secondary:       v.Severity,
+			})
+		}
+	}
+	for _, v := range primary {
+		f := findingOf(v)
+		if !matched[f] {
+			matched[f] = true
+			diff.PrimaryOnly = append(diff.PrimaryOnly, v.VulnerabilityID)
+		}
+	}
+	return diff
+}
+
+// Comparator wraps the Compare method.
+// Compare scans the given image with the secondary scanner if the scan is sampled, and records how its
+// findings differ from the given report of the primary scanner. It does not wait for the shadow scan.
+type Comparator interface {
+	Compare(imageRef tunnel.ImageRef, primary tunnel.Report)
+}
+
+type comparator struct {
+	wrapper    tunnel.Wrapper
+	percentage int
+	// slots holds a token per running shadow scan.
+	slots chan struct{}
+
+	sample func() int
+	run    func(func())
+}
+
+// NewComparator constructs a Comparator, which samples the configured percentage of scans and runs the shadow
+// scans with the given Wrapper of the secondary Tunnel.
+func NewComparator(config etc.Shadow, wrapper tunnel.Wrapper) Comparator {
+	return &comparator{
+		wrapper:    wrapper,
+		percentage: config.Percentage,
+		slots:      make(chan struct{}, max(config.Concurrency, 1)),
+		sample:     func() int { return rand.Intn(100) },
+		run:        func(f func()) { go f() },
+	}
+}
+
+func (c *comparator) Compare(imageRef tunnel.ImageRef, primary tunnel.Report) {
+	if c.sample() >= c.percentage {
+		return
+	}
+
+	select {
+	case c.slots <- struct{}{}:
+	default:
+		metrics.ShadowScans.WithLabelValues("skipped").Inc()
+		slog.Debug("Skipped shadow scan as all shadow scans are running", slog.String("image_ref", imageRef.Name))
+		return
+	}
+
+	c.run(func() {
+		defer func() { <-c.slots }()
+		c.compare(imageRef, primary)
+	})
+}
+
+func (c *comparator) compare(imageRef tunnel.ImageRef, primary tunnel.Report) {
+	logger := slog.With(slog.String("image_ref", imageRef.Name))
+
+	secondary, err := c.wrapper.Scan(imageRef)
+	if err != nil {
+		metrics.ShadowScans.WithLabelValues("failed").Inc()
+		logger.Warn("Error while running shadow scan", slog.String("err", err.Error()))
+		return
+	}
+
+	diff := Compare(primary.Vulnerabilities, secondary.Vulnerabilities)
+	metrics.ShadowScans.WithLabelValues("compared").Inc()
+	metrics.ShadowFindings.WithLabelValues("both").Add(float64(diff.Overlap))
+	metrics.ShadowFindings.WithLabelValues("primary").Add(float64(len(diff.PrimaryOnly)))
+	metrics.ShadowFindings.WithLabelValues("secondary").Add(float64(len(diff.SecondaryOnly)))
+	metrics.ShadowSeverityDisagreements.Add(float64(len(diff.SeverityDisagreements)))
+
+	logger.Info("Compared shadow scan",
+		slog.Int("overlap", diff.Overlap),
+		slog.Any("primary_only", diff.PrimaryOnly),
+		slog.Any("secondary_only", diff.SecondaryOnly),
+		slog.Any("severity_disagreements", diff.SeverityDisagreements),
+	)
+}
@@ -0,0 +1,133 @@
+package shadow
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/metrics"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+)
+
+func TestCompare(t *testing.T) {
+	openssl := tunnel.Vulnerability{VulnerabilityID: "CVE-2019-1549", PkgName: "openssl", InstalledVersion: "1.1.1c-r0", Severity: "MEDIUM"}
+	musl := tunnel.Vulnerability{VulnerabilityID: "CVE-2019-14697", PkgName: "musl", InstalledVersion: "1.1.22-r2", Severity: "CRITICAL"}
+	libssl := tunnel.Vulnerability{VulnerabilityID: "CVE-2019-1549", PkgName: "libssl", InstalledVersion: "1.1.1c-r0", Severity: "MEDIUM"}
+
+	testCases := []struct {
+		name         string
+		primary      []tunnel.Vulnerability
+		secondary    []tunnel.Vulnerability
+		expectedDiff Diff
+	}{
+		{
+			name:         "Should return overlap when scanners agree",
+			primary:      []tunnel.Vulnerability{openssl, musl},
+			secondary:    []tunnel.Vulnerability{musl, openssl},
+			expectedDiff: Diff{Overlap: 2},
+		},
+		{
+			name:      "Should return findings of a single scanner",
+			primary:   []tunnel.Vulnerability{openssl, musl},
+			secondary: []tunnel.Vulnerability{openssl, libssl},
+			expectedDiff: Diff{
+				Overlap:       1,
+				PrimaryOnly:   []string{"CVE-2019-14697"},
+				SecondaryOnly: []string{"CVE-2019-1549"},
+			},
+		},
+		{
+			name:    "Should return severity disagreements",
+			primary: []tunnel.Vulnerability{openssl},
+			secondary: []tunnel.Vulnerability{
+				{VulnerabilityID: "CVE-2019-1549", PkgName: "openssl", InstalledVersion: "1.1.1c-r0", Severity: "HIGH"},
+			},
+			expectedDiff: Diff{
+				Overlap: 1,
+				SeverityDisagreements: []SeverityDisagreement{
+					{VulnerabilityID: "CVE-2019-1549", PkgName: "openssl", Primary: "MEDIUM", Secondary: "HIGH"},
+				},
+			},
+		},
+		{
+			name:         "Should count findings reported for several targets once",
+			primary:      []tunnel.Vulnerability{musl, musl},
+			secondary:    []tunnel.Vulnerability{musl},
+			expectedDiff: Diff{Overlap: 1},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedDiff, Compare(tc.primary, tc.secondary))
+		})
+	}
+}
+
+func TestComparator_Compare(t *testing.T) {
+	imageRef := tunnel.ImageRef{Name: "core.harbor.domain/library/mongo@sha256:917f"}
+	primary := tunnel.Report{Vulnerabilities: []tunnel.Vulnerability{
+		{VulnerabilityID: "CVE-2019-1549", PkgName: "openssl", InstalledVersion: "1.1.1c-r0", Severity: "MEDIUM"},
+	}}
+
+	newComparator := func(wrapper tunnel.Wrapper, sample int) *comparator {
+		c := NewComparator(etc.Shadow{Percentage: 10, Concurrency: 1}, wrapper).(*comparator)
+		c.sample = func() int { return sample }
+		c.run = func(f func()) { f() }
+		return c
+	}
+
+	t.Run("Should record diff of sampled scan", func(t *testing.T) {
+		compared := testutil.ToFloat64(metrics.ShadowScans.WithLabelValues("compared"))
+		secondaryOnly := testutil.ToFloat64(metrics.ShadowFindings.WithLabelValues("secondary"))
+		disagreements := testutil.ToFloat64(metrics.ShadowSeverityDisagreements)
+
+		wrapper := tunnel.NewMockWrapper()
+		wrapper.On("Scan", imageRef).Return(tunnel.Report{Vulnerabilities: []tunnel.Vulnerability{
+			{VulnerabilityID: "CVE-2019-1549", PkgName: "openssl", InstalledVersion: "1.1.1c-r0", Severity: "HIGH"},
+			{VulnerabilityID: "CVE-2019-14697", PkgName: "musl", InstalledVersion: "1.1.22-r2", Severity: "CRITICAL"},
+		}}, nil)
+
+		newComparator(wrapper, 9).Compare(imageRef, primary)
+
+		wrapper.AssertExpectations(t)
+		assert.Equal(t, compared+1, testutil.ToFloat64(metrics.ShadowScans.WithLabelValues("compared")))
+		assert.Equal(t, secondaryOnly+1, testutil.ToFloat64(metrics.ShadowFindings.WithLabelValues("secondary")))
+		assert.Equal(t, disagreements+1, testutil.ToFloat64(metrics.ShadowSeverityDisagreements))
+	})
+
+	t.Run("Should not scan unsampled scan", func(t *testing.T) {
+		wrapper := tunnel.NewMockWrapper()
+
+		newComparator(wrapper, 10).Compare(imageRef, primary)
+
+		wrapper.AssertNotCalled(t, "Scan", imageRef)
+	})
+
+	t.Run("Should skip sampled scan when all shadow scans are running", func(t *testing.T) {
+		skipped := testutil.ToFloat64(metrics.ShadowScans.WithLabelValues("skipped"))
+		wrapper := tunnel.NewMockWrapper()
+		c := newComparator(wrapper, 0)
+		c.slots <- struct{}{}
+
+		c.Compare(imageRef, primary)
+
+		wrapper.AssertNotCalled(t, "Scan", imageRef)
+		assert.Equal(t, skipped+1, testutil.ToFloat64(metrics.ShadowScans.WithLabelValues("skipped")))
+	})
+
+	t.Run("Should record failed shadow scan", func(t *testing.T) {
+		failed := testutil.ToFloat64(metrics.ShadowScans.WithLabelValues("failed"))
+		wrapper := tunnel.NewMockWrapper()
+		wrapper.On("Scan", imageRef).Return(tunnel.Report{}, errors.New("running tunnel: exit status 1"))
+		c := newComparator(wrapper, 0)
+
+		c.Compare(imageRef, primary)
+
+		assert.Equal(t, failed+1, testutil.ToFloat64(metrics.ShadowScans.WithLabelValues("failed")))
+		assert.Empty(t, c.slots, "slot should be released")
+	})
+}
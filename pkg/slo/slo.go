@@ -0,0 +1,128 @@
+// Package slo tracks the service-level objective of scan jobs, e.g. 95% of scan jobs finish within 10 minutes of
+// being queued, and the burn rate of its error budget. The status is computed from the scan job summaries kept in
+// the store, so that all replicas of the adapter report the same status.
+package slo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+)
+
+// shortWindowRatio is the ratio of the long window to the short window of the burn rate alert. The short window
+// resets the alert soon after the burn stops.
+const shortWindowRatio = 12
+
+// BurnRate is the rate at which the error budget is consumed over a window. A burn rate of 1 consumes exactly the
+// error budget over the SLO window.
+type BurnRate struct {
+	WindowSeconds int64   `json:"window_seconds"`
+	Rate          float64 `json:"rate"`
+}
+
+// Status is the compliance with the SLO over its window.
+type Status struct {
+	// Objective is the ratio of scan jobs which must finish within the latency.
+	Objective      float64 `json:"objective"`
+	LatencySeconds int64   `json:"latency_seconds"`
+	WindowSeconds  int64   `json:"window_seconds"`
+	// Total is the number of scan jobs queued in the window, which either finished or should have finished.
+	Total int `json:"total"`
+	// Good is the number of scan jobs which finished within the latency.
+	Good int `json:"good"`
+	// Compliance is the ratio of good scan jobs, 1 if there are none.
+	Compliance float64 `json:"compliance"`
+	// ErrorBudgetRemaining is the ratio of the error budget not consumed yet, negative once it is exhausted.
+	ErrorBudgetRemaining float64 `json:"error_budget_remaining"`
+	// BurnRates are the burn rates over the long and the short window of the alert.
+	BurnRates []BurnRate `json:"burn_rates"`
+	// Alerting is true if the error budget burns faster than the threshold over both windows.
+	Alerting bool `json:"alerting"`
+}
+
+// Tracker wraps the Status method.
+// Status returns the current status of the SLO.
+type Tracker interface {
+	Status(ctx context.Context) (Status, error)
+}
+
+type tracker struct {
+	config etc.SLO
+	store  persistence.Store
+	now    func() time.Time
+}
+
+// NewTracker constructs a Tracker of the configured SLO, which lists the scan jobs in the given Store.
+func NewTracker(config etc.SLO, store persistence.Store) Tracker {
+	return &tracker{
+		config: config,
+		store:  store,
+		now:    time.Now,
+	}
+}
+
+func (t *tracker) Status(ctx context.Context) (Status, error) {
+	summaries, err := t.store.ListSummaries(ctx, 0)
+	if err != nil {
+		return Status{}, fmt.Errorf("listing scan jobs: %w", err)
+	}
+
+	now := t.now()
+	objective := t.config.Objective / 100
+	status := Status{
+		Objective:      objective,
+		LatencySeconds: int64(t.config.Latency.Seconds()),
+		WindowSeconds:  int64(t.config.Window.Seconds()),
+	}
+
+	status.Good, status.Total = t.count(summaries, now, t.config.Window)
+	status.Compliance = ratio(status.Good, status.Total)
+	status.ErrorBudgetRemaining = 1 - burnRate(status.Compliance, objective)
+
+	status.Alerting = true
+	for _, window := range []time.Duration{t.config.Window, t.config.Window / shortWindowRatio} {
+		good, total := t.count(summaries, now, window)
+		rate := burnRate(ratio(good, total), objective)
+		status.BurnRates = append(status.BurnRates, BurnRate{WindowSeconds: int64(window.Seconds()), Rate: rate})
+		status.Alerting = status.Alerting && rate > t.config.BurnRateThreshold
+	}
+	return status, nil
+}
+
+// count returns the number of good scan jobs and the total number of scan jobs queued within the given window.
+// Scan jobs still running within the latency are not counted yet, while scan jobs running for longer are bad.
+func (t *tracker) count(summaries []job.Summary, now time.Time, window time.Duration) (good, total int) {
+	for _, s := range summaries {
+		if s.CreatedAt.Before(now.Add(-window)) {
+			continue
+		}
+		switch {
+		case s.Status == job.Finished:
+			total++
+			if s.FinishedAt.Sub(s.CreatedAt) <= t.config.Latency {
+				good++
+			}
+		case s.Status == job.Failed:
+			total++
+		case now.Sub(s.CreatedAt) > t.config.Latency:
+			total++
+		}
+	}
+	return good, total
+}
+
+func ratio(good, total int) float64 {
+	if total == 0 {
+		return 1
+	}
+	return float64(good) / float64(total)
+}
+
+// burnRate returns the ratio of bad scan jobs to the ratio allowed by the objective.
+func burnRate(compliance, objective float64) float64 {
+	return (1 - compliance) / (1 - objective)
+}
@@ -0,0 +1,94 @@
+package slo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/mock"
+)
+
+func TestTracker_Status(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 11, 14, 22, 0, 0, 0, time.UTC)
+	config := etc.SLO{Objective: 90, Latency: 10 * time.Minute, Window: time.Hour, BurnRateThreshold: 5}
+
+	// finished returns a scan job queued the given time ago, which took the given duration to finish.
+	finished := func(ago, took time.Duration) job.Summary {
+		return job.Summary{Status: job.Finished, CreatedAt: now.Add(-ago), FinishedAt: now.Add(-ago + took)}
+	}
+
+	newTracker := func(summaries []job.Summary) Tracker {
+		store := mock.NewStore()
+		store.On("ListSummaries", ctx, 0).Return(summaries, nil)
+		tr := NewTracker(config, store).(*tracker)
+		tr.now = func() time.Time { return now }
+		return tr
+	}
+
+	t.Run("Should return compliance of scan jobs in window", func(t *testing.T) {
+		summaries := []job.Summary{
+			{Status: job.Queued, CreatedAt: now.Add(-time.Minute)},
+			{Status: job.Pending, CreatedAt: now.Add(-15 * time.Minute)},
+			finished(20*time.Minute, time.Minute),
+			finished(30*time.Minute, 2*time.Minute),
+			{Status: job.Failed, CreatedAt: now.Add(-40 * time.Minute)},
+			finished(50*time.Minute, 5*time.Minute),
+			finished(55*time.Minute, 15*time.Minute),
+			finished(2*time.Hour, time.Minute),
+		}
+
+		status, err := newTracker(summaries).Status(ctx)
+		require.NoError(t, err)
+
+		assert.Equal(t, 0.9, status.Objective)
+		assert.Equal(t, int64(600), status.LatencySeconds)
+		assert.Equal(t, int64(3600), status.WindowSeconds)
+		assert.Equal(t, 3, status.Good)
+		assert.Equal(t, 6, status.Total)
+		assert.InDelta(t, 0.5, status.Compliance, 1e-9)
+		assert.InDelta(t, -4, status.ErrorBudgetRemaining, 1e-9)
+		require.Len(t, status.BurnRates, 2)
+		assert.Equal(t, int64(3600), status.BurnRates[0].WindowSeconds)
+		assert.InDelta(t, 5, status.BurnRates[0].Rate, 1e-9)
+		assert.Equal(t, int64(300), status.BurnRates[1].WindowSeconds)
+		assert.InDelta(t, 0, status.BurnRates[1].Rate, 1e-9)
+		assert.False(t, status.Alerting)
+	})
+
+	t.Run("Should alert when error budget burns fast over both windows", func(t *testing.T) {
+		summaries := []job.Summary{
+			{Status: job.Failed, CreatedAt: now.Add(-time.Minute)},
+			{Status: job.Failed, CreatedAt: now.Add(-30 * time.Minute)},
+			finished(40*time.Minute, time.Minute),
+		}
+
+		status, err := newTracker(summaries).Status(ctx)
+		require.NoError(t, err)
+
+		assert.True(t, status.Alerting)
+	})
+
+	t.Run("Should comply when there are no scan jobs", func(t *testing.T) {
+		status, err := newTracker([]job.Summary{}).Status(ctx)
+		require.NoError(t, err)
+
+		assert.Equal(t, 1.0, status.Compliance)
+		assert.Equal(t, 1.0, status.ErrorBudgetRemaining)
+		assert.False(t, status.Alerting)
+	})
+
+	t.Run("Should return error when scan jobs cannot be listed", func(t *testing.T) {
+		store := mock.NewStore()
+		store.On("ListSummaries", ctx, 0).Return([]job.Summary(nil), errors.New("redis is down"))
+
+		_, err := NewTracker(config, store).Status(ctx)
+		assert.EqualError(t, err, "listing scan jobs: redis is down")
+	})
+}
@@ -0,0 +1,284 @@
+// Package support generates support bundles, i.e. tarballs collecting the sanitized configuration, version
+// info, recent logs, queue and store stats, and anonymized failed scan jobs of an adapter, so that they can be
+// attached to support escalations and bug reports without leaking credentials or image names.
+package support
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/queue"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+)
+
+// maxFailedJobs is the number of the most recent failed scan jobs included in bundles.
+const maxFailedJobs = 10
+
+// redacted replaces secrets in the configuration.
+const redacted = "REDACTED"
+
+var (
+	urlPattern   = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"']+`)
+	imagePattern = regexp.MustCompile(`[\w.-]+(?::\d+)?/[\w./-]+(?:@sha256:[0-9a-f]+|:[\w.-]+)?`)
+)
+
+// Generator wraps the Generate method.
+// Generate writes a gzipped tarball of the support bundle to the given writer.
+type Generator interface {
+	Generate(ctx context.Context, w io.Writer) error
+}
+
+type generator struct {
+	info     etc.BuildInfo
+	config   etc.Config
+	wrapper  tunnel.Wrapper
+	store    persistence.Store
+	enqueuer queue.Enqueuer
+	logs     *LogBuffer
+	now      func() time.Time
+}
+
+// NewGenerator constructs a Generator. Logs are only included if the given LogBuffer is not nil.
+func NewGenerator(info etc.BuildInfo, config etc.Config, wrapper tunnel.Wrapper, store persistence.Store, enqueuer queue.Enqueuer, logs *LogBuffer) Generator {
+	return &generator{
+		info:     info,
+		config:   config,
+		wrapper:  wrapper,
+		store:    store,
+		enqueuer: enqueuer,
+		logs:     logs,
+		now:      time.Now,
+	}
+}
+
+// version is the version.json file of the bundle.
+type version struct {
+	Adapter etc.BuildInfo       `json:"adapter"`
+	Tunnel  *tunnel.VersionInfo `json:"tunnel,omitempty"`
+	Error   string              `json:"error,omitempty"`
+}
+
+// stats is the stats.json file of the bundle. Either stats or the error getting them are included, so that an
+// unreachable Redis, a usual cause of escalations, does not prevent the bundle from being generated.
+type stats struct {
+	Queue      *job.QueueStats `json:"queue,omitempty"`
+	QueueError string          `json:"queue_error,omitempty"`
+	ScanJobs   map[string]int  `json:"scan_jobs,omitempty"`
+	StoreError string          `json:"store_error,omitempty"`
+}
+
+func (g *generator) Generate(ctx context.Context, w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	modTime := g.now()
+
+	summaries, storeErr := g.store.ListSummaries(ctx, 0)
+
+	files := []struct {
+		name    string
+		content interface{}
+	}{
+		{name: "version.json", content: g.version()},
+		{name: "config.json", content: Sanitize(g.config)},
+		{name: "stats.json", content: g.stats(ctx, summaries, storeErr)},
+		{name: "failed-jobs.json", content: failedJobs(summaries)},
+	}
+	for _, f := range files {
+		b, err := json.MarshalIndent(f.content, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshalling %s: %w", f.name, err)
+		}
+		if err = writeFile(tw, f.name, modTime, b); err != nil {
+			return err
+		}
+	}
+
+	if g.logs != nil {
+		var logs []byte
+		for _, record := range g.logs.Records() {
+			logs = append(logs, record...)
+		}
+		if err := writeFile(tw, "logs.jsonl", modTime, logs); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("closing tarball: %w", err)
+	}
+	return gz.Close()
+}
+
+func (g *generator) version() version {
+	v := version{Adapter: g.info}
+	info, err := g.wrapper.GetVersion()
+	if err != nil {
+		v.Error = err.Error()
+		return v
+	}
+	v.Tunnel = &info
+	return v
+}
+
+func (g *generator) stats(ctx context.Context, summaries []job.Summary, storeErr error) stats {
+	var s stats
+	if queueStats, err := g.enqueuer.Stats(ctx); err != nil {
+		s.QueueError = err.Error()
+	} else {
+		s.Queue = &queueStats
+	}
+	if storeErr != nil {
+		s.StoreError = storeErr.Error()
+		return s
+	}
+	s.ScanJobs = make(map[string]int)
+	for _, summary := range summaries {
+		s.ScanJobs[summary.Status.String()]++
+	}
+	return s
+}
+
+// failedJobs returns the anonymized summaries of the most recent failed scan jobs.
+func failedJobs(summaries []job.Summary) []job.Summary {
+	failed := make([]job.Summary, 0, maxFailedJobs)
+	for _, summary := range summaries {
+		if summary.Status != job.Failed {
+			continue
+		}
+		failed = append(failed, Anonymize(summary))
+		if len(failed) == maxFailedJobs {
+			break
+		}
+	}
+	return failed
+}
+
+// Anonymize replaces the ID of the given scan job with a hash, which still correlates the scan job with its
+// logs, and the URLs and image references in its error with placeholders.
+func Anonymize(summary job.Summary) job.Summary {
+	hash := sha256.Sum256([]byte(summary.ID))
+	summary.ID = hex.EncodeToString(hash[:8])
+	summary.Error = urlPattern.ReplaceAllString(summary.Error, "<url>")
+	summary.Error = imagePattern.ReplaceAllString(summary.Error, "<image>")
+	return summary
+}
+
+// Sanitize returns the given configuration with its secrets, i.e. tokens and the credentials, paths and queries
+// of URLs, redacted. Paths are redacted too, as webhook URLs usually embed their secrets in the path.
+func Sanitize(config etc.Config) etc.Config {
+	if config.Tunnel.GitHubToken != "" {
+		config.Tunnel.GitHubToken = redacted
+	}
+	if config.Tunnel.ServerToken != "" {
+		config.Tunnel.ServerToken = redacted
+	}
+	config.Tunnel.CredentialsRefreshHookURL = redactURL(config.Tunnel.CredentialsRefreshHookURL)
+	config.Auth.StaticTokens = redactAll(config.Auth.StaticTokens)
+	config.Auth.StaticAdminTokens = redactAll(config.Auth.StaticAdminTokens)
+	if config.Auth.SignatureSecret != "" {
+		config.Auth.SignatureSecret = redacted
+	}
+	if config.HarborWebhook.Secret != "" {
+		config.HarborWebhook.Secret = redacted
+	}
+	if config.HarborWebhook.RegistryPassword != "" {
+		config.HarborWebhook.RegistryPassword = redacted
+	}
+	config.Impact.WebhookURL = redactURL(config.Impact.WebhookURL)
+	config.CleanScans.WebhookURL = redactURL(config.CleanScans.WebhookURL)
+	config.Enrichment.Hooks = redactHooks(config.Enrichment.Hooks)
+	config.Notifications.SlackWebhookURL = redactURL(config.Notifications.SlackWebhookURL)
+	config.Notifications.TeamsWebhookURL = redactURL(config.Notifications.TeamsWebhookURL)
+	if config.Store.CredentialsKey != "" {
+		config.Store.CredentialsKey = redacted
+	}
+	config.RedisPool.URL = redactURL(config.RedisPool.URL)
+	config.PostgresStore.URL = redactURL(config.PostgresStore.URL)
+	if config.NVD.APIKey != "" {
+		config.NVD.APIKey = redacted
+	}
+	config.Audit.KafkaRESTURL = redactURL(config.Audit.KafkaRESTURL)
+	if config.Audit.S3SecretAccessKey != "" {
+		config.Audit.S3SecretAccessKey = redacted
+	}
+	if config.Policy.BundlePassword != "" {
+		config.Policy.BundlePassword = redacted
+	}
+	if config.VEX.OCIPassword != "" {
+		config.VEX.OCIPassword = redacted
+	}
+	return config
+}
+
+func redactAll(secrets []string) []string {
+	if len(secrets) == 0 {
+		return secrets
+	}
+	r := make([]string, len(secrets))
+	for i := range secrets {
+		r[i] = redacted
+	}
+	return r
+}
+
+// redactHooks redacts the URLs of the given HTTP enrichment hooks, keeping the paths of the executable ones.
+func redactHooks(hooks []string) []string {
+	if len(hooks) == 0 {
+		return hooks
+	}
+	r := make([]string, len(hooks))
+	for i, hook := range hooks {
+		if etc.IsHTTPHook(hook) {
+			hook = redactURL(hook)
+		}
+		r[i] = hook
+	}
+	return r
+}
+
+// redactURL redacts the password, path and query of the given URL, keeping its scheme and host. Unparsable URLs
+// are redacted as a whole.
+func redactURL(s string) string {
+	if s == "" {
+		return s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return redacted
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+		u.Path = "/" + redacted
+	}
+	u.RawPath, u.RawQuery, u.Fragment = "", "", ""
+	return u.String()
+}
+
+func writeFile(tw *tar.Writer, name string, modTime time.Time, content []byte) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(content)),
+		ModTime: modTime,
+	}); err != nil {
+		return fmt.Errorf("writing %s header: %w", name, err)
+	}
+	if _, err := tw.Write(content); err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package support
+
+import (
+	"bytes"
+	"sync"
+)
+
+// LogBuffer is an io.Writer keeping the most recent log records written to it, so that support bundles include
+// recent logs. Each Write is expected to hold a single record, as written by the handlers of log/slog.
+type LogBuffer struct {
+	mu      sync.Mutex
+	records [][]byte
+	// next is the index of the oldest record once the buffer is full.
+	next int
+}
+
+// NewLogBuffer constructs a LogBuffer keeping the given number of records.
+func NewLogBuffer(size int) *LogBuffer {
+	return &LogBuffer{records: make([][]byte, 0, max(size, 1))}
+}
+
+func (b *LogBuffer) Write(p []byte) (int, error) {
+	record := bytes.Clone(p)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.records) < cap(b.records) {
+		b.records = append(b.records, record)
+		return len(p), nil
+	}
+	b.records[b.next] = record
+	b.next = (b.next + 1) % len(b.records)
+	return len(p), nil
+}
+
+// Records returns the kept records, oldest first.
+func (b *LogBuffer) Records() [][]byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	records := make([][]byte, 0, len(b.records))
+	records = append(records, b.records[b.next:]...)
+	return append(records, b.records[:b.next]...)
+}
@@ -0,0 +1,42 @@
+package support
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogBuffer(t *testing.T) {
+	testCases := []struct {
+		name            string
+		writes          int
+		expectedRecords [][]byte
+	}{
+		{
+			name:            "Should keep all records when not full",
+			writes:          2,
+			expectedRecords: [][]byte{[]byte("record 0\n"), []byte("record 1\n")},
+		},
+		{
+			name:   "Should keep most recent records when full",
+			writes: 5,
+			expectedRecords: [][]byte{
+				[]byte("record 2\n"),
+				[]byte("record 3\n"),
+				[]byte("record 4\n"),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			logs := NewLogBuffer(3)
+			for i := 0; i < tc.writes; i++ {
+				_, err := fmt.Fprintf(logs, "record %d\n", i)
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedRecords, logs.Records())
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package telemetry
+
+import (
+	"context"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+)
+
+type store struct {
+	persistence.Store
+	reporter Reporter
+}
+
+// NewStore constructs a Store, which delegates to the given Store and counts the scan jobs successfully created,
+// finished and failed with the given Reporter.
+func NewStore(s persistence.Store, reporter Reporter) persistence.Store {
+	return &store{
+		Store:    s,
+		reporter: reporter,
+	}
+}
+
+func (s *store) Create(ctx context.Context, scanJob job.ScanJob) error {
+	if err := s.Store.Create(ctx, scanJob); err != nil {
+		return err
+	}
+	s.reporter.Count(job.Queued, "")
+	return nil
+}
+
+func (s *store) UpdateStatus(ctx context.Context, scanJobID string, newStatus job.ScanJobStatus, error ...string) error {
+	if err := s.Store.UpdateStatus(ctx, scanJobID, newStatus, error...); err != nil {
+		return err
+	}
+	if newStatus == job.Finished || newStatus == job.Failed {
+		var err string
+		if len(error) > 0 {
+			err = error[0]
+		}
+		s.reporter.Count(newStatus, err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/mock"
+)
+
+// recordingReporter records the counted scan jobs.
+type recordingReporter struct {
+	counted []job.ScanJobStatus
+	errors  []string
+}
+
+func (r *recordingReporter) Count(status job.ScanJobStatus, err string) {
+	r.counted = append(r.counted, status)
+	r.errors = append(r.errors, err)
+}
+
+func (r *recordingReporter) Close() {
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+
+	backend := mock.NewStore()
+	backend.On("Create", ctx, job.ScanJob{ID: "job:1"}).Return(nil)
+	backend.On("UpdateStatus", ctx, "job:1", job.Pending, []string(nil)).Return(nil)
+	backend.On("UpdateStatus", ctx, "job:1", job.Finished, []string(nil)).Return(nil)
+	backend.On("UpdateStatus", ctx, "job:2", job.Failed, []string{"pulling image: timeout"}).Return(nil)
+	backend.On("UpdateStatus", ctx, "job:3", job.Finished, []string(nil)).Return(xerrors.New("connection refused"))
+
+	reporter := &recordingReporter{}
+	s := NewStore(backend, reporter)
+
+	require.NoError(t, s.Create(ctx, job.ScanJob{ID: "job:1"}))
+	require.NoError(t, s.UpdateStatus(ctx, "job:1", job.Pending))
+	require.NoError(t, s.UpdateStatus(ctx, "job:1", job.Finished))
+	require.NoError(t, s.UpdateStatus(ctx, "job:2", job.Failed, "pulling image: timeout"))
+	assert.Error(t, s.UpdateStatus(ctx, "job:3", job.Finished))
+
+	assert.Equal(t, []job.ScanJobStatus{job.Queued, job.Finished, job.Failed}, reporter.counted)
+	assert.Equal(t, []string{"", "", "pulling image: timeout"}, reporter.errors)
+	backend.AssertExpectations(t)
+}
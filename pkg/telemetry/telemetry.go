@@ -0,0 +1,213 @@
+// Package telemetry reports aggregate, anonymized usage counters of the adapter, i.e. the number of scan jobs and
+// the classes of the errors of failed scan jobs, together with the version of the adapter, to help maintainers
+// prioritize. It is disabled unless explicitly opted in with SCANNER_TELEMETRY_ENABLED.
+//
+// Reports never carry registries, artifacts, vulnerabilities, error messages or anything else identifying the
+// installation: the instance ID is random and regenerated on each start, and only tells apart the reports of
+// replicas. Reporting is best effort: the counters of a period are dropped when the endpoint fails.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/httpx"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+)
+
+// sendTimeout bounds the request sending a report to the endpoint.
+const sendTimeout = 30 * time.Second
+
+// Classes of the errors of failed scan jobs.
+const (
+	ErrorClassRegistryUnauthorized = "registry_unauthorized"
+	ErrorClassRegistryThrottled    = "registry_throttled"
+	ErrorClassPinMismatch          = "pin_mismatch"
+	ErrorClassTimeout              = "timeout"
+	ErrorClassStalled              = "stalled"
+	ErrorClassOther                = "other"
+)
+
+// errorClassMarkers map the markers found in the errors of failed scan jobs to their class, in order of precedence.
+var errorClassMarkers = []struct {
+	marker string
+	class  string
+}{
+	{tunnel.ErrRegistryUnauthorized.Error(), ErrorClassRegistryUnauthorized},
+	{tunnel.ErrRegistryThrottled.Error(), ErrorClassRegistryThrottled},
+	{tunnel.ErrPinMismatch.Error(), ErrorClassPinMismatch},
+	{"deadline exceeded", ErrorClassTimeout},
+	{"scan job stalled", ErrorClassStalled},
+}
+
+// ClassifyError returns the class of the given error of a failed scan job, so that the error itself, which may
+// name registries and artifacts, is never reported.
+func ClassifyError(err string) string {
+	for _, m := range errorClassMarkers {
+		if strings.Contains(err, m.marker) {
+			return m.class
+		}
+	}
+	return ErrorClassOther
+}
+
+// Counters are the usage counters of a reporting period.
+type Counters struct {
+	Queued   int `json:"scans_queued"`
+	Finished int `json:"scans_finished"`
+	Failed   int `json:"scans_failed"`
+	// Errors are the numbers of failed scan jobs keyed by error class.
+	Errors map[string]int `json:"errors,omitempty"`
+}
+
+// Report is the report of the usage counters of a period sent to the endpoint.
+type Report struct {
+	InstanceID  string    `json:"instance_id"`
+	Version     string    `json:"version"`
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+	Counters
+}
+
+// Reporter counts scan jobs, and periodically reports the counters to the configured endpoint.
+type Reporter interface {
+	// Count increments the counter of scan jobs of the given status. The error of a failed scan job is only
+	// counted by class.
+	Count(status job.ScanJobStatus, err string)
+	// Close reports the counters of the current period and stops the Reporter.
+	Close()
+}
+
+type reporter struct {
+	config     etc.Telemetry
+	instanceID string
+	version    string
+	client     *http.Client
+	now        func() time.Time
+
+	mu          sync.Mutex
+	counters    Counters
+	periodStart time.Time
+
+	stop    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+}
+
+// NewReporter constructs a Reporter, which reports the counters to the configured endpoint at the configured
+// interval, with a random instance ID.
+func NewReporter(config etc.Telemetry, info etc.BuildInfo) Reporter {
+	r := &reporter{
+		config:     config,
+		instanceID: newInstanceID(),
+		version:    info.Version,
+		client:     httpx.Client("telemetry", sendTimeout),
+		now:        time.Now,
+		stop:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+	r.periodStart = r.now()
+	go r.run()
+	return r
+}
+
+func newInstanceID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (r *reporter) Count(status job.ScanJobStatus, err string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch status {
+	case job.Queued:
+		r.counters.Queued++
+	case job.Finished:
+		r.counters.Finished++
+	case job.Failed:
+		r.counters.Failed++
+		if r.counters.Errors == nil {
+			r.counters.Errors = make(map[string]int)
+		}
+		r.counters.Errors[ClassifyError(err)]++
+	}
+}
+
+func (r *reporter) Close() {
+	r.once.Do(func() {
+		close(r.stop)
+	})
+	<-r.stopped
+}
+
+func (r *reporter) run() {
+	defer close(r.stopped)
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.report()
+		case <-r.stop:
+			r.report()
+			return
+		}
+	}
+}
+
+// report sends the counters of the current period, and starts a new period.
+func (r *reporter) report() {
+	r.mu.Lock()
+	report := Report{
+		InstanceID:  r.instanceID,
+		Version:     r.version,
+		PeriodStart: r.periodStart,
+		PeriodEnd:   r.now(),
+		Counters:    r.counters,
+	}
+	r.counters = Counters{}
+	r.periodStart = report.PeriodEnd
+	r.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	if err := r.send(ctx, report); err != nil {
+		slog.Warn("Error while sending telemetry report", slog.String("err", err.Error()))
+	}
+}
+
+func (r *reporter) send(ctx context.Context, report Report) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("marshalling report: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.config.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "harbor-scanner-tunnel/"+r.version)
+
+	res, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending report: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("sending report: unexpected status %d", res.StatusCode)
+	}
+	return nil
+}
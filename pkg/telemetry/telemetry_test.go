@@ -0,0 +1,91 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+)
+
+func TestClassifyError(t *testing.T) {
+	testCases := []struct {
+		name          string
+		err           string
+		expectedClass string
+	}{
+		{
+			name:          "Should classify rejected registry credentials",
+			err:           "running tunnel: registry rejected credentials: GET https://core.harbor.domain/v2/: UNAUTHORIZED",
+			expectedClass: ErrorClassRegistryUnauthorized,
+		},
+		{
+			name:          "Should classify throttled image pulls",
+			err:           "running tunnel: registry throttled image pull: 429 Too Many Requests",
+			expectedClass: ErrorClassRegistryThrottled,
+		},
+		{
+			name:          "Should classify timeouts",
+			err:           "running tunnel: exit status 1: context deadline exceeded",
+			expectedClass: ErrorClassTimeout,
+		},
+		{
+			name:          "Should classify stalled scan jobs",
+			err:           "scan job stalled 3 times, the worker may be running out of resources",
+			expectedClass: ErrorClassStalled,
+		},
+		{
+			name:          "Should classify unknown errors as other",
+			err:           "decoding report: unexpected EOF",
+			expectedClass: ErrorClassOther,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedClass, ClassifyError(tc.err))
+		})
+	}
+}
+
+func TestReporter(t *testing.T) {
+	reports := make(chan Report, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "harbor-scanner-tunnel/v0.30.0", r.Header.Get("User-Agent"))
+		var report Report
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&report))
+		reports <- report
+	}))
+	defer server.Close()
+
+	r := NewReporter(etc.Telemetry{Enabled: true, Endpoint: server.URL, Interval: 24 * time.Hour},
+		etc.BuildInfo{Version: "v0.30.0"})
+	r.Count(job.Queued, "")
+	r.Count(job.Queued, "")
+	r.Count(job.Finished, "")
+	r.Count(job.Failed, "running tunnel: exit status 1: context deadline exceeded")
+	r.Close()
+
+	var report Report
+	select {
+	case report = <-reports:
+	default:
+		require.Fail(t, "report was not sent on close")
+	}
+	assert.Len(t, report.InstanceID, 32)
+	assert.Equal(t, "v0.30.0", report.Version)
+	assert.False(t, report.PeriodEnd.Before(report.PeriodStart))
+	assert.Equal(t, Counters{
+		Queued:   2,
+		Finished: 1,
+		Failed:   1,
+		Errors:   map[string]int{ErrorClassTimeout: 1},
+	}, report.Counters)
+}
@@ -0,0 +1,42 @@
+//go:build integration || component || conformance
+
+// Package containers starts throwaway dependencies for integration tests with testcontainers.
+package containers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	tc "github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+const redisImage = "redis:5.0.5"
+
+// StartRedis starts a Redis container, which is terminated when the test completes,
+// and returns the URL to connect to it.
+func StartRedis(t *testing.T) string {
+	t.Helper()
+
+	ctx := context.Background()
+	redisC, err := tc.GenericContainer(ctx, tc.GenericContainerRequest{
+		ContainerRequest: tc.ContainerRequest{
+			Image:        redisImage,
+			ExposedPorts: []string{"6379/tcp"},
+			WaitingFor:   wait.ForLog("Ready to accept connections"),
+		},
+		Started: true,
+	})
+	require.NoError(t, err, "should start redis container")
+	t.Cleanup(func() {
+		_ = redisC.Terminate(ctx)
+	})
+
+	host, err := redisC.Host(ctx)
+	require.NoError(t, err)
+	port, err := redisC.MappedPort(ctx, "6379")
+	require.NoError(t, err)
+	return fmt.Sprintf("redis://%s:%d", host, port.Int())
+}
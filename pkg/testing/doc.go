@@ -0,0 +1,7 @@
+// Package testing groups the harness used to write end-to-end tests of the scan pipeline without
+// external infrastructure:
+//
+//   - registry serves fixture images from an in-process fake OCI distribution registry,
+//   - harborclient drives the adapter the same way Harbor does,
+//   - containers starts throwaway dependencies, such as Redis, with testcontainers.
+package testing
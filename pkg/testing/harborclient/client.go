@@ -0,0 +1,160 @@
+// Package harborclient implements a fake Harbor, i.e. a client which drives the Scanner Adapter API
+// the same way Harbor does when it scans artifacts.
+package harborclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+)
+
+const (
+	mimeTypeScanRequest         = "application/vnd.scanner.adapter.scan.request+json; version=1.0"
+	mimeTypeVulnerabilityReport = "application/vnd.security.vulnerability.report; version=1.1"
+	mimeTypeMetadata            = "application/vnd.scanner.adapter.metadata+json; version=1.0"
+)
+
+// Client performs the operations Harbor performs against a Scanner Adapter.
+type Client struct {
+	endpointURL  string
+	httpClient   *http.Client
+	pollInterval time.Duration
+}
+
+// NewClient constructs a new Client with the given Scanner Adapter endpoint URL.
+func NewClient(endpointURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	// Like Harbor, do not follow the 302 Found responses sent while scan reports are not ready.
+	noRedirects := *httpClient
+	noRedirects.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return &Client{
+		endpointURL:  strings.TrimRight(endpointURL, "/"),
+		httpClient:   &noRedirects,
+		pollInterval: 100 * time.Millisecond,
+	}
+}
+
+// WithPollInterval sets the interval between two polls of a scan report which is not ready yet.
+func (c *Client) WithPollInterval(interval time.Duration) *Client {
+	c.pollInterval = interval
+	return c
+}
+
+// GetMetadata retrieves the metadata of the Scanner Adapter.
+func (c *Client) GetMetadata(ctx context.Context) (metadata harbor.ScannerAdapterMetadata, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpointURL+"/api/v1/metadata", nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Accept", mimeTypeMetadata)
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return metadata, c.toError(res)
+	}
+	err = json.NewDecoder(res.Body).Decode(&metadata)
+	return
+}
+
+// RequestScan sends a ScanRequest to the Scanner Adapter and receives the corresponding ScanResponse.
+func (c *Client) RequestScan(ctx context.Context, request harbor.ScanRequest) (scanResp harbor.ScanResponse, err error) {
+	b, err := json.Marshal(request)
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpointURL+"/api/v1/scan", bytes.NewReader(b))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", mimeTypeScanRequest)
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusAccepted {
+		return scanResp, c.toError(res)
+	}
+	err = json.NewDecoder(res.Body).Decode(&scanResp)
+	return
+}
+
+// GetScanReport polls for the ScanReport associated with the given ScanRequest ID until it is ready,
+// the Scanner Adapter responds with an error, or the context is done.
+func (c *Client) GetScanReport(ctx context.Context, scanRequestID string) (report harbor.ScanReport, err error) {
+	url := fmt.Sprintf("%s/api/v1/scan/%s/report", c.endpointURL, scanRequestID)
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return report, err
+		}
+		req.Header.Set("Accept", mimeTypeVulnerabilityReport)
+
+		res, err := c.httpClient.Do(req)
+		if err != nil {
+			return report, err
+		}
+
+		switch res.StatusCode {
+		case http.StatusFound:
+			_ = res.Body.Close()
+			select {
+			case <-ctx.Done():
+				return report, ctx.Err()
+			case <-time.After(c.pollInterval):
+			}
+		case http.StatusOK:
+			defer res.Body.Close()
+			err = json.NewDecoder(res.Body).Decode(&report)
+			return report, err
+		default:
+			defer res.Body.Close()
+			return report, c.toError(res)
+		}
+	}
+}
+
+// Scan requests a scan and waits for the corresponding ScanReport.
+func (c *Client) Scan(ctx context.Context, request harbor.ScanRequest) (harbor.ScanReport, error) {
+	scanResp, err := c.RequestScan(ctx, request)
+	if err != nil {
+		return harbor.ScanReport{}, fmt.Errorf("requesting scan: %w", err)
+	}
+	return c.GetScanReport(ctx, scanResp.ID)
+}
+
+// Error is returned when the Scanner Adapter responds with an unexpected status.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("unexpected response status %d: %s", e.StatusCode, e.Message)
+}
+
+func (c *Client) toError(res *http.Response) error {
+	var body struct {
+		Err harbor.Error `json:"error"`
+	}
+	_ = json.NewDecoder(res.Body).Decode(&body)
+	return &Error{StatusCode: res.StatusCode, Message: body.Err.Message}
+}
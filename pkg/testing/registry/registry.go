@@ -0,0 +1,276 @@
+// Package registry implements an in-process fake of the OCI distribution API serving fixture images.
+package registry
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	MediaTypeManifest = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeConfig   = "application/vnd.oci.image.config.v1+json"
+	MediaTypeLayer    = "application/vnd.oci.image.layer.v1.tar+gzip"
+)
+
+// Descriptor describes the content addressed by a manifest.
+type Descriptor struct {
+	MediaType string        `json:"mediaType"`
+	Digest    digest.Digest `json:"digest"`
+	Size      int64         `json:"size"`
+}
+
+// Manifest is an OCI image manifest.
+type Manifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType"`
+	Config        Descriptor   `json:"config"`
+	Layers        []Descriptor `json:"layers"`
+}
+
+// Image is a fixture image made of a manifest and the blobs it references.
+type Image struct {
+	Manifest []byte
+	Blobs    map[digest.Digest][]byte
+}
+
+// Digest returns the digest of the image manifest, i.e. what Harbor sends as artifact digest.
+func (i Image) Digest() digest.Digest {
+	return digest.FromBytes(i.Manifest)
+}
+
+// NewImage builds a single layer linux/amd64 image with the given files.
+func NewImage(files map[string][]byte) (Image, error) {
+	layer, err := tarGzip(files)
+	if err != nil {
+		return Image{}, fmt.Errorf("building layer: %w", err)
+	}
+
+	config, err := json.Marshal(map[string]any{
+		"architecture": "amd64",
+		"os":           "linux",
+		"created":      time.Unix(0, 0).UTC(),
+		"rootfs": map[string]any{
+			"type":     "layers",
+			"diff_ids": []digest.Digest{digest.FromBytes(layer)},
+		},
+	})
+	if err != nil {
+		return Image{}, fmt.Errorf("marshalling config: %w", err)
+	}
+
+	manifest, err := json.Marshal(Manifest{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeManifest,
+		Config:        Descriptor{MediaType: MediaTypeConfig, Digest: digest.FromBytes(config), Size: int64(len(config))},
+		Layers:        []Descriptor{{MediaType: MediaTypeLayer, Digest: digest.FromBytes(layer), Size: int64(len(layer))}},
+	})
+	if err != nil {
+		return Image{}, fmt.Errorf("marshalling manifest: %w", err)
+	}
+
+	return Image{
+		Manifest: manifest,
+		Blobs: map[digest.Digest][]byte{
+			digest.FromBytes(config): config,
+			digest.FromBytes(layer):  layer,
+		},
+	}, nil
+}
+
+// tarGzip archives the given files. Entries are sorted and timestamps zeroed, so that the
+// same files always produce the same layer digest.
+func tarGzip(files map[string][]byte) ([]byte, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, name := range names {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(files[name])),
+			ModTime: time.Unix(0, 0),
+		}); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var (
+	manifestPath = regexp.MustCompile(`^/v2/(.+)/manifests/([^/]+)$`)
+	blobPath     = regexp.MustCompile(`^/v2/(.+)/blobs/([^/]+)$`)
+)
+
+// Server is a fake registry serving pushed fixture images over HTTP.
+type Server struct {
+	server   *httptest.Server
+	username string
+	password string
+
+	mu        sync.RWMutex
+	manifests map[string][]byte // keyed by repository@reference
+	blobs     map[digest.Digest][]byte
+	requests  []string
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithBasicAuth requires clients to authenticate with the given credentials.
+func WithBasicAuth(username, password string) Option {
+	return func(s *Server) {
+		s.username = username
+		s.password = password
+	}
+}
+
+// NewServer starts a fake registry. Callers must Close it when done.
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		manifests: make(map[string][]byte),
+		blobs:     make(map[digest.Digest][]byte),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// URL returns the base URL of the registry, e.g. http://127.0.0.1:34567.
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// Host returns the host and port of the registry, as used in image references.
+func (s *Server) Host() string {
+	u, _ := url.Parse(s.server.URL)
+	return u.Host
+}
+
+// Push makes the image available under the given repository, both by tag and by digest.
+func (s *Server) Push(repository, tag string, image Image) digest.Digest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d := image.Digest()
+	s.manifests[repository+"@"+d.String()] = image.Manifest
+	if tag != "" {
+		s.manifests[repository+"@"+tag] = image.Manifest
+	}
+	for bd, blob := range image.Blobs {
+		s.blobs[bd] = blob
+	}
+	return d
+}
+
+// Requests returns the method and path of the requests served so far, e.g. "GET /v2/".
+func (s *Server) Requests() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]string(nil), s.requests...)
+}
+
+// Close shuts down the registry.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+	s.mu.Unlock()
+
+	if s.username != "" {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != s.username || password != s.password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="fake-registry"`)
+			writeError(w, http.StatusUnauthorized, "UNAUTHORIZED", "authentication required")
+			return
+		}
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "the registry is read-only")
+		return
+	}
+
+	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+
+	if r.URL.Path == "/v2/" || r.URL.Path == "/v2" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if m := manifestPath.FindStringSubmatch(r.URL.Path); m != nil {
+		s.mu.RLock()
+		manifest, ok := s.manifests[m[1]+"@"+m[2]]
+		s.mu.RUnlock()
+		if !ok {
+			writeError(w, http.StatusNotFound, "MANIFEST_UNKNOWN", "manifest unknown")
+			return
+		}
+		writeContent(w, r, MediaTypeManifest, manifest)
+		return
+	}
+
+	if m := blobPath.FindStringSubmatch(r.URL.Path); m != nil {
+		s.mu.RLock()
+		blob, ok := s.blobs[digest.Digest(m[2])]
+		s.mu.RUnlock()
+		if !ok {
+			writeError(w, http.StatusNotFound, "BLOB_UNKNOWN", "blob unknown to registry")
+			return
+		}
+		writeContent(w, r, "application/octet-stream", blob)
+		return
+	}
+
+	writeError(w, http.StatusNotFound, "NAME_UNKNOWN", "repository name not known to registry")
+}
+
+func writeContent(w http.ResponseWriter, r *http.Request, mediaType string, content []byte) {
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	w.Header().Set("Docker-Content-Digest", digest.FromBytes(content).String())
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		_, _ = w.Write(content)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"errors": []map[string]string{{"code": code, "message": message}},
+	})
+}
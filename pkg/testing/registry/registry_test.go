@@ -0,0 +1,78 @@
+package registry
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	image, err := NewImage(map[string][]byte{
+		"etc/os-release": []byte("ID=alpine\nVERSION_ID=3.18.4\n"),
+	})
+	require.NoError(t, err)
+
+	server := NewServer(WithBasicAuth("harbor", "s3cret"))
+	defer server.Close()
+
+	d := server.Push("library/alpine", "3.18", image)
+	assert.Equal(t, image.Digest(), d)
+
+	get := func(path string, authenticate bool) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, server.URL()+path, nil)
+		require.NoError(t, err)
+		if authenticate {
+			req.SetBasicAuth("harbor", "s3cret")
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = res.Body.Close() })
+		return res
+	}
+
+	t.Run("Should require authentication", func(t *testing.T) {
+		res := get("/v2/", false)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+		assert.Equal(t, `Basic realm="fake-registry"`, res.Header.Get("WWW-Authenticate"))
+	})
+
+	t.Run("Should serve manifest by tag and by digest", func(t *testing.T) {
+		for _, reference := range []string{"3.18", d.String()} {
+			res := get("/v2/library/alpine/manifests/"+reference, true)
+			require.Equal(t, http.StatusOK, res.StatusCode)
+			assert.Equal(t, MediaTypeManifest, res.Header.Get("Content-Type"))
+			assert.Equal(t, d.String(), res.Header.Get("Docker-Content-Digest"))
+		}
+	})
+
+	t.Run("Should serve blobs referenced by manifest", func(t *testing.T) {
+		var manifest Manifest
+		require.NoError(t, json.Unmarshal(image.Manifest, &manifest))
+
+		for _, descriptor := range append(manifest.Layers, manifest.Config) {
+			res := get("/v2/library/alpine/blobs/"+descriptor.Digest.String(), true)
+			require.Equal(t, http.StatusOK, res.StatusCode)
+			b, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			assert.Equal(t, descriptor.Digest, digest.FromBytes(b))
+		}
+	})
+
+	t.Run("Should respond with 404 for unknown manifest", func(t *testing.T) {
+		res := get("/v2/library/alpine/manifests/latest", true)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("Should build reproducible images", func(t *testing.T) {
+		again, err := NewImage(map[string][]byte{
+			"etc/os-release": []byte("ID=alpine\nVERSION_ID=3.18.4\n"),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, image.Digest(), again.Digest())
+	})
+}
@@ -0,0 +1,40 @@
+package throttle
+
+import (
+	"strings"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/ext"
+)
+
+// proxyEnvs are the environment variables replaced to route the traffic of Tunnel through the Proxy.
+var proxyEnvs = []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"}
+
+type ambassador struct {
+	ext.Ambassador
+	proxyURL string
+}
+
+// NewAmbassador wraps the given Ambassador, so that commands are run with their HTTP and HTTPS traffic
+// routed through the Proxy at the given URL. Hosts listed in NO_PROXY are not throttled.
+func NewAmbassador(delegate ext.Ambassador, proxyURL string) ext.Ambassador {
+	return &ambassador{Ambassador: delegate, proxyURL: proxyURL}
+}
+
+func (a *ambassador) Environ() []string {
+	var environ []string
+	for _, env := range a.Ambassador.Environ() {
+		if !isProxyEnv(env) {
+			environ = append(environ, env)
+		}
+	}
+	return append(environ, "HTTP_PROXY="+a.proxyURL, "HTTPS_PROXY="+a.proxyURL)
+}
+
+func isProxyEnv(env string) bool {
+	for _, name := range proxyEnvs {
+		if strings.HasPrefix(env, name+"=") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+// Package throttle caps the aggregate bandwidth of image pulls performed by Tunnel processes.
+//
+// Tunnel pulls images itself, so the adapter cannot throttle its connections directly. Instead, when a
+// bandwidth limit is configured, Tunnel is pointed at a local forward proxy via the HTTP_PROXY and HTTPS_PROXY
+// environment variables, and the proxy shares a single Limiter between all connections it tunnels.
+package throttle
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// maxChunk is the maximum number of bytes read at once from a throttled connection.
+const maxChunk = 32 * 1024
+
+// Limiter is a token bucket, which refills at the configured number of bytes per second.
+// It is safe for concurrent use by multiple goroutines.
+type Limiter struct {
+	rate  float64
+	burst int
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter constructs a Limiter allowing the given number of bytes per second.
+func NewLimiter(bytesPerSecond int64) *Limiter {
+	burst := maxChunk
+	if bytesPerSecond < maxChunk {
+		burst = int(bytesPerSecond)
+	}
+	return &Limiter{
+		rate:   float64(bytesPerSecond),
+		burst:  burst,
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// WaitN blocks until n bytes may be transferred or the context is done.
+// The bytes are reserved up front, so concurrent callers are served in order.
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > float64(l.burst) {
+		l.tokens = float64(l.burst)
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	deficit := -l.tokens
+	l.mu.Unlock()
+
+	if deficit <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(time.Duration(deficit / l.rate * float64(time.Second)))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Reader returns a reader, which reads from r no faster than the Limiter allows.
+func (l *Limiter) Reader(ctx context.Context, r io.Reader) io.Reader {
+	return &reader{ctx: ctx, limiter: l, r: r}
+}
+
+type reader struct {
+	ctx     context.Context
+	limiter *Limiter
+	r       io.Reader
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if len(p) > r.limiter.burst {
+		p = p[:r.limiter.burst]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.WaitN(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
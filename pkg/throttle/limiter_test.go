@@ -0,0 +1,51 @@
+package throttle
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter_Reader(t *testing.T) {
+	t.Run("Should read no faster than the rate", func(t *testing.T) {
+		data := bytes.Repeat([]byte{'x'}, maxChunk+500_000)
+		limiter := NewLimiter(1_000_000)
+
+		start := time.Now()
+		b, err := io.ReadAll(limiter.Reader(context.Background(), bytes.NewReader(data)))
+		require.NoError(t, err)
+
+		assert.Equal(t, data, b)
+		assert.GreaterOrEqual(t, time.Since(start), 450*time.Millisecond)
+	})
+
+	t.Run("Should share the rate between readers", func(t *testing.T) {
+		limiter := NewLimiter(1_000_000)
+		errs := make(chan error, 2)
+
+		start := time.Now()
+		for i := 0; i < 2; i++ {
+			go func() {
+				_, err := io.Copy(io.Discard, limiter.Reader(context.Background(), bytes.NewReader(make([]byte, 250_000))))
+				errs <- err
+			}()
+		}
+		require.NoError(t, <-errs)
+		require.NoError(t, <-errs)
+
+		assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+	})
+
+	t.Run("Should stop waiting when context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := io.ReadAll(NewLimiter(1).Reader(ctx, bytes.NewReader(make([]byte, 10))))
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
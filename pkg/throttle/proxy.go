@@ -0,0 +1,297 @@
+package throttle
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// hopHeaders are the headers meaningful only for a single connection, which must not be forwarded.
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// Proxy is a forward HTTP proxy, which throttles all responses it relays with a shared Limiter.
+// HTTPS traffic is tunnelled with the CONNECT method, so TLS is still terminated by Tunnel.
+//
+// Requests are forwarded to the upstream proxy configured in the environment of the adapter, if any.
+type Proxy struct {
+	limiter   *Limiter
+	proxyFunc func(*url.URL) (*url.URL, error)
+	transport *http.Transport
+
+	transferred atomic.Int64
+	throughput  atomic.Int64
+
+	listener net.Listener
+	server   *http.Server
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewProxy constructs a Proxy relaying at most the given number of bytes per second in total.
+func NewProxy(bytesPerSecond int64) *Proxy {
+	proxyFunc := httpproxy.FromEnvironment().ProxyFunc()
+	p := &Proxy{
+		limiter:   NewLimiter(bytesPerSecond),
+		proxyFunc: proxyFunc,
+		done:      make(chan struct{}),
+	}
+	p.transport = &http.Transport{
+		Proxy: func(req *http.Request) (*url.URL, error) {
+			return p.proxyFunc(req.URL)
+		},
+		DialContext:           (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+		IdleConnTimeout:       90 * time.Second,
+		ResponseHeaderTimeout: time.Minute,
+	}
+	return p
+}
+
+// Start listens on the given address, e.g. 127.0.0.1:0, and serves proxy requests in the background.
+func (p *Proxy) Start(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", addr, err)
+	}
+	p.listener = listener
+	p.server = &http.Server{Handler: p, ReadHeaderTimeout: 30 * time.Second}
+
+	p.wg.Add(2)
+	go func() {
+		defer p.wg.Done()
+		if err := p.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Error while serving pull proxy", slog.String("err", err.Error()))
+		}
+	}()
+	go func() {
+		defer p.wg.Done()
+		p.measure()
+	}()
+	return nil
+}
+
+// URL returns the URL of the started Proxy.
+func (p *Proxy) URL() string {
+	return "http://" + p.listener.Addr().String()
+}
+
+// Close stops accepting new connections. Tunnelled connections are closed by their ends.
+func (p *Proxy) Close() error {
+	close(p.done)
+	err := p.server.Close()
+	p.wg.Wait()
+	return err
+}
+
+// Transferred returns the total number of bytes relayed to clients.
+func (p *Proxy) Transferred() int64 {
+	return p.transferred.Load()
+}
+
+// Throughput returns the number of bytes relayed to clients during the last second.
+func (p *Proxy) Throughput() int64 {
+	return p.throughput.Load()
+}
+
+func (p *Proxy) measure() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	previous := p.transferred.Load()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+			current := p.transferred.Load()
+			p.throughput.Store(current - previous)
+			previous = current
+		}
+	}
+}
+
+func (p *Proxy) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodConnect {
+		p.tunnel(res, req)
+		return
+	}
+	p.forward(res, req)
+}
+
+func (p *Proxy) forward(res http.ResponseWriter, req *http.Request) {
+	if !req.URL.IsAbs() {
+		http.Error(res, "absolute request URI required", http.StatusBadRequest)
+		return
+	}
+
+	outReq := req.Clone(req.Context())
+	outReq.RequestURI = ""
+	for _, h := range hopHeaders {
+		outReq.Header.Del(h)
+	}
+
+	outRes, err := p.transport.RoundTrip(outReq)
+	if err != nil {
+		slog.Warn("Error while forwarding pull request", slog.String("host", req.URL.Host),
+			slog.String("err", err.Error()))
+		http.Error(res, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer func() { _ = outRes.Body.Close() }()
+
+	for _, h := range hopHeaders {
+		outRes.Header.Del(h)
+	}
+	for key, values := range outRes.Header {
+		res.Header()[key] = values
+	}
+	res.WriteHeader(outRes.StatusCode)
+	_, _ = io.Copy(res, p.throttle(req.Context(), outRes.Body))
+}
+
+func (p *Proxy) tunnel(res http.ResponseWriter, req *http.Request) {
+	upstream, upstreamReader, err := p.dial(req.Context(), req.Host)
+	if err != nil {
+		slog.Warn("Error while connecting to pull target", slog.String("host", req.Host),
+			slog.String("err", err.Error()))
+		http.Error(res, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	hijacker, ok := res.(http.Hijacker)
+	if !ok {
+		_ = upstream.Close()
+		http.Error(res, "hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+	client, clientBuf, err := hijacker.Hijack()
+	if err != nil {
+		_ = upstream.Close()
+		slog.Warn("Error while hijacking pull connection", slog.String("err", err.Error()))
+		return
+	}
+
+	if _, err = client.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n")); err != nil {
+		_ = client.Close()
+		_ = upstream.Close()
+		return
+	}
+
+	go func() {
+		_, _ = io.Copy(upstream, clientBuf.Reader)
+		closeWrite(upstream)
+	}()
+	go func() {
+		_, _ = io.Copy(client, p.throttle(context.Background(), upstreamReader))
+		_ = client.Close()
+		_ = upstream.Close()
+	}()
+}
+
+// dial connects to the given host, through the upstream proxy if one is configured for it.
+// It returns the connection and the reader to read from it, which may hold data buffered while
+// establishing the tunnel through the upstream proxy.
+func (p *Proxy) dial(ctx context.Context, host string) (net.Conn, io.Reader, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+
+	upstreamProxy, err := p.proxyFunc(&url.URL{Scheme: "https", Host: host})
+	if err != nil {
+		return nil, nil, fmt.Errorf("resolving upstream proxy: %w", err)
+	}
+	if upstreamProxy == nil {
+		conn, err := dialer.DialContext(ctx, "tcp", host)
+		if err != nil {
+			return nil, nil, err
+		}
+		return conn, conn, nil
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", canonicalAddr(upstreamProxy))
+	if err != nil {
+		return nil, nil, fmt.Errorf("dialing upstream proxy: %w", err)
+	}
+
+	connectReq := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: host},
+		Host:   host,
+		Header: make(http.Header),
+	}
+	if u := upstreamProxy.User; u != nil {
+		password, _ := u.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(u.Username() + ":" + password))
+		connectReq.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err = connectReq.Write(conn); err != nil {
+		_ = conn.Close()
+		return nil, nil, fmt.Errorf("writing CONNECT request to upstream proxy: %w", err)
+	}
+
+	br := bufio.NewReader(conn)
+	connectRes, err := http.ReadResponse(br, connectReq)
+	if err != nil {
+		_ = conn.Close()
+		return nil, nil, fmt.Errorf("reading CONNECT response from upstream proxy: %w", err)
+	}
+	_ = connectRes.Body.Close()
+	if connectRes.StatusCode != http.StatusOK {
+		_ = conn.Close()
+		return nil, nil, fmt.Errorf("upstream proxy refused CONNECT: %s", connectRes.Status)
+	}
+	return conn, br, nil
+}
+
+// throttle limits the bandwidth of reading from r and accounts the bytes read.
+func (p *Proxy) throttle(ctx context.Context, r io.Reader) io.Reader {
+	return &countingReader{r: p.limiter.Reader(ctx, r), count: &p.transferred}
+}
+
+type countingReader struct {
+	r     io.Reader
+	count *atomic.Int64
+}
+
+func (r *countingReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.count.Add(int64(n))
+	return n, err
+}
+
+func closeWrite(conn net.Conn) {
+	if c, ok := conn.(interface{ CloseWrite() error }); ok {
+		_ = c.CloseWrite()
+		return
+	}
+	_ = conn.Close()
+}
+
+func canonicalAddr(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	if u.Scheme == "https" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}
@@ -0,0 +1,96 @@
+package throttle
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/ext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxy(t *testing.T) {
+	blob := bytes.Repeat([]byte{'x'}, maxChunk+250_000)
+	handler := http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+		_, _ = res.Write(blob)
+	})
+
+	testCases := []struct {
+		name   string
+		server *httptest.Server
+	}{
+		{
+			name:   "Should forward HTTP requests",
+			server: httptest.NewServer(handler),
+		},
+		{
+			name:   "Should tunnel HTTPS connections",
+			server: httptest.NewTLSServer(handler),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer tc.server.Close()
+
+			proxy := NewProxy(1_000_000)
+			proxy.proxyFunc = func(*url.URL) (*url.URL, error) { return nil, nil }
+			require.NoError(t, proxy.Start("127.0.0.1:0"))
+			defer func() { _ = proxy.Close() }()
+
+			proxyURL, err := url.Parse(proxy.URL())
+			require.NoError(t, err)
+			client := &http.Client{Transport: &http.Transport{
+				Proxy:           http.ProxyURL(proxyURL),
+				TLSClientConfig: &tls.Config{RootCAs: certPool(tc.server)},
+			}}
+
+			start := time.Now()
+			res, err := client.Get(tc.server.URL + "/v2/library/alpine/blobs/sha256:123")
+			require.NoError(t, err)
+			b, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			_ = res.Body.Close()
+
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+			assert.Equal(t, blob, b)
+			assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+			assert.GreaterOrEqual(t, proxy.Transferred(), int64(len(blob)))
+		})
+	}
+}
+
+func TestAmbassador_Environ(t *testing.T) {
+	delegate := ext.NewMockAmbassador()
+	delegate.On("Environ").Return([]string{
+		"HOME=/home/scanner",
+		"HTTPS_PROXY=http://corporate:3128",
+		"http_proxy=http://corporate:3128",
+		"NO_PROXY=core.harbor.domain",
+	})
+
+	environ := NewAmbassador(delegate, "http://127.0.0.1:41234").Environ()
+
+	assert.Equal(t, []string{
+		"HOME=/home/scanner",
+		"NO_PROXY=core.harbor.domain",
+		"HTTP_PROXY=http://127.0.0.1:41234",
+		"HTTPS_PROXY=http://127.0.0.1:41234",
+	}, environ)
+}
+
+func certPool(server *httptest.Server) *x509.CertPool {
+	if server.Certificate() == nil {
+		return nil
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	return pool
+}
@@ -0,0 +1,230 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// The OTLP/JSON encoding of export requests, which differs from the canonical JSON mapping of protobuf in that
+// trace and span IDs are hex encoded. 64-bit integers are encoded as strings.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes,omitempty"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name    string `json:"name"`
+		Version string `json:"version,omitempty"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Events            []otlpEvent    `json:"events,omitempty"`
+		Status            otlpStatus     `json:"status"`
+	}
+	otlpEvent struct {
+		TimeUnixNano string         `json:"timeUnixNano"`
+		Name         string         `json:"name"`
+		Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string       `json:"key"`
+		Value otlpAnyValue `json:"value"`
+	}
+	otlpAnyValue struct {
+		StringValue *string         `json:"stringValue,omitempty"`
+		BoolValue   *bool           `json:"boolValue,omitempty"`
+		IntValue    *string         `json:"intValue,omitempty"`
+		DoubleValue *float64        `json:"doubleValue,omitempty"`
+		ArrayValue  *otlpArrayValue `json:"arrayValue,omitempty"`
+	}
+	otlpArrayValue struct {
+		Values []otlpAnyValue `json:"values"`
+	}
+)
+
+// Status codes of OTLP spans, which are numbered differently from the codes of the API.
+const (
+	otlpStatusOK    = 1
+	otlpStatusError = 2
+)
+
+type otlpExporter struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+// NewOTLPExporter constructs a span exporter, which posts spans in the OTLP/JSON encoding to the /v1/traces path
+// of the OTLP/HTTP receiver at the given endpoint, e.g. an OpenTelemetry Collector. The given headers are added to
+// each request, e.g. to authenticate to a hosted receiver.
+func NewOTLPExporter(endpoint string, headers map[string]string, client *http.Client) sdktrace.SpanExporter {
+	return &otlpExporter{
+		url:     strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		headers: headers,
+		client:  client,
+	}
+}
+
+func (e *otlpExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(toOTLPRequest(spans))
+	if err != nil {
+		return fmt.Errorf("marshalling spans: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.headers {
+		req.Header.Set(key, value)
+	}
+
+	res, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("exporting spans: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("exporting spans: unexpected status %d: %s", res.StatusCode, bytes.TrimSpace(message))
+	}
+	return nil
+}
+
+func (e *otlpExporter) Shutdown(_ context.Context) error {
+	return nil
+}
+
+// toOTLPRequest groups the given spans by resource and instrumentation scope.
+func toOTLPRequest(spans []sdktrace.ReadOnlySpan) otlpRequest {
+	var request otlpRequest
+	resources := make(map[attribute.Distinct]int)
+	scopes := make(map[attribute.Distinct]map[otlpScope]int)
+	for _, span := range spans {
+		resource := span.Resource().Equivalent()
+		r, ok := resources[resource]
+		if !ok {
+			r = len(request.ResourceSpans)
+			resources[resource] = r
+			scopes[resource] = make(map[otlpScope]int)
+			request.ResourceSpans = append(request.ResourceSpans, otlpResourceSpans{
+				Resource: otlpResource{Attributes: toOTLPAttributes(span.Resource().Attributes())},
+			})
+		}
+
+		scope := otlpScope{Name: span.InstrumentationScope().Name, Version: span.InstrumentationScope().Version}
+		s, ok := scopes[resource][scope]
+		if !ok {
+			s = len(request.ResourceSpans[r].ScopeSpans)
+			scopes[resource][scope] = s
+			request.ResourceSpans[r].ScopeSpans = append(request.ResourceSpans[r].ScopeSpans, otlpScopeSpans{Scope: scope})
+		}
+		request.ResourceSpans[r].ScopeSpans[s].Spans = append(request.ResourceSpans[r].ScopeSpans[s].Spans, toOTLPSpan(span))
+	}
+	return request
+}
+
+func toOTLPSpan(span sdktrace.ReadOnlySpan) otlpSpan {
+	s := otlpSpan{
+		TraceID:           span.SpanContext().TraceID().String(),
+		SpanID:            span.SpanContext().SpanID().String(),
+		Name:              span.Name(),
+		Kind:              int(span.SpanKind()),
+		StartTimeUnixNano: strconv.FormatInt(span.StartTime().UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.EndTime().UnixNano(), 10),
+		Attributes:        toOTLPAttributes(span.Attributes()),
+	}
+	if span.Parent().HasSpanID() {
+		s.ParentSpanID = span.Parent().SpanID().String()
+	}
+	for _, event := range span.Events() {
+		s.Events = append(s.Events, otlpEvent{
+			TimeUnixNano: strconv.FormatInt(event.Time.UnixNano(), 10),
+			Name:         event.Name,
+			Attributes:   toOTLPAttributes(event.Attributes),
+		})
+	}
+	switch span.Status().Code {
+	case codes.Ok:
+		s.Status = otlpStatus{Code: otlpStatusOK}
+	case codes.Error:
+		s.Status = otlpStatus{Code: otlpStatusError, Message: span.Status().Description}
+	}
+	return s
+}
+
+func toOTLPAttributes(attributes []attribute.KeyValue) []otlpKeyValue {
+	var values []otlpKeyValue
+	for _, kv := range attributes {
+		values = append(values, otlpKeyValue{Key: string(kv.Key), Value: toOTLPValue(kv.Value)})
+	}
+	return values
+}
+
+func toOTLPValue(value attribute.Value) otlpAnyValue {
+	switch value.Type() {
+	case attribute.BOOL:
+		v := value.AsBool()
+		return otlpAnyValue{BoolValue: &v}
+	case attribute.INT64:
+		v := strconv.FormatInt(value.AsInt64(), 10)
+		return otlpAnyValue{IntValue: &v}
+	case attribute.FLOAT64:
+		v := value.AsFloat64()
+		return otlpAnyValue{DoubleValue: &v}
+	case attribute.BOOLSLICE:
+		return toOTLPArray(value.AsBoolSlice(), attribute.BoolValue)
+	case attribute.INT64SLICE:
+		return toOTLPArray(value.AsInt64Slice(), attribute.Int64Value)
+	case attribute.FLOAT64SLICE:
+		return toOTLPArray(value.AsFloat64Slice(), attribute.Float64Value)
+	case attribute.STRINGSLICE:
+		return toOTLPArray(value.AsStringSlice(), attribute.StringValue)
+	default:
+		v := value.Emit()
+		return otlpAnyValue{StringValue: &v}
+	}
+}
+
+func toOTLPArray[T any](elements []T, toValue func(T) attribute.Value) otlpAnyValue {
+	array := &otlpArrayValue{Values: make([]otlpAnyValue, len(elements))}
+	for i, element := range elements {
+		array.Values[i] = toOTLPValue(toValue(element))
+	}
+	return otlpAnyValue{ArrayValue: array}
+}
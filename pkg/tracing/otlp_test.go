@@ -0,0 +1,104 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestOTLPExporter(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("0af7651916cd43dd8448eb211c80319c")
+	parentID, _ := trace.SpanIDFromHex("b7ad6b7169203331")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	started := time.Unix(1700000000, 0)
+
+	spans := tracetest.SpanStubs{
+		{
+			Name: "ScanJob",
+			SpanContext: trace.NewSpanContext(trace.SpanContextConfig{
+				TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled,
+			}),
+			Parent:    trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: parentID}),
+			SpanKind:  trace.SpanKindConsumer,
+			StartTime: started,
+			EndTime:   started.Add(1500 * time.Millisecond),
+			Attributes: []attribute.KeyValue{
+				ScanJobID("job:123"),
+				attribute.Int("vulnerabilities", 42),
+			},
+			Status:                 sdktrace.Status{Code: codes.Error, Description: "running tunnel wrapper: exit status 1"},
+			Resource:               sdkresource.NewSchemaless(attribute.String("service.name", "harbor-scanner-tunnel")),
+			InstrumentationLibrary: instrumentation.Scope{Name: instrumentationName},
+		},
+	}.Snapshots()
+
+	t.Run("Should post spans in OTLP/JSON encoding", func(t *testing.T) {
+		var body []byte
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1/traces", r.URL.Path)
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			assert.Equal(t, "s3cret", r.Header.Get("X-Api-Key"))
+			body, _ = io.ReadAll(r.Body)
+		}))
+		defer server.Close()
+
+		exporter := NewOTLPExporter(server.URL+"/", map[string]string{"x-api-key": "s3cret"}, server.Client())
+		require.NoError(t, exporter.ExportSpans(context.Background(), spans))
+
+		assert.JSONEq(t, `{
+  "resourceSpans": [
+    {
+      "resource": {
+        "attributes": [{"key": "service.name", "value": {"stringValue": "harbor-scanner-tunnel"}}]
+      },
+      "scopeSpans": [
+        {
+          "scope": {"name": "github.com/khulnasoft-lab/harbor-scanner-tunnel"},
+          "spans": [
+            {
+              "traceId": "0af7651916cd43dd8448eb211c80319c",
+              "spanId": "00f067aa0ba902b7",
+              "parentSpanId": "b7ad6b7169203331",
+              "name": "ScanJob",
+              "kind": 5,
+              "startTimeUnixNano": "1700000000000000000",
+              "endTimeUnixNano": "1700000001500000000",
+              "attributes": [
+                {"key": "scan_job.id", "value": {"stringValue": "job:123"}},
+                {"key": "vulnerabilities", "value": {"intValue": "42"}}
+              ],
+              "status": {"code": 2, "message": "running tunnel wrapper: exit status 1"}
+            }
+          ]
+        }
+      ]
+    }
+  ]
+}`, string(body))
+	})
+
+	t.Run("Should return error when receiver rejects spans", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		}))
+		defer server.Close()
+
+		exporter := NewOTLPExporter(server.URL, nil, server.Client())
+		err := exporter.ExportSpans(context.Background(), spans)
+
+		assert.EqualError(t, err, "exporting spans: unexpected status 401: unauthorized")
+	})
+}
@@ -0,0 +1,73 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+)
+
+// attributeScanJobID is the span attribute holding the identifier of a scan job.
+const attributeScanJobID = attribute.Key("scan_job.id")
+
+// ScanJobID returns the span attribute holding the given identifier of a scan job.
+func ScanJobID(id string) attribute.KeyValue {
+	return attributeScanJobID.String(id)
+}
+
+// store traces the writes of the decorated store. Reads are not traced, as reports are polled by Harbor
+// outside of the trace of a scan request.
+type store struct {
+	persistence.Store
+}
+
+// NewStore decorates the given store with a span for each write.
+func NewStore(delegate persistence.Store) persistence.Store {
+	return &store{Store: delegate}
+}
+
+func (s *store) Create(ctx context.Context, scanJob job.ScanJob) (err error) {
+	ctx, span := s.start(ctx, "Create", scanJob.ID)
+	defer func() { End(span, err) }()
+	return s.Store.Create(ctx, scanJob)
+}
+
+func (s *store) UpdateStatus(ctx context.Context, scanJobID string, newStatus job.ScanJobStatus, error ...string) (err error) {
+	ctx, span := s.start(ctx, "UpdateStatus", scanJobID)
+	span.SetAttributes(attribute.String("scan_job.status", newStatus.String()))
+	defer func() { End(span, err) }()
+	return s.Store.UpdateStatus(ctx, scanJobID, newStatus, error...)
+}
+
+func (s *store) UpdateReport(ctx context.Context, scanJobID string, report harbor.ScanReport) (err error) {
+	ctx, span := s.start(ctx, "UpdateReport", scanJobID)
+	defer func() { End(span, err) }()
+	return s.Store.UpdateReport(ctx, scanJobID, report)
+}
+
+func (s *store) Replace(ctx context.Context, scanJob job.ScanJob) (err error) {
+	ctx, span := s.start(ctx, "Replace", scanJob.ID)
+	defer func() { End(span, err) }()
+	return s.Store.Replace(ctx, scanJob)
+}
+
+func (s *store) UpdateSBOM(ctx context.Context, scanJobID string, sbom harbor.SBOMReport) (err error) {
+	ctx, span := s.start(ctx, "UpdateSBOM", scanJobID)
+	defer func() { End(span, err) }()
+	return s.Store.UpdateSBOM(ctx, scanJobID, sbom)
+}
+
+func (s *store) Extend(ctx context.Context, scanJobID string, ttl time.Duration) (err error) {
+	ctx, span := s.start(ctx, "Extend", scanJobID)
+	defer func() { End(span, err) }()
+	return s.Store.Extend(ctx, scanJobID, ttl)
+}
+
+func (s *store) start(ctx context.Context, operation, scanJobID string) (context.Context, trace.Span) {
+	return Start(ctx, "Store."+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(ScanJobID(scanJobID)))
+}
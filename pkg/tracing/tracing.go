@@ -0,0 +1,85 @@
+// Package tracing follows scan requests with OpenTelemetry traces, from the request accepted by the API through
+// the job queue, the Tunnel invocation, the transformation of the report and the writes to the store.
+//
+// Nothing is traced unless a tracer provider is installed with NewTracerProvider, as spans are started with the
+// global tracer provider, which is a no-op by default.
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+)
+
+const instrumentationName = "github.com/khulnasoft-lab/harbor-scanner-tunnel"
+
+// NewTracerProvider constructs a tracer provider exporting the sampled spans to the OTLP/HTTP receiver of the
+// given config, and installs it as the global tracer provider along with the W3C Trace Context propagator.
+// The returned provider must be shut down to flush the spans not yet exported.
+func NewTracerProvider(config etc.Tracing, info etc.BuildInfo, client *http.Client) *sdktrace.TracerProvider {
+	resource := sdkresource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(config.ServiceName),
+		semconv.ServiceVersion(info.Version),
+	)
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(NewOTLPExporter(config.OTLPEndpoint, config.Headers(), client)),
+		sdktrace.WithResource(resource),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return provider
+}
+
+// Start starts a span with the given name, which is a child of the span of the given context if any.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// End ends the given span, which is marked as failed if the given error is not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		fail(span, err)
+	}
+	span.End()
+}
+
+// Fail marks the span of the given context as failed with the given error.
+func Fail(ctx context.Context, err error) {
+	fail(trace.SpanFromContext(ctx), err)
+}
+
+func fail(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// Inject returns the trace context of the given context as a map, which is carried by the scan job from the
+// enqueuer to the worker. The map is nil if the context is not traced.
+func Inject(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
+
+// Extract returns a copy of the given context holding the trace context of the given map, as returned by Inject.
+func Extract(ctx context.Context, carrier map[string]string) context.Context {
+	if len(carrier) == 0 {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(carrier))
+}
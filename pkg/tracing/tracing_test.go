@@ -0,0 +1,39 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestInjectExtract(t *testing.T) {
+	t.Run("Should carry trace context from enqueuer to worker", func(t *testing.T) {
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+		defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+		traceID, _ := trace.TraceIDFromHex("0af7651916cd43dd8448eb211c80319c")
+		spanID, _ := trace.SpanIDFromHex("b7ad6b7169203331")
+		ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled,
+		}))
+
+		carrier := Inject(ctx)
+		assert.Equal(t, map[string]string{
+			"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		}, carrier)
+
+		extracted := trace.SpanContextFromContext(Extract(context.Background(), carrier))
+		assert.Equal(t, traceID, extracted.TraceID())
+		assert.Equal(t, spanID, extracted.SpanID())
+		assert.True(t, extracted.IsRemote())
+	})
+
+	t.Run("Should not carry trace context when tracing is disabled", func(t *testing.T) {
+		assert.Nil(t, Inject(context.Background()))
+		assert.Equal(t, context.Background(), Extract(context.Background(), nil))
+	})
+}
@@ -0,0 +1,30 @@
+package tunnel
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrRegistryUnauthorized is wrapped by the errors of Tunnel processes failing because the registry rejected
+// their credentials, typically because Harbor rotated the credentials of its robot account while the scan
+// job was queued.
+var ErrRegistryUnauthorized = errors.New("registry rejected credentials")
+
+// unauthorizedMarkers are found in the output of Tunnel processes failing because the registry responded
+// with the 401 status.
+var unauthorizedMarkers = []string{
+	"401 Unauthorized",
+	"UNAUTHORIZED",
+	"unauthorized: authentication required",
+}
+
+// isUnauthorized returns true if the given error of a Tunnel process is caused by the registry rejecting
+// its credentials.
+func isUnauthorized(err error) bool {
+	for _, marker := range unauthorizedMarkers {
+		if strings.Contains(err.Error(), marker) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package tunnel
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// CacheModeShared runs all Tunnel processes with the same cache dir. It is the fastest mode, as image layers
+	// analyzed once are reused by all workers, but concurrent processes may corrupt the cache.
+	CacheModeShared = "shared"
+	// CacheModeIsolated runs concurrent Tunnel processes with distinct cache dirs, each holding its own copy of
+	// the vulnerability database and of the analysis cache.
+	CacheModeIsolated = "isolated"
+)
+
+// isolatedCachesDir is the subdirectory of the configured cache dir holding the isolated cache dirs.
+const isolatedCachesDir = "isolated"
+
+// scanCacheDir is the subdirectory of a cache dir holding the analysis cache of image layers. Unlike the
+// vulnerability database, it is written by every scan, hence it is the part of the cache that gets corrupted.
+const scanCacheDir = "fanal"
+
+// corruptedCacheMarkers are found in the output of Tunnel processes failing because of a corrupted cache.
+var corruptedCacheMarkers = []string{
+	"cache may be corrupted",
+	"failed to get the cache",
+	"unable to initialize the cache",
+	"invalid database",
+	"database file size too small",
+}
+
+// isCacheCorrupted returns true if the given error of a Tunnel process is caused by a corrupted cache.
+func isCacheCorrupted(err error) bool {
+	for _, marker := range corruptedCacheMarkers {
+		if strings.Contains(err.Error(), marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// cachePool hands out cache dirs to Tunnel processes, making sure that no two processes use the same cache dir
+// at the same time. Released cache dirs are handed out again, so the number of cache dirs does not exceed the
+// number of concurrent processes.
+type cachePool struct {
+	root string
+
+	mu   sync.Mutex
+	free []string
+	size int
+}
+
+func newCachePool(cacheDir string) *cachePool {
+	return &cachePool{root: filepath.Join(cacheDir, isolatedCachesDir)}
+}
+
+// acquire returns a cache dir and the function to call once the process using it is done.
+func (p *cachePool) acquire() (string, func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var dir string
+	if n := len(p.free); n > 0 {
+		dir, p.free = p.free[n-1], p.free[:n-1]
+	} else {
+		dir = filepath.Join(p.root, strconv.Itoa(p.size))
+		p.size++
+	}
+
+	return dir, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.free = append(p.free, dir)
+	}
+}
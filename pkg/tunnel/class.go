@@ -0,0 +1,72 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// Classes of the errors failing scan jobs, which label the failure metrics so that dashboards show what fails
+// during scan storms.
+const (
+	ErrorClassAuth            = "auth"
+	ErrorClassNetwork         = "network"
+	ErrorClassTimeout         = "timeout"
+	ErrorClassOOM             = "oom"
+	ErrorClassScannerInternal = "scanner-internal"
+)
+
+// networkMarkers are found in the output of Tunnel processes failing because the registry could not be reached.
+var networkMarkers = []string{
+	"connection refused",
+	"connection reset by peer",
+	"no such host",
+	"network is unreachable",
+	"i/o timeout",
+	"TLS handshake timeout",
+}
+
+// timeoutMarkers are found in the errors of Tunnel processes which exceeded their timeout.
+var timeoutMarkers = []string{
+	"context deadline exceeded",
+}
+
+// oomMarkers are found in the errors of Tunnel processes killed because they ran out of memory.
+var oomMarkers = []string{
+	"signal: killed",
+	"exit status 137",
+	"out of memory",
+	"cannot allocate memory",
+}
+
+// ErrorClass returns the class of the given error failing a scan job, i.e. ErrorClassAuth if the registry
+// rejected the credentials, ErrorClassNetwork if the registry could not be reached or throttled the pulls,
+// ErrorClassTimeout if the scan timed out, ErrorClassOOM if Tunnel ran out of memory, and
+// ErrorClassScannerInternal otherwise. As errors are flattened to strings on their way up, e.g. when they are
+// stored with scan jobs, the messages of the wrapped errors are matched as well as the errors themselves.
+func ErrorClass(err error) string {
+	switch {
+	case errors.Is(err, ErrRegistryUnauthorized) || containsAny(err, ErrRegistryUnauthorized.Error()) ||
+		isUnauthorized(err):
+		return ErrorClassAuth
+	case errors.Is(err, ErrRegistryThrottled) || containsAny(err, ErrRegistryThrottled.Error()) ||
+		isThrottled(err) || containsAny(err, networkMarkers...):
+		return ErrorClassNetwork
+	case errors.Is(err, context.DeadlineExceeded) || containsAny(err, timeoutMarkers...):
+		return ErrorClassTimeout
+	case containsAny(err, oomMarkers...):
+		return ErrorClassOOM
+	default:
+		return ErrorClassScannerInternal
+	}
+}
+
+// containsAny returns true if the message of the given error contains any of the given markers.
+func containsAny(err error, markers ...string) bool {
+	for _, marker := range markers {
+		if strings.Contains(err.Error(), marker) {
+			return true
+		}
+	}
+	return false
+}
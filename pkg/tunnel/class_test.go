@@ -0,0 +1,64 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorClass(t *testing.T) {
+	testCases := []struct {
+		name          string
+		err           error
+		expectedClass string
+	}{
+		{
+			name:          "Should classify rejected credentials as auth",
+			err:           fmt.Errorf("%w: running tunnel: exit status 1: 401 Unauthorized", ErrRegistryUnauthorized),
+			expectedClass: ErrorClassAuth,
+		},
+		{
+			name:          "Should classify flattened rejected credentials as auth",
+			err:           errors.New("running tunnel wrapper: registry rejected credentials: running tunnel: exit status 1"),
+			expectedClass: ErrorClassAuth,
+		},
+		{
+			name:          "Should classify unreachable registry as network",
+			err:           errors.New("running tunnel: exit status 1: dial tcp 10.0.0.1:443: connect: connection refused"),
+			expectedClass: ErrorClassNetwork,
+		},
+		{
+			name:          "Should classify throttled pulls as network",
+			err:           fmt.Errorf("%w: running tunnel: exit status 1: TOOMANYREQUESTS", ErrRegistryThrottled),
+			expectedClass: ErrorClassNetwork,
+		},
+		{
+			name:          "Should classify exceeded timeout as timeout",
+			err:           errors.New("running tunnel: exit status 1: analyze error: context deadline exceeded"),
+			expectedClass: ErrorClassTimeout,
+		},
+		{
+			name:          "Should classify exceeded deadline as timeout",
+			err:           fmt.Errorf("getting scan job: %w", context.DeadlineExceeded),
+			expectedClass: ErrorClassTimeout,
+		},
+		{
+			name:          "Should classify killed process as oom",
+			err:           errors.New("running tunnel: signal: killed: "),
+			expectedClass: ErrorClassOOM,
+		},
+		{
+			name:          "Should classify other errors as scanner-internal",
+			err:           errors.New("running tunnel: exit status 1: failed to initialize DB"),
+			expectedClass: ErrorClassScannerInternal,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedClass, ErrorClass(tc.err))
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package tunnel
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// vulnerabilityDBDir is the subdirectory of a cache dir holding the vulnerability database.
+const vulnerabilityDBDir = "db"
+
+const (
+	// dbRepairBaseBackoff is the delay before the first repair following a failed one. It doubles with each
+	// consecutive failure, up to dbRepairMaxBackoff.
+	dbRepairBaseBackoff = time.Second
+	dbRepairMaxBackoff  = 30 * time.Second
+)
+
+// corruptedDBMarkers are found in the output of Tunnel processes failing because the vulnerability database
+// is corrupted, or was written with a schema the Tunnel binary doesn't support. They take precedence over
+// corruptedCacheMarkers, which may follow them in the same output.
+var corruptedDBMarkers = []string{
+	"failed to open DB",
+	"DB error",
+	"old DB schema",
+	"DB schema version",
+}
+
+// isDBCorrupted returns true if the given error of a Tunnel process is caused by a corrupted vulnerability database.
+func isDBCorrupted(err error) bool {
+	for _, marker := range corruptedDBMarkers {
+		if strings.Contains(err.Error(), marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// dbRepairer serializes the repairs of the vulnerability database, i.e. purging it so that Tunnel downloads it
+// again. Consecutive failed repairs are spaced by a jittered exponential backoff, and once they reach the
+// configured maximum the circuit opens: repairs are suspended and scans fail fast until the cooldown is over.
+type dbRepairer struct {
+	maxFailures int
+	cooldown    time.Duration
+
+	now    func() time.Time
+	sleep  func(time.Duration)
+	jitter func(time.Duration) time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	// repairedAt holds the time of the last successful repair of each cache dir.
+	repairedAt map[string]time.Time
+}
+
+func newDBRepairer(maxFailures int, cooldown time.Duration) *dbRepairer {
+	return &dbRepairer{
+		maxFailures: maxFailures,
+		cooldown:    cooldown,
+		repairedAt:  make(map[string]time.Time),
+		now:         time.Now,
+		sleep:       time.Sleep,
+		jitter: func(d time.Duration) time.Duration {
+			// Full jitter spreads the repairs of replicas sharing the same cache volume.
+			return time.Duration(rand.Int63n(int64(d) + 1))
+		},
+	}
+}
+
+// repair runs the given functions purging the vulnerability database in the given cache dir and retrying the
+// failed Tunnel process, unless the circuit is open. A Tunnel process which failed at failedAt doesn't purge
+// the vulnerability database again if another repair of the same cache dir completed in the meantime.
+func (r *dbRepairer) repair(cacheDir string, failedAt time.Time, purge func() error, retry func() error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.repairedAt[cacheDir].After(failedAt) {
+		return retry()
+	}
+
+	if now := r.now(); now.Before(r.openUntil) {
+		return fmt.Errorf("vulnerability DB repair suspended until %s after %d failed repairs",
+			r.openUntil.Format(time.RFC3339), r.failures)
+	}
+
+	if r.failures > 0 {
+		r.sleep(r.jitter(r.backoff()))
+	}
+
+	err := purge()
+	if err == nil {
+		err = retry()
+	}
+	if err != nil {
+		r.failures++
+		if r.failures >= r.maxFailures {
+			r.openUntil = r.now().Add(r.cooldown)
+		}
+		return err
+	}
+
+	r.failures = 0
+	r.openUntil = time.Time{}
+	r.repairedAt[cacheDir] = r.now()
+	return nil
+}
+
+func (r *dbRepairer) backoff() time.Duration {
+	backoff := dbRepairBaseBackoff << (r.failures - 1)
+	if backoff > dbRepairMaxBackoff || backoff <= 0 {
+		return dbRepairMaxBackoff
+	}
+	return backoff
+}
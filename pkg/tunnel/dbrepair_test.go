@@ -0,0 +1,78 @@
+package tunnel
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDBRepairer_Repair(t *testing.T) {
+	const cacheDir = "/home/scanner/.cache/tunnel"
+
+	newRepairer := func(now *time.Time, sleeps *[]time.Duration) *dbRepairer {
+		r := newDBRepairer(2, 10*time.Minute)
+		r.now = func() time.Time { return *now }
+		r.sleep = func(d time.Duration) { *sleeps = append(*sleeps, d) }
+		r.jitter = func(d time.Duration) time.Duration { return d }
+		return r
+	}
+
+	t.Run("Should purge vulnerability DB and retry", func(t *testing.T) {
+		now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		var sleeps []time.Duration
+		var purged, retried int
+
+		err := newRepairer(&now, &sleeps).repair(cacheDir, now,
+			func() error { purged++; return nil },
+			func() error { retried++; return nil })
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, purged)
+		assert.Equal(t, 1, retried)
+		assert.Empty(t, sleeps)
+	})
+
+	t.Run("Should only retry when vulnerability DB was repaired after failure", func(t *testing.T) {
+		now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		var sleeps []time.Duration
+		var purged, retried int
+		r := newRepairer(&now, &sleeps)
+		failedAt := now
+
+		now = now.Add(time.Second)
+		require.NoError(t, r.repair(cacheDir, failedAt, func() error { return nil }, func() error { return nil }))
+		err := r.repair(cacheDir, failedAt,
+			func() error { purged++; return nil },
+			func() error { retried++; return nil })
+
+		require.NoError(t, err)
+		assert.Equal(t, 0, purged)
+		assert.Equal(t, 1, retried)
+	})
+
+	t.Run("Should back off and open circuit after consecutive failed repairs", func(t *testing.T) {
+		now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		var sleeps []time.Duration
+		var purged int
+		r := newRepairer(&now, &sleeps)
+		purge := func() error { purged++; return nil }
+		retry := func() error { return errors.New("running tunnel: failed to open DB") }
+
+		assert.EqualError(t, r.repair(cacheDir, now, purge, retry), "running tunnel: failed to open DB")
+		assert.EqualError(t, r.repair(cacheDir, now, purge, retry), "running tunnel: failed to open DB")
+		assert.EqualError(t, r.repair(cacheDir, now, purge, retry),
+			"vulnerability DB repair suspended until 2024-03-01T12:10:00Z after 2 failed repairs")
+		assert.Equal(t, 2, purged)
+		assert.Equal(t, []time.Duration{time.Second}, sleeps)
+
+		now = now.Add(10 * time.Minute)
+		err := r.repair(cacheDir, now, purge, func() error { return nil })
+
+		require.NoError(t, err)
+		assert.Equal(t, 3, purged)
+		assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, sleeps)
+	})
+}
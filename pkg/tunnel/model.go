@@ -8,11 +8,61 @@ const SchemaVersion = 2
 
 type ScanReport struct {
 	SchemaVersion int
-	Results       []ScanResult `json:"Results"`
+	Metadata      ImageMetadata `json:"Metadata"`
+	Results       []ScanResult  `json:"Results"`
+}
+
+// ImageMetadata holds the metadata of the scanned image detected by Tunnel.
+type ImageMetadata struct {
+	// Size is the size of the image in bytes, unknown when scanning SBOMs.
+	Size        int64       `json:"Size"`
+	OS          *OS         `json:"OS"`
+	ImageConfig ImageConfig `json:"ImageConfig"`
+}
+
+type OS struct {
+	Family string `json:"Family"`
+	Name   string `json:"Name"`
+}
+
+type ImageConfig struct {
+	Architecture string          `json:"architecture"`
+	OS           string          `json:"os"`
+	Config       ContainerConfig `json:"config"`
+}
+
+// ContainerConfig is the configuration of the containers run from the scanned image.
+type ContainerConfig struct {
+	// Env holds the environment variables of the image as KEY=value pairs.
+	Env []string `json:"Env,omitempty"`
+}
+
+// Report is the outcome of a scan, i.e. the vulnerabilities found and the metadata of the scanned image.
+type Report struct {
+	Vulnerabilities []Vulnerability
+	Metadata        ImageMetadata
+	// Targets are the targets scanned by Tunnel, including those without vulnerabilities, e.g. the OS packages
+	// and the lockfiles of language packages found in the image.
+	Targets []Target
+	// Partial is true if the scan was interrupted, e.g. timed out, and the report only holds the results Tunnel
+	// wrote before. Interruption is the error the scan was interrupted with.
+	Partial      bool
+	Interruption string
+}
+
+// Target is a target scanned by Tunnel, with the Class and Type of its ScanResult.
+type Target struct {
+	Name  string
+	Class string
+	Type  string
 }
 
 type ScanResult struct {
-	Target          string          `json:"Target"`
+	Target string `json:"Target"`
+	// Class is either os-pkgs or lang-pkgs, and Type is the OS family or the language package manager, e.g.
+	// alpine or npm.
+	Class           string          `json:"Class"`
+	Type            string          `json:"Type"`
 	Vulnerabilities []Vulnerability `json:"Vulnerabilities"`
 }
 
@@ -21,6 +71,11 @@ type Metadata struct {
 	UpdatedAt  time.Time `json:"UpdatedAt"`
 }
 
+// Age returns the time elapsed since the vulnerability database was last updated.
+func (m *Metadata) Age(now time.Time) time.Duration {
+	return now.Sub(m.UpdatedAt)
+}
+
 type VersionInfo struct {
 	Version         string    `json:"Version,omitempty"`
 	VulnerabilityDB *Metadata `json:"VulnerabilityDB"`
@@ -38,6 +93,11 @@ type CVSSInfo struct {
 	V3Score  *float32 `json:"V3Score,omitempty"`
 }
 
+// PkgIdentifier identifies a package.
+type PkgIdentifier struct {
+	PURL string `json:"PURL,omitempty"`
+}
+
 type Vulnerability struct {
 	VulnerabilityID  string              `json:"VulnerabilityID"`
 	PkgName          string              `json:"PkgName"`
@@ -51,4 +111,11 @@ type Vulnerability struct {
 	Layer            *Layer              `json:"Layer"`
 	CVSS             map[string]CVSSInfo `json:"CVSS"`
 	CweIDs           []string            `json:"CweIDs"`
+	// PkgPath is the path of the file declaring a language package, e.g. its package.json or JAR.
+	PkgPath string `json:"PkgPath,omitempty"`
+	// PkgIdentifier identifies the vulnerable package, if Tunnel reports its package URL.
+	PkgIdentifier PkgIdentifier `json:"PkgIdentifier"`
+	// Class and Type are copied from the ScanResult the vulnerability was found in.
+	Class string `json:"-"`
+	Type  string `json:"-"`
 }
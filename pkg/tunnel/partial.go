@@ -0,0 +1,98 @@
+package tunnel
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// interruptedMarkers are found in the errors of Tunnel processes which timed out, or were canceled or killed.
+var interruptedMarkers = []string{
+	"context deadline exceeded",
+	"context canceled",
+	"signal: killed",
+	"signal: terminated",
+	"signal: interrupt",
+}
+
+// isInterrupted returns true if the given error of a Tunnel process is caused by the process being interrupted
+// before it completed, rather than by the scan failing.
+func isInterrupted(err error) bool {
+	for _, marker := range interruptedMarkers {
+		if strings.Contains(err.Error(), marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// recoverPartialReport returns the partial report recovered from the given report file after the Tunnel process
+// writing it failed with the given error. The error is returned as is unless the process was interrupted after
+// writing the metadata of the image or the results of at least one target.
+func (w *wrapper) recoverPartialReport(logger *slog.Logger, reportFile io.Reader, err error) (Report, error) {
+	if !isInterrupted(err) {
+		return Report{}, err
+	}
+	scanReport, ok := decodePartialReport(reportFile)
+	if !ok {
+		return Report{}, err
+	}
+
+	logger.Warn("Recovered partial scan report of interrupted scan", slog.Int("results", len(scanReport.Results)),
+		slog.String("err", err.Error()))
+	report := toReport(scanReport)
+	report.Partial = true
+	report.Interruption = err.Error()
+	return report, nil
+}
+
+// decodePartialReport decodes the given report up to the first malformed or truncated token, keeping the results
+// decoded so far. It returns false if the report does not hold any result nor the metadata of the image.
+func decodePartialReport(r io.Reader) (ScanReport, bool) {
+	var report ScanReport
+	decoder := json.NewDecoder(r)
+	if t, err := decoder.Token(); err != nil || t != json.Delim('{') {
+		return ScanReport{}, false
+	}
+
+fields:
+	for decoder.More() {
+		t, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch key, _ := t.(string); key {
+		case "SchemaVersion":
+			err = decoder.Decode(&report.SchemaVersion)
+		case "Metadata":
+			var metadata ImageMetadata
+			if err = decoder.Decode(&metadata); err == nil {
+				report.Metadata = metadata
+			}
+		case "Results":
+			if t, err = decoder.Token(); err != nil || t != json.Delim('[') {
+				break fields
+			}
+			for decoder.More() {
+				var result ScanResult
+				if err = decoder.Decode(&result); err != nil {
+					break fields
+				}
+				report.Results = append(report.Results, result)
+			}
+			_, err = decoder.Token()
+		default:
+			var skipped json.RawMessage
+			err = decoder.Decode(&skipped)
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	if report.SchemaVersion != SchemaVersion || (len(report.Results) == 0 && report.Metadata.OS == nil) {
+		return ScanReport{}, false
+	}
+	return report, true
+}
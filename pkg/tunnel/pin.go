@@ -0,0 +1,167 @@
+package tunnel
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/executor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/ext"
+)
+
+// ErrPinMismatch is returned instead of scanning while the Tunnel binary does not match the pinned version or
+// checksum.
+var ErrPinMismatch = errors.New("tunnel binary does not match the pinned version")
+
+// Pin wraps the Err method.
+// Err returns the error of the last verification of the Tunnel binary against the pinned version and checksum, or
+// nil if it matched.
+type Pin interface {
+	Err() error
+}
+
+// checksum is the SHA-256 checksum of the executable, which is computed again only if the executable is modified.
+type checksum struct {
+	path    string
+	size    int64
+	modTime time.Time
+	sum     string
+}
+
+type pinnedWrapper struct {
+	Wrapper
+	config     etc.Tunnel
+	ambassador ext.Ambassador
+
+	mu       sync.Mutex
+	err      error
+	checksum checksum
+}
+
+// NewPinnedWrapper wraps the given Wrapper so that it refuses to scan while the Tunnel binary does not match the
+// configured version and checksum. The binary is verified once now, and again before each scan which may update
+// the vulnerability database, since Tunnel may have been replaced in between. The returned Pin exposes the result
+// of the last verification, e.g. to fail readiness. The Wrapper is returned as is with a nil Pin if nothing is
+// pinned.
+func NewPinnedWrapper(delegate Wrapper, config etc.Tunnel, ambassador ext.Ambassador) (Wrapper, Pin) {
+	if config.ExpectedVersion == "" && config.ExpectedSHA256 == "" {
+		return delegate, nil
+	}
+	w := &pinnedWrapper{Wrapper: delegate, config: config, ambassador: ambassador}
+	_ = w.verify()
+	return w, w
+}
+
+func (w *pinnedWrapper) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+func (w *pinnedWrapper) Scan(imageRef ImageRef) (Report, error) {
+	if err := w.check(); err != nil {
+		return Report{}, err
+	}
+	return w.Wrapper.Scan(imageRef)
+}
+
+func (w *pinnedWrapper) GenerateSBOM(imageRef ImageRef, format SBOMFormat) ([]byte, error) {
+	if err := w.check(); err != nil {
+		return nil, err
+	}
+	return w.Wrapper.GenerateSBOM(imageRef, format)
+}
+
+func (w *pinnedWrapper) ScanSBOM(sbom []byte) (Report, error) {
+	if err := w.check(); err != nil {
+		return Report{}, err
+	}
+	return w.Wrapper.ScanSBOM(sbom)
+}
+
+// check verifies the binary again unless the vulnerability database is never updated, in which case the result
+// of the last verification is returned.
+func (w *pinnedWrapper) check() error {
+	if w.config.SkipUpdate {
+		return w.Err()
+	}
+	return w.verify()
+}
+
+// verify verifies the binary against the pinned version and checksum, and records the result.
+func (w *pinnedWrapper) verify() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	err := w.mismatch()
+	if err != nil && w.err == nil {
+		slog.Error("Tunnel binary does not match the pinned version, refusing to scan", slog.String("err", err.Error()))
+	}
+	if err == nil && w.err != nil {
+		slog.Info("Tunnel binary matches the pinned version again")
+	}
+	w.err = err
+	return err
+}
+
+// mismatch returns an error wrapping ErrPinMismatch describing the mismatch, or nil if the binary matches.
+func (w *pinnedWrapper) mismatch() error {
+	if expected := w.config.ExpectedVersion; expected != "" {
+		vi, err := w.Wrapper.GetVersion()
+		if err != nil {
+			return fmt.Errorf("%w: getting version: %v", ErrPinMismatch, err)
+		}
+		if strings.TrimPrefix(vi.Version, "v") != strings.TrimPrefix(expected, "v") {
+			return fmt.Errorf("%w: expected version %s, got %s", ErrPinMismatch, expected, vi.Version)
+		}
+	}
+
+	if expected := w.config.ExpectedSHA256; expected != "" {
+		sum, err := w.sum()
+		if err != nil {
+			return fmt.Errorf("%w: computing checksum: %v", ErrPinMismatch, err)
+		}
+		if !strings.EqualFold(sum, expected) {
+			return fmt.Errorf("%w: expected SHA-256 %s, got %s", ErrPinMismatch, expected, sum)
+		}
+	}
+	return nil
+}
+
+// sum returns the SHA-256 checksum of the executable run by the process execution driver.
+func (w *pinnedWrapper) sum() (string, error) {
+	path, err := w.ambassador.LookPath(executor.Executable(w.config))
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if c := w.checksum; c.path == path && c.size == info.Size() && c.modTime.Equal(info.ModTime()) {
+		return c.sum, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	w.checksum = checksum{path: path, size: info.Size(), modTime: info.ModTime(), sum: sum}
+	return sum, nil
+}
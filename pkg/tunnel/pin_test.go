@@ -0,0 +1,117 @@
+package tunnel
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/ext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// emptySHA256 is the SHA-256 checksum of an empty file.
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func TestNewPinnedWrapper(t *testing.T) {
+	executable := filepath.Join(t.TempDir(), "tunnel")
+	require.NoError(t, os.WriteFile(executable, nil, 0755))
+	imageRef := ImageRef{Name: "alpine:3.10.2"}
+
+	testCases := []struct {
+		name          string
+		config        etc.Tunnel
+		version       string
+		versionErr    error
+		expectedError string
+	}{
+		{
+			name:    "Should scan when version and checksum match",
+			config:  etc.Tunnel{ExpectedVersion: "v0.46.1", ExpectedSHA256: emptySHA256},
+			version: "0.46.1",
+		},
+		{
+			name:          "Should refuse to scan when version does not match",
+			config:        etc.Tunnel{ExpectedVersion: "0.46.1"},
+			version:       "0.47.0",
+			expectedError: "tunnel binary does not match the pinned version: expected version 0.46.1, got 0.47.0",
+		},
+		{
+			name:          "Should refuse to scan when version cannot be retrieved",
+			config:        etc.Tunnel{ExpectedVersion: "0.46.1"},
+			versionErr:    errors.New("exit status 1"),
+			expectedError: "tunnel binary does not match the pinned version: getting version: exit status 1",
+		},
+		{
+			name:   "Should refuse to scan when checksum does not match",
+			config: etc.Tunnel{ExpectedSHA256: "0000000000000000000000000000000000000000000000000000000000000000"},
+			expectedError: "tunnel binary does not match the pinned version: expected SHA-256 " +
+				"0000000000000000000000000000000000000000000000000000000000000000, got " + emptySHA256,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			delegate := NewMockWrapper()
+			delegate.On("GetVersion").Return(VersionInfo{Version: tc.version}, tc.versionErr)
+			delegate.On("Scan", imageRef).Return(Report{}, nil)
+			ambassador := ext.NewMockAmbassador()
+			ambassador.On("LookPath", "tunnel").Return(executable, nil)
+
+			wrapper, pin := NewPinnedWrapper(delegate, tc.config, ambassador)
+			require.NotNil(t, pin)
+
+			_, err := wrapper.Scan(imageRef)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				assert.ErrorIs(t, err, ErrPinMismatch)
+				assert.Equal(t, err, pin.Err())
+				delegate.AssertNotCalled(t, "Scan", imageRef)
+				return
+			}
+			assert.NoError(t, err)
+			assert.NoError(t, pin.Err())
+			delegate.AssertCalled(t, "Scan", imageRef)
+		})
+	}
+}
+
+func TestNewPinnedWrapper_Drift(t *testing.T) {
+	imageRef := ImageRef{Name: "alpine:3.10.2"}
+
+	t.Run("Should verify again before scans updating the vulnerability database", func(t *testing.T) {
+		delegate := NewMockWrapper()
+		delegate.On("GetVersion").Return(VersionInfo{Version: "0.46.1"}, nil).Once()
+		delegate.On("GetVersion").Return(VersionInfo{Version: "0.47.0"}, nil)
+
+		wrapper, pin := NewPinnedWrapper(delegate, etc.Tunnel{ExpectedVersion: "0.46.1"}, ext.NewMockAmbassador())
+		assert.NoError(t, pin.Err())
+
+		_, err := wrapper.Scan(imageRef)
+		assert.ErrorIs(t, err, ErrPinMismatch)
+		assert.ErrorIs(t, pin.Err(), ErrPinMismatch)
+	})
+
+	t.Run("Should not verify again when the vulnerability database is not updated", func(t *testing.T) {
+		delegate := NewMockWrapper()
+		delegate.On("GetVersion").Return(VersionInfo{Version: "0.46.1"}, nil).Once()
+		delegate.On("Scan", imageRef).Return(Report{}, nil)
+
+		config := etc.Tunnel{ExpectedVersion: "0.46.1", SkipUpdate: true}
+		wrapper, _ := NewPinnedWrapper(delegate, config, ext.NewMockAmbassador())
+
+		_, err := wrapper.Scan(imageRef)
+		assert.NoError(t, err)
+		delegate.AssertNumberOfCalls(t, "GetVersion", 1)
+	})
+
+	t.Run("Should return wrapper as is when nothing is pinned", func(t *testing.T) {
+		delegate := NewMockWrapper()
+
+		wrapper, pin := NewPinnedWrapper(delegate, etc.Tunnel{}, ext.NewMockAmbassador())
+		assert.Same(t, delegate, wrapper)
+		assert.Nil(t, pin)
+	})
+}
@@ -0,0 +1,76 @@
+package tunnel
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/httpx"
+)
+
+// serverVersionTimeout bounds the requests for the version of the Tunnel server.
+const serverVersionTimeout = 30 * time.Second
+
+// serverWrapper is the Wrapper of the client/server mode. Tunnel processes are thin clients of a long-running
+// Tunnel server, which holds the vulnerability database and matches the packages found in images and SBOMs
+// against it, so that neither the adapter nor the processes download or load the database. Images are still
+// pulled and analyzed by the processes, which have access to the registry credentials of scan requests.
+type serverWrapper struct {
+	*wrapper
+	client *http.Client
+}
+
+func newServerWrapper(w *wrapper) *serverWrapper {
+	return &serverWrapper{
+		wrapper: w,
+		client:  httpx.Client("tunnel_server", serverVersionTimeout),
+	}
+}
+
+// GetVersion returns the version of the Tunnel server and of its vulnerability database.
+func (w *serverWrapper) GetVersion() (VersionInfo, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(w.config.ServerURL, "/")+"/version", nil)
+	if err != nil {
+		return VersionInfo{}, fmt.Errorf("failed preparing tunnel server version request: %w", err)
+	}
+	if w.config.ServerToken != "" {
+		req.Header.Set(w.config.ServerTokenHeader, w.config.ServerToken)
+	}
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		return VersionInfo{}, fmt.Errorf("failed requesting tunnel server version: %w", err)
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode != http.StatusOK {
+		return VersionInfo{}, fmt.Errorf("failed requesting tunnel server version: unexpected status %d", res.StatusCode)
+	}
+
+	var vi VersionInfo
+	if err = json.NewDecoder(res.Body).Decode(&vi); err != nil {
+		return VersionInfo{}, fmt.Errorf("failed parsing tunnel server version: %w", err)
+	}
+	return vi, nil
+}
+
+// serverArgs returns the arguments having Tunnel processes match vulnerabilities on the Tunnel server, if any.
+func (w *wrapper) serverArgs() []string {
+	if w.config.ServerURL == "" {
+		return nil
+	}
+	return []string{"--server", w.config.ServerURL}
+}
+
+// serverEnv returns the environment variables authenticating Tunnel processes to the Tunnel server, if any. The
+// token is not passed as an argument, which would expose it in the process list.
+func (w *wrapper) serverEnv() []string {
+	if w.config.ServerURL == "" || w.config.ServerToken == "" {
+		return nil
+	}
+	return []string{
+		"TUNNEL_TOKEN=" + w.config.ServerToken,
+		"TUNNEL_TOKEN_HEADER=" + w.config.ServerTokenHeader,
+	}
+}
@@ -0,0 +1,96 @@
+package tunnel
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/ext"
+)
+
+func TestServerWrapper_Scan(t *testing.T) {
+	ambassador := ext.NewMockAmbassador()
+	ambassador.On("Environ").Return([]string{})
+	ambassador.On("LookPath", "tunnel").Return("/usr/local/bin/tunnel", nil)
+
+	config := etc.Tunnel{
+		CacheDir:          "/home/scanner/.cache/tunnel",
+		ReportsDir:        "/home/scanner/.cache/reports",
+		VulnType:          "os,library",
+		SecurityChecks:    "vuln",
+		Severity:          "CRITICAL",
+		SkipUpdate:        true,
+		Timeout:           5 * time.Minute,
+		ServerURL:         "http://tunnel-server:4954",
+		ServerToken:       "s3cret",
+		ServerTokenHeader: "Tunnel-Token",
+	}
+
+	ambassador.On("TempFile", "/home/scanner/.cache/reports", "scan_report_*.json").
+		Return(ext.NewFakeFile("/home/scanner/.cache/reports/scan_report_1234567890.json", expectedReportJSON), nil)
+	ambassador.On("Remove", "/home/scanner/.cache/reports/scan_report_1234567890.json").Return(nil)
+	ambassador.On("RunCmd", &exec.Cmd{
+		Path: "/usr/local/bin/tunnel",
+		Env: []string{
+			"TUNNEL_TIMEOUT=5m0s",
+			"TUNNEL_TOKEN=s3cret",
+			"TUNNEL_TOKEN_HEADER=Tunnel-Token",
+		},
+		Args: []string{
+			"/usr/local/bin/tunnel",
+			"--cache-dir", "/home/scanner/.cache/tunnel",
+			"image",
+			"--server", "http://tunnel-server:4954",
+			"--no-progress",
+			"--severity", "CRITICAL",
+			"--vuln-type", "os,library",
+			"--scanners", "vuln",
+			"--format", "json",
+			"--output", "/home/scanner/.cache/reports/scan_report_1234567890.json",
+			"alpine:3.10.2",
+		},
+	}).Return([]byte{}, nil)
+
+	report, err := NewWrapper(config, ambassador).Scan(ImageRef{Name: "alpine:3.10.2", Auth: NoAuth{}})
+
+	require.NoError(t, err)
+	assert.Equal(t, expectedReport, report)
+	ambassador.AssertExpectations(t)
+}
+
+func TestServerWrapper_GetVersion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/version" || req.Header.Get("Tunnel-Token") != "s3cret" {
+			res.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(res).Encode(expectedVersion)
+	}))
+	defer server.Close()
+
+	config := etc.Tunnel{ServerURL: server.URL + "/", ServerTokenHeader: "Tunnel-Token"}
+
+	t.Run("Should return version of the server", func(t *testing.T) {
+		config.ServerToken = "s3cret"
+
+		vi, err := NewWrapper(config, ext.NewMockAmbassador()).GetVersion()
+
+		require.NoError(t, err)
+		assert.Equal(t, expectedVersion, vi)
+	})
+
+	t.Run("Should return error when server rejects token", func(t *testing.T) {
+		config.ServerToken = "expired"
+
+		_, err := NewWrapper(config, ext.NewMockAmbassador()).GetVersion()
+
+		assert.EqualError(t, err, "failed requesting tunnel server version: unexpected status 401")
+	})
+}
@@ -0,0 +1,84 @@
+package tunnel
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// SBOMFormat is the format of the SBOMs generated by Tunnel.
+type SBOMFormat string
+
+const (
+	// SBOMFormatCycloneDX is the CycloneDX JSON format, which SBOMs scanned by Tunnel are generated in.
+	SBOMFormatCycloneDX SBOMFormat = "cyclonedx"
+	// SBOMFormatSPDXJSON is the SPDX 2.3 JSON format.
+	SBOMFormatSPDXJSON SBOMFormat = "spdx-json"
+)
+
+// spdxVersionPrefix is the prefix of the versions of SPDX documents in the 2.x format.
+const spdxVersionPrefix = "SPDX-2."
+
+// SPDXDocument is an SPDX 2.3 document generated by Tunnel. Only the fields of interest to the adapter are
+// decoded, the document returned to Harbor is the one generated by Tunnel.
+type SPDXDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      SPDXCreationInfo   `json:"creationInfo"`
+	Packages          []SPDXPackage      `json:"packages,omitempty"`
+	Relationships     []SPDXRelationship `json:"relationships,omitempty"`
+}
+
+type SPDXCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type SPDXPackage struct {
+	SPDXID                string            `json:"SPDXID"`
+	Name                  string            `json:"name"`
+	VersionInfo           string            `json:"versionInfo,omitempty"`
+	Supplier              string            `json:"supplier,omitempty"`
+	DownloadLocation      string            `json:"downloadLocation,omitempty"`
+	LicenseConcluded      string            `json:"licenseConcluded,omitempty"`
+	LicenseDeclared       string            `json:"licenseDeclared,omitempty"`
+	PrimaryPackagePurpose string            `json:"primaryPackagePurpose,omitempty"`
+	ExternalRefs          []SPDXExternalRef `json:"externalRefs,omitempty"`
+}
+
+type SPDXExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type SPDXRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+// PackageURL returns the package URL of the package, or an empty string if it has none.
+func (p SPDXPackage) PackageURL() string {
+	for _, ref := range p.ExternalRefs {
+		if ref.ReferenceType == "purl" {
+			return ref.ReferenceLocator
+		}
+	}
+	return ""
+}
+
+// ParseSPDX parses the given SPDX JSON document, and fails unless it is an SPDX 2.x document.
+func ParseSPDX(data []byte) (SPDXDocument, error) {
+	var doc SPDXDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return SPDXDocument{}, fmt.Errorf("decoding SPDX document: %w", err)
+	}
+	if !strings.HasPrefix(doc.SPDXVersion, spdxVersionPrefix) {
+		return SPDXDocument{}, fmt.Errorf("unsupported SPDX version: %q", doc.SPDXVersion)
+	}
+	return doc, nil
+}
@@ -0,0 +1,50 @@
+package tunnel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSPDX(t *testing.T) {
+	doc, err := ParseSPDX([]byte(`{
+  "spdxVersion": "SPDX-2.3",
+  "dataLicense": "CC0-1.0",
+  "SPDXID": "SPDXRef-DOCUMENT",
+  "name": "alpine:3.10.2",
+  "documentNamespace": "http://aquasecurity.github.io/trivy/container_image/alpine:3.10.2-5f6c5d8d",
+  "creationInfo": {"created": "2024-05-01T12:00:00Z", "creators": ["Tool: tunnel-0.50.0"]},
+  "packages": [
+    {
+      "SPDXID": "SPDXRef-Package-musl",
+      "name": "musl",
+      "versionInfo": "1.1.22-r3",
+      "licenseDeclared": "MIT",
+      "externalRefs": [
+        {"referenceCategory": "PACKAGE-MANAGER", "referenceType": "purl", "referenceLocator": "pkg:apk/alpine/musl@1.1.22-r3"}
+      ]
+    }
+  ],
+  "relationships": [
+    {"spdxElementId": "SPDXRef-DOCUMENT", "relationshipType": "DESCRIBES", "relatedSpdxElement": "SPDXRef-Package-musl"}
+  ]
+}`))
+	require.NoError(t, err)
+	assert.Equal(t, "SPDX-2.3", doc.SPDXVersion)
+	assert.Equal(t, []string{"Tool: tunnel-0.50.0"}, doc.CreationInfo.Creators)
+	require.Len(t, doc.Packages, 1)
+	assert.Equal(t, "musl", doc.Packages[0].Name)
+	assert.Equal(t, "pkg:apk/alpine/musl@1.1.22-r3", doc.Packages[0].PackageURL())
+	assert.Equal(t, []SPDXRelationship{{
+		SPDXElementID:      "SPDXRef-DOCUMENT",
+		RelationshipType:   "DESCRIBES",
+		RelatedSPDXElement: "SPDXRef-Package-musl",
+	}}, doc.Relationships)
+
+	_, err = ParseSPDX([]byte(`{"spdxVersion": "SPDX-3.0"}`))
+	assert.EqualError(t, err, `unsupported SPDX version: "SPDX-3.0"`)
+
+	_, err = ParseSPDX([]byte(`not json`))
+	assert.ErrorContains(t, err, "decoding SPDX document")
+}
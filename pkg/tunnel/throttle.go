@@ -0,0 +1,113 @@
+package tunnel
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrRegistryThrottled is wrapped by the errors of Tunnel processes failing because the registry throttled their
+// image pulls, typically during scan-all runs, once the retries allowed by the throttle budget are exhausted.
+var ErrRegistryThrottled = errors.New("registry throttled image pull")
+
+// throttledMarkers are found in the output of Tunnel processes failing because the registry responded with the
+// 429 status.
+var throttledMarkers = []string{
+	"429 Too Many Requests",
+	"TOOMANYREQUESTS",
+	"toomanyrequests",
+}
+
+// isThrottled returns true if the given error of a Tunnel process is caused by the registry throttling its pulls.
+func isThrottled(err error) bool {
+	for _, marker := range throttledMarkers {
+		if strings.Contains(err.Error(), marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// RegistryThrottles counts the image pulls throttled by registries, labelled by whether they were retried or
+// failed once the throttle budget was exhausted.
+var RegistryThrottles = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "scanner_registry_throttled_pulls_total",
+	Help: "Total number of image pulls throttled by registries.",
+}, []string{"result"})
+
+// RegistryConcurrencyLimit is the number of image pulls currently allowed at the same time.
+var RegistryConcurrencyLimit = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "scanner_registry_concurrency_limit",
+	Help: "Number of image pulls currently allowed at the same time, lowered while registries throttle pulls.",
+})
+
+// aimdDecrease is the factor applied to the concurrency limit when the registry throttles a pull.
+const aimdDecrease = 0.5
+
+// registryLimiter limits the number of image pulls in progress. When adaptive, the limit follows an additive
+// increase, multiplicative decrease (AIMD) scheme: it is halved when the registry throttles a pull, and grows by
+// one pull each time as many pulls as the limit succeeded, up to the configured maximum.
+type registryLimiter struct {
+	max      int
+	adaptive bool
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	limit    float64
+	inFlight int
+	// generation is incremented on each decrease, so that the pulls started before a decrease, which were
+	// throttled by the same burst, do not decrease the limit again.
+	generation int
+}
+
+func newRegistryLimiter(maxPulls int, adaptive bool) *registryLimiter {
+	l := &registryLimiter{max: maxPulls, adaptive: adaptive, limit: float64(maxPulls)}
+	l.cond = sync.NewCond(&l.mu)
+	RegistryConcurrencyLimit.Set(float64(maxPulls))
+	return l
+}
+
+// acquire blocks until the number of pulls in progress drops below the limit, and returns the generation the
+// pull started in, and whether it had to wait.
+func (l *registryLimiter) acquire() (generation int, waited bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.inFlight >= int(l.limit) {
+		waited = true
+		l.cond.Wait()
+	}
+	l.inFlight++
+	return l.generation, waited
+}
+
+// release ends a pull started in the given generation, and adapts the limit to whether it was throttled.
+func (l *registryLimiter) release(generation int, throttled bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+	if l.adaptive {
+		switch {
+		case throttled && generation == l.generation:
+			l.limit = max(1, l.limit*aimdDecrease)
+			l.generation++
+		case !throttled:
+			l.limit = min(float64(l.max), l.limit+1/l.limit)
+		}
+		RegistryConcurrencyLimit.Set(float64(int(l.limit)))
+	}
+	l.cond.Broadcast()
+}
+
+// throttleBackoff returns the jittered delay before retrying a pull throttled for the given number of times, which
+// doubles the given base delay with each attempt.
+func throttleBackoff(base time.Duration, attempt int) time.Duration {
+	d := base << attempt
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
@@ -0,0 +1,78 @@
+package tunnel
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsThrottled(t *testing.T) {
+	assert.True(t, isThrottled(errors.New("running tunnel: exit status 1: GET https://index.docker.io/v2/library/alpine/manifests/3.10: TOOMANYREQUESTS: You have reached your pull rate limit")))
+	assert.True(t, isThrottled(errors.New("running tunnel: exit status 1: unexpected status code 429 Too Many Requests")))
+	assert.False(t, isThrottled(errors.New("running tunnel: exit status 1: manifest unknown")))
+}
+
+func TestRegistryLimiter(t *testing.T) {
+	t.Run("Should halve limit once per burst of throttled pulls", func(t *testing.T) {
+		l := newRegistryLimiter(8, true)
+
+		var generations []int
+		for i := 0; i < 8; i++ {
+			generation, _ := l.acquire()
+			generations = append(generations, generation)
+		}
+		for _, generation := range generations {
+			l.release(generation, true)
+		}
+
+		assert.Equal(t, 4.0, l.limit)
+	})
+
+	t.Run("Should not lower limit below one pull", func(t *testing.T) {
+		l := newRegistryLimiter(2, true)
+
+		for i := 0; i < 3; i++ {
+			generation, _ := l.acquire()
+			l.release(generation, true)
+		}
+
+		assert.Equal(t, 1.0, l.limit)
+	})
+
+	t.Run("Should raise limit by one pull once as many pulls as the limit succeeded", func(t *testing.T) {
+		l := newRegistryLimiter(4, true)
+		l.limit = 2
+
+		for i := 0; i < 2; i++ {
+			generation, _ := l.acquire()
+			l.release(generation, false)
+		}
+		assert.InDelta(t, 2.9, l.limit, 0.1)
+
+		for i := 0; i < 10; i++ {
+			generation, _ := l.acquire()
+			l.release(generation, false)
+		}
+		assert.Equal(t, 4.0, l.limit)
+	})
+
+	t.Run("Should keep limit unless adaptive", func(t *testing.T) {
+		l := newRegistryLimiter(4, false)
+
+		generation, _ := l.acquire()
+		l.release(generation, true)
+
+		assert.Equal(t, 4.0, l.limit)
+	})
+}
+
+func TestThrottleBackoff(t *testing.T) {
+	for attempt := 0; attempt < 3; attempt++ {
+		backoff := throttleBackoff(time.Second, attempt)
+		assert.GreaterOrEqual(t, backoff, time.Second<<attempt/2)
+		assert.LessOrEqual(t, backoff, time.Second<<attempt)
+	}
+	assert.Zero(t, throttleBackoff(0, 2))
+}
@@ -0,0 +1,29 @@
+package tunnel
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrArtifactUnscannable is wrapped by the errors of Tunnel processes failing for a reason which does not go away
+// when the scan is attempted again, e.g. because the manifest of the artifact was deleted, or because the media
+// type of the artifact is not supported.
+var ErrArtifactUnscannable = errors.New("artifact cannot be scanned")
+
+// unscannableMarkers are found in the output of Tunnel processes failing for a permanent reason.
+var unscannableMarkers = []string{
+	"MANIFEST_UNKNOWN",
+	"manifest unknown",
+	"unsupported MediaType",
+	"unsupported media type",
+}
+
+// isUnscannable returns true if the given error of a Tunnel process is caused by a permanent failure.
+func isUnscannable(err error) bool {
+	for _, marker := range unscannableMarkers {
+		if strings.Contains(err.Error(), marker) {
+			return true
+		}
+	}
+	return false
+}
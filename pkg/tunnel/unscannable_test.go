@@ -0,0 +1,14 @@
+package tunnel
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsUnscannable(t *testing.T) {
+	assert.True(t, isUnscannable(errors.New("running tunnel: exit status 1: GET https://core.harbor.domain/v2/library/alpine/manifests/sha256:917f: MANIFEST_UNKNOWN: manifest unknown")))
+	assert.True(t, isUnscannable(errors.New(`running tunnel: exit status 1: unsupported MediaType: "application/vnd.cncf.helm.config.v1+json"`)))
+	assert.False(t, isUnscannable(errors.New("running tunnel: exit status 1: context deadline exceeded")))
+}
@@ -0,0 +1,46 @@
+package tunnel
+
+import (
+	"sync"
+	"time"
+)
+
+// VersionCacheTTL is how long the version info of Tunnel is cached by default.
+const VersionCacheTTL = time.Minute
+
+// VersionCache holds the version info of Tunnel, so that frequent readiness probes and metrics scrapes do not
+// exec Tunnel each time.
+type VersionCache struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	info      VersionInfo
+	expiresAt time.Time
+}
+
+// NewVersionCache constructs a VersionCache holding the version info for the given TTL.
+func NewVersionCache(ttl time.Duration) *VersionCache {
+	return &VersionCache{ttl: ttl}
+}
+
+// Get returns the cached version info, or the one retrieved by the given function if the cache has expired. The
+// version info is only cached once the vulnerability database has been downloaded, so that probes notice it
+// right away. Concurrent callers wait for the version info being retrieved.
+func (c *VersionCache) Get(now time.Time, retrieve func() (VersionInfo, error)) (VersionInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Before(c.expiresAt) {
+		return c.info, nil
+	}
+
+	info, err := retrieve()
+	if err != nil {
+		return VersionInfo{}, err
+	}
+	if info.VulnerabilityDB != nil {
+		c.info = info
+		c.expiresAt = now.Add(c.ttl)
+	}
+	return info, nil
+}
@@ -2,24 +2,27 @@ package tunnel
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/executor"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/ext"
 )
 
-const (
-	tunnelCmd = "tunnel"
-)
-
 type ImageRef struct {
 	Name     string
 	Auth     RegistryAuth
 	Insecure bool
+	// Timeout extends the configured timeout of the Tunnel processes analyzing the image, up to the configured
+	// maximum. Timeouts shorter than the configured one are ignored.
+	Timeout time.Duration
 }
 
 // RegistryAuth wraps registry credentials.
@@ -39,42 +42,198 @@ type BearerAuth struct {
 }
 
 type Wrapper interface {
-	Scan(imageRef ImageRef) ([]Vulnerability, error)
+	Scan(imageRef ImageRef) (Report, error)
+	// GenerateSBOM analyzes the given image and returns its software bill of materials in the given format.
+	GenerateSBOM(imageRef ImageRef, format SBOMFormat) ([]byte, error)
+	// ScanSBOM matches the vulnerability database against the given CycloneDX SBOM, without pulling
+	// or analyzing the image it was generated from.
+	ScanSBOM(sbom []byte) (Report, error)
 	GetVersion() (VersionInfo, error)
 }
 
 type wrapper struct {
 	config     etc.Tunnel
 	ambassador ext.Ambassador
+	// registryConnections limits concurrent image pulls, nil if there is no limit.
+	registryConnections *registryLimiter
+	// sleep waits before retrying throttled image pulls.
+	sleep func(time.Duration)
+	// caches hands out isolated cache dirs, nil if all processes share the configured cache dir.
+	caches *cachePool
+	// db repairs corrupted vulnerability databases, nil if they cannot be downloaded again.
+	db *dbRepairer
+	// driver runs Tunnel processes.
+	driver executor.Driver
+	// gateway relays the registry requests of Tunnel processes, nil if they are sent to the registries directly.
+	gateway RegistryGateway
+}
+
+// RegistryGateway wraps the Address method.
+// Address returns the host and port of the plain HTTP listener relaying requests to the given registry host,
+// which is reached over plain HTTP if plainHTTP is true.
+type RegistryGateway interface {
+	Address(host string, plainHTTP bool) (string, error)
+}
+
+type WrapperOption func(*wrapper)
+
+// WithRegistryGateway has Tunnel pull images through the given RegistryGateway, e.g. to add headers to the
+// registry requests.
+func WithRegistryGateway(gateway RegistryGateway) WrapperOption {
+	return func(w *wrapper) {
+		w.gateway = gateway
+	}
+}
+
+// layerPullBudget is the number of layers downloaded in parallel by all Tunnel processes together, unless
+// the parallelism of each process is configured.
+const layerPullBudget = 10
+
+// DefaultParallel returns the number of layers each Tunnel process downloads in parallel, which spreads
+// layerPullBudget among the given number of workers, or among the processes allowed to pull images at the
+// same time if there are fewer.
+func DefaultParallel(config etc.Tunnel, workerConcurrency int) int {
+	pulls := workerConcurrency
+	if config.MaxRegistryConnections > 0 && config.MaxRegistryConnections < pulls {
+		pulls = config.MaxRegistryConnections
+	}
+	return max(1, layerPullBudget/max(1, pulls))
 }
 
-func NewWrapper(config etc.Tunnel, ambassador ext.Ambassador) Wrapper {
-	return &wrapper{
+func NewWrapper(config etc.Tunnel, ambassador ext.Ambassador, opts ...WrapperOption) Wrapper {
+	w := &wrapper{
 		config:     config,
 		ambassador: ambassador,
+		driver:     executor.NewDriver(config, ambassador),
+		sleep:      time.Sleep,
 	}
+	if config.MaxRegistryConnections > 0 {
+		w.registryConnections = newRegistryLimiter(config.MaxRegistryConnections, config.RegistryAdaptiveConcurrency)
+	}
+	if config.CacheMode == CacheModeIsolated {
+		w.caches = newCachePool(config.CacheDir)
+	}
+	// The vulnerability database of the client/server mode is held by the server.
+	if config.DBRepairMaxFailures > 0 && !config.SkipUpdate && config.ServerURL == "" {
+		w.db = newDBRepairer(config.DBRepairMaxFailures, config.DBRepairCooldown)
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if config.ServerURL != "" {
+		return newServerWrapper(w)
+	}
+	return w
 }
 
-func (w *wrapper) Scan(imageRef ImageRef) ([]Vulnerability, error) {
+func (w *wrapper) Scan(imageRef ImageRef) (Report, error) {
 	logger := slog.With(slog.String("image_ref", imageRef.Name))
 	logger.Debug("Started scanning")
 
+	imageRef, restore, err := w.throughGateway(imageRef)
+	if err != nil {
+		return Report{}, err
+	}
+
 	reportFile, err := w.ambassador.TempFile(w.config.ReportsDir, "scan_report_*.json")
 	if err != nil {
-		return nil, err
+		return Report{}, err
 	}
 	logger.Debug("Saving scan report to tmp file", slog.String("path", reportFile.Name()))
-	defer func() {
-		logger.Debug("Removing scan report tmp file", slog.String("path", reportFile.Name()))
-		if err = w.ambassador.Remove(reportFile.Name()); err != nil {
-			logger.Warn("Error while removing scan report tmp file", slog.String("err", err.Error()))
-		}
-	}()
+	defer w.removeTempFile(logger, reportFile, "scan report")
 
-	cmd, err := w.prepareScanCmd(imageRef, reportFile.Name())
+	cacheDir, releaseCacheDir := w.acquireCacheDir()
+	defer releaseCacheDir()
+
+	err = w.pull(logger, cacheDir, func() (*exec.Cmd, error) {
+		return w.prepareScanCmd(cacheDir, imageRef, reportFile.Name())
+	})
+	var report Report
+	if err != nil {
+		report, err = w.recoverPartialReport(logger, reportFile, err)
+	} else {
+		report, err = w.parseReport(reportFile)
+	}
+	for i := range report.Targets {
+		report.Targets[i].Name = restore.Replace(report.Targets[i].Name)
+	}
+	return report, err
+}
+
+func (w *wrapper) GenerateSBOM(imageRef ImageRef, format SBOMFormat) ([]byte, error) {
+	logger := slog.With(slog.String("image_ref", imageRef.Name), slog.String("format", string(format)))
+	logger.Debug("Started generating SBOM")
+
+	imageRef, restore, err := w.throughGateway(imageRef)
+	if err != nil {
+		return nil, err
+	}
+
+	sbomFile, err := w.ambassador.TempFile(w.config.ReportsDir, "sbom_*.json")
 	if err != nil {
 		return nil, err
 	}
+	defer w.removeTempFile(logger, sbomFile, "SBOM")
+
+	cacheDir, releaseCacheDir := w.acquireCacheDir()
+	defer releaseCacheDir()
+
+	err = w.pull(logger, cacheDir, func() (*exec.Cmd, error) {
+		return w.prepareGenerateSBOMCmd(cacheDir, imageRef, format, sbomFile.Name())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sbom, err := io.ReadAll(sbomFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading SBOM from file: %w", err)
+	}
+	sbom = []byte(restore.Replace(string(sbom)))
+	if format == SBOMFormatSPDXJSON {
+		if _, err = ParseSPDX(sbom); err != nil {
+			return nil, err
+		}
+	}
+	return sbom, nil
+}
+
+func (w *wrapper) ScanSBOM(sbom []byte) (Report, error) {
+	logger := slog.Default()
+	logger.Debug("Started scanning SBOM")
+
+	sbomFile, err := w.ambassador.TempFile(w.config.ReportsDir, "sbom_*.json")
+	if err != nil {
+		return Report{}, err
+	}
+	defer w.removeTempFile(logger, sbomFile, "SBOM")
+
+	if _, err = sbomFile.Write(sbom); err != nil {
+		return Report{}, fmt.Errorf("writing SBOM to file: %w", err)
+	}
+
+	reportFile, err := w.ambassador.TempFile(w.config.ReportsDir, "scan_report_*.json")
+	if err != nil {
+		return Report{}, err
+	}
+	defer w.removeTempFile(logger, reportFile, "scan report")
+
+	cacheDir, releaseCacheDir := w.acquireCacheDir()
+	defer releaseCacheDir()
+
+	// Scanning an SBOM doesn't pull the image, hence it does not take a registry connection.
+	err = w.runWithRepair(logger, cacheDir, func() (*exec.Cmd, error) {
+		return w.prepareScanSBOMCmd(cacheDir, sbomFile.Name(), reportFile.Name())
+	})
+	if err != nil {
+		return w.recoverPartialReport(logger, reportFile, err)
+	}
+
+	return w.parseReport(reportFile)
+}
+
+func (w *wrapper) runCmd(logger *slog.Logger, cmd *exec.Cmd) error {
+	defer w.driver.Release(cmd)
 
 	logger.Debug("Exec command with args", slog.String("path", cmd.Path),
 		slog.String("args", strings.Join(cmd.Args, " ")))
@@ -85,52 +244,206 @@ func (w *wrapper) Scan(imageRef ImageRef) ([]Vulnerability, error) {
 			slog.String("exit_code", fmt.Sprintf("%d", cmd.ProcessState.ExitCode())),
 			slog.String("std_out", string(stdout)),
 		)
-		return nil, fmt.Errorf("running tunnel: %v: %v", err, string(stdout))
+		return fmt.Errorf("running tunnel: %v: %v", err, string(stdout))
 	}
 
 	logger.Debug("Running tunnel finished",
 		slog.String("exit_code", fmt.Sprintf("%d", cmd.ProcessState.ExitCode())),
 		slog.String("std_out", string(stdout)),
 	)
+	return nil
+}
+
+// runWithRepair runs the command prepared by the given function. If Tunnel fails because the vulnerability
+// database or the analysis cache in the given cache dir is corrupted, it is purged and the command is run once
+// again, rather than failing every subsequent scan job. If the registry rejects the credentials of the command,
+// the returned error wraps ErrRegistryUnauthorized, and if the artifact cannot be scanned at all, it wraps
+// ErrArtifactUnscannable.
+func (w *wrapper) runWithRepair(logger *slog.Logger, cacheDir string, prepare func() (*exec.Cmd, error)) error {
+	run := func() error {
+		cmd, err := prepare()
+		if err != nil {
+			return err
+		}
+		return w.runCmd(logger, cmd)
+	}
+
+	err := run()
+	switch {
+	case err == nil:
+		return nil
+	case isDBCorrupted(err):
+		if w.db == nil {
+			return err
+		}
+		logger.Warn("Repairing corrupted vulnerability DB", slog.String("cache_dir", cacheDir),
+			slog.String("err", err.Error()))
+		return w.db.repair(cacheDir, w.db.now(), func() error {
+			if err := w.ambassador.RemoveAll(filepath.Join(cacheDir, vulnerabilityDBDir)); err != nil {
+				return fmt.Errorf("purging corrupted vulnerability DB: %w", err)
+			}
+			return nil
+		}, run)
+	case isCacheCorrupted(err):
+		logger.Warn("Clearing corrupted scan cache", slog.String("cache_dir", cacheDir), slog.String("err", err.Error()))
+		if err := w.ambassador.RemoveAll(filepath.Join(cacheDir, scanCacheDir)); err != nil {
+			return fmt.Errorf("clearing corrupted scan cache: %w", err)
+		}
+		return run()
+	case isUnauthorized(err):
+		return fmt.Errorf("%w: %v", ErrRegistryUnauthorized, err)
+	case isThrottled(err):
+		return fmt.Errorf("%w: %v", ErrRegistryThrottled, err)
+	case isUnscannable(err):
+		return fmt.Errorf("%w: %v", ErrArtifactUnscannable, err)
+	default:
+		return err
+	}
+}
+
+// acquireCacheDir returns the cache dir of a Tunnel process, and the function to call once the process is done.
+func (w *wrapper) acquireCacheDir() (string, func()) {
+	if w.caches == nil {
+		return w.config.CacheDir, func() {}
+	}
+	return w.caches.acquire()
+}
+
+func (w *wrapper) removeTempFile(logger *slog.Logger, file ext.File, description string) {
+	logger.Debug("Removing "+description+" tmp file", slog.String("path", file.Name()))
+	if err := w.ambassador.Remove(file.Name()); err != nil {
+		logger.Warn("Error while removing "+description+" tmp file", slog.String("err", err.Error()))
+	}
+}
+
+// pull runs the command prepared by the given function, which pulls an image, once a registry connection is
+// acquired. A pull throttled by the registry is retried after a backoff, as long as the throttle budget allows it.
+func (w *wrapper) pull(logger *slog.Logger, cacheDir string, prepare func() (*exec.Cmd, error)) error {
+	for attempt := 0; ; attempt++ {
+		release := w.acquireRegistryConnection(logger)
+		err := w.runWithRepair(logger, cacheDir, prepare)
+		throttled := errors.Is(err, ErrRegistryThrottled)
+		release(throttled)
+		if !throttled {
+			return err
+		}
+		if attempt >= w.config.RegistryThrottleRetries {
+			RegistryThrottles.WithLabelValues("failed").Inc()
+			return err
+		}
+		RegistryThrottles.WithLabelValues("retried").Inc()
+		backoff := throttleBackoff(w.config.RegistryThrottleBackoff, attempt)
+		logger.Warn("Registry throttled image pull, retrying", slog.Int("attempt", attempt+1),
+			slog.Duration("backoff", backoff))
+		w.sleep(backoff)
+	}
+}
+
+// acquireRegistryConnection blocks until the number of image pulls in progress drops below the limit, and
+// returns the function to call once the pull is done, with whether the registry throttled it.
+func (w *wrapper) acquireRegistryConnection(logger *slog.Logger) func(throttled bool) {
+	if w.registryConnections == nil {
+		return func(bool) {}
+	}
 
-	return w.parseVulnerabilities(reportFile)
+	start := time.Now()
+	generation, waited := w.registryConnections.acquire()
+	if waited {
+		logger.Debug("Acquired registry connection", slog.Duration("wait", time.Since(start)))
+	}
+
+	return func(throttled bool) {
+		w.registryConnections.release(generation, throttled)
+	}
 }
 
-func (w *wrapper) parseVulnerabilities(reportFile io.Reader) ([]Vulnerability, error) {
+func (w *wrapper) parseReport(reportFile io.Reader) (Report, error) {
 	var scanReport ScanReport
 	if err := json.NewDecoder(reportFile).Decode(&scanReport); err != nil {
-		return nil, fmt.Errorf("decoding scan report from file: %w", err)
+		return Report{}, fmt.Errorf("decoding scan report from file: %w", err)
 	}
 
 	if scanReport.SchemaVersion != SchemaVersion {
-		return nil, fmt.Errorf("unsupported schema %d, expected %d", scanReport.SchemaVersion, SchemaVersion)
+		return Report{}, fmt.Errorf("unsupported schema %d, expected %d", scanReport.SchemaVersion, SchemaVersion)
 	}
+	return toReport(scanReport), nil
+}
 
+// toReport flattens the vulnerabilities of the results of the given report.
+func toReport(scanReport ScanReport) Report {
 	var vulnerabilities []Vulnerability
+	var targets []Target
 	for _, scanResult := range scanReport.Results {
 		slog.Debug("Parsing vulnerabilities", slog.String("target", scanResult.Target))
-		vulnerabilities = append(vulnerabilities, scanResult.Vulnerabilities...)
+		targets = append(targets, Target{Name: scanResult.Target, Class: scanResult.Class, Type: scanResult.Type})
+		for _, v := range scanResult.Vulnerabilities {
+			v.Class, v.Type = scanResult.Class, scanResult.Type
+			vulnerabilities = append(vulnerabilities, v)
+		}
 	}
 
-	return vulnerabilities, nil
+	return Report{
+		Vulnerabilities: vulnerabilities,
+		Metadata:        scanReport.Metadata,
+		Targets:         targets,
+	}
 }
 
-func (w *wrapper) prepareScanCmd(imageRef ImageRef, outputFile string) (*exec.Cmd, error) {
-	args := []string{
-		"--no-progress",
-		"--severity", w.config.Severity,
-		"--vuln-type", w.config.VulnType,
+func (w *wrapper) prepareScanCmd(cacheDir string, imageRef ImageRef, outputFile string) (*exec.Cmd, error) {
+	args := append(w.vulnerabilityArgs(),
 		"--scanners", w.config.SecurityChecks,
 		"--format", "json",
 		"--output", outputFile,
 		imageRef.Name,
+	)
+
+	env, err := w.imageEnv(imageRef)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.prepareCmd(cacheDir, "image", args, w.timeout(imageRef), env)
+}
+
+func (w *wrapper) prepareGenerateSBOMCmd(cacheDir string, imageRef ImageRef, format SBOMFormat, outputFile string) (*exec.Cmd, error) {
+	args := []string{
+		"--no-progress",
+		"--format", string(format),
+		"--output", outputFile,
+		imageRef.Name,
+	}
+
+	env, err := w.imageEnv(imageRef)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.prepareCmd(cacheDir, "image", args, w.timeout(imageRef), env)
+}
+
+func (w *wrapper) prepareScanSBOMCmd(cacheDir, sbomFile, outputFile string) (*exec.Cmd, error) {
+	args := append(w.vulnerabilityArgs(),
+		"--format", "json",
+		"--output", outputFile,
+		sbomFile,
+	)
+
+	return w.prepareCmd(cacheDir, "sbom", args, w.config.Timeout, nil)
+}
+
+// vulnerabilityArgs returns the arguments controlling which vulnerabilities are reported.
+func (w *wrapper) vulnerabilityArgs() []string {
+	args := []string{
+		"--no-progress",
+		"--severity", w.config.Severity,
+		"--vuln-type", w.config.VulnType,
 	}
 
 	if w.config.IgnoreUnfixed {
 		args = append([]string{"--ignore-unfixed"}, args...)
 	}
 
-	if w.config.SkipUpdate {
+	if w.config.SkipUpdate && w.config.ServerURL == "" {
 		args = append([]string{"--skip-db-update"}, args...)
 	}
 
@@ -142,61 +455,130 @@ func (w *wrapper) prepareScanCmd(imageRef ImageRef, outputFile string) (*exec.Cm
 		args = append([]string{"--ignore-policy", w.config.IgnorePolicy}, args...)
 	}
 
-	name, err := w.ambassador.LookPath(tunnelCmd)
-	if err != nil {
-		return nil, err
-	}
-
-	globalArgs := []string{"--cache-dir", w.config.CacheDir}
+	return append(w.serverArgs(), args...)
+}
 
-	if w.config.DebugMode {
-		globalArgs = append(globalArgs, "--debug")
+// throughGateway returns the given image reference pointed at the registry gateway, with the replacer restoring
+// the registry host in the output of Tunnel. The image reference is returned as is without a gateway.
+func (w *wrapper) throughGateway(imageRef ImageRef) (ImageRef, *strings.Replacer, error) {
+	if w.gateway == nil {
+		return imageRef, strings.NewReplacer(), nil
 	}
-	globalArgs = append(globalArgs, "image")
 
-	args = append(globalArgs, args...)
-
-	cmd := exec.Command(name, args...)
+	host, repository, _ := strings.Cut(imageRef.Name, "/")
+	address, err := w.gateway.Address(host, imageRef.Insecure)
+	if err != nil {
+		return ImageRef{}, nil, fmt.Errorf("relaying registry requests: %w", err)
+	}
+	imageRef.Name = address + "/" + repository
+	// The gateway listens over plain HTTP, and establishes the TLS connections to the registry itself.
+	imageRef.Insecure = true
+	return imageRef, strings.NewReplacer(address, host), nil
+}
 
-	cmd.Env = w.ambassador.Environ()
+// timeout returns the timeout of the Tunnel processes analyzing the given image, which is the configured
+// timeout unless the image extends it, capped by the configured maximum.
+func (w *wrapper) timeout(imageRef ImageRef) time.Duration {
+	if imageRef.Timeout <= w.config.Timeout {
+		return w.config.Timeout
+	}
+	return min(imageRef.Timeout, w.config.MaxTimeout)
+}
 
-	cmd.Env = append(cmd.Env, fmt.Sprintf("TUNNEL_TIMEOUT=%s", w.config.Timeout.String()))
+// imageEnv returns the environment variables for pulling the given image.
+func (w *wrapper) imageEnv(imageRef ImageRef) ([]string, error) {
+	var env []string
 
 	switch a := imageRef.Auth.(type) {
 	case NoAuth:
 	case BasicAuth:
-		cmd.Env = append(cmd.Env,
+		env = append(env,
 			fmt.Sprintf("TUNNEL_USERNAME=%s", a.Username),
 			fmt.Sprintf("TUNNEL_PASSWORD=%s", a.Password))
 	case BearerAuth:
-		cmd.Env = append(cmd.Env,
+		env = append(env,
 			fmt.Sprintf("TUNNEL_REGISTRY_TOKEN=%s", a.Token))
 	default:
 		return nil, fmt.Errorf("invalid auth type %T", a)
 	}
 
 	if imageRef.Insecure {
-		cmd.Env = append(cmd.Env, "TUNNEL_NON_SSL=true")
+		env = append(env, "TUNNEL_NON_SSL=true")
+	}
+
+	if w.config.Parallel > 0 {
+		env = append(env, fmt.Sprintf("TUNNEL_PARALLEL=%d", w.config.Parallel))
+	}
+
+	host, _, _ := strings.Cut(imageRef.Name, "/")
+	if bundle := w.config.RegistryCABundle(host); bundle != "" {
+		// Tunnel loads the system roots in addition to the certificates of the SSL_CERT_DIR directories,
+		// so the directory of the bundle is prepended to the ones already configured.
+		certDirs := filepath.Dir(bundle)
+		if dirs := w.environ("SSL_CERT_DIR"); dirs != "" {
+			certDirs += string(filepath.ListSeparator) + dirs
+		}
+		env = append(env, fmt.Sprintf("SSL_CERT_DIR=%s", certDirs))
+	}
+	if !w.config.Insecure && w.config.IsInsecureRegistry(host) {
+		env = append(env, "TUNNEL_INSECURE=true")
 	}
 
+	return env, nil
+}
+
+// environ returns the value of the given variable in the environment of the adapter.
+func (w *wrapper) environ(key string) string {
+	for _, kv := range w.ambassador.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok && k == key {
+			return v
+		}
+	}
+	return ""
+}
+
+func (w *wrapper) prepareCmd(cacheDir, subcommand string, args []string, timeout time.Duration, env []string) (*exec.Cmd, error) {
+	globalArgs := []string{"--cache-dir", cacheDir}
+
+	if w.config.DebugMode {
+		globalArgs = append(globalArgs, "--debug")
+	}
+	globalArgs = append(globalArgs, subcommand)
+
+	args = append(globalArgs, args...)
+
+	env = append([]string{fmt.Sprintf("TUNNEL_TIMEOUT=%s", timeout.String())}, env...)
+
 	if strings.TrimSpace(w.config.GitHubToken) != "" {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("GITHUB_TOKEN=%s", w.config.GitHubToken))
+		env = append(env, fmt.Sprintf("GITHUB_TOKEN=%s", w.config.GitHubToken))
 	}
 
 	if w.config.Insecure {
-		cmd.Env = append(cmd.Env, "TUNNEL_INSECURE=true")
+		env = append(env, "TUNNEL_INSECURE=true")
+	}
+
+	env = append(env, w.serverEnv()...)
+
+	// Tunnel writes to the cache dir and to the reports dir, which also holds the SBOMs it reads.
+	mounts := []executor.Mount{{Path: cacheDir}, {Path: w.config.ReportsDir}}
+	if w.config.IgnorePolicy != "" {
+		mounts = append(mounts, executor.Mount{Path: w.config.IgnorePolicy, ReadOnly: true})
 	}
 
-	return cmd, nil
+	return w.driver.Command(executor.Spec{Args: args, Env: env, Mounts: mounts})
 }
 
 func (w *wrapper) GetVersion() (VersionInfo, error) {
-	cmd, err := w.prepareVersionCmd()
+	cacheDir, releaseCacheDir := w.acquireCacheDir()
+	defer releaseCacheDir()
+
+	cmd, err := w.prepareVersionCmd(cacheDir)
 	if err != nil {
 		return VersionInfo{}, fmt.Errorf("failed preparing tunnel version command: %w", err)
 	}
 
 	versionOutput, err := w.ambassador.RunCmd(cmd)
+	w.driver.Release(cmd)
 	if err != nil {
 		return VersionInfo{}, fmt.Errorf("failed running tunnel version command: %w: %v", err, string(versionOutput))
 	}
@@ -210,18 +592,12 @@ func (w *wrapper) GetVersion() (VersionInfo, error) {
 	return vi, nil
 }
 
-func (w *wrapper) prepareVersionCmd() (*exec.Cmd, error) {
+func (w *wrapper) prepareVersionCmd(cacheDir string) (*exec.Cmd, error) {
 	args := []string{
-		"--cache-dir", w.config.CacheDir,
+		"--cache-dir", cacheDir,
 		"version",
 		"--format", "json",
 	}
 
-	name, err := w.ambassador.LookPath(tunnelCmd)
-	if err != nil {
-		return nil, err
-	}
-
-	cmd := exec.Command(name, args...)
-	return cmd, nil
+	return w.driver.Command(executor.Spec{Args: args, Mounts: []executor.Mount{{Path: cacheDir, ReadOnly: true}}})
 }
@@ -17,7 +17,17 @@ func NewMockWrapper() *MockWrapper {
 	return &MockWrapper{}
 }
 
-func (w *MockWrapper) Scan(imageRef ImageRef) ([]Vulnerability, error) {
+func (w *MockWrapper) Scan(imageRef ImageRef) (Report, error) {
 	args := w.Called(imageRef)
-	return args.Get(0).([]Vulnerability), args.Error(1)
+	return args.Get(0).(Report), args.Error(1)
+}
+
+func (w *MockWrapper) GenerateSBOM(imageRef ImageRef, format SBOMFormat) ([]byte, error) {
+	args := w.Called(imageRef, format)
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (w *MockWrapper) ScanSBOM(sbom []byte) (Report, error) {
+	args := w.Called(sbom)
+	return args.Get(0).(Report), args.Error(1)
 }
@@ -2,21 +2,41 @@ package tunnel
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/ext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
 var (
 	expectedReportJSON = `{
   "SchemaVersion": 2,
+  "Metadata": {
+    "Size": 5814784,
+    "OS": {
+      "Family": "alpine",
+      "Name": "3.10.2"
+    },
+    "ImageConfig": {
+      "architecture": "amd64",
+      "os": "linux"
+    }
+  },
   "Results": [
     {
       "Target": "alpine:3.10.2",
+      "Class": "os-pkgs",
+      "Type": "alpine",
       "Vulnerabilities": [
         {
           "VulnerabilityID": "CVE-2018-6543",
@@ -47,32 +67,42 @@ var (
     }
   ]
 }`
-	expectedReport = []Vulnerability{
-		{
-			VulnerabilityID:  "CVE-2018-6543",
-			PkgName:          "binutils",
-			InstalledVersion: "2.30-r1",
-			FixedVersion:     "2.30-r2",
-			Severity:         "MEDIUM",
-			References: []string{
-				"https://cve.mitre.org/cgi-bin/cvename.cgi?name=CVE-2018-6543",
-			},
-			Layer: &Layer{Digest: "sha256:5216338b40a7b96416b8b9858974bbe4acc3096ee60acbc4dfb1ee02aecceb10"},
-			CVSS: map[string]CVSSInfo{
-				"nvd": {
-					V2Vector: "AV:L/AC:M/Au:N/C:P/I:N/A:N",
-					V3Vector: "CVSS:3.1/AV:L/AC:H/PR:L/UI:N/S:U/C:H/I:N/A:N",
-					V2Score:  float32Ptr(1.9),
-					V3Score:  float32Ptr(4.7),
+	expectedReport = Report{
+		Vulnerabilities: []Vulnerability{
+			{
+				VulnerabilityID:  "CVE-2018-6543",
+				PkgName:          "binutils",
+				InstalledVersion: "2.30-r1",
+				FixedVersion:     "2.30-r2",
+				Severity:         "MEDIUM",
+				References: []string{
+					"https://cve.mitre.org/cgi-bin/cvename.cgi?name=CVE-2018-6543",
 				},
-				"redhat": {
-					V2Vector: "",
-					V3Vector: "CVSS:3.0/AV:L/AC:L/PR:L/UI:N/S:U/C:H/I:N/A:N",
-					V2Score:  nil,
-					V3Score:  float32Ptr(5.5),
+				Layer: &Layer{Digest: "sha256:5216338b40a7b96416b8b9858974bbe4acc3096ee60acbc4dfb1ee02aecceb10"},
+				Class: "os-pkgs",
+				Type:  "alpine",
+				CVSS: map[string]CVSSInfo{
+					"nvd": {
+						V2Vector: "AV:L/AC:M/Au:N/C:P/I:N/A:N",
+						V3Vector: "CVSS:3.1/AV:L/AC:H/PR:L/UI:N/S:U/C:H/I:N/A:N",
+						V2Score:  float32Ptr(1.9),
+						V3Score:  float32Ptr(4.7),
+					},
+					"redhat": {
+						V2Vector: "",
+						V3Vector: "CVSS:3.0/AV:L/AC:L/PR:L/UI:N/S:U/C:H/I:N/A:N",
+						V2Score:  nil,
+						V3Score:  float32Ptr(5.5),
+					},
 				},
 			},
 		},
+		Metadata: ImageMetadata{
+			Size:        5814784,
+			OS:          &OS{Family: "alpine", Name: "3.10.2"},
+			ImageConfig: ImageConfig{Architecture: "amd64", OS: "linux"},
+		},
+		Targets: []Target{{Name: "alpine:3.10.2", Class: "os-pkgs", Type: "alpine"}},
 	}
 
 	expectedVersion = VersionInfo{
@@ -102,6 +132,7 @@ func TestWrapper_Scan(t *testing.T) {
 		GitHubToken:    "<github_token>",
 		Insecure:       true,
 		Timeout:        5 * time.Minute,
+		Parallel:       4,
 	}
 
 	imageRef := ImageRef{
@@ -140,6 +171,7 @@ func TestWrapper_Scan(t *testing.T) {
 		"TUNNEL_USERNAME=dave.loper",
 		"TUNNEL_PASSWORD=s3cret",
 		"TUNNEL_NON_SSL=true",
+		"TUNNEL_PARALLEL=4",
 		"GITHUB_TOKEN=<github_token>",
 		"TUNNEL_INSECURE=true",
 	}
@@ -162,8 +194,719 @@ func TestWrapper_Scan(t *testing.T) {
 	ambassador.AssertExpectations(t)
 }
 
+func TestDefaultParallel(t *testing.T) {
+	testCases := []struct {
+		name              string
+		config            etc.Tunnel
+		workerConcurrency int
+		expected          int
+	}{
+		{name: "Should give whole budget to single worker", workerConcurrency: 1, expected: 10},
+		{name: "Should spread budget among workers", workerConcurrency: 4, expected: 2},
+		{name: "Should download at least one layer", workerConcurrency: 32, expected: 1},
+		{
+			name:              "Should spread budget among processes allowed to pull images",
+			config:            etc.Tunnel{MaxRegistryConnections: 2},
+			workerConcurrency: 8,
+			expected:          5,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, DefaultParallel(tc.config, tc.workerConcurrency))
+		})
+	}
+}
+
+func TestWrapper_Scan_RegistryTLS(t *testing.T) {
+	config := etc.Tunnel{
+		CacheDir:   "/home/scanner/.cache/tunnel",
+		ReportsDir: "/home/scanner/.cache/reports",
+		Severity:   "CRITICAL",
+		Timeout:    5 * time.Minute,
+		RegistryCABundles: []string{
+			"registry.internal=/etc/registry/internal/ca.crt",
+		},
+		InsecureRegistries: []string{"registry.sandbox"},
+	}
+
+	testCases := []struct {
+		name         string
+		environ      []string
+		image        string
+		expectedEnvs []string
+	}{
+		{
+			name:         "Should verify registry with its CA bundle",
+			environ:      []string{},
+			image:        "registry.internal:443/library/alpine:3.10.2",
+			expectedEnvs: []string{"TUNNEL_TIMEOUT=5m0s", "SSL_CERT_DIR=/etc/registry/internal"},
+		},
+		{
+			name:    "Should keep configured certificate directories",
+			environ: []string{"SSL_CERT_DIR=/etc/ssl/certs"},
+			image:   "registry.internal:443/library/alpine:3.10.2",
+			expectedEnvs: []string{
+				"SSL_CERT_DIR=/etc/ssl/certs",
+				"TUNNEL_TIMEOUT=5m0s",
+				"SSL_CERT_DIR=/etc/registry/internal:/etc/ssl/certs",
+			},
+		},
+		{
+			name:         "Should skip verification of insecure registry",
+			environ:      []string{},
+			image:        "registry.sandbox:443/library/alpine:3.10.2",
+			expectedEnvs: []string{"TUNNEL_TIMEOUT=5m0s", "TUNNEL_INSECURE=true"},
+		},
+		{
+			name:         "Should verify other registries with system roots",
+			environ:      []string{},
+			image:        "core.harbor.domain:443/library/alpine:3.10.2",
+			expectedEnvs: []string{"TUNNEL_TIMEOUT=5m0s"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ambassador := ext.NewMockAmbassador()
+			ambassador.On("Environ").Return(tc.environ)
+			ambassador.On("LookPath", "tunnel").Return("/usr/local/bin/tunnel", nil)
+			ambassador.On("TempFile", "/home/scanner/.cache/reports", "scan_report_*.json").
+				Return(ext.NewFakeFile("/home/scanner/.cache/reports/scan_report_1234567890.json", expectedReportJSON), nil)
+			ambassador.On("Remove", "/home/scanner/.cache/reports/scan_report_1234567890.json").Return(nil)
+			ambassador.On("RunCmd", mock.MatchedBy(func(cmd *exec.Cmd) bool {
+				return assert.Equal(t, tc.expectedEnvs, cmd.Env)
+			})).Return([]byte{}, nil)
+
+			_, err := NewWrapper(config, ambassador).Scan(ImageRef{Name: tc.image, Auth: NoAuth{}})
+
+			require.NoError(t, err)
+			ambassador.AssertExpectations(t)
+		})
+	}
+}
+
+type fakeGateway struct {
+	address string
+}
+
+func (g *fakeGateway) Address(_ string, _ bool) (string, error) {
+	return g.address, nil
+}
+
+func TestWrapper_Scan_RegistryGateway(t *testing.T) {
+	config := etc.Tunnel{
+		CacheDir:   "/home/scanner/.cache/tunnel",
+		ReportsDir: "/home/scanner/.cache/reports",
+		Severity:   "CRITICAL",
+		Timeout:    5 * time.Minute,
+	}
+	reportJSON := strings.ReplaceAll(expectedReportJSON, `"alpine:3.10.2"`,
+		`"127.0.0.1:40000/library/alpine:3.10.2 (alpine 3.10.2)"`)
+
+	ambassador := ext.NewMockAmbassador()
+	ambassador.On("Environ").Return([]string{})
+	ambassador.On("LookPath", "tunnel").Return("/usr/local/bin/tunnel", nil)
+	ambassador.On("TempFile", "/home/scanner/.cache/reports", "scan_report_*.json").
+		Return(ext.NewFakeFile("/home/scanner/.cache/reports/scan_report_1234567890.json", reportJSON), nil)
+	ambassador.On("Remove", "/home/scanner/.cache/reports/scan_report_1234567890.json").Return(nil)
+	ambassador.On("RunCmd", mock.MatchedBy(func(cmd *exec.Cmd) bool {
+		return assert.Equal(t, "127.0.0.1:40000/library/alpine:3.10.2", cmd.Args[len(cmd.Args)-1]) &&
+			assert.Contains(t, cmd.Env, "TUNNEL_NON_SSL=true")
+	})).Return([]byte{}, nil)
+
+	report, err := NewWrapper(config, ambassador, WithRegistryGateway(&fakeGateway{address: "127.0.0.1:40000"})).
+		Scan(ImageRef{Name: "core.harbor.domain:443/library/alpine:3.10.2", Auth: NoAuth{}})
+
+	require.NoError(t, err)
+	assert.Equal(t, []Target{{
+		Name:  "core.harbor.domain:443/library/alpine:3.10.2 (alpine 3.10.2)",
+		Class: "os-pkgs",
+		Type:  "alpine",
+	}}, report.Targets)
+	ambassador.AssertExpectations(t)
+}
+
+func TestWrapper_Scan_Timeout(t *testing.T) {
+	config := etc.Tunnel{
+		CacheDir:   "/home/scanner/.cache/tunnel",
+		ReportsDir: "/home/scanner/.cache/reports",
+		Timeout:    5 * time.Minute,
+		MaxTimeout: 30 * time.Minute,
+	}
+
+	testCases := []struct {
+		name            string
+		timeout         time.Duration
+		expectedTimeout string
+	}{
+		{
+			name:            "Should apply configured timeout",
+			expectedTimeout: "TUNNEL_TIMEOUT=5m0s",
+		},
+		{
+			name:            "Should extend configured timeout",
+			timeout:         20 * time.Minute,
+			expectedTimeout: "TUNNEL_TIMEOUT=20m0s",
+		},
+		{
+			name:            "Should cap extended timeout",
+			timeout:         2 * time.Hour,
+			expectedTimeout: "TUNNEL_TIMEOUT=30m0s",
+		},
+		{
+			name:            "Should not shorten configured timeout",
+			timeout:         time.Minute,
+			expectedTimeout: "TUNNEL_TIMEOUT=5m0s",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ambassador := ext.NewMockAmbassador()
+			ambassador.On("Environ").Return([]string{})
+			ambassador.On("LookPath", "tunnel").Return("/usr/local/bin/tunnel", nil)
+			ambassador.On("TempFile", "/home/scanner/.cache/reports", "scan_report_*.json").
+				Return(ext.NewFakeFile("/home/scanner/.cache/reports/scan_report_1234567890.json", expectedReportJSON), nil)
+			ambassador.On("Remove", "/home/scanner/.cache/reports/scan_report_1234567890.json").Return(nil)
+			ambassador.On("RunCmd", mock.MatchedBy(func(cmd *exec.Cmd) bool {
+				return assert.Equal(t, []string{tc.expectedTimeout}, cmd.Env)
+			})).Return([]byte{}, nil)
+
+			_, err := NewWrapper(config, ambassador).Scan(ImageRef{Name: "core.harbor.domain:443/library/alpine:3.10.2",
+				Auth: NoAuth{}, Timeout: tc.timeout})
+
+			require.NoError(t, err)
+			ambassador.AssertExpectations(t)
+		})
+	}
+}
+
+func TestWrapper_Scan_MaxRegistryConnections(t *testing.T) {
+	const scans = 6
+
+	ambassador := ext.NewMockAmbassador()
+	ambassador.On("Environ").Return([]string{})
+	ambassador.On("LookPath", "tunnel").Return("/usr/local/bin/tunnel", nil)
+	ambassador.On("Remove", mock.Anything).Return(nil)
+	for i := 0; i < scans; i++ {
+		ambassador.On("TempFile", "/home/scanner/.cache/reports", "scan_report_*.json").
+			Return(ext.NewFakeFile(fmt.Sprintf("/home/scanner/.cache/reports/scan_report_%d.json", i), expectedReportJSON), nil).
+			Once()
+	}
+
+	var inProgress, maxInProgress int32
+	ambassador.On("RunCmd", mock.Anything).Run(func(_ mock.Arguments) {
+		n := atomic.AddInt32(&inProgress, 1)
+		for {
+			max := atomic.LoadInt32(&maxInProgress)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInProgress, max, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&inProgress, -1)
+	}).Return([]byte{}, nil)
+
+	wrapper := NewWrapper(etc.Tunnel{
+		ReportsDir:             "/home/scanner/.cache/reports",
+		MaxRegistryConnections: 2,
+	}, ambassador)
+
+	var wg sync.WaitGroup
+	for i := 0; i < scans; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := wrapper.Scan(ImageRef{Name: "alpine:3.10.2", Auth: NoAuth{}})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&maxInProgress))
+	ambassador.AssertNumberOfCalls(t, "RunCmd", scans)
+}
+
+func TestWrapper_Scan_IsolatedCache(t *testing.T) {
+	const scans = 6
+
+	ambassador := ext.NewMockAmbassador()
+	ambassador.On("Environ").Return([]string{})
+	ambassador.On("LookPath", "tunnel").Return("/usr/local/bin/tunnel", nil)
+	ambassador.On("Remove", mock.Anything).Return(nil)
+	for i := 0; i < scans; i++ {
+		ambassador.On("TempFile", "/home/scanner/.cache/reports", "scan_report_*.json").
+			Return(ext.NewFakeFile(fmt.Sprintf("/home/scanner/.cache/reports/scan_report_%d.json", i), expectedReportJSON), nil).
+			Once()
+	}
+
+	var mu sync.Mutex
+	inUse := make(map[string]bool)
+	ambassador.On("RunCmd", mock.Anything).Run(func(args mock.Arguments) {
+		cacheDir := args.Get(0).(*exec.Cmd).Args[2]
+		assert.True(t, strings.HasPrefix(cacheDir, "/home/scanner/.cache/tunnel/isolated/"), cacheDir)
+
+		mu.Lock()
+		assert.False(t, inUse[cacheDir], "cache dir %s is used by concurrent processes", cacheDir)
+		inUse[cacheDir] = true
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		inUse[cacheDir] = false
+		mu.Unlock()
+	}).Return([]byte{}, nil)
+
+	wrapper := NewWrapper(etc.Tunnel{
+		CacheDir:   "/home/scanner/.cache/tunnel",
+		ReportsDir: "/home/scanner/.cache/reports",
+		CacheMode:  CacheModeIsolated,
+	}, ambassador)
+
+	var wg sync.WaitGroup
+	for i := 0; i < scans; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := wrapper.Scan(ImageRef{Name: "alpine:3.10.2", Auth: NoAuth{}})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	ambassador.AssertNumberOfCalls(t, "RunCmd", scans)
+}
+
+func TestWrapper_Scan_CacheRepair(t *testing.T) {
+	config := etc.Tunnel{
+		CacheDir:   "/home/scanner/.cache/tunnel",
+		ReportsDir: "/home/scanner/.cache/reports",
+	}
+
+	t.Run("Should clear corrupted scan cache and retry", func(t *testing.T) {
+		ambassador := ext.NewMockAmbassador()
+		ambassador.On("Environ").Return([]string{})
+		ambassador.On("LookPath", "tunnel").Return("/usr/local/bin/tunnel", nil)
+		ambassador.On("Remove", mock.Anything).Return(nil)
+		ambassador.On("TempFile", "/home/scanner/.cache/reports", "scan_report_*.json").
+			Return(ext.NewFakeFile("/home/scanner/.cache/reports/scan_report_1.json", expectedReportJSON), nil)
+		ambassador.On("RunCmd", mock.Anything).
+			Return([]byte("unable to initialize the cache: invalid database"), errors.New("exit status 1")).Once()
+		ambassador.On("RemoveAll", "/home/scanner/.cache/tunnel/fanal").Return(nil)
+		ambassador.On("RunCmd", mock.Anything).Return([]byte{}, nil).Once()
+
+		report, err := NewWrapper(config, ambassador).Scan(ImageRef{Name: "alpine:3.10.2", Auth: NoAuth{}})
+
+		require.NoError(t, err)
+		assert.Equal(t, expectedReport, report)
+		ambassador.AssertExpectations(t)
+	})
+
+	t.Run("Should not clear scan cache when Tunnel fails for another reason", func(t *testing.T) {
+		ambassador := ext.NewMockAmbassador()
+		ambassador.On("Environ").Return([]string{})
+		ambassador.On("LookPath", "tunnel").Return("/usr/local/bin/tunnel", nil)
+		ambassador.On("Remove", mock.Anything).Return(nil)
+		ambassador.On("TempFile", "/home/scanner/.cache/reports", "scan_report_*.json").
+			Return(ext.NewFakeFile("/home/scanner/.cache/reports/scan_report_1.json", expectedReportJSON), nil)
+		ambassador.On("RunCmd", mock.Anything).
+			Return([]byte("MANIFEST_UNKNOWN: manifest unknown"), errors.New("exit status 1"))
+
+		_, err := NewWrapper(config, ambassador).Scan(ImageRef{Name: "alpine:3.10.2", Auth: NoAuth{}})
+
+		assert.ErrorIs(t, err, ErrArtifactUnscannable)
+		assert.EqualError(t, err, "artifact cannot be scanned: running tunnel: exit status 1: MANIFEST_UNKNOWN: manifest unknown")
+		ambassador.AssertNotCalled(t, "RemoveAll", mock.Anything)
+		ambassador.AssertNumberOfCalls(t, "RunCmd", 1)
+	})
+}
+
+func TestWrapper_Scan_Unauthorized(t *testing.T) {
+	ambassador := ext.NewMockAmbassador()
+	ambassador.On("Environ").Return([]string{})
+	ambassador.On("LookPath", "tunnel").Return("/usr/local/bin/tunnel", nil)
+	ambassador.On("Remove", mock.Anything).Return(nil)
+	ambassador.On("TempFile", "/home/scanner/.cache/reports", "scan_report_*.json").
+		Return(ext.NewFakeFile("/home/scanner/.cache/reports/scan_report_1.json", expectedReportJSON), nil)
+	ambassador.On("RunCmd", mock.Anything).
+		Return([]byte("GET https://core.harbor.domain/v2/library/alpine/manifests/3.10.2: UNAUTHORIZED: unauthorized to access repository"),
+			errors.New("exit status 1"))
+
+	_, err := NewWrapper(etc.Tunnel{
+		CacheDir:   "/home/scanner/.cache/tunnel",
+		ReportsDir: "/home/scanner/.cache/reports",
+	}, ambassador).Scan(ImageRef{Name: "core.harbor.domain/library/alpine:3.10.2", Auth: BasicAuth{Username: "robot$scanner", Password: "s3cret"}})
+
+	assert.ErrorIs(t, err, ErrRegistryUnauthorized)
+	assert.EqualError(t, err, "registry rejected credentials: running tunnel: exit status 1: "+
+		"GET https://core.harbor.domain/v2/library/alpine/manifests/3.10.2: UNAUTHORIZED: unauthorized to access repository")
+	ambassador.AssertNotCalled(t, "RemoveAll", mock.Anything)
+}
+
+func TestWrapper_Scan_RegistryThrottled(t *testing.T) {
+	throttled := []byte("GET https://index.docker.io/v2/library/alpine/manifests/3.10.2: TOOMANYREQUESTS: You have reached your pull rate limit")
+
+	t.Run("Should retry throttled pull", func(t *testing.T) {
+		ambassador := ext.NewMockAmbassador()
+		ambassador.On("Environ").Return([]string{})
+		ambassador.On("LookPath", "tunnel").Return("/usr/local/bin/tunnel", nil)
+		ambassador.On("Remove", mock.Anything).Return(nil)
+		ambassador.On("TempFile", "/home/scanner/.cache/reports", "scan_report_*.json").
+			Return(ext.NewFakeFile("/home/scanner/.cache/reports/scan_report_1.json", expectedReportJSON), nil)
+		ambassador.On("RunCmd", mock.Anything).Return(throttled, errors.New("exit status 1")).Once()
+		ambassador.On("RunCmd", mock.Anything).Return([]byte{}, nil).Once()
+
+		w := NewWrapper(etc.Tunnel{
+			CacheDir:                    "/home/scanner/.cache/tunnel",
+			ReportsDir:                  "/home/scanner/.cache/reports",
+			MaxRegistryConnections:      4,
+			RegistryAdaptiveConcurrency: true,
+			RegistryThrottleRetries:     3,
+			RegistryThrottleBackoff:     5 * time.Second,
+		}, ambassador).(*wrapper)
+		var slept []time.Duration
+		w.sleep = func(d time.Duration) {
+			slept = append(slept, d)
+		}
+
+		report, err := w.Scan(ImageRef{Name: "alpine:3.10.2", Auth: NoAuth{}})
+
+		require.NoError(t, err)
+		assert.Equal(t, expectedReport, report)
+		require.Len(t, slept, 1)
+		assert.LessOrEqual(t, slept[0], 5*time.Second)
+		assert.Equal(t, 2.5, w.registryConnections.limit)
+		ambassador.AssertNumberOfCalls(t, "RunCmd", 2)
+	})
+
+	t.Run("Should return error when retries are exhausted", func(t *testing.T) {
+		ambassador := ext.NewMockAmbassador()
+		ambassador.On("Environ").Return([]string{})
+		ambassador.On("LookPath", "tunnel").Return("/usr/local/bin/tunnel", nil)
+		ambassador.On("Remove", mock.Anything).Return(nil)
+		ambassador.On("TempFile", "/home/scanner/.cache/reports", "scan_report_*.json").
+			Return(ext.NewFakeFile("/home/scanner/.cache/reports/scan_report_1.json", expectedReportJSON), nil)
+		ambassador.On("RunCmd", mock.Anything).Return(throttled, errors.New("exit status 1"))
+
+		w := NewWrapper(etc.Tunnel{
+			CacheDir:                "/home/scanner/.cache/tunnel",
+			ReportsDir:              "/home/scanner/.cache/reports",
+			RegistryThrottleRetries: 2,
+		}, ambassador).(*wrapper)
+		w.sleep = func(time.Duration) {}
+
+		_, err := w.Scan(ImageRef{Name: "alpine:3.10.2", Auth: NoAuth{}})
+
+		assert.ErrorIs(t, err, ErrRegistryThrottled)
+		ambassador.AssertNumberOfCalls(t, "RunCmd", 3)
+	})
+}
+
+func TestWrapper_Scan_Partial(t *testing.T) {
+	// The report of the first target was written before the scan was interrupted in the middle of the second.
+	truncatedReportJSON := strings.TrimSuffix(expectedReportJSON, "\n  ]\n}") + `,
+    {
+      "Target": "usr/local/bin/mongod",
+      "Class": "lang-pkgs",
+      "Type": "gobinary",
+      "Vulnerabilities": [
+        {
+          "VulnerabilityID": "CVE-2023-`
+
+	testCases := []struct {
+		name           string
+		reportJSON     string
+		stdout         string
+		runErr         string
+		expectedReport Report
+		expectedError  string
+	}{
+		{
+			name:       "Should recover results written before scan timed out",
+			reportJSON: truncatedReportJSON,
+			stdout:     "FATAL scan error: context deadline exceeded",
+			runErr:     "signal: killed",
+			expectedReport: Report{
+				Vulnerabilities: expectedReport.Vulnerabilities,
+				Metadata:        expectedReport.Metadata,
+				Targets:         expectedReport.Targets,
+				Partial:         true,
+				Interruption:    "running tunnel: signal: killed: FATAL scan error: context deadline exceeded",
+			},
+		},
+		{
+			name:          "Should return error when interrupted scan did not write results",
+			reportJSON:    `{"SchemaVersion": 2, "Results": [`,
+			stdout:        "FATAL scan error: context deadline exceeded",
+			runErr:        "signal: killed",
+			expectedError: "running tunnel: signal: killed: FATAL scan error: context deadline exceeded",
+		},
+		{
+			name:          "Should return error when scan failed without being interrupted",
+			reportJSON:    truncatedReportJSON,
+			stdout:        "FATAL image scan error: manifest unknown",
+			runErr:        "exit status 1",
+			expectedError: "artifact cannot be scanned: running tunnel: exit status 1: FATAL image scan error: manifest unknown",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ambassador := ext.NewMockAmbassador()
+			ambassador.On("Environ").Return([]string{})
+			ambassador.On("LookPath", "tunnel").Return("/usr/local/bin/tunnel", nil)
+			ambassador.On("Remove", mock.Anything).Return(nil)
+			ambassador.On("TempFile", "/home/scanner/.cache/reports", "scan_report_*.json").
+				Return(ext.NewFakeFile("/home/scanner/.cache/reports/scan_report_1.json", tc.reportJSON), nil)
+			ambassador.On("RunCmd", mock.Anything).Return([]byte(tc.stdout), errors.New(tc.runErr))
+
+			report, err := NewWrapper(etc.Tunnel{
+				CacheDir:   "/home/scanner/.cache/tunnel",
+				ReportsDir: "/home/scanner/.cache/reports",
+			}, ambassador).Scan(ImageRef{Name: "core.harbor.domain/library/mongo:7.0", Auth: NoAuth{}})
+
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedReport, report)
+		})
+	}
+}
+
+func TestWrapper_Scan_DBRepair(t *testing.T) {
+	config := etc.Tunnel{
+		CacheDir:            "/home/scanner/.cache/tunnel",
+		ReportsDir:          "/home/scanner/.cache/reports",
+		DBRepairMaxFailures: 3,
+		DBRepairCooldown:    10 * time.Minute,
+	}
+
+	t.Run("Should purge corrupted vulnerability DB and retry", func(t *testing.T) {
+		ambassador := ext.NewMockAmbassador()
+		ambassador.On("Environ").Return([]string{})
+		ambassador.On("LookPath", "tunnel").Return("/usr/local/bin/tunnel", nil)
+		ambassador.On("Remove", mock.Anything).Return(nil)
+		ambassador.On("TempFile", "/home/scanner/.cache/reports", "scan_report_*.json").
+			Return(ext.NewFakeFile("/home/scanner/.cache/reports/scan_report_1.json", expectedReportJSON), nil)
+		ambassador.On("RunCmd", mock.Anything).
+			Return([]byte("init error: DB error: failed to open DB: invalid database"), errors.New("exit status 1")).Once()
+		ambassador.On("RemoveAll", "/home/scanner/.cache/tunnel/db").Return(nil)
+		ambassador.On("RunCmd", mock.Anything).Return([]byte{}, nil).Once()
+
+		report, err := NewWrapper(config, ambassador).Scan(ImageRef{Name: "alpine:3.10.2", Auth: NoAuth{}})
+
+		require.NoError(t, err)
+		assert.Equal(t, expectedReport, report)
+		ambassador.AssertExpectations(t)
+		ambassador.AssertNotCalled(t, "RemoveAll", "/home/scanner/.cache/tunnel/fanal")
+	})
+
+	t.Run("Should not purge vulnerability DB when updates are skipped", func(t *testing.T) {
+		config := config
+		config.SkipUpdate = true
+
+		ambassador := ext.NewMockAmbassador()
+		ambassador.On("Environ").Return([]string{})
+		ambassador.On("LookPath", "tunnel").Return("/usr/local/bin/tunnel", nil)
+		ambassador.On("Remove", mock.Anything).Return(nil)
+		ambassador.On("TempFile", "/home/scanner/.cache/reports", "scan_report_*.json").
+			Return(ext.NewFakeFile("/home/scanner/.cache/reports/scan_report_1.json", expectedReportJSON), nil)
+		ambassador.On("RunCmd", mock.Anything).
+			Return([]byte("init error: DB error: old DB schema"), errors.New("exit status 1"))
+
+		_, err := NewWrapper(config, ambassador).Scan(ImageRef{Name: "alpine:3.10.2", Auth: NoAuth{}})
+
+		assert.EqualError(t, err, "running tunnel: exit status 1: init error: DB error: old DB schema")
+		ambassador.AssertNotCalled(t, "RemoveAll", mock.Anything)
+		ambassador.AssertNumberOfCalls(t, "RunCmd", 1)
+	})
+}
+
+func TestWrapper_GenerateSBOM(t *testing.T) {
+	ambassador := ext.NewMockAmbassador()
+	ambassador.On("Environ").Return([]string{"HTTP_PROXY=http://someproxy:7777"})
+	ambassador.On("LookPath", "tunnel").Return("/usr/local/bin/tunnel", nil)
+
+	config := etc.Tunnel{
+		CacheDir:   "/home/scanner/.cache/tunnel",
+		ReportsDir: "/home/scanner/.cache/reports",
+		Severity:   "CRITICAL,MEDIUM",
+		SkipUpdate: true,
+		Timeout:    5 * time.Minute,
+	}
+
+	imageRef := ImageRef{
+		Name: "alpine:3.10.2",
+		Auth: BearerAuth{Token: "s3cret"},
+	}
+
+	expectedSBOM := `{"bomFormat": "CycloneDX", "specVersion": "1.5", "components": []}`
+
+	ambassador.On("TempFile", "/home/scanner/.cache/reports", "sbom_*.json").
+		Return(ext.NewFakeFile("/home/scanner/.cache/reports/sbom_1234567890.json", expectedSBOM), nil)
+	ambassador.On("Remove", "/home/scanner/.cache/reports/sbom_1234567890.json").
+		Return(nil)
+	ambassador.On("RunCmd", &exec.Cmd{
+		Path: "/usr/local/bin/tunnel",
+		Env: []string{
+			"HTTP_PROXY=http://someproxy:7777",
+			"TUNNEL_TIMEOUT=5m0s",
+			"TUNNEL_REGISTRY_TOKEN=s3cret",
+		},
+		Args: []string{
+			"/usr/local/bin/tunnel",
+			"--cache-dir",
+			"/home/scanner/.cache/tunnel",
+			"image",
+			"--no-progress",
+			"--format",
+			"cyclonedx",
+			"--output",
+			"/home/scanner/.cache/reports/sbom_1234567890.json",
+			"alpine:3.10.2",
+		}},
+	).Return([]byte{}, nil)
+
+	sbom, err := NewWrapper(config, ambassador).GenerateSBOM(imageRef, SBOMFormatCycloneDX)
+
+	require.NoError(t, err)
+	require.Equal(t, expectedSBOM, string(sbom))
+
+	ambassador.AssertExpectations(t)
+}
+
+func TestWrapper_GenerateSBOM_SPDX(t *testing.T) {
+	config := etc.Tunnel{
+		CacheDir:   "/home/scanner/.cache/tunnel",
+		ReportsDir: "/home/scanner/.cache/reports",
+		SkipUpdate: true,
+		Timeout:    5 * time.Minute,
+	}
+	imageRef := ImageRef{Name: "alpine:3.10.2", Auth: NoAuth{}}
+
+	testCases := []struct {
+		name          string
+		sbom          string
+		expectedError string
+	}{
+		{
+			name: "Should generate SPDX document",
+			sbom: `{"spdxVersion": "SPDX-2.3", "SPDXID": "SPDXRef-DOCUMENT", "name": "alpine:3.10.2"}`,
+		},
+		{
+			name:          "Should return error when document is not SPDX",
+			sbom:          `{"bomFormat": "CycloneDX", "specVersion": "1.5"}`,
+			expectedError: `unsupported SPDX version: ""`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ambassador := ext.NewMockAmbassador()
+			ambassador.On("Environ").Return([]string{})
+			ambassador.On("LookPath", "tunnel").Return("/usr/local/bin/tunnel", nil)
+			ambassador.On("TempFile", "/home/scanner/.cache/reports", "sbom_*.json").
+				Return(ext.NewFakeFile("/home/scanner/.cache/reports/sbom_1234567890.json", tc.sbom), nil)
+			ambassador.On("Remove", "/home/scanner/.cache/reports/sbom_1234567890.json").
+				Return(nil)
+			ambassador.On("RunCmd", &exec.Cmd{
+				Path: "/usr/local/bin/tunnel",
+				Env:  []string{"TUNNEL_TIMEOUT=5m0s"},
+				Args: []string{
+					"/usr/local/bin/tunnel",
+					"--cache-dir",
+					"/home/scanner/.cache/tunnel",
+					"image",
+					"--no-progress",
+					"--format",
+					"spdx-json",
+					"--output",
+					"/home/scanner/.cache/reports/sbom_1234567890.json",
+					"alpine:3.10.2",
+				}},
+			).Return([]byte{}, nil)
+
+			sbom, err := NewWrapper(config, ambassador).GenerateSBOM(imageRef, SBOMFormatSPDXJSON)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.sbom, string(sbom))
+			ambassador.AssertExpectations(t)
+		})
+	}
+}
+
+func TestWrapper_ScanSBOM(t *testing.T) {
+	ambassador := ext.NewMockAmbassador()
+	ambassador.On("Environ").Return([]string{"HTTP_PROXY=http://someproxy:7777"})
+	ambassador.On("LookPath", "tunnel").Return("/usr/local/bin/tunnel", nil)
+
+	config := etc.Tunnel{
+		CacheDir:      "/home/scanner/.cache/tunnel",
+		ReportsDir:    "/home/scanner/.cache/reports",
+		VulnType:      "os,library",
+		Severity:      "CRITICAL,MEDIUM",
+		IgnoreUnfixed: true,
+		OfflineScan:   true,
+		Timeout:       5 * time.Minute,
+	}
+
+	sbom := `{"bomFormat": "CycloneDX", "specVersion": "1.5", "components": []}`
+	sbomFile := ext.NewFakeFile("/home/scanner/.cache/reports/sbom_1234567890.json", "")
+
+	ambassador.On("TempFile", "/home/scanner/.cache/reports", "sbom_*.json").
+		Return(sbomFile, nil)
+	ambassador.On("TempFile", "/home/scanner/.cache/reports", "scan_report_*.json").
+		Return(ext.NewFakeFile("/home/scanner/.cache/reports/scan_report_1234567890.json", expectedReportJSON), nil)
+	ambassador.On("Remove", "/home/scanner/.cache/reports/sbom_1234567890.json").
+		Return(nil)
+	ambassador.On("Remove", "/home/scanner/.cache/reports/scan_report_1234567890.json").
+		Return(nil)
+	ambassador.On("RunCmd", &exec.Cmd{
+		Path: "/usr/local/bin/tunnel",
+		Env: []string{
+			"HTTP_PROXY=http://someproxy:7777",
+			"TUNNEL_TIMEOUT=5m0s",
+		},
+		Args: []string{
+			"/usr/local/bin/tunnel",
+			"--cache-dir",
+			"/home/scanner/.cache/tunnel",
+			"sbom",
+			"--offline-scan",
+			"--ignore-unfixed",
+			"--no-progress",
+			"--severity",
+			"CRITICAL,MEDIUM",
+			"--vuln-type",
+			"os,library",
+			"--format",
+			"json",
+			"--output",
+			"/home/scanner/.cache/reports/scan_report_1234567890.json",
+			"/home/scanner/.cache/reports/sbom_1234567890.json",
+		}},
+	).Return([]byte{}, nil)
+
+	report, err := NewWrapper(config, ambassador).ScanSBOM([]byte(sbom))
+
+	require.NoError(t, err)
+	require.Equal(t, expectedReport, report)
+	require.Equal(t, sbom, sbomFile.Written())
+
+	ambassador.AssertExpectations(t)
+}
+
 func TestWrapper_GetVersion(t *testing.T) {
 	ambassador := ext.NewMockAmbassador()
+	ambassador.On("Environ").Return([]string{})
 	ambassador.On("LookPath", "tunnel").Return("/usr/local/bin/tunnel", nil)
 
 	config := etc.Tunnel{
@@ -183,6 +926,7 @@ func TestWrapper_GetVersion(t *testing.T) {
 	b, _ := json.Marshal(expectedVersion)
 	ambassador.On("RunCmd", &exec.Cmd{
 		Path: "/usr/local/bin/tunnel",
+		Env:  []string{},
 		Args: expectedCmdArgs},
 	).Return(b, nil)
 
@@ -0,0 +1,149 @@
+package vex
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// csafCategoryVEX is the category of CSAF documents following the VEX profile.
+const csafCategoryVEX = "csaf_vex"
+
+// csafDocument is a CSAF VEX document. Its vulnerabilities list the IDs of the products not affected by them, or
+// fixed, which are mapped to package URLs by the product tree. Products are either named directly, or related
+// to other products, e.g. a package installed on an image.
+type csafDocument struct {
+	Document struct {
+		Tracking struct {
+			ID string `json:"id"`
+		} `json:"tracking"`
+	} `json:"document"`
+	ProductTree struct {
+		Branches         []csafBranch       `json:"branches"`
+		FullProductNames []csafProductName  `json:"full_product_names"`
+		Relationships    []csafRelationship `json:"relationships"`
+	} `json:"product_tree"`
+	Vulnerabilities []csafVulnerability `json:"vulnerabilities"`
+}
+
+type csafBranch struct {
+	Branches []csafBranch     `json:"branches"`
+	Product  *csafProductName `json:"product"`
+}
+
+type csafProductName struct {
+	ProductID                   string `json:"product_id"`
+	ProductIdentificationHelper struct {
+		PURL string `json:"purl"`
+	} `json:"product_identification_helper"`
+}
+
+type csafRelationship struct {
+	ProductReference          string          `json:"product_reference"`
+	RelatesToProductReference string          `json:"relates_to_product_reference"`
+	FullProductName           csafProductName `json:"full_product_name"`
+}
+
+type csafVulnerability struct {
+	CVE string `json:"cve"`
+	IDs []struct {
+		Text string `json:"text"`
+	} `json:"ids"`
+	ProductStatus struct {
+		Fixed            []string `json:"fixed"`
+		KnownNotAffected []string `json:"known_not_affected"`
+	} `json:"product_status"`
+	Flags []struct {
+		Label      string   `json:"label"`
+		ProductIDs []string `json:"product_ids"`
+	} `json:"flags"`
+}
+
+func parseCSAF(b []byte) ([]Statement, error) {
+	var d csafDocument
+	if err := json.Unmarshal(b, &d); err != nil {
+		return nil, fmt.Errorf("decoding CSAF document: %w", err)
+	}
+	id := d.Document.Tracking.ID
+
+	products := make(map[string]Product)
+	var walk func(branches []csafBranch)
+	walk = func(branches []csafBranch) {
+		for _, branch := range branches {
+			if branch.Product != nil {
+				addCSAFProduct(products, *branch.Product)
+			}
+			walk(branch.Branches)
+		}
+	}
+	walk(d.ProductTree.Branches)
+	for _, p := range d.ProductTree.FullProductNames {
+		addCSAFProduct(products, p)
+	}
+	// The product of a relationship is the component installed on the product it relates to.
+	for _, r := range d.ProductTree.Relationships {
+		component, ok := products[r.ProductReference]
+		if !ok {
+			continue
+		}
+		parent, ok := products[r.RelatesToProductReference]
+		if !ok {
+			continue
+		}
+		products[r.FullProductName.ProductID] = Product{ID: parent.ID, Subcomponents: []string{component.ID}}
+	}
+
+	var statements []Statement
+	for i, v := range d.Vulnerabilities {
+		name := v.CVE
+		var aliases []string
+		for _, alias := range v.IDs {
+			if name == "" {
+				name = alias.Text
+				continue
+			}
+			aliases = append(aliases, alias.Text)
+		}
+		if name == "" {
+			return nil, fmt.Errorf("CSAF document %s: vulnerability %d has no CVE or ID", id, i)
+		}
+
+		for _, status := range []struct {
+			status     Status
+			productIDs []string
+		}{
+			{status: StatusNotAffected, productIDs: v.ProductStatus.KnownNotAffected},
+			{status: StatusFixed, productIDs: v.ProductStatus.Fixed},
+		} {
+			for _, productID := range status.productIDs {
+				// Products identified without a package URL, e.g. by their CPE only, cannot be matched.
+				product, ok := products[productID]
+				if !ok {
+					continue
+				}
+				statement := Statement{
+					Vulnerability: name,
+					Aliases:       aliases,
+					Products:      []Product{product},
+					Status:        status.status,
+					Document:      id,
+				}
+				for _, flag := range v.Flags {
+					if slices.Contains(flag.ProductIDs, productID) {
+						statement.Justification = flag.Label
+					}
+				}
+				statements = append(statements, statement)
+			}
+		}
+	}
+	return statements, nil
+}
+
+// addCSAFProduct adds the given product to the products indexed by ID, if it is identified by a package URL.
+func addCSAFProduct(products map[string]Product, p csafProductName) {
+	if p.ProductIdentificationHelper.PURL == "" {
+		return
+	}
+	products[p.ProductID] = Product{ID: p.ProductIdentificationHelper.PURL}
+}
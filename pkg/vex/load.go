@@ -0,0 +1,184 @@
+package vex
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/registry"
+)
+
+// Media types of the layers of OCI artifacts read as VEX documents.
+const (
+	MediaTypeOpenVEX = "application/vnd.openvex+json"
+	MediaTypeCSAF    = "application/csaf+json"
+	mediaTypeJSON    = "application/json"
+)
+
+const mediaTypeOCIManifest = "application/vnd.oci.image.manifest.v1+json"
+
+// maxDocumentSize bounds the size of VEX documents and of the manifests of OCI artifacts holding them.
+const maxDocumentSize = 16 << 20
+
+// Load constructs the Matcher of the statements of the VEX documents read from the configured path, then from
+// the configured OCI artifact. Documents of a directory are read in the order of their names.
+func Load(ctx context.Context, config etc.VEX, tunnel etc.Tunnel) (Matcher, error) {
+	var statements []Statement
+	if config.Path != "" {
+		s, err := loadPath(config.Path)
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, s...)
+	}
+	if config.OCIRef != "" {
+		s, err := loadOCI(ctx, config, tunnel)
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, s...)
+	}
+	return NewMatcher(statements), nil
+}
+
+func loadPath(path string) ([]Statement, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading VEX documents: %w", err)
+	}
+	files := []string{path}
+	if info.IsDir() {
+		// Glob returns the matching files sorted by name.
+		if files, err = filepath.Glob(filepath.Join(path, "*.json")); err != nil {
+			return nil, fmt.Errorf("reading VEX documents: %w", err)
+		}
+	}
+
+	var statements []Statement
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("reading VEX document: %w", err)
+		}
+		s, err := Parse(b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		statements = append(statements, s...)
+	}
+	return statements, nil
+}
+
+type manifest struct {
+	Layers []struct {
+		MediaType string `json:"mediaType"`
+		Digest    string `json:"digest"`
+	} `json:"layers"`
+}
+
+// loadOCI pulls the VEX documents from the layers of the configured OCI artifact, in the order of its manifest.
+func loadOCI(ctx context.Context, config etc.VEX, tunnel etc.Tunnel) ([]Statement, error) {
+	u, err := url.Parse(config.OCIRef)
+	if err != nil {
+		return nil, fmt.Errorf("parsing VEX OCI reference: %w", err)
+	}
+	client, err := registry.NewHTTPClient(tunnel, u.Host)
+	if err != nil {
+		return nil, err
+	}
+	c := &ociClient{client: client, username: config.OCIUsername, password: config.OCIPassword}
+	repository, reference := parseReference(strings.Trim(u.Path, "/"))
+	base := "https://" + u.Host + "/v2/" + repository
+
+	b, err := c.get(ctx, base+"/manifests/"+reference, mediaTypeOCIManifest)
+	if err != nil {
+		return nil, err
+	}
+	var m manifest
+	if err = json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("decoding manifest: %w", err)
+	}
+
+	var statements []Statement
+	for _, layer := range m.Layers {
+		if !slices.Contains([]string{MediaTypeOpenVEX, MediaTypeCSAF, mediaTypeJSON}, layer.MediaType) {
+			continue
+		}
+		expected, err := digest.Parse(layer.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid digest: %s", layer.Digest)
+		}
+		document, err := c.get(ctx, base+"/blobs/"+layer.Digest, "*/*")
+		if err != nil {
+			return nil, err
+		}
+		if actual := expected.Algorithm().FromBytes(document); actual != expected {
+			return nil, fmt.Errorf("blob digest mismatch: expected %s, got %s", expected, actual)
+		}
+		s, err := Parse(document)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", layer.Digest, err)
+		}
+		statements = append(statements, s...)
+	}
+	return statements, nil
+}
+
+// parseReference splits the given repository path into the repository and the tag or digest, which defaults to
+// the latest tag.
+func parseReference(path string) (repository, reference string) {
+	if repository, reference, ok := strings.Cut(path, "@"); ok {
+		return repository, reference
+	}
+	if i := strings.LastIndex(path, ":"); i > strings.LastIndex(path, "/") {
+		return path[:i], path[i+1:]
+	}
+	return path, "latest"
+}
+
+type ociClient struct {
+	client   *http.Client
+	username string
+	password string
+}
+
+// get returns the body of the response to a GET request of the given URL, which fails unless the status is 200.
+func (c *ociClient) get(ctx context.Context, rawURL, accept string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("getting %s: %w", req.URL.Path, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("getting %s: unexpected status %d", req.URL.Path, res.StatusCode)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(res.Body, maxDocumentSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", req.URL.Path, err)
+	}
+	if len(b) > maxDocumentSize {
+		return nil, fmt.Errorf("reading %s: exceeds %d bytes", req.URL.Path, maxDocumentSize)
+	}
+	return b, nil
+}
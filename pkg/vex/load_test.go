@@ -0,0 +1,136 @@
+package vex
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+)
+
+func TestParseReference(t *testing.T) {
+	testCases := []struct {
+		path               string
+		expectedRepository string
+		expectedReference  string
+	}{
+		{path: "security/vex:v3", expectedRepository: "security/vex", expectedReference: "v3"},
+		{path: "security/vex@sha256:917f", expectedRepository: "security/vex", expectedReference: "sha256:917f"},
+		{path: "security/vex", expectedRepository: "security/vex", expectedReference: "latest"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			repository, reference := parseReference(tc.path)
+			assert.Equal(t, tc.expectedRepository, repository)
+			assert.Equal(t, tc.expectedReference, reference)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	artifact := harbor.Artifact{Repository: "library/mongo", Digest: "sha256:917f"}
+	curl := tunnel.Vulnerability{
+		VulnerabilityID: "CVE-2023-38545",
+		PkgName:         "curl",
+		PkgIdentifier:   tunnel.PkgIdentifier{PURL: "pkg:rpm/redhat/curl@7.76.1-26.el9"},
+	}
+	openssl := tunnel.Vulnerability{VulnerabilityID: "CVE-2023-0464", PkgName: "openssl"}
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "csaf.json"), []byte(testCSAF), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "openvex.json"), []byte(testOpenVEXLegacy), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("# VEX documents"), 0600))
+
+	openVEX := []byte(testOpenVEXLegacy)
+	openVEXDigest := digest.FromBytes(openVEX)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "robot$vex" || password != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/security/vex/manifests/v3":
+			assert.Equal(t, mediaTypeOCIManifest, r.Header.Get("Accept"))
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"layers": []map[string]any{
+					{"mediaType": "application/vnd.oci.empty.v1+json", "digest": digest.FromString("{}")},
+					{"mediaType": MediaTypeOpenVEX, "digest": openVEXDigest},
+				},
+			})
+		case "/v2/security/vex/manifests/tampered":
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"layers": []map[string]any{
+					{"mediaType": MediaTypeOpenVEX, "digest": digest.FromString("{}")},
+				},
+			})
+		case "/v2/security/vex/blobs/" + openVEXDigest.String(), "/v2/security/vex/blobs/" + digest.FromString("{}").String():
+			_, _ = w.Write(openVEX)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "https://")
+
+	t.Run("Should load JSON documents of directory", func(t *testing.T) {
+		matcher, err := Load(context.Background(), etc.VEX{Path: dir}, etc.Tunnel{})
+		require.NoError(t, err)
+
+		statement, ok := matcher.Match(artifact, curl)
+		assert.True(t, ok)
+		assert.Equal(t, "RHSA-2023:5809", statement.Document)
+		statement, ok = matcher.Match(artifact, openssl)
+		assert.True(t, ok)
+		assert.Equal(t, "https://vex.example.com/public/vex-legacy", statement.Document)
+	})
+
+	t.Run("Should load single document", func(t *testing.T) {
+		matcher, err := Load(context.Background(), etc.VEX{Path: filepath.Join(dir, "csaf.json")}, etc.Tunnel{})
+		require.NoError(t, err)
+
+		_, ok := matcher.Match(artifact, curl)
+		assert.True(t, ok)
+		_, ok = matcher.Match(artifact, openssl)
+		assert.False(t, ok)
+	})
+
+	t.Run("Should pull documents of OCI artifact", func(t *testing.T) {
+		matcher, err := Load(context.Background(), etc.VEX{OCIRef: "oci://" + host + "/security/vex:v3", OCIUsername: "robot$vex", OCIPassword: "s3cret"},
+			etc.Tunnel{Insecure: true})
+		require.NoError(t, err)
+
+		statement, ok := matcher.Match(artifact, openssl)
+		assert.True(t, ok)
+		assert.Equal(t, StatusFixed, statement.Status)
+	})
+
+	t.Run("Should return error when blob digest does not match", func(t *testing.T) {
+		_, err := Load(context.Background(), etc.VEX{OCIRef: "oci://" + host + "/security/vex:tampered", OCIUsername: "robot$vex", OCIPassword: "s3cret"},
+			etc.Tunnel{Insecure: true})
+		assert.EqualError(t, err, "blob digest mismatch: expected "+digest.FromString("{}").String()+", got "+openVEXDigest.String())
+	})
+
+	t.Run("Should return error when unauthorized", func(t *testing.T) {
+		_, err := Load(context.Background(), etc.VEX{OCIRef: "oci://" + host + "/security/vex:v3"}, etc.Tunnel{Insecure: true})
+		assert.EqualError(t, err, "getting /v2/security/vex/manifests/v3: unexpected status 401")
+	})
+
+	t.Run("Should return error with file of invalid document", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "advisory.json")
+		require.NoError(t, os.WriteFile(file, []byte(`{"document": {"category": "csaf_base"}}`), 0600))
+
+		_, err := Load(context.Background(), etc.VEX{Path: file}, etc.Tunnel{})
+		assert.EqualError(t, err, file+": unsupported VEX document: neither OpenVEX nor CSAF VEX")
+	})
+}
@@ -0,0 +1,80 @@
+package vex
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// openVEXDocument is an OpenVEX document. Vulnerabilities and products are either objects, as of OpenVEX 0.2.0,
+// or plain strings, as of earlier versions, which also list the subcomponents of all products in the statement.
+type openVEXDocument struct {
+	ID         string             `json:"@id"`
+	Statements []openVEXStatement `json:"statements"`
+}
+
+type openVEXStatement struct {
+	Vulnerability openVEXVulnerability `json:"vulnerability"`
+	Products      []openVEXComponent   `json:"products"`
+	Subcomponents []openVEXComponent   `json:"subcomponents"`
+	Status        Status               `json:"status"`
+	Justification string               `json:"justification"`
+}
+
+type openVEXVulnerability struct {
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases"`
+}
+
+func (v *openVEXVulnerability) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &v.Name); err == nil {
+		return nil
+	}
+	type vulnerability openVEXVulnerability
+	return json.Unmarshal(b, (*vulnerability)(v))
+}
+
+type openVEXComponent struct {
+	ID            string             `json:"@id"`
+	Subcomponents []openVEXComponent `json:"subcomponents"`
+}
+
+func (c *openVEXComponent) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &c.ID); err == nil {
+		return nil
+	}
+	type component openVEXComponent
+	return json.Unmarshal(b, (*component)(c))
+}
+
+func parseOpenVEX(b []byte) ([]Statement, error) {
+	var d openVEXDocument
+	if err := json.Unmarshal(b, &d); err != nil {
+		return nil, fmt.Errorf("decoding OpenVEX document: %w", err)
+	}
+
+	var statements []Statement
+	for i, s := range d.Statements {
+		if s.Status != StatusNotAffected && s.Status != StatusFixed {
+			continue
+		}
+		if s.Vulnerability.Name == "" {
+			return nil, fmt.Errorf("OpenVEX document %s: statement %d has no vulnerability", d.ID, i)
+		}
+		statement := Statement{
+			Vulnerability: s.Vulnerability.Name,
+			Aliases:       s.Vulnerability.Aliases,
+			Status:        s.Status,
+			Justification: s.Justification,
+			Document:      d.ID,
+		}
+		for _, p := range s.Products {
+			product := Product{ID: p.ID}
+			for _, c := range append(p.Subcomponents, s.Subcomponents...) {
+				product.Subcomponents = append(product.Subcomponents, c.ID)
+			}
+			statement.Products = append(statement.Products, product)
+		}
+		statements = append(statements, statement)
+	}
+	return statements, nil
+}
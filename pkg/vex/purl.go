@@ -0,0 +1,71 @@
+package vex
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+)
+
+// purlTypeOCI is the package URL type of OCI artifacts, whose version is their digest.
+const purlTypeOCI = "oci"
+
+// purl is a package URL, i.e. pkg:type/namespace/name@version?qualifiers#subpath, without its qualifiers and
+// subpath, which do not take part in matching packages.
+type purl struct {
+	Type      string
+	Namespace string
+	Name      string
+	Version   string
+}
+
+func parsePURL(s string) (purl, error) {
+	rest, ok := strings.CutPrefix(s, "pkg:")
+	if !ok {
+		return purl{}, fmt.Errorf("invalid package URL: %s", s)
+	}
+	rest, _, _ = strings.Cut(rest, "#")
+	rest, _, _ = strings.Cut(rest, "?")
+
+	var p purl
+	if at := strings.LastIndex(rest, "@"); at >= 0 {
+		version, err := url.PathUnescape(rest[at+1:])
+		if err != nil {
+			return purl{}, fmt.Errorf("invalid package URL: %s", s)
+		}
+		p.Version = version
+		rest = rest[:at]
+	}
+	segments := strings.Split(strings.Trim(rest, "/"), "/")
+	if len(segments) < 2 {
+		return purl{}, fmt.Errorf("invalid package URL: %s", s)
+	}
+	p.Type = strings.ToLower(segments[0])
+	for i := 1; i < len(segments); i++ {
+		segment, err := url.PathUnescape(segments[i])
+		if err != nil {
+			return purl{}, fmt.Errorf("invalid package URL: %s", s)
+		}
+		segments[i] = segment
+	}
+	p.Name = segments[len(segments)-1]
+	p.Namespace = strings.Join(segments[1:len(segments)-1], "/")
+	return p, nil
+}
+
+// matches returns true if the package URL names the package of the given vulnerability, in any version if it
+// has none. Vulnerabilities reported without a package URL are matched by package name and version.
+func (p purl) matches(v tunnel.Vulnerability) bool {
+	if p.Version != "" && p.Version != v.InstalledVersion {
+		return false
+	}
+	if v.PkgIdentifier.PURL == "" {
+		return p.Name == v.PkgName
+	}
+	pkg, err := parsePURL(v.PkgIdentifier.PURL)
+	if err != nil {
+		return false
+	}
+	return p.Type == pkg.Type && p.Namespace == pkg.Namespace && p.Name == pkg.Name
+}
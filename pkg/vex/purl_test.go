@@ -0,0 +1,56 @@
+package vex
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePURL(t *testing.T) {
+	testCases := []struct {
+		purl          string
+		expectedPURL  purl
+		expectedError string
+	}{
+		{
+			purl:         "pkg:deb/debian/openssl@1.1.1n-0+deb11u4?arch=amd64&distro=debian-11",
+			expectedPURL: purl{Type: "deb", Namespace: "debian", Name: "openssl", Version: "1.1.1n-0+deb11u4"},
+		},
+		{
+			purl:         "pkg:golang/golang.org/x/net@v0.8.0",
+			expectedPURL: purl{Type: "golang", Namespace: "golang.org/x", Name: "net", Version: "v0.8.0"},
+		},
+		{
+			purl:         "pkg:npm/%40angular/core@16.0.0#packages/core",
+			expectedPURL: purl{Type: "npm", Namespace: "@angular", Name: "core", Version: "16.0.0"},
+		},
+		{
+			purl:         "pkg:OCI/mongo@sha256%3A917f?repository_url=index.docker.io/library/mongo",
+			expectedPURL: purl{Type: "oci", Name: "mongo", Version: "sha256:917f"},
+		},
+		{
+			purl:         "pkg:pypi/django",
+			expectedPURL: purl{Type: "pypi", Name: "django"},
+		},
+		{
+			purl:          "deb/debian/openssl",
+			expectedError: "invalid package URL: deb/debian/openssl",
+		},
+		{
+			purl:          "pkg:openssl",
+			expectedError: "invalid package URL: pkg:openssl",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.purl, func(t *testing.T) {
+			p, err := parsePURL(tc.purl)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedPURL, p)
+		})
+	}
+}
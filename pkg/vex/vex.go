@@ -0,0 +1,130 @@
+// Package vex loads VEX documents, i.e. OpenVEX or CSAF VEX documents, whose statements tell which vulnerabilities
+// do not affect the products they name, so that the matching findings are annotated as such in scan reports.
+package vex
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+)
+
+// Status is the status of the products of a statement with regard to its vulnerability.
+type Status string
+
+// Statuses of the statements suppressing findings. Statements of other statuses, e.g. affected or
+// under_investigation, are skipped when documents are parsed.
+const (
+	StatusNotAffected Status = "not_affected"
+	StatusFixed       Status = "fixed"
+)
+
+// Statement tells that the vulnerability with the given ID, or one of its aliases, does not affect the given
+// products, or is fixed in them.
+type Statement struct {
+	Vulnerability string
+	Aliases       []string
+	// Products are the products the statement applies to, or all products if empty.
+	Products      []Product
+	Status        Status
+	Justification string
+	// Document is the ID of the document the statement was read from.
+	Document string
+}
+
+// Product is identified by its package URL, e.g. pkg:oci/mongo@sha256:917f... for the image of an artifact, or
+// pkg:deb/debian/openssl@1.1.1n-0+deb11u4 for a package. If Subcomponents are listed, the statement applies to
+// the vulnerabilities of these packages of the product only.
+type Product struct {
+	ID            string
+	Subcomponents []string
+}
+
+// Parse parses the statements of the given OpenVEX or CSAF VEX document.
+func Parse(b []byte) ([]Statement, error) {
+	var probe struct {
+		Statements json.RawMessage `json:"statements"`
+		Document   *struct {
+			Category string `json:"category"`
+		} `json:"document"`
+	}
+	if err := json.Unmarshal(b, &probe); err != nil {
+		return nil, fmt.Errorf("decoding VEX document: %w", err)
+	}
+	switch {
+	case probe.Statements != nil:
+		return parseOpenVEX(b)
+	case probe.Document != nil && probe.Document.Category == csafCategoryVEX:
+		return parseCSAF(b)
+	}
+	return nil, fmt.Errorf("unsupported VEX document: neither OpenVEX nor CSAF VEX")
+}
+
+// Matcher wraps the Match method.
+// Match returns the statement suppressing the given vulnerability found in the given artifact, and false if no
+// statement matches it.
+type Matcher interface {
+	Match(artifact harbor.Artifact, v tunnel.Vulnerability) (Statement, bool)
+}
+
+type matcher struct {
+	// statements are indexed by the upper case IDs and aliases of their vulnerabilities.
+	statements map[string][]Statement
+}
+
+// NewMatcher constructs a Matcher of the given statements. If several statements match a vulnerability, the one
+// given last applies, so that later documents override earlier ones.
+func NewMatcher(statements []Statement) Matcher {
+	m := &matcher{statements: make(map[string][]Statement)}
+	for _, s := range statements {
+		for _, id := range append([]string{s.Vulnerability}, s.Aliases...) {
+			key := strings.ToUpper(id)
+			m.statements[key] = append(m.statements[key], s)
+		}
+	}
+	return m
+}
+
+func (m *matcher) Match(artifact harbor.Artifact, v tunnel.Vulnerability) (Statement, bool) {
+	statements := m.statements[strings.ToUpper(v.VulnerabilityID)]
+	for i := len(statements) - 1; i >= 0; i-- {
+		if appliesTo(statements[i], artifact, v) {
+			return statements[i], true
+		}
+	}
+	return Statement{}, false
+}
+
+// appliesTo returns true if any product of the given statement is the image of the given artifact, or the
+// vulnerable package.
+func appliesTo(s Statement, artifact harbor.Artifact, v tunnel.Vulnerability) bool {
+	if len(s.Products) == 0 {
+		return true
+	}
+	for _, p := range s.Products {
+		product, err := parsePURL(p.ID)
+		if err != nil {
+			continue
+		}
+		if product.Type == purlTypeOCI {
+			if product.Version != artifact.Digest {
+				continue
+			}
+			if len(p.Subcomponents) == 0 {
+				return true
+			}
+			for _, subcomponent := range p.Subcomponents {
+				if component, err := parsePURL(subcomponent); err == nil && component.matches(v) {
+					return true
+				}
+			}
+			continue
+		}
+		if product.matches(v) {
+			return true
+		}
+	}
+	return false
+}
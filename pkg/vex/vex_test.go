@@ -0,0 +1,326 @@
+package vex
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+)
+
+const testOpenVEX = `{
+  "@context": "https://openvex.dev/ns/v0.2.0",
+  "@id": "https://vex.example.com/public/vex-2e67563e",
+  "statements": [
+    {
+      "vulnerability": {"name": "CVE-2023-44487", "aliases": ["GHSA-qppj-fm5r-hxr3"]},
+      "products": [
+        {
+          "@id": "pkg:oci/mongo@sha256%3A917fb7a0b6b0c1f0ae3d42e5e5f2bb1e4d8e3b3b1a2c3d4e5f60718293a4b5c6",
+          "subcomponents": [{"@id": "pkg:golang/golang.org/x/net@v0.8.0"}]
+        }
+      ],
+      "status": "not_affected",
+      "justification": "vulnerable_code_not_in_execute_path"
+    },
+    {
+      "vulnerability": {"name": "CVE-2023-0464"},
+      "products": [{"@id": "pkg:deb/debian/openssl"}],
+      "status": "affected"
+    }
+  ]
+}`
+
+const testOpenVEXLegacy = `{
+  "@context": "https://openvex.dev/ns",
+  "@id": "https://vex.example.com/public/vex-legacy",
+  "statements": [
+    {
+      "vulnerability": "CVE-2023-0464",
+      "products": ["pkg:oci/mongo@sha256:917f"],
+      "subcomponents": ["pkg:deb/debian/openssl"],
+      "status": "fixed"
+    }
+  ]
+}`
+
+const testCSAF = `{
+  "document": {
+    "category": "csaf_vex",
+    "tracking": {"id": "RHSA-2023:5809"}
+  },
+  "product_tree": {
+    "branches": [
+      {
+        "branches": [
+          {
+            "product": {
+              "product_id": "mongo-image",
+              "product_identification_helper": {"purl": "pkg:oci/mongo@sha256:917f"}
+            }
+          },
+          {
+            "product": {
+              "product_id": "rhel-9",
+              "product_identification_helper": {"cpe": "cpe:/o:redhat:enterprise_linux:9"}
+            }
+          }
+        ]
+      }
+    ],
+    "full_product_names": [
+      {
+        "product_id": "curl",
+        "product_identification_helper": {"purl": "pkg:rpm/redhat/curl"}
+      }
+    ],
+    "relationships": [
+      {
+        "product_reference": "curl",
+        "relates_to_product_reference": "mongo-image",
+        "full_product_name": {"product_id": "mongo-image:curl"}
+      }
+    ]
+  },
+  "vulnerabilities": [
+    {
+      "cve": "CVE-2023-38545",
+      "ids": [{"text": "RHBZ-2241933"}],
+      "product_status": {
+        "known_not_affected": ["mongo-image:curl", "rhel-9"],
+        "fixed": ["curl"]
+      },
+      "flags": [{"label": "component_not_present", "product_ids": ["mongo-image:curl"]}]
+    }
+  ]
+}`
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name               string
+		document           string
+		expectedStatements []Statement
+		expectedError      string
+	}{
+		{
+			name:     "Should parse OpenVEX document",
+			document: testOpenVEX,
+			expectedStatements: []Statement{
+				{
+					Vulnerability: "CVE-2023-44487",
+					Aliases:       []string{"GHSA-qppj-fm5r-hxr3"},
+					Products: []Product{
+						{
+							ID:            "pkg:oci/mongo@sha256%3A917fb7a0b6b0c1f0ae3d42e5e5f2bb1e4d8e3b3b1a2c3d4e5f60718293a4b5c6",
+							Subcomponents: []string{"pkg:golang/golang.org/x/net@v0.8.0"},
+						},
+					},
+					Status:        StatusNotAffected,
+					Justification: "vulnerable_code_not_in_execute_path",
+					Document:      "https://vex.example.com/public/vex-2e67563e",
+				},
+			},
+		},
+		{
+			name:     "Should parse OpenVEX document with plain string vulnerabilities and products",
+			document: testOpenVEXLegacy,
+			expectedStatements: []Statement{
+				{
+					Vulnerability: "CVE-2023-0464",
+					Products:      []Product{{ID: "pkg:oci/mongo@sha256:917f", Subcomponents: []string{"pkg:deb/debian/openssl"}}},
+					Status:        StatusFixed,
+					Document:      "https://vex.example.com/public/vex-legacy",
+				},
+			},
+		},
+		{
+			name:     "Should parse CSAF VEX document",
+			document: testCSAF,
+			expectedStatements: []Statement{
+				{
+					Vulnerability: "CVE-2023-38545",
+					Aliases:       []string{"RHBZ-2241933"},
+					Products:      []Product{{ID: "pkg:oci/mongo@sha256:917f", Subcomponents: []string{"pkg:rpm/redhat/curl"}}},
+					Status:        StatusNotAffected,
+					Justification: "component_not_present",
+					Document:      "RHSA-2023:5809",
+				},
+				{
+					Vulnerability: "CVE-2023-38545",
+					Aliases:       []string{"RHBZ-2241933"},
+					Products:      []Product{{ID: "pkg:rpm/redhat/curl"}},
+					Status:        StatusFixed,
+					Document:      "RHSA-2023:5809",
+				},
+			},
+		},
+		{
+			name:          "Should return error when OpenVEX statement has no vulnerability",
+			document:      `{"@id": "vex-1", "statements": [{"products": ["pkg:deb/debian/openssl"], "status": "not_affected"}]}`,
+			expectedError: "OpenVEX document vex-1: statement 0 has no vulnerability",
+		},
+		{
+			name:          "Should return error when CSAF vulnerability has no CVE or ID",
+			document:      `{"document": {"category": "csaf_vex", "tracking": {"id": "RHSA-1"}}, "vulnerabilities": [{}]}`,
+			expectedError: "CSAF document RHSA-1: vulnerability 0 has no CVE or ID",
+		},
+		{
+			name:          "Should return error when CSAF document is not a VEX document",
+			document:      `{"document": {"category": "csaf_security_advisory"}}`,
+			expectedError: "unsupported VEX document: neither OpenVEX nor CSAF VEX",
+		},
+		{
+			name:          "Should return error when document is not JSON",
+			document:      `statements: []`,
+			expectedError: "decoding VEX document: invalid character 's' looking for beginning of value",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			statements, err := Parse([]byte(tc.document))
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedStatements, statements)
+		})
+	}
+}
+
+func TestMatcher_Match(t *testing.T) {
+	artifact := harbor.Artifact{Repository: "library/mongo", Digest: "sha256:917f"}
+	matcher := NewMatcher([]Statement{
+		{
+			Vulnerability: "CVE-2023-44487",
+			Aliases:       []string{"GHSA-qppj-fm5r-hxr3"},
+			Products:      []Product{{ID: "pkg:oci/mongo@sha256%3A917f", Subcomponents: []string{"pkg:golang/golang.org/x/net"}}},
+			Status:        StatusNotAffected,
+			Document:      "vex-1",
+		},
+		{
+			Vulnerability: "CVE-2023-0464",
+			Products:      []Product{{ID: "pkg:oci/mongo@sha256:917f"}},
+			Status:        StatusNotAffected,
+			Document:      "vex-1",
+		},
+		{
+			Vulnerability: "CVE-2023-38545",
+			Products:      []Product{{ID: "pkg:rpm/redhat/curl@7.76.1-26.el9"}},
+			Status:        StatusFixed,
+			Document:      "vex-1",
+		},
+		{
+			Vulnerability: "CVE-2023-0464",
+			Products:      []Product{{ID: "pkg:oci/mongo@sha256:917f"}},
+			Status:        StatusFixed,
+			Document:      "vex-2",
+		},
+	})
+
+	testCases := []struct {
+		name              string
+		artifact          harbor.Artifact
+		vulnerability     tunnel.Vulnerability
+		expectedDocument  string
+		expectedStatus    Status
+		expectedNoMatches bool
+	}{
+		{
+			name:     "Should match subcomponent of image by package URL",
+			artifact: artifact,
+			vulnerability: tunnel.Vulnerability{
+				VulnerabilityID: "CVE-2023-44487",
+				PkgName:         "golang.org/x/net",
+				PkgIdentifier:   tunnel.PkgIdentifier{PURL: "pkg:golang/golang.org/x/net@v0.8.0"},
+			},
+			expectedDocument: "vex-1",
+			expectedStatus:   StatusNotAffected,
+		},
+		{
+			name:     "Should match alias of vulnerability case-insensitively",
+			artifact: artifact,
+			vulnerability: tunnel.Vulnerability{
+				VulnerabilityID: "ghsa-qppj-fm5r-hxr3",
+				PkgName:         "golang.org/x/net",
+				PkgIdentifier:   tunnel.PkgIdentifier{PURL: "pkg:golang/golang.org/x/net@v0.8.0"},
+			},
+			expectedDocument: "vex-1",
+			expectedStatus:   StatusNotAffected,
+		},
+		{
+			name:     "Should not match package which is not subcomponent of image",
+			artifact: artifact,
+			vulnerability: tunnel.Vulnerability{
+				VulnerabilityID: "CVE-2023-44487",
+				PkgName:         "nghttp2",
+				PkgIdentifier:   tunnel.PkgIdentifier{PURL: "pkg:deb/debian/nghttp2@1.43.0-1"},
+			},
+			expectedNoMatches: true,
+		},
+		{
+			name:     "Should not match image of other digest",
+			artifact: harbor.Artifact{Repository: "library/mongo", Digest: "sha256:0a1b"},
+			vulnerability: tunnel.Vulnerability{
+				VulnerabilityID: "CVE-2023-44487",
+				PkgName:         "golang.org/x/net",
+				PkgIdentifier:   tunnel.PkgIdentifier{PURL: "pkg:golang/golang.org/x/net@v0.8.0"},
+			},
+			expectedNoMatches: true,
+		},
+		{
+			name:     "Should match statement given last",
+			artifact: artifact,
+			vulnerability: tunnel.Vulnerability{
+				VulnerabilityID: "CVE-2023-0464",
+				PkgName:         "openssl",
+			},
+			expectedDocument: "vex-2",
+			expectedStatus:   StatusFixed,
+		},
+		{
+			name:     "Should match package by name and version when reported without package URL",
+			artifact: harbor.Artifact{Repository: "library/centos", Digest: "sha256:0a1b"},
+			vulnerability: tunnel.Vulnerability{
+				VulnerabilityID:  "CVE-2023-38545",
+				PkgName:          "curl",
+				InstalledVersion: "7.76.1-26.el9",
+			},
+			expectedDocument: "vex-1",
+			expectedStatus:   StatusFixed,
+		},
+		{
+			name:     "Should not match other version of package",
+			artifact: harbor.Artifact{Repository: "library/centos", Digest: "sha256:0a1b"},
+			vulnerability: tunnel.Vulnerability{
+				VulnerabilityID:  "CVE-2023-38545",
+				PkgName:          "curl",
+				InstalledVersion: "7.76.1-23.el9",
+			},
+			expectedNoMatches: true,
+		},
+		{
+			name:              "Should not match vulnerability without statement",
+			artifact:          artifact,
+			vulnerability:     tunnel.Vulnerability{VulnerabilityID: "CVE-2024-0001", PkgName: "openssl"},
+			expectedNoMatches: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			statement, ok := matcher.Match(tc.artifact, tc.vulnerability)
+			assert.Equal(t, !tc.expectedNoMatches, ok)
+			assert.Equal(t, tc.expectedDocument, statement.Document)
+			assert.Equal(t, tc.expectedStatus, statement.Status)
+		})
+	}
+
+	t.Run("Should match all products when statement names none", func(t *testing.T) {
+		statement, ok := NewMatcher([]Statement{{Vulnerability: "CVE-2023-0464", Status: StatusNotAffected}}).
+			Match(artifact, tunnel.Vulnerability{VulnerabilityID: "CVE-2023-0464", PkgName: "openssl"})
+		assert.True(t, ok)
+		assert.Equal(t, StatusNotAffected, statement.Status)
+	})
+}
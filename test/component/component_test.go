@@ -181,7 +181,11 @@ func TestComponent(t *testing.T) {
 			report, err := c.GetScanReport(resp.ID)
 			require.NoError(t, err)
 
-			assert.Equal(t, artifact, report.Artifact)
+			assert.Equal(t, artifact.Repository, report.Artifact.Repository)
+			assert.Equal(t, artifact.Digest, report.Artifact.Digest)
+			if assert.NotNil(t, report.Artifact.Platform) {
+				assert.NotEmpty(t, report.Artifact.Platform.OSFamily)
+			}
 			assert.Equal(t, tunnelScanner, report.Scanner)
 			// TODO Adding asserts on CVEs is tricky as we do not have any control over upstream vulnerabilities database used by Tunnel.
 			for _, v := range report.Vulnerabilities {
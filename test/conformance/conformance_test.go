@@ -0,0 +1,349 @@
+//go:build conformance
+
+// Package conformance verifies that the adapter conforms to the Harbor pluggable scanner API specification.
+//
+// Unlike the unit tests of the REST API handler, the scenarios below assert raw HTTP status codes, headers
+// and JSON documents instead of Go types, so that a change of a model that breaks the contract with Harbor
+// fails the suite even if the handler and its tests are changed consistently.
+//
+// See https://github.com/goharbor/pluggable-scanner-spec.
+package conformance
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/clock"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	v1 "github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/http/api/v1"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence/redis"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/queue"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/redisx"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/scan"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/testing/containers"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+)
+
+const (
+	mimeTypeMetadata       = "application/vnd.scanner.adapter.metadata+json; version=1.0"
+	mimeTypeScanRequest    = "application/vnd.scanner.adapter.scan.request+json; version=1.0"
+	mimeTypeScanResponse   = "application/vnd.scanner.adapter.scan.response+json; version=1.0"
+	mimeTypeError          = "application/vnd.scanner.adapter.error; version=1.0"
+	mimeTypeVulnReport     = "application/vnd.security.vulnerability.report; version=1.1"
+	mimeTypeSBOMReport     = "application/vnd.security.sbom.report+json; version=1.0"
+	mimeTypeOCIManifest    = "application/vnd.oci.image.manifest.v1+json"
+	mimeTypeDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+
+	digestFinished = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	digestPending  = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+	digestFailed   = "sha256:3333333333333333333333333333333333333333333333333333333333333333"
+)
+
+// severities are the severities defined by the specification.
+var severities = []interface{}{"Unknown", "Low", "Medium", "High", "Critical"}
+
+func TestConformance(t *testing.T) {
+	if testing.Short() {
+		t.Skip("A conformance test")
+	}
+
+	adapterURL := startAdapter(t)
+
+	t.Run("GET /api/v1/metadata", func(t *testing.T) {
+		rs := do(t, http.MethodGet, adapterURL+"/api/v1/metadata", nil, nil)
+
+		assert.Equal(t, http.StatusOK, rs.StatusCode)
+		assert.Equal(t, mimeTypeMetadata, rs.Header.Get("Content-Type"))
+
+		body := decodeObject(t, rs)
+		scanner := requireObject(t, body, "scanner")
+		for _, key := range []string{"name", "vendor", "version"} {
+			assert.NotEmpty(t, requireString(t, scanner, key), "scanner.%s", key)
+		}
+
+		capabilities, ok := body["capabilities"].([]interface{})
+		require.True(t, ok, "capabilities must be an array")
+		require.NotEmpty(t, capabilities)
+		for _, c := range capabilities {
+			capability, ok := c.(map[string]interface{})
+			require.True(t, ok, "capability must be an object")
+			assert.Subset(t, capability["consumes_mime_types"], []interface{}{mimeTypeOCIManifest, mimeTypeDockerManifest})
+			if capability["type"] == "sbom" {
+				assert.Subset(t, capability["produces_mime_types"], []interface{}{mimeTypeSBOMReport})
+				continue
+			}
+			assert.Subset(t, capability["produces_mime_types"], []interface{}{mimeTypeVulnReport})
+		}
+
+		properties := requireObject(t, body, "properties")
+		for key, value := range properties {
+			assert.IsType(t, "", value, "properties.%s must be a string", key)
+		}
+		assert.Equal(t, "os-package-vulnerability", properties["harbor.scanner-adapter/scanner-type"])
+	})
+
+	t.Run("POST /api/v1/scan", func(t *testing.T) {
+		t.Run("Should accept valid scan request", func(t *testing.T) {
+			rs := requestScan(t, adapterURL, digestFinished)
+
+			assert.Equal(t, http.StatusAccepted, rs.StatusCode)
+			assert.Equal(t, mimeTypeScanResponse, rs.Header.Get("Content-Type"))
+			assert.NotEmpty(t, requireString(t, decodeObject(t, rs), "id"))
+		})
+
+		t.Run("Should reject malformed scan request", func(t *testing.T) {
+			rs := do(t, http.MethodPost, adapterURL+"/api/v1/scan", strings.NewReader(`{"registry":`),
+				http.Header{"Content-Type": {mimeTypeScanRequest}})
+
+			assert.Equal(t, http.StatusBadRequest, rs.StatusCode)
+			assertError(t, rs)
+		})
+
+		for _, tc := range []struct {
+			name string
+			body string
+		}{
+			{
+				name: "Should reject scan request without registry URL",
+				body: `{"registry":{},"artifact":{"repository":"library/alpine","digest":"` + digestFinished + `"}}`,
+			},
+			{
+				name: "Should reject scan request without repository",
+				body: `{"registry":{"url":"https://core.harbor.domain"},"artifact":{"digest":"` + digestFinished + `"}}`,
+			},
+			{
+				name: "Should reject scan request without digest",
+				body: `{"registry":{"url":"https://core.harbor.domain"},"artifact":{"repository":"library/alpine"}}`,
+			},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				rs := do(t, http.MethodPost, adapterURL+"/api/v1/scan", strings.NewReader(tc.body),
+					http.Header{"Content-Type": {mimeTypeScanRequest}})
+
+				assert.Equal(t, http.StatusUnprocessableEntity, rs.StatusCode)
+				assertError(t, rs)
+			})
+		}
+	})
+
+	t.Run("GET /api/v1/scan/{scan_request_id}/report", func(t *testing.T) {
+		t.Run("Should return vulnerability report of finished scan", func(t *testing.T) {
+			id := requireString(t, decodeObject(t, requestScan(t, adapterURL, digestFinished)), "id")
+			rs := pollReport(t, adapterURL, id)
+
+			assert.Equal(t, http.StatusOK, rs.StatusCode)
+			assert.Equal(t, mimeTypeVulnReport, rs.Header.Get("Content-Type"))
+
+			report := decodeObject(t, rs)
+			generatedAt, err := time.Parse(time.RFC3339, requireString(t, report, "generated_at"))
+			require.NoError(t, err, "generated_at must be RFC 3339 date-time")
+			assert.False(t, generatedAt.IsZero())
+
+			artifact := requireObject(t, report, "artifact")
+			assert.Equal(t, "library/alpine", artifact["repository"])
+			assert.Equal(t, digestFinished, artifact["digest"])
+
+			scanner := requireObject(t, report, "scanner")
+			for _, key := range []string{"name", "vendor", "version"} {
+				assert.NotEmpty(t, requireString(t, scanner, key), "scanner.%s", key)
+			}
+
+			assert.Contains(t, severities, report["severity"])
+
+			vulnerabilities, ok := report["vulnerabilities"].([]interface{})
+			require.True(t, ok, "vulnerabilities must be an array")
+			require.Len(t, vulnerabilities, 1)
+			vulnerability, ok := vulnerabilities[0].(map[string]interface{})
+			require.True(t, ok, "vulnerability must be an object")
+			assert.Equal(t, "CVE-2019-1549", vulnerability["id"])
+			assert.Equal(t, "openssl", vulnerability["package"])
+			assert.Equal(t, "1.1.1c-r0", vulnerability["version"])
+			assert.Equal(t, "1.1.1d-r0", vulnerability["fix_version"])
+			assert.Contains(t, severities, vulnerability["severity"])
+			assert.IsType(t, "", vulnerability["description"])
+			assert.IsType(t, []interface{}{}, vulnerability["links"])
+		})
+
+		t.Run("Should redirect while scan is in progress", func(t *testing.T) {
+			id := requireString(t, decodeObject(t, requestScan(t, adapterURL, digestPending)), "id")
+			rs := do(t, http.MethodGet, adapterURL+"/api/v1/scan/"+id+"/report", nil,
+				http.Header{"Accept": {mimeTypeVulnReport}})
+
+			assert.Equal(t, http.StatusFound, rs.StatusCode)
+			assert.Equal(t, "/api/v1/scan/"+id+"/report", rs.Header.Get("Location"))
+		})
+
+		t.Run("Should return error of failed scan", func(t *testing.T) {
+			id := requireString(t, decodeObject(t, requestScan(t, adapterURL, digestFailed)), "id")
+			rs := pollReport(t, adapterURL, id)
+
+			assert.Equal(t, http.StatusInternalServerError, rs.StatusCode)
+			assertError(t, rs)
+		})
+
+		t.Run("Should return not found for unknown scan request", func(t *testing.T) {
+			rs := do(t, http.MethodGet, adapterURL+"/api/v1/scan/unknown/report", nil,
+				http.Header{"Accept": {mimeTypeVulnReport}})
+
+			assert.Equal(t, http.StatusNotFound, rs.StatusCode)
+			assertError(t, rs)
+		})
+
+		t.Run("Should reject unsupported report MIME type", func(t *testing.T) {
+			rs := do(t, http.MethodGet, adapterURL+"/api/v1/scan/unknown/report", nil,
+				http.Header{"Accept": {"application/vnd.scanner.adapter.vuln.report.raw"}})
+
+			assert.Equal(t, http.StatusUnsupportedMediaType, rs.StatusCode)
+			assertError(t, rs)
+		})
+	})
+}
+
+// startAdapter starts the adapter backed by a real Redis and a mocked Tunnel wrapper, and returns its URL.
+func startAdapter(t *testing.T) string {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	config := etc.Config{
+		RedisPool: etc.RedisPool{URL: containers.StartRedis(t)},
+		RedisStore: etc.RedisStore{
+			Namespace:  "harbor.scanner.tunnel:store",
+			ScanJobTTL: time.Hour,
+		},
+		JobQueue: etc.JobQueue{
+			Namespace:         "harbor.scanner.tunnel:job-queue",
+			WorkerConcurrency: 2,
+		},
+	}
+
+	rdb, err := redisx.NewClient(config.RedisPool)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	// Scans of digestPending are blocked until the suite completes.
+	release := make(chan time.Time)
+	t.Cleanup(func() { close(release) })
+
+	wrapper := tunnel.NewMockWrapper()
+	wrapper.On("GetVersion").Return(tunnel.VersionInfo{Version: "v0.46.1"}, nil)
+	wrapper.On("Scan", imageRefWithDigest(digestFinished)).Return(tunnel.Report{
+		Vulnerabilities: []tunnel.Vulnerability{
+			{VulnerabilityID: "CVE-2019-1549", PkgName: "openssl", InstalledVersion: "1.1.1c-r0", FixedVersion: "1.1.1d-r0", Severity: "HIGH"},
+		},
+	}, nil)
+	wrapper.On("Scan", imageRefWithDigest(digestPending)).WaitUntil(release).Return(tunnel.Report{}, nil)
+	wrapper.On("Scan", imageRefWithDigest(digestFailed)).Return(tunnel.Report{}, xerrors.New("running tunnel: exit status 1"))
+
+	store := redis.NewStore(config.RedisStore, rdb)
+	index := redis.NewVulnerabilityIndex(config.RedisStore, rdb)
+	controller := scan.NewController(store, index, nil, wrapper, scan.NewTransformer(clock.System))
+	enqueuer := queue.NewEnqueuer(config.JobQueue, rdb, store, mustIDGenerator(t))
+	worker := queue.NewWorker(config.JobQueue, rdb, store, controller)
+
+	worker.Start(ctx)
+	t.Cleanup(worker.Stop)
+
+	adapter := httptest.NewServer(v1.NewAPIHandler(etc.BuildInfo{Version: "dev"}, config, enqueuer, store, wrapper))
+	t.Cleanup(adapter.Close)
+
+	// The worker subscribes asynchronously, give it a moment before publishing scan jobs.
+	time.Sleep(time.Second)
+
+	return adapter.URL
+}
+
+func mustIDGenerator(t *testing.T) job.IDGenerator {
+	idGenerator, err := job.NewIDGenerator(job.IDGeneratorRandom)
+	require.NoError(t, err)
+	return idGenerator
+}
+
+func imageRefWithDigest(digest string) interface{} {
+	return mock.MatchedBy(func(imageRef tunnel.ImageRef) bool {
+		return strings.HasSuffix(imageRef.Name, "@"+digest)
+	})
+}
+
+func requestScan(t *testing.T, adapterURL, digest string) *http.Response {
+	t.Helper()
+	body := `{"registry":{"url":"http://registry.domain:5000"},"artifact":{"repository":"library/alpine","digest":"` +
+		digest + `","mime_type":"` + mimeTypeDockerManifest + `"}}`
+	return do(t, http.MethodPost, adapterURL+"/api/v1/scan", strings.NewReader(body),
+		http.Header{"Content-Type": {mimeTypeScanRequest}})
+}
+
+// pollReport polls for the scan report until the adapter stops redirecting, the way Harbor does.
+func pollReport(t *testing.T, adapterURL, id string) *http.Response {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		rs := do(t, http.MethodGet, adapterURL+"/api/v1/scan/"+id+"/report", nil,
+			http.Header{"Accept": {mimeTypeVulnReport}})
+		if rs.StatusCode != http.StatusFound {
+			return rs
+		}
+		_ = rs.Body.Close()
+		require.True(t, time.Now().Before(deadline), "scan %s has not finished in time", id)
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func do(t *testing.T, method, url string, body io.Reader, header http.Header) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, body)
+	require.NoError(t, err)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	rs, err := client.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = rs.Body.Close() })
+	return rs
+}
+
+// assertError asserts that the response body is an error document as defined by the specification.
+func assertError(t *testing.T, rs *http.Response) {
+	t.Helper()
+	assert.Equal(t, mimeTypeError, rs.Header.Get("Content-Type"))
+	assert.NotEmpty(t, requireString(t, requireObject(t, decodeObject(t, rs), "error"), "message"))
+}
+
+func decodeObject(t *testing.T, rs *http.Response) map[string]interface{} {
+	t.Helper()
+	var body map[string]interface{}
+	require.NoError(t, json.NewDecoder(rs.Body).Decode(&body), "body must be a JSON object")
+	return body
+}
+
+func requireObject(t *testing.T, object map[string]interface{}, key string) map[string]interface{} {
+	t.Helper()
+	value, ok := object[key].(map[string]interface{})
+	require.True(t, ok, "%s must be an object", key)
+	return value
+}
+
+func requireString(t *testing.T, object map[string]interface{}, key string) string {
+	t.Helper()
+	value, ok := object[key].(string)
+	require.True(t, ok, "%s must be a string", key)
+	return value
+}
@@ -67,6 +67,7 @@ func TestRestApi(t *testing.T) {
 				Digest:     "sha256:6c3c624b58dbbcd3c0dd82b4c53f04194d1247c6eebdaab7c610cf7d66709b3b",
 			},
 		}).Return(job.ScanJob{ID: "job:123"}, nil)
+		enqueuer.On("Position", mock.Anything, "job:123").Return(job.QueuePosition{}, nil)
 
 		// when
 		rs, err := ts.Client().Post(ts.URL+"/api/v1/scan", "application/json", strings.NewReader(`{
@@ -195,6 +196,7 @@ func TestRestApi(t *testing.T) {
   },
   "capabilities": [
     {
+      "type": "vulnerability",
       "consumes_mime_types": [
         "application/vnd.oci.image.manifest.v1+json",
         "application/vnd.docker.distribution.manifest.v2+json"
@@ -202,6 +204,19 @@ func TestRestApi(t *testing.T) {
       "produces_mime_types": [
         "application/vnd.security.vulnerability.report; version=1.1"
       ]
+    },
+    {
+      "type": "sbom",
+      "consumes_mime_types": [
+        "application/vnd.oci.image.manifest.v1+json",
+        "application/vnd.docker.distribution.manifest.v2+json"
+      ],
+      "produces_mime_types": [
+        "application/vnd.security.sbom.report+json; version=1.0"
+      ],
+      "additional_attributes": {
+        "sbom_media_types": ["application/vnd.cyclonedx+json", "application/spdx+json"]
+      }
     }
   ],
   "properties": {
@@ -0,0 +1,304 @@
+//go:build integration
+// +build integration
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence/postgres"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tc "github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// TestStore is an integration test for the PostgreSQL persistence store.
+func TestStore(t *testing.T) {
+	if testing.Short() {
+		t.Skip("An integration test")
+	}
+
+	ctx := context.Background()
+	postgresC, err := tc.GenericContainer(ctx, tc.GenericContainerRequest{
+		ContainerRequest: tc.ContainerRequest{
+			Image:        "postgres:15-alpine",
+			ExposedPorts: []string{"5432/tcp"},
+			Env: map[string]string{
+				"POSTGRES_USER":     "scanner",
+				"POSTGRES_PASSWORD": "scanner",
+				"POSTGRES_DB":       "scanner",
+			},
+			WaitingFor: wait.ForLog("database system is ready to accept connections").WithOccurrence(2),
+		},
+		Started: true,
+	})
+	require.NoError(t, err, "should start postgres container")
+	defer func() {
+		_ = postgresC.Terminate(ctx)
+	}()
+
+	config := etc.PostgresStore{
+		URL:        getPostgresURL(t, ctx, postgresC),
+		ScanJobTTL: parseDuration(t, "10s"),
+	}
+
+	db, err := sql.Open("postgres", config.URL)
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+
+	require.NoError(t, postgres.Migrate(ctx, db), "migrating schema should not fail")
+	require.NoError(t, postgres.Migrate(ctx, db), "migrating schema again should be a no-op")
+
+	store := postgres.NewStore(config, db)
+
+	t.Run("CRUD", func(t *testing.T) {
+		scanJobID := "123"
+
+		err := store.Create(ctx, job.ScanJob{
+			ID:     scanJobID,
+			Status: job.Queued,
+		})
+		require.NoError(t, err, "saving scan job should not fail")
+
+		err = store.Create(ctx, job.ScanJob{ID: scanJobID, Status: job.Queued})
+		assert.True(t, errors.Is(err, persistence.ErrScanJobExists), "saving scan job twice should fail")
+
+		j, err := store.Get(ctx, scanJobID)
+		require.NoError(t, err, "getting scan job should not fail")
+		assert.Equal(t, &job.ScanJob{
+			ID:     scanJobID,
+			Status: job.Queued,
+		}, j)
+
+		err = store.UpdateStatus(ctx, scanJobID, job.Pending)
+		require.NoError(t, err, "updating scan job status should not fail")
+
+		j, err = store.Get(ctx, scanJobID)
+		require.NoError(t, err, "getting scan job should not fail")
+		assert.Equal(t, &job.ScanJob{
+			ID:     scanJobID,
+			Status: job.Pending,
+		}, j)
+
+		scanReport := harbor.ScanReport{
+			Severity: harbor.SevHigh,
+			Vulnerabilities: []harbor.VulnerabilityItem{
+				{
+					ID: "CVE-2013-1400",
+				},
+			},
+		}
+
+		err = store.UpdateReport(ctx, scanJobID, scanReport)
+		require.NoError(t, err, "updating scan job reports should not fail")
+
+		j, err = store.Get(ctx, scanJobID)
+		require.NoError(t, err, "retrieving scan job should not fail")
+		require.NotNil(t, j, "retrieved scan job must not be nil")
+		assert.Equal(t, scanReport, j.Report)
+
+		err = store.UpdateStatus(ctx, scanJobID, job.Finished)
+		require.NoError(t, err)
+
+		err = store.UpdateStatus(ctx, "unknown", job.Finished)
+		assert.EqualError(t, err, "scan job unknown not found")
+
+		time.Sleep(parseDuration(t, "12s"))
+
+		j, err = store.Get(ctx, scanJobID)
+		require.NoError(t, err, "retrieve scan job should not fail")
+		require.Nil(t, j, "retrieved scan job should be nil, i.e. expired")
+
+		err = store.Create(ctx, job.ScanJob{ID: scanJobID, Status: job.Queued})
+		require.NoError(t, err, "saving scan job should replace the expired scan job")
+	})
+
+	t.Run("Extend", func(t *testing.T) {
+		scanJobID := "running"
+
+		require.NoError(t, store.Create(ctx, job.ScanJob{ID: scanJobID, Status: job.Pending}))
+
+		err := store.Extend(ctx, scanJobID, parseDuration(t, "1m"))
+		require.NoError(t, err, "extending scan job should not fail")
+
+		assert.Greater(t, getTTL(t, ctx, db, scanJobID), parseDuration(t, "10s"),
+			"running scan job should outlive the configured TTL")
+
+		require.NoError(t, store.UpdateStatus(ctx, scanJobID, job.Finished))
+
+		assert.LessOrEqual(t, getTTL(t, ctx, db, scanJobID), parseDuration(t, "10s"),
+			"completed scan job should be reset to the configured TTL")
+
+		err = store.Extend(ctx, "unknown", parseDuration(t, "1m"))
+		assert.EqualError(t, err, "scan job unknown not found")
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		scanJobID := "replaced"
+		report := harbor.ScanReport{Severity: harbor.SevHigh}
+
+		require.NoError(t, store.Create(ctx, job.ScanJob{ID: scanJobID, Status: job.Finished, Report: report,
+			Request: &harbor.ScanRequest{Artifact: harbor.Artifact{Repository: "library/mongo", Digest: "sha256:917f5b7f"}}}))
+
+		replacement := job.ScanJob{
+			ID:           scanJobID,
+			Status:       job.Queued,
+			Request:      &harbor.ScanRequest{Artifact: harbor.Artifact{Repository: "library/mongo", Digest: "sha256:2a8ba8b4"}},
+			TraceContext: map[string]string{"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"},
+		}
+		require.NoError(t, store.Replace(ctx, replacement), "replacing scan job should not fail")
+
+		j, err := store.Get(ctx, scanJobID)
+		require.NoError(t, err)
+		assert.Equal(t, &replacement, j)
+
+		err = store.Replace(ctx, job.ScanJob{ID: "unknown", Status: job.Queued})
+		assert.EqualError(t, err, "scan job unknown not found")
+	})
+
+	t.Run("FindByStatus", func(t *testing.T) {
+		for _, scanJob := range []job.ScanJob{
+			{ID: "queued", Status: job.Queued},
+			{ID: "pending", Status: job.Pending},
+			{ID: "finished", Status: job.Finished},
+		} {
+			require.NoError(t, store.Create(ctx, scanJob))
+		}
+
+		scanJobs, err := store.FindByStatus(ctx, job.Queued, job.Pending)
+		require.NoError(t, err, "finding scan jobs should not fail")
+		assert.ElementsMatch(t, []job.ScanJob{
+			{ID: "123", Status: job.Queued},
+			{ID: "queued", Status: job.Queued},
+			{ID: "pending", Status: job.Pending},
+		}, scanJobs)
+	})
+
+	t.Run("ListSummaries", func(t *testing.T) {
+		_, err := postgres.DeleteExpired(ctx, db, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+
+		summaries := postgres.NewStore(etc.PostgresStore{ScanJobTTL: parseDuration(t, "1h")}, db)
+
+		require.NoError(t, summaries.Create(ctx, job.ScanJob{ID: "mongo", Status: job.Queued}))
+		require.NoError(t, summaries.Create(ctx, job.ScanJob{ID: "nginx", Status: job.Queued}))
+		require.NoError(t, summaries.UpdateStatus(ctx, "mongo", job.Pending))
+		require.NoError(t, summaries.UpdateReport(ctx, "mongo", harbor.ScanReport{
+			Severity: harbor.SevHigh,
+			Vulnerabilities: []harbor.VulnerabilityItem{
+				{ID: "CVE-2019-1549", Severity: harbor.SevHigh},
+				{ID: "CVE-2019-14697", Severity: harbor.SevHigh},
+				{ID: "CVE-2019-1547", Severity: harbor.SevLow},
+			},
+		}))
+		require.NoError(t, summaries.UpdateStatus(ctx, "mongo", job.Finished))
+
+		list, err := summaries.ListSummaries(ctx, 0)
+		require.NoError(t, err, "listing scan job summaries should not fail")
+		require.Len(t, list, 2)
+
+		assert.Equal(t, "mongo", list[0].ID, "most recently updated scan job should come first")
+		assert.Equal(t, job.Finished, list[0].Status)
+		assert.Equal(t, 2, list[0].Counts["High"])
+		assert.Equal(t, 1, list[0].Counts["Low"])
+		assert.Equal(t, 0, list[0].Counts["Critical"])
+		assert.False(t, list[0].CreatedAt.IsZero())
+		assert.False(t, list[0].StartedAt.IsZero())
+		assert.False(t, list[0].FinishedAt.Before(list[0].StartedAt))
+
+		assert.Equal(t, "nginx", list[1].ID)
+		assert.Equal(t, job.Queued, list[1].Status)
+		assert.True(t, list[1].StartedAt.IsZero())
+
+		list, err = summaries.ListSummaries(ctx, 1)
+		require.NoError(t, err, "listing scan job summaries should not fail")
+		require.Len(t, list, 1)
+		assert.Equal(t, "mongo", list[0].ID)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		_, err := postgres.DeleteExpired(ctx, db, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+
+		jobs := postgres.NewStore(etc.PostgresStore{ScanJobTTL: parseDuration(t, "1h")}, db)
+
+		require.NoError(t, jobs.Create(ctx, job.ScanJob{ID: "job-3", Status: job.Queued}))
+		require.NoError(t, jobs.Create(ctx, job.ScanJob{ID: "job-1", Status: job.Queued,
+			Request: &harbor.ScanRequest{Artifact: harbor.Artifact{Repository: "library/mongo", Digest: "sha256:917f"}}}))
+		require.NoError(t, jobs.Create(ctx, job.ScanJob{ID: "job-2", Status: job.Queued}))
+		require.NoError(t, jobs.UpdateStatus(ctx, "job-2", job.Failed, "out of memory"))
+
+		entries, err := jobs.List(ctx, persistence.ListOptions{Limit: 2})
+		require.NoError(t, err, "listing scan jobs should not fail")
+		require.Len(t, entries, 2)
+		assert.Equal(t, "job-1", entries[0].ID, "scan jobs should be ordered by ID")
+		assert.Equal(t, &harbor.Artifact{Repository: "library/mongo", Digest: "sha256:917f"}, entries[0].Artifact)
+		assert.False(t, entries[0].CreatedAt.IsZero())
+		assert.Equal(t, "job-2", entries[1].ID)
+		assert.Equal(t, job.Failed, entries[1].Status)
+		assert.Equal(t, "out of memory", entries[1].Error)
+		assert.False(t, entries[1].FinishedAt.IsZero())
+
+		entries, err = jobs.List(ctx, persistence.ListOptions{After: "job-2", Limit: 2})
+		require.NoError(t, err, "listing scan jobs should not fail")
+		require.Len(t, entries, 1)
+		assert.Equal(t, "job-3", entries[0].ID)
+
+		entries, err = jobs.List(ctx, persistence.ListOptions{Statuses: []job.ScanJobStatus{job.Failed}})
+		require.NoError(t, err, "listing scan jobs should not fail")
+		require.Len(t, entries, 1)
+		assert.Equal(t, "job-2", entries[0].ID)
+	})
+
+	t.Run("DeleteExpired", func(t *testing.T) {
+		require.NoError(t, store.Create(ctx, job.ScanJob{ID: "expiring", Status: job.Queued}))
+
+		deleted, err := postgres.DeleteExpired(ctx, db, time.Now())
+		require.NoError(t, err)
+		assert.Zero(t, deleted, "live scan jobs should not be deleted")
+
+		deleted, err = postgres.DeleteExpired(ctx, db, time.Now().Add(parseDuration(t, "11s")))
+		require.NoError(t, err, "deleting expired scan jobs should not fail")
+		assert.Equal(t, int64(1), deleted)
+
+		var count int
+		require.NoError(t, db.QueryRowContext(ctx, `SELECT count(*) FROM scan_jobs WHERE id = 'expiring'`).Scan(&count))
+		assert.Zero(t, count)
+	})
+}
+
+func getTTL(t *testing.T, ctx context.Context, db *sql.DB, scanJobID string) time.Duration {
+	t.Helper()
+	var expiresAt time.Time
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT expires_at FROM scan_jobs WHERE id = $1`, scanJobID).
+		Scan(&expiresAt))
+	return time.Until(expiresAt)
+}
+
+func getPostgresURL(t *testing.T, ctx context.Context, postgresC tc.Container) string {
+	t.Helper()
+	host, err := postgresC.Host(ctx)
+	require.NoError(t, err)
+	port, err := postgresC.MappedPort(ctx, "5432")
+	require.NoError(t, err)
+	return fmt.Sprintf("postgres://scanner:scanner@%s:%d/scanner?sslmode=disable", host, port.Int())
+}
+
+func parseDuration(t *testing.T, s string) time.Duration {
+	t.Helper()
+	d, err := time.ParseDuration(s)
+	require.NoError(t, err, "should parse duration %s", s)
+	return d
+}
@@ -12,7 +12,9 @@ import (
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence/redis"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/policy"
 	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/redisx"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -107,6 +109,459 @@ func TestStore(t *testing.T) {
 		require.Nil(t, j, "retrieved scan job should be nil, i.e. expired")
 	})
 
+	t.Run("Extend", func(t *testing.T) {
+		scanJobID := "running"
+
+		require.NoError(t, store.Create(ctx, job.ScanJob{ID: scanJobID, Status: job.Pending}))
+
+		err := store.Extend(ctx, scanJobID, parseDuration(t, "1m"))
+		require.NoError(t, err, "extending scan job should not fail")
+
+		ttl, err := pool.TTL(ctx, "harbor.scanner.tunnel:store:scan-job:"+scanJobID).Result()
+		require.NoError(t, err)
+		assert.Greater(t, ttl, parseDuration(t, "10s"), "running scan job should outlive the configured TTL")
+
+		require.NoError(t, store.UpdateStatus(ctx, scanJobID, job.Finished))
+
+		ttl, err = pool.TTL(ctx, "harbor.scanner.tunnel:store:scan-job:"+scanJobID).Result()
+		require.NoError(t, err)
+		assert.LessOrEqual(t, ttl, parseDuration(t, "10s"), "completed scan job should be reset to the configured TTL")
+
+		err = store.Extend(ctx, "unknown", parseDuration(t, "1m"))
+		assert.EqualError(t, err, "scan job unknown not found")
+	})
+
+	t.Run("Extend without TTL", func(t *testing.T) {
+		persistent := redis.NewStore(etc.RedisStore{Namespace: "harbor.scanner.tunnel:persistent-store"}, pool)
+		scanJobID := "running"
+
+		require.NoError(t, persistent.Create(ctx, job.ScanJob{ID: scanJobID, Status: job.Pending}))
+		require.NoError(t, persistent.Extend(ctx, scanJobID, parseDuration(t, "1m")))
+
+		ttl, err := pool.TTL(ctx, "harbor.scanner.tunnel:persistent-store:scan-job:"+scanJobID).Result()
+		require.NoError(t, err)
+		assert.Equal(t, time.Duration(-1), ttl, "scan job without TTL should never expire")
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		scanJobID := "replaced"
+		report := harbor.ScanReport{Severity: harbor.SevHigh}
+
+		require.NoError(t, store.Create(ctx, job.ScanJob{ID: scanJobID, Status: job.Finished, Report: report,
+			Request: &harbor.ScanRequest{Artifact: harbor.Artifact{Repository: "library/mongo", Digest: "sha256:917f5b7f"}}}))
+
+		replacement := job.ScanJob{
+			ID:           scanJobID,
+			Status:       job.Queued,
+			Request:      &harbor.ScanRequest{Artifact: harbor.Artifact{Repository: "library/mongo", Digest: "sha256:2a8ba8b4"}},
+			TraceContext: map[string]string{"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"},
+		}
+		require.NoError(t, store.Replace(ctx, replacement), "replacing scan job should not fail")
+
+		j, err := store.Get(ctx, scanJobID)
+		require.NoError(t, err)
+		assert.Equal(t, &replacement, j)
+
+		err = store.Replace(ctx, job.ScanJob{ID: "unknown", Status: job.Queued})
+		assert.EqualError(t, err, "scan job unknown not found")
+	})
+
+	t.Run("FindByStatus", func(t *testing.T) {
+		for _, scanJob := range []job.ScanJob{
+			{ID: "queued", Status: job.Queued},
+			{ID: "pending", Status: job.Pending},
+			{ID: "finished", Status: job.Finished},
+		} {
+			require.NoError(t, store.Create(ctx, scanJob))
+		}
+
+		scanJobs, err := store.FindByStatus(ctx, job.Queued, job.Pending)
+		require.NoError(t, err, "finding scan jobs should not fail")
+		assert.ElementsMatch(t, []job.ScanJob{
+			{ID: "queued", Status: job.Queued},
+			{ID: "pending", Status: job.Pending},
+		}, scanJobs)
+	})
+
+	t.Run("FindByStatus spanning several batches", func(t *testing.T) {
+		for i := 0; i < 250; i++ {
+			require.NoError(t, store.Create(ctx, job.ScanJob{ID: fmt.Sprintf("failed-%d", i), Status: job.Failed}))
+		}
+
+		scanJobs, err := store.FindByStatus(ctx, job.Failed)
+		require.NoError(t, err, "finding scan jobs should not fail")
+		assert.Len(t, scanJobs, 250)
+	})
+
+	t.Run("ListSummaries", func(t *testing.T) {
+		summaries := redis.NewStore(etc.RedisStore{
+			Namespace:  "harbor.scanner.tunnel:summaries",
+			ScanJobTTL: parseDuration(t, "1h"),
+		}, pool)
+
+		require.NoError(t, summaries.Create(ctx, job.ScanJob{ID: "mongo", Status: job.Queued}))
+		require.NoError(t, summaries.Create(ctx, job.ScanJob{ID: "nginx", Status: job.Queued}))
+		require.NoError(t, summaries.UpdateStatus(ctx, "mongo", job.Pending))
+		require.NoError(t, summaries.UpdateReport(ctx, "mongo", harbor.ScanReport{
+			Severity: harbor.SevHigh,
+			Vulnerabilities: []harbor.VulnerabilityItem{
+				{ID: "CVE-2019-1549", Severity: harbor.SevHigh},
+				{ID: "CVE-2019-14697", Severity: harbor.SevHigh},
+				{ID: "CVE-2019-1547", Severity: harbor.SevLow},
+			},
+		}))
+		require.NoError(t, summaries.UpdateStatus(ctx, "mongo", job.Finished))
+
+		list, err := summaries.ListSummaries(ctx, 0)
+		require.NoError(t, err, "listing scan job summaries should not fail")
+		require.Len(t, list, 2)
+
+		assert.Equal(t, "mongo", list[0].ID, "most recently updated scan job should come first")
+		assert.Equal(t, job.Finished, list[0].Status)
+		assert.Equal(t, 2, list[0].Counts["High"])
+		assert.Equal(t, 1, list[0].Counts["Low"])
+		assert.Equal(t, 0, list[0].Counts["Critical"])
+		assert.False(t, list[0].CreatedAt.IsZero())
+		assert.False(t, list[0].FinishedAt.Before(list[0].StartedAt))
+
+		assert.Equal(t, "nginx", list[1].ID)
+		assert.Equal(t, job.Queued, list[1].Status)
+		assert.True(t, list[1].StartedAt.IsZero())
+
+		list, err = summaries.ListSummaries(ctx, 1)
+		require.NoError(t, err, "listing scan job summaries should not fail")
+		require.Len(t, list, 1)
+		assert.Equal(t, "mongo", list[0].ID)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		jobs := redis.NewStore(etc.RedisStore{
+			Namespace:  "harbor.scanner.tunnel:list",
+			ScanJobTTL: parseDuration(t, "1h"),
+		}, pool)
+
+		require.NoError(t, jobs.Create(ctx, job.ScanJob{ID: "job-3", Status: job.Queued}))
+		require.NoError(t, jobs.Create(ctx, job.ScanJob{ID: "job-1", Status: job.Queued,
+			Request: &harbor.ScanRequest{Artifact: harbor.Artifact{Repository: "library/mongo", Digest: "sha256:917f"}}}))
+		require.NoError(t, jobs.Create(ctx, job.ScanJob{ID: "job-2", Status: job.Queued}))
+		require.NoError(t, jobs.UpdateStatus(ctx, "job-2", job.Failed, "out of memory"))
+
+		entries, err := jobs.List(ctx, persistence.ListOptions{Limit: 2})
+		require.NoError(t, err, "listing scan jobs should not fail")
+		require.Len(t, entries, 2)
+		assert.Equal(t, "job-1", entries[0].ID, "scan jobs should be ordered by ID")
+		assert.Equal(t, &harbor.Artifact{Repository: "library/mongo", Digest: "sha256:917f"}, entries[0].Artifact)
+		assert.False(t, entries[0].CreatedAt.IsZero())
+		assert.Equal(t, "job-2", entries[1].ID)
+		assert.Equal(t, job.Failed, entries[1].Status)
+		assert.Equal(t, "out of memory", entries[1].Error)
+		assert.False(t, entries[1].FinishedAt.IsZero())
+
+		entries, err = jobs.List(ctx, persistence.ListOptions{After: "job-2", Limit: 2})
+		require.NoError(t, err, "listing scan jobs should not fail")
+		require.Len(t, entries, 1)
+		assert.Equal(t, "job-3", entries[0].ID)
+
+		entries, err = jobs.List(ctx, persistence.ListOptions{Statuses: []job.ScanJobStatus{job.Failed}})
+		require.NoError(t, err, "listing scan jobs should not fail")
+		require.Len(t, entries, 1)
+		assert.Equal(t, "job-2", entries[0].ID)
+	})
+
+	t.Run("SchemaVersioning", func(t *testing.T) {
+		// Scan job stored by a version of the adapter predating schema versioning.
+		err := pool.Set(ctx, "harbor.scanner.tunnel:store:scan-job:legacy",
+			`{"id": "legacy", "status": 2, "error": "", "report": {"severity": "High"}}`, 0).Err()
+		require.NoError(t, err)
+
+		j, err := store.Get(ctx, "legacy")
+		require.NoError(t, err, "getting legacy scan job should not fail")
+		assert.Equal(t, &job.ScanJob{
+			ID:     "legacy",
+			Status: job.Finished,
+			Report: harbor.ScanReport{Severity: harbor.SevHigh},
+		}, j)
+
+		require.NoError(t, store.UpdateStatus(ctx, "legacy", job.Failed, "rescan"))
+
+		value, err := pool.Get(ctx, "harbor.scanner.tunnel:store:scan-job:legacy").Result()
+		require.NoError(t, err)
+		assert.Contains(t, value, `"schema_version":1`)
+	})
+
+	t.Run("MigrateNamespace", func(t *testing.T) {
+		old := redis.NewStore(etc.RedisStore{Namespace: "harbor.scanner.tunnel:old", ScanJobTTL: parseDuration(t, "1h")}, pool)
+		for i := 0; i < 150; i++ {
+			require.NoError(t, old.Create(ctx, job.ScanJob{ID: fmt.Sprintf("old-%d", i), Status: job.Finished}))
+		}
+
+		var batches int
+		result, err := redis.MigrateNamespace(ctx, pool, redis.NamespaceMigration{
+			From:      "harbor.scanner.tunnel:old",
+			To:        "harbor.scanner.tunnel:copied",
+			Copy:      true,
+			BatchSize: 50,
+			Progress:  func(redis.NamespaceResult) { batches++ },
+		})
+		require.NoError(t, err, "copying namespace should not fail")
+		assert.Equal(t, 0, result.Skipped)
+		assert.Greater(t, result.Migrated, 150, "summaries should be copied along with scan jobs")
+		assert.Greater(t, batches, 1)
+
+		copied := redis.NewStore(etc.RedisStore{Namespace: "harbor.scanner.tunnel:copied", ScanJobTTL: parseDuration(t, "1h")}, pool)
+		j, err := copied.Get(ctx, "old-42")
+		require.NoError(t, err)
+		assert.Equal(t, &job.ScanJob{ID: "old-42", Status: job.Finished}, j)
+		ttl, err := pool.TTL(ctx, "harbor.scanner.tunnel:copied:scan-job:old-42").Result()
+		require.NoError(t, err)
+		assert.Greater(t, ttl, parseDuration(t, "50m"), "TTL should be preserved")
+
+		renamed, err := redis.MigrateNamespace(ctx, pool, redis.NamespaceMigration{
+			From: "harbor.scanner.tunnel:old",
+			To:   "harbor.scanner.tunnel:copied",
+		})
+		require.NoError(t, err, "renaming namespace should not fail")
+		assert.Equal(t, redis.NamespaceResult{Skipped: result.Migrated}, renamed,
+			"keys already in the new namespace should not be overwritten")
+
+		_, err = redis.MigrateNamespace(ctx, pool, redis.NamespaceMigration{
+			From: "harbor.scanner.tunnel:old",
+			To:   "harbor.scanner.tunnel:old:nested",
+		})
+		assert.EqualError(t, err, "namespaces harbor.scanner.tunnel:old and harbor.scanner.tunnel:old:nested must not be nested")
+	})
+
+	t.Run("VulnerabilityIndex", func(t *testing.T) {
+		index := redis.NewVulnerabilityIndex(etc.RedisStore{
+			Namespace:             "harbor.scanner.tunnel:store",
+			VulnerabilityIndexTTL: parseDuration(t, "1h"),
+		}, pool)
+
+		scannedAt := time.Now().UTC().Truncate(time.Second)
+		mongo := harbor.Artifact{Repository: "library/mongo", Digest: "sha256:917f"}
+		nginx := harbor.Artifact{Repository: "library/nginx", Digest: "sha256:3b00"}
+
+		err := index.Index(ctx, "https://core.harbor.domain", harbor.ScanReport{
+			GeneratedAt: scannedAt,
+			Artifact:    mongo,
+			Vulnerabilities: []harbor.VulnerabilityItem{
+				{ID: "CVE-2019-1549", Pkg: "openssl", Version: "1.1.1c-r0", FixVersion: "1.1.1d-r0", Severity: harbor.SevMedium},
+				{ID: "CVE-2019-1549", Pkg: "libssl", Version: "1.1.1c-r0", FixVersion: "1.1.1d-r0", Severity: harbor.SevHigh},
+				{ID: "CVE-2019-14697", Pkg: "musl", Version: "1.1.22-r2", Severity: harbor.SevCritical},
+			},
+		})
+		require.NoError(t, err, "indexing vulnerabilities should not fail")
+
+		err = index.Index(ctx, "https://core.harbor.domain", harbor.ScanReport{
+			GeneratedAt: scannedAt,
+			Artifact:    nginx,
+			Vulnerabilities: []harbor.VulnerabilityItem{
+				{ID: "CVE-2019-1549", Pkg: "openssl", Version: "1.1.1c-r0", FixVersion: "1.1.1d-r0", Severity: harbor.SevHigh},
+			},
+		})
+		require.NoError(t, err, "indexing vulnerabilities should not fail")
+
+		artifacts, err := index.FindArtifacts(ctx, "CVE-2019-1549")
+		require.NoError(t, err, "finding affected artifacts should not fail")
+		assert.Equal(t, []persistence.AffectedArtifact{
+			{
+				Registry:   "https://core.harbor.domain",
+				Repository: "library/mongo",
+				Digest:     "sha256:917f",
+				Severity:   harbor.SevHigh,
+				Packages: []persistence.AffectedPackage{
+					{Name: "openssl", Version: "1.1.1c-r0", FixVersion: "1.1.1d-r0"},
+					{Name: "libssl", Version: "1.1.1c-r0", FixVersion: "1.1.1d-r0"},
+				},
+				ScannedAt: scannedAt,
+			},
+			{
+				Registry:   "https://core.harbor.domain",
+				Repository: "library/nginx",
+				Digest:     "sha256:3b00",
+				Severity:   harbor.SevHigh,
+				Packages: []persistence.AffectedPackage{
+					{Name: "openssl", Version: "1.1.1c-r0", FixVersion: "1.1.1d-r0"},
+				},
+				ScannedAt: scannedAt,
+			},
+		}, artifacts)
+
+		// Rescanning mongo after the fix removes it from the vulnerability.
+		err = index.Index(ctx, "https://core.harbor.domain", harbor.ScanReport{
+			GeneratedAt: scannedAt,
+			Artifact:    mongo,
+			Vulnerabilities: []harbor.VulnerabilityItem{
+				{ID: "CVE-2019-14697", Pkg: "musl", Version: "1.1.22-r2", Severity: harbor.SevCritical},
+			},
+		})
+		require.NoError(t, err, "indexing vulnerabilities should not fail")
+
+		artifacts, err = index.FindArtifacts(ctx, "CVE-2019-1549")
+		require.NoError(t, err, "finding affected artifacts should not fail")
+		require.Len(t, artifacts, 1)
+		assert.Equal(t, "library/nginx", artifacts[0].Repository)
+
+		ids, err := index.FindVulnerabilities(ctx, "https://core.harbor.domain", mongo)
+		require.NoError(t, err, "finding indexed vulnerabilities should not fail")
+		assert.Equal(t, []string{"CVE-2019-14697"}, ids)
+
+		artifacts, err = index.FindArtifacts(ctx, "CVE-2021-44228")
+		require.NoError(t, err, "finding affected artifacts should not fail")
+		assert.Empty(t, artifacts)
+	})
+
+	t.Run("SBOMStore", func(t *testing.T) {
+		sboms := redis.NewSBOMStore(etc.RedisStore{
+			Namespace: "harbor.scanner.tunnel:store",
+			SBOMTTL:   parseDuration(t, "1h"),
+		}, pool)
+
+		sbom := []byte(`{"bomFormat": "CycloneDX", "specVersion": "1.5", "components": []}`)
+		mongo := harbor.Artifact{Repository: "library/mongo", Digest: "sha256:917f"}
+
+		stored, err := sboms.Get(ctx, mongo.Digest)
+		require.NoError(t, err, "getting SBOM should not fail")
+		assert.Nil(t, stored)
+
+		err = sboms.Save(ctx, "https://core.harbor.domain", mongo, sbom)
+		require.NoError(t, err, "saving SBOM should not fail")
+
+		stored, err = sboms.Get(ctx, mongo.Digest)
+		require.NoError(t, err, "getting SBOM should not fail")
+		assert.Equal(t, sbom, stored)
+
+		artifacts, err := sboms.FindArtifacts(ctx)
+		require.NoError(t, err, "finding SBOM artifacts should not fail")
+		require.Len(t, artifacts, 1)
+		assert.Equal(t, "https://core.harbor.domain", artifacts[0].Registry)
+		assert.Equal(t, mongo.Repository, artifacts[0].Repository)
+		assert.Equal(t, mongo.Digest, artifacts[0].Digest)
+	})
+
+	t.Run("LifetimeStore", func(t *testing.T) {
+		lifetimes := redis.NewLifetimeStore(etc.RedisStore{
+			Namespace:   "harbor.scanner.tunnel:lifetimes",
+			LifetimeTTL: parseDuration(t, "1h"),
+		}, pool)
+		firstScannedAt := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+		rescannedAt := time.Date(2024, 3, 3, 9, 30, 0, 0, time.UTC)
+		mongo := map[string]persistence.VulnerabilityLifetime{
+			"CVE-2024-3094": {Severity: harbor.SevCritical, FirstSeen: firstScannedAt, LastSeen: firstScannedAt,
+				RemediatedAt: rescannedAt},
+			"CVE-2019-1549": {Severity: harbor.SevHigh, FirstSeen: firstScannedAt, LastSeen: rescannedAt},
+		}
+		nginx := map[string]persistence.VulnerabilityLifetime{
+			"CVE-2019-1563": {Severity: harbor.SevHigh, FirstSeen: firstScannedAt, LastSeen: rescannedAt},
+		}
+
+		stored, err := lifetimes.Get(ctx, "sha256:917f")
+		require.NoError(t, err, "getting vulnerability lifetimes should not fail")
+		assert.Empty(t, stored)
+
+		require.NoError(t, lifetimes.Save(ctx, "sha256:917f", mongo), "saving vulnerability lifetimes should not fail")
+		require.NoError(t, lifetimes.Save(ctx, "sha256:6c3c", nginx), "saving vulnerability lifetimes should not fail")
+		stored, err = lifetimes.Get(ctx, "sha256:917f")
+		require.NoError(t, err, "getting vulnerability lifetimes should not fail")
+		assert.Equal(t, mongo, stored)
+
+		summary, err := lifetimes.Summarize(ctx)
+		require.NoError(t, err, "summarizing vulnerability lifetimes should not fail")
+		assert.Equal(t, 2, summary.Artifacts)
+		assert.Equal(t, 2, summary.Open)
+		assert.Equal(t, 1, summary.Remediated)
+		assert.Equal(t, int64(172800), summary.MeanTimeToRemediateSeconds)
+		assert.Equal(t, 1, summary.BySeverity["Critical"].Remediated)
+		assert.Equal(t, 2, summary.BySeverity["High"].Open)
+
+		delete(mongo, "CVE-2024-3094")
+		require.NoError(t, lifetimes.Save(ctx, "sha256:917f", mongo), "saving vulnerability lifetimes should not fail")
+		stored, err = lifetimes.Get(ctx, "sha256:917f")
+		require.NoError(t, err, "getting vulnerability lifetimes should not fail")
+		assert.Equal(t, mongo, stored, "saved lifetimes should replace the previous ones")
+
+		firstSeenKey := "harbor.scanner.tunnel:lifetimes:sbom-first-seen:sha256:a1b2"
+		require.NoError(t, pool.HSet(ctx, firstSeenKey, "CVE-2019-1549", firstScannedAt.Format(time.RFC3339Nano)).Err())
+		stored, err = lifetimes.Get(ctx, "sha256:a1b2")
+		require.NoError(t, err, "getting vulnerability lifetimes should not fail")
+		assert.Equal(t, map[string]persistence.VulnerabilityLifetime{
+			"CVE-2019-1549": {FirstSeen: firstScannedAt, LastSeen: firstScannedAt},
+		}, stored, "first seen times should be got for digests without lifetimes")
+
+		require.NoError(t, lifetimes.Save(ctx, "sha256:a1b2", stored), "saving vulnerability lifetimes should not fail")
+		exists, err := pool.Exists(ctx, firstSeenKey).Result()
+		require.NoError(t, err)
+		assert.Zero(t, exists, "first seen times should be deleted once lifetimes are saved")
+	})
+
+	t.Run("ExceptionStore", func(t *testing.T) {
+		exceptions := redis.NewExceptionStore(etc.RedisStore{
+			Namespace: "harbor.scanner.tunnel:exceptions",
+		}, pool)
+		requestedAt := time.Now().UTC().Truncate(time.Second)
+		web := policy.Exception{
+			ID:              "8a1c2f6e",
+			Repository:      "storefront/web",
+			VulnerabilityID: "CVE-2019-1549",
+			Justification:   "Not reachable",
+			ExpiresAt:       requestedAt.Add(time.Hour),
+			Status:          policy.ExceptionRequested,
+			RequestedBy:     "alice",
+			RequestedAt:     requestedAt,
+		}
+		api := policy.Exception{
+			ID:              "b03e9d47",
+			Repository:      "storefront/api",
+			Digest:          "sha256:6c3c",
+			VulnerabilityID: "CVE-2019-1563",
+			Justification:   "Fix pending",
+			ExpiresAt:       requestedAt.Add(time.Hour),
+			Status:          policy.ExceptionRequested,
+			RequestedBy:     "bob",
+			RequestedAt:     requestedAt.Add(time.Minute),
+		}
+
+		stored, err := exceptions.Get(ctx, web.ID)
+		require.NoError(t, err, "getting policy exception should not fail")
+		assert.Nil(t, stored)
+
+		require.NoError(t, exceptions.Save(ctx, api), "saving policy exception should not fail")
+		require.NoError(t, exceptions.Save(ctx, web), "saving policy exception should not fail")
+
+		approvedAt := requestedAt.Add(2 * time.Minute)
+		web.Status = policy.ExceptionApproved
+		web.ApprovedBy = "carol"
+		web.ApprovedAt = &approvedAt
+		require.NoError(t, exceptions.Save(ctx, web), "saving policy exception should not fail")
+		stored, err = exceptions.Get(ctx, web.ID)
+		require.NoError(t, err, "getting policy exception should not fail")
+		assert.Equal(t, &web, stored)
+
+		listed, err := exceptions.List(ctx)
+		require.NoError(t, err, "listing policy exceptions should not fail")
+		assert.Equal(t, []policy.Exception{web, api}, listed, "exceptions should be listed in the order they were requested")
+
+		ttl, err := pool.TTL(ctx, "harbor.scanner.tunnel:exceptions:policy-exception:8a1c2f6e").Result()
+		require.NoError(t, err)
+		assert.True(t, ttl > 0 && ttl <= time.Hour, "exception should expire when it expires")
+	})
+
+	t.Run("FailureIndex", func(t *testing.T) {
+		failures := redis.NewFailureIndex(config, pool, 2, parseDuration(t, "1h"))
+		digest := "sha256:917f"
+
+		for _, message := range []string{"MANIFEST_UNKNOWN", "unsupported MediaType"} {
+			failed, err := failures.Find(ctx, digest)
+			require.NoError(t, err, "finding failure should not fail")
+			assert.Empty(t, failed, "digest should not fail fast before threshold")
+
+			require.NoError(t, failures.Record(ctx, digest, message), "recording failure should not fail")
+		}
+
+		failed, err := failures.Find(ctx, digest)
+		require.NoError(t, err, "finding failure should not fail")
+		assert.Equal(t, "unsupported MediaType", failed)
+	})
 }
 
 func getRedisURL(t *testing.T, ctx context.Context, redisC tc.Container) string {
@@ -0,0 +1,68 @@
+//go:build integration
+
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence/redis"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/queue"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/redisx"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/testing/containers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestEnqueuer_MaxBacklog is an integration test of rejecting scan requests while the backlog is full, as if all
+// workers were busy.
+func TestEnqueuer_MaxBacklog(t *testing.T) {
+	if testing.Short() {
+		t.Skip("An integration test")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	config := etc.Config{
+		RedisPool: etc.RedisPool{URL: containers.StartRedis(t)},
+		RedisStore: etc.RedisStore{
+			Namespace:  "harbor.scanner.tunnel:store",
+			ScanJobTTL: time.Hour,
+		},
+		JobQueue: etc.JobQueue{
+			Namespace:         "harbor.scanner.tunnel:job-queue",
+			WorkerConcurrency: 1,
+			MaxBacklog:        2,
+		},
+	}
+
+	rdb, err := redisx.NewClient(config.RedisPool)
+	require.NoError(t, err)
+	defer func() { _ = rdb.Close() }()
+
+	idGenerator, err := job.NewIDGenerator(job.IDGeneratorULID)
+	require.NoError(t, err)
+	enqueuer := queue.NewEnqueuer(config.JobQueue, rdb, redis.NewStore(config.RedisStore, rdb), idGenerator)
+
+	request := harbor.ScanRequest{
+		Registry: harbor.Registry{URL: "https://core.harbor.domain"},
+		Artifact: harbor.Artifact{
+			Repository: "library/alpine",
+			Digest:     "sha256:917f5b7f4bef1b35ee90f03033f33a81002511c1e0767fd44276d4bd9cd2fa8e",
+		},
+	}
+
+	// No worker picks up the scan jobs, which stay in the backlog.
+	for i := 0; i < config.JobQueue.MaxBacklog; i++ {
+		_, err = enqueuer.Enqueue(ctx, request)
+		require.NoError(t, err)
+	}
+
+	_, err = enqueuer.Enqueue(ctx, request)
+	assert.ErrorIs(t, err, queue.ErrBacklogFull)
+}
@@ -0,0 +1,107 @@
+//go:build integration
+
+package queue
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/clock"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence/redis"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/queue"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/redisx"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/testing/containers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingController records the scan jobs it is asked to scan.
+type recordingController struct {
+	mu      sync.Mutex
+	scanned []string
+}
+
+func (c *recordingController) Scan(_ context.Context, scanJobID string, _ harbor.ScanRequest) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.scanned = append(c.scanned, scanJobID)
+	return nil
+}
+
+func (c *recordingController) Scanned() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.scanned...)
+}
+
+// TestRecover is an integration test of rebuilding the queue from the store, as if Redis
+// had been restored from a backup without the queue messages.
+func TestRecover(t *testing.T) {
+	if testing.Short() {
+		t.Skip("An integration test")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	config := etc.Config{
+		RedisPool: etc.RedisPool{URL: containers.StartRedis(t)},
+		RedisStore: etc.RedisStore{
+			Namespace:  "harbor.scanner.tunnel:store",
+			ScanJobTTL: time.Hour,
+		},
+		JobQueue: etc.JobQueue{
+			Namespace:         "harbor.scanner.tunnel:job-queue",
+			WorkerConcurrency: 1,
+		},
+	}
+
+	rdb, err := redisx.NewClient(config.RedisPool)
+	require.NoError(t, err)
+	defer func() { _ = rdb.Close() }()
+
+	store := redis.NewStore(config.RedisStore, rdb)
+	request := &harbor.ScanRequest{
+		Registry: harbor.Registry{URL: "https://core.harbor.domain"},
+		Artifact: harbor.Artifact{
+			Repository: "library/alpine",
+			Digest:     "sha256:917f5b7f4bef1b35ee90f03033f33a81002511c1e0767fd44276d4bd9cd2fa8e",
+		},
+	}
+
+	for _, scanJob := range []job.ScanJob{
+		{ID: "queued", Status: job.Queued, Request: request},
+		{ID: "pending", Status: job.Pending, Request: request},
+		{ID: "locked", Status: job.Pending, Request: request},
+		{ID: "finished", Status: job.Finished, Request: request},
+		{ID: "legacy", Status: job.Queued},
+	} {
+		require.NoError(t, store.Create(ctx, scanJob))
+	}
+	// The lock of a scan job being processed by another replica.
+	require.NoError(t, rdb.Set(ctx, config.JobQueue.Namespace+"jobs:scan_artifact:lock:locked", "", time.Minute).Err())
+
+	controller := &recordingController{}
+	worker := queue.NewWorker(config.JobQueue, rdb, store, controller)
+	worker.Start(ctx)
+	defer worker.Stop()
+
+	recovered, err := queue.Recover(ctx, config.JobQueue, rdb, store, clock.System)
+	require.NoError(t, err)
+	assert.Equal(t, 2, recovered)
+
+	assert.Eventually(t, func() bool {
+		return len(controller.Scanned()) == 2
+	}, 10*time.Second, 100*time.Millisecond)
+	assert.ElementsMatch(t, []string{"queued", "pending"}, controller.Scanned())
+
+	legacy, err := store.Get(ctx, "legacy")
+	require.NoError(t, err)
+	assert.Equal(t, job.Failed, legacy.Status)
+	assert.Equal(t, "scan job was lost and cannot be recovered", legacy.Error)
+}
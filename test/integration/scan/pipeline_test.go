@@ -0,0 +1,120 @@
+//go:build integration
+
+package scan
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/clock"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/etc"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/harbor"
+	v1 "github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/http/api/v1"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/job"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/persistence/redis"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/queue"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/redisx"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/scan"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/testing/containers"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/testing/harborclient"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/testing/registry"
+	"github.com/khulnasoft-lab/harbor-scanner-tunnel/pkg/tunnel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// TestScanPipeline is an integration test of the scan pipeline, from accepting a scan request to
+// serving the scan report, backed by a real Redis, a fake registry and a mocked Tunnel wrapper.
+func TestScanPipeline(t *testing.T) {
+	if testing.Short() {
+		t.Skip("An integration test")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	image, err := registry.NewImage(map[string][]byte{
+		"etc/os-release": []byte("ID=alpine\nVERSION_ID=3.10.2\n"),
+	})
+	require.NoError(t, err)
+
+	fakeRegistry := registry.NewServer()
+	defer fakeRegistry.Close()
+	imageDigest := fakeRegistry.Push("library/alpine", "3.10.2", image)
+
+	config := etc.Config{
+		RedisPool: etc.RedisPool{URL: containers.StartRedis(t)},
+		RedisStore: etc.RedisStore{
+			Namespace:  "harbor.scanner.tunnel:store",
+			ScanJobTTL: time.Hour,
+		},
+		JobQueue: etc.JobQueue{
+			Namespace:         "harbor.scanner.tunnel:job-queue",
+			WorkerConcurrency: 1,
+		},
+	}
+
+	rdb, err := redisx.NewClient(config.RedisPool)
+	require.NoError(t, err)
+	defer func() { _ = rdb.Close() }()
+
+	wrapper := tunnel.NewMockWrapper()
+	wrapper.On("Scan", tunnel.ImageRef{
+		Name:     fakeRegistry.Host() + "/library/alpine@" + imageDigest.String(),
+		Auth:     tunnel.NoAuth{},
+		Insecure: true,
+	}).Run(func(args mock.Arguments) {
+		// Pull the manifest like Tunnel would, to make sure the scanned image is served by the fake registry.
+		res, err := http.Get(fakeRegistry.URL() + "/v2/library/alpine/manifests/" + imageDigest.String())
+		require.NoError(t, err)
+		_ = res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+	}).Return(tunnel.Report{
+		Vulnerabilities: []tunnel.Vulnerability{
+			{VulnerabilityID: "CVE-2019-1549", PkgName: "openssl", InstalledVersion: "1.1.1c-r0", FixedVersion: "1.1.1d-r0", Severity: "HIGH"},
+		},
+	}, nil)
+
+	idGenerator, err := job.NewIDGenerator(job.IDGeneratorULID)
+	require.NoError(t, err)
+
+	store := redis.NewStore(config.RedisStore, rdb)
+	index := redis.NewVulnerabilityIndex(config.RedisStore, rdb)
+	controller := scan.NewController(store, index, nil, wrapper, scan.NewTransformer(clock.System))
+	enqueuer := queue.NewEnqueuer(config.JobQueue, rdb, store, idGenerator)
+	worker := queue.NewWorker(config.JobQueue, rdb, store, controller)
+
+	worker.Start(ctx)
+	defer worker.Stop()
+
+	adapter := httptest.NewServer(v1.NewAPIHandler(etc.BuildInfo{}, config, enqueuer, store, wrapper,
+		v1.WithVulnerabilityIndex(index)))
+	defer adapter.Close()
+
+	// The worker subscribes asynchronously, give it a moment before publishing scan jobs.
+	time.Sleep(time.Second)
+
+	report, err := harborclient.NewClient(adapter.URL, nil).Scan(ctx, harbor.ScanRequest{
+		Registry: harbor.Registry{URL: fakeRegistry.URL()},
+		Artifact: harbor.Artifact{
+			Repository: "library/alpine",
+			Digest:     imageDigest.String(),
+			MimeType:   registry.MediaTypeManifest,
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, harbor.SevHigh, report.Severity)
+	require.Len(t, report.Vulnerabilities, 1)
+	assert.Equal(t, "CVE-2019-1549", report.Vulnerabilities[0].ID)
+
+	artifacts, err := index.FindArtifacts(ctx, "CVE-2019-1549")
+	require.NoError(t, err)
+	require.Len(t, artifacts, 1)
+	assert.Equal(t, imageDigest.String(), artifacts[0].Digest)
+	wrapper.AssertExpectations(t)
+}